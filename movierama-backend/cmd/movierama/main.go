@@ -1,90 +1,443 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/go-redis/redis/v8"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/app/admin"
 	"movierama/internal/app/auth"
 	movieapp "movierama/internal/app/movie"
+	"movierama/internal/bruteforce"
 	"movierama/internal/config"
+	"movierama/internal/contentfilter"
+	"movierama/internal/health"
+	grpcapi "movierama/internal/infra/grpc"
+	"movierama/internal/infra/http/openapi"
+	"movierama/internal/infra/http/requestauth"
+	"movierama/internal/infra/http/requestcompress"
+	"movierama/internal/infra/http/requestlocale"
+	"movierama/internal/infra/http/requestlog"
+	"movierama/internal/infra/http/requesttrace"
+	adminroute "movierama/internal/infra/http/router/admin"
 	authroute "movierama/internal/infra/http/router/auth"
+	graphqlroute "movierama/internal/infra/http/router/graphql"
+	healthroute "movierama/internal/infra/http/router/health"
+	jwksroute "movierama/internal/infra/http/router/jwks"
+	metricsroute "movierama/internal/infra/http/router/metrics"
 	movieroute "movierama/internal/infra/http/router/movie"
+	"movierama/internal/infra/http/server"
 	sqlrepo "movierama/internal/infra/repository/sql"
-	"movierama/internal/infra/repository/sql/movie"
-	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/jwtkeys"
+	"movierama/internal/logging"
+	"movierama/internal/mail"
+	"movierama/internal/metrics"
+	"movierama/internal/oidc"
+	"movierama/internal/ratelimit"
+	"movierama/internal/tracing"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 func main() {
+	configFile := flag.String("config", "", "YAML or TOML config file, overridden by the env variables (default $APP_CONFIG_FILE)")
+	migrate := flag.Bool("migrate", false, "Apply the pending migrations before serving")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [migrate up|down [N]|status|version|force VERSION]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	e := echo.New()
 
-	if err := run(e); err != nil {
-		e.Logger.Fatalf("Error: %v")
+	// Shut down gracefully on SIGTERM and SIGINT.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	var err error
+	if flag.Arg(0) == "migrate" {
+		err = runMigrate(ctx, e, *configFile, flag.Args()[1:])
+	} else {
+		err = run(ctx, e, *configFile, *migrate)
+	}
+	if err != nil {
+		e.Logger.Fatalf("Error: %v", err)
 	}
 }
 
-func run(e *echo.Echo) error {
-	err := godotenv.Load("../../.env")
+func run(ctx context.Context, e *echo.Echo, configFile string, migrate bool) error {
+	cfg, err := loadConfig(e, configFile)
 	if err != nil {
-		e.Logger.Warnf("no .env file exists: %v", err)
+		return err
 	}
 
-	// Setup app config
-	cfg := config.New()
+	logger, err := logging.New(cfg.App.LogLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	// Load the API description used for the docs and request validation.
+	doc, err := openapi.Spec()
+	if err != nil {
+		return err
+	}
+
+	// Setup the metrics registry.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	m := metrics.New(reg)
+
+	// Setup tracing, the spans are only exported when an OTLP endpoint is configured.
+	tp, shutdownTracing, err := tracing.NewProvider(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("flush spans", zap.Error(err))
+		}
+	}()
+
+	// Take the client IP from X-Forwarded-For when the request comes from a proxy of a private
+	// network, so that the clients cannot spoof the IP of the rate limits.
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
 
 	// Middleware
-	e.Use(middleware.Logger())
+	e.Use(requestlog.RequestID())
+	e.Use(requestlog.Logger(logger))
+	e.Use(metricsroute.Middleware(m))
+	e.Use(requesttrace.Middleware(tp))
 	e.Use(middleware.Recover())
+	e.Use(requestcompress.Middleware())
+	e.Use(requestlocale.Middleware())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{cfg.App.FrontendURL},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowOrigins:     cfg.App.FrontendURLs,
+		AllowCredentials: true,
+		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, "Accept-Language",
+			echo.HeaderAuthorization, echo.HeaderXRequestID, "traceparent", "tracestate",
+			"If-None-Match", echo.HeaderIfModifiedSince},
+		ExposeHeaders: []string{echo.HeaderXRequestID, echo.HeaderRetryAfter,
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "ETag"},
 	}))
+	e.Use(openapi.Validator(doc))
 
-	reader, writer := setUpDB(cfg)
-	defer func() {
-		reader.Close()
-		writer.Close()
-	}()
+	// Apply the pending migrations before serving when asked to, waiting for the database to come up.
+	// The memory storage has no schema.
+	if migrate && cfg.Storage.Driver != config.DriverMemory {
+		if err = migrateUp(ctx, cfg, logger); err != nil {
+			return err
+		}
+	}
 
-	// Initialise repositories.
-	mr, err := movie.NewRepository(reader, writer)
+	// Check the dependencies on readiness, redis only when configured.
+	checker := health.NewChecker(cfg.App.ReadyTimeout)
+	var rdb *redis.Client
+	if cfg.Redis.Host != "" {
+		rdb = setUpRedis(cfg)
+		defer rdb.Close()
+		checker.Add("redis", func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		})
+	}
+	store, err := setUpStorage(ctx, cfg, tp, reg, rdb, checker, logger)
 	if err != nil {
 		return err
 	}
-	ur, err := user.NewRepository(reader, writer)
+	defer store.close()
+	limiter, err := setUpLimiter(cfg, rdb, logger)
 	if err != nil {
 		return err
 	}
 
-	// Initialise Services.
-	as := auth.NewService(ur, cfg)
-	ms := movieapp.NewService(mr)
+	mailer, err := setUpMailer(cfg, logger)
+	if err != nil {
+		return err
+	}
 
-	// Configure middleware with the custom claims type
-	jwtCfg := middleware.JWTConfig{
-		Claims:     &auth.JwtCustomClaims{},
-		SigningKey: []byte(cfg.App.JWTSecret),
+	keys, err := setUpKeys(cfg, logger)
+	if err != nil {
+		return err
 	}
+
+	// Initialise Services.
+	as := auth.NewTracedService(auth.NewService(store.users, setUpGuard(cfg, rdb, logger), setUpOIDC(cfg, rdb), mailer, keys,
+		cfg, logger, m), tp)
+	ms := movieapp.NewTracedService(movieapp.NewService(store.movies, store.flags, setUpFilter(cfg, store.movies), logger, m), tp)
+	ads := admin.NewTracedService(admin.NewService(store.adminUsers, store.adminMovies, store.moderation, store.reports, mailer, cfg, logger, m), tp)
+
+	// Configure middleware with the custom claims type, the tokens of the banned users stop working.
+	jwtCfg := requestauth.JWTConfig(keys, as)
 	// Initialise Routes.
-	authroute.NewRouter(as).AppendRoutes(e)
-	movieroute.NewRouter(ms, jwtCfg).AppendRoutes(e)
+	authroute.NewRouter(as, jwtCfg, logger).WithLimiter(limiter).AppendRoutes(e)
+	movieroute.NewRouter(ms, jwtCfg, logger).WithLimiter(limiter).WithCacheMaxAge(cfg.App.CacheMaxAge).AppendRoutes(e)
+	graphqlroute.NewRouter(ms, as, jwtCfg, logger).WithLimiter(limiter).AppendRoutes(e)
+	adminroute.NewRouter(ads, as, jwtCfg, logger).AppendRoutes(e)
+	openapi.NewRouter(doc).AppendRoutes(e)
+	healthroute.NewRouter(checker).AppendRoutes(e)
+	jwksroute.NewRouter(keys).AppendRoutes(e)
+
+	// The other servers and the long-lived streams are stopped along with the HTTP server.
+	var onShutdown []func(ctx context.Context) error
 
-	// Run the app.
-	return e.Start(":" + cfg.App.Port)
+	// Serve the metrics on the admin port when configured, along with the API otherwise.
+	if cfg.App.MetricsPort != 0 {
+		admin := echo.New()
+		admin.HideBanner = true
+		admin.HidePort = true
+		metricsroute.NewRouter(reg).AppendRoutes(admin)
+		onShutdown = append(onShutdown, admin.Shutdown)
+		go func() {
+			if err := admin.Start(fmt.Sprintf(":%d", cfg.App.MetricsPort)); err != nil && err != http.ErrServerClosed {
+				logger.Error("admin server stopped", zap.Error(err))
+			}
+		}()
+	} else {
+		metricsroute.NewRouter(reg).AppendRoutes(e)
+	}
+
+	// Serve gRPC on its own port when configured.
+	if cfg.App.GRPCPort != 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.App.GRPCPort))
+		if err != nil {
+			return err
+		}
+		gs := grpcapi.NewServer(ms, as, keys, limiter, logger)
+		onShutdown = append(onShutdown, func(ctx context.Context) error {
+			return grpcapi.Shutdown(ctx, gs)
+		})
+		go func() {
+			if err := gs.Serve(lis); err != nil {
+				logger.Error("gRPC server stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	// Run the app until it is asked to stop, the deferred calls close the storage once the requests are drained.
+	err = server.Serve(ctx, e, server.Config{
+		Address:         fmt.Sprintf(":%d", cfg.App.Port),
+		ShutdownTimeout: cfg.App.ShutdownTimeout,
+		OnShutdown:      onShutdown,
+	})
+	logger.Info("server stopped", zap.Error(err))
+
+	return err
+}
+
+// loadConfig returns the app config, refusing to start when it is invalid.
+func loadConfig(e *echo.Echo, configFile string) (*config.Config, error) {
+	if err := godotenv.Load("../../.env"); err != nil {
+		e.Logger.Warnf("no .env file exists: %v", err)
+	}
+	if configFile == "" {
+		configFile = os.Getenv("APP_CONFIG_FILE")
+	}
+
+	return config.New(configFile)
 }
 
-func setUpDB(cfg *config.Config) (read *sql.DB, write *sql.DB) {
-	serviceStoreConfig := sqlrepo.DBConfig{
+func setUpDB(cfg *config.Config, tp trace.TracerProvider) (read *sql.DB, write *sql.DB, err error) {
+	return sqlrepo.NewDB(dbConfig(cfg, tp))
+}
+
+// dbConfig returns the settings of the MySQL or PostgreSQL databases of the storage driver.
+func dbConfig(cfg *config.Config, tp trace.TracerProvider) sqlrepo.DBConfig {
+	if cfg.Storage.Driver == config.DriverPostgres {
+		return sqlrepo.DBConfig{
+			Driver:   sqlrepo.Postgres,
+			Username: cfg.Postgres.Username,
+			Pass:     cfg.Postgres.Password,
+			Reader:   cfg.Postgres.Read,
+			Writer:   cfg.Postgres.Write,
+			Port:     cfg.Postgres.Port,
+			DB:       cfg.Postgres.DB,
+			SSLMode:  cfg.Postgres.SSLMode,
+
+			MaxOpenConns:    cfg.Postgres.MaxOpenConns,
+			MaxIdleConns:    cfg.Postgres.MaxIdleConns,
+			ConnMaxLifetime: cfg.Postgres.ConnMaxLifetime,
+			TracerProvider:  tp,
+		}
+	}
+
+	return sqlrepo.DBConfig{
+		Driver:   sqlrepo.MySQL,
 		Username: cfg.MySQL.Username,
 		Pass:     cfg.MySQL.Password,
 		Reader:   cfg.MySQL.Read,
 		Writer:   cfg.MySQL.Write,
 		Port:     cfg.MySQL.Port,
 		DB:       cfg.MySQL.DB,
+
+		MaxOpenConns:    cfg.MySQL.MaxOpenConns,
+		MaxIdleConns:    cfg.MySQL.MaxIdleConns,
+		ConnMaxLifetime: cfg.MySQL.ConnMaxLifetime,
+		TracerProvider:  tp,
+	}
+}
+
+func setUpRedis(cfg *config.Config) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+}
+
+// rateLimitTimeout bounds the redis calls of the rate limits, the lockouts and the read sessions, the memory stores
+// or the writer take over on timeout.
+const rateLimitTimeout = 100 * time.Millisecond
+
+// setUpLimiter returns the rate limiter, nil when disabled. The buckets are kept in redis when
+// configured, so that the limits hold across replicas, and in memory while redis is down.
+func setUpLimiter(cfg *config.Config, rdb *redis.Client, logger *zap.Logger) (*ratelimit.Limiter, error) {
+	if !cfg.RateLimit.Enabled {
+		return nil, nil
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if rdb != nil {
+		limitRdb := rdb.WithTimeout(rateLimitTimeout)
+		store = ratelimit.NewFallbackStore(ratelimit.NewRedisStore(limitRdb, "movierama:ratelimit:"), store, logger)
+	}
+
+	// The config is validated, the limits parse.
+	limit := func(s string) ratelimit.Limit {
+		l, _ := ratelimit.ParseLimit(s)
+		return l
+	}
+	rl := cfg.RateLimit
+	groups := map[string]ratelimit.Group{
+		ratelimit.GroupLogin:    {PerIP: limit(rl.LoginPerIP)},
+		ratelimit.GroupRegister: {PerIP: limit(rl.RegisterPerIP)},
+		ratelimit.GroupMovies:   {PerIP: limit(rl.MoviesPerIP), PerUser: limit(rl.MoviesPerUser)},
+		ratelimit.GroupVotes:    {PerIP: limit(rl.VotesPerIP), PerUser: limit(rl.VotesPerUser)},
+		ratelimit.GroupEmail:    {PerIP: limit(rl.EmailPerIP)},
+	}
+
+	return ratelimit.NewLimiter(store, groups, rl.AllowList)
+}
+
+// setUpFilter returns the content filter of the submitted movies, the submission rate comes last as it
+// reads the database.
+func setUpFilter(cfg *config.Config, counter contentfilter.MovieCounter) *contentfilter.Pipeline {
+	mod := cfg.Moderation
+	filter := contentfilter.New()
+	if mod.RejectLinks {
+		filter.Use(contentfilter.NewLinks(mod.MaxLinks))
+	}
+	if len(mod.BannedWords) > 0 {
+		filter.Use(contentfilter.NewBannedWords(mod.BannedWords))
+	}
+	if mod.FlagSpam {
+		filter.Use(contentfilter.NewSpam())
+	}
+	if mod.MaxMoviesPerHour > 0 {
+		filter.Use(contentfilter.NewSubmissionRate(counter, mod.MaxMoviesPerHour))
+	}
+
+	return filter
+}
+
+// setUpGuard returns the brute-force guard of the logins, nil when disabled. The failures are kept
+// in redis when configured, so that the lockouts hold across replicas, and in memory while redis is down.
+func setUpGuard(cfg *config.Config, rdb *redis.Client, logger *zap.Logger) *bruteforce.Guard {
+	l := cfg.Lockout
+	if !l.Enabled {
+		return nil
+	}
+
+	var store bruteforce.Store = bruteforce.NewMemoryStore()
+	if rdb != nil {
+		store = bruteforce.NewFallbackStore(bruteforce.NewRedisStore(rdb.WithTimeout(rateLimitTimeout), "movierama:lockout:"), store, logger)
+	}
+
+	return bruteforce.NewGuard(store,
+		bruteforce.Policy{
+			FreeAttempts: l.UsernameFreeAttempts, BaseDelay: l.BaseDelay, MaxDelay: l.MaxDelay,
+			MaxAttempts: l.UsernameMaxAttempts, LockoutDuration: l.Duration,
+		},
+		bruteforce.Policy{
+			FreeAttempts: l.IPFreeAttempts, BaseDelay: l.BaseDelay, MaxDelay: l.MaxDelay,
+			MaxAttempts: l.IPMaxAttempts, LockoutDuration: l.Duration,
+		})
+}
+
+// setUpOIDC returns the login flow of the external identity providers whose client is configured. The
+// pending logins are kept in redis when configured, so that the callback may reach another replica.
+func setUpOIDC(cfg *config.Config, rdb *redis.Client) *oidc.Flow {
+	o := cfg.OIDC
+	var providers []oidc.Provider
+	if o.GitHubClientID != "" {
+		providers = append(providers, oidc.NewGitHub(o.GitHubClientID, o.GitHubClientSecret, o.RedirectURL))
+	}
+	if o.GoogleClientID != "" {
+		providers = append(providers, oidc.NewGoogle(o.GoogleClientID, o.GoogleClientSecret, o.RedirectURL))
+	}
+	if o.ClientID != "" {
+		providers = append(providers, oidc.New(oidc.Config{
+			Name:         o.ProviderName,
+			ClientID:     o.ClientID,
+			ClientSecret: o.ClientSecret,
+			RedirectURL:  o.RedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+			Issuer:       o.Issuer,
+		}))
+	}
+
+	var states oidc.StateStore = oidc.NewMemoryStore()
+	if rdb != nil {
+		states = oidc.NewRedisStore(rdb, "movierama:oidc:")
+	}
+
+	return oidc.NewFlow(states, o.StateTTL, providers...)
+}
+
+// setUpMailer returns the mailer of the email verifications and password resets, it sends them with the SMTP
+// server when configured and only logs them otherwise, for development.
+func setUpMailer(cfg *config.Config, logger *zap.Logger) (mail.Mailer, error) {
+	e := cfg.Email
+	if e.SMTPHost == "" {
+		logger.Warn("emails are logged, not sent, set EMAIL_SMTP_HOST to send them")
+		return mail.NewLog(logger), nil
 	}
 
-	read, write = sqlrepo.NewDB(serviceStoreConfig)
+	return mail.NewSMTP(e.SMTPHost, e.SMTPPort, e.SMTPUsername, e.SMTPPassword, e.From)
+}
+
+// setUpKeys returns the keys signing and verifying the access tokens, those of the key files when configured
+// and the JWT secret otherwise. A rotation is scheduled in the key files, the app must be restarted to read
+// a new file.
+func setUpKeys(cfg *config.Config, logger *zap.Logger) (*jwtkeys.Set, error) {
+	if len(cfg.App.JWTKeyFiles) == 0 {
+		return jwtkeys.NewHMAC(cfg.App.JWTSecret), nil
+	}
+	keys, err := jwtkeys.Load(cfg.App.JWTKeyFiles...)
+	if err != nil {
+		return nil, err
+	}
+	signing := keys.SigningKey(time.Now())
+	if signing == nil {
+		return nil, jwtkeys.ErrNoSigningKey
+	}
+	if cfg.App.JWTVerifySecret {
+		keys = keys.AcceptHMAC(cfg.App.JWTSecret)
+	}
+	logger.Info("jwt keys loaded", zap.Int("keys", len(keys.Keys())), zap.String("signing_kid", signing.ID))
 
-	return read, write
+	return keys, nil
 }
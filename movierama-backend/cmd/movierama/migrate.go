@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"movierama/internal/config"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/logging"
+	"movierama/migration"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// runMigrate runs the migrate subcommand: up, down [N], status, version or force VERSION.
+func runMigrate(ctx context.Context, e *echo.Echo, configFile string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [N]|status|version|force VERSION")
+	}
+	cfg, err := loadConfig(e, configFile)
+	if err != nil {
+		return err
+	}
+	logger, err := logging.New(cfg.App.LogLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	m, db, err := newMigrator(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch cmd := args[0]; cmd {
+	case "up":
+		applied, err := m.Up(ctx)
+		fmt.Printf("%d migrations applied\n", len(applied))
+
+		return err
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("migrate down: %q is not a positive number", args[1])
+			}
+		}
+		reverted, err := m.Down(ctx, n)
+		fmt.Printf("%d migrations reverted\n", len(reverted))
+
+		return err
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%d %-30s %s\n", s.Version, s.Name, state)
+		}
+
+		return nil
+	case "version":
+		version, dirty, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+			return nil
+		}
+		fmt.Println(version)
+
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return errors.New("usage: migrate force VERSION")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate force: %q is not a version", args[1])
+		}
+
+		return m.Force(ctx, version)
+	default:
+		return fmt.Errorf("migrate: unknown command %q", cmd)
+	}
+}
+
+// migrateUp applies the pending migrations.
+func migrateUp(ctx context.Context, cfg *config.Config, logger *zap.Logger) error {
+	m, db, err := newMigrator(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := m.Up(ctx)
+	logger.Info("migrations applied", zap.Int("count", len(applied)))
+
+	return err
+}
+
+// newMigrator returns a migrator of the writer database, on a handle allowing the multi statements migrations.
+func newMigrator(cfg *config.Config, logger *zap.Logger) (*migration.Migrator, *sql.DB, error) {
+	var (
+		db  *sql.DB
+		err error
+	)
+	switch cfg.Storage.Driver {
+	case config.DriverMemory:
+		return nil, nil, errors.New("migrate: the memory storage has no migrations")
+	case config.DriverSQLite:
+		db, err = sqlrepo.NewSQLite(cfg.Storage.SQLitePath, nil)
+	case config.DriverPostgres:
+		c := dbConfig(cfg, nil)
+		db, err = sql.Open(c.DriverName(), c.DSN(c.Writer))
+	default:
+		c := dbConfig(cfg, nil)
+		db, err = sql.Open(c.DriverName(), c.DSN(c.Writer)+"&multiStatements=true")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return migration.NewMigrator(db, dialect(cfg), logger), db, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"movierama/internal/app/admin"
+	"movierama/internal/app/auth"
+	movieapp "movierama/internal/app/movie"
+	"movierama/internal/config"
+	"movierama/internal/health"
+	"movierama/internal/infra/repository/memory"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/infra/repository/sql/moderation"
+	"movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/metrics"
+	"movierama/migration"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// storage holds the repositories of the configured driver.
+type storage struct {
+	movies movieapp.Repository
+	// flags queues the movies flagged by the content filter for moderation.
+	flags movieapp.ModerationRepository
+	users auth.Repository
+	// The admin repositories serve the moderation of the users and movies.
+	adminUsers  admin.UserRepository
+	adminMovies admin.MovieRepository
+	moderation  admin.ActionRepository
+	reports     admin.ReportRepository
+	// close releases the databases.
+	close func()
+}
+
+// setUpStorage returns the repositories of the storage driver, registering the metrics and the
+// readiness checks of its databases. The reader is monitored until ctx is done, the sessions of the
+// users who wrote recently are kept in rdb when set.
+func setUpStorage(ctx context.Context, cfg *config.Config, tp trace.TracerProvider, reg prometheus.Registerer,
+	rdb *redis.Client, checker *health.Checker, logger *zap.Logger) (*storage, error) {
+	var reader, writer *sql.DB
+	switch cfg.Storage.Driver {
+	case config.DriverMemory:
+		r := memory.NewRepository()
+		return &storage{movies: r, flags: r, users: r, adminUsers: r, adminMovies: r, moderation: r, reports: r, close: func() {}}, nil
+	case config.DriverSQLite:
+		db, err := sqlrepo.NewSQLite(cfg.Storage.SQLitePath, tp)
+		if err != nil {
+			return nil, err
+		}
+		reader, writer = db, db
+	default:
+		var err error
+		if reader, writer, err = setUpDB(cfg, tp); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &storage{close: func() {
+		reader.Close()
+		writer.Close()
+	}}
+	err := metrics.RegisterDB(reg, "reader", reader)
+	if err == nil {
+		err = metrics.RegisterDB(reg, "writer", writer)
+	}
+	newMovies, newUsers, newModeration := movie.NewRepository, user.NewRepository, moderation.NewRepository
+	if cfg.Storage.Driver == config.DriverPostgres {
+		newMovies, newUsers, newModeration = movie.NewPostgresRepository, user.NewPostgresRepository, moderation.NewPostgresRepository
+	}
+	var (
+		mr  *movie.Repository
+		ur  *user.Repository
+		mdr *moderation.Repository
+	)
+	if err == nil {
+		mr, err = newMovies(reader, writer, logger)
+	}
+	if err == nil {
+		ur, err = newUsers(reader, writer, logger)
+	}
+	if err == nil {
+		mdr, err = newModeration(reader, writer, logger)
+	}
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	// The repositories share the sessions of the users who wrote recently, and the reads fall back to
+	// the writer while the reader is down, which is why the reader only degrades the readiness. The
+	// replicas share the sessions through redis, they need sticky sessions without it.
+	router := sqlrepo.NewRouter(reader, writer, cfg.Storage.ReadYourWritesWindow)
+	if rdb != nil {
+		sessions := sqlrepo.NewRedisSessions(rdb.WithTimeout(rateLimitTimeout), "movierama:session:")
+		router.WithSessions(sessions, logger)
+	}
+	go router.Monitor(ctx, cfg.Storage.ReaderCheckInterval, logger)
+	s.movies, s.users = mr.WithRouter(router), ur.WithRouter(router)
+	s.adminUsers, s.adminMovies, s.moderation = ur, mr, mdr.WithRouter(router)
+	s.reports, s.flags = mdr, mdr
+
+	if reader != writer {
+		checker.AddOptional("reader", "writer", reader.PingContext)
+	}
+	checker.Add("writer", writer.PingContext)
+	migrator := migration.NewMigrator(writer, dialect(cfg), logger)
+	checker.Add("migrations", func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err == nil && pending > 0 {
+			err = fmt.Errorf("%d pending migrations", pending)
+		}
+
+		return err
+	})
+
+	return s, nil
+}
+
+// dialect returns the migration dialect of the storage driver.
+func dialect(cfg *config.Config) migration.Dialect {
+	switch cfg.Storage.Driver {
+	case config.DriverSQLite:
+		return migration.SQLite
+	case config.DriverPostgres:
+		return migration.Postgres
+	default:
+		return migration.MySQL
+	}
+}
@@ -0,0 +1,175 @@
+// Package admin implements the moderation of the accounts and movies by the moderators and admins,
+// and the reports of the movies by the users.
+package admin
+
+import (
+	"movierama/internal/app/auth"
+	"time"
+)
+
+// Moderation actions.
+const (
+	ActionSuspend     = "suspend"
+	ActionBan         = "ban"
+	ActionReinstate   = "reinstate"
+	ActionSetRole     = "set_role"
+	ActionRemoveMovie = "remove_movie"
+	ActionHideMovie   = "hide_movie"
+	ActionDismiss     = "dismiss_reports"
+	ActionWarn        = "warn"
+	// ActionAutoHide hides a movie once it reaches the report threshold, its moderator id is zero.
+	ActionAutoHide = "auto_hide"
+	// ActionFlagMovie queues a submitted movie flagged by the content filter, its moderator id is zero.
+	ActionFlagMovie = "flag_movie"
+	// ActionMergeMovie folds a duplicate movie into another movie, its detail is the id of the other movie.
+	ActionMergeMovie = "merge_movie"
+)
+
+// Report categories.
+const (
+	CategorySpam      = "spam"
+	CategoryOffensive = "offensive"
+	CategoryIllegal   = "illegal"
+	CategoryOther     = "other"
+)
+
+var categories = map[string]bool{
+	CategorySpam:      true,
+	CategoryOffensive: true,
+	CategoryIllegal:   true,
+	CategoryOther:     true,
+}
+
+// Resolutions of the reports of a movie.
+const (
+	// ResolveDismiss resolves the reports and shows the movie again.
+	ResolveDismiss = "dismiss"
+	// ResolveHide resolves the reports and hides the movie.
+	ResolveHide = "hide"
+	// ResolveDelete deletes the movie along with its votes and reports.
+	ResolveDelete = "delete"
+	// ResolveWarn resolves the reports, records a warning of the submitter and emails it to them.
+	ResolveWarn = "warn"
+)
+
+// Page sizes of the accounts and actions.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// maxReasonLength is the length of the reason column.
+const maxReasonLength = 500
+
+// Actor is the moderator or admin performing an action.
+type Actor struct {
+	ID   int
+	Role auth.Role
+}
+
+// Account contains the admin view of a user.
+type Account struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      auth.Role `json:"role"`
+	Status    string    `json:"status"`
+	// SuspendedUntil is the end of the suspension of a suspended account.
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+// AccountFilter selects accounts, the zero fields match every account.
+type AccountFilter struct {
+	// Query matches the accounts whose username, first or last name contains it, ignoring case.
+	Query  string
+	Role   auth.Role
+	Status string
+	// AfterID selects the accounts following the account of this id, to page through them.
+	AfterID int
+	// Limit defaults to DefaultLimit and is capped at MaxLimit.
+	Limit int
+}
+
+// StatusChange contains the payload struct for a suspension, a ban or a reinstatement.
+type StatusChange struct {
+	Status string `json:"status"`
+	// Until is the end of a suspension, it must be in the future.
+	Until  *time.Time `json:"until,omitempty"`
+	Reason string     `json:"reason"`
+}
+
+// RoleChange contains the payload struct for a role change.
+type RoleChange struct {
+	Role   auth.Role `json:"role"`
+	Reason string    `json:"reason"`
+}
+
+// MovieRemoval contains the payload struct for a movie removal.
+type MovieRemoval struct {
+	Reason string `json:"reason"`
+}
+
+// MovieMerge contains the payload struct for the merge of a duplicate movie into another movie.
+type MovieMerge struct {
+	Into   int    `json:"into"`
+	Reason string `json:"reason"`
+}
+
+// Report contains the payload struct for the report of a movie.
+type Report struct {
+	Category string `json:"category"`
+}
+
+// QueueEntry is a movie with open reports.
+type QueueEntry struct {
+	MovieID int    `json:"movie_id"`
+	Title   string `json:"title"`
+	UserID  int    `json:"user_id"`
+	// HiddenAt is set when the movie is hidden from the listings.
+	HiddenAt *time.Time `json:"hidden_at,omitempty"`
+	Reports  int        `json:"reports"`
+	// Categories counts the open reports by category.
+	Categories map[string]int `json:"categories"`
+}
+
+// QueueFilter pages through the moderation queue.
+type QueueFilter struct {
+	Offset int
+	// Limit defaults to DefaultLimit and is capped at MaxLimit.
+	Limit int
+}
+
+// Resolution contains the payload struct for the resolution of the reports of a movie.
+type Resolution struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// Action is an entry of the moderation log.
+type Action struct {
+	ID          int    `json:"id"`
+	ModeratorID int    `json:"moderator_id"`
+	Action      string `json:"action"`
+	UserID      int    `json:"user_id,omitempty"`
+	MovieID     int    `json:"movie_id,omitempty"`
+	// MovieTitle keeps the title of a removed movie.
+	MovieTitle string `json:"movie_title,omitempty"`
+	// Detail is the new role of a role change, the end of a suspension, the number of resolved reports, the
+	// content filter rule which flagged a movie or the movie a duplicate was merged into.
+	Detail    string    `json:"detail,omitempty"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ActionFilter selects moderation actions, the zero fields match every action.
+type ActionFilter struct {
+	ModeratorID int
+	UserID      int
+	MovieID     int
+	Action      string
+	// BeforeID selects the actions older than the action of this id, to page through them.
+	BeforeID int
+	// Limit defaults to DefaultLimit and is capped at MaxLimit.
+	Limit int
+}
@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"context"
+	"movierama/internal/infra/repository/sql/moderation"
+	moviesql "movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"time"
+)
+
+// UserRepository should be able to manage the accounts.
+type UserRepository interface {
+	SearchAccounts(ctx context.Context, filter user.AccountFilter) ([]user.Account, error)
+	GetUserAuthDetailsByID(ctx context.Context, userID int) (*user.AuthUserDetails, error)
+	UpdateRole(ctx context.Context, userID int, role string) error
+	UpdateStatus(ctx context.Context, userID int, status string, until *time.Time) error
+}
+
+// MovieRepository should be able to hide, remove and merge the movies.
+type MovieRepository interface {
+	GetAnyMovie(ctx context.Context, movieID int) (*moviesql.Movie, error)
+	SetHidden(ctx context.Context, movieID int, hidden bool) error
+	DeleteMovie(ctx context.Context, movieID int) error
+	MergeMovies(ctx context.Context, movieID, intoID int) (int, error)
+}
+
+// ActionRepository should be able to manage the moderation log.
+type ActionRepository interface {
+	AddAction(ctx context.Context, action *moderation.Action) error
+	GetActions(ctx context.Context, filter moderation.ActionFilter) ([]moderation.Action, error)
+}
+
+// ReportRepository should be able to manage the reports of the movies.
+type ReportRepository interface {
+	AddReport(ctx context.Context, report *moderation.Report) error
+	CountReports(ctx context.Context, movieID int, source string) (int, error)
+	GetQueue(ctx context.Context, filter moderation.QueueFilter) ([]moderation.QueueEntry, error)
+	ResolveReports(ctx context.Context, movieID int) (int, error)
+}
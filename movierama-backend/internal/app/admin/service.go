@@ -0,0 +1,519 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"movierama/internal/app/auth"
+	"movierama/internal/config"
+	"movierama/internal/infra/repository/sql/moderation"
+	moviesql "movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/logging"
+	"movierama/internal/mail"
+	"movierama/internal/metrics"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// Admin errors.
+var (
+	ErrForbidden     = errors.New("action not allowed for the role")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrMovieNotFound = errors.New("movie not found")
+	ErrOwnAccount    = errors.New("moderators cannot act on their own account")
+	ErrInvalidStatus = errors.New("invalid account status")
+	ErrInvalidUntil  = errors.New("a suspension must end in the future")
+	ErrInvalidRole   = errors.New("invalid role")
+	ErrInvalidReason = errors.New("a reason of at most 500 characters is required")
+	// ErrInvalidCategory is returned for a report category other than spam, offensive, illegal or other.
+	ErrInvalidCategory   = errors.New("invalid report category")
+	ErrOwnMovie          = errors.New("users cannot report their own movies")
+	ErrAlreadyReported   = errors.New("movie already reported")
+	ErrInvalidResolution = errors.New("invalid resolution")
+	ErrSameMovie         = errors.New("a movie cannot be merged into itself")
+)
+
+// Service handles the moderation of the accounts and movies.
+type Service interface {
+	ListUsers(ctx context.Context, filter AccountFilter) ([]Account, error)
+	SetStatus(ctx context.Context, actor Actor, userID int, change *StatusChange) error
+	SetRole(ctx context.Context, actor Actor, userID int, change *RoleChange) error
+	RemoveMovie(ctx context.Context, actor Actor, movieID int, removal *MovieRemoval) error
+	MergeMovie(ctx context.Context, actor Actor, movieID int, merge *MovieMerge) error
+	GetActions(ctx context.Context, filter ActionFilter) ([]Action, error)
+	ReportMovie(ctx context.Context, reporterID, movieID int, report *Report) error
+	GetQueue(ctx context.Context, filter QueueFilter) ([]QueueEntry, error)
+	ResolveReports(ctx context.Context, actor Actor, movieID int, resolution *Resolution) error
+}
+
+type adminService struct {
+	ur      UserRepository
+	mr      MovieRepository
+	ar      ActionRepository
+	rr      ReportRepository
+	mailer  mail.Mailer
+	cfg     *config.Config
+	log     *zap.Logger
+	metrics *metrics.Metrics
+}
+
+// NewService constructor, the warnings are emailed to the submitters with mailer when set.
+func NewService(userRepo UserRepository, movieRepo MovieRepository, actionRepo ActionRepository, reportRepo ReportRepository,
+	mailer mail.Mailer, cfg *config.Config, logger *zap.Logger, m *metrics.Metrics) Service {
+	return &adminService{
+		ur:      userRepo,
+		mr:      movieRepo,
+		ar:      actionRepo,
+		rr:      reportRepo,
+		mailer:  mailer,
+		cfg:     cfg,
+		log:     logger,
+		metrics: m,
+	}
+}
+
+// ListUsers returns the accounts selected by filter, ordered by id.
+func (a adminService) ListUsers(ctx context.Context, filter AccountFilter) ([]Account, error) {
+	dbAccounts, err := a.ur.SearchAccounts(ctx, user.AccountFilter{
+		Query:   strings.TrimSpace(filter.Query),
+		Role:    string(filter.Role),
+		Status:  filter.Status,
+		AfterID: filter.AfterID,
+		Limit:   clampLimit(filter.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]Account, 0, len(dbAccounts))
+	for _, acc := range dbAccounts {
+		accounts = append(accounts, Account{
+			ID:             acc.ID,
+			Username:       acc.Username,
+			FirstName:      acc.FirstName,
+			LastName:       acc.LastName,
+			Role:           auth.Role(acc.Role),
+			Status:         acc.Status,
+			SuspendedUntil: acc.SuspendedUntil,
+		})
+	}
+
+	return accounts, nil
+}
+
+// SetStatus suspends, bans or reinstates the account of userID. The actor must outrank the user,
+// and only the admins ban the users or lift their bans.
+func (a adminService) SetStatus(ctx context.Context, actor Actor, userID int, change *StatusChange) error {
+	if err := checkReason(change.Reason); err != nil {
+		return err
+	}
+	target, err := a.target(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+	if !actor.Role.Outranks(auth.Role(target.Role)) {
+		return ErrForbidden
+	}
+
+	var action, detail string
+	var until *time.Time
+	adminOnly := false
+	switch change.Status {
+	case auth.StatusSuspended:
+		if change.Until == nil || !change.Until.After(time.Now()) {
+			return ErrInvalidUntil
+		}
+		u := change.Until.UTC()
+		action, detail, until = ActionSuspend, u.Format(time.RFC3339), &u
+	case auth.StatusBanned:
+		action, adminOnly = ActionBan, true
+	case auth.StatusActive:
+		action, adminOnly = ActionReinstate, target.Status == auth.StatusBanned
+	default:
+		return ErrInvalidStatus
+	}
+	if adminOnly && !actor.Role.AtLeast(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+
+	if err = a.ur.UpdateStatus(ctx, userID, change.Status, until); err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("account status changed", zap.Int("moderator_id", actor.ID),
+		zap.Int("user_id", userID), zap.String("status", change.Status))
+
+	return a.record(ctx, &moderation.Action{
+		ModeratorID: actor.ID,
+		Action:      action,
+		UserID:      &userID,
+		Detail:      detail,
+		Reason:      change.Reason,
+	})
+}
+
+// SetRole changes the role of the account of userID, only the admins change the roles.
+func (a adminService) SetRole(ctx context.Context, actor Actor, userID int, change *RoleChange) error {
+	if !actor.Role.AtLeast(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if !change.Role.Valid() {
+		return ErrInvalidRole
+	}
+	if err := checkReason(change.Reason); err != nil {
+		return err
+	}
+	if _, err := a.target(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	if err := a.ur.UpdateRole(ctx, userID, string(change.Role)); err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("account role changed", zap.Int("moderator_id", actor.ID),
+		zap.Int("user_id", userID), zap.String("role", string(change.Role)))
+
+	return a.record(ctx, &moderation.Action{
+		ModeratorID: actor.ID,
+		Action:      ActionSetRole,
+		UserID:      &userID,
+		Detail:      string(change.Role),
+		Reason:      change.Reason,
+	})
+}
+
+// RemoveMovie deletes a movie along with its votes, the log keeps its title and author.
+func (a adminService) RemoveMovie(ctx context.Context, actor Actor, movieID int, removal *MovieRemoval) error {
+	if !actor.Role.AtLeast(auth.RoleModerator) {
+		return ErrForbidden
+	}
+	if err := checkReason(removal.Reason); err != nil {
+		return err
+	}
+
+	movie, err := a.movie(ctx, movieID)
+	if err != nil {
+		return err
+	}
+	if err = a.mr.DeleteMovie(ctx, movieID); err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("movie removed", zap.Int("moderator_id", actor.ID), zap.Int("movie_id", movieID))
+
+	return a.record(ctx, &moderation.Action{
+		ModeratorID: actor.ID,
+		Action:      ActionRemoveMovie,
+		UserID:      &movie.UserID,
+		MovieID:     &movieID,
+		MovieTitle:  movie.Title,
+		Reason:      removal.Reason,
+	})
+}
+
+// MergeMovie folds the votes of a duplicate movie into another movie and deletes it, without counting twice
+// the users who voted for both. The log keeps the title and author of the duplicate.
+func (a adminService) MergeMovie(ctx context.Context, actor Actor, movieID int, merge *MovieMerge) error {
+	if !actor.Role.AtLeast(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if err := checkReason(merge.Reason); err != nil {
+		return err
+	}
+	if movieID == merge.Into {
+		return ErrSameMovie
+	}
+
+	movie, err := a.movie(ctx, movieID)
+	if err != nil {
+		return err
+	}
+	if _, err = a.movie(ctx, merge.Into); err != nil {
+		return err
+	}
+	moved, err := a.mr.MergeMovies(ctx, movieID, merge.Into)
+	if err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("movies merged", zap.Int("moderator_id", actor.ID), zap.Int("movie_id", movieID),
+		zap.Int("into_id", merge.Into), zap.Int("votes", moved))
+
+	return a.record(ctx, &moderation.Action{
+		ModeratorID: actor.ID,
+		Action:      ActionMergeMovie,
+		UserID:      &movie.UserID,
+		MovieID:     &movieID,
+		MovieTitle:  movie.Title,
+		Detail:      strconv.Itoa(merge.Into),
+		Reason:      merge.Reason,
+	})
+}
+
+// ReportMovie records the report of a visible movie by reporterID, a user reports a movie once.
+// The movie is hidden once the open reports of the users reach Moderation.AutoHideReports.
+func (a adminService) ReportMovie(ctx context.Context, reporterID, movieID int, report *Report) error {
+	if !categories[report.Category] {
+		return ErrInvalidCategory
+	}
+	movie, err := a.movie(ctx, movieID)
+	if err != nil {
+		return err
+	}
+	if movie.HiddenAt != nil {
+		return ErrMovieNotFound
+	}
+	if movie.UserID == reporterID {
+		return ErrOwnMovie
+	}
+
+	err = a.rr.AddReport(ctx, &moderation.Report{MovieID: movieID, ReporterID: reporterID, Category: report.Category})
+	if errors.Is(err, moderation.ErrDuplicateReport) {
+		return ErrAlreadyReported
+	}
+	if err != nil {
+		return err
+	}
+	a.metrics.MovieReported(report.Category)
+	logging.For(ctx, a.log).Info("movie reported", zap.Int("reporter_id", reporterID),
+		zap.Int("movie_id", movieID), zap.String("category", report.Category))
+
+	threshold := a.cfg.Moderation.AutoHideReports
+	if threshold <= 0 {
+		return nil
+	}
+	count, err := a.rr.CountReports(ctx, movieID, moderation.SourceUser)
+	if err != nil || count < threshold {
+		return err
+	}
+	if err = a.mr.SetHidden(ctx, movieID, true); err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Warn("movie hidden after reports", zap.Int("movie_id", movieID), zap.Int("reports", count))
+
+	return a.record(ctx, &moderation.Action{
+		Action:     ActionAutoHide,
+		UserID:     &movie.UserID,
+		MovieID:    &movieID,
+		MovieTitle: movie.Title,
+		Detail:     strconv.Itoa(count),
+		Reason:     "reached the report threshold",
+	})
+}
+
+// GetQueue returns the movies with open reports, the most reported first.
+func (a adminService) GetQueue(ctx context.Context, filter QueueFilter) ([]QueueEntry, error) {
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	dbEntries, err := a.rr.GetQueue(ctx, moderation.QueueFilter{Offset: offset, Limit: clampLimit(filter.Limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]QueueEntry, 0, len(dbEntries))
+	for _, e := range dbEntries {
+		entries = append(entries, QueueEntry{
+			MovieID:    e.MovieID,
+			Title:      e.Title,
+			UserID:     e.UserID,
+			HiddenAt:   e.HiddenAt,
+			Reports:    e.Reports,
+			Categories: e.Categories,
+		})
+	}
+
+	return entries, nil
+}
+
+// ResolveReports dismisses the reports of a movie, hides it, deletes it or warns its submitter.
+// The resolution is logged with the number of reports it resolves.
+func (a adminService) ResolveReports(ctx context.Context, actor Actor, movieID int, resolution *Resolution) error {
+	if !actor.Role.AtLeast(auth.RoleModerator) {
+		return ErrForbidden
+	}
+	if err := checkReason(resolution.Reason); err != nil {
+		return err
+	}
+	movie, err := a.movie(ctx, movieID)
+	if err != nil {
+		return err
+	}
+
+	var action string
+	switch resolution.Action {
+	case ResolveDismiss:
+		action = ActionDismiss
+		if movie.HiddenAt != nil {
+			err = a.mr.SetHidden(ctx, movieID, false)
+		}
+	case ResolveHide:
+		action = ActionHideMovie
+		if movie.HiddenAt == nil {
+			err = a.mr.SetHidden(ctx, movieID, true)
+		}
+	case ResolveDelete:
+		action = ActionRemoveMovie
+	case ResolveWarn:
+		action = ActionWarn
+	default:
+		return ErrInvalidResolution
+	}
+	if err != nil {
+		return err
+	}
+
+	// The deletion drops the reports, they are counted first.
+	var resolved int
+	if resolution.Action == ResolveDelete {
+		if resolved, err = a.rr.CountReports(ctx, movieID, ""); err != nil {
+			return err
+		}
+		err = a.mr.DeleteMovie(ctx, movieID)
+	} else {
+		resolved, err = a.rr.ResolveReports(ctx, movieID)
+	}
+	if err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("movie reports resolved", zap.Int("moderator_id", actor.ID),
+		zap.Int("movie_id", movieID), zap.String("action", resolution.Action), zap.Int("reports", resolved))
+
+	err = a.record(ctx, &moderation.Action{
+		ModeratorID: actor.ID,
+		Action:      action,
+		UserID:      &movie.UserID,
+		MovieID:     &movieID,
+		MovieTitle:  movie.Title,
+		Detail:      strconv.Itoa(resolved),
+		Reason:      resolution.Reason,
+	})
+	if err == nil && resolution.Action == ResolveWarn {
+		a.warn(ctx, movie, resolution.Reason)
+	}
+
+	return err
+}
+
+// warn emails the warning of a reported movie to its submitter. A failure is logged rather than returned, the
+// warning is recorded and the submitters without a verified email are not notified.
+func (a adminService) warn(ctx context.Context, movie *moviesql.Movie, reason string) {
+	if a.mailer == nil {
+		return
+	}
+	log := logging.For(ctx, a.log).With(zap.Int("user_id", movie.UserID), zap.Int("movie_id", movie.ID))
+	submitter, err := a.ur.GetUserAuthDetailsByID(ctx, movie.UserID)
+	if err != nil {
+		log.Error("get the submitter of a warning", zap.Error(err))
+		return
+	}
+	if submitter.Email == nil || submitter.EmailVerifiedAt == nil {
+		log.Info("warning not sent", zap.String("reason", "no verified email"))
+		return
+	}
+	err = a.mailer.Send(ctx, mail.Message{
+		To:      *submitter.Email,
+		Subject: "Your MovieRama movie was reported",
+		Body: fmt.Sprintf("Hi %s,\n\nYour movie %q was reported and reviewed by a moderator, who warns you:\n%s\n\n"+
+			"Further reported movies may get your account suspended.\n", submitter.Username, movie.Title, reason),
+	})
+	if err != nil {
+		log.Error("send warning", zap.Error(err))
+		return
+	}
+	log.Info("warning sent")
+}
+
+// GetActions returns the moderation actions selected by filter, newest first.
+func (a adminService) GetActions(ctx context.Context, filter ActionFilter) ([]Action, error) {
+	dbActions, err := a.ar.GetActions(ctx, moderation.ActionFilter{
+		ModeratorID: filter.ModeratorID,
+		UserID:      filter.UserID,
+		MovieID:     filter.MovieID,
+		Action:      filter.Action,
+		BeforeID:    filter.BeforeID,
+		Limit:       clampLimit(filter.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]Action, 0, len(dbActions))
+	for _, act := range dbActions {
+		action := Action{
+			ID:          act.ID,
+			ModeratorID: act.ModeratorID,
+			Action:      act.Action,
+			MovieTitle:  act.MovieTitle,
+			Detail:      act.Detail,
+			Reason:      act.Reason,
+			CreatedAt:   act.CreatedAt,
+		}
+		if act.UserID != nil {
+			action.UserID = *act.UserID
+		}
+		if act.MovieID != nil {
+			action.MovieID = *act.MovieID
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+// target returns the account of userID that actor acts on.
+func (a adminService) target(ctx context.Context, actor Actor, userID int) (*user.AuthUserDetails, error) {
+	if actor.ID == userID {
+		return nil, ErrOwnAccount
+	}
+	target, err := a.ur.GetUserAuthDetailsByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+
+	return target, err
+}
+
+// movie returns the movie of movieID, hidden or not.
+func (a adminService) movie(ctx context.Context, movieID int) (*moviesql.Movie, error) {
+	movie, err := a.mr.GetAnyMovie(ctx, movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMovieNotFound
+	}
+
+	return movie, err
+}
+
+// record logs a moderation action once it is applied. The change is not rolled back
+// on failure, the error tells the moderator that the log misses it.
+func (a adminService) record(ctx context.Context, action *moderation.Action) error {
+	if err := a.ar.AddAction(ctx, action); err != nil {
+		return err
+	}
+	a.metrics.ModerationAction(action.Action)
+
+	return nil
+}
+
+func checkReason(reason string) error {
+	if strings.TrimSpace(reason) == "" || utf8.RuneCountInString(reason) > maxReasonLength {
+		return ErrInvalidReason
+	}
+
+	return nil
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+
+	return limit
+}
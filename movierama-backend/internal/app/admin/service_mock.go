@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+// SvcMock describes a mock struct.
+type SvcMock struct {
+	mock.Mock
+}
+
+// ListUsers mock.
+func (sm *SvcMock) ListUsers(ctx context.Context, filter AccountFilter) ([]Account, error) {
+	args := sm.MethodCalled("ListUsers", ctx, filter)
+
+	return args.Get(0).([]Account), args.Error(1)
+}
+
+// SetStatus mock.
+func (sm *SvcMock) SetStatus(ctx context.Context, actor Actor, userID int, change *StatusChange) error {
+	args := sm.MethodCalled("SetStatus", ctx, actor, userID, change)
+
+	return args.Error(0)
+}
+
+// SetRole mock.
+func (sm *SvcMock) SetRole(ctx context.Context, actor Actor, userID int, change *RoleChange) error {
+	args := sm.MethodCalled("SetRole", ctx, actor, userID, change)
+
+	return args.Error(0)
+}
+
+// RemoveMovie mock.
+func (sm *SvcMock) RemoveMovie(ctx context.Context, actor Actor, movieID int, removal *MovieRemoval) error {
+	args := sm.MethodCalled("RemoveMovie", ctx, actor, movieID, removal)
+
+	return args.Error(0)
+}
+
+// MergeMovie mock.
+func (sm *SvcMock) MergeMovie(ctx context.Context, actor Actor, movieID int, merge *MovieMerge) error {
+	args := sm.MethodCalled("MergeMovie", ctx, actor, movieID, merge)
+
+	return args.Error(0)
+}
+
+// GetActions mock.
+func (sm *SvcMock) GetActions(ctx context.Context, filter ActionFilter) ([]Action, error) {
+	args := sm.MethodCalled("GetActions", ctx, filter)
+
+	return args.Get(0).([]Action), args.Error(1)
+}
+
+// ReportMovie mock.
+func (sm *SvcMock) ReportMovie(ctx context.Context, reporterID, movieID int, report *Report) error {
+	args := sm.MethodCalled("ReportMovie", ctx, reporterID, movieID, report)
+
+	return args.Error(0)
+}
+
+// GetQueue mock.
+func (sm *SvcMock) GetQueue(ctx context.Context, filter QueueFilter) ([]QueueEntry, error) {
+	args := sm.MethodCalled("GetQueue", ctx, filter)
+
+	return args.Get(0).([]QueueEntry), args.Error(1)
+}
+
+// ResolveReports mock.
+func (sm *SvcMock) ResolveReports(ctx context.Context, actor Actor, movieID int, resolution *Resolution) error {
+	args := sm.MethodCalled("ResolveReports", ctx, actor, movieID, resolution)
+
+	return args.Error(0)
+}
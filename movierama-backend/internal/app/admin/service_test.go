@@ -0,0 +1,639 @@
+package admin_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"movierama/internal/app/admin"
+	"movierama/internal/app/auth"
+	"movierama/internal/config"
+	"movierama/internal/infra/repository/sql/moderation"
+	sqlmovie "movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/mail"
+	"movierama/internal/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+var (
+	moderator = admin.Actor{ID: 1, Role: auth.RoleModerator}
+	adminUser = admin.Actor{ID: 1, Role: auth.RoleAdmin}
+)
+
+// loggedAction matches the recorded moderation action.
+func loggedAction(exp moderation.Action) interface{} {
+	return mock.MatchedBy(func(a *moderation.Action) bool {
+		return a.ModeratorID == exp.ModeratorID && a.Action == exp.Action && a.Detail == exp.Detail &&
+			a.Reason == exp.Reason && a.MovieTitle == exp.MovieTitle &&
+			equalID(a.UserID, exp.UserID) && equalID(a.MovieID, exp.MovieID)
+	})
+}
+
+func equalID(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func Test_ListUsers(t *testing.T) {
+	until := time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		filter    admin.AccountFilter
+		expFilter user.AccountFilter
+	}{
+		"should default the limit": {
+			filter:    admin.AccountFilter{Query: " alice ", Role: auth.RoleModerator, Status: "active", AfterID: 3},
+			expFilter: user.AccountFilter{Query: "alice", Role: "moderator", Status: "active", AfterID: 3, Limit: 50},
+		},
+		"should cap the limit": {
+			filter:    admin.AccountFilter{Limit: 1000},
+			expFilter: user.AccountFilter{Limit: 200},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			users := &user.Mock{}
+			users.On("SearchAccounts", context.TODO(), tt.expFilter).Return([]user.Account{
+				{ID: 4, Username: "alice", FirstName: "Alice", LastName: "A", Role: "user", Status: "suspended", SuspendedUntil: &until},
+			}, nil)
+			svc := admin.NewService(users, &sqlmovie.Mock{}, &moderation.Mock{}, &moderation.Mock{}, nil, config.Default(), zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			res, err := svc.ListUsers(context.TODO(), tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, []admin.Account{
+				{ID: 4, Username: "alice", FirstName: "Alice", LastName: "A", Role: auth.RoleUser, Status: "suspended", SuspendedUntil: &until},
+			}, res)
+			users.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_SetStatus(t *testing.T) {
+	until := time.Now().Add(24 * time.Hour).UTC()
+	past := time.Now().Add(-time.Hour)
+	tests := map[string]struct {
+		actor     admin.Actor
+		userID    int
+		change    *admin.StatusChange
+		target    *user.AuthUserDetails
+		targetErr error
+		expUntil  *time.Time
+		expAction *moderation.Action
+		expErr    error
+	}{
+		"should suspend a user": {
+			actor:     moderator,
+			userID:    2,
+			change:    &admin.StatusChange{Status: "suspended", Until: &until, Reason: "rude"},
+			target:    &user.AuthUserDetails{ID: 2, Role: "user", Status: "active"},
+			expUntil:  &until,
+			expAction: &moderation.Action{ModeratorID: 1, Action: "suspend", UserID: intPtr(2), Detail: until.Format(time.RFC3339), Reason: "rude"},
+		},
+		"should ban a user as admin": {
+			actor:     adminUser,
+			userID:    2,
+			change:    &admin.StatusChange{Status: "banned", Reason: "spam"},
+			target:    &user.AuthUserDetails{ID: 2, Role: "moderator", Status: "active"},
+			expAction: &moderation.Action{ModeratorID: 1, Action: "ban", UserID: intPtr(2), Reason: "spam"},
+		},
+		"should reinstate a suspended user": {
+			actor:     moderator,
+			userID:    2,
+			change:    &admin.StatusChange{Status: "active", Reason: "appeal"},
+			target:    &user.AuthUserDetails{ID: 2, Role: "user", Status: "suspended", SuspendedUntil: &until},
+			expAction: &moderation.Action{ModeratorID: 1, Action: "reinstate", UserID: intPtr(2), Reason: "appeal"},
+		},
+		"should not let a moderator ban": {
+			actor:  moderator,
+			userID: 2,
+			change: &admin.StatusChange{Status: "banned", Reason: "spam"},
+			target: &user.AuthUserDetails{ID: 2, Role: "user", Status: "active"},
+			expErr: admin.ErrForbidden,
+		},
+		"should not let a moderator lift a ban": {
+			actor:  moderator,
+			userID: 2,
+			change: &admin.StatusChange{Status: "active", Reason: "appeal"},
+			target: &user.AuthUserDetails{ID: 2, Role: "user", Status: "banned"},
+			expErr: admin.ErrForbidden,
+		},
+		"should not act on a peer": {
+			actor:  moderator,
+			userID: 2,
+			change: &admin.StatusChange{Status: "suspended", Until: &until, Reason: "rude"},
+			target: &user.AuthUserDetails{ID: 2, Role: "moderator", Status: "active"},
+			expErr: admin.ErrForbidden,
+		},
+		"should not act on the own account": {
+			actor:  adminUser,
+			userID: 1,
+			change: &admin.StatusChange{Status: "banned", Reason: "spam"},
+			expErr: admin.ErrOwnAccount,
+		},
+		"should require a reason": {
+			actor:  adminUser,
+			userID: 2,
+			change: &admin.StatusChange{Status: "banned", Reason: "  "},
+			expErr: admin.ErrInvalidReason,
+		},
+		"should reject a reason too long": {
+			actor:  adminUser,
+			userID: 2,
+			change: &admin.StatusChange{Status: "banned", Reason: strings.Repeat("é", 501)},
+			expErr: admin.ErrInvalidReason,
+		},
+		"should reject a past suspension end": {
+			actor:  moderator,
+			userID: 2,
+			change: &admin.StatusChange{Status: "suspended", Until: &past, Reason: "rude"},
+			target: &user.AuthUserDetails{ID: 2, Role: "user", Status: "active"},
+			expErr: admin.ErrInvalidUntil,
+		},
+		"should reject an unknown status": {
+			actor:  moderator,
+			userID: 2,
+			change: &admin.StatusChange{Status: "deleted", Reason: "rude"},
+			target: &user.AuthUserDetails{ID: 2, Role: "user", Status: "active"},
+			expErr: admin.ErrInvalidStatus,
+		},
+		"should return not found for a missing user": {
+			actor:     moderator,
+			userID:    2,
+			change:    &admin.StatusChange{Status: "banned", Reason: "spam"},
+			targetErr: sql.ErrNoRows,
+			expErr:    admin.ErrUserNotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			users := &user.Mock{}
+			users.On("GetUserAuthDetailsByID", context.TODO(), tt.userID).Return(tt.target, tt.targetErr).Maybe()
+			actions := &moderation.Mock{}
+			if tt.expAction != nil {
+				users.On("UpdateStatus", context.TODO(), tt.userID, tt.change.Status, tt.expUntil).Return(nil).Once()
+				actions.On("AddAction", context.TODO(), loggedAction(*tt.expAction)).Return(nil).Once()
+			}
+			reg := prometheus.NewRegistry()
+			svc := admin.NewService(users, &sqlmovie.Mock{}, actions, &moderation.Mock{}, nil, config.Default(), zap.NewNop(), metrics.New(reg))
+
+			err := svc.SetStatus(context.TODO(), tt.actor, tt.userID, tt.change)
+			assert.Equal(t, tt.expErr, err)
+			users.AssertExpectations(t)
+			actions.AssertExpectations(t)
+			if tt.expAction != nil {
+				assert.Equal(t, 1, testutil.CollectAndCount(reg, "movierama_moderation_actions_total"))
+			}
+		})
+	}
+}
+
+func Test_SetRole(t *testing.T) {
+	tests := map[string]struct {
+		actor     admin.Actor
+		change    *admin.RoleChange
+		expAction *moderation.Action
+		expErr    error
+	}{
+		"should promote a user": {
+			actor:     adminUser,
+			change:    &admin.RoleChange{Role: auth.RoleModerator, Reason: "trusted"},
+			expAction: &moderation.Action{ModeratorID: 1, Action: "set_role", UserID: intPtr(2), Detail: "moderator", Reason: "trusted"},
+		},
+		"should not let a moderator change roles": {
+			actor:  moderator,
+			change: &admin.RoleChange{Role: auth.RoleModerator, Reason: "trusted"},
+			expErr: admin.ErrForbidden,
+		},
+		"should reject an unknown role": {
+			actor:  adminUser,
+			change: &admin.RoleChange{Role: "owner", Reason: "trusted"},
+			expErr: admin.ErrInvalidRole,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			users := &user.Mock{}
+			actions := &moderation.Mock{}
+			if tt.expAction != nil {
+				users.On("GetUserAuthDetailsByID", context.TODO(), 2).Return(&user.AuthUserDetails{ID: 2, Role: "user", Status: "active"}, nil)
+				users.On("UpdateRole", context.TODO(), 2, string(tt.change.Role)).Return(nil).Once()
+				actions.On("AddAction", context.TODO(), loggedAction(*tt.expAction)).Return(nil).Once()
+			}
+			svc := admin.NewService(users, &sqlmovie.Mock{}, actions, &moderation.Mock{}, nil, config.Default(), zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			err := svc.SetRole(context.TODO(), tt.actor, 2, tt.change)
+			assert.Equal(t, tt.expErr, err)
+			users.AssertExpectations(t)
+			actions.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_RemoveMovie(t *testing.T) {
+	tests := map[string]struct {
+		actor     admin.Actor
+		movie     *sqlmovie.Movie
+		movieErr  error
+		expAction *moderation.Action
+		expErr    error
+	}{
+		"should remove a movie": {
+			actor:     moderator,
+			movie:     &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam"},
+			expAction: &moderation.Action{ModeratorID: 1, Action: "remove_movie", UserID: intPtr(2), MovieID: intPtr(4), MovieTitle: "Spam", Reason: "spam"},
+		},
+		"should return not found for a missing movie": {
+			actor:    moderator,
+			movieErr: sql.ErrNoRows,
+			expErr:   admin.ErrMovieNotFound,
+		},
+		"should not let a user remove movies": {
+			actor:  admin.Actor{ID: 1, Role: auth.RoleUser},
+			expErr: admin.ErrForbidden,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			movies := &sqlmovie.Mock{}
+			movies.On("GetAnyMovie", context.TODO(), 4).Return(tt.movie, tt.movieErr).Maybe()
+			actions := &moderation.Mock{}
+			if tt.expAction != nil {
+				movies.On("DeleteMovie", context.TODO(), 4).Return(nil).Once()
+				actions.On("AddAction", context.TODO(), loggedAction(*tt.expAction)).Return(nil).Once()
+			}
+			svc := admin.NewService(&user.Mock{}, movies, actions, &moderation.Mock{}, nil, config.Default(), zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			err := svc.RemoveMovie(context.TODO(), tt.actor, 4, &admin.MovieRemoval{Reason: "spam"})
+			assert.Equal(t, tt.expErr, err)
+			movies.AssertExpectations(t)
+			actions.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_MergeMovie(t *testing.T) {
+	tests := map[string]struct {
+		actor     admin.Actor
+		into      int
+		reason    string
+		movieErr  error
+		intoErr   error
+		expAction *moderation.Action
+		expErr    error
+	}{
+		"should merge a movie": {
+			actor:  adminUser,
+			into:   7,
+			reason: "duplicate",
+			expAction: &moderation.Action{ModeratorID: 1, Action: "merge_movie", UserID: intPtr(2), MovieID: intPtr(4),
+				MovieTitle: "Matrix, The", Detail: "7", Reason: "duplicate"},
+		},
+		"should return not found for a missing movie": {
+			actor:    adminUser,
+			into:     7,
+			reason:   "duplicate",
+			movieErr: sql.ErrNoRows,
+			expErr:   admin.ErrMovieNotFound,
+		},
+		"should return not found for a missing movie to merge into": {
+			actor:   adminUser,
+			into:    7,
+			reason:  "duplicate",
+			intoErr: sql.ErrNoRows,
+			expErr:  admin.ErrMovieNotFound,
+		},
+		"should not merge a movie into itself": {
+			actor:  adminUser,
+			into:   4,
+			reason: "duplicate",
+			expErr: admin.ErrSameMovie,
+		},
+		"should require a reason": {
+			actor:  adminUser,
+			into:   7,
+			expErr: admin.ErrInvalidReason,
+		},
+		"should not let a moderator merge movies": {
+			actor:  moderator,
+			into:   7,
+			reason: "duplicate",
+			expErr: admin.ErrForbidden,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			movies := &sqlmovie.Mock{}
+			movies.On("GetAnyMovie", context.TODO(), 4).Return(&sqlmovie.Movie{ID: 4, UserID: 2, Title: "Matrix, The"}, tt.movieErr).Maybe()
+			movies.On("GetAnyMovie", context.TODO(), 7).Return(&sqlmovie.Movie{ID: 7, UserID: 3, Title: "The Matrix"}, tt.intoErr).Maybe()
+			actions := &moderation.Mock{}
+			if tt.expAction != nil {
+				movies.On("MergeMovies", context.TODO(), 4, 7).Return(3, nil).Once()
+				actions.On("AddAction", context.TODO(), loggedAction(*tt.expAction)).Return(nil).Once()
+			}
+			svc := admin.NewService(&user.Mock{}, movies, actions, &moderation.Mock{}, nil, config.Default(), zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			err := svc.MergeMovie(context.TODO(), tt.actor, 4, &admin.MovieMerge{Into: tt.into, Reason: tt.reason})
+			assert.Equal(t, tt.expErr, err)
+			movies.AssertExpectations(t)
+			actions.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_GetActions(t *testing.T) {
+	createdAt := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	actions := &moderation.Mock{}
+	actions.On("GetActions", context.TODO(), moderation.ActionFilter{UserID: 2, Limit: 50}).Return([]moderation.Action{
+		{ID: 2, ModeratorID: 1, Action: "remove_movie", UserID: intPtr(2), MovieID: intPtr(4), MovieTitle: "Spam", Reason: "spam", CreatedAt: createdAt},
+		{ID: 1, ModeratorID: 1, Action: "ban", UserID: intPtr(2), Reason: "abuse", CreatedAt: createdAt},
+	}, nil)
+	svc := admin.NewService(&user.Mock{}, &sqlmovie.Mock{}, actions, &moderation.Mock{}, nil, config.Default(), zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+	res, err := svc.GetActions(context.TODO(), admin.ActionFilter{UserID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []admin.Action{
+		{ID: 2, ModeratorID: 1, Action: "remove_movie", UserID: 2, MovieID: 4, MovieTitle: "Spam", Reason: "spam", CreatedAt: createdAt},
+		{ID: 1, ModeratorID: 1, Action: "ban", UserID: 2, Reason: "abuse", CreatedAt: createdAt},
+	}, res)
+}
+
+// reportWith matches the recorded report.
+func reportWith(movieID, reporterID int, category string) interface{} {
+	return mock.MatchedBy(func(r *moderation.Report) bool {
+		return r.MovieID == movieID && r.ReporterID == reporterID && r.Category == category
+	})
+}
+
+func Test_ReportMovie(t *testing.T) {
+	hiddenAt := time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		reporterID int
+		category   string
+		threshold  int
+		movie      *sqlmovie.Movie
+		movieErr   error
+		addErr     error
+		count      int
+		expHide    bool
+		expErr     error
+	}{
+		"should report a movie": {
+			reporterID: 3,
+			category:   "spam",
+			threshold:  5,
+			movie:      &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam"},
+			count:      4,
+		},
+		"should hide the movie at the threshold": {
+			reporterID: 3,
+			category:   "offensive",
+			threshold:  5,
+			movie:      &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam"},
+			count:      5,
+			expHide:    true,
+		},
+		"should not count the reports when the hiding is disabled": {
+			reporterID: 3,
+			category:   "illegal",
+			movie:      &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam"},
+		},
+		"should reject an invalid category": {
+			reporterID: 3,
+			category:   "boring",
+			expErr:     admin.ErrInvalidCategory,
+		},
+		"should return not found for a missing movie": {
+			reporterID: 3,
+			category:   "spam",
+			movieErr:   sql.ErrNoRows,
+			expErr:     admin.ErrMovieNotFound,
+		},
+		"should return not found for a hidden movie": {
+			reporterID: 3,
+			category:   "spam",
+			movie:      &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam", HiddenAt: &hiddenAt},
+			expErr:     admin.ErrMovieNotFound,
+		},
+		"should not let users report their own movies": {
+			reporterID: 2,
+			category:   "spam",
+			movie:      &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam"},
+			expErr:     admin.ErrOwnMovie,
+		},
+		"should reject a second report of the user": {
+			reporterID: 3,
+			category:   "other",
+			threshold:  5,
+			movie:      &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam"},
+			addErr:     moderation.ErrDuplicateReport,
+			expErr:     admin.ErrAlreadyReported,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			movies := &sqlmovie.Mock{}
+			movies.On("GetAnyMovie", context.TODO(), 4).Return(tt.movie, tt.movieErr).Maybe()
+			reports := &moderation.Mock{}
+			reports.On("AddReport", context.TODO(), reportWith(4, tt.reporterID, tt.category)).Return(tt.addErr).Maybe()
+			reports.On("CountReports", context.TODO(), 4, "user").Return(tt.count, nil).Maybe()
+			actions := &moderation.Mock{}
+			if tt.expHide {
+				movies.On("SetHidden", context.TODO(), 4, true).Return(nil).Once()
+				actions.On("AddAction", context.TODO(), loggedAction(moderation.Action{
+					Action: "auto_hide", UserID: intPtr(2), MovieID: intPtr(4), MovieTitle: "Spam", Detail: "5",
+					Reason: "reached the report threshold",
+				})).Return(nil).Once()
+			}
+			cfg := config.Default()
+			cfg.Moderation.AutoHideReports = tt.threshold
+			reg := prometheus.NewRegistry()
+			svc := admin.NewService(&user.Mock{}, movies, actions, reports, nil, cfg, zap.NewNop(), metrics.New(reg))
+
+			err := svc.ReportMovie(context.TODO(), tt.reporterID, 4, &admin.Report{Category: tt.category})
+			assert.Equal(t, tt.expErr, err)
+			if tt.expErr == nil {
+				assert.Equal(t, 1, testutil.CollectAndCount(reg, "movierama_movie_reports_total"))
+			}
+			if tt.threshold == 0 {
+				reports.AssertNotCalled(t, "CountReports", mock.Anything, mock.Anything, mock.Anything)
+			}
+			movies.AssertExpectations(t)
+			actions.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_GetQueue(t *testing.T) {
+	reports := &moderation.Mock{}
+	reports.On("GetQueue", context.TODO(), moderation.QueueFilter{Offset: 0, Limit: 50}).Return([]moderation.QueueEntry{
+		{MovieID: 4, Title: "Spam", UserID: 2, Reports: 3, Categories: map[string]int{"spam": 3}},
+	}, nil)
+	svc := admin.NewService(&user.Mock{}, &sqlmovie.Mock{}, &moderation.Mock{}, reports, nil, config.Default(), zap.NewNop(),
+		metrics.New(prometheus.NewRegistry()))
+
+	res, err := svc.GetQueue(context.TODO(), admin.QueueFilter{Offset: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, []admin.QueueEntry{
+		{MovieID: 4, Title: "Spam", UserID: 2, Reports: 3, Categories: map[string]int{"spam": 3}},
+	}, res)
+}
+
+func Test_ResolveReports(t *testing.T) {
+	hiddenAt := time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC)
+	visible := &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam"}
+	hidden := &sqlmovie.Movie{ID: 4, UserID: 2, Title: "Spam", HiddenAt: &hiddenAt}
+	verifiedAt := hiddenAt
+	tests := map[string]struct {
+		actor     admin.Actor
+		action    string
+		reason    string
+		movie     *sqlmovie.Movie
+		movieErr  error
+		submitter *user.AuthUserDetails
+		expHidden *bool
+		expDelete bool
+		expAction string
+		expMail   string
+		expErr    error
+	}{
+		"should dismiss the reports and show the movie": {
+			actor:     moderator,
+			action:    "dismiss",
+			movie:     hidden,
+			expHidden: boolPtr(false),
+			expAction: "dismiss_reports",
+		},
+		"should dismiss the reports of a visible movie": {
+			actor:     moderator,
+			action:    "dismiss",
+			movie:     visible,
+			expAction: "dismiss_reports",
+		},
+		"should hide the movie": {
+			actor:     moderator,
+			action:    "hide",
+			movie:     visible,
+			expHidden: boolPtr(true),
+			expAction: "hide_movie",
+		},
+		"should delete the movie": {
+			actor:     adminUser,
+			action:    "delete",
+			movie:     hidden,
+			expDelete: true,
+			expAction: "remove_movie",
+		},
+		"should warn the submitter": {
+			actor:     moderator,
+			action:    "warn",
+			movie:     visible,
+			submitter: &user.AuthUserDetails{ID: 2, Username: "bob", Email: strPtr("bob@example.com"), EmailVerifiedAt: &verifiedAt},
+			expAction: "warn",
+			expMail:   "bob@example.com",
+		},
+		"should record the warning of a submitter without a verified email": {
+			actor:     moderator,
+			action:    "warn",
+			movie:     visible,
+			submitter: &user.AuthUserDetails{ID: 2, Username: "bob", Email: strPtr("bob@example.com")},
+			expAction: "warn",
+		},
+		"should reject an unknown resolution": {
+			actor:  moderator,
+			action: "ignore",
+			movie:  visible,
+			expErr: admin.ErrInvalidResolution,
+		},
+		"should require a reason": {
+			actor:  moderator,
+			action: "hide",
+			reason: " ",
+			expErr: admin.ErrInvalidReason,
+		},
+		"should return not found for a missing movie": {
+			actor:    moderator,
+			action:   "hide",
+			movieErr: sql.ErrNoRows,
+			expErr:   admin.ErrMovieNotFound,
+		},
+		"should not let a user resolve reports": {
+			actor:  admin.Actor{ID: 1, Role: auth.RoleUser},
+			action: "dismiss",
+			expErr: admin.ErrForbidden,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			movies := &sqlmovie.Mock{}
+			movies.On("GetAnyMovie", context.TODO(), 4).Return(tt.movie, tt.movieErr).Maybe()
+			reports := &moderation.Mock{}
+			actions := &moderation.Mock{}
+			if tt.expHidden != nil {
+				movies.On("SetHidden", context.TODO(), 4, *tt.expHidden).Return(nil).Once()
+			}
+			if tt.expDelete {
+				reports.On("CountReports", context.TODO(), 4, "").Return(3, nil).Once()
+				movies.On("DeleteMovie", context.TODO(), 4).Return(nil).Once()
+			} else if tt.expAction != "" {
+				reports.On("ResolveReports", context.TODO(), 4).Return(3, nil).Once()
+			}
+			if tt.expAction != "" {
+				actions.On("AddAction", context.TODO(), loggedAction(moderation.Action{
+					ModeratorID: 1, Action: tt.expAction, UserID: intPtr(2), MovieID: intPtr(4), MovieTitle: "Spam", Detail: "3",
+					Reason: "reviewed",
+				})).Return(nil).Once()
+			}
+			users := &user.Mock{}
+			if tt.submitter != nil {
+				users.On("GetUserAuthDetailsByID", context.TODO(), 2).Return(tt.submitter, nil).Once()
+			}
+			reason := tt.reason
+			if reason == "" {
+				reason = "reviewed"
+			}
+			mailer := mail.NewCapture()
+			svc := admin.NewService(users, movies, actions, reports, mailer, config.Default(), zap.NewNop(),
+				metrics.New(prometheus.NewRegistry()))
+
+			err := svc.ResolveReports(context.TODO(), tt.actor, 4, &admin.Resolution{Action: tt.action, Reason: reason})
+			assert.Equal(t, tt.expErr, err)
+			if tt.expMail != "" {
+				msg, ok := mailer.Last(tt.expMail)
+				if assert.True(t, ok, "the warning is emailed") {
+					assert.Contains(t, msg.Body, `"Spam"`)
+					assert.Contains(t, msg.Body, "reviewed")
+				}
+			} else {
+				assert.Empty(t, mailer.Messages())
+			}
+			users.AssertExpectations(t)
+			movies.AssertExpectations(t)
+			reports.AssertExpectations(t)
+			actions.AssertExpectations(t)
+		})
+	}
+}
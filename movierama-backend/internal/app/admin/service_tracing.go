@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"context"
+	"movierama/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "movierama/internal/app/admin"
+
+type tracedService struct {
+	svc    Service
+	tracer trace.Tracer
+}
+
+// NewTracedService wraps svc to record a span per method call.
+func NewTracedService(svc Service, tp trace.TracerProvider) Service {
+	return &tracedService{
+		svc:    svc,
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+func (s *tracedService) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "admin.Service/"+method, trace.WithAttributes(attrs...))
+}
+
+// ListUsers function.
+func (s *tracedService) ListUsers(ctx context.Context, filter AccountFilter) (res []Account, err error) {
+	ctx, span := s.start(ctx, "ListUsers")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ListUsers(ctx, filter)
+}
+
+// SetStatus function.
+func (s *tracedService) SetStatus(ctx context.Context, actor Actor, userID int, change *StatusChange) (err error) {
+	ctx, span := s.start(ctx, "SetStatus", attribute.Int("admin.moderator_id", actor.ID),
+		attribute.Int("admin.user_id", userID), attribute.String("admin.status", change.Status))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.SetStatus(ctx, actor, userID, change)
+}
+
+// SetRole function.
+func (s *tracedService) SetRole(ctx context.Context, actor Actor, userID int, change *RoleChange) (err error) {
+	ctx, span := s.start(ctx, "SetRole", attribute.Int("admin.moderator_id", actor.ID),
+		attribute.Int("admin.user_id", userID), attribute.String("admin.role", string(change.Role)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.SetRole(ctx, actor, userID, change)
+}
+
+// RemoveMovie function.
+func (s *tracedService) RemoveMovie(ctx context.Context, actor Actor, movieID int, removal *MovieRemoval) (err error) {
+	ctx, span := s.start(ctx, "RemoveMovie", attribute.Int("admin.moderator_id", actor.ID), attribute.Int("admin.movie_id", movieID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.RemoveMovie(ctx, actor, movieID, removal)
+}
+
+// MergeMovie function.
+func (s *tracedService) MergeMovie(ctx context.Context, actor Actor, movieID int, merge *MovieMerge) (err error) {
+	ctx, span := s.start(ctx, "MergeMovie", attribute.Int("admin.moderator_id", actor.ID), attribute.Int("admin.movie_id", movieID),
+		attribute.Int("admin.into_id", merge.Into))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.MergeMovie(ctx, actor, movieID, merge)
+}
+
+// GetActions function.
+func (s *tracedService) GetActions(ctx context.Context, filter ActionFilter) (res []Action, err error) {
+	ctx, span := s.start(ctx, "GetActions", attribute.Int("admin.moderator_id", filter.ModeratorID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetActions(ctx, filter)
+}
+
+// ReportMovie function.
+func (s *tracedService) ReportMovie(ctx context.Context, reporterID, movieID int, report *Report) (err error) {
+	ctx, span := s.start(ctx, "ReportMovie", attribute.Int("admin.reporter_id", reporterID),
+		attribute.Int("admin.movie_id", movieID), attribute.String("admin.category", report.Category))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ReportMovie(ctx, reporterID, movieID, report)
+}
+
+// GetQueue function.
+func (s *tracedService) GetQueue(ctx context.Context, filter QueueFilter) (res []QueueEntry, err error) {
+	ctx, span := s.start(ctx, "GetQueue", attribute.Int("admin.offset", filter.Offset))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetQueue(ctx, filter)
+}
+
+// ResolveReports function.
+func (s *tracedService) ResolveReports(ctx context.Context, actor Actor, movieID int, resolution *Resolution) (err error) {
+	ctx, span := s.start(ctx, "ResolveReports", attribute.Int("admin.moderator_id", actor.ID),
+		attribute.Int("admin.movie_id", movieID), attribute.String("admin.resolution", resolution.Action))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ResolveReports(ctx, actor, movieID, resolution)
+}
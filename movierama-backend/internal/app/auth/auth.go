@@ -1,7 +1,151 @@
 package auth
 
+import (
+	"context"
+	"time"
+)
+
 // LoginRes contains the login response.
 type LoginRes struct {
 	Username string `json:"username"`
 	Token    string `json:"token"`
 }
+
+// User contains the public user data.
+type User struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// Auth events.
+const (
+	EventLoginSucceeded = "login_succeeded"
+	EventLoginFailed    = "login_failed"
+	// EventLoginBlocked is a login rejected without checking the password after repeated failures.
+	EventLoginBlocked = "login_blocked"
+	// EventLoginDenied is a login with valid credentials of a suspended or banned account.
+	EventLoginDenied    = "login_denied"
+	EventRegistered     = "registered"
+	EventPasswordChange = "password_changed"
+	// EventIdentityLinked is an identity of an external identity provider linked to an account.
+	EventIdentityLinked = "identity_linked"
+	EventEmailVerified  = "email_verified"
+	// EventPasswordReset is a password replaced with a token sent by email.
+	EventPasswordReset      = "password_reset"
+	EventAccessTokenCreated = "access_token_created"
+	EventAccessTokenRevoked = "access_token_revoked"
+)
+
+// Event is an entry of the auth audit log.
+type Event struct {
+	ID int `json:"id"`
+	// UserID is zero when the username is unknown.
+	UserID    int       `json:"user_id,omitempty"`
+	Username  string    `json:"username"`
+	Event     string    `json:"event"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventFilter selects auth events, the zero fields match every event.
+type EventFilter struct {
+	UserID   int
+	Username string
+	IP       string
+	Event    string
+	// BeforeID selects the events older than the event of this id, to page through them.
+	BeforeID int
+	// Limit defaults to DefaultEventLimit and is capped at MaxEventLimit.
+	Limit int
+}
+
+// Event page sizes.
+const (
+	DefaultEventLimit = 50
+	MaxEventLimit     = 200
+)
+
+// PasswordChange contains the payload struct for a password change.
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// EmailRequest contains the payload struct of the requests naming an email.
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// EmailVerification contains the payload struct for an email verification.
+type EmailVerification struct {
+	Token string `json:"token"`
+}
+
+// PasswordReset contains the payload struct for a password reset.
+type PasswordReset struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// AccessTokenRequest contains the payload struct for a personal access token creation.
+type AccessTokenRequest struct {
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+	// ExpiresAt is nil for a token without expiry.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AccessToken contains a personal access token, without its secret.
+type AccessToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []Scope    `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAccessToken contains a created personal access token with its secret.
+type NewAccessToken struct {
+	AccessToken
+	Token string `json:"token"`
+}
+
+// AuthorizationRes contains the page of the identity provider the user logs in on.
+type AuthorizationRes struct {
+	URL string `json:"authorization_url"`
+	// Binding is the secret the client completes the login with, it is kept out of the body.
+	Binding string `json:"-"`
+}
+
+// OIDCCallback contains the payload struct of the callback of an identity provider.
+type OIDCCallback struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+	// Binding is the secret of the client that started the login.
+	Binding string `json:"-"`
+	// UserID is the user completing the login, zero when anonymous. A link is completed by its user.
+	UserID int `json:"-"`
+}
+
+// Client describes the client of a request, it is recorded in the audit log.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientContextKey struct{}
+
+// WithClient returns a copy of ctx carrying the client of the request.
+func WithClient(ctx context.Context, c Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, c)
+}
+
+// ClientFrom returns the client of the request, zero when unknown.
+func ClientFrom(ctx context.Context) Client {
+	c, _ := ctx.Value(clientContextKey{}).(Client)
+
+	return c
+}
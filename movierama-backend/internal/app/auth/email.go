@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"movierama/internal/emailtoken"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/logging"
+	"movierama/internal/mail"
+	"movierama/internal/metrics"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxEmailLength is the length of the email column.
+const maxEmailLength = 255
+
+// normalizeEmail returns the trimmed and lowercased email, empty when email is blank.
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", nil
+	}
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+
+	return email, nil
+}
+
+// emailError returns ErrEmailNotVerified for the login of a user whose email is not verified, when the
+// verification is required. The accounts without email, created before it was required, are let in.
+func (a authService) emailError(ctx context.Context, dbUser *user.AuthUserDetails) error {
+	if !a.cfg.Email.RequireVerified || dbUser.Email == nil || dbUser.EmailVerifiedAt != nil {
+		return nil
+	}
+	logging.For(ctx, a.log).Warn("login denied", zap.String("reason", "email not verified"), zap.Int("user_id", dbUser.ID))
+	a.metrics.Login(metrics.LoginDenied)
+	a.audit(ctx, EventLoginDenied, dbUser.ID, dbUser.Username)
+
+	return ErrEmailNotVerified
+}
+
+// verifyState is the state binding the verification tokens, verifying the email or changing it spends them.
+func verifyState(dbUser *user.AuthUserDetails) string {
+	if dbUser.Email == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s|%t", *dbUser.Email, dbUser.EmailVerifiedAt != nil)
+}
+
+// resetState is the state binding the reset tokens, changing the password or the email spends them.
+func resetState(dbUser *user.AuthUserDetails) string {
+	email := ""
+	if dbUser.Email != nil {
+		email = *dbUser.Email
+	}
+
+	return dbUser.Password + "|" + email
+}
+
+// verifyToken returns the user of the token, with its details when it was signed.
+func (a authService) verifyToken(ctx context.Context, token, purpose string,
+	state func(*user.AuthUserDetails) string) (*user.AuthUserDetails, error) {
+	var dbUser *user.AuthUserDetails
+	_, err := a.tokens.Verify(token, purpose, func(userID int) (string, error) {
+		var err error
+		if dbUser, err = a.ur.GetUserAuthDetailsByID(ctx, userID); err != nil {
+			return "", err
+		}
+		return state(dbUser), nil
+	})
+	log := logging.For(ctx, a.log).With(zap.String("purpose", purpose))
+	switch {
+	case errors.Is(err, emailtoken.ErrInvalid), errors.Is(err, emailtoken.ErrExpired), errors.Is(err, sql.ErrNoRows):
+		log.Warn("email token rejected", zap.Error(err))
+		return nil, ErrInvalidEmailToken
+	case err != nil:
+		return nil, err
+	}
+
+	return dbUser, nil
+}
+
+// VerifyEmail verifies the email of the token, the token is spent by the verification.
+func (a authService) VerifyEmail(ctx context.Context, verification *EmailVerification) error {
+	dbUser, err := a.verifyToken(ctx, verification.Token, emailtoken.PurposeVerify, verifyState)
+	if err != nil {
+		return err
+	}
+	if dbUser.Email == nil || dbUser.EmailVerifiedAt != nil {
+		return ErrInvalidEmailToken
+	}
+	if err = a.ur.VerifyEmail(ctx, dbUser.ID, *dbUser.Email, time.Now().UTC()); err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("email verified", zap.Int("user_id", dbUser.ID))
+	a.audit(ctx, EventEmailVerified, dbUser.ID, dbUser.Username)
+
+	return nil
+}
+
+// ResendVerification sends the verification of an unverified email again.
+func (a authService) ResendVerification(ctx context.Context, req *EmailRequest) error {
+	dbUser, err := a.userByEmail(ctx, req.Email)
+	if dbUser == nil || err != nil {
+		return err
+	}
+	if dbUser.EmailVerifiedAt != nil {
+		logging.For(ctx, a.log).Info("verification not sent", zap.String("reason", "email verified"),
+			zap.Int("user_id", dbUser.ID))
+		return nil
+	}
+	a.sendVerification(ctx, dbUser.ID, dbUser.Username, *dbUser.Email)
+
+	return nil
+}
+
+// ChangeEmail replaces the email of the user, the new email is not verified until its token is used.
+func (a authService) ChangeEmail(ctx context.Context, userID int, req *EmailRequest) error {
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return err
+	}
+	if email == "" {
+		return ErrEmailRequired
+	}
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if dbUser.Email != nil && *dbUser.Email == email {
+		return nil
+	}
+
+	log := logging.For(ctx, a.log).With(zap.Int("user_id", userID))
+	err = a.ur.UpdateEmail(ctx, userID, email)
+	if errors.Is(err, user.ErrDuplicateEmail) {
+		log.Info("email change rejected", zap.String("reason", "email taken"))
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("email changed")
+	a.sendVerification(ctx, userID, dbUser.Username, email)
+
+	return nil
+}
+
+// ForgotPassword sends a password reset to the email of an account that is not banned.
+func (a authService) ForgotPassword(ctx context.Context, req *EmailRequest) error {
+	dbUser, err := a.userByEmail(ctx, req.Email)
+	if dbUser == nil || err != nil {
+		return err
+	}
+	log := logging.For(ctx, a.log).With(zap.Int("user_id", dbUser.ID))
+	if errors.Is(AccountError(dbUser.Status, dbUser.SuspendedUntil, time.Now()), ErrAccountBanned) {
+		log.Warn("password reset not sent", zap.String("reason", "account banned"))
+		return nil
+	}
+
+	token, err := a.tokens.Sign(emailtoken.PurposeReset, dbUser.ID, resetState(dbUser), a.cfg.Email.ResetTTL)
+	if err != nil {
+		return err
+	}
+	a.send(ctx, mail.Message{
+		To:      *dbUser.Email,
+		Subject: "Reset your MovieRama password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to choose a new password:\n%s\n\n"+
+			"The link expires in %s. If you did not ask for it, ignore this email.\n",
+			dbUser.Username, tokenURL(a.cfg.Email.ResetURL, token), ttlText(a.cfg.Email.ResetTTL)),
+	})
+	log.Info("password reset sent")
+
+	return nil
+}
+
+// ResetPassword replaces the password of the user of the token, it also verifies the email the token
+// was sent to.
+func (a authService) ResetPassword(ctx context.Context, reset *PasswordReset) error {
+	dbUser, err := a.verifyToken(ctx, reset.Token, emailtoken.PurposeReset, resetState)
+	if err != nil {
+		return err
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(reset.NewPassword), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+	if err = a.ur.UpdatePassword(ctx, dbUser.ID, string(passwordHash)); err != nil {
+		return err
+	}
+	log := logging.For(ctx, a.log).With(zap.Int("user_id", dbUser.ID))
+	log.Info("password reset")
+	a.audit(ctx, EventPasswordReset, dbUser.ID, dbUser.Username)
+	if err = a.guard.Reset(ctx, dbUser.Username); err != nil {
+		log.Error("reset failed logins", zap.Error(err))
+	}
+
+	if dbUser.Email != nil && dbUser.EmailVerifiedAt == nil {
+		if err = a.ur.VerifyEmail(ctx, dbUser.ID, *dbUser.Email, time.Now().UTC()); err != nil {
+			return err
+		}
+		log.Info("email verified")
+		a.audit(ctx, EventEmailVerified, dbUser.ID, dbUser.Username)
+	}
+
+	return nil
+}
+
+// userByEmail returns the user of the email, nil when it is unknown.
+func (a authService) userByEmail(ctx context.Context, email string) (*user.AuthUserDetails, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	dbUser, err := a.ur.GetUserAuthDetailsByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		logging.For(ctx, a.log).Info("email not sent", zap.String("reason", "unknown email"))
+		return nil, nil
+	}
+
+	return dbUser, err
+}
+
+// sendVerification sends the verification of the unverified email of the user.
+func (a authService) sendVerification(ctx context.Context, userID int, username, email string) {
+	state := verifyState(&user.AuthUserDetails{Email: &email})
+	token, err := a.tokens.Sign(emailtoken.PurposeVerify, userID, state, a.cfg.Email.VerifyTTL)
+	if err != nil {
+		logging.For(ctx, a.log).Error("sign email token", zap.Error(err))
+		return
+	}
+	a.send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your MovieRama email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to verify your email:\n%s\n\nThe link expires in %s.\n",
+			username, tokenURL(a.cfg.Email.VerifyURL, token), ttlText(a.cfg.Email.VerifyTTL)),
+	})
+}
+
+// send sends msg with the mailer. A failure is logged rather than returned, the user can ask for it again.
+func (a authService) send(ctx context.Context, msg mail.Message) {
+	if a.mailer == nil {
+		return
+	}
+	if err := a.mailer.Send(ctx, msg); err != nil {
+		logging.For(ctx, a.log).Error("send email", zap.String("subject", msg.Subject), zap.Error(err))
+	}
+}
+
+// tokenURL returns the page with the token in its token parameter.
+func tokenURL(page, token string) string {
+	u, err := url.Parse(page)
+	if err != nil {
+		return page + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// ttlText returns d in hours, or in minutes when shorter than two hours.
+func ttlText(d time.Duration) string {
+	if d >= 2*time.Hour {
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+
+	return fmt.Sprintf("%d minutes", int(d.Minutes()))
+}
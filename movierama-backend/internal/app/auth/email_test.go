@@ -0,0 +1,184 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/app/auth"
+	"movierama/internal/config"
+	"movierama/internal/infra/repository/memory"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/mail"
+	"movierama/internal/metrics"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func newEmailService(repo auth.Repository, mailer mail.Mailer, requireVerified bool) auth.Service {
+	cfg := &config.Config{
+		App: config.App{JWTSecret: "secret"},
+		Email: config.Email{
+			VerifyURL:       "https://movierama.dev/verify-email",
+			ResetURL:        "https://movierama.dev/reset-password?lang=en",
+			VerifyTTL:       time.Hour,
+			ResetTTL:        time.Hour,
+			RequireVerified: requireVerified,
+		},
+	}
+
+	return auth.NewService(repo, nil, nil, mailer, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+}
+
+// tokenOf returns the token of the link of the last email sent to the address.
+func tokenOf(t *testing.T, c *mail.Capture, to string) string {
+	msg, ok := c.Last(to)
+	require.True(t, ok, "an email is sent to %s", to)
+	for _, line := range strings.Split(msg.Body, "\n") {
+		if u, err := url.Parse(line); err == nil && u.Query().Get("token") != "" {
+			return u.Query().Get("token")
+		}
+	}
+	require.Fail(t, "the email has no link with a token", msg.Body)
+
+	return ""
+}
+
+func register(t *testing.T, app auth.Service, username, email string) {
+	require.NoError(t, app.Register(clientContext(), &auth.UserRegister{
+		Username: username, Password: "secret_pass", FirstName: username, LastName: "Doe", Email: email,
+	}))
+}
+
+func Test_VerifyEmail(t *testing.T) {
+	repo := memory.NewRepository()
+	capture := mail.NewCapture()
+	app := newEmailService(repo, capture, true)
+
+	err := app.Register(clientContext(), &auth.UserRegister{Username: "alice", Password: "secret_pass"})
+	assert.ErrorIs(t, err, auth.ErrEmailRequired)
+	err = app.Register(clientContext(), &auth.UserRegister{Username: "alice", Password: "secret_pass", Email: "alice"})
+	assert.ErrorIs(t, err, auth.ErrInvalidEmail)
+	register(t, app, "alice", " Alice@Example.com ")
+	err = app.Register(clientContext(), &auth.UserRegister{Username: "alice2", Password: "secret_pass", Email: "alice@example.com"})
+	assert.ErrorIs(t, err, auth.ErrEmailTaken)
+
+	msg, ok := capture.Last("alice@example.com")
+	require.True(t, ok)
+	assert.Equal(t, "Verify your MovieRama email", msg.Subject)
+	assert.Contains(t, msg.Body, "https://movierama.dev/verify-email?token=")
+	token := tokenOf(t, capture, "alice@example.com")
+
+	_, err = app.Login(clientContext(), &auth.UserLogin{Username: "alice", Password: "secret_pass"})
+	assert.ErrorIs(t, err, auth.ErrEmailNotVerified)
+	_, err = app.Login(clientContext(), &auth.UserLogin{Username: "alice", Password: "wrong_pass"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials, "the verification is only told to the owner")
+
+	require.NoError(t, app.VerifyEmail(clientContext(), &auth.EmailVerification{Token: token}))
+	assert.ErrorIs(t, app.VerifyEmail(clientContext(), &auth.EmailVerification{Token: token}), auth.ErrInvalidEmailToken,
+		"a token is used once")
+	_, err = app.Login(clientContext(), &auth.UserLogin{Username: "alice", Password: "secret_pass"})
+	require.NoError(t, err)
+
+	details, err := repo.GetUserAuthDetailsByID(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.NotNil(t, details.EmailVerifiedAt)
+	events, err := repo.GetAuthEvents(context.TODO(), user.AuthEventFilter{UserID: 1, Event: auth.EventEmailVerified, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// A verified email is not sent again, the request tells nothing either way.
+	require.NoError(t, app.ResendVerification(clientContext(), &auth.EmailRequest{Email: "alice@example.com"}))
+	require.NoError(t, app.ResendVerification(clientContext(), &auth.EmailRequest{Email: "carol@example.com"}))
+	assert.Len(t, capture.Messages(), 1)
+}
+
+func Test_ChangeEmail(t *testing.T) {
+	repo := memory.NewRepository()
+	capture := mail.NewCapture()
+	app := newEmailService(repo, capture, false)
+	register(t, app, "alice", "alice@example.com")
+	register(t, app, "bob", "")
+	oldToken := tokenOf(t, capture, "alice@example.com")
+
+	_, err := app.Login(clientContext(), &auth.UserLogin{Username: "alice", Password: "secret_pass"})
+	require.NoError(t, err, "the verification is not required")
+
+	assert.ErrorIs(t, app.ChangeEmail(clientContext(), 2, &auth.EmailRequest{Email: "alice@example.com"}), auth.ErrEmailTaken)
+	assert.ErrorIs(t, app.ChangeEmail(clientContext(), 2, &auth.EmailRequest{Email: ""}), auth.ErrEmailRequired)
+	require.NoError(t, app.ChangeEmail(clientContext(), 1, &auth.EmailRequest{Email: "alice@example.org"}))
+
+	assert.ErrorIs(t, app.VerifyEmail(clientContext(), &auth.EmailVerification{Token: oldToken}), auth.ErrInvalidEmailToken,
+		"the token of the former email is spent")
+	require.NoError(t, app.ResendVerification(clientContext(), &auth.EmailRequest{Email: "alice@example.org"}))
+	require.NoError(t, app.VerifyEmail(clientContext(), &auth.EmailVerification{Token: tokenOf(t, capture, "alice@example.org")}))
+
+	details, err := repo.GetUserAuthDetailsByEmail(context.TODO(), "alice@example.org")
+	require.NoError(t, err)
+	assert.Equal(t, 1, details.ID)
+	assert.NotNil(t, details.EmailVerifiedAt)
+}
+
+func Test_ResetPassword(t *testing.T) {
+	repo := memory.NewRepository()
+	capture := mail.NewCapture()
+	app := newEmailService(repo, capture, true)
+	register(t, app, "alice", "alice@example.com")
+	register(t, app, "bob", "bob@example.com")
+	verifyToken := tokenOf(t, capture, "alice@example.com")
+
+	require.NoError(t, app.ForgotPassword(clientContext(), &auth.EmailRequest{Email: "carol@example.com"}),
+		"an unknown email is not told")
+	require.NoError(t, app.ForgotPassword(clientContext(), &auth.EmailRequest{Email: "ALICE@example.com"}))
+	msg, ok := capture.Last("alice@example.com")
+	require.True(t, ok)
+	assert.Equal(t, "Reset your MovieRama password", msg.Subject)
+	assert.Contains(t, msg.Body, "https://movierama.dev/reset-password?lang=en&token=")
+	assert.Contains(t, msg.Body, "60 minutes")
+	token := tokenOf(t, capture, "alice@example.com")
+
+	assert.ErrorIs(t, app.VerifyEmail(clientContext(), &auth.EmailVerification{Token: token}), auth.ErrInvalidEmailToken,
+		"a reset token does not verify an email")
+	assert.ErrorIs(t, app.ResetPassword(clientContext(), &auth.PasswordReset{Token: verifyToken, NewPassword: "new_pass"}),
+		auth.ErrInvalidEmailToken, "a verification token does not reset a password")
+	assert.ErrorIs(t, app.ResetPassword(clientContext(), &auth.PasswordReset{Token: "forged", NewPassword: "new_pass"}),
+		auth.ErrInvalidEmailToken)
+
+	stolen := &auth.JwtCustomClaims{UserID: 1}
+	require.NoError(t, app.Authenticate(context.TODO(), stolen))
+	require.NoError(t, app.ResetPassword(clientContext(), &auth.PasswordReset{Token: token, NewPassword: "new_pass"}))
+	assert.ErrorIs(t, app.Authenticate(context.TODO(), stolen), auth.ErrTokenRevoked,
+		"the reset revokes the tokens issued before")
+	assert.ErrorIs(t, app.ResetPassword(clientContext(), &auth.PasswordReset{Token: token, NewPassword: "other_pass"}),
+		auth.ErrInvalidEmailToken, "a token is used once")
+
+	_, err := app.Login(clientContext(), &auth.UserLogin{Username: "alice", Password: "secret_pass"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = app.Login(clientContext(), &auth.UserLogin{Username: "alice", Password: "new_pass"})
+	require.NoError(t, err, "the reset verifies the email")
+	events, err := repo.GetAuthEvents(context.TODO(), user.AuthEventFilter{UserID: 1, Event: auth.EventPasswordReset, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// A banned account gets no reset.
+	require.NoError(t, repo.UpdateStatus(context.TODO(), 2, "banned", nil))
+	sent := len(capture.Messages())
+	require.NoError(t, app.ForgotPassword(clientContext(), &auth.EmailRequest{Email: "bob@example.com"}))
+	assert.Len(t, capture.Messages(), sent)
+}
+
+func Test_ResetPassword_Expired(t *testing.T) {
+	repo := memory.NewRepository()
+	capture := mail.NewCapture()
+	cfg := &config.Config{App: config.App{JWTSecret: "secret"}, Email: config.Email{VerifyTTL: time.Hour, ResetTTL: -time.Second}}
+	app := auth.NewService(repo, nil, nil, capture, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+	register(t, app, "alice", "alice@example.com")
+	require.NoError(t, app.ForgotPassword(clientContext(), &auth.EmailRequest{Email: "alice@example.com"}))
+
+	err := app.ResetPassword(clientContext(), &auth.PasswordReset{Token: tokenOf(t, capture, "alice@example.com"), NewPassword: "new_pass"})
+	assert.ErrorIs(t, err, auth.ErrInvalidEmailToken)
+}
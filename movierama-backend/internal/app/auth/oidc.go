@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/logging"
+	"movierama/internal/metrics"
+	"movierama/internal/oidc"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Providers returns the names of the external identity providers.
+func (a authService) Providers(_ context.Context) []string {
+	return a.flow.Providers()
+}
+
+// AuthorizeOIDC starts a login with an external identity provider and returns the page the user logs in on.
+func (a authService) AuthorizeOIDC(ctx context.Context, provider string, userID int) (*AuthorizationRes, error) {
+	u, binding, err := a.flow.Begin(ctx, provider, userID)
+	if errors.Is(err, oidc.ErrUnknownProvider) {
+		return nil, ErrUnknownProvider
+	}
+	if err != nil {
+		logging.For(ctx, a.log).Error("start external login", zap.String("provider", provider), zap.Error(err))
+		return nil, err
+	}
+
+	return &AuthorizationRes{URL: u, Binding: binding}, nil
+}
+
+// LoginOIDC completes a login with an external identity provider, started by the same client. The identity
+// logs in the account it is linked to; it is linked to the account of the user who started the login to link
+// it, and completes it, or to a new account on its first login when the accounts are created automatically.
+func (a authService) LoginOIDC(ctx context.Context, callback *OIDCCallback) (*LoginRes, error) {
+	log := logging.For(ctx, a.log)
+	pending, id, err := a.flow.Complete(ctx, callback.Code, callback.State, callback.Binding)
+	switch {
+	case errors.Is(err, oidc.ErrInvalidState):
+		log.Warn("external login failed", zap.String("reason", "invalid state"))
+		return nil, ErrLoginExpired
+	case errors.Is(err, oidc.ErrInvalidToken):
+		log.Warn("external login failed", zap.String("reason", "identity rejected"), zap.Error(err))
+		a.metrics.Login(metrics.LoginFailed)
+		return nil, ErrIdentityRejected
+	case err != nil:
+		log.Error("complete external login", zap.Error(err))
+		return nil, err
+	}
+	log = log.With(zap.String("provider", id.Provider), zap.String("subject", id.Subject))
+	if pending.UserID != 0 && pending.UserID != callback.UserID {
+		log.Warn("identity link rejected", zap.Int("user_id", pending.UserID), zap.Int("completed_by", callback.UserID))
+		return nil, ErrLoginExpired
+	}
+
+	userID, err := a.ur.GetIdentityUserID(ctx, id.Provider, id.Subject)
+	linked := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	switch {
+	case pending.UserID != 0 && linked && userID != pending.UserID:
+		log.Warn("identity link rejected", zap.Int("user_id", pending.UserID), zap.Int("linked_user_id", userID))
+		return nil, ErrIdentityLinked
+	case pending.UserID != 0 && !linked:
+		if err = a.link(ctx, pending.UserID, id); err != nil {
+			return nil, err
+		}
+		userID = pending.UserID
+	case !linked && !a.cfg.OIDC.AutoCreate:
+		log.Warn("external login failed", zap.String("reason", "identity not linked"))
+		a.metrics.Login(metrics.LoginFailed)
+		return nil, ErrIdentityNotLinked
+	case !linked:
+		if userID, err = a.createLinkedUser(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = AccountError(dbUser.Status, dbUser.SuspendedUntil, time.Now()); err != nil {
+		log.Warn("login denied", zap.String("reason", dbUser.Status), zap.Int("user_id", dbUser.ID))
+		a.metrics.Login(metrics.LoginDenied)
+		a.audit(ctx, EventLoginDenied, dbUser.ID, dbUser.Username)
+		return nil, err
+	}
+	if err = a.emailError(ctx, dbUser); err != nil {
+		return nil, err
+	}
+
+	return a.issue(ctx, dbUser)
+}
+
+// link links the identity to the account of userID.
+func (a authService) link(ctx context.Context, userID int, id *oidc.Identity) error {
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = a.ur.AddIdentity(ctx, &user.Identity{UserID: userID, Provider: id.Provider, Subject: id.Subject, Email: id.Email})
+	if errors.Is(err, user.ErrDuplicateIdentity) {
+		return ErrProviderLinked
+	}
+	if err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("identity linked", zap.Int("user_id", userID), zap.String("provider", id.Provider))
+	a.audit(ctx, EventIdentityLinked, userID, dbUser.Username)
+
+	return nil
+}
+
+// createLinkedUser creates an account without password for the identity and links it, the username is taken
+// from the identity and numbered when it is already taken.
+func (a authService) createLinkedUser(ctx context.Context, id *oidc.Identity) (int, error) {
+	first, last := id.GivenName, id.FamilyName
+	if first == "" && last == "" {
+		first, last, _ = strings.Cut(strings.TrimSpace(id.Name), " ")
+	}
+	u := &user.SQLUser{FirstName: truncate(first, maxUsernameLength), LastName: truncate(last, maxUsernameLength)}
+	// The email the provider vouches for is taken as verified, unless another account has it.
+	if email, err := normalizeEmail(id.Email); err == nil && email != "" && id.EmailVerified {
+		if _, err = a.ur.GetUserAuthDetailsByEmail(ctx, email); errors.Is(err, sql.ErrNoRows) {
+			now := time.Now().UTC()
+			u.Email, u.EmailVerifiedAt = &email, &now
+		}
+	}
+
+	base := usernameOf(id)
+	for i := 1; u.ID == nil; i++ {
+		if i > maxUsernameAttempts {
+			return 0, fmt.Errorf("create the account of %s identity %s: %w", id.Provider, id.Subject, errNoUsernameAvailable)
+		}
+		u.Username = base
+		if i > 1 {
+			u.Username = fmt.Sprintf("%s%d", truncate(base, maxUsernameLength-3), i)
+		}
+		err := a.ur.CreateUser(ctx, u)
+		if errors.Is(err, user.ErrDuplicateEmail) {
+			// taken in the meantime, the user can verify another one
+			u.Email, u.EmailVerifiedAt = nil, nil
+			i--
+			continue
+		}
+		if err != nil && !errors.Is(err, user.ErrDuplicateUsername) {
+			return 0, err
+		}
+	}
+
+	err := a.ur.AddIdentity(ctx, &user.Identity{UserID: *u.ID, Provider: id.Provider, Subject: id.Subject, Email: id.Email})
+	if err != nil {
+		return 0, err
+	}
+	logging.For(ctx, a.log).Info("user registered", zap.String("username", u.Username), zap.Int("user_id", *u.ID),
+		zap.String("provider", id.Provider))
+	a.metrics.UserRegistered()
+	a.audit(ctx, EventRegistered, *u.ID, u.Username)
+
+	return *u.ID, nil
+}
+
+// usernameOf returns the username of the identity, the local part of its email or the provider and the
+// subject when it has none.
+func usernameOf(id *oidc.Identity) string {
+	username := strings.TrimSpace(id.Username)
+	if username == "" {
+		username, _, _ = strings.Cut(id.Email, "@")
+	}
+	if username == "" {
+		username = id.Provider + "_" + id.Subject
+	}
+
+	return truncate(username, maxUsernameLength)
+}
@@ -0,0 +1,288 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/app/auth"
+	"movierama/internal/config"
+	"movierama/internal/infra/repository/memory"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/metrics"
+	"movierama/internal/oidc"
+	"movierama/internal/oidc/oidctest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type oidcLogin struct {
+	userID int
+	user   oidctest.User
+}
+
+// loginOIDC logs u in with the provider, userID is the user linking the identity, zero for a login.
+func loginOIDC(t *testing.T, app auth.Service, srv *oidctest.Server, provider string, userID int, u oidctest.User) (*auth.LoginRes, error) {
+	res, err := app.AuthorizeOIDC(clientContext(), provider, userID)
+	require.NoError(t, err)
+	code, state := srv.Authorize(t, res.URL, u)
+
+	return app.LoginOIDC(clientContext(), &auth.OIDCCallback{Code: code, State: state, Binding: res.Binding, UserID: userID})
+}
+
+func newOIDCService(srv *oidctest.Server, repo auth.Repository, cfg *config.Config) auth.Service {
+	flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute,
+		oidc.New(srv.OIDC("test", "http://localhost:3000/auth/callback")),
+		oidc.New(srv.OAuth2("github", "http://localhost:3000/auth/callback")))
+
+	return auth.NewService(repo, nil, flow, nil, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+}
+
+func Test_LoginOIDC(t *testing.T) {
+	alice := oidctest.User{Subject: "1", Username: "alice", Email: "alice@example.com", GivenName: "Alice", FamilyName: "Smith"}
+	bob := oidctest.User{Subject: "2", Username: "bob", Email: "bob@example.com"}
+	tests := map[string]struct {
+		provider       string
+		manualAccounts bool
+		usernames      []string
+		before         []oidcLogin
+		banned         int
+		userID         int
+		user           oidctest.User
+		expUserID      int
+		expUsername    string
+		expEvent       string
+		expErr         error
+	}{
+		"should create the account of a new identity": {
+			user:        alice,
+			expUserID:   1,
+			expUsername: "alice",
+			expEvent:    auth.EventRegistered,
+		},
+		"should create the account of a new identity of an oauth2 provider": {
+			provider:    "github",
+			user:        alice,
+			expUserID:   1,
+			expUsername: "alice",
+			expEvent:    auth.EventRegistered,
+		},
+		"should number a taken username": {
+			usernames:   []string{"alice"},
+			user:        alice,
+			expUserID:   2,
+			expUsername: "alice2",
+			expEvent:    auth.EventRegistered,
+		},
+		"should take the username of the email": {
+			user:        oidctest.User{Subject: "3", Email: "carol@example.com"},
+			expUserID:   1,
+			expUsername: "carol",
+			expEvent:    auth.EventRegistered,
+		},
+		"should log the linked identity in": {
+			before:      []oidcLogin{{user: alice}},
+			user:        alice,
+			expUserID:   1,
+			expUsername: "alice",
+			expEvent:    auth.EventLoginSucceeded,
+		},
+		"should reject a new identity when the accounts are not created": {
+			manualAccounts: true,
+			user:           alice,
+			expErr:         auth.ErrIdentityNotLinked,
+		},
+		"should link the identity to the account of the user": {
+			manualAccounts: true,
+			usernames:      []string{"bob"},
+			userID:         1,
+			user:           alice,
+			expUserID:      1,
+			expUsername:    "bob",
+			expEvent:       auth.EventIdentityLinked,
+		},
+		"should log the identity linked by the user in": {
+			manualAccounts: true,
+			usernames:      []string{"bob"},
+			before:         []oidcLogin{{userID: 1, user: alice}},
+			user:           alice,
+			expUserID:      1,
+			expUsername:    "bob",
+			expEvent:       auth.EventLoginSucceeded,
+		},
+		"should reject an identity linked to another account": {
+			usernames: []string{"bob", "carol"},
+			before:    []oidcLogin{{userID: 1, user: alice}},
+			userID:    2,
+			user:      alice,
+			expErr:    auth.ErrIdentityLinked,
+		},
+		"should reject a second identity of the provider": {
+			usernames: []string{"bob"},
+			before:    []oidcLogin{{userID: 1, user: alice}},
+			userID:    1,
+			user:      bob,
+			expErr:    auth.ErrProviderLinked,
+		},
+		"should deny a banned account": {
+			before: []oidcLogin{{user: alice}},
+			banned: 1,
+			user:   alice,
+			expErr: auth.ErrAccountBanned,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := oidctest.NewServer(t)
+			repo := memory.NewRepository()
+			for _, username := range tt.usernames {
+				require.NoError(t, repo.CreateUser(context.TODO(), &user.SQLUser{Username: username, Password: secretPassHash}))
+			}
+			cfg := &config.Config{
+				App:  config.App{JWTSecret: "secret"},
+				OIDC: config.OIDC{AutoCreate: !tt.manualAccounts},
+			}
+			app := newOIDCService(srv, repo, cfg)
+			provider := tt.provider
+			if provider == "" {
+				provider = "test"
+			}
+			for _, l := range tt.before {
+				_, err := loginOIDC(t, app, srv, provider, l.userID, l.user)
+				require.NoError(t, err)
+			}
+			if tt.banned != 0 {
+				require.NoError(t, repo.UpdateStatus(context.TODO(), tt.banned, "banned", nil))
+			}
+
+			res, err := loginOIDC(t, app, srv, provider, tt.userID, tt.user)
+			if tt.expErr != nil {
+				assert.ErrorIs(t, err, tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			claims := &auth.JwtCustomClaims{}
+			_, err = jwt.ParseWithClaims(res.Token, claims, func(*jwt.Token) (interface{}, error) {
+				return []byte("secret"), nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expUserID, claims.UserID)
+
+			details, err := repo.GetUserAuthDetailsByID(context.TODO(), tt.expUserID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expUsername, details.Username)
+			if tt.expUserID <= len(tt.usernames) {
+				assert.Equal(t, secretPassHash, details.Password)
+			} else {
+				assert.Empty(t, details.Password, "the created accounts have no password")
+			}
+
+			events, err := repo.GetAuthEvents(context.TODO(), user.AuthEventFilter{UserID: tt.expUserID, Limit: 10})
+			require.NoError(t, err)
+			var kinds []string
+			for _, e := range events {
+				kinds = append(kinds, e.Event)
+			}
+			assert.Contains(t, kinds, tt.expEvent)
+		})
+	}
+}
+
+func Test_LoginOIDC_Errors(t *testing.T) {
+	alice := oidctest.User{Subject: "1", Username: "alice"}
+	cfg := &config.Config{App: config.App{JWTSecret: "secret"}, OIDC: config.OIDC{AutoCreate: true}}
+
+	t.Run("should reject an unknown provider", func(t *testing.T) {
+		app := newOIDCService(oidctest.NewServer(t), memory.NewRepository(), cfg)
+
+		_, err := app.AuthorizeOIDC(clientContext(), "unknown", 0)
+		assert.ErrorIs(t, err, auth.ErrUnknownProvider)
+	})
+
+	t.Run("should reject a used state", func(t *testing.T) {
+		srv := oidctest.NewServer(t)
+		app := newOIDCService(srv, memory.NewRepository(), cfg)
+		res, err := app.AuthorizeOIDC(clientContext(), "test", 0)
+		require.NoError(t, err)
+		code, state := srv.Authorize(t, res.URL, alice)
+
+		_, err = app.LoginOIDC(clientContext(), &auth.OIDCCallback{Code: code, State: state, Binding: res.Binding})
+		require.NoError(t, err)
+		_, err = app.LoginOIDC(clientContext(), &auth.OIDCCallback{Code: code, State: state, Binding: res.Binding})
+		assert.ErrorIs(t, err, auth.ErrLoginExpired)
+	})
+
+	t.Run("should reject a login completed by another client", func(t *testing.T) {
+		srv := oidctest.NewServer(t)
+		app := newOIDCService(srv, memory.NewRepository(), cfg)
+		res, err := app.AuthorizeOIDC(clientContext(), "test", 0)
+		require.NoError(t, err)
+		code, state := srv.Authorize(t, res.URL, alice)
+
+		_, err = app.LoginOIDC(clientContext(), &auth.OIDCCallback{Code: code, State: state})
+		assert.ErrorIs(t, err, auth.ErrLoginExpired)
+	})
+
+	t.Run("should reject a link completed by another user", func(t *testing.T) {
+		srv := oidctest.NewServer(t)
+		repo := memory.NewRepository()
+		app := newOIDCService(srv, repo, cfg)
+		_, err := loginOIDC(t, app, srv, "test", 0, alice)
+		require.NoError(t, err)
+		res, err := app.AuthorizeOIDC(clientContext(), "github", 1)
+		require.NoError(t, err)
+		code, state := srv.Authorize(t, res.URL, oidctest.User{Subject: "2", Username: "bob"})
+
+		_, err = app.LoginOIDC(clientContext(), &auth.OIDCCallback{Code: code, State: state, Binding: res.Binding, UserID: 2})
+		assert.ErrorIs(t, err, auth.ErrLoginExpired)
+		_, err = repo.GetIdentityUserID(context.TODO(), "github", "2")
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject an identity of another issuer", func(t *testing.T) {
+		srv := oidctest.NewServer(t)
+		srv.TokenIssuer = "https://attacker.example.com"
+		repo := memory.NewRepository()
+		app := newOIDCService(srv, repo, cfg)
+
+		_, err := loginOIDC(t, app, srv, "test", 0, alice)
+		assert.ErrorIs(t, err, auth.ErrIdentityRejected)
+		_, err = repo.GetUserAuthDetailsByID(context.TODO(), 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("should list the providers", func(t *testing.T) {
+		app := newOIDCService(oidctest.NewServer(t), memory.NewRepository(), cfg)
+
+		assert.Equal(t, []string{"github", "test"}, app.Providers(context.TODO()))
+	})
+}
+
+func Test_LoginOIDC_Email(t *testing.T) {
+	cfg := &config.Config{App: config.App{JWTSecret: "secret"}, OIDC: config.OIDC{AutoCreate: true}}
+	srv := oidctest.NewServer(t)
+	repo := memory.NewRepository()
+	app := newOIDCService(srv, repo, cfg)
+
+	_, err := loginOIDC(t, app, srv, "test", 0, oidctest.User{Subject: "1", Username: "alice", Email: "Alice@example.com", EmailVerified: true})
+	require.NoError(t, err)
+	_, err = loginOIDC(t, app, srv, "test", 0, oidctest.User{Subject: "2", Username: "bob", Email: "alice@example.com", EmailVerified: true})
+	require.NoError(t, err, "an email of another account is left out")
+	_, err = loginOIDC(t, app, srv, "test", 0, oidctest.User{Subject: "3", Username: "carol", Email: "carol@example.com"})
+	require.NoError(t, err)
+
+	alice, err := repo.GetUserAuthDetailsByEmail(context.TODO(), "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, alice.ID)
+	assert.NotNil(t, alice.EmailVerifiedAt, "the email the provider vouches for is verified")
+	for _, userID := range []int{2, 3} {
+		details, err := repo.GetUserAuthDetailsByID(context.TODO(), userID)
+		require.NoError(t, err)
+		assert.Nil(t, details.Email)
+	}
+}
@@ -3,10 +3,27 @@ package auth
 import (
 	"context"
 	"movierama/internal/infra/repository/sql/user"
+	"time"
 )
 
 // Repository should be able to manage the user auth.
 type Repository interface {
 	GetUserAuthDetails(ctx context.Context, username string) (*user.AuthUserDetails, error)
+	GetUserAuthDetailsByID(ctx context.Context, userID int) (*user.AuthUserDetails, error)
+	GetUserAuthDetailsByEmail(ctx context.Context, email string) (*user.AuthUserDetails, error)
 	CreateUser(ctx context.Context, user *user.SQLUser) error
+	UpdatePassword(ctx context.Context, userID int, password string) error
+	UpdateEmail(ctx context.Context, userID int, email string) error
+	VerifyEmail(ctx context.Context, userID int, email string, at time.Time) error
+	BootstrapRole(ctx context.Context, userID int, role string) (bool, error)
+	GetUsers(ctx context.Context, userIDs []int) ([]user.User, error)
+	AddAuthEvent(ctx context.Context, event *user.AuthEvent) error
+	GetAuthEvents(ctx context.Context, filter user.AuthEventFilter) ([]user.AuthEvent, error)
+	GetIdentityUserID(ctx context.Context, provider, subject string) (int, error)
+	AddIdentity(ctx context.Context, identity *user.Identity) error
+	AddAccessToken(ctx context.Context, token *user.AccessToken) error
+	GetAccessTokenByHash(ctx context.Context, hash string) (*user.AccessToken, error)
+	GetAccessTokens(ctx context.Context, userID int) ([]user.AccessToken, error)
+	DeleteAccessToken(ctx context.Context, userID, tokenID int) error
+	TouchAccessToken(ctx context.Context, tokenID int, at time.Time) error
 }
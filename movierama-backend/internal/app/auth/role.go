@@ -0,0 +1,58 @@
+package auth
+
+import "time"
+
+// Role is the role of a user, it grants the access to the admin API.
+type Role string
+
+// User roles, each role is granted the access of the roles below it.
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	return roleRanks[r] != 0
+}
+
+// AtLeast reports whether r is granted the access of min.
+func (r Role) AtLeast(min Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[min]
+}
+
+// Outranks reports whether r is above other.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
+// Account statuses.
+const (
+	StatusActive = "active"
+	// StatusSuspended blocks the account until its suspension ends.
+	StatusSuspended = "suspended"
+	// StatusBanned blocks the account until it is reinstated.
+	StatusBanned = "banned"
+)
+
+// AccountError returns the error of an account that cannot log in, nil when the account is active
+// or its suspension has ended at now.
+func AccountError(status string, suspendedUntil *time.Time, now time.Time) error {
+	switch status {
+	case StatusBanned:
+		return ErrAccountBanned
+	case StatusSuspended:
+		if suspendedUntil == nil || suspendedUntil.After(now) {
+			return &SuspendedError{Until: suspendedUntil}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package auth
+
+// Scope grants a personal access token the use of some routes.
+type Scope string
+
+// Personal access token scopes.
+const (
+	ScopeMoviesRead   Scope = "movies:read"
+	ScopeMoviesWrite  Scope = "movies:write"
+	ScopeVotesWrite   Scope = "votes:write"
+	ScopeReportsWrite Scope = "reports:write"
+)
+
+// Scopes lists the scopes of the personal access tokens.
+var Scopes = []Scope{ScopeMoviesRead, ScopeMoviesWrite, ScopeVotesWrite, ScopeReportsWrite}
+
+// Valid reports whether s is a known scope.
+func (s Scope) Valid() bool {
+	for _, scope := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,40 +2,213 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt"
-	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
+	"movierama/internal/bruteforce"
 	"movierama/internal/config"
+	"movierama/internal/emailtoken"
 	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/jwtkeys"
+	"movierama/internal/logging"
+	"movierama/internal/mail"
+	"movierama/internal/metrics"
+	"movierama/internal/oidc"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 const (
 	accessTokenExpirationHours = 72
+	// maxUsernameAttempts is the number of usernames tried for an account created on an external login.
+	maxUsernameAttempts = 10
+)
+
+// Lengths of the columns of the audit log.
+const (
+	maxUsernameLength  = 255
+	maxIPLength        = 45
+	maxUserAgentLength = 512
 )
 
+// Auth errors.
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrTooManyAttempts    = errors.New("too many failed login attempts")
+	ErrAccountBanned      = errors.New("account is banned")
+	ErrAccountSuspended   = errors.New("account is suspended")
+	// ErrTokenRevoked is returned for a token of a deleted user, of a former role or of a former password.
+	ErrTokenRevoked    = errors.New("token is no longer valid")
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	// ErrLoginExpired is returned for a callback of an unknown, expired or already completed login, or of a login
+	// started by another client or user.
+	ErrLoginExpired = errors.New("the login is invalid or has expired, start it again")
+	// ErrIdentityRejected is returned when the identity provider does not vouch for the user.
+	ErrIdentityRejected  = errors.New("the identity provider rejected the login")
+	ErrIdentityNotLinked = errors.New("the identity is not linked to an account")
+	ErrIdentityLinked    = errors.New("the identity is linked to another account")
+	ErrProviderLinked    = errors.New("another identity of the provider is linked to the account")
+	ErrInvalidEmail      = errors.New("invalid email address")
+	ErrEmailTaken        = errors.New("email is already taken")
+	ErrEmailRequired     = errors.New("an email is required")
+	// ErrEmailNotVerified is returned for a login with valid credentials of an account whose email is not
+	// verified, when the verification is required.
+	ErrEmailNotVerified = errors.New("email is not verified")
+	// ErrInvalidEmailToken is returned for a forged, expired or already used email token.
+	ErrInvalidEmailToken = errors.New("the link is invalid or has expired")
+	ErrInvalidTokenName  = errors.New("the token name must have 1 to 64 characters")
+	ErrInvalidScope      = errors.New("the token needs at least one scope, of " + scopeList())
+	ErrInvalidExpiry     = errors.New("the token expiry must be in the future")
+	ErrTooManyTokens     = errors.New("too many access tokens, revoke one first")
+	ErrTokenNotFound     = errors.New("access token not found")
+	// ErrInvalidAccessToken is returned for an unknown, expired or revoked personal access token.
+	ErrInvalidAccessToken  = errors.New("invalid, expired or revoked access token")
+	errNoUsernameAvailable = errors.New("no username available")
+)
+
+// AttemptsError is returned when a login is rejected after repeated failures, it matches ErrTooManyAttempts.
+type AttemptsError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (e *AttemptsError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+// Is reports whether target is ErrTooManyAttempts.
+func (e *AttemptsError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// SuspendedError is returned for a suspended account, it matches ErrAccountSuspended.
+type SuspendedError struct {
+	// Until is nil for a suspension without end.
+	Until *time.Time
+}
+
+// Error implements error.
+func (e *SuspendedError) Error() string {
+	if e.Until == nil {
+		return ErrAccountSuspended.Error()
+	}
+
+	return fmt.Sprintf("%v until %s", ErrAccountSuspended, e.Until.UTC().Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrAccountSuspended.
+func (e *SuspendedError) Is(target error) bool {
+	return target == ErrAccountSuspended
+}
+
 // JwtCustomClaims contains the custom jwt claims data.
 type JwtCustomClaims struct {
-	UserID int `json:"user_id"`
+	UserID int  `json:"user_id"`
+	Role   Role `json:"role,omitempty"`
+	// TokenVersion is the token version of the user when the token was issued, the tokens issued before
+	// the versions have none.
+	TokenVersion int `json:"token_version,omitempty"`
+	// AccessTokenID is the id of the personal access token of the request, zero for a JWT. It is never
+	// read from a JWT.
+	AccessTokenID int `json:"-"`
+	// Scopes are the scopes of the personal access token.
+	Scopes []Scope `json:"-"`
 	jwt.StandardClaims
 }
 
+// Allows reports whether the token of the claims is granted scope, a JWT is granted every scope.
+func (c *JwtCustomClaims) Allows(scope Scope) bool {
+	if c.AccessTokenID == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserRole returns the role of the claims, the tokens issued before the roles are of users.
+func (c *JwtCustomClaims) UserRole() Role {
+	if c.Role == "" {
+		return RoleUser
+	}
+
+	return c.Role
+}
+
 // Service handles auth information.
 type Service interface {
-	Login(c echo.Context, user *UserLogin) (*LoginRes, error)
+	Login(ctx context.Context, user *UserLogin) (*LoginRes, error)
 	Register(ctx context.Context, registerUser *UserRegister) error
+	ChangePassword(ctx context.Context, userID int, change *PasswordChange) error
+	GetUsers(ctx context.Context, userIDs []int) ([]User, error)
+	GetEvents(ctx context.Context, filter EventFilter) ([]Event, error)
+	// Authenticate checks that the token of claims is still valid for its user.
+	Authenticate(ctx context.Context, claims *JwtCustomClaims) error
+	// Providers returns the names of the external identity providers.
+	Providers(ctx context.Context) []string
+	// AuthorizeOIDC starts a login with an external identity provider, userID is the user linking the
+	// identity to their account, zero for a login.
+	AuthorizeOIDC(ctx context.Context, provider string, userID int) (*AuthorizationRes, error)
+	// LoginOIDC completes a login with an external identity provider.
+	LoginOIDC(ctx context.Context, callback *OIDCCallback) (*LoginRes, error)
+	// VerifyEmail verifies the email of the token sent on registration or on an email change.
+	VerifyEmail(ctx context.Context, verification *EmailVerification) error
+	// ResendVerification sends the verification of the email again, it never tells whether it is known.
+	ResendVerification(ctx context.Context, req *EmailRequest) error
+	// ChangeEmail replaces the email of the user and sends its verification.
+	ChangeEmail(ctx context.Context, userID int, req *EmailRequest) error
+	// ForgotPassword sends a password reset to the email, it never tells whether it is known.
+	ForgotPassword(ctx context.Context, req *EmailRequest) error
+	// ResetPassword replaces the password of the user of the token sent by ForgotPassword.
+	ResetPassword(ctx context.Context, reset *PasswordReset) error
+	// CreateAccessToken creates a personal access token of the user, its secret is only returned here.
+	CreateAccessToken(ctx context.Context, userID int, req *AccessTokenRequest) (*NewAccessToken, error)
+	GetAccessTokens(ctx context.Context, userID int) ([]AccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID, tokenID int) error
+	// AuthenticateAccessToken returns the claims of a personal access token whose user may still use it.
+	AuthenticateAccessToken(ctx context.Context, token string) (*JwtCustomClaims, error)
 }
 
 type authService struct {
-	ur  Repository
-	cfg *config.Config
+	ur      Repository
+	guard   *bruteforce.Guard
+	flow    *oidc.Flow
+	mailer  mail.Mailer
+	keys    *jwtkeys.Set
+	tokens  *emailtoken.Signer
+	cfg     *config.Config
+	log     *zap.Logger
+	metrics *metrics.Metrics
 }
 
-// NewService constructor.
-func NewService(userRepo Repository, cfg *config.Config) Service {
+// NewService constructor, the failed logins are slowed down and locked out by guard when it is not nil.
+// The users log in with the external identity providers of flow, none when it is nil. The email
+// verifications and password resets are sent with mailer, none when it is nil. The access tokens are signed
+// with keys, HS256 with the JWT secret of cfg when it is nil.
+func NewService(userRepo Repository, guard *bruteforce.Guard, flow *oidc.Flow, mailer mail.Mailer, keys *jwtkeys.Set,
+	cfg *config.Config, logger *zap.Logger, m *metrics.Metrics) Service {
+	if keys == nil {
+		keys = jwtkeys.NewHMAC(cfg.App.JWTSecret)
+	}
+
 	return &authService{
-		ur:  userRepo,
-		cfg: cfg,
+		ur:      userRepo,
+		guard:   guard,
+		flow:    flow,
+		mailer:  mailer,
+		keys:    keys,
+		tokens:  emailtoken.NewSigner(cfg.App.JWTSecret),
+		cfg:     cfg,
+		log:     logger,
+		metrics: m,
 	}
 }
 
@@ -45,43 +218,187 @@ type UserLogin struct {
 	Password string `json:"password"`
 }
 
-func (a authService) Login(c echo.Context, user *UserLogin) (*LoginRes, error) {
-	dbUser, err := a.ur.GetUserAuthDetails(c.Request().Context(), user.Username)
+func (a authService) Login(ctx context.Context, user *UserLogin) (*LoginRes, error) {
+	log := logging.For(ctx, a.log).With(zap.String("username", user.Username))
+	client := ClientFrom(ctx)
+
+	// The guard fails open, a login is not rejected because its store is down.
+	block, err := a.guard.Check(ctx, user.Username, client.IP)
+	if err != nil {
+		log.Error("check failed logins", zap.Error(err))
+	}
+	if block != nil {
+		log.Warn("login blocked", zap.Duration("retry_after", block.Wait), zap.Bool("locked", block.Locked))
+		a.metrics.Login(metrics.LoginBlocked)
+		a.audit(ctx, EventLoginBlocked, 0, user.Username)
+		return nil, &AttemptsError{RetryAfter: block.Wait}
+	}
+
+	dbUser, err := a.ur.GetUserAuthDetails(ctx, user.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("login failed", zap.String("reason", "unknown username"))
+		a.loginFailed(ctx, 0, user.Username)
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if err = bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(user.Password)); err != nil {
 		// If the two passwords don't match, return an error.
+		log.Warn("login failed", zap.String("reason", "wrong password"), zap.Int("user_id", dbUser.ID))
+		a.loginFailed(ctx, dbUser.ID, user.Username)
+		return nil, ErrInvalidCredentials
+	}
+
+	// The right password gives back the attempt counted by the guard.
+	if err = a.guard.Succeed(ctx, user.Username, client.IP); err != nil {
+		log.Error("reset failed logins", zap.Error(err))
+	}
+
+	// The password is checked first, so that the status of an account is only told to its owner.
+	if err = AccountError(dbUser.Status, dbUser.SuspendedUntil, time.Now()); err != nil {
+		log.Warn("login denied", zap.String("reason", dbUser.Status), zap.Int("user_id", dbUser.ID))
+		a.metrics.Login(metrics.LoginDenied)
+		a.audit(ctx, EventLoginDenied, dbUser.ID, dbUser.Username)
+		return nil, err
+	}
+	if err = a.emailError(ctx, dbUser); err != nil {
 		return nil, err
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
-		UserID: dbUser.ID,
+	return a.issue(ctx, dbUser)
+}
+
+// issue returns the token of a user who logged in, whose account is not blocked.
+func (a authService) issue(ctx context.Context, dbUser *user.AuthUserDetails) (*LoginRes, error) {
+	log := logging.For(ctx, a.log).With(zap.String("username", dbUser.Username))
+	role, err := a.bootstrapRole(ctx, dbUser)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the token with the current key and send it as response.
+	t, err := a.keys.Sign(&JwtCustomClaims{
+		UserID:       dbUser.ID,
+		Role:         role,
+		TokenVersion: dbUser.TokenVersion,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Hour * accessTokenExpirationHours).Unix(),
 		},
 	})
-
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString([]byte(a.cfg.App.JWTSecret))
 	if err != nil {
+		log.Error("sign token", zap.Error(err))
 		return nil, err
 	}
+	log.Info("user logged in", zap.Int("user_id", dbUser.ID))
+	a.metrics.Login(metrics.LoginSucceeded)
+	a.audit(ctx, EventLoginSucceeded, dbUser.ID, dbUser.Username)
 
 	return &LoginRes{Username: dbUser.Username, Token: t}, nil
 }
 
+// bootstrapRole returns the role of the user, promoting the admin user ids of the config to admin once, while
+// their role was never changed, so that the admins can demote them.
+func (a authService) bootstrapRole(ctx context.Context, dbUser *user.AuthUserDetails) (Role, error) {
+	role := Role(dbUser.Role)
+	if role != RoleUser || !a.adminUserID(dbUser.ID) {
+		return role, nil
+	}
+	promoted, err := a.ur.BootstrapRole(ctx, dbUser.ID, string(RoleAdmin))
+	if err != nil || !promoted {
+		return role, err
+	}
+	logging.For(ctx, a.log).Info("user promoted to admin", zap.Int("user_id", dbUser.ID))
+
+	return RoleAdmin, nil
+}
+
+// adminUserID reports whether userID is one of the admin user ids of the config.
+func (a authService) adminUserID(userID int) bool {
+	for _, admin := range a.cfg.App.AdminUserIDs {
+		if admin == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticate checks that the user of claims exists, is not suspended or banned and still has the role
+// and the token version of the token, so that the tokens stop working as soon as the account is blocked,
+// demoted or its password changed.
+func (a authService) Authenticate(ctx context.Context, claims *JwtCustomClaims) error {
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, claims.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if err = AccountError(dbUser.Status, dbUser.SuspendedUntil, time.Now()); err != nil {
+		return err
+	}
+	if Role(dbUser.Role) != claims.UserRole() || dbUser.TokenVersion != claims.TokenVersion {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// loginFailed records a failed login, userID is zero for an unknown username. Its attempt was already counted
+// by the guard.
+func (a authService) loginFailed(ctx context.Context, userID int, username string) {
+	a.metrics.Login(metrics.LoginFailed)
+	a.audit(ctx, EventLoginFailed, userID, username)
+}
+
+// audit records an auth event of the client of ctx, userID is zero for an unknown username.
+// A failure is logged rather than returned, so that the audit log does not break the logins.
+func (a authService) audit(ctx context.Context, event string, userID int, username string) {
+	client := ClientFrom(ctx)
+	e := &user.AuthEvent{
+		Username:  truncate(username, maxUsernameLength),
+		Event:     event,
+		IP:        truncate(client.IP, maxIPLength),
+		UserAgent: truncate(client.UserAgent, maxUserAgentLength),
+	}
+	if userID != 0 {
+		e.UserID = &userID
+	}
+	if err := a.ur.AddAuthEvent(ctx, e); err != nil {
+		logging.For(ctx, a.log).Error("record auth event", zap.String("event", event), zap.Error(err))
+	}
+}
+
+// truncate returns the first n runes of s.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+
+	return s
+}
+
 // UserRegister contains the payload struct for registration.
 type UserRegister struct {
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
+	// Email is optional unless the verification is required, its verification is sent on registration.
+	Email string `json:"email"`
 }
 
 func (a authService) Register(ctx context.Context, registerUser *UserRegister) error {
+	email, err := normalizeEmail(registerUser.Email)
+	if err != nil {
+		return err
+	}
+	if email == "" && a.cfg.Email.RequireVerified {
+		return ErrEmailRequired
+	}
+
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(registerUser.Password), bcrypt.MinCost)
 	if err != nil {
 		return err
@@ -93,11 +410,107 @@ func (a authService) Register(ctx context.Context, registerUser *UserRegister) e
 		FirstName: registerUser.FirstName,
 		LastName:  registerUser.LastName,
 	}
+	if email != "" {
+		u.Email = &email
+	}
 
+	log := logging.For(ctx, a.log).With(zap.String("username", registerUser.Username))
 	err = a.ur.CreateUser(ctx, u)
+	if errors.Is(err, user.ErrDuplicateUsername) {
+		log.Info("registration rejected", zap.String("reason", "username taken"))
+		return ErrUsernameTaken
+	}
+	if errors.Is(err, user.ErrDuplicateEmail) {
+		log.Info("registration rejected", zap.String("reason", "email taken"))
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("user registered")
+	a.metrics.UserRegistered()
+	a.audit(ctx, EventRegistered, *u.ID, u.Username)
+	if email != "" {
+		a.sendVerification(ctx, *u.ID, u.Username, email)
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of the user after checking the current one, which revokes their JWTs.
+func (a authService) ChangePassword(ctx context.Context, userID int, change *PasswordChange) error {
+	log := logging.For(ctx, a.log).With(zap.Int("user_id", userID))
+
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(change.CurrentPassword)); err != nil {
+		log.Warn("password change rejected", zap.String("reason", "wrong password"))
+		return ErrInvalidCredentials
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(change.NewPassword), bcrypt.MinCost)
 	if err != nil {
 		return err
 	}
+	if err = a.ur.UpdatePassword(ctx, userID, string(passwordHash)); err != nil {
+		return err
+	}
+	log.Info("password changed")
+	a.audit(ctx, EventPasswordChange, userID, dbUser.Username)
 
 	return nil
 }
+
+// GetEvents returns the auth events selected by filter, newest first.
+func (a authService) GetEvents(ctx context.Context, filter EventFilter) ([]Event, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultEventLimit
+	}
+	if filter.Limit > MaxEventLimit {
+		filter.Limit = MaxEventLimit
+	}
+
+	dbEvents, err := a.ur.GetAuthEvents(ctx, user.AuthEventFilter(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(dbEvents))
+	for _, e := range dbEvents {
+		event := Event{
+			ID:        e.ID,
+			Username:  e.Username,
+			Event:     e.Event,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			CreatedAt: e.CreatedAt,
+		}
+		if e.UserID != nil {
+			event.UserID = *e.UserID
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// GetUsers returns the users of the given ids, missing users are omitted.
+func (a authService) GetUsers(ctx context.Context, userIDs []int) ([]User, error) {
+	dbUsers, err := a.ur.GetUsers(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(dbUsers))
+	for _, u := range dbUsers {
+		users = append(users, User{
+			ID:        u.ID,
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+		})
+	}
+
+	return users, nil
+}
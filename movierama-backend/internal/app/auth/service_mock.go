@@ -2,7 +2,6 @@ package auth
 
 import (
 	"context"
-	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -12,15 +11,127 @@ type SvcMock struct {
 }
 
 // Login mock.
-func (sm SvcMock) Login(c echo.Context, ul *UserLogin) (*LoginRes, error) {
-	args := sm.MethodCalled("Login", c, ul)
+func (sm *SvcMock) Login(ctx context.Context, ul *UserLogin) (*LoginRes, error) {
+	args := sm.MethodCalled("Login", ctx, ul)
 
 	return args.Get(0).(*LoginRes), args.Error(1)
 }
 
 // Register mock.
-func (sm SvcMock) Register(ctx context.Context, registerUser *UserRegister) error {
+func (sm *SvcMock) Register(ctx context.Context, registerUser *UserRegister) error {
 	args := sm.MethodCalled("Register", ctx, registerUser)
 
 	return args.Error(0)
 }
+
+// ChangePassword mock.
+func (sm *SvcMock) ChangePassword(ctx context.Context, userID int, change *PasswordChange) error {
+	args := sm.MethodCalled("ChangePassword", ctx, userID, change)
+
+	return args.Error(0)
+}
+
+// GetUsers mock.
+func (sm *SvcMock) GetUsers(ctx context.Context, userIDs []int) ([]User, error) {
+	args := sm.MethodCalled("GetUsers", ctx, userIDs)
+
+	return args.Get(0).([]User), args.Error(1)
+}
+
+// GetEvents mock.
+func (sm *SvcMock) GetEvents(ctx context.Context, filter EventFilter) ([]Event, error) {
+	args := sm.MethodCalled("GetEvents", ctx, filter)
+
+	return args.Get(0).([]Event), args.Error(1)
+}
+
+// Authenticate mock.
+func (sm *SvcMock) Authenticate(ctx context.Context, claims *JwtCustomClaims) error {
+	args := sm.MethodCalled("Authenticate", ctx, claims)
+
+	return args.Error(0)
+}
+
+// Providers mock.
+func (sm *SvcMock) Providers(ctx context.Context) []string {
+	args := sm.MethodCalled("Providers", ctx)
+
+	return args.Get(0).([]string)
+}
+
+// AuthorizeOIDC mock.
+func (sm *SvcMock) AuthorizeOIDC(ctx context.Context, provider string, userID int) (*AuthorizationRes, error) {
+	args := sm.MethodCalled("AuthorizeOIDC", ctx, provider, userID)
+
+	return args.Get(0).(*AuthorizationRes), args.Error(1)
+}
+
+// LoginOIDC mock.
+func (sm *SvcMock) LoginOIDC(ctx context.Context, callback *OIDCCallback) (*LoginRes, error) {
+	args := sm.MethodCalled("LoginOIDC", ctx, callback)
+
+	return args.Get(0).(*LoginRes), args.Error(1)
+}
+
+// VerifyEmail mock.
+func (sm *SvcMock) VerifyEmail(ctx context.Context, verification *EmailVerification) error {
+	args := sm.MethodCalled("VerifyEmail", ctx, verification)
+
+	return args.Error(0)
+}
+
+// ResendVerification mock.
+func (sm *SvcMock) ResendVerification(ctx context.Context, req *EmailRequest) error {
+	args := sm.MethodCalled("ResendVerification", ctx, req)
+
+	return args.Error(0)
+}
+
+// ChangeEmail mock.
+func (sm *SvcMock) ChangeEmail(ctx context.Context, userID int, req *EmailRequest) error {
+	args := sm.MethodCalled("ChangeEmail", ctx, userID, req)
+
+	return args.Error(0)
+}
+
+// ForgotPassword mock.
+func (sm *SvcMock) ForgotPassword(ctx context.Context, req *EmailRequest) error {
+	args := sm.MethodCalled("ForgotPassword", ctx, req)
+
+	return args.Error(0)
+}
+
+// ResetPassword mock.
+func (sm *SvcMock) ResetPassword(ctx context.Context, reset *PasswordReset) error {
+	args := sm.MethodCalled("ResetPassword", ctx, reset)
+
+	return args.Error(0)
+}
+
+// CreateAccessToken mock.
+func (sm *SvcMock) CreateAccessToken(ctx context.Context, userID int, req *AccessTokenRequest) (*NewAccessToken, error) {
+	args := sm.MethodCalled("CreateAccessToken", ctx, userID, req)
+
+	return args.Get(0).(*NewAccessToken), args.Error(1)
+}
+
+// GetAccessTokens mock.
+func (sm *SvcMock) GetAccessTokens(ctx context.Context, userID int) ([]AccessToken, error) {
+	args := sm.MethodCalled("GetAccessTokens", ctx, userID)
+
+	return args.Get(0).([]AccessToken), args.Error(1)
+}
+
+// RevokeAccessToken mock.
+func (sm *SvcMock) RevokeAccessToken(ctx context.Context, userID, tokenID int) error {
+	args := sm.MethodCalled("RevokeAccessToken", ctx, userID, tokenID)
+
+	return args.Error(0)
+}
+
+// AuthenticateAccessToken mock.
+func (sm *SvcMock) AuthenticateAccessToken(ctx context.Context, token string) (*JwtCustomClaims, error) {
+	args := sm.MethodCalled("AuthenticateAccessToken", ctx, token)
+
+	return args.Get(0).(*JwtCustomClaims), args.Error(1)
+}
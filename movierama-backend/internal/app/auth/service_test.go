@@ -2,39 +2,72 @@ package auth_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
-	"github.com/labstack/echo/v4"
+	"github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 	"movierama/internal/app/auth"
+	"movierama/internal/bruteforce"
 	"movierama/internal/config"
 	"movierama/internal/infra/repository/sql/user"
 	sqluser "movierama/internal/infra/repository/sql/user"
-	"net/http"
-	"net/http/httptest"
+	"movierama/internal/logging"
+	"movierama/internal/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
-func Test_Login(t *testing.T) {
-	rec := httptest.NewRecorder()
+const secretPassHash = "$2a$04$ShttrFUsTWC/aW1ahlr3rO2zLoQpuGfSHjKRB83f.8dJBebnBTOpG"
+
+// authEvent matches the recorded auth event of the given type, user and username.
+func authEvent(event string, userID int, username string) interface{} {
+	return mock.MatchedBy(func(e *user.AuthEvent) bool {
+		if (e.UserID == nil) != (userID == 0) || (e.UserID != nil && *e.UserID != userID) {
+			return false
+		}
+
+		return e.Event == event && e.Username == username && e.IP == "192.0.2.1" && e.UserAgent == "test-agent"
+	})
+}
+
+// clientContext returns a context of the client matched by authEvent.
+func clientContext() context.Context {
+	return auth.WithClient(context.TODO(), auth.Client{IP: "192.0.2.1", UserAgent: "test-agent"})
+}
 
+func Test_Login(t *testing.T) {
+	suspendedUntil := time.Now().Add(time.Hour)
 	tests := map[string]struct {
 		sqlRepo   *sqluser.Mock
 		userLogin *auth.UserLogin
-		echoCtx   echo.Context
 		expRes    *auth.LoginRes
 		expErr    error
+		expLog    string
+		expLogins string
 	}{
 		"should login": {
 			sqlRepo: func() *sqluser.Mock {
 				userDetails := &user.AuthUserDetails{
 					ID:       1,
 					Username: "test_username",
-					Password: "$2a$04$ShttrFUsTWC/aW1ahlr3rO2zLoQpuGfSHjKRB83f.8dJBebnBTOpG",
+					Password: secretPassHash,
 				}
 				repo := sqluser.Mock{}
-				repo.On("GetUserAuthDetails", context.TODO(), "test_username").
+				repo.On("GetUserAuthDetails", mock.Anything, "test_username").
 					Return(userDetails, nil)
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginSucceeded, 1, "test_username")).Return(nil)
 
 				return &repo
 			}(),
@@ -46,6 +79,154 @@ func Test_Login(t *testing.T) {
 				Username: "test_username",
 			},
 			expErr: nil,
+			expLog: "user logged in",
+			expLogins: `
+				movierama_logins_total{result="blocked"} 0
+				movierama_logins_total{result="denied"} 0
+				movierama_logins_total{result="failed"} 0
+				movierama_logins_total{result="succeeded"} 1
+			`,
+		},
+		"should return invalid credentials on wrong password": {
+			sqlRepo: func() *sqluser.Mock {
+				userDetails := &user.AuthUserDetails{
+					ID:       1,
+					Username: "test_username",
+					Password: secretPassHash,
+				}
+				repo := sqluser.Mock{}
+				repo.On("GetUserAuthDetails", mock.Anything, "test_username").
+					Return(userDetails, nil)
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginFailed, 1, "test_username")).Return(nil)
+
+				return &repo
+			}(),
+			userLogin: &auth.UserLogin{
+				Username: "test_username",
+				Password: "wrong_pass",
+			},
+			expErr: auth.ErrInvalidCredentials,
+			expLog: "login failed",
+			expLogins: `
+				movierama_logins_total{result="blocked"} 0
+				movierama_logins_total{result="denied"} 0
+				movierama_logins_total{result="failed"} 1
+				movierama_logins_total{result="succeeded"} 0
+			`,
+		},
+		"should return invalid credentials on missing user": {
+			sqlRepo: func() *sqluser.Mock {
+				repo := sqluser.Mock{}
+				repo.On("GetUserAuthDetails", mock.Anything, "test_username").
+					Return((*user.AuthUserDetails)(nil), sql.ErrNoRows)
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginFailed, 0, "test_username")).Return(nil)
+
+				return &repo
+			}(),
+			userLogin: &auth.UserLogin{
+				Username: "test_username",
+				Password: "secret_pass",
+			},
+			expErr: auth.ErrInvalidCredentials,
+			expLog: "login failed",
+			expLogins: `
+				movierama_logins_total{result="blocked"} 0
+				movierama_logins_total{result="denied"} 0
+				movierama_logins_total{result="failed"} 1
+				movierama_logins_total{result="succeeded"} 0
+			`,
+		},
+		"should deny a banned account": {
+			sqlRepo: func() *sqluser.Mock {
+				userDetails := &user.AuthUserDetails{
+					ID:       1,
+					Username: "test_username",
+					Password: secretPassHash,
+					Role:     "user",
+					Status:   "banned",
+				}
+				repo := sqluser.Mock{}
+				repo.On("GetUserAuthDetails", mock.Anything, "test_username").
+					Return(userDetails, nil)
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginDenied, 1, "test_username")).Return(nil)
+
+				return &repo
+			}(),
+			userLogin: &auth.UserLogin{
+				Username: "test_username",
+				Password: "secret_pass",
+			},
+			expErr: auth.ErrAccountBanned,
+			expLog: "login denied",
+			expLogins: `
+				movierama_logins_total{result="blocked"} 0
+				movierama_logins_total{result="denied"} 1
+				movierama_logins_total{result="failed"} 0
+				movierama_logins_total{result="succeeded"} 0
+			`,
+		},
+		"should deny a suspended account": {
+			sqlRepo: func() *sqluser.Mock {
+				userDetails := &user.AuthUserDetails{
+					ID:             1,
+					Username:       "test_username",
+					Password:       secretPassHash,
+					Role:           "user",
+					Status:         "suspended",
+					SuspendedUntil: &suspendedUntil,
+				}
+				repo := sqluser.Mock{}
+				repo.On("GetUserAuthDetails", mock.Anything, "test_username").
+					Return(userDetails, nil)
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginDenied, 1, "test_username")).Return(nil)
+
+				return &repo
+			}(),
+			userLogin: &auth.UserLogin{
+				Username: "test_username",
+				Password: "secret_pass",
+			},
+			expErr: &auth.SuspendedError{Until: &suspendedUntil},
+			expLog: "login denied",
+			expLogins: `
+				movierama_logins_total{result="blocked"} 0
+				movierama_logins_total{result="denied"} 1
+				movierama_logins_total{result="failed"} 0
+				movierama_logins_total{result="succeeded"} 0
+			`,
+		},
+		"should login after the suspension ended": {
+			sqlRepo: func() *sqluser.Mock {
+				ended := time.Now().Add(-time.Minute)
+				userDetails := &user.AuthUserDetails{
+					ID:             1,
+					Username:       "test_username",
+					Password:       secretPassHash,
+					Role:           "user",
+					Status:         "suspended",
+					SuspendedUntil: &ended,
+				}
+				repo := sqluser.Mock{}
+				repo.On("GetUserAuthDetails", mock.Anything, "test_username").
+					Return(userDetails, nil)
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginSucceeded, 1, "test_username")).Return(nil)
+
+				return &repo
+			}(),
+			userLogin: &auth.UserLogin{
+				Username: "test_username",
+				Password: "secret_pass",
+			},
+			expRes: &auth.LoginRes{
+				Username: "test_username",
+			},
+			expLog: "user logged in",
+			expLogins: `
+				movierama_logins_total{result="blocked"} 0
+				movierama_logins_total{result="denied"} 0
+				movierama_logins_total{result="failed"} 0
+				movierama_logins_total{result="succeeded"} 1
+			`,
 		},
 	}
 
@@ -56,17 +237,241 @@ func Test_Login(t *testing.T) {
 					JWTSecret: "secret",
 				},
 			}
-			app := auth.NewService(tt.sqlRepo, cfg)
-			e := echo.New()
-			req := httptest.NewRequest(http.MethodPost, "/", nil)
-			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-			rec = httptest.NewRecorder()
-			c := e.NewContext(req, rec)
-
-			res, err := app.Login(c, tt.userLogin)
+			core, logs := observer.New(zapcore.DebugLevel)
+			reg := prometheus.NewRegistry()
+			app := auth.NewService(tt.sqlRepo, nil, nil, nil, nil, cfg, zap.New(core), metrics.New(reg))
+
+			ctx := logging.WithRequestID(clientContext(), "rid")
+			res, err := app.Login(ctx, tt.userLogin)
 			assert.Equal(t, tt.expErr, err)
-			assert.Equal(t, tt.expRes.Username, res.Username)
-			assert.NotEmpty(t, res.Token)
+			if tt.expRes != nil {
+				assert.Equal(t, tt.expRes.Username, res.Username)
+				assert.NotEmpty(t, res.Token)
+			}
+
+			entries := logs.FilterMessage(tt.expLog).All()
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, "rid", entries[0].ContextMap()["request_id"])
+				assert.Equal(t, tt.userLogin.Username, entries[0].ContextMap()["username"])
+				assert.NotContains(t, entries[0].ContextMap(), "password")
+			}
+
+			expLogins := "# HELP movierama_logins_total Number of login attempts by result.\n" +
+				"# TYPE movierama_logins_total counter\n" + tt.expLogins
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expLogins), "movierama_logins_total"))
+			tt.sqlRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_Login_Lockout(t *testing.T) {
+	repo := &sqluser.Mock{}
+	repo.On("GetUserAuthDetails", mock.Anything, "test_username").
+		Return(&user.AuthUserDetails{ID: 1, Username: "test_username", Password: secretPassHash}, nil)
+	repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginFailed, 1, "test_username")).Return(nil).Twice()
+	repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginBlocked, 0, "Test_Username")).Return(nil).Once()
+
+	policy := bruteforce.Policy{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, MaxAttempts: 5, LockoutDuration: time.Hour}
+	guard := bruteforce.NewGuard(bruteforce.NewMemoryStore(), policy, bruteforce.Policy{})
+	reg := prometheus.NewRegistry()
+	cfg := &config.Config{App: config.App{JWTSecret: "secret"}}
+	app := auth.NewService(repo, guard, nil, nil, nil, cfg, zap.NewNop(), metrics.New(reg))
+
+	wrong := &auth.UserLogin{Username: "test_username", Password: "wrong_pass"}
+	for i := 0; i < 2; i++ {
+		_, err := app.Login(clientContext(), wrong)
+		require.Equal(t, auth.ErrInvalidCredentials, err)
+	}
+
+	// The right password is not checked while the username must wait.
+	_, err := app.Login(clientContext(), &auth.UserLogin{Username: "Test_Username", Password: "secret_pass"})
+	var attemptsErr *auth.AttemptsError
+	if assert.ErrorAs(t, err, &attemptsErr) {
+		assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+		assert.InDelta(t, time.Minute, attemptsErr.RetryAfter, float64(time.Second))
+	}
+
+	expLogins := `
+		# HELP movierama_logins_total Number of login attempts by result.
+		# TYPE movierama_logins_total counter
+		movierama_logins_total{result="blocked"} 1
+		movierama_logins_total{result="denied"} 0
+		movierama_logins_total{result="failed"} 2
+		movierama_logins_total{result="succeeded"} 0
+	`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expLogins), "movierama_logins_total"))
+	repo.AssertExpectations(t)
+}
+
+func Test_Login_Lockout_Parallel(t *testing.T) {
+	repo := &sqluser.Mock{}
+	repo.On("GetUserAuthDetails", mock.Anything, "test_username").
+		Return(&user.AuthUserDetails{ID: 1, Username: "test_username", Password: secretPassHash}, nil)
+	repo.On("AddAuthEvent", mock.Anything, mock.Anything).Return(nil)
+
+	policy := bruteforce.Policy{MaxAttempts: 3, LockoutDuration: time.Hour}
+	guard := bruteforce.NewGuard(bruteforce.NewMemoryStore(), policy, bruteforce.Policy{})
+	cfg := &config.Config{App: config.App{JWTSecret: "secret"}}
+	app := auth.NewService(repo, guard, nil, nil, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+	// The parallel wrong logins are all counted before their passwords are checked.
+	var checked int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := app.Login(clientContext(), &auth.UserLogin{Username: "test_username", Password: "wrong_pass"})
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				atomic.AddInt32(&checked, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(policy.MaxAttempts), checked)
+
+	_, err := app.Login(clientContext(), &auth.UserLogin{Username: "test_username", Password: "secret_pass"})
+	var attemptsErr *auth.AttemptsError
+	if assert.ErrorAs(t, err, &attemptsErr) {
+		assert.InDelta(t, time.Hour, attemptsErr.RetryAfter, float64(time.Second))
+	}
+}
+
+func Test_Login_Role(t *testing.T) {
+	tests := map[string]struct {
+		role         string
+		adminUserIDs []int
+		expRole      auth.Role
+		expPromotion bool
+		promoted     bool
+	}{
+		"should carry the role in the token": {
+			role:    "moderator",
+			expRole: auth.RoleModerator,
+		},
+		"should promote an admin user id of the config": {
+			role:         "user",
+			adminUserIDs: []int{2, 1},
+			expRole:      auth.RoleAdmin,
+			expPromotion: true,
+			promoted:     true,
+		},
+		"should not promote an admin user id whose role was changed": {
+			role:         "user",
+			adminUserIDs: []int{1},
+			expRole:      auth.RoleUser,
+			expPromotion: true,
+		},
+		"should not promote another user id": {
+			role:         "user",
+			adminUserIDs: []int{2},
+			expRole:      auth.RoleUser,
+		},
+		"should not promote a moderator": {
+			role:         "moderator",
+			adminUserIDs: []int{1},
+			expRole:      auth.RoleModerator,
+		},
+		"should not promote an admin again": {
+			role:         "admin",
+			adminUserIDs: []int{1},
+			expRole:      auth.RoleAdmin,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo := &sqluser.Mock{}
+			repo.On("GetUserAuthDetails", mock.Anything, "test_username").Return(&user.AuthUserDetails{
+				ID: 1, Username: "test_username", Password: secretPassHash, Role: tt.role, Status: "active",
+			}, nil)
+			repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventLoginSucceeded, 1, "test_username")).Return(nil)
+			if tt.expPromotion {
+				repo.On("BootstrapRole", mock.Anything, 1, "admin").Return(tt.promoted, nil).Once()
+			}
+			cfg := &config.Config{App: config.App{JWTSecret: "secret", AdminUserIDs: tt.adminUserIDs}}
+			app := auth.NewService(repo, nil, nil, nil, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			res, err := app.Login(clientContext(), &auth.UserLogin{Username: "test_username", Password: "secret_pass"})
+			require.NoError(t, err)
+
+			claims := &auth.JwtCustomClaims{}
+			_, err = jwt.ParseWithClaims(res.Token, claims, func(*jwt.Token) (interface{}, error) {
+				return []byte("secret"), nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 1, claims.UserID)
+			assert.Equal(t, tt.expRole, claims.Role)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_Authenticate(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	dbErr := errors.New("db down")
+	tests := map[string]struct {
+		details *user.AuthUserDetails
+		repoErr error
+		claims  *auth.JwtCustomClaims
+		expErr  error
+	}{
+		"should accept an active user of the token role": {
+			details: &user.AuthUserDetails{ID: 1, Role: "moderator", Status: "active"},
+			claims:  &auth.JwtCustomClaims{UserID: 1, Role: auth.RoleModerator},
+		},
+		"should accept a token without role of a user": {
+			details: &user.AuthUserDetails{ID: 1, Role: "user", Status: "active"},
+			claims:  &auth.JwtCustomClaims{UserID: 1},
+		},
+		"should revoke the token of a deleted user": {
+			repoErr: sql.ErrNoRows,
+			claims:  &auth.JwtCustomClaims{UserID: 1},
+			expErr:  auth.ErrTokenRevoked,
+		},
+		"should revoke the token of a former role": {
+			details: &user.AuthUserDetails{ID: 1, Role: "user", Status: "active"},
+			claims:  &auth.JwtCustomClaims{UserID: 1, Role: auth.RoleAdmin},
+			expErr:  auth.ErrTokenRevoked,
+		},
+		"should accept a token of the current password": {
+			details: &user.AuthUserDetails{ID: 1, Role: "user", Status: "active", TokenVersion: 2},
+			claims:  &auth.JwtCustomClaims{UserID: 1, TokenVersion: 2},
+		},
+		"should revoke a token issued before a password change": {
+			details: &user.AuthUserDetails{ID: 1, Role: "user", Status: "active", TokenVersion: 2},
+			claims:  &auth.JwtCustomClaims{UserID: 1, TokenVersion: 1},
+			expErr:  auth.ErrTokenRevoked,
+		},
+		"should reject the token of a banned user": {
+			details: &user.AuthUserDetails{ID: 1, Role: "user", Status: "banned"},
+			claims:  &auth.JwtCustomClaims{UserID: 1},
+			expErr:  auth.ErrAccountBanned,
+		},
+		"should reject the token of a suspended user": {
+			details: &user.AuthUserDetails{ID: 1, Role: "user", Status: "suspended", SuspendedUntil: &until},
+			claims:  &auth.JwtCustomClaims{UserID: 1},
+			expErr:  auth.ErrAccountSuspended,
+		},
+		"should return the repository error": {
+			repoErr: dbErr,
+			claims:  &auth.JwtCustomClaims{UserID: 1},
+			expErr:  dbErr,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo := &sqluser.Mock{}
+			repo.On("GetUserAuthDetailsByID", mock.Anything, 1).Return(tt.details, tt.repoErr)
+			app := auth.NewService(repo, nil, nil, nil, nil, &config.Config{}, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			err := app.Authenticate(context.TODO(), tt.claims)
+			if tt.expErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expErr)
+			}
 		})
 	}
 }
@@ -80,7 +485,11 @@ func Test_Register(t *testing.T) {
 		"Should get public movies": {
 			sqlRepo: func() *sqluser.Mock {
 				repo := sqluser.Mock{}
-				repo.On("CreateUser", context.TODO(), mock.Anything).Return(nil)
+				repo.On("CreateUser", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+					id := 1
+					args.Get(1).(*user.SQLUser).ID = &id
+				})
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventRegistered, 1, "test_username")).Return(nil)
 
 				return &repo
 			}(),
@@ -92,10 +501,25 @@ func Test_Register(t *testing.T) {
 			},
 			expErr: nil,
 		},
+		"Should return error on taken username": {
+			sqlRepo: func() *sqluser.Mock {
+				repo := sqluser.Mock{}
+				repo.On("CreateUser", mock.Anything, mock.Anything).Return(sqluser.ErrDuplicateUsername)
+
+				return &repo
+			}(),
+			userRegister: &auth.UserRegister{
+				Username:  "test_username",
+				Password:  "test_password",
+				FirstName: "test_firstname",
+				LastName:  "test_lastname",
+			},
+			expErr: auth.ErrUsernameTaken,
+		},
 		"Should return error on repo error": {
 			sqlRepo: func() *sqluser.Mock {
 				repo := sqluser.Mock{}
-				repo.On("CreateUser", context.TODO(), mock.Anything).Return(errors.New("random error"))
+				repo.On("CreateUser", mock.Anything, mock.Anything).Return(errors.New("random error"))
 
 				return &repo
 			}(),
@@ -111,10 +535,91 @@ func Test_Register(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := auth.NewService(tt.sqlRepo, config.New())
+			app := auth.NewService(tt.sqlRepo, nil, nil, nil, nil, &config.Config{}, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			err := app.Register(clientContext(), tt.userRegister)
+			assert.Equal(t, tt.expErr, err)
+			tt.sqlRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_ChangePassword(t *testing.T) {
+	tests := map[string]struct {
+		sqlRepo *sqluser.Mock
+		change  *auth.PasswordChange
+		expErr  error
+	}{
+		"should change the password": {
+			sqlRepo: func() *sqluser.Mock {
+				repo := sqluser.Mock{}
+				repo.On("GetUserAuthDetailsByID", mock.Anything, 1).
+					Return(&user.AuthUserDetails{ID: 1, Username: "test_username", Password: secretPassHash}, nil)
+				repo.On("UpdatePassword", mock.Anything, 1, mock.MatchedBy(func(hash string) bool {
+					return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new_pass")) == nil
+				})).Return(nil)
+				repo.On("AddAuthEvent", mock.Anything, authEvent(auth.EventPasswordChange, 1, "test_username")).Return(nil)
+
+				return &repo
+			}(),
+			change: &auth.PasswordChange{CurrentPassword: "secret_pass", NewPassword: "new_pass"},
+		},
+		"should return invalid credentials on wrong current password": {
+			sqlRepo: func() *sqluser.Mock {
+				repo := sqluser.Mock{}
+				repo.On("GetUserAuthDetailsByID", mock.Anything, 1).
+					Return(&user.AuthUserDetails{ID: 1, Username: "test_username", Password: secretPassHash}, nil)
+
+				return &repo
+			}(),
+			change: &auth.PasswordChange{CurrentPassword: "wrong_pass", NewPassword: "new_pass"},
+			expErr: auth.ErrInvalidCredentials,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := auth.NewService(tt.sqlRepo, nil, nil, nil, nil, &config.Config{}, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
-			err := app.Register(context.TODO(), tt.userRegister)
+			err := app.ChangePassword(clientContext(), 1, tt.change)
 			assert.Equal(t, tt.expErr, err)
+			tt.sqlRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_GetEvents(t *testing.T) {
+	userID := 1
+	createdAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		filter    auth.EventFilter
+		expFilter user.AuthEventFilter
+	}{
+		"should default the limit": {
+			filter:    auth.EventFilter{UserID: 1},
+			expFilter: user.AuthEventFilter{UserID: 1, Limit: auth.DefaultEventLimit},
+		},
+		"should cap the limit": {
+			filter:    auth.EventFilter{UserID: 1, BeforeID: 10, Limit: 1000},
+			expFilter: user.AuthEventFilter{UserID: 1, BeforeID: 10, Limit: auth.MaxEventLimit},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo := &sqluser.Mock{}
+			repo.On("GetAuthEvents", mock.Anything, tt.expFilter).Return([]user.AuthEvent{
+				{ID: 2, UserID: &userID, Username: "test_username", Event: auth.EventLoginSucceeded, IP: "192.0.2.1", CreatedAt: createdAt},
+				{ID: 1, Username: "unknown", Event: auth.EventLoginFailed, IP: "192.0.2.1", CreatedAt: createdAt},
+			}, nil)
+			app := auth.NewService(repo, nil, nil, nil, nil, &config.Config{}, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			events, err := app.GetEvents(context.TODO(), tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, []auth.Event{
+				{ID: 2, UserID: 1, Username: "test_username", Event: auth.EventLoginSucceeded, IP: "192.0.2.1", CreatedAt: createdAt},
+				{ID: 1, Username: "unknown", Event: auth.EventLoginFailed, IP: "192.0.2.1", CreatedAt: createdAt},
+			}, events)
 		})
 	}
 }
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"movierama/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "movierama/internal/app/auth"
+
+type tracedService struct {
+	svc    Service
+	tracer trace.Tracer
+}
+
+// NewTracedService wraps svc to record a span per method call.
+func NewTracedService(svc Service, tp trace.TracerProvider) Service {
+	return &tracedService{
+		svc:    svc,
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+func (s *tracedService) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "auth.Service/"+method, trace.WithAttributes(attrs...))
+}
+
+// Login function.
+func (s *tracedService) Login(ctx context.Context, user *UserLogin) (res *LoginRes, err error) {
+	ctx, span := s.start(ctx, "Login", attribute.String("auth.username", user.Username))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.Login(ctx, user)
+}
+
+// Register function.
+func (s *tracedService) Register(ctx context.Context, registerUser *UserRegister) (err error) {
+	ctx, span := s.start(ctx, "Register", attribute.String("auth.username", registerUser.Username))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.Register(ctx, registerUser)
+}
+
+// ChangePassword function.
+func (s *tracedService) ChangePassword(ctx context.Context, userID int, change *PasswordChange) (err error) {
+	ctx, span := s.start(ctx, "ChangePassword", attribute.Int("auth.user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ChangePassword(ctx, userID, change)
+}
+
+// GetUsers function.
+func (s *tracedService) GetUsers(ctx context.Context, userIDs []int) (res []User, err error) {
+	ctx, span := s.start(ctx, "GetUsers", attribute.Int("auth.user_count", len(userIDs)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetUsers(ctx, userIDs)
+}
+
+// GetEvents function.
+func (s *tracedService) GetEvents(ctx context.Context, filter EventFilter) (res []Event, err error) {
+	ctx, span := s.start(ctx, "GetEvents", attribute.Int("auth.user_id", filter.UserID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetEvents(ctx, filter)
+}
+
+// Authenticate function.
+func (s *tracedService) Authenticate(ctx context.Context, claims *JwtCustomClaims) (err error) {
+	ctx, span := s.start(ctx, "Authenticate", attribute.Int("auth.user_id", claims.UserID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.Authenticate(ctx, claims)
+}
+
+// Providers function.
+func (s *tracedService) Providers(ctx context.Context) []string {
+	ctx, span := s.start(ctx, "Providers")
+	defer span.End()
+
+	return s.svc.Providers(ctx)
+}
+
+// AuthorizeOIDC function.
+func (s *tracedService) AuthorizeOIDC(ctx context.Context, provider string, userID int) (res *AuthorizationRes, err error) {
+	ctx, span := s.start(ctx, "AuthorizeOIDC", attribute.String("auth.provider", provider), attribute.Int("auth.user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.AuthorizeOIDC(ctx, provider, userID)
+}
+
+// LoginOIDC function.
+func (s *tracedService) LoginOIDC(ctx context.Context, callback *OIDCCallback) (res *LoginRes, err error) {
+	ctx, span := s.start(ctx, "LoginOIDC")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.LoginOIDC(ctx, callback)
+}
+
+// VerifyEmail function.
+func (s *tracedService) VerifyEmail(ctx context.Context, verification *EmailVerification) (err error) {
+	ctx, span := s.start(ctx, "VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.VerifyEmail(ctx, verification)
+}
+
+// ResendVerification function.
+func (s *tracedService) ResendVerification(ctx context.Context, req *EmailRequest) (err error) {
+	ctx, span := s.start(ctx, "ResendVerification")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ResendVerification(ctx, req)
+}
+
+// ChangeEmail function.
+func (s *tracedService) ChangeEmail(ctx context.Context, userID int, req *EmailRequest) (err error) {
+	ctx, span := s.start(ctx, "ChangeEmail", attribute.Int("auth.user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ChangeEmail(ctx, userID, req)
+}
+
+// ForgotPassword function.
+func (s *tracedService) ForgotPassword(ctx context.Context, req *EmailRequest) (err error) {
+	ctx, span := s.start(ctx, "ForgotPassword")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ForgotPassword(ctx, req)
+}
+
+// ResetPassword function.
+func (s *tracedService) ResetPassword(ctx context.Context, reset *PasswordReset) (err error) {
+	ctx, span := s.start(ctx, "ResetPassword")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.ResetPassword(ctx, reset)
+}
+
+// CreateAccessToken function.
+func (s *tracedService) CreateAccessToken(ctx context.Context, userID int, req *AccessTokenRequest) (res *NewAccessToken, err error) {
+	ctx, span := s.start(ctx, "CreateAccessToken", attribute.Int("auth.user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.CreateAccessToken(ctx, userID, req)
+}
+
+// GetAccessTokens function.
+func (s *tracedService) GetAccessTokens(ctx context.Context, userID int) (res []AccessToken, err error) {
+	ctx, span := s.start(ctx, "GetAccessTokens", attribute.Int("auth.user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetAccessTokens(ctx, userID)
+}
+
+// RevokeAccessToken function.
+func (s *tracedService) RevokeAccessToken(ctx context.Context, userID, tokenID int) (err error) {
+	ctx, span := s.start(ctx, "RevokeAccessToken", attribute.Int("auth.user_id", userID),
+		attribute.Int("auth.token_id", tokenID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.RevokeAccessToken(ctx, userID, tokenID)
+}
+
+// AuthenticateAccessToken function.
+func (s *tracedService) AuthenticateAccessToken(ctx context.Context, token string) (res *JwtCustomClaims, err error) {
+	ctx, span := s.start(ctx, "AuthenticateAccessToken")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.AuthenticateAccessToken(ctx, token)
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/logging"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// AccessTokenPrefix starts the personal access tokens, it tells them apart from the JWTs.
+const AccessTokenPrefix = "mrpat_"
+
+const (
+	// accessTokenBytes is the number of random bytes of a personal access token.
+	accessTokenBytes = 32
+	// maxTokenNameLength is the length of the name column.
+	maxTokenNameLength = 64
+	// maxAccessTokens is the number of personal access tokens of a user.
+	maxAccessTokens = 20
+	// lastUsedPrecision is the period within which the uses of a token are not recorded again, so that a
+	// busy script does not write on every request.
+	lastUsedPrecision = time.Minute
+)
+
+// IsAccessToken reports whether the bearer token is a personal access token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// hashToken returns the stored hash of a personal access token. The tokens are random, a hash without
+// salt cannot be reversed and finds the token in one lookup.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func scopeList() string {
+	names := make([]string, len(Scopes))
+	for i, s := range Scopes {
+		names[i] = string(s)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// joinScopes returns the stored scopes, each scope once.
+func joinScopes(scopes []Scope) string {
+	var names []string
+	seen := make(map[Scope]bool)
+	for _, s := range scopes {
+		if !seen[s] {
+			seen[s] = true
+			names = append(names, string(s))
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+// splitScopes returns the scopes of the stored list.
+func splitScopes(scopes string) []Scope {
+	var res []Scope
+	for _, s := range strings.Split(scopes, ",") {
+		if s != "" {
+			res = append(res, Scope(s))
+		}
+	}
+
+	return res
+}
+
+func toAccessToken(t *user.AccessToken) AccessToken {
+	return AccessToken{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     splitScopes(t.Scopes),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// CreateAccessToken creates a personal access token of the user, only its hash is stored.
+func (a authService) CreateAccessToken(ctx context.Context, userID int, req *AccessTokenRequest) (*NewAccessToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxTokenNameLength {
+		return nil, ErrInvalidTokenName
+	}
+	if len(req.Scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	for _, s := range req.Scopes {
+		if !s.Valid() {
+			return nil, ErrInvalidScope
+		}
+	}
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, ErrInvalidExpiry
+	}
+
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := a.ur.GetAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) >= maxAccessTokens {
+		return nil, ErrTooManyTokens
+	}
+
+	b := make([]byte, accessTokenBytes)
+	if _, err = rand.Read(b); err != nil {
+		return nil, err
+	}
+	secret := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	stored := &user.AccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(secret),
+		Scopes:    joinScopes(req.Scopes),
+		CreatedAt: now.Truncate(time.Second),
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		stored.ExpiresAt = &expiresAt
+	}
+	if err = a.ur.AddAccessToken(ctx, stored); err != nil {
+		return nil, err
+	}
+	logging.For(ctx, a.log).Info("access token created", zap.Int("user_id", userID), zap.Int("token_id", stored.ID),
+		zap.String("scopes", stored.Scopes))
+	a.audit(ctx, EventAccessTokenCreated, userID, dbUser.Username)
+
+	return &NewAccessToken{AccessToken: toAccessToken(stored), Token: secret}, nil
+}
+
+// GetAccessTokens returns the personal access tokens of the user, without their secrets.
+func (a authService) GetAccessTokens(ctx context.Context, userID int) ([]AccessToken, error) {
+	tokens, err := a.ur.GetAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]AccessToken, len(tokens))
+	for i := range tokens {
+		res[i] = toAccessToken(&tokens[i])
+	}
+
+	return res, nil
+}
+
+// RevokeAccessToken deletes a personal access token of the user, it stops working at once.
+func (a authService) RevokeAccessToken(ctx context.Context, userID, tokenID int) error {
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = a.ur.DeleteAccessToken(ctx, userID, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return err
+	}
+	logging.For(ctx, a.log).Info("access token revoked", zap.Int("user_id", userID), zap.Int("token_id", tokenID))
+	a.audit(ctx, EventAccessTokenRevoked, userID, dbUser.Username)
+
+	return nil
+}
+
+// AuthenticateAccessToken returns the claims of a personal access token that exists and has not expired,
+// with the current role of its user, who must not be suspended or banned. The use of the token is recorded.
+func (a authService) AuthenticateAccessToken(ctx context.Context, token string) (*JwtCustomClaims, error) {
+	log := logging.For(ctx, a.log)
+	if !IsAccessToken(token) {
+		return nil, ErrInvalidAccessToken
+	}
+	t, err := a.ur.GetAccessTokenByHash(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("access token rejected", zap.String("reason", "unknown token"))
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	log = log.With(zap.Int("user_id", t.UserID), zap.Int("token_id", t.ID))
+	now := time.Now()
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		log.Info("access token rejected", zap.String("reason", "expired"))
+		return nil, ErrInvalidAccessToken
+	}
+
+	dbUser, err := a.ur.GetUserAuthDetailsByID(ctx, t.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = AccountError(dbUser.Status, dbUser.SuspendedUntil, now); err != nil {
+		return nil, err
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= lastUsedPrecision {
+		// A failure to record the use does not deny the request.
+		if err = a.ur.TouchAccessToken(ctx, t.ID, now.UTC()); err != nil {
+			log.Error("record access token use", zap.Error(err))
+		}
+	}
+
+	return &JwtCustomClaims{
+		UserID:        t.UserID,
+		Role:          Role(dbUser.Role),
+		AccessTokenID: t.ID,
+		Scopes:        splitScopes(t.Scopes),
+	}, nil
+}
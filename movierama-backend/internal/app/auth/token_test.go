@@ -0,0 +1,162 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/app/auth"
+	"movierama/internal/config"
+	"movierama/internal/infra/repository/memory"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/internal/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func newTokenService(repo auth.Repository) auth.Service {
+	cfg := &config.Config{App: config.App{JWTSecret: "secret"}}
+
+	return auth.NewService(repo, nil, nil, nil, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+}
+
+func Test_CreateAccessToken(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	tests := map[string]struct {
+		req    *auth.AccessTokenRequest
+		expErr error
+	}{
+		"should create a token": {
+			req: &auth.AccessTokenRequest{Name: " bot ", Scopes: []auth.Scope{auth.ScopeMoviesWrite, auth.ScopeMoviesWrite}},
+		},
+		"should reject a blank name": {
+			req:    &auth.AccessTokenRequest{Name: " ", Scopes: []auth.Scope{auth.ScopeMoviesWrite}},
+			expErr: auth.ErrInvalidTokenName,
+		},
+		"should reject a long name": {
+			req:    &auth.AccessTokenRequest{Name: strings.Repeat("a", 65), Scopes: []auth.Scope{auth.ScopeMoviesWrite}},
+			expErr: auth.ErrInvalidTokenName,
+		},
+		"should reject a token without scope": {
+			req:    &auth.AccessTokenRequest{Name: "bot"},
+			expErr: auth.ErrInvalidScope,
+		},
+		"should reject an unknown scope": {
+			req:    &auth.AccessTokenRequest{Name: "bot", Scopes: []auth.Scope{auth.ScopeMoviesWrite, "admin"}},
+			expErr: auth.ErrInvalidScope,
+		},
+		"should reject a past expiry": {
+			req:    &auth.AccessTokenRequest{Name: "bot", Scopes: []auth.Scope{auth.ScopeMoviesWrite}, ExpiresAt: &past},
+			expErr: auth.ErrInvalidExpiry,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := newTokenService(memory.NewRepository())
+			register(t, app, "alice", "")
+
+			res, err := app.CreateAccessToken(clientContext(), 1, tt.req)
+			if tt.expErr != nil {
+				assert.ErrorIs(t, err, tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, auth.IsAccessToken(res.Token))
+			assert.Equal(t, "bot", res.Name)
+			assert.Equal(t, []auth.Scope{auth.ScopeMoviesWrite}, res.Scopes, "each scope is kept once")
+			assert.Nil(t, res.ExpiresAt)
+		})
+	}
+}
+
+func Test_AuthenticateAccessToken(t *testing.T) {
+	repo := memory.NewRepository()
+	app := newTokenService(repo)
+	register(t, app, "alice", "")
+	register(t, app, "bob", "")
+	expiresAt := time.Now().Add(time.Hour)
+	res, err := app.CreateAccessToken(clientContext(), 1, &auth.AccessTokenRequest{
+		Name: "bot", Scopes: []auth.Scope{auth.ScopeMoviesWrite, auth.ScopeVotesWrite}, ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+
+	claims, err := app.AuthenticateAccessToken(clientContext(), res.Token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claims.UserID)
+	assert.Equal(t, auth.RoleUser, claims.UserRole())
+	assert.True(t, claims.Allows(auth.ScopeVotesWrite))
+	assert.False(t, claims.Allows(auth.ScopeReportsWrite))
+	assert.True(t, (&auth.JwtCustomClaims{UserID: 1}).Allows(auth.ScopeReportsWrite), "a JWT is granted every scope")
+
+	tokens, err := app.GetAccessTokens(clientContext(), 1)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, res.ID, tokens[0].ID)
+	assert.NotNil(t, tokens[0].LastUsedAt, "the use is recorded")
+	stored, err := repo.GetAccessTokens(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.NotContains(t, stored[0].TokenHash, strings.TrimPrefix(res.Token, auth.AccessTokenPrefix), "the token is hashed")
+
+	_, err = app.AuthenticateAccessToken(clientContext(), res.Token+"x")
+	assert.ErrorIs(t, err, auth.ErrInvalidAccessToken)
+	_, err = app.AuthenticateAccessToken(clientContext(), "eyJhbGciOiJIUzI1NiJ9")
+	assert.ErrorIs(t, err, auth.ErrInvalidAccessToken)
+
+	// The tokens of a suspended user stop working.
+	require.NoError(t, repo.UpdateStatus(context.TODO(), 1, auth.StatusSuspended, nil))
+	_, err = app.AuthenticateAccessToken(clientContext(), res.Token)
+	assert.ErrorIs(t, err, auth.ErrAccountSuspended)
+	require.NoError(t, repo.UpdateStatus(context.TODO(), 1, auth.StatusActive, nil))
+
+	// A token is revoked by its user only, and stops working at once.
+	assert.ErrorIs(t, app.RevokeAccessToken(clientContext(), 2, res.ID), auth.ErrTokenNotFound)
+	require.NoError(t, app.RevokeAccessToken(clientContext(), 1, res.ID))
+	_, err = app.AuthenticateAccessToken(clientContext(), res.Token)
+	assert.ErrorIs(t, err, auth.ErrInvalidAccessToken)
+
+	events, err := repo.GetAuthEvents(context.TODO(), user.AuthEventFilter{UserID: 1, Limit: 10})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(events), 2)
+	assert.Equal(t, auth.EventAccessTokenRevoked, events[0].Event)
+	assert.Equal(t, auth.EventAccessTokenCreated, events[1].Event)
+}
+
+func Test_AuthenticateAccessToken_Expired(t *testing.T) {
+	repo := memory.NewRepository()
+	app := newTokenService(repo)
+	register(t, app, "alice", "")
+	res, err := app.CreateAccessToken(clientContext(), 1, &auth.AccessTokenRequest{
+		Name: "bot", Scopes: []auth.Scope{auth.ScopeMoviesRead},
+	})
+	require.NoError(t, err)
+	tokens, err := repo.GetAccessTokens(context.TODO(), 1)
+	require.NoError(t, err)
+
+	// Store a copy of the token that expired a moment ago.
+	expired := tokens[0]
+	expiresAt := time.Now().Add(-time.Second)
+	expired.ExpiresAt = &expiresAt
+	require.NoError(t, repo.DeleteAccessToken(context.TODO(), 1, res.ID))
+	require.NoError(t, repo.AddAccessToken(context.TODO(), &expired))
+
+	_, err = app.AuthenticateAccessToken(clientContext(), res.Token)
+	assert.ErrorIs(t, err, auth.ErrInvalidAccessToken)
+}
+
+func Test_CreateAccessToken_Limit(t *testing.T) {
+	app := newTokenService(memory.NewRepository())
+	register(t, app, "alice", "")
+	req := &auth.AccessTokenRequest{Name: "bot", Scopes: []auth.Scope{auth.ScopeMoviesRead}}
+	for i := 0; i < 20; i++ {
+		_, err := app.CreateAccessToken(clientContext(), 1, req)
+		require.NoError(t, err)
+	}
+
+	_, err := app.CreateAccessToken(clientContext(), 1, req)
+	assert.ErrorIs(t, err, auth.ErrTooManyTokens)
+}
@@ -1,24 +1,49 @@
 package movie
 
+import "time"
+
 // Movie contains the movie data.
 type Movie struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	UserID      int    `json:"user_id"`
-	PostedBy    string `json:"posted_by"`
-	Likes       int    `json:"likes"`
-	Hates       int    `json:"hates"`
-	UserLiked   bool   `json:"user_liked"`
-	UserHated   bool   `json:"user_hated"`
-	IsSameUser  bool   `json:"is_same_user"`
-	TimeAgo     string `json:"time_ago"`
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	UserID      int       `json:"user_id"`
+	PostedBy    string    `json:"posted_by"`
+	Likes       int       `json:"likes"`
+	Hates       int       `json:"hates"`
+	UserLiked   bool      `json:"user_liked"`
+	UserHated   bool      `json:"user_hated"`
+	IsSameUser  bool      `json:"is_same_user"`
+	TimeAgo     string    `json:"time_ago"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // NewMovie contains the new movie data.
 type NewMovie struct {
 	Title       string
 	Description string
+	// Confirm creates the movie even though its title looks like the title of an existing movie.
+	Confirm bool
+}
+
+// Duplicate is an existing movie whose title looks like the title of a new movie.
+type Duplicate struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// Change contains the number of the movies of a scope and the time of the last write of one of them or of their
+// votes, a write moves the time and a deletion changes the number. The responses of the movies are cached by it.
+type Change struct {
+	Movies    int
+	ChangedAt time.Time
+}
+
+// ChangeScope selects the movies of a change, the zero scope selects every movie.
+type ChangeScope struct {
+	// UserID selects the movies submitted by the user.
+	UserID  int
+	MovieID int
 }
 
 // GetmoviesRes contains the response of get movies.
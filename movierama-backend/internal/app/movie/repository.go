@@ -2,7 +2,9 @@ package movie
 
 import (
 	"context"
+	"movierama/internal/infra/repository/sql/moderation"
 	moviesql "movierama/internal/infra/repository/sql/movie"
+	"time"
 )
 
 // Repository should be able to manage the movies.
@@ -11,7 +13,19 @@ type Repository interface {
 	GetMovies(ctx context.Context, authUsrID int, sortType string) ([]moviesql.Movie, error)
 	GetUserMovies(ctx context.Context, userID, authUsrID int, sortType string) ([]moviesql.Movie, error)
 	GetUserMoviesPublic(ctx context.Context, userID int, sortType string) ([]moviesql.Movie, error)
+	GetMovie(ctx context.Context, movieID, authUsrID int) (*moviesql.Movie, error)
 	CreateMovie(ctx context.Context, movie *moviesql.SQLMovie) error
 	AddMovieAction(ctx context.Context, movieID, userID int, action string) error
 	RemoveMovieAction(ctx context.Context, movieID, userID int, action string) error
+	SetMovieAction(ctx context.Context, movieID, userID int, action string) (string, error)
+	CountUserMoviesSince(ctx context.Context, userID int, since time.Time) (int, error)
+	GetSimilarTitles(ctx context.Context, key string) ([]moviesql.Title, error)
+	SetTitleKeys(ctx context.Context, keys map[int]string) error
+	GetChange(ctx context.Context, authUsrID int, scope moviesql.ChangeScope) (*moviesql.Change, error)
+}
+
+// ModerationRepository should be able to queue the flagged movies for moderation.
+type ModerationRepository interface {
+	AddReport(ctx context.Context, report *moderation.Report) error
+	AddAction(ctx context.Context, action *moderation.Action) error
 }
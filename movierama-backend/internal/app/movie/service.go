@@ -2,9 +2,16 @@ package movie
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"movierama/internal/contentfilter"
+	"movierama/internal/infra/repository/sql/moderation"
 	sqlmovie "movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/locale"
+	"movierama/internal/logging"
+	"movierama/internal/metrics"
 
-	"github.com/xeonx/timeago"
+	"go.uber.org/zap"
 )
 
 // AuthUserIDContextKey contains the context key for the user id.
@@ -13,25 +20,70 @@ const AuthUserIDContextKey = UserIDContextKey("auth_user_id")
 // UserIDContextKey contains the type of user id context key.
 type UserIDContextKey string
 
+// Movie actions.
+const (
+	ActionLike = "like"
+	ActionHate = "hate"
+)
+
+// ModerationFlagMovie is the moderation action recorded for a movie flagged by the content filter, its
+// moderator id is zero.
+const ModerationFlagMovie = "flag_movie"
+
+// Movie errors.
+var (
+	ErrMovieNotFound = errors.New("movie not found")
+	ErrInvalidAction = errors.New("invalid movie action")
+	ErrOwnMovie      = errors.New("users cannot vote for the movies they have submitted")
+)
+
+// DuplicateError is returned for a new movie whose title looks like the titles of existing movies, unless the
+// movie is confirmed.
+type DuplicateError struct {
+	Movies []Duplicate
+}
+
+// Error describes the error.
+func (e *DuplicateError) Error() string {
+	return "the movie may already exist"
+}
+
 // Service handles movie information.
 type Service interface {
 	GetMoviesPublic(ctx context.Context, sortType string) (*GetmoviesRes, error)
 	GetMovies(ctx context.Context, sortType string) (*GetmoviesRes, error)
 	GetUserMovies(ctx context.Context, userID int, sortType string) (*GetmoviesRes, error)
 	GetUserMoviesPublic(ctx context.Context, userID int, sortType string) (*GetmoviesRes, error)
+	GetMovie(ctx context.Context, movieID int) (*Movie, error)
+	GetChange(ctx context.Context, scope ChangeScope) (*Change, error)
 	CreateMovie(ctx context.Context, movie NewMovie) error
 	Action(ctx context.Context, movieID int, action string) error
 	RemoveAction(ctx context.Context, movieID int, action string) error
+	Vote(ctx context.Context, movieID int, action string) error
+}
+
+// Filter checks the submitted movies, it rejects a movie with a *contentfilter.RejectedError.
+type Filter interface {
+	Check(ctx context.Context, s *contentfilter.Submission) (contentfilter.Result, error)
 }
 
 type movieService struct {
-	mr Repository
+	mr      Repository
+	mdr     ModerationRepository
+	filter  Filter
+	log     *zap.Logger
+	metrics *metrics.Metrics
 }
 
-// NewService constructor.
-func NewService(movieRepo Repository) Service {
+// NewService constructor, a nil filter accepts every movie.
+func NewService(movieRepo Repository, moderationRepo ModerationRepository, filter Filter, logger *zap.Logger,
+	m *metrics.Metrics) Service {
 	return &movieService{
-		mr: movieRepo,
+		mr:      movieRepo,
+		mdr:     moderationRepo,
+		filter:  filter,
+		log:     logger,
+		metrics: m,
 	}
 }
 
@@ -44,6 +96,7 @@ func (a movieService) GetMoviesPublic(ctx context.Context, sortType string) (*Ge
 	}
 
 	res := &GetmoviesRes{}
+	l := locale.FromContext(ctx)
 	for _, movie := range movies {
 		m := Movie{
 			ID:          movie.ID,
@@ -55,7 +108,8 @@ func (a movieService) GetMoviesPublic(ctx context.Context, sortType string) (*Ge
 			Hates:       movie.Hates,
 			UserLiked:   movie.UserLiked,
 			UserHated:   movie.UserHated,
-			TimeAgo:     timeago.English.Format(movie.CreatedAt),
+			TimeAgo:     l.TimeAgo(movie.CreatedAt),
+			CreatedAt:   movie.CreatedAt.UTC(),
 		}
 		res.Movies = append(res.Movies, m)
 	}
@@ -73,6 +127,7 @@ func (a movieService) GetMovies(ctx context.Context, sortType string) (*Getmovie
 	}
 
 	res := &GetmoviesRes{}
+	l := locale.FromContext(ctx)
 	for _, movie := range movies {
 		m := Movie{
 			ID:          movie.ID,
@@ -85,7 +140,8 @@ func (a movieService) GetMovies(ctx context.Context, sortType string) (*Getmovie
 			UserLiked:   movie.UserLiked,
 			UserHated:   movie.UserHated,
 			IsSameUser:  movie.UserID == authUserID,
-			TimeAgo:     timeago.English.Format(movie.CreatedAt),
+			TimeAgo:     l.TimeAgo(movie.CreatedAt),
+			CreatedAt:   movie.CreatedAt.UTC(),
 		}
 		res.Movies = append(res.Movies, m)
 	}
@@ -102,6 +158,7 @@ func (a movieService) GetUserMovies(ctx context.Context, userID int, sortType st
 	}
 
 	res := &GetmoviesRes{}
+	l := locale.FromContext(ctx)
 	for _, movie := range movies {
 		m := Movie{
 			ID:          movie.ID,
@@ -114,7 +171,8 @@ func (a movieService) GetUserMovies(ctx context.Context, userID int, sortType st
 			UserLiked:   movie.UserLiked,
 			UserHated:   movie.UserHated,
 			IsSameUser:  movie.UserID == authUserID,
-			TimeAgo:     timeago.English.Format(movie.CreatedAt),
+			TimeAgo:     l.TimeAgo(movie.CreatedAt),
+			CreatedAt:   movie.CreatedAt.UTC(),
 		}
 		res.Movies = append(res.Movies, m)
 	}
@@ -130,6 +188,7 @@ func (a movieService) GetUserMoviesPublic(ctx context.Context, userID int, sortT
 	}
 
 	res := &GetmoviesRes{}
+	l := locale.FromContext(ctx)
 	for _, movie := range movies {
 		m := Movie{
 			ID:          movie.ID,
@@ -141,7 +200,8 @@ func (a movieService) GetUserMoviesPublic(ctx context.Context, userID int, sortT
 			Hates:       movie.Hates,
 			UserLiked:   movie.UserLiked,
 			UserHated:   movie.UserHated,
-			TimeAgo:     timeago.English.Format(movie.CreatedAt),
+			TimeAgo:     l.TimeAgo(movie.CreatedAt),
+			CreatedAt:   movie.CreatedAt.UTC(),
 		}
 		res.Movies = append(res.Movies, m)
 	}
@@ -149,23 +209,142 @@ func (a movieService) GetUserMoviesPublic(ctx context.Context, userID int, sortT
 	return res, err
 }
 
-// CreateMovie function.
+// GetMovie function, the auth user is optional.
+func (a movieService) GetMovie(ctx context.Context, movieID int) (*Movie, error) {
+	authUserID, _ := ctx.Value(AuthUserIDContextKey).(int)
+
+	movie, err := a.mr.GetMovie(ctx, movieID, authUserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMovieNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Movie{
+		ID:          movie.ID,
+		Title:       movie.Title,
+		Description: movie.Description,
+		UserID:      movie.UserID,
+		PostedBy:    movie.PostedBy,
+		Likes:       movie.Likes,
+		Hates:       movie.Hates,
+		UserLiked:   movie.UserLiked,
+		UserHated:   movie.UserHated,
+		IsSameUser:  authUserID != 0 && movie.UserID == authUserID,
+		TimeAgo:     locale.FromContext(ctx).TimeAgo(movie.CreatedAt),
+		CreatedAt:   movie.CreatedAt.UTC(),
+	}, nil
+}
+
+// GetChange function, the auth user is optional. It is cheap next to the movies of scope, whose responses it
+// versions.
+func (a movieService) GetChange(ctx context.Context, scope ChangeScope) (*Change, error) {
+	authUserID, _ := ctx.Value(AuthUserIDContextKey).(int)
+
+	change, err := a.mr.GetChange(ctx, authUserID, sqlmovie.ChangeScope{UserID: scope.UserID, MovieID: scope.MovieID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Change{Movies: change.Movies, ChangedAt: change.ChangedAt.UTC()}, nil
+}
+
+// CreateMovie function, the movies rejected by the content filter are not created and the flagged ones are
+// queued for moderation. A movie whose title looks like the title of an existing movie is created once confirmed.
 func (a movieService) CreateMovie(ctx context.Context, movie NewMovie) error {
 	authUserID := ctx.Value(AuthUserIDContextKey).(int)
-	println(ctx)
+	verdict := contentfilter.Result{Verdict: contentfilter.Allow}
+	if a.filter != nil {
+		var err error
+		verdict, err = a.filter.Check(ctx, &contentfilter.Submission{
+			UserID:      authUserID,
+			Title:       movie.Title,
+			Description: movie.Description,
+		})
+		var rejected *contentfilter.RejectedError
+		if errors.As(err, &rejected) {
+			logging.For(ctx, a.log).Info("movie rejected", zap.Int("user_id", authUserID), zap.String("title", movie.Title),
+				zap.String("rule", rejected.Rule), zap.String("reason", rejected.Reason))
+			a.metrics.MovieFiltered(rejected.Rule, contentfilter.Reject.String())
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+	key := NormalizeTitle(movie.Title)
+	if !movie.Confirm && key != "" {
+		dups, err := a.duplicates(ctx, key)
+		if err != nil {
+			return err
+		}
+		if len(dups) > 0 {
+			logging.For(ctx, a.log).Info("movie duplicate suspected", zap.Int("user_id", authUserID),
+				zap.String("title", movie.Title), zap.Int("duplicate_id", dups[0].ID))
+			return &DuplicateError{Movies: dups}
+		}
+	}
+
 	m := &sqlmovie.SQLMovie{
 		UserID:      authUserID,
 		Title:       movie.Title,
 		Description: movie.Description,
+		TitleKey:    key,
 	}
 	err := a.mr.CreateMovie(ctx, m)
 	if err != nil {
 		return err
 	}
+	logging.For(ctx, a.log).Info("movie created", zap.Int("user_id", authUserID), zap.String("title", movie.Title))
+	a.metrics.MovieCreated()
+	if verdict.Verdict == contentfilter.Flag {
+		a.flag(ctx, m, verdict)
+	}
 
 	return nil
 }
 
+// duplicates returns the movies whose titles look like the title of key, among the titles sharing an end of
+// key. The keys of the titles created before the keys are stored along the way.
+func (a movieService) duplicates(ctx context.Context, key string) ([]Duplicate, error) {
+	titles, err := a.mr.GetSimilarTitles(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	dups, unkeyed := likelyDuplicates(key, titles)
+	if len(unkeyed) > 0 {
+		if err = a.mr.SetTitleKeys(ctx, unkeyed); err != nil {
+			logging.For(ctx, a.log).Error("set title keys", zap.Error(err))
+		}
+	}
+
+	return dups, nil
+}
+
+// flag queues a created movie for moderation, the movie is kept when it cannot be queued.
+func (a movieService) flag(ctx context.Context, m *sqlmovie.SQLMovie, verdict contentfilter.Result) {
+	a.metrics.MovieFiltered(verdict.Rule, contentfilter.Flag.String())
+	err := a.mdr.AddReport(ctx, &moderation.Report{MovieID: *m.ID, Source: moderation.SourceFilter, Category: verdict.Category})
+	if err == nil {
+		err = a.mdr.AddAction(ctx, &moderation.Action{
+			Action:     ModerationFlagMovie,
+			UserID:     &m.UserID,
+			MovieID:    m.ID,
+			MovieTitle: m.Title,
+			Detail:     verdict.Rule,
+			Reason:     verdict.Reason,
+		})
+	}
+	log := logging.For(ctx, a.log).With(zap.Int("movie_id", *m.ID), zap.String("rule", verdict.Rule),
+		zap.String("reason", verdict.Reason))
+	if err != nil {
+		log.Error("flagged movie not queued", zap.Error(err))
+		return
+	}
+	log.Info("movie flagged")
+}
+
 // Action function.
 func (a movieService) Action(ctx context.Context, movieID int, action string) error {
 	authUserID := ctx.Value(AuthUserIDContextKey).(int)
@@ -174,6 +353,7 @@ func (a movieService) Action(ctx context.Context, movieID int, action string) er
 	if err != nil {
 		return err
 	}
+	a.metrics.VoteCast(action)
 
 	return nil
 }
@@ -188,3 +368,33 @@ func (a movieService) RemoveAction(ctx context.Context, movieID int, action stri
 
 	return nil
 }
+
+// Vote sets the auth user vote for a movie replacing any previous one, an empty action retracts the vote.
+func (a movieService) Vote(ctx context.Context, movieID int, action string) error {
+	authUserID := ctx.Value(AuthUserIDContextKey).(int)
+	if action != "" && action != ActionLike && action != ActionHate {
+		return ErrInvalidAction
+	}
+
+	previous, err := a.mr.SetMovieAction(ctx, movieID, authUserID, action)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrMovieNotFound
+	case errors.Is(err, sqlmovie.ErrOwnMovie):
+		logging.For(ctx, a.log).Info("vote rejected", zap.Int("user_id", authUserID), zap.Int("movie_id", movieID),
+			zap.String("reason", "own movie"))
+		return ErrOwnMovie
+	case err != nil:
+		return err
+	}
+	if previous == action {
+		return nil
+	}
+	if action != "" {
+		a.metrics.VoteCast(action)
+	}
+	logging.For(ctx, a.log).Debug("vote recorded", zap.Int("user_id", authUserID), zap.Int("movie_id", movieID),
+		zap.String("action", action), zap.String("previous", previous))
+
+	return nil
+}
@@ -38,6 +38,20 @@ func (m *SvcMock) GetUserMoviesPublic(ctx context.Context, userID int, sortType
 	return args.Get(0).(*GetmoviesRes), args.Error(1)
 }
 
+// GetMovie mock.
+func (m *SvcMock) GetMovie(ctx context.Context, movieID int) (*Movie, error) {
+	args := m.MethodCalled("GetMovie", ctx, movieID)
+
+	return args.Get(0).(*Movie), args.Error(1)
+}
+
+// GetChange mock.
+func (m *SvcMock) GetChange(ctx context.Context, scope ChangeScope) (*Change, error) {
+	args := m.MethodCalled("GetChange", ctx, scope)
+
+	return args.Get(0).(*Change), args.Error(1)
+}
+
 // CreateMovie mock.
 func (m *SvcMock) CreateMovie(ctx context.Context, movie NewMovie) error {
 	args := m.MethodCalled("CreateMovie", ctx, movie)
@@ -58,3 +72,10 @@ func (m *SvcMock) RemoveAction(ctx context.Context, movieID int, action string)
 
 	return args.Error(0)
 }
+
+// Vote mock.
+func (m *SvcMock) Vote(ctx context.Context, movieID int, action string) error {
+	args := m.MethodCalled("Vote", ctx, movieID, action)
+
+	return args.Error(0)
+}
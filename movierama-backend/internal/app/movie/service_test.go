@@ -2,12 +2,22 @@ package movie_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"movierama/internal/app/movie"
+	"movierama/internal/contentfilter"
+	"movierama/internal/infra/repository/sql/moderation"
 	sqlMovieMock "movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/locale"
+	"movierama/internal/metrics"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
 )
 
 func Test_GetMoviesPublic(t *testing.T) {
@@ -50,6 +60,7 @@ func Test_GetMoviesPublic(t *testing.T) {
 						Likes:       3,
 						Hates:       4,
 						TimeAgo:     "about an hour ago",
+						CreatedAt:   time1HourAgo.UTC(),
 					},
 				},
 			},
@@ -69,7 +80,7 @@ func Test_GetMoviesPublic(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := movie.NewService(tt.sqlRepo)
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
 			res, err := app.GetMoviesPublic(context.TODO(), tt.sortType)
 			assert.Equal(t, tt.expErr, err)
@@ -127,6 +138,7 @@ func Test_GetMovies(t *testing.T) {
 						UserHated:   false,
 						IsSameUser:  false,
 						TimeAgo:     "about an hour ago",
+						CreatedAt:   time1HourAgo.UTC(),
 					},
 				},
 			},
@@ -168,6 +180,7 @@ func Test_GetMovies(t *testing.T) {
 						Hates:       4,
 						IsSameUser:  true,
 						TimeAgo:     "about an hour ago",
+						CreatedAt:   time1HourAgo.UTC(),
 					},
 				},
 			},
@@ -190,7 +203,7 @@ func Test_GetMovies(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := movie.NewService(tt.sqlRepo)
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
 			res, err := app.GetMovies(tt.ctx, tt.sortType)
 			assert.Equal(t, tt.expErr, err)
@@ -241,6 +254,7 @@ func Test_GetUserMoviesPublic(t *testing.T) {
 						Likes:       3,
 						Hates:       4,
 						TimeAgo:     "about an hour ago",
+						CreatedAt:   time1HourAgo.UTC(),
 					},
 				},
 			},
@@ -261,7 +275,7 @@ func Test_GetUserMoviesPublic(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := movie.NewService(tt.sqlRepo)
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
 			res, err := app.GetUserMoviesPublic(context.TODO(), tt.userID, tt.sortType)
 			assert.Equal(t, tt.expErr, err)
@@ -321,6 +335,7 @@ func Test_GetUserMovies(t *testing.T) {
 						UserHated:   false,
 						IsSameUser:  false,
 						TimeAgo:     "about an hour ago",
+						CreatedAt:   time1HourAgo.UTC(),
 					},
 				},
 			},
@@ -363,6 +378,7 @@ func Test_GetUserMovies(t *testing.T) {
 						Hates:       4,
 						IsSameUser:  true,
 						TimeAgo:     "about an hour ago",
+						CreatedAt:   time1HourAgo.UTC(),
 					},
 				},
 			},
@@ -386,7 +402,7 @@ func Test_GetUserMovies(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := movie.NewService(tt.sqlRepo)
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
 			res, err := app.GetUserMovies(tt.ctx, tt.userID, tt.sortType)
 			assert.Equal(t, tt.expErr, err)
@@ -396,62 +412,200 @@ func Test_GetUserMovies(t *testing.T) {
 }
 
 func Test_CreateMovie(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), movie.AuthUserIDContextKey, 3)
+	newMovie := movie.NewMovie{
+		Title:       "movie title",
+		Description: "movie description",
+	}
+	rule := func(res contentfilter.Result, err error) movie.Filter {
+		return contentfilter.New(contentfilter.NewRule("test", func(context.Context, *contentfilter.Submission) (contentfilter.Result, error) {
+			return res, err
+		}))
+	}
+	// created expects the creation of the movie, after looking for duplicates unless confirmed.
+	created := func(err error, confirmed ...bool) *sqlMovieMock.Mock {
+		repo := sqlMovieMock.Mock{}
+		if len(confirmed) == 0 {
+			repo.On("GetSimilarTitles", ctx, "movie title").
+				Return([]sqlMovieMock.Title{{ID: 1, Title: "Movie", Key: "movie"}}, nil)
+		}
+		repo.On("CreateMovie", ctx, &sqlMovieMock.SQLMovie{
+			Title:       "movie title",
+			Description: "movie description",
+			UserID:      3,
+			TitleKey:    "movie title",
+		}).Run(func(args mock.Arguments) {
+			id := 5
+			args.Get(1).(*sqlMovieMock.SQLMovie).ID = &id
+		}).Return(err)
+
+		return &repo
+	}
+	movieID, userID := 5, 3
+	flagged := func(reportErr error) *moderation.Mock {
+		repo := moderation.Mock{}
+		repo.On("AddReport", ctx, &moderation.Report{MovieID: 5, Source: moderation.SourceFilter, Category: "spam"}).Return(reportErr)
+		repo.On("AddAction", ctx, &moderation.Action{
+			Action:     movie.ModerationFlagMovie,
+			UserID:     &userID,
+			MovieID:    &movieID,
+			MovieTitle: "movie title",
+			Detail:     "test",
+			Reason:     "looks like spam",
+		}).Return(nil).Maybe()
+
+		return &repo
+	}
+
 	tests := map[string]struct {
 		sqlRepo *sqlMovieMock.Mock
-		ctx     context.Context
-		movie   movie.NewMovie
+		modRepo *moderation.Mock
+		filter  movie.Filter
+		confirm bool
 		expErr  error
 	}{
-		"Should get movies": {
+		"Should create the movie without filter": {
+			sqlRepo: created(nil),
+			modRepo: &moderation.Mock{},
+		},
+		"Should return the likely duplicates and key the titles without key": {
 			sqlRepo: func() *sqlMovieMock.Mock {
-				m := &sqlMovieMock.SQLMovie{
-					Title:       "movie title",
-					Description: "movie description",
-					UserID:      3,
-				}
-
 				repo := sqlMovieMock.Mock{}
-				repo.On("CreateMovie", context.WithValue(
-					context.TODO(), movie.AuthUserIDContextKey, 3), m).
-					Return(nil)
+				repo.On("GetSimilarTitles", ctx, "movie title").Return([]sqlMovieMock.Title{
+					{ID: 1, Title: "Movie titles", Key: "movie titles"},
+					{ID: 2, Title: "Alien"},
+					{ID: 4, Title: "The  MOVIE Title!"},
+				}, nil)
+				repo.On("SetTitleKeys", ctx, map[int]string{2: "alien", 4: "movie title"}).Return(nil)
 
 				return &repo
 			}(),
-			ctx: context.WithValue(context.TODO(), movie.AuthUserIDContextKey, 3),
-			movie: movie.NewMovie{
-				Title:       "movie title",
-				Description: "movie description",
-			},
-			expErr: nil,
+			modRepo: &moderation.Mock{},
+			expErr: &movie.DuplicateError{Movies: []movie.Duplicate{
+				{ID: 4, Title: "The  MOVIE Title!"},
+				{ID: 1, Title: "Movie titles"},
+			}},
 		},
-		"Should return error on repo error": {
+		"Should create a confirmed movie without looking for duplicates": {
+			sqlRepo: created(nil, true),
+			modRepo: &moderation.Mock{},
+			confirm: true,
+		},
+		"Should return error on titles error": {
 			sqlRepo: func() *sqlMovieMock.Mock {
-				m := &sqlMovieMock.SQLMovie{
-					Title:       "movie title",
-					Description: "movie description",
-					UserID:      3,
-				}
 				repo := sqlMovieMock.Mock{}
-				repo.On("CreateMovie", context.WithValue(
-					context.TODO(), movie.AuthUserIDContextKey, 3), m).
-					Return(errors.New("random error"))
+				repo.On("GetSimilarTitles", ctx, "movie title").Return([]sqlMovieMock.Title(nil), errors.New("random error"))
 
 				return &repo
 			}(),
-			movie: movie.NewMovie{
-				Title:       "movie title",
-				Description: "movie description",
-			}, ctx: context.WithValue(context.TODO(), movie.AuthUserIDContextKey, 3),
-			expErr: errors.New("random error"),
+			modRepo: &moderation.Mock{},
+			expErr:  errors.New("random error"),
+		},
+		"Should return error on repo error": {
+			sqlRepo: created(errors.New("random error")),
+			modRepo: &moderation.Mock{},
+			expErr:  errors.New("random error"),
+		},
+		"Should create an allowed movie": {
+			sqlRepo: created(nil),
+			modRepo: &moderation.Mock{},
+			filter:  rule(contentfilter.Result{Verdict: contentfilter.Allow}, nil),
+		},
+		"Should not create a rejected movie": {
+			sqlRepo: &sqlMovieMock.Mock{},
+			modRepo: &moderation.Mock{},
+			filter:  rule(contentfilter.Result{Verdict: contentfilter.Reject, Reason: "banned"}, nil),
+			expErr:  &contentfilter.RejectedError{Rule: "test", Reason: "banned"},
+		},
+		"Should queue a flagged movie": {
+			sqlRepo: created(nil),
+			modRepo: flagged(nil),
+			filter:  rule(contentfilter.Result{Verdict: contentfilter.Flag, Reason: "looks like spam", Category: "spam"}, nil),
+		},
+		"Should keep a flagged movie which cannot be queued": {
+			sqlRepo: created(nil),
+			modRepo: flagged(errors.New("random error")),
+			filter:  rule(contentfilter.Result{Verdict: contentfilter.Flag, Reason: "looks like spam", Category: "spam"}, nil),
+		},
+		"Should return error on filter error": {
+			sqlRepo: &sqlMovieMock.Mock{},
+			modRepo: &moderation.Mock{},
+			filter:  rule(contentfilter.Result{}, errors.New("random error")),
+			expErr:  fmt.Errorf("content filter test: %w", errors.New("random error")),
 		},
 	}
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := movie.NewService(tt.sqlRepo)
+			app := movie.NewService(tt.sqlRepo, tt.modRepo, tt.filter, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
-			err := app.CreateMovie(tt.ctx, tt.movie)
+			m := newMovie
+			m.Confirm = tt.confirm
+			err := app.CreateMovie(ctx, m)
 			assert.Equal(t, tt.expErr, err)
+			tt.sqlRepo.AssertExpectations(t)
+			tt.modRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_CreateMovie_Sequels(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), movie.AuthUserIDContextKey, 3)
+	tests := map[string]struct {
+		title    string
+		existing string
+		expDup   bool
+	}{
+		"Should tell the numbered sequels apart": {
+			title:    "Toy Story 3",
+			existing: "Toy Story 2",
+		},
+		"Should tell the roman numbered sequels apart": {
+			title:    "Rocky III",
+			existing: "Rocky II",
+		},
+		"Should tell a sequel from the first movie": {
+			title:    "Toy Story 2",
+			existing: "Toy Story",
+		},
+		"Should match the same sequel": {
+			title:    "Toy Story 2!",
+			existing: "toy story 2",
+			expDup:   true,
+		},
+		"Should match a number and its roman numeral": {
+			title:    "Star Wars: Episode IV - A New Hope",
+			existing: "Star Wars Episode 4 A New Hope",
+			expDup:   true,
+		},
+		"Should match the titles without numbers": {
+			title:    "Matrix, The",
+			existing: "The Matrix",
+			expDup:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			key := movie.NormalizeTitle(tt.title)
+			repo := &sqlMovieMock.Mock{}
+			repo.On("GetSimilarTitles", ctx, key).
+				Return([]sqlMovieMock.Title{{ID: 1, Title: tt.existing, Key: movie.NormalizeTitle(tt.existing)}}, nil)
+			if !tt.expDup {
+				repo.On("CreateMovie", ctx, mock.Anything).Run(func(args mock.Arguments) {
+					id := 5
+					args.Get(1).(*sqlMovieMock.SQLMovie).ID = &id
+				}).Return(nil)
+			}
+			app := movie.NewService(repo, &moderation.Mock{}, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			err := app.CreateMovie(ctx, movie.NewMovie{Title: tt.title, Description: "movie description"})
+			if tt.expDup {
+				assert.Equal(t, &movie.DuplicateError{Movies: []movie.Duplicate{{ID: 1, Title: tt.existing}}}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			repo.AssertExpectations(t)
 		})
 	}
 }
@@ -496,7 +650,7 @@ func Test_Action(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := movie.NewService(tt.sqlRepo)
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
 			err := app.Action(tt.ctx, tt.movieID, tt.action)
 			assert.Equal(t, tt.expErr, err)
@@ -544,10 +698,264 @@ func Test_RemoveAction(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			app := movie.NewService(tt.sqlRepo)
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
 
 			err := app.RemoveAction(tt.ctx, tt.movieID, tt.action)
 			assert.Equal(t, tt.expErr, err)
 		})
 	}
 }
+
+func Test_GetMovie(t *testing.T) {
+	time1HourAgo := time.Now().Add(time.Duration(-1) * time.Hour)
+	authCtx := context.WithValue(context.TODO(), movie.AuthUserIDContextKey, 3)
+	greekCtx := locale.WithLocale(context.TODO(), locale.Greek)
+	tests := map[string]struct {
+		sqlRepo *sqlMovieMock.Mock
+		ctx     context.Context
+		expRes  *movie.Movie
+		expErr  error
+	}{
+		"Should get movie without auth": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("GetMovie", context.TODO(), 1, 0).Return(&sqlMovieMock.Movie{
+					ID:        1,
+					Title:     "movie title",
+					UserID:    3,
+					PostedBy:  "Full Name",
+					Likes:     3,
+					CreatedAt: time1HourAgo,
+				}, nil)
+
+				return &repo
+			}(),
+			ctx: context.TODO(),
+			expRes: &movie.Movie{
+				ID:        1,
+				Title:     "movie title",
+				UserID:    3,
+				PostedBy:  "Full Name",
+				Likes:     3,
+				TimeAgo:   "about an hour ago",
+				CreatedAt: time1HourAgo.UTC(),
+			},
+		},
+		"Should get movie with auth": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("GetMovie", authCtx, 1, 3).Return(&sqlMovieMock.Movie{
+					ID:        1,
+					Title:     "movie title",
+					UserID:    3,
+					PostedBy:  "Full Name",
+					CreatedAt: time1HourAgo,
+				}, nil)
+
+				return &repo
+			}(),
+			ctx: authCtx,
+			expRes: &movie.Movie{
+				ID:         1,
+				Title:      "movie title",
+				UserID:     3,
+				PostedBy:   "Full Name",
+				IsSameUser: true,
+				TimeAgo:    "about an hour ago",
+				CreatedAt:  time1HourAgo.UTC(),
+			},
+		},
+		"Should get movie in the locale of the request": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("GetMovie", greekCtx, 1, 0).Return(&sqlMovieMock.Movie{
+					ID:        1,
+					Title:     "movie title",
+					UserID:    3,
+					PostedBy:  "Full Name",
+					CreatedAt: time1HourAgo,
+				}, nil)
+
+				return &repo
+			}(),
+			ctx: greekCtx,
+			expRes: &movie.Movie{
+				ID:        1,
+				Title:     "movie title",
+				UserID:    3,
+				PostedBy:  "Full Name",
+				TimeAgo:   "πριν από περίπου μία ώρα",
+				CreatedAt: time1HourAgo.UTC(),
+			},
+		},
+		"Should return not found on missing movie": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("GetMovie", context.TODO(), 1, 0).Return((*sqlMovieMock.Movie)(nil), sql.ErrNoRows)
+
+				return &repo
+			}(),
+			ctx:    context.TODO(),
+			expErr: movie.ErrMovieNotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			res, err := app.GetMovie(tt.ctx, 1)
+			assert.Equal(t, tt.expErr, err)
+			assert.Equal(t, tt.expRes, res)
+		})
+	}
+}
+
+func Test_GetChange(t *testing.T) {
+	changedAt := time.Date(2026, time.October, 25, 11, 0, 0, 0, time.FixedZone("EET", 2*60*60))
+	authCtx := context.WithValue(context.TODO(), movie.AuthUserIDContextKey, 3)
+	tests := map[string]struct {
+		sqlRepo *sqlMovieMock.Mock
+		ctx     context.Context
+		scope   movie.ChangeScope
+		expRes  *movie.Change
+		expErr  error
+	}{
+		"Should get the change of every movie without auth": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("GetChange", context.TODO(), 0, sqlMovieMock.ChangeScope{}).
+					Return(&sqlMovieMock.Change{Movies: 7, ChangedAt: changedAt}, nil)
+
+				return &repo
+			}(),
+			ctx:    context.TODO(),
+			expRes: &movie.Change{Movies: 7, ChangedAt: changedAt.UTC()},
+		},
+		"Should get the change of a movie of the auth user": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("GetChange", authCtx, 3, sqlMovieMock.ChangeScope{MovieID: 5}).
+					Return(&sqlMovieMock.Change{Movies: 1, ChangedAt: changedAt}, nil)
+
+				return &repo
+			}(),
+			ctx:    authCtx,
+			scope:  movie.ChangeScope{MovieID: 5},
+			expRes: &movie.Change{Movies: 1, ChangedAt: changedAt.UTC()},
+		},
+		"Should return the repository error": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("GetChange", context.TODO(), 0, sqlMovieMock.ChangeScope{UserID: 2}).
+					Return((*sqlMovieMock.Change)(nil), errors.New("sql error"))
+
+				return &repo
+			}(),
+			ctx:    context.TODO(),
+			scope:  movie.ChangeScope{UserID: 2},
+			expErr: errors.New("sql error"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			res, err := app.GetChange(tt.ctx, tt.scope)
+			assert.Equal(t, tt.expErr, err)
+			assert.Equal(t, tt.expRes, res)
+		})
+	}
+}
+
+func Test_Vote(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), movie.AuthUserIDContextKey, 3)
+	tests := map[string]struct {
+		sqlRepo *sqlMovieMock.Mock
+		action  string
+		expErr  error
+	}{
+		"Should add a vote": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("SetMovieAction", ctx, 1, 3, "like").Return("", nil)
+
+				return &repo
+			}(),
+			action: "like",
+		},
+		"Should switch a vote": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("SetMovieAction", ctx, 1, 3, "hate").Return("like", nil)
+
+				return &repo
+			}(),
+			action: "hate",
+		},
+		"Should retract a vote": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("SetMovieAction", ctx, 1, 3, "").Return("hate", nil)
+
+				return &repo
+			}(),
+			action: "",
+		},
+		"Should keep the same vote": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("SetMovieAction", ctx, 1, 3, "like").Return("like", nil)
+
+				return &repo
+			}(),
+			action: "like",
+		},
+		"Should not vote for own movie": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("SetMovieAction", ctx, 1, 3, "like").Return("", sqlMovieMock.ErrOwnMovie)
+
+				return &repo
+			}(),
+			action: "like",
+			expErr: movie.ErrOwnMovie,
+		},
+		"Should not vote for a missing movie": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("SetMovieAction", ctx, 1, 3, "like").Return("", sql.ErrNoRows)
+
+				return &repo
+			}(),
+			action: "like",
+			expErr: movie.ErrMovieNotFound,
+		},
+		"Should return error on invalid action": {
+			sqlRepo: &sqlMovieMock.Mock{},
+			action:  "love",
+			expErr:  movie.ErrInvalidAction,
+		},
+		"Should return error on repo error": {
+			sqlRepo: func() *sqlMovieMock.Mock {
+				repo := sqlMovieMock.Mock{}
+				repo.On("SetMovieAction", ctx, 1, 3, "like").Return("", errors.New("random error"))
+
+				return &repo
+			}(),
+			action: "like",
+			expErr: errors.New("random error"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := movie.NewService(tt.sqlRepo, nil, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+
+			err := app.Vote(ctx, 1, tt.action)
+			assert.Equal(t, tt.expErr, err)
+			tt.sqlRepo.AssertExpectations(t)
+		})
+	}
+}
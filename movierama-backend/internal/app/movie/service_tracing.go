@@ -0,0 +1,108 @@
+package movie
+
+import (
+	"context"
+	"movierama/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "movierama/internal/app/movie"
+
+type tracedService struct {
+	svc    Service
+	tracer trace.Tracer
+}
+
+// NewTracedService wraps svc to record a span per method call.
+func NewTracedService(svc Service, tp trace.TracerProvider) Service {
+	return &tracedService{
+		svc:    svc,
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+func (s *tracedService) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "movie.Service/"+method, trace.WithAttributes(attrs...))
+}
+
+// GetMoviesPublic function.
+func (s *tracedService) GetMoviesPublic(ctx context.Context, sortType string) (res *GetmoviesRes, err error) {
+	ctx, span := s.start(ctx, "GetMoviesPublic", attribute.String("movie.sort", sortType))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetMoviesPublic(ctx, sortType)
+}
+
+// GetMovies function.
+func (s *tracedService) GetMovies(ctx context.Context, sortType string) (res *GetmoviesRes, err error) {
+	ctx, span := s.start(ctx, "GetMovies", attribute.String("movie.sort", sortType))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetMovies(ctx, sortType)
+}
+
+// GetUserMovies function.
+func (s *tracedService) GetUserMovies(ctx context.Context, userID int, sortType string) (res *GetmoviesRes, err error) {
+	ctx, span := s.start(ctx, "GetUserMovies", attribute.Int("movie.user_id", userID), attribute.String("movie.sort", sortType))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetUserMovies(ctx, userID, sortType)
+}
+
+// GetUserMoviesPublic function.
+func (s *tracedService) GetUserMoviesPublic(ctx context.Context, userID int, sortType string) (res *GetmoviesRes, err error) {
+	ctx, span := s.start(ctx, "GetUserMoviesPublic", attribute.Int("movie.user_id", userID), attribute.String("movie.sort", sortType))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetUserMoviesPublic(ctx, userID, sortType)
+}
+
+// GetMovie function.
+func (s *tracedService) GetMovie(ctx context.Context, movieID int) (res *Movie, err error) {
+	ctx, span := s.start(ctx, "GetMovie", attribute.Int("movie.id", movieID))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetMovie(ctx, movieID)
+}
+
+// GetChange function.
+func (s *tracedService) GetChange(ctx context.Context, scope ChangeScope) (res *Change, err error) {
+	ctx, span := s.start(ctx, "GetChange")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.GetChange(ctx, scope)
+}
+
+// CreateMovie function.
+func (s *tracedService) CreateMovie(ctx context.Context, movie NewMovie) (err error) {
+	ctx, span := s.start(ctx, "CreateMovie")
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.CreateMovie(ctx, movie)
+}
+
+// Action function.
+func (s *tracedService) Action(ctx context.Context, movieID int, action string) (err error) {
+	ctx, span := s.start(ctx, "Action", attribute.Int("movie.id", movieID), attribute.String("movie.action", action))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.Action(ctx, movieID, action)
+}
+
+// RemoveAction function.
+func (s *tracedService) RemoveAction(ctx context.Context, movieID int, action string) (err error) {
+	ctx, span := s.start(ctx, "RemoveAction", attribute.Int("movie.id", movieID), attribute.String("movie.action", action))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.RemoveAction(ctx, movieID, action)
+}
+
+// Vote function.
+func (s *tracedService) Vote(ctx context.Context, movieID int, action string) (err error) {
+	ctx, span := s.start(ctx, "Vote", attribute.Int("movie.id", movieID), attribute.String("movie.action", action))
+	defer func() { tracing.End(span, err) }()
+
+	return s.svc.Vote(ctx, movieID, action)
+}
@@ -0,0 +1,84 @@
+package movie_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/app/movie"
+	"movierama/internal/tracing"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_TracedService(t *testing.T) {
+	tests := map[string]struct {
+		svc       *movie.SvcMock
+		call      func(ctx context.Context, svc movie.Service) error
+		expName   string
+		expAttr   attribute.KeyValue
+		expStatus codes.Code
+	}{
+		"should trace the service calls": {
+			svc: func() *movie.SvcMock {
+				m := &movie.SvcMock{}
+				m.On("GetUserMovies", mock.Anything, 2, "likes").Return(&movie.GetmoviesRes{}, nil)
+
+				return m
+			}(),
+			call: func(ctx context.Context, svc movie.Service) error {
+				_, err := svc.GetUserMovies(ctx, 2, "likes")
+
+				return err
+			},
+			expName: "movie.Service/GetUserMovies",
+			expAttr: attribute.Int("movie.user_id", 2),
+		},
+		"should record the errors": {
+			svc: func() *movie.SvcMock {
+				m := &movie.SvcMock{}
+				m.On("Vote", mock.Anything, 5, "like").Return(movie.ErrOwnMovie)
+
+				return m
+			}(),
+			call: func(ctx context.Context, svc movie.Service) error {
+				return svc.Vote(ctx, 5, "like")
+			},
+			expName:   "movie.Service/Vote",
+			expAttr:   attribute.Int("movie.id", 5),
+			expStatus: codes.Error,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tp, exporter := tracing.NewTestProvider()
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+
+			// The wrapped service receives the context of the span.
+			var callCtx context.Context
+			for _, c := range tt.svc.ExpectedCalls {
+				c.Run(func(args mock.Arguments) {
+					callCtx = args.Get(0).(context.Context)
+				})
+			}
+
+			svc := movie.NewTracedService(tt.svc, tp)
+			_ = tt.call(ctx, svc)
+			parent.End()
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 2)
+			span := spans[0]
+			assert.Equal(t, tt.expName, span.Name)
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+			assert.Equal(t, span.SpanContext.SpanID(), trace.SpanContextFromContext(callCtx).SpanID())
+			assert.Contains(t, span.Attributes, tt.expAttr)
+			assert.Equal(t, tt.expStatus, span.Status.Code)
+			tt.svc.AssertExpectations(t)
+		})
+	}
+}
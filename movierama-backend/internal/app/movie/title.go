@@ -0,0 +1,172 @@
+package movie
+
+import (
+	moviesql "movierama/internal/infra/repository/sql/movie"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// duplicateSimilarity is the similarity of the normalized titles from which a movie looks like a duplicate.
+	duplicateSimilarity = 0.85
+	// maxDuplicates is the number of likely duplicates returned for a new movie.
+	maxDuplicates = 5
+)
+
+// articles are dropped from the start and the end of the titles, so that "The Matrix" and "Matrix, The" match.
+var articles = map[string]bool{"the": true, "a": true, "an": true}
+
+// romanRe matches the roman numerals up to 39, which number the sequels.
+var romanRe = regexp.MustCompile(`^x{0,3}(?:ix|iv|v?i{0,3})$`)
+
+var romanValues = map[byte]int{'i': 1, 'v': 5, 'x': 10}
+
+// NormalizeTitle folds a title to the lower case words of its letters and digits, without accents and without
+// a leading or trailing article.
+func NormalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(title) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	words := strings.Fields(b.String())
+	if len(words) > 1 && articles[words[0]] {
+		words = words[1:]
+	}
+	if len(words) > 1 && articles[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// similarity returns the Levenshtein similarity of two normalized titles, 1 when they are equal.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	// prev and cur are the rows of the edit distances between the prefixes of ra and rb.
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minOf(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+func minOf(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
+
+// numbers returns the numbers of the words of a normalized title, in digits or in roman numerals, so that the
+// sequels are told apart: "rocky ii" and "rocky 2" both number 2.
+func numbers(key string) []string {
+	var nums []string
+	for _, w := range strings.Fields(key) {
+		switch {
+		case strings.Trim(w, "0123456789") == "":
+			n := strings.TrimLeft(w, "0")
+			if n == "" {
+				n = "0"
+			}
+			nums = append(nums, n)
+		case romanRe.MatchString(w):
+			n := 0
+			for i := range w {
+				v := romanValues[w[i]]
+				if i+1 < len(w) && v < romanValues[w[i+1]] {
+					v = -v
+				}
+				n += v
+			}
+			nums = append(nums, strconv.Itoa(n))
+		}
+	}
+
+	return nums
+}
+
+// sameNumbers reports whether two normalized titles have the same numbers in the same order.
+func sameNumbers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// likelyDuplicates returns the movies whose titles look like the title of key and have the same numbers, the most
+// similar first. The titles without key are normalized and returned by key in unkeyed, so that their keys are
+// stored.
+func likelyDuplicates(key string, titles []moviesql.Title) (dups []Duplicate, unkeyed map[int]string) {
+	type match struct {
+		Duplicate
+		score float64
+	}
+	var matches []match
+	keyNumbers := numbers(key)
+	for _, t := range titles {
+		if t.Key == "" {
+			t.Key = NormalizeTitle(t.Title)
+			if unkeyed == nil {
+				unkeyed = make(map[int]string)
+			}
+			unkeyed[t.ID] = t.Key
+		}
+		if !sameNumbers(keyNumbers, numbers(t.Key)) {
+			continue
+		}
+		if score := similarity(key, t.Key); score >= duplicateSimilarity {
+			matches = append(matches, match{Duplicate: Duplicate{ID: t.ID, Title: t.Title}, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	for i := 0; i < len(matches) && i < maxDuplicates; i++ {
+		dups = append(dups, matches[i].Duplicate)
+	}
+
+	return dups, unkeyed
+}
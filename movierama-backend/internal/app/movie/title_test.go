@@ -0,0 +1,33 @@
+package movie_test
+
+import (
+	"movierama/internal/app/movie"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := map[string]struct {
+		title string
+		exp   string
+	}{
+		"should lower the case":                 {title: "The MATRIX", exp: "matrix"},
+		"should collapse the whitespace":        {title: "  the   matrix ", exp: "matrix"},
+		"should drop a trailing article":        {title: "Matrix, The", exp: "matrix"},
+		"should drop the punctuation":           {title: "Léon: The Professional!", exp: "leon the professional"},
+		"should strip the diacritics":           {title: "Amélie", exp: "amelie"},
+		"should keep a lone article":            {title: "The", exp: "the"},
+		"should keep the digits":                {title: "2001: A Space Odyssey", exp: "2001 a space odyssey"},
+		"should decompose compatibility runes":  {title: "Ｓｅｖｅｎ", exp: "seven"},
+		"should return empty on punctuation":    {title: "?!", exp: ""},
+		"should drop a leading indefinite one":  {title: "A Beautiful Mind", exp: "beautiful mind"},
+		"should keep the inner articles intact": {title: "Gone with the Wind", exp: "gone with the wind"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, movie.NormalizeTitle(tt.title))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package bruteforce slows down and locks out the repeated failures of authentication attempts.
+package bruteforce
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Policy defines the delays and the lockout of a key after consecutive failures.
+type Policy struct {
+	// FreeAttempts is the number of failures allowed without delay.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure over FreeAttempts, it doubles after every
+	// other failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxAttempts is the number of failures that locks the key for LockoutDuration.
+	MaxAttempts     int
+	LockoutDuration time.Duration
+}
+
+// wait returns the wait before the next attempt after the failures of a.
+func (p Policy) wait(a Attempts, now time.Time) time.Duration {
+	var d time.Duration
+	switch {
+	case p.MaxAttempts > 0 && a.Failures >= p.MaxAttempts:
+		d = p.LockoutDuration
+	case a.Failures > p.FreeAttempts:
+		d = p.BaseDelay
+		for i := p.FreeAttempts + 1; i < a.Failures && d < p.MaxDelay; i++ {
+			d *= 2
+		}
+		if d > p.MaxDelay {
+			d = p.MaxDelay
+		}
+	default:
+		return 0
+	}
+
+	if wait := a.Last.Add(d).Sub(now); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+// locked reports whether the failures of a lock the key.
+func (p Policy) locked(a Attempts) bool {
+	return p.MaxAttempts > 0 && a.Failures >= p.MaxAttempts
+}
+
+// Attempts are the consecutive failures of a key.
+type Attempts struct {
+	Failures int
+	Last     time.Time
+}
+
+// Store keeps the failures of the keys.
+type Store interface {
+	// Attempt records an attempt of key as a failure and returns the failures before it, in one step so that the
+	// parallel attempts see each other. The failures are forgotten after ttl without attempts.
+	Attempt(ctx context.Context, key string, ttl time.Duration) (Attempts, error)
+	// Refund gives back the last attempt of key, when it did not fail.
+	Refund(ctx context.Context, key string) error
+	// Reset forgets the failures of key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Block is the verdict of an attempt that must wait.
+type Block struct {
+	// Wait is the time until the next attempt is allowed.
+	Wait time.Duration
+	// Locked is set when the key is locked out rather than delayed.
+	Locked bool
+}
+
+// Guard tracks the failed logins per username and per client IP.
+type Guard struct {
+	store    Store
+	username Policy
+	ip       Policy
+}
+
+// NewGuard constructor.
+func NewGuard(store Store, username, ip Policy) *Guard {
+	return &Guard{store: store, username: username, ip: ip}
+}
+
+func usernameKey(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check records a login of username from ip as a failure until it succeeds, and returns its block, nil when it may
+// go on. The attempt is counted before the password is checked, so that the parallel logins cannot all pass the
+// delays, and the blocked attempts count as well. A nil guard blocks nothing.
+func (g *Guard) Check(ctx context.Context, username, ip string) (*Block, error) {
+	if g == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var block *Block
+	for _, k := range []struct {
+		key    string
+		policy Policy
+	}{
+		{usernameKey(username), g.username},
+		{ipKey(ip), g.ip},
+	} {
+		a, err := g.store.Attempt(ctx, k.key, k.policy.ttl())
+		if err != nil {
+			return nil, err
+		}
+		if wait := k.policy.wait(a, now); wait > 0 && (block == nil || wait > block.Wait) {
+			block = &Block{Wait: wait, Locked: k.policy.locked(a)}
+		}
+	}
+
+	return block, nil
+}
+
+// Succeed forgets the failures of username and gives back the attempt of ip. The other failures of the IP are
+// kept, so that an attacker cannot reset them with the logins of its own account.
+func (g *Guard) Succeed(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+	if err := g.store.Reset(ctx, usernameKey(username)); err != nil {
+		return err
+	}
+
+	return g.store.Refund(ctx, ipKey(ip))
+}
+
+// Reset forgets the failures of username, e.g. once its password is reset.
+func (g *Guard) Reset(ctx context.Context, username string) error {
+	if g == nil {
+		return nil
+	}
+
+	return g.store.Reset(ctx, usernameKey(username))
+}
+
+// ttl returns how long the failures are remembered, past the longest wait.
+func (p Policy) ttl() time.Duration {
+	if p.LockoutDuration > p.MaxDelay {
+		return p.LockoutDuration
+	}
+
+	return p.MaxDelay
+}
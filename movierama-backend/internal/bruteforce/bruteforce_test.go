@@ -0,0 +1,152 @@
+package bruteforce_test
+
+import (
+	"context"
+	"movierama/internal/bruteforce"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	usernamePolicy = bruteforce.Policy{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		MaxAttempts:     6,
+		LockoutDuration: time.Hour,
+	}
+	ipPolicy = bruteforce.Policy{
+		FreeAttempts:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		MaxAttempts:     20,
+		LockoutDuration: time.Hour,
+	}
+)
+
+func TestGuard(t *testing.T) {
+	tests := map[string]struct {
+		failures int
+		expWait  time.Duration
+		expLock  bool
+	}{
+		"should allow the free attempts": {
+			failures: 2,
+		},
+		"should delay after the free attempts": {
+			failures: 3,
+			expWait:  time.Second,
+		},
+		"should double the delay": {
+			failures: 4,
+			expWait:  2 * time.Second,
+		},
+		"should cap the delay": {
+			failures: 5,
+			expWait:  4 * time.Second,
+		},
+		"should lock out": {
+			failures: 6,
+			expWait:  time.Hour,
+			expLock:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := bruteforce.NewGuard(bruteforce.NewMemoryStore(), usernamePolicy, ipPolicy)
+			ctx := context.Background()
+			for i := 0; i < tt.failures; i++ {
+				_, err := g.Check(ctx, "User", "10.1.0.1")
+				require.NoError(t, err)
+			}
+
+			// The usernames are case insensitive.
+			block, err := g.Check(ctx, "user", "10.1.0.2")
+			require.NoError(t, err)
+			if tt.expWait == 0 {
+				assert.Nil(t, block)
+				return
+			}
+			require.NotNil(t, block)
+			assert.InDelta(t, tt.expWait, block.Wait, float64(100*time.Millisecond))
+			assert.Equal(t, tt.expLock, block.Locked)
+		})
+	}
+}
+
+func TestGuard_IP(t *testing.T) {
+	g := bruteforce.NewGuard(bruteforce.NewMemoryStore(), usernamePolicy, ipPolicy)
+	ctx := context.Background()
+
+	// Spraying a password over many usernames is blocked per IP.
+	for i := 0; i < 20; i++ {
+		_, err := g.Check(ctx, "user"+string(rune('a'+i)), "10.1.0.1")
+		require.NoError(t, err)
+	}
+
+	block, err := g.Check(ctx, "other", "10.1.0.1")
+	require.NoError(t, err)
+	require.NotNil(t, block)
+	assert.True(t, block.Locked)
+
+	block, err = g.Check(ctx, "other", "10.1.0.2")
+	require.NoError(t, err)
+	assert.Nil(t, block)
+}
+
+func TestGuard_Succeed(t *testing.T) {
+	g := bruteforce.NewGuard(bruteforce.NewMemoryStore(), usernamePolicy, ipPolicy)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := g.Check(ctx, "user", "10.1.0.1")
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, g.Succeed(ctx, "user", "10.1.0.1"))
+	block, err := g.Check(ctx, "user", "10.1.0.2")
+	require.NoError(t, err)
+	assert.Nil(t, block)
+}
+
+func TestGuard_Parallel(t *testing.T) {
+	g := bruteforce.NewGuard(bruteforce.NewMemoryStore(), usernamePolicy, ipPolicy)
+	ctx := context.Background()
+
+	// The parallel attempts are counted before any of them fails, only the free ones pass.
+	var passed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			block, err := g.Check(ctx, "user", "10.1.1."+strconv.Itoa(i))
+			if assert.NoError(t, err) && block == nil {
+				atomic.AddInt32(&passed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(usernamePolicy.FreeAttempts+1), passed)
+
+	block, err := g.Check(ctx, "user", "10.1.0.2")
+	require.NoError(t, err)
+	require.NotNil(t, block)
+	assert.True(t, block.Locked)
+}
+
+func TestGuard_Nil(t *testing.T) {
+	var g *bruteforce.Guard
+
+	block, err := g.Check(context.Background(), "user", "10.1.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, block)
+	assert.NoError(t, g.Succeed(context.Background(), "user", "10.1.0.1"))
+	assert.NoError(t, g.Reset(context.Background(), "user"))
+}
@@ -0,0 +1,231 @@
+package bruteforce
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// sweepInterval is the interval between two removals of the expired keys of the memory store.
+const sweepInterval = time.Minute
+
+type entry struct {
+	Attempts
+	expires time.Time
+}
+
+// MemoryStore keeps the failures in memory, they are not shared by replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// NewMemoryStore constructor.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry), lastSweep: time.Now()}
+}
+
+// Attempt implements Store.
+func (s *MemoryStore) Attempt(_ context.Context, key string, ttl time.Duration) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	e, ok := s.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &entry{}
+		s.entries[key] = e
+	}
+	prev := e.Attempts
+	e.Failures++
+	e.Last = now
+	e.expires = now.Add(ttl)
+
+	return prev, nil
+}
+
+// Refund implements Store.
+func (s *MemoryStore) Refund(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		if e.Failures--; e.Failures <= 0 {
+			delete(s.entries, key)
+		}
+	}
+
+	return nil
+}
+
+// Reset implements Store.
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
+
+// attemptScript counts an attempt of KEYS[1] at ARGV[1] milliseconds, forgotten after ARGV[2] milliseconds, and
+// returns the failures and the last attempt before it.
+var attemptScript = redis.NewScript(`
+local prev = redis.call('HMGET', KEYS[1], 'failures', 'last')
+redis.call('HINCRBY', KEYS[1], 'failures', 1)
+redis.call('HSET', KEYS[1], 'last', ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return prev
+`)
+
+// refundScript gives back an attempt of KEYS[1], if it still exists.
+var refundScript = redis.NewScript(`
+local failures = tonumber(redis.call('HGET', KEYS[1], 'failures'))
+if failures ~= nil then
+	if failures <= 1 then
+		redis.call('DEL', KEYS[1])
+	else
+		redis.call('HINCRBY', KEYS[1], 'failures', -1)
+	end
+end
+return 0
+`)
+
+// RedisStore keeps the failures in redis, they are shared by replicas.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore constructor, the keys start with prefix.
+func NewRedisStore(client redis.Cmdable, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Attempt implements Store.
+func (s *RedisStore) Attempt(ctx context.Context, key string, ttl time.Duration) (Attempts, error) {
+	vals, err := attemptScript.Run(ctx, s.client, []string{s.prefix + key}, time.Now().UnixMilli(), ttl.Milliseconds()).Slice()
+	if err != nil {
+		return Attempts{}, err
+	}
+
+	return attempts(vals)
+}
+
+// Refund implements Store.
+func (s *RedisStore) Refund(ctx context.Context, key string) error {
+	return refundScript.Run(ctx, s.client, []string{s.prefix + key}).Err()
+}
+
+// Reset implements Store.
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// attempts decodes the failures and last fields of a hash.
+func attempts(vals []interface{}) (Attempts, error) {
+	var a Attempts
+	if f, ok := vals[0].(string); ok {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return Attempts{}, err
+		}
+		a.Failures = n
+	}
+	if l, ok := vals[1].(string); ok {
+		ms, err := strconv.ParseInt(l, 10, 64)
+		if err != nil {
+			return Attempts{}, err
+		}
+		a.Last = time.UnixMilli(ms)
+	}
+
+	return a, nil
+}
+
+// FallbackStore keeps the failures in the primary store, and in the fallback store while the primary
+// one fails, e.g. in memory while redis is down. The primary store is retried after RetryInterval.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+	log      *zap.Logger
+	// RetryInterval is the wait before the primary store is tried again after a failure.
+	RetryInterval time.Duration
+
+	mu      sync.Mutex
+	failing bool
+	retryAt time.Time
+}
+
+// NewFallbackStore constructor.
+func NewFallbackStore(primary, fallback Store, logger *zap.Logger) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback, log: logger, RetryInterval: 5 * time.Second}
+}
+
+// Attempt implements Store.
+func (s *FallbackStore) Attempt(ctx context.Context, key string, ttl time.Duration) (Attempts, error) {
+	var a Attempts
+	err := s.do(func(st Store) (err error) {
+		a, err = st.Attempt(ctx, key, ttl)
+		return err
+	})
+
+	return a, err
+}
+
+// Refund implements Store.
+func (s *FallbackStore) Refund(ctx context.Context, key string) error {
+	return s.do(func(st Store) error {
+		return st.Refund(ctx, key)
+	})
+}
+
+// Reset implements Store.
+func (s *FallbackStore) Reset(ctx context.Context, key string) error {
+	return s.do(func(st Store) error {
+		return st.Reset(ctx, key)
+	})
+}
+
+// do runs fn on the primary store, or on the fallback one while the primary one fails.
+func (s *FallbackStore) do(fn func(st Store) error) error {
+	s.mu.Lock()
+	skip := s.failing && time.Now().Before(s.retryAt)
+	s.mu.Unlock()
+	if skip {
+		return fn(s.fallback)
+	}
+
+	err := fn(s.primary)
+
+	s.mu.Lock()
+	switch {
+	case err != nil && !s.failing:
+		s.log.Warn("brute-force store failed, falling back", zap.Error(err))
+	case err == nil && s.failing:
+		s.log.Info("brute-force store recovered")
+	}
+	s.failing = err != nil
+	s.retryAt = time.Now().Add(s.RetryInterval)
+	s.mu.Unlock()
+
+	if err != nil {
+		return fn(s.fallback)
+	}
+
+	return nil
+}
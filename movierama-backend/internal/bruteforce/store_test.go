@@ -0,0 +1,91 @@
+package bruteforce_test
+
+import (
+	"context"
+	"movierama/internal/bruteforce"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) bruteforce.Store{
+		"memory": func(t *testing.T) bruteforce.Store {
+			return bruteforce.NewMemoryStore()
+		},
+		"redis": func(t *testing.T) bruteforce.Store {
+			mr := miniredis.RunT(t)
+			return bruteforce.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "movierama:lockout:")
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+
+			start := time.Now()
+			a, err := s.Attempt(ctx, "a", time.Hour)
+			require.NoError(t, err)
+			assert.Zero(t, a.Failures)
+			_, err = s.Attempt(ctx, "a", time.Hour)
+			require.NoError(t, err)
+			a, err = s.Attempt(ctx, "a", time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, 2, a.Failures)
+			assert.WithinDuration(t, start, a.Last, time.Second)
+
+			require.NoError(t, s.Refund(ctx, "a"))
+			a, err = s.Attempt(ctx, "a", time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, 2, a.Failures)
+
+			require.NoError(t, s.Reset(ctx, "a"))
+			a, err = s.Attempt(ctx, "a", time.Hour)
+			require.NoError(t, err)
+			assert.Zero(t, a.Failures)
+
+			// A refund of the only attempt forgets the key.
+			require.NoError(t, s.Refund(ctx, "a"))
+			require.NoError(t, s.Refund(ctx, "a"))
+			a, err = s.Attempt(ctx, "a", time.Hour)
+			require.NoError(t, err)
+			assert.Zero(t, a.Failures)
+		})
+	}
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	s := bruteforce.NewMemoryStore()
+
+	_, err := s.Attempt(context.Background(), "a", 20*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+
+	a, err := s.Attempt(context.Background(), "a", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, a.Failures)
+}
+
+func TestFallbackStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	primary := bruteforce.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}), "")
+	s := bruteforce.NewFallbackStore(primary, bruteforce.NewMemoryStore(), zap.NewNop())
+
+	_, err := s.Attempt(context.Background(), "a", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("a"))
+
+	// The memory store takes over while redis is down.
+	mr.Close()
+	_, err = s.Attempt(context.Background(), "b", time.Hour)
+	require.NoError(t, err)
+	a, err := s.Attempt(context.Background(), "b", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.Failures)
+}
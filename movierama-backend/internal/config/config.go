@@ -1,58 +1,729 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"math"
+	"movierama/internal/ratelimit"
+	"net/mail"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// minJWTSecretLen is the minimum length of the HS256 signing key, 256 bits, which also derives the keys of
+// the email tokens.
+const minJWTSecretLen = 32
+
+// minJWTSecretEntropy is the least entropy, in bits, estimated from the characters of the JWT secret. The
+// secrets generated with openssl rand -hex 16 or -base64 32 have well over it.
+const minJWTSecretEntropy = 80
+
+// jwtSecretPlaceholders are the words of the example secrets, which sign forgeable tokens once deployed.
+var jwtSecretPlaceholders = []string{"change-me", "changeme", "replace-me", "placeholder", "example"}
+
+// Storage drivers.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
 )
 
 // Config is the main configuration of app.
 type Config struct {
-	App   App
-	MySQL MySQL
+	App        App        `yaml:"app" toml:"app"`
+	Storage    Storage    `yaml:"storage" toml:"storage"`
+	MySQL      MySQL      `yaml:"mysql" toml:"mysql"`
+	Postgres   Postgres   `yaml:"postgres" toml:"postgres"`
+	Redis      Redis      `yaml:"redis" toml:"redis"`
+	RateLimit  RateLimit  `yaml:"rate_limit" toml:"rate_limit"`
+	Lockout    Lockout    `yaml:"lockout" toml:"lockout"`
+	Moderation Moderation `yaml:"moderation" toml:"moderation"`
+	OIDC       OIDC       `yaml:"oidc" toml:"oidc"`
+	Email      Email      `yaml:"email" toml:"email"`
+	Tracing    Tracing    `yaml:"tracing" toml:"tracing"`
 }
 
 // App contains app configuration.
 type App struct {
-	FrontendURL string
-	JWTSecret   string
-	Port        string
+	// FrontendURLs are the origins allowed by CORS, comma separated in env.
+	FrontendURLs []string `yaml:"frontend_urls" toml:"frontend_urls" env:"FRONTEND_URL"`
+	JWTSecret    string   `yaml:"jwt_secret" toml:"jwt_secret" env:"APP_JWT_SECRET"`
+	// JWTKeyFiles are the PEM RSA or Ed25519 keys of the access tokens, comma separated in env: the private
+	// keys sign and verify, the public keys only verify. The tokens are signed HS256 with JWTSecret when unset.
+	JWTKeyFiles []string `yaml:"jwt_key_files" toml:"jwt_key_files" env:"APP_JWT_KEY_FILES"`
+	// JWTVerifySecret also verifies the HS256 tokens of JWTSecret along with the key files, so that the switch
+	// to the keys does not log the users out.
+	JWTVerifySecret bool `yaml:"jwt_verify_secret" toml:"jwt_verify_secret" env:"APP_JWT_VERIFY_SECRET"`
+	Port            int  `yaml:"port" toml:"port" env:"APP_PORT"`
+	// GRPCPort serves the gRPC API when set.
+	GRPCPort int    `yaml:"grpc_port" toml:"grpc_port" env:"APP_GRPC_PORT"`
+	LogLevel string `yaml:"log_level" toml:"log_level" env:"APP_LOG_LEVEL"`
+	// MetricsPort serves the metrics on a separate port when set.
+	MetricsPort int `yaml:"metrics_port" toml:"metrics_port" env:"APP_METRICS_PORT"`
+	// ReadyTimeout bounds the dependency checks of the readiness endpoint.
+	ReadyTimeout time.Duration `yaml:"ready_timeout" toml:"ready_timeout" env:"APP_READY_TIMEOUT"`
+	// ShutdownTimeout bounds the wait for the in-flight requests on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"APP_SHUTDOWN_TIMEOUT"`
+	// AdminUserIDs are promoted to admin on their first login, comma separated in env, to bootstrap the first admins.
+	AdminUserIDs []int `yaml:"admin_user_ids" toml:"admin_user_ids" env:"APP_ADMIN_USER_IDS"`
+	// CacheMaxAge is how long the browsers and the shared caches keep the public movies before revalidating
+	// them, 0 revalidates them on each use. It is kept under a minute, when the time_ago of the movies changes.
+	CacheMaxAge time.Duration `yaml:"cache_max_age" toml:"cache_max_age" env:"APP_CACHE_MAX_AGE"`
+}
+
+// Storage contains storage configuration.
+type Storage struct {
+	// Driver is one of mysql, postgres, sqlite or memory, the memory storage is lost on restart.
+	Driver string `yaml:"driver" toml:"driver" env:"STORAGE_DRIVER"`
+	// SQLitePath is the database file of the sqlite driver.
+	SQLitePath string `yaml:"sqlite_path" toml:"sqlite_path" env:"SQLITE_PATH"`
+	// ReadYourWritesWindow routes the reads of a user to the writer for this long after its writes,
+	// so that a lagging reader does not hide them. Disabled when 0.
+	ReadYourWritesWindow time.Duration `yaml:"read_your_writes_window" toml:"read_your_writes_window" env:"STORAGE_READ_YOUR_WRITES_WINDOW"`
+	// ReaderCheckInterval is the interval of the reader pings, every read goes to the writer while they fail.
+	ReaderCheckInterval time.Duration `yaml:"reader_check_interval" toml:"reader_check_interval" env:"STORAGE_READER_CHECK_INTERVAL"`
 }
 
 // MySQL contains mysql configuration.
 type MySQL struct {
-	Username string
-	Password string
-	Read     string
-	Write    string
-	Port     string
-	DB       string
+	Username string `yaml:"username" toml:"username" env:"MYSQL_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"MYSQL_PASSWORD"`
+	Read     string `yaml:"read" toml:"read" env:"MYSQL_READ"`
+	Write    string `yaml:"write" toml:"write" env:"MYSQL_WRITE"`
+	Port     int    `yaml:"port" toml:"port" env:"MYSQL_PORT"`
+	DB       string `yaml:"db" toml:"db" env:"MYSQL_DB"`
+	// MaxOpenConns limits the open connections of each pool, unlimited when 0.
+	MaxOpenConns int `yaml:"max_open_conns" toml:"max_open_conns" env:"MYSQL_MAX_OPEN_CONNS"`
+	// MaxIdleConns limits the idle connections of each pool.
+	MaxIdleConns int `yaml:"max_idle_conns" toml:"max_idle_conns" env:"MYSQL_MAX_IDLE_CONNS"`
+	// ConnMaxLifetime closes the connections older than it, never when 0.
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"MYSQL_CONN_MAX_LIFETIME"`
+}
+
+// Postgres contains postgres configuration.
+type Postgres struct {
+	Username string `yaml:"username" toml:"username" env:"POSTGRES_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"POSTGRES_PASSWORD"`
+	Read     string `yaml:"read" toml:"read" env:"POSTGRES_READ"`
+	Write    string `yaml:"write" toml:"write" env:"POSTGRES_WRITE"`
+	Port     int    `yaml:"port" toml:"port" env:"POSTGRES_PORT"`
+	DB       string `yaml:"db" toml:"db" env:"POSTGRES_DB"`
+	// SSLMode is the libpq sslmode of the connections, e.g. disable, require or verify-full.
+	SSLMode         string        `yaml:"ssl_mode" toml:"ssl_mode" env:"POSTGRES_SSL_MODE"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"POSTGRES_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"POSTGRES_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"POSTGRES_CONN_MAX_LIFETIME"`
+}
+
+// Redis contains redis configuration, redis is only used when Host is set.
+type Redis struct {
+	Host     string `yaml:"host" toml:"host" env:"REDIS_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"REDIS_PORT"`
+	Password string `yaml:"password" toml:"password" env:"REDIS_PASSWORD"`
+	DB       int    `yaml:"db" toml:"db" env:"REDIS_DB"`
+}
+
+// RateLimit contains rate limiting configuration, the buckets are kept in redis when configured.
+// The limits are written <requests>/<period>, e.g. 10/1m, and a limit of 0 does not limit.
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// AllowList contains the IPs and CIDRs that are never limited, comma separated in env.
+	AllowList     []string `yaml:"allow_list" toml:"allow_list" env:"RATE_LIMIT_ALLOW_LIST"`
+	LoginPerIP    string   `yaml:"login_per_ip" toml:"login_per_ip" env:"RATE_LIMIT_LOGIN_PER_IP"`
+	RegisterPerIP string   `yaml:"register_per_ip" toml:"register_per_ip" env:"RATE_LIMIT_REGISTER_PER_IP"`
+	MoviesPerIP   string   `yaml:"movies_per_ip" toml:"movies_per_ip" env:"RATE_LIMIT_MOVIES_PER_IP"`
+	MoviesPerUser string   `yaml:"movies_per_user" toml:"movies_per_user" env:"RATE_LIMIT_MOVIES_PER_USER"`
+	VotesPerIP    string   `yaml:"votes_per_ip" toml:"votes_per_ip" env:"RATE_LIMIT_VOTES_PER_IP"`
+	VotesPerUser  string   `yaml:"votes_per_user" toml:"votes_per_user" env:"RATE_LIMIT_VOTES_PER_USER"`
+	// EmailPerIP limits the requests sending an email, e.g. a password reset.
+	EmailPerIP string `yaml:"email_per_ip" toml:"email_per_ip" env:"RATE_LIMIT_EMAIL_PER_IP"`
+}
+
+// limits returns the limits by env variable.
+func (r RateLimit) limits() []struct{ key, value string } {
+	return []struct{ key, value string }{
+		{"RATE_LIMIT_LOGIN_PER_IP", r.LoginPerIP},
+		{"RATE_LIMIT_REGISTER_PER_IP", r.RegisterPerIP},
+		{"RATE_LIMIT_MOVIES_PER_IP", r.MoviesPerIP},
+		{"RATE_LIMIT_MOVIES_PER_USER", r.MoviesPerUser},
+		{"RATE_LIMIT_VOTES_PER_IP", r.VotesPerIP},
+		{"RATE_LIMIT_VOTES_PER_USER", r.VotesPerUser},
+		{"RATE_LIMIT_EMAIL_PER_IP", r.EmailPerIP},
+	}
+}
+
+// Lockout contains the brute-force protection of the logins, the failures are kept in redis when configured.
+// The failures over the free attempts delay the next login by BaseDelay, doubling up to MaxDelay, and the
+// max attempts lock the username or the IP out for Duration.
+type Lockout struct {
+	Enabled              bool          `yaml:"enabled" toml:"enabled" env:"LOCKOUT_ENABLED"`
+	UsernameFreeAttempts int           `yaml:"username_free_attempts" toml:"username_free_attempts" env:"LOCKOUT_USERNAME_FREE_ATTEMPTS"`
+	UsernameMaxAttempts  int           `yaml:"username_max_attempts" toml:"username_max_attempts" env:"LOCKOUT_USERNAME_MAX_ATTEMPTS"`
+	IPFreeAttempts       int           `yaml:"ip_free_attempts" toml:"ip_free_attempts" env:"LOCKOUT_IP_FREE_ATTEMPTS"`
+	IPMaxAttempts        int           `yaml:"ip_max_attempts" toml:"ip_max_attempts" env:"LOCKOUT_IP_MAX_ATTEMPTS"`
+	BaseDelay            time.Duration `yaml:"base_delay" toml:"base_delay" env:"LOCKOUT_BASE_DELAY"`
+	MaxDelay             time.Duration `yaml:"max_delay" toml:"max_delay" env:"LOCKOUT_MAX_DELAY"`
+	Duration             time.Duration `yaml:"duration" toml:"duration" env:"LOCKOUT_DURATION"`
+}
+
+// Moderation contains the moderation of the movies.
+type Moderation struct {
+	// AutoHideReports is the number of open reports that hides a movie until a moderator reviews it, zero disables it.
+	AutoHideReports int `yaml:"auto_hide_reports" toml:"auto_hide_reports" env:"MODERATION_AUTO_HIDE_REPORTS"`
+	// BannedWords contains the words and phrases rejected in the submitted movies, comma separated in env.
+	BannedWords []string `yaml:"banned_words" toml:"banned_words" env:"MODERATION_BANNED_WORDS"`
+	// RejectLinks rejects the movies with a link in their title or more than MaxLinks links in their description.
+	RejectLinks bool `yaml:"reject_links" toml:"reject_links" env:"MODERATION_REJECT_LINKS"`
+	// MaxLinks is the number of links allowed in the description of a movie, the titles allow none.
+	MaxLinks int `yaml:"max_links" toml:"max_links" env:"MODERATION_MAX_LINKS"`
+	// MaxMoviesPerHour is the number of movies a user can submit per hour, zero disables it.
+	MaxMoviesPerHour int `yaml:"max_movies_per_hour" toml:"max_movies_per_hour" env:"MODERATION_MAX_MOVIES_PER_HOUR"`
+	// FlagSpam queues the movies which look like spam for moderation.
+	FlagSpam bool `yaml:"flag_spam" toml:"flag_spam" env:"MODERATION_FLAG_SPAM"`
+}
+
+// OIDC contains the login with external identity providers, a provider is enabled when its client id is set.
+// The pending logins are kept in redis when configured.
+type OIDC struct {
+	// RedirectURL is the page of the frontend receiving the code and the state from the providers.
+	RedirectURL string `yaml:"redirect_url" toml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	// AutoCreate creates an account on the first login of an identity linked to no account.
+	AutoCreate bool `yaml:"auto_create" toml:"auto_create" env:"OIDC_AUTO_CREATE"`
+	// StateTTL bounds the time between the redirection to a provider and its callback.
+	StateTTL           time.Duration `yaml:"state_ttl" toml:"state_ttl" env:"OIDC_STATE_TTL"`
+	GitHubClientID     string        `yaml:"github_client_id" toml:"github_client_id" env:"OIDC_GITHUB_CLIENT_ID"`
+	GitHubClientSecret string        `yaml:"github_client_secret" toml:"github_client_secret" env:"OIDC_GITHUB_CLIENT_SECRET"`
+	GoogleClientID     string        `yaml:"google_client_id" toml:"google_client_id" env:"OIDC_GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string        `yaml:"google_client_secret" toml:"google_client_secret" env:"OIDC_GOOGLE_CLIENT_SECRET"`
+	// ProviderName, Issuer, ClientID and ClientSecret describe any other OpenID Connect provider, it is
+	// discovered from its issuer.
+	ProviderName string `yaml:"provider_name" toml:"provider_name" env:"OIDC_PROVIDER_NAME"`
+	Issuer       string `yaml:"issuer" toml:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string `yaml:"client_id" toml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret" env:"OIDC_CLIENT_SECRET"`
+}
+
+// providers returns the client credentials of the enabled providers by env prefix.
+func (o OIDC) providers() []struct{ prefix, id, secret string } {
+	var enabled []struct{ prefix, id, secret string }
+	for _, p := range []struct{ prefix, id, secret string }{
+		{"OIDC_GITHUB", o.GitHubClientID, o.GitHubClientSecret},
+		{"OIDC_GOOGLE", o.GoogleClientID, o.GoogleClientSecret},
+		{"OIDC", o.ClientID, o.ClientSecret},
+	} {
+		if p.id != "" {
+			enabled = append(enabled, p)
+		}
+	}
+
+	return enabled
+}
+
+// Email contains the emails verifying the addresses and resetting the passwords, they are sent with SMTPHost
+// when set and only logged otherwise.
+type Email struct {
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host" env:"EMAIL_SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" toml:"smtp_port" env:"EMAIL_SMTP_PORT"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username" env:"EMAIL_SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password" env:"EMAIL_SMTP_PASSWORD"`
+	// From is the sender of the emails, e.g. MovieRama <no-reply@movierama.dev>.
+	From string `yaml:"from" toml:"from" env:"EMAIL_FROM"`
+	// VerifyURL and ResetURL are the pages of the frontend receiving the tokens in their token parameter.
+	VerifyURL string `yaml:"verify_url" toml:"verify_url" env:"EMAIL_VERIFY_URL"`
+	ResetURL  string `yaml:"reset_url" toml:"reset_url" env:"EMAIL_RESET_URL"`
+	// VerifyTTL and ResetTTL bound the validity of the tokens.
+	VerifyTTL time.Duration `yaml:"verify_ttl" toml:"verify_ttl" env:"EMAIL_VERIFY_TTL"`
+	ResetTTL  time.Duration `yaml:"reset_ttl" toml:"reset_ttl" env:"EMAIL_RESET_TTL"`
+	// RequireVerified requires an email on registration and denies the login until it is verified.
+	RequireVerified bool `yaml:"require_verified" toml:"require_verified" env:"EMAIL_REQUIRE_VERIFIED"`
+}
+
+// Tracing contains tracing configuration, spans are only exported when OTLPEndpoint is set.
+type Tracing struct {
+	OTLPEndpoint string `yaml:"otlp_endpoint" toml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" toml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+}
+
+// Error lists the problems of an invalid config.
+type Error struct {
+	Problems []string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Default returns the config used for the settings that are not set.
+func Default() *Config {
+	return &Config{
+		App: App{
+			Port:            1323,
+			LogLevel:        "info",
+			ReadyTimeout:    2 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			CacheMaxAge:     5 * time.Second,
+		},
+		Storage: Storage{
+			Driver:               DriverMySQL,
+			SQLitePath:           "movierama.db",
+			ReadYourWritesWindow: 5 * time.Second,
+			ReaderCheckInterval:  5 * time.Second,
+		},
+		MySQL: MySQL{
+			Port:            3306,
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Postgres: Postgres{
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Redis: Redis{
+			Port: 6379,
+		},
+		RateLimit: RateLimit{
+			Enabled:       true,
+			LoginPerIP:    "10/1m",
+			RegisterPerIP: "5/1h",
+			MoviesPerIP:   "30/1h",
+			MoviesPerUser: "10/1h",
+			VotesPerIP:    "120/1m",
+			VotesPerUser:  "60/1m",
+			EmailPerIP:    "5/1h",
+		},
+		Lockout: Lockout{
+			Enabled:              true,
+			UsernameFreeAttempts: 3,
+			UsernameMaxAttempts:  10,
+			IPFreeAttempts:       10,
+			IPMaxAttempts:        50,
+			BaseDelay:            time.Second,
+			MaxDelay:             time.Minute,
+			Duration:             15 * time.Minute,
+		},
+		Moderation: Moderation{
+			AutoHideReports:  5,
+			RejectLinks:      true,
+			MaxLinks:         2,
+			MaxMoviesPerHour: 10,
+			FlagSpam:         true,
+		},
+		OIDC: OIDC{
+			AutoCreate:   true,
+			StateTTL:     10 * time.Minute,
+			ProviderName: "oidc",
+		},
+		Email: Email{
+			SMTPPort:  587,
+			From:      "MovieRama <no-reply@movierama.dev>",
+			VerifyURL: "http://localhost:4200/verify-email",
+			ResetURL:  "http://localhost:4200/reset-password",
+			VerifyTTL: 48 * time.Hour,
+			ResetTTL:  time.Hour,
+		},
+	}
+}
+
+// New constructor, it layers the optional YAML or TOML file over the defaults and the
+// non-empty env variables over the file. It fails with every problem of the config at once.
+func New(file string) (*Config, error) {
+	cfg := Default()
+	if file != "" {
+		if err := cfg.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	problems := loadEnv(reflect.ValueOf(cfg).Elem())
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+// loadFile decodes the file over cfg, its format is picked by extension.
+func (cfg *Config) loadFile(file string) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch ext := filepath.Ext(file); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	case ".toml":
+		var md toml.MetaData
+		if md, err = toml.Decode(string(b), cfg); err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", md.Undecoded())
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q", file, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", file, err)
+	}
+
+	return nil
 }
 
-// New constructor
-func New() *Config {
-	cfg := &Config{}
-	cfg.setAppConfig()
-	cfg.setMySQLConfig()
+// loadEnv sets the fields of v from the env variables of their env tag.
+func loadEnv(v reflect.Value) []string {
+	var problems []string
+	for i := 0; i < v.NumField(); i++ {
+		field, f := v.Field(i), v.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			problems = append(problems, loadEnv(field)...)
+			continue
+		}
+		key := f.Tag.Get("env")
+		value := os.Getenv(key)
+		if key == "" || value == "" {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
 
-	return cfg
+	return problems
 }
 
-// SetAppConfig creates an App struct.
-func (cfg *Config) setAppConfig() {
-	cfg.App = App{
-		FrontendURL: os.Getenv("FRONTEND_URL"),
-		JWTSecret:   os.Getenv("APP_JWT_SECRET"),
-		Port:        os.Getenv("APP_PORT"),
+func setField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		field.SetInt(int64(d))
+	case []string:
+		var list []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case []int:
+		var list []int
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("%q is not an integer", s)
+			}
+			list = append(list, n)
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
 	}
+
+	return nil
 }
 
-// SetMySQLConfig creates a MySQL config struct.
-func (cfg *Config) setMySQLConfig() {
-	cfg.MySQL = MySQL{
-		Username: os.Getenv("MYSQL_USERNAME"),
-		Password: os.Getenv("MYSQL_PASSWORD"),
-		Read:     os.Getenv("MYSQL_READ"),
-		Write:    os.Getenv("MYSQL_WRITE"),
-		Port:     os.Getenv("MYSQL_PORT"),
-		DB:       os.Getenv("MYSQL_DB"),
+// validate returns the invalid and insecure settings of cfg.
+func (cfg *Config) validate() []string {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(cfg.App.FrontendURLs) == 0 {
+		addf("FRONTEND_URL is required")
+	}
+	for _, origin := range cfg.App.FrontendURLs {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("FRONTEND_URL: %q is not an http(s) origin", origin)
+		}
+	}
+	switch secret := cfg.App.JWTSecret; {
+	case len(secret) < minJWTSecretLen:
+		addf("APP_JWT_SECRET must be at least %d characters", minJWTSecretLen)
+	case isPlaceholder(secret):
+		addf("APP_JWT_SECRET is a placeholder, generate one with openssl rand -base64 32")
+	case isRepeated(secret) || entropyBits(secret) < minJWTSecretEntropy:
+		addf("APP_JWT_SECRET is too predictable, generate one with openssl rand -base64 32")
+	}
+	if cfg.App.JWTVerifySecret && len(cfg.App.JWTKeyFiles) == 0 {
+		addf("APP_JWT_VERIFY_SECRET requires APP_JWT_KEY_FILES")
+	}
+	if !validPort(cfg.App.Port) {
+		addf("APP_PORT: %d is not a valid port", cfg.App.Port)
+	}
+	if cfg.App.GRPCPort != 0 && !validPort(cfg.App.GRPCPort) {
+		addf("APP_GRPC_PORT: %d is not a valid port", cfg.App.GRPCPort)
 	}
+	if cfg.App.MetricsPort != 0 && !validPort(cfg.App.MetricsPort) {
+		addf("APP_METRICS_PORT: %d is not a valid port", cfg.App.MetricsPort)
+	}
+	if cfg.App.GRPCPort != 0 && cfg.App.GRPCPort == cfg.App.Port {
+		addf("APP_GRPC_PORT must differ from APP_PORT")
+	}
+	if cfg.App.MetricsPort != 0 && (cfg.App.MetricsPort == cfg.App.Port || cfg.App.MetricsPort == cfg.App.GRPCPort) {
+		addf("APP_METRICS_PORT must differ from APP_PORT and APP_GRPC_PORT")
+	}
+	if _, err := zapcore.ParseLevel(cfg.App.LogLevel); err != nil {
+		addf("APP_LOG_LEVEL: %q is not a log level", cfg.App.LogLevel)
+	}
+	if cfg.App.ReadyTimeout <= 0 {
+		addf("APP_READY_TIMEOUT must be positive")
+	}
+	if cfg.App.ShutdownTimeout <= 0 {
+		addf("APP_SHUTDOWN_TIMEOUT must be positive")
+	}
+	if cfg.App.CacheMaxAge < 0 {
+		addf("APP_CACHE_MAX_AGE must not be negative")
+	}
+
+	switch cfg.Storage.Driver {
+	case DriverMySQL:
+		m := cfg.MySQL
+		problems = append(problems, database{
+			prefix: "MYSQL", username: m.Username, read: m.Read, write: m.Write, db: m.DB, port: m.Port,
+			maxOpenConns: m.MaxOpenConns, maxIdleConns: m.MaxIdleConns, connMaxLifetime: m.ConnMaxLifetime,
+		}.validate()...)
+	case DriverPostgres:
+		p := cfg.Postgres
+		problems = append(problems, database{
+			prefix: "POSTGRES", username: p.Username, read: p.Read, write: p.Write, db: p.DB, port: p.Port,
+			maxOpenConns: p.MaxOpenConns, maxIdleConns: p.MaxIdleConns, connMaxLifetime: p.ConnMaxLifetime,
+		}.validate()...)
+		if !validSSLModes[p.SSLMode] {
+			addf("POSTGRES_SSL_MODE: %q is not a valid sslmode", p.SSLMode)
+		}
+	case DriverSQLite:
+		if cfg.Storage.SQLitePath == "" {
+			addf("SQLITE_PATH is required")
+		}
+	case DriverMemory:
+	default:
+		addf("STORAGE_DRIVER: %q is not one of mysql, postgres, sqlite or memory", cfg.Storage.Driver)
+	}
+	if cfg.Storage.ReadYourWritesWindow < 0 {
+		addf("STORAGE_READ_YOUR_WRITES_WINDOW must not be negative")
+	}
+	if cfg.Storage.ReaderCheckInterval <= 0 {
+		addf("STORAGE_READER_CHECK_INTERVAL must be positive")
+	}
+
+	if cfg.Redis.Host != "" {
+		if !validPort(cfg.Redis.Port) {
+			addf("REDIS_PORT: %d is not a valid port", cfg.Redis.Port)
+		}
+		if cfg.Redis.DB < 0 {
+			addf("REDIS_DB must not be negative")
+		}
+	}
+
+	if cfg.RateLimit.Enabled {
+		for _, l := range cfg.RateLimit.limits() {
+			if _, err := ratelimit.ParseLimit(l.value); err != nil {
+				addf("%s: %v", l.key, err)
+			}
+		}
+		if _, err := ratelimit.ParseAllowList(cfg.RateLimit.AllowList); err != nil {
+			addf("RATE_LIMIT_ALLOW_LIST: %v", err)
+		}
+	}
+
+	if l := cfg.Lockout; l.Enabled {
+		if l.UsernameFreeAttempts < 0 || l.IPFreeAttempts < 0 {
+			addf("LOCKOUT_USERNAME_FREE_ATTEMPTS and LOCKOUT_IP_FREE_ATTEMPTS must not be negative")
+		}
+		if l.UsernameMaxAttempts < 0 || l.IPMaxAttempts < 0 {
+			addf("LOCKOUT_USERNAME_MAX_ATTEMPTS and LOCKOUT_IP_MAX_ATTEMPTS must not be negative")
+		}
+		if l.BaseDelay <= 0 {
+			addf("LOCKOUT_BASE_DELAY must be positive")
+		}
+		if l.MaxDelay < l.BaseDelay {
+			addf("LOCKOUT_MAX_DELAY must not be less than LOCKOUT_BASE_DELAY")
+		}
+		if l.Duration <= 0 {
+			addf("LOCKOUT_DURATION must be positive")
+		}
+	}
+	if cfg.Moderation.AutoHideReports < 0 {
+		addf("MODERATION_AUTO_HIDE_REPORTS must not be negative")
+	}
+	if cfg.Moderation.MaxLinks < 0 {
+		addf("MODERATION_MAX_LINKS must not be negative")
+	}
+	if cfg.Moderation.MaxMoviesPerHour < 0 {
+		addf("MODERATION_MAX_MOVIES_PER_HOUR must not be negative")
+	}
+
+	if o := cfg.OIDC; len(o.providers()) > 0 {
+		if u, err := url.Parse(o.RedirectURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("OIDC_REDIRECT_URL: %q is not an http(s) URL", o.RedirectURL)
+		}
+		for _, p := range o.providers() {
+			if p.secret == "" {
+				addf("%s_CLIENT_SECRET is required with %s_CLIENT_ID", p.prefix, p.prefix)
+			}
+		}
+		if o.StateTTL <= 0 {
+			addf("OIDC_STATE_TTL must be positive")
+		}
+	}
+	if o := cfg.OIDC; o.ClientID != "" {
+		if u, err := url.Parse(o.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("OIDC_ISSUER: %q is not an http(s) URL", o.Issuer)
+		}
+		if o.ProviderName == "" || o.ProviderName == "github" || o.ProviderName == "google" {
+			addf("OIDC_PROVIDER_NAME: %q must be set and differ from github and google", o.ProviderName)
+		}
+	}
+
+	e := cfg.Email
+	if e.SMTPHost != "" && !validPort(e.SMTPPort) {
+		addf("EMAIL_SMTP_PORT: %d is not a valid port", e.SMTPPort)
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		addf("EMAIL_FROM: %q is not an email address", e.From)
+	}
+	for _, u := range []struct{ key, value string }{
+		{"EMAIL_VERIFY_URL", e.VerifyURL},
+		{"EMAIL_RESET_URL", e.ResetURL},
+	} {
+		if parsed, err := url.Parse(u.value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			addf("%s: %q is not an http(s) URL", u.key, u.value)
+		}
+	}
+	if e.VerifyTTL <= 0 || e.ResetTTL <= 0 {
+		addf("EMAIL_VERIFY_TTL and EMAIL_RESET_TTL must be positive")
+	}
+
+	return problems
+}
+
+// validSSLModes are the sslmode values of libpq.
+var validSSLModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// database holds the settings shared by the SQL database sections, their env variables start with prefix.
+type database struct {
+	prefix                    string
+	username, read, write, db string
+	port                      int
+	maxOpenConns              int
+	maxIdleConns              int
+	connMaxLifetime           time.Duration
+}
+
+// validate returns the problems of the database settings.
+func (d database) validate() []string {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, d.prefix+"_"+fmt.Sprintf(format, args...))
+	}
+
+	for _, required := range []struct{ key, value string }{
+		{"USERNAME", d.username},
+		{"READ", d.read},
+		{"WRITE", d.write},
+		{"DB", d.db},
+	} {
+		if required.value == "" {
+			addf("%s is required", required.key)
+		}
+	}
+	if !validPort(d.port) {
+		addf("PORT: %d is not a valid port", d.port)
+	}
+	if d.maxOpenConns < 0 {
+		addf("MAX_OPEN_CONNS must not be negative")
+	}
+	if d.maxIdleConns < 0 {
+		addf("MAX_IDLE_CONNS must not be negative")
+	}
+	if d.maxOpenConns > 0 && d.maxIdleConns > d.maxOpenConns {
+		addf("MAX_IDLE_CONNS must not exceed %s_MAX_OPEN_CONNS", d.prefix)
+	}
+	if d.connMaxLifetime < 0 {
+		addf("CONN_MAX_LIFETIME must not be negative")
+	}
+
+	return problems
+}
+
+// isPlaceholder reports whether the secret is an example secret.
+func isPlaceholder(secret string) bool {
+	secret = strings.ToLower(secret)
+	for _, word := range jwtSecretPlaceholders {
+		if strings.Contains(secret, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRepeated reports whether s repeats a shorter string, e.g. abcabcabc.
+func isRepeated(s string) bool {
+	for period := 1; period <= len(s)/2; period++ {
+		if len(s)%period == 0 && strings.Repeat(s[:period], len(s)/period) == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// entropyBits estimates the entropy of s from the frequencies of its characters.
+func entropyBits(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	bits := 0.0
+	for _, c := range counts {
+		bits -= float64(c) * math.Log2(float64(c)/float64(n))
+	}
+
+	return bits
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
 }
@@ -3,38 +3,450 @@ package config_test
 import (
 	"movierama/internal/config"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+const jwtSecret = "rHi5CBOCnyF2UQFgW5O2B6vMfJ1qHWm1"
+
+// setEnv sets the env variables of a valid config, overridden by env.
+func setEnv(t *testing.T, env map[string]string) {
+	vars := map[string]string{
+		"FRONTEND_URL":   "http://localhost",
+		"APP_JWT_SECRET": jwtSecret,
+		"MYSQL_USERNAME": "mysql_username",
+		"MYSQL_READ":     "mysql_read",
+		"MYSQL_WRITE":    "mysql_write",
+		"MYSQL_DB":       "movierama_test",
+	}
+	for k, v := range env {
+		vars[k] = v
+	}
+	for k, v := range vars {
+		t.Setenv(k, v)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
 func TestConfig_New(t *testing.T) {
-	os.Setenv("FRONTEND_URL", "http://localhost")
-	os.Setenv("APP_JWT_SECRET", "secret_key")
-	os.Setenv("APP_PORT", "appport")
-	os.Setenv("MYSQL_USERNAME", "mysql_username")
-	os.Setenv("MYSQL_PASSWORD", "mysql_password")
-	os.Setenv("MYSQL_READ", "mysql_read")
-	os.Setenv("MYSQL_WRITE", "mysql_write")
-	os.Setenv("MYSQL_PORT", "3306")
-	os.Setenv("MYSQL_DB", "movierama_test")
+	setEnv(t, map[string]string{
+		"FRONTEND_URL":                    "http://localhost, https://movierama.dev",
+		"APP_JWT_KEY_FILES":               "/keys/2026-10.pem, /keys/2026-11.pem",
+		"APP_JWT_VERIFY_SECRET":           "true",
+		"APP_PORT":                        "8080",
+		"APP_GRPC_PORT":                   "8081",
+		"APP_LOG_LEVEL":                   "debug",
+		"APP_METRICS_PORT":                "8082",
+		"APP_READY_TIMEOUT":               "500ms",
+		"APP_SHUTDOWN_TIMEOUT":            "30s",
+		"APP_ADMIN_USER_IDS":              "1, 2",
+		"APP_CACHE_MAX_AGE":               "1m",
+		"STORAGE_DRIVER":                  "mysql",
+		"SQLITE_PATH":                     "/data/movierama.db",
+		"STORAGE_READ_YOUR_WRITES_WINDOW": "10s",
+		"STORAGE_READER_CHECK_INTERVAL":   "1s",
+		"MYSQL_PASSWORD":                  "mysql_password",
+		"MYSQL_PORT":                      "3307",
+		"MYSQL_MAX_OPEN_CONNS":            "50",
+		"MYSQL_MAX_IDLE_CONNS":            "10",
+		"MYSQL_CONN_MAX_LIFETIME":         "1h",
+		"POSTGRES_USERNAME":               "postgres_username",
+		"POSTGRES_PASSWORD":               "postgres_password",
+		"POSTGRES_READ":                   "postgres_read",
+		"POSTGRES_WRITE":                  "postgres_write",
+		"POSTGRES_PORT":                   "5433",
+		"POSTGRES_DB":                     "movierama",
+		"POSTGRES_SSL_MODE":               "require",
+		"POSTGRES_MAX_OPEN_CONNS":         "30",
+		"REDIS_HOST":                      "redis",
+		"REDIS_PORT":                      "6380",
+		"REDIS_PASSWORD":                  "redis_password",
+		"REDIS_DB":                        "1",
+		"RATE_LIMIT_ALLOW_LIST":           "10.0.0.0/8, 127.0.0.1",
+		"RATE_LIMIT_LOGIN_PER_IP":         "5/1m",
+		"RATE_LIMIT_VOTES_PER_USER":       "0",
+		"LOCKOUT_USERNAME_MAX_ATTEMPTS":   "5",
+		"LOCKOUT_DURATION":                "1h",
+		"MODERATION_AUTO_HIDE_REPORTS":    "3",
+		"MODERATION_BANNED_WORDS":         "spoiler, buy now",
+		"MODERATION_REJECT_LINKS":         "false",
+		"MODERATION_FLAG_SPAM":            "false",
+		"OIDC_REDIRECT_URL":               "https://movierama.dev/auth/callback",
+		"OIDC_AUTO_CREATE":                "false",
+		"OIDC_GITHUB_CLIENT_ID":           "github_id",
+		"OIDC_GITHUB_CLIENT_SECRET":       "github_secret",
+		"EMAIL_SMTP_HOST":                 "smtp.movierama.dev",
+		"EMAIL_SMTP_USERNAME":             "smtp_username",
+		"EMAIL_SMTP_PASSWORD":             "smtp_password",
+		"EMAIL_FROM":                      "no-reply@movierama.dev",
+		"EMAIL_RESET_TTL":                 "30m",
+		"EMAIL_REQUIRE_VERIFIED":          "true",
+		"TRACING_OTLP_ENDPOINT":           "localhost:4318",
+		"TRACING_OTLP_INSECURE":           "true",
+	})
 
 	expCfg := &config.Config{
 		App: config.App{
-			FrontendURL: "http://localhost",
-			JWTSecret:   "secret_key",
-			Port:        "appport",
+			FrontendURLs:    []string{"http://localhost", "https://movierama.dev"},
+			JWTSecret:       jwtSecret,
+			JWTKeyFiles:     []string{"/keys/2026-10.pem", "/keys/2026-11.pem"},
+			JWTVerifySecret: true,
+			Port:            8080,
+			GRPCPort:        8081,
+			LogLevel:        "debug",
+			MetricsPort:     8082,
+			ReadyTimeout:    500 * time.Millisecond,
+			ShutdownTimeout: 30 * time.Second,
+			AdminUserIDs:    []int{1, 2},
+			CacheMaxAge:     time.Minute,
+		},
+		Storage: config.Storage{
+			Driver:               config.DriverMySQL,
+			SQLitePath:           "/data/movierama.db",
+			ReadYourWritesWindow: 10 * time.Second,
+			ReaderCheckInterval:  time.Second,
 		},
 		MySQL: config.MySQL{
-			Username: "mysql_username",
-			Password: "mysql_password",
-			Read:     "mysql_read",
-			Write:    "mysql_write",
-			Port:     "3306",
-			DB:       "movierama_test",
+			Username:        "mysql_username",
+			Password:        "mysql_password",
+			Read:            "mysql_read",
+			Write:           "mysql_write",
+			Port:            3307,
+			DB:              "movierama_test",
+			MaxOpenConns:    50,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: time.Hour,
+		},
+		Postgres: config.Postgres{
+			Username:        "postgres_username",
+			Password:        "postgres_password",
+			Read:            "postgres_read",
+			Write:           "postgres_write",
+			Port:            5433,
+			DB:              "movierama",
+			SSLMode:         "require",
+			MaxOpenConns:    30,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Redis: config.Redis{
+			Host:     "redis",
+			Port:     6380,
+			Password: "redis_password",
+			DB:       1,
+		},
+		RateLimit: config.RateLimit{
+			Enabled:       true,
+			AllowList:     []string{"10.0.0.0/8", "127.0.0.1"},
+			LoginPerIP:    "5/1m",
+			RegisterPerIP: "5/1h",
+			MoviesPerIP:   "30/1h",
+			MoviesPerUser: "10/1h",
+			VotesPerIP:    "120/1m",
+			VotesPerUser:  "0",
+			EmailPerIP:    "5/1h",
+		},
+		Lockout: config.Lockout{
+			Enabled:              true,
+			UsernameFreeAttempts: 3,
+			UsernameMaxAttempts:  5,
+			IPFreeAttempts:       10,
+			IPMaxAttempts:        50,
+			BaseDelay:            time.Second,
+			MaxDelay:             time.Minute,
+			Duration:             time.Hour,
+		},
+		Moderation: config.Moderation{
+			AutoHideReports:  3,
+			BannedWords:      []string{"spoiler", "buy now"},
+			MaxLinks:         2,
+			MaxMoviesPerHour: 10,
+		},
+		OIDC: config.OIDC{
+			RedirectURL:        "https://movierama.dev/auth/callback",
+			StateTTL:           10 * time.Minute,
+			GitHubClientID:     "github_id",
+			GitHubClientSecret: "github_secret",
+			ProviderName:       "oidc",
+		},
+		Email: config.Email{
+			SMTPHost:        "smtp.movierama.dev",
+			SMTPPort:        587,
+			SMTPUsername:    "smtp_username",
+			SMTPPassword:    "smtp_password",
+			From:            "no-reply@movierama.dev",
+			VerifyURL:       "http://localhost:4200/verify-email",
+			ResetURL:        "http://localhost:4200/reset-password",
+			VerifyTTL:       48 * time.Hour,
+			ResetTTL:        30 * time.Minute,
+			RequireVerified: true,
+		},
+		Tracing: config.Tracing{
+			OTLPEndpoint: "localhost:4318",
+			OTLPInsecure: true,
 		},
 	}
 
-	cfg := config.New()
+	cfg, err := config.New("")
+	require.NoError(t, err)
 	assert.Equal(t, expCfg, cfg)
 }
+
+func TestConfig_New_Defaults(t *testing.T) {
+	setEnv(t, nil)
+
+	cfg, err := config.New("")
+	require.NoError(t, err)
+	def := config.Default()
+	assert.Equal(t, def.App.Port, cfg.App.Port)
+	assert.Equal(t, "info", cfg.App.LogLevel)
+	assert.Equal(t, 2*time.Second, cfg.App.ReadyTimeout)
+	assert.Equal(t, 10*time.Second, cfg.App.ShutdownTimeout)
+	assert.Equal(t, 5*time.Second, cfg.App.CacheMaxAge)
+	assert.Equal(t, def.MySQL.Port, cfg.MySQL.Port)
+	assert.Equal(t, def.MySQL.MaxOpenConns, cfg.MySQL.MaxOpenConns)
+	assert.Equal(t, config.DriverMySQL, cfg.Storage.Driver)
+	assert.Equal(t, 5*time.Second, cfg.Storage.ReadYourWritesWindow)
+	assert.Zero(t, cfg.App.GRPCPort)
+	assert.Empty(t, cfg.Redis.Host)
+}
+
+func TestConfig_New_File(t *testing.T) {
+	tests := map[string]struct {
+		name    string
+		content string
+	}{
+		"should load a YAML file": {
+			name: "movierama.yaml",
+			content: `
+app:
+  frontend_urls: ["http://file.local"]
+  port: 9000
+  log_level: warn
+  shutdown_timeout: 20s
+mysql:
+  max_open_conns: 40
+`,
+		},
+		"should load a TOML file": {
+			name: "movierama.toml",
+			content: `
+[app]
+frontend_urls = ["http://file.local"]
+port = 9000
+log_level = "warn"
+shutdown_timeout = "20s"
+
+[mysql]
+max_open_conns = 40
+`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The env variables override the file.
+			setEnv(t, map[string]string{"FRONTEND_URL": "", "APP_LOG_LEVEL": "error"})
+
+			cfg, err := config.New(writeFile(t, tt.name, tt.content))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"http://file.local"}, cfg.App.FrontendURLs)
+			assert.Equal(t, 9000, cfg.App.Port)
+			assert.Equal(t, "error", cfg.App.LogLevel)
+			assert.Equal(t, 20*time.Second, cfg.App.ShutdownTimeout)
+			assert.Equal(t, 40, cfg.MySQL.MaxOpenConns)
+			assert.Equal(t, config.Default().MySQL.MaxIdleConns, cfg.MySQL.MaxIdleConns)
+		})
+	}
+}
+
+func TestConfig_New_Storage(t *testing.T) {
+	// The MySQL settings are only required by the mysql driver.
+	for _, driver := range []string{config.DriverPostgres, config.DriverSQLite, config.DriverMemory} {
+		t.Run(driver, func(t *testing.T) {
+			setEnv(t, map[string]string{
+				"STORAGE_DRIVER": driver, "MYSQL_USERNAME": "", "MYSQL_READ": "",
+				"POSTGRES_USERNAME": "postgres", "POSTGRES_READ": "db", "POSTGRES_WRITE": "db", "POSTGRES_DB": "movierama",
+			})
+
+			cfg, err := config.New("")
+			require.NoError(t, err)
+			assert.Equal(t, driver, cfg.Storage.Driver)
+		})
+	}
+}
+
+func TestConfig_New_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		env         map[string]string
+		file        string
+		expProblems []string
+		expErr      bool
+	}{
+		"should list every problem": {
+			env: map[string]string{
+				"FRONTEND_URL":         "localhost:4200",
+				"APP_JWT_SECRET":       "secret",
+				"APP_PORT":             "http",
+				"APP_GRPC_PORT":        "1323",
+				"APP_LOG_LEVEL":        "verbose",
+				"APP_SHUTDOWN_TIMEOUT": "soon",
+				"MYSQL_USERNAME":       "",
+				"MYSQL_MAX_OPEN_CONNS": "5",
+				"MYSQL_MAX_IDLE_CONNS": "10",
+				"REDIS_HOST":           "redis",
+				"REDIS_PORT":           "70000",
+			},
+			expProblems: []string{
+				`APP_PORT: "http" is not an integer`,
+				`APP_SHUTDOWN_TIMEOUT: "soon" is not a duration`,
+				`FRONTEND_URL: "localhost:4200" is not an http(s) origin`,
+				"APP_JWT_SECRET must be at least 32 characters",
+				"APP_GRPC_PORT must differ from APP_PORT",
+				`APP_LOG_LEVEL: "verbose" is not a log level`,
+				"MYSQL_USERNAME is required",
+				"MYSQL_MAX_IDLE_CONNS must not exceed MYSQL_MAX_OPEN_CONNS",
+				"REDIS_PORT: 70000 is not a valid port",
+			},
+		},
+		"should reject the placeholder secret": {
+			env:         map[string]string{"APP_JWT_SECRET": "change-me-to-a-random-32-byte-secret"},
+			expProblems: []string{"APP_JWT_SECRET is a placeholder, generate one with openssl rand -base64 32"},
+		},
+		"should reject a repeated secret": {
+			env:         map[string]string{"APP_JWT_SECRET": "0123456789abcdef0123456789abcdef"},
+			expProblems: []string{"APP_JWT_SECRET is too predictable, generate one with openssl rand -base64 32"},
+		},
+		"should reject a low entropy secret": {
+			env:         map[string]string{"APP_JWT_SECRET": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaab"},
+			expProblems: []string{"APP_JWT_SECRET is too predictable, generate one with openssl rand -base64 32"},
+		},
+		"should require the key files to verify the secret": {
+			env:         map[string]string{"APP_JWT_VERIFY_SECRET": "true"},
+			expProblems: []string{"APP_JWT_VERIFY_SECRET requires APP_JWT_KEY_FILES"},
+		},
+		"should require the frontend url": {
+			env:         map[string]string{"FRONTEND_URL": ""},
+			expProblems: []string{"FRONTEND_URL is required"},
+		},
+		"should reject a negative cache max age": {
+			env:         map[string]string{"APP_CACHE_MAX_AGE": "-5s"},
+			expProblems: []string{"APP_CACHE_MAX_AGE must not be negative"},
+		},
+		"should reject an unknown storage driver": {
+			env:         map[string]string{"STORAGE_DRIVER": "mongo"},
+			expProblems: []string{`STORAGE_DRIVER: "mongo" is not one of mysql, postgres, sqlite or memory`},
+		},
+		"should reject invalid read routing settings": {
+			env: map[string]string{"STORAGE_READ_YOUR_WRITES_WINDOW": "-1s", "STORAGE_READER_CHECK_INTERVAL": "0s"},
+			expProblems: []string{
+				"STORAGE_READ_YOUR_WRITES_WINDOW must not be negative",
+				"STORAGE_READER_CHECK_INTERVAL must be positive",
+			},
+		},
+		"should validate the postgres settings of the postgres driver": {
+			env: map[string]string{
+				"STORAGE_DRIVER":          "postgres",
+				"POSTGRES_USERNAME":       "postgres",
+				"POSTGRES_READ":           "postgres",
+				"POSTGRES_SSL_MODE":       "always",
+				"POSTGRES_MAX_IDLE_CONNS": "-1",
+			},
+			expProblems: []string{
+				"POSTGRES_WRITE is required",
+				"POSTGRES_DB is required",
+				"POSTGRES_MAX_IDLE_CONNS must not be negative",
+				`POSTGRES_SSL_MODE: "always" is not a valid sslmode`,
+			},
+		},
+		"should reject invalid rate limits": {
+			env: map[string]string{"RATE_LIMIT_MOVIES_PER_USER": "10 per hour", "RATE_LIMIT_ALLOW_LIST": "internal"},
+			expProblems: []string{
+				`RATE_LIMIT_MOVIES_PER_USER: "10 per hour" is not <requests>/<period>`,
+				`RATE_LIMIT_ALLOW_LIST: "internal" is not an IP or a CIDR`,
+			},
+		},
+		"should reject invalid lockout delays": {
+			env: map[string]string{"LOCKOUT_BASE_DELAY": "2m", "LOCKOUT_IP_MAX_ATTEMPTS": "-1"},
+			expProblems: []string{
+				"LOCKOUT_USERNAME_MAX_ATTEMPTS and LOCKOUT_IP_MAX_ATTEMPTS must not be negative",
+				"LOCKOUT_MAX_DELAY must not be less than LOCKOUT_BASE_DELAY",
+			},
+		},
+		"should reject negative moderation settings": {
+			env: map[string]string{
+				"MODERATION_AUTO_HIDE_REPORTS":   "-1",
+				"MODERATION_MAX_LINKS":           "-1",
+				"MODERATION_MAX_MOVIES_PER_HOUR": "-1",
+			},
+			expProblems: []string{
+				"MODERATION_AUTO_HIDE_REPORTS must not be negative",
+				"MODERATION_MAX_LINKS must not be negative",
+				"MODERATION_MAX_MOVIES_PER_HOUR must not be negative",
+			},
+		},
+		"should reject incomplete identity providers": {
+			env: map[string]string{
+				"OIDC_GOOGLE_CLIENT_ID": "google_id",
+				"OIDC_CLIENT_ID":        "client_id",
+				"OIDC_CLIENT_SECRET":    "client_secret",
+				"OIDC_PROVIDER_NAME":    "github",
+			},
+			expProblems: []string{
+				`OIDC_REDIRECT_URL: "" is not an http(s) URL`,
+				"OIDC_GOOGLE_CLIENT_SECRET is required with OIDC_GOOGLE_CLIENT_ID",
+				`OIDC_ISSUER: "" is not an http(s) URL`,
+				`OIDC_PROVIDER_NAME: "github" must be set and differ from github and google`,
+			},
+		},
+		"should reject invalid email settings": {
+			env: map[string]string{
+				"EMAIL_SMTP_HOST":  "smtp.movierama.dev",
+				"EMAIL_SMTP_PORT":  "70000",
+				"EMAIL_FROM":       "MovieRama",
+				"EMAIL_VERIFY_URL": "/verify-email",
+				"EMAIL_RESET_TTL":  "-1h",
+			},
+			expProblems: []string{
+				"EMAIL_SMTP_PORT: 70000 is not a valid port",
+				`EMAIL_FROM: "MovieRama" is not an email address`,
+				`EMAIL_VERIFY_URL: "/verify-email" is not an http(s) URL`,
+				"EMAIL_VERIFY_TTL and EMAIL_RESET_TTL must be positive",
+			},
+		},
+		"should reject unknown file keys": {
+			file:   "app:\n  prot: 1323\n",
+			expErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			file := ""
+			if tt.file != "" {
+				file = writeFile(t, "movierama.yml", tt.file)
+			}
+
+			cfg, err := config.New(file)
+			assert.Nil(t, cfg)
+			require.Error(t, err)
+			if tt.expErr {
+				return
+			}
+			var cfgErr *config.Error
+			require.ErrorAs(t, err, &cfgErr)
+			assert.Equal(t, tt.expProblems, cfgErr.Problems)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Package contentfilter checks the submitted movies with a pipeline of rules, each rule allows a
+// submission, flags it for moderation or rejects it.
+package contentfilter
+
+import (
+	"context"
+	"fmt"
+)
+
+// Verdict is the outcome of a rule.
+type Verdict int
+
+// Verdicts, from the mildest to the strictest.
+const (
+	// Allow accepts the submission.
+	Allow Verdict = iota
+	// Flag accepts the submission and queues it for moderation.
+	Flag
+	// Reject refuses the submission.
+	Reject
+)
+
+// String returns the name of the verdict.
+func (v Verdict) String() string {
+	switch v {
+	case Flag:
+		return "flag"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// Submission is a movie submitted by a user.
+type Submission struct {
+	UserID      int
+	Title       string
+	Description string
+}
+
+// Result is the verdict of a rule.
+type Result struct {
+	Verdict Verdict
+	// Rule is the name of the rule which gave the verdict, set by the pipeline.
+	Rule string
+	// Reason is shown to the user on a rejection and to the moderators on a flag.
+	Reason string
+	// Category is the report category of a flag, "other" when empty.
+	Category string
+}
+
+// Rule checks a submission, custom classifiers plug into the pipeline by implementing it.
+type Rule interface {
+	// Name identifies the rule in the results, logs and metrics.
+	Name() string
+	// Check returns the verdict of the rule, an error when the submission could not be checked.
+	Check(ctx context.Context, s *Submission) (Result, error)
+}
+
+// RejectedError is returned for a rejected submission.
+type RejectedError struct {
+	Rule   string
+	Reason string
+}
+
+// Error returns the reason of the rejection.
+func (e *RejectedError) Error() string {
+	return e.Reason
+}
+
+// Pipeline runs rules in order.
+type Pipeline struct {
+	rules []Rule
+}
+
+// New constructor.
+func New(rules ...Rule) *Pipeline {
+	return &Pipeline{rules: rules}
+}
+
+// Use appends rules to the pipeline.
+func (p *Pipeline) Use(rules ...Rule) *Pipeline {
+	p.rules = append(p.rules, rules...)
+
+	return p
+}
+
+// Check runs the rules in order and stops at the first rejection, returned as a *RejectedError.
+// Otherwise it returns the first flag, or an Allow result when no rule flags the submission.
+func (p *Pipeline) Check(ctx context.Context, s *Submission) (Result, error) {
+	result := Result{Verdict: Allow}
+	for _, rule := range p.rules {
+		res, err := rule.Check(ctx, s)
+		if err != nil {
+			return Result{}, fmt.Errorf("content filter %s: %w", rule.Name(), err)
+		}
+		res.Rule = rule.Name()
+		switch res.Verdict {
+		case Reject:
+			return res, &RejectedError{Rule: res.Rule, Reason: res.Reason}
+		case Flag:
+			if result.Verdict == Allow {
+				if res.Category == "" {
+					res.Category = "other"
+				}
+				result = res
+			}
+		}
+	}
+
+	return result, nil
+}
+
+type ruleFunc struct {
+	name  string
+	check func(ctx context.Context, s *Submission) (Result, error)
+}
+
+// NewRule adapts a function to a Rule.
+func NewRule(name string, check func(ctx context.Context, s *Submission) (Result, error)) Rule {
+	return &ruleFunc{name: name, check: check}
+}
+
+func (r *ruleFunc) Name() string {
+	return r.name
+}
+
+func (r *ruleFunc) Check(ctx context.Context, s *Submission) (Result, error) {
+	return r.check(ctx, s)
+}
@@ -0,0 +1,100 @@
+package contentfilter_test
+
+import (
+	"context"
+	"errors"
+	"movierama/internal/contentfilter"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func verdict(name string, v contentfilter.Verdict, reason string, calls *[]string) contentfilter.Rule {
+	return contentfilter.NewRule(name, func(context.Context, *contentfilter.Submission) (contentfilter.Result, error) {
+		*calls = append(*calls, name)
+		return contentfilter.Result{Verdict: v, Reason: reason}, nil
+	})
+}
+
+func TestPipeline_Check(t *testing.T) {
+	errDown := errors.New("classifier down")
+
+	tests := map[string]struct {
+		rules       func(calls *[]string) []contentfilter.Rule
+		expResult   contentfilter.Result
+		expCalls    []string
+		expRejected *contentfilter.RejectedError
+		expErr      error
+	}{
+		"should allow without rules": {
+			rules:     func(*[]string) []contentfilter.Rule { return nil },
+			expResult: contentfilter.Result{Verdict: contentfilter.Allow},
+		},
+		"should allow when every rule allows": {
+			rules: func(calls *[]string) []contentfilter.Rule {
+				return []contentfilter.Rule{
+					verdict("a", contentfilter.Allow, "", calls),
+					verdict("b", contentfilter.Allow, "", calls),
+				}
+			},
+			expResult: contentfilter.Result{Verdict: contentfilter.Allow},
+			expCalls:  []string{"a", "b"},
+		},
+		"should return the first flag": {
+			rules: func(calls *[]string) []contentfilter.Rule {
+				return []contentfilter.Rule{
+					verdict("a", contentfilter.Flag, "first", calls),
+					verdict("b", contentfilter.Flag, "second", calls),
+				}
+			},
+			expResult: contentfilter.Result{Verdict: contentfilter.Flag, Rule: "a", Reason: "first", Category: "other"},
+			expCalls:  []string{"a", "b"},
+		},
+		"should stop at the first rejection": {
+			rules: func(calls *[]string) []contentfilter.Rule {
+				return []contentfilter.Rule{
+					verdict("a", contentfilter.Flag, "flagged", calls),
+					verdict("b", contentfilter.Reject, "rejected", calls),
+					verdict("c", contentfilter.Allow, "", calls),
+				}
+			},
+			expResult:   contentfilter.Result{Verdict: contentfilter.Reject, Rule: "b", Reason: "rejected"},
+			expCalls:    []string{"a", "b"},
+			expRejected: &contentfilter.RejectedError{Rule: "b", Reason: "rejected"},
+		},
+		"should fail when a rule fails": {
+			rules: func(calls *[]string) []contentfilter.Rule {
+				return []contentfilter.Rule{
+					contentfilter.NewRule("classifier", func(context.Context, *contentfilter.Submission) (contentfilter.Result, error) {
+						return contentfilter.Result{}, errDown
+					}),
+					verdict("b", contentfilter.Allow, "", calls),
+				}
+			},
+			expErr: errDown,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls []string
+			p := contentfilter.New(tt.rules(&calls)...)
+
+			res, err := p.Check(context.Background(), &contentfilter.Submission{UserID: 1, Title: "title"})
+			assert.Equal(t, tt.expCalls, calls)
+			if tt.expErr != nil {
+				assert.ErrorIs(t, err, tt.expErr)
+				return
+			}
+			if tt.expRejected != nil {
+				var rejected *contentfilter.RejectedError
+				require.ErrorAs(t, err, &rejected)
+				assert.Equal(t, tt.expRejected, rejected)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.expResult, res)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package contentfilter
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// lookalikes maps the characters commonly substituted for letters to get around the filters.
+var lookalikes = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+}
+
+// Normalize folds a text to lower case words of ASCII-like letters separated by single spaces: the
+// compatibility characters (e.g. full width letters) are decomposed, the accents and the invisible
+// format characters (e.g. zero width spaces) are dropped and the lookalike digits and symbols are
+// replaced by their letters.
+func Normalize(s string) string {
+	var b strings.Builder
+	space := true
+	for _, r := range norm.NFKD.String(s) {
+		if l, ok := lookalikes[r]; ok {
+			r = l
+		}
+		switch {
+		case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+			space = false
+		case !space:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), " ")
+}
+
+// joinSpelled joins the runs of single letter words, so that "b a d" or "b.a.d" match "bad".
+func joinSpelled(words []string) []string {
+	joined := make([]string, 0, len(words))
+	run := ""
+	for _, w := range words {
+		if len([]rune(w)) == 1 {
+			run += w
+			continue
+		}
+		if run != "" {
+			joined = append(joined, run)
+			run = ""
+		}
+		joined = append(joined, w)
+	}
+	if run != "" {
+		joined = append(joined, run)
+	}
+
+	return joined
+}
@@ -0,0 +1,221 @@
+package contentfilter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Rule names.
+const (
+	RuleBannedWords    = "banned_words"
+	RuleLinks          = "links"
+	RuleSpam           = "spam"
+	RuleSubmissionRate = "submission_rate"
+)
+
+// Report categories of the flags, they match the categories of the user reports.
+const (
+	CategorySpam      = "spam"
+	CategoryOffensive = "offensive"
+)
+
+type bannedWords struct {
+	phrases []string
+}
+
+// NewBannedWords returns a rule rejecting the submissions which contain one of the words or phrases,
+// compared whole word after Normalize so that case, accents, lookalikes and spelled out letters do not
+// get around it.
+func NewBannedWords(words []string) Rule {
+	r := &bannedWords{}
+	for _, w := range words {
+		if n := Normalize(w); n != "" {
+			r.phrases = append(r.phrases, " "+n+" ")
+		}
+	}
+
+	return r
+}
+
+func (r *bannedWords) Name() string {
+	return RuleBannedWords
+}
+
+func (r *bannedWords) Check(_ context.Context, s *Submission) (Result, error) {
+	words := strings.Fields(Normalize(s.Title + " " + s.Description))
+	texts := []string{
+		" " + strings.Join(words, " ") + " ",
+		" " + strings.Join(joinSpelled(words), " ") + " ",
+	}
+	for _, p := range r.phrases {
+		for _, t := range texts {
+			if strings.Contains(t, p) {
+				return Result{
+					Verdict:  Reject,
+					Reason:   "the title or the description contains a banned word",
+					Category: CategoryOffensive,
+				}, nil
+			}
+		}
+	}
+
+	return Result{Verdict: Allow}, nil
+}
+
+// linkRe matches the URLs with a scheme or www, and the domains of the common spam TLDs followed by a path, so that
+// the words ending like a domain, e.g. "Kill.me", are not links.
+var linkRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9][a-z0-9-]*\.(?:com|net|org|io|info|biz|xyz|top|ru|me|ly)/\S*`)
+
+type links struct {
+	max int
+}
+
+// NewLinks returns a rule rejecting the titles with a link and the descriptions with more than max links.
+func NewLinks(max int) Rule {
+	return &links{max: max}
+}
+
+func (r *links) Name() string {
+	return RuleLinks
+}
+
+func (r *links) Check(_ context.Context, s *Submission) (Result, error) {
+	if linkRe.MatchString(s.Title) {
+		return Result{Verdict: Reject, Reason: "links are not allowed in the title", Category: CategorySpam}, nil
+	}
+	if n := len(linkRe.FindAllStringIndex(s.Description, -1)); n > r.max {
+		reason := fmt.Sprintf("at most %d links are allowed in the description", r.max)
+		if r.max == 0 {
+			reason = "links are not allowed in the description"
+		}
+		return Result{Verdict: Reject, Reason: reason, Category: CategorySpam}, nil
+	}
+
+	return Result{Verdict: Allow}, nil
+}
+
+const (
+	// shoutingLetters is the number of letters from which a title in capitals is shouting.
+	shoutingLetters = 8
+	// repeatedRunes is the length of a run of the same character which looks like spam.
+	repeatedRunes = 6
+	// repeatedWords is the number of words from which a description made of the same word looks like spam.
+	repeatedWords = 10
+)
+
+type spam struct{}
+
+// NewSpam returns a rule flagging the submissions which look like spam: a title in capitals, long runs of
+// the same character or a description repeating the same word.
+func NewSpam() Rule {
+	return &spam{}
+}
+
+func (r *spam) Name() string {
+	return RuleSpam
+}
+
+func (r *spam) Check(_ context.Context, s *Submission) (Result, error) {
+	flag := func(reason string) (Result, error) {
+		return Result{Verdict: Flag, Reason: reason, Category: CategorySpam}, nil
+	}
+	if shouting(s.Title) {
+		return flag("the title is in capitals")
+	}
+	if hasRepeatedRunes(s.Title) || hasRepeatedRunes(s.Description) {
+		return flag("the title or the description repeats the same character")
+	}
+	if hasRepeatedWord(s.Description) {
+		return flag("the description repeats the same word")
+	}
+
+	return Result{Verdict: Allow}, nil
+}
+
+// shouting reports whether at least 80% of the letters of the text are capitals.
+func shouting(text string) bool {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+
+	return letters >= shoutingLetters && upper*5 >= letters*4
+}
+
+func hasRepeatedRunes(text string) bool {
+	var prev rune
+	run := 0
+	for _, r := range text {
+		if r == prev && !unicode.IsSpace(r) {
+			run++
+			if run >= repeatedRunes {
+				return true
+			}
+			continue
+		}
+		prev, run = r, 1
+	}
+
+	return false
+}
+
+// hasRepeatedWord reports whether a single word makes up half of a long enough text.
+func hasRepeatedWord(text string) bool {
+	words := strings.Fields(Normalize(text))
+	if len(words) < repeatedWords {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, w := range words {
+		counts[w]++
+		if counts[w]*2 >= len(words) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MovieCounter counts the movies created by a user.
+type MovieCounter interface {
+	CountUserMoviesSince(ctx context.Context, userID int, since time.Time) (int, error)
+}
+
+type submissionRate struct {
+	counter MovieCounter
+	max     int
+}
+
+// NewSubmissionRate returns a rule rejecting the submissions of the users who created max movies in the
+// last hour.
+func NewSubmissionRate(counter MovieCounter, max int) Rule {
+	return &submissionRate{counter: counter, max: max}
+}
+
+func (r *submissionRate) Name() string {
+	return RuleSubmissionRate
+}
+
+func (r *submissionRate) Check(ctx context.Context, s *Submission) (Result, error) {
+	n, err := r.counter.CountUserMoviesSince(ctx, s.UserID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return Result{}, err
+	}
+	if n >= r.max {
+		return Result{
+			Verdict: Reject,
+			Reason:  fmt.Sprintf("at most %d movies can be submitted per hour", r.max),
+		}, nil
+	}
+
+	return Result{Verdict: Allow}, nil
+}
@@ -0,0 +1,226 @@
+package contentfilter_test
+
+import (
+	"context"
+	"errors"
+	"movierama/internal/contentfilter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]struct {
+		s   string
+		exp string
+	}{
+		"should lower the case":              {s: "The Matrix", exp: "the matrix"},
+		"should strip the accents":           {s: "Amélie à Paris", exp: "amelie a paris"},
+		"should decompose full width":        {s: "ＢＡＤ movie", exp: "bad movie"},
+		"should drop zero width characters":  {s: "b\u200bad", exp: "bad"},
+		"should replace lookalikes":          {s: "b4d m0vi3", exp: "bad movie"},
+		"should collapse the punctuation":    {s: "  bad,  movie!! ", exp: "bad movie"},
+		"should return empty on punctuation": {s: "?!", exp: ""},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, contentfilter.Normalize(tt.s))
+		})
+	}
+}
+
+func TestBannedWords(t *testing.T) {
+	rule := contentfilter.NewBannedWords([]string{"badword", "very bad phrase", " "})
+
+	tests := map[string]struct {
+		s          contentfilter.Submission
+		expVerdict contentfilter.Verdict
+	}{
+		"should allow a clean submission": {
+			s:          contentfilter.Submission{Title: "Clean", Description: "A nice movie"},
+			expVerdict: contentfilter.Allow,
+		},
+		"should reject a banned word in the title": {
+			s:          contentfilter.Submission{Title: "The BADWORD movie"},
+			expVerdict: contentfilter.Reject,
+		},
+		"should reject a banned phrase in the description": {
+			s:          contentfilter.Submission{Title: "Clean", Description: "A Very  bad, phrase."},
+			expVerdict: contentfilter.Reject,
+		},
+		"should reject an accented word with lookalikes": {
+			s:          contentfilter.Submission{Title: "b4dwörd"},
+			expVerdict: contentfilter.Reject,
+		},
+		"should reject a spelled out word": {
+			s:          contentfilter.Submission{Title: "b.a.d.w.o.r.d"},
+			expVerdict: contentfilter.Reject,
+		},
+		"should reject a word split by invisible characters": {
+			s:          contentfilter.Submission{Title: "bad\u200bword"},
+			expVerdict: contentfilter.Reject,
+		},
+		"should allow a word containing a banned word": {
+			s:          contentfilter.Submission{Title: "badwordy"},
+			expVerdict: contentfilter.Allow,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := rule.Check(context.Background(), &tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expVerdict, res.Verdict)
+			if tt.expVerdict == contentfilter.Reject {
+				assert.Equal(t, contentfilter.CategoryOffensive, res.Category)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := map[string]struct {
+		max        int
+		s          contentfilter.Submission
+		expVerdict contentfilter.Verdict
+		expReason  string
+	}{
+		"should allow a submission without links": {
+			max:        1,
+			s:          contentfilter.Submission{Title: "Dr. No", Description: "Bond, e.g. 007."},
+			expVerdict: contentfilter.Allow,
+		},
+		"should allow the words ending like a domain": {
+			max:        1,
+			s:          contentfilter.Submission{Title: "Kill.me", Description: "Italy.ly, Dr.io and No.com are no links."},
+			expVerdict: contentfilter.Allow,
+		},
+		"should reject a link in the title": {
+			max:        1,
+			s:          contentfilter.Submission{Title: "Watch at cheap-movies.com/free"},
+			expVerdict: contentfilter.Reject,
+			expReason:  "links are not allowed in the title",
+		},
+		"should allow up to max links in the description": {
+			max:        1,
+			s:          contentfilter.Submission{Title: "Title", Description: "Trailer at https://example.org/trailer"},
+			expVerdict: contentfilter.Allow,
+		},
+		"should reject more than max links in the description": {
+			max:        1,
+			s:          contentfilter.Submission{Title: "Title", Description: "See www.example.org and http://spam.example"},
+			expVerdict: contentfilter.Reject,
+			expReason:  "at most 1 links are allowed in the description",
+		},
+		"should reject any link when max is zero": {
+			s:          contentfilter.Submission{Title: "Title", Description: "See HTTPS://example.org"},
+			expVerdict: contentfilter.Reject,
+			expReason:  "links are not allowed in the description",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := contentfilter.NewLinks(tt.max).Check(context.Background(), &tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expVerdict, res.Verdict)
+			assert.Equal(t, tt.expReason, res.Reason)
+		})
+	}
+}
+
+func TestSpam(t *testing.T) {
+	tests := map[string]struct {
+		s          contentfilter.Submission
+		expVerdict contentfilter.Verdict
+		expReason  string
+	}{
+		"should allow a normal submission": {
+			s:          contentfilter.Submission{Title: "The Matrix", Description: "A hacker learns the truth. THE END"},
+			expVerdict: contentfilter.Allow,
+		},
+		"should allow a short title in capitals": {
+			s:          contentfilter.Submission{Title: "JAWS"},
+			expVerdict: contentfilter.Allow,
+		},
+		"should flag a title in capitals": {
+			s:          contentfilter.Submission{Title: "BEST MOVIE EVER"},
+			expVerdict: contentfilter.Flag,
+			expReason:  "the title is in capitals",
+		},
+		"should flag repeated characters": {
+			s:          contentfilter.Submission{Title: "Great", Description: "Sooooooo good"},
+			expVerdict: contentfilter.Flag,
+			expReason:  "the title or the description repeats the same character",
+		},
+		"should flag a repeated word": {
+			s:          contentfilter.Submission{Title: "Great", Description: strings.Repeat("buy now ", 6)},
+			expVerdict: contentfilter.Flag,
+			expReason:  "the description repeats the same word",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := contentfilter.NewSpam().Check(context.Background(), &tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expVerdict, res.Verdict)
+			assert.Equal(t, tt.expReason, res.Reason)
+		})
+	}
+}
+
+type counterFunc func(ctx context.Context, userID int, since time.Time) (int, error)
+
+func (f counterFunc) CountUserMoviesSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	return f(ctx, userID, since)
+}
+
+func TestSubmissionRate(t *testing.T) {
+	errDB := errors.New("db down")
+
+	tests := map[string]struct {
+		count      int
+		countErr   error
+		expVerdict contentfilter.Verdict
+		expErr     error
+	}{
+		"should allow under the limit": {
+			count:      2,
+			expVerdict: contentfilter.Allow,
+		},
+		"should reject at the limit": {
+			count:      3,
+			expVerdict: contentfilter.Reject,
+		},
+		"should fail when the movies cannot be counted": {
+			countErr: errDB,
+			expErr:   errDB,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			counter := counterFunc(func(_ context.Context, userID int, since time.Time) (int, error) {
+				assert.Equal(t, 7, userID)
+				assert.WithinDuration(t, time.Now().Add(-time.Hour), since, time.Minute)
+				return tt.count, tt.countErr
+			})
+
+			res, err := contentfilter.NewSubmissionRate(counter, 3).Check(context.Background(), &contentfilter.Submission{UserID: 7})
+			if tt.expErr != nil {
+				assert.ErrorIs(t, err, tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expVerdict, res.Verdict)
+			if tt.expVerdict == contentfilter.Reject {
+				assert.Equal(t, "at most 3 movies can be submitted per hour", res.Reason)
+			}
+		})
+	}
+}
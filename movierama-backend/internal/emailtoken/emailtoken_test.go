@@ -0,0 +1,102 @@
+package emailtoken_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/emailtoken"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	signer := emailtoken.NewSigner("secret")
+	states := map[int]string{1: "alice@example.com", 2: "bob@example.com"}
+	state := func(userID int) (string, error) {
+		s, ok := states[userID]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return s, nil
+	}
+	token, err := signer.Sign(emailtoken.PurposeVerify, 1, "alice@example.com", time.Hour)
+	require.NoError(t, err)
+	expired, err := signer.Sign(emailtoken.PurposeVerify, 1, "alice@example.com", -time.Second)
+	require.NoError(t, err)
+	changed, err := signer.Sign(emailtoken.PurposeVerify, 1, "alice@example.org", time.Hour)
+	require.NoError(t, err)
+	other, err := emailtoken.NewSigner("other").Sign(emailtoken.PurposeVerify, 1, "alice@example.com", time.Hour)
+	require.NoError(t, err)
+	p, sig, _ := strings.Cut(token, ".")
+	_, changedSig, _ := strings.Cut(changed, ".")
+
+	tests := map[string]struct {
+		token     string
+		purpose   string
+		expUserID int
+		expErr    error
+	}{
+		"should verify a token": {
+			token:     token,
+			purpose:   emailtoken.PurposeVerify,
+			expUserID: 1,
+		},
+		"should reject a token of another purpose": {
+			token:   token,
+			purpose: emailtoken.PurposeReset,
+			expErr:  emailtoken.ErrInvalid,
+		},
+		"should reject an expired token": {
+			token:   expired,
+			purpose: emailtoken.PurposeVerify,
+			expErr:  emailtoken.ErrExpired,
+		},
+		"should reject a token once the state changed": {
+			token:   changed,
+			purpose: emailtoken.PurposeVerify,
+			expErr:  emailtoken.ErrInvalid,
+		},
+		"should reject a token of another secret": {
+			token:   other,
+			purpose: emailtoken.PurposeVerify,
+			expErr:  emailtoken.ErrInvalid,
+		},
+		"should reject a tampered signature": {
+			token:   p + "." + changedSig,
+			purpose: emailtoken.PurposeVerify,
+			expErr:  emailtoken.ErrInvalid,
+		},
+		"should reject a malformed token": {
+			token:   "abc" + sig,
+			purpose: emailtoken.PurposeVerify,
+			expErr:  emailtoken.ErrInvalid,
+		},
+		"should reject an empty token": {
+			purpose: emailtoken.PurposeVerify,
+			expErr:  emailtoken.ErrInvalid,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			userID, err := signer.Verify(tt.token, tt.purpose, state)
+			if tt.expErr != nil {
+				assert.ErrorIs(t, err, tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expUserID, userID)
+		})
+	}
+}
+
+func TestSigner_StateError(t *testing.T) {
+	signer := emailtoken.NewSigner("secret")
+	token, err := signer.Sign(emailtoken.PurposeReset, 3, "hash", time.Hour)
+	require.NoError(t, err)
+	errState := errors.New("user not found")
+
+	_, err = signer.Verify(token, emailtoken.PurposeReset, func(int) (string, error) { return "", errState })
+	assert.ErrorIs(t, err, errState)
+}
@@ -0,0 +1,123 @@
+// Package health checks the dependencies the app needs to serve requests.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Statuses of a report and of its checks.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusDegraded is the status of a failed optional check, which does not fail the report.
+	StatusDegraded = "degraded"
+)
+
+// CheckFunc checks a dependency, it returns an error when the dependency is unavailable.
+type CheckFunc func(ctx context.Context) error
+
+// Result is the outcome of a check.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// Fallback is what serves in place of a degraded dependency.
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// Report is the outcome of all the checks.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK reports whether all the checks passed.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+	// fallback is set on the optional checks.
+	fallback string
+}
+
+// Checker runs the registered checks.
+type Checker struct {
+	timeout time.Duration
+	checks  []check
+}
+
+// NewChecker constructor, every check must complete within timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers the check of the dependency name.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// AddOptional registers the check of the dependency name, which fallback serves in place of while it fails. It
+// is reported degraded then, without failing the report.
+func (c *Checker) AddOptional(name, fallback string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn, fallback: fallback})
+}
+
+// Check runs the checks concurrently and reports their status and latency.
+func (c *Checker) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func(i int, fn CheckFunc) {
+			defer wg.Done()
+			results[i] = run(ctx, fn)
+		}(i, chk.fn)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	for i, chk := range c.checks {
+		switch {
+		case results[i].Status == StatusOK:
+		case chk.fallback != "":
+			results[i].Status, results[i].Fallback = StatusDegraded, chk.fallback
+		default:
+			report.Status = StatusFail
+		}
+		report.Checks[chk.name] = results[i]
+	}
+
+	return report
+}
+
+// run runs fn, giving up when ctx is done even if fn ignores it.
+func run(ctx context.Context, fn CheckFunc) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	return res
+}
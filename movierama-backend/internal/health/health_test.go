@@ -0,0 +1,94 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"movierama/internal/health"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_Check(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	hanging := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	tests := map[string]struct {
+		checks    map[string]health.CheckFunc
+		optional  map[string]health.CheckFunc
+		expStatus string
+		expChecks map[string]health.Result
+	}{
+		"should be ok when all the checks pass": {
+			checks:    map[string]health.CheckFunc{"reader": ok, "writer": ok},
+			expStatus: health.StatusOK,
+			expChecks: map[string]health.Result{
+				"reader": {Status: health.StatusOK},
+				"writer": {Status: health.StatusOK},
+			},
+		},
+		"should fail when a check fails": {
+			checks:    map[string]health.CheckFunc{"reader": ok, "writer": failing},
+			expStatus: health.StatusFail,
+			expChecks: map[string]health.Result{
+				"reader": {Status: health.StatusOK},
+				"writer": {Status: health.StatusFail, Error: "connection refused"},
+			},
+		},
+		"should degrade a failing optional check without failing": {
+			checks:    map[string]health.CheckFunc{"writer": ok},
+			optional:  map[string]health.CheckFunc{"reader": failing},
+			expStatus: health.StatusOK,
+			expChecks: map[string]health.Result{
+				"reader": {Status: health.StatusDegraded, Error: "connection refused", Fallback: "writer"},
+				"writer": {Status: health.StatusOK},
+			},
+		},
+		"should report a passing optional check ok": {
+			checks:    map[string]health.CheckFunc{"writer": ok},
+			optional:  map[string]health.CheckFunc{"reader": ok},
+			expStatus: health.StatusOK,
+			expChecks: map[string]health.Result{
+				"reader": {Status: health.StatusOK},
+				"writer": {Status: health.StatusOK},
+			},
+		},
+		"should fail a check that exceeds the timeout": {
+			checks:    map[string]health.CheckFunc{"redis": hanging},
+			expStatus: health.StatusFail,
+			expChecks: map[string]health.Result{
+				"redis": {Status: health.StatusFail, Error: context.DeadlineExceeded.Error()},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := health.NewChecker(50 * time.Millisecond)
+			for n, fn := range tt.checks {
+				c.Add(n, fn)
+			}
+			for n, fn := range tt.optional {
+				c.AddOptional(n, "writer", fn)
+			}
+
+			start := time.Now()
+			report := c.Check(context.Background())
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+			assert.Equal(t, tt.expStatus, report.Status)
+			assert.Len(t, report.Checks, len(tt.expChecks))
+			for n, exp := range tt.expChecks {
+				res := report.Checks[n]
+				assert.GreaterOrEqual(t, res.LatencyMS, 0.0)
+				res.LatencyMS = 0
+				assert.Equal(t, exp, res, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"movierama/internal/app/auth"
+	"movierama/internal/infra/grpc/pb"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type authServer struct {
+	pb.UnimplementedAuthServiceServer
+	asSvc auth.Service
+}
+
+// Login returns a JWT for the user credentials.
+func (s *authServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	res, err := s.asSvc.Login(withClient(ctx), &auth.UserLogin{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	})
+	var attemptsErr *auth.AttemptsError
+	if errors.As(err, &attemptsErr) {
+		retryAfter := strconv.Itoa(int(math.Ceil(attemptsErr.RetryAfter.Seconds())))
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfter))
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.LoginResponse{Username: res.Username, Token: res.Token}, nil
+}
+
+// Register creates a user.
+func (s *authServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	err := s.asSvc.Register(withClient(ctx), &auth.UserRegister{
+		Username:  req.GetUsername(),
+		Password:  req.GetPassword(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Email:     req.GetEmail(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.RegisterResponse{}, nil
+}
+
+// withClient returns ctx carrying the client of the call, for the audit log.
+func withClient(ctx context.Context) context.Context {
+	c := auth.Client{IP: peerIP(ctx)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			c.UserAgent = ua[0]
+		}
+	}
+
+	return auth.WithClient(ctx, c)
+}
@@ -0,0 +1,58 @@
+package grpc
+
+import (
+	"errors"
+	"movierama/internal/app/auth"
+	"movierama/internal/app/movie"
+	"movierama/internal/contentfilter"
+	"movierama/internal/infra/grpc/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps the domain errors to gRPC status errors, the likely duplicates of a new movie are in the details.
+func toStatus(err error) error {
+	var code codes.Code
+	var rejected *contentfilter.RejectedError
+	var dupErr *movie.DuplicateError
+	switch {
+	case errors.As(err, &dupErr):
+		return duplicateStatus(dupErr)
+	case errors.As(err, &rejected):
+		code = codes.InvalidArgument
+	case errors.Is(err, movie.ErrMovieNotFound):
+		code = codes.NotFound
+	case errors.Is(err, movie.ErrInvalidAction):
+		code = codes.InvalidArgument
+	case errors.Is(err, movie.ErrOwnMovie):
+		code = codes.PermissionDenied
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		code = codes.Unauthenticated
+	case errors.Is(err, auth.ErrUsernameTaken), errors.Is(err, auth.ErrEmailTaken):
+		code = codes.AlreadyExists
+	case errors.Is(err, auth.ErrInvalidEmail), errors.Is(err, auth.ErrEmailRequired):
+		code = codes.InvalidArgument
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		code = codes.ResourceExhausted
+	case errors.Is(err, auth.ErrAccountBanned), errors.Is(err, auth.ErrAccountSuspended), errors.Is(err, auth.ErrEmailNotVerified):
+		code = codes.PermissionDenied
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	return status.Error(code, err.Error())
+}
+
+func duplicateStatus(err *movie.DuplicateError) error {
+	details := &pb.DuplicateMovies{}
+	for _, m := range err.Movies {
+		details.Movies = append(details.Movies, &pb.DuplicateMovies_Duplicate{Id: int64(m.ID), Title: m.Title})
+	}
+	st, detailsErr := status.New(codes.AlreadyExists, err.Error()).WithDetails(details)
+	if detailsErr != nil {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,197 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt"
+	"movierama/internal/app/auth"
+	"movierama/internal/app/movie"
+	"movierama/internal/jwtkeys"
+	"movierama/internal/locale"
+	"movierama/internal/logging"
+	"movierama/internal/ratelimit"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// authRequired contains the methods that can only be called by an auth user,
+// the rest accept anonymous calls and serve the public view of the data.
+var authRequired = map[string]bool{
+	"/movierama.v1.MovieService/CreateMovie": true,
+	"/movierama.v1.MovieService/Vote":        true,
+}
+
+// limitGroups contains the rate limit group of the limited methods, like their REST routes.
+var limitGroups = map[string]string{
+	"/movierama.v1.AuthService/Login":        ratelimit.GroupLogin,
+	"/movierama.v1.AuthService/Register":     ratelimit.GroupRegister,
+	"/movierama.v1.MovieService/CreateMovie": ratelimit.GroupMovies,
+	"/movierama.v1.MovieService/Vote":        ratelimit.GroupVotes,
+}
+
+// requestIDMetadataKey contains the metadata key of the request id.
+const requestIDMetadataKey = "x-request-id"
+
+// acceptLanguageMetadataKey contains the metadata key of the preferred languages, like the HTTP header.
+const acceptLanguageMetadataKey = "accept-language"
+
+// maxRequestIDLength limits the length of the request ids accepted from clients.
+const maxRequestIDLength = 128
+
+// LoggingInterceptor returns a unary interceptor that reuses the x-request-id metadata
+// or generates a new id, returns it in the response header and logs every call.
+func LoggingInterceptor(l *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		var rid string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if ids := md.Get(requestIDMetadataKey); len(ids) > 0 && len(ids[0]) <= maxRequestIDLength {
+				rid = ids[0]
+			}
+		}
+		if rid == "" {
+			rid = logging.NewRequestID()
+		}
+		ctx = logging.WithRequestID(ctx, rid)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, rid))
+
+		res, err := handler(ctx, req)
+
+		code := status.Code(err)
+		fields := []zap.Field{
+			zap.String("method", info.FullMethod),
+			zap.String("code", code.String()),
+			zap.Duration("latency", time.Since(start)),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+		log := logging.For(ctx, l)
+		switch code {
+		case codes.OK:
+			log.Info("rpc", fields...)
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			log.Error("rpc", fields...)
+		default:
+			log.Warn("rpc", fields...)
+		}
+
+		return res, err
+	}
+}
+
+// LocaleInterceptor returns a unary interceptor that negotiates the locale of the call from the accept-language
+// metadata, stores it in the context and translates the messages of the statuses returned by the handlers.
+func LocaleInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		l := locale.Default
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			l = locale.Negotiate(strings.Join(md.Get(acceptLanguageMetadataKey), ","))
+		}
+
+		res, err := handler(locale.WithLocale(ctx, l), req)
+		if err == nil || l == locale.English {
+			return res, err
+		}
+		st, ok := status.FromError(err)
+		if !ok {
+			return res, err
+		}
+		p := st.Proto()
+		p.Message = l.Translate(p.Message)
+
+		return res, status.ErrorProto(p)
+	}
+}
+
+// AuthInterceptor returns a unary interceptor that validates the bearer JWT of the authorization
+// metadata against keys, checks with asSvc that its user may still use it and stores the auth user id in the context.
+func AuthInterceptor(keys *jwtkeys.Set, asSvc auth.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		token := bearerToken(ctx)
+		if token == "" {
+			if authRequired[info.FullMethod] {
+				return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+			}
+
+			return handler(ctx, req)
+		}
+
+		claims := &auth.JwtCustomClaims{}
+		if _, err := jwt.ParseWithClaims(token, claims, keys.Keyfunc); err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired jwt")
+		}
+		if err := asSvc.Authenticate(ctx, claims); err != nil {
+			if errors.Is(err, auth.ErrAccountBanned) || errors.Is(err, auth.ErrAccountSuspended) || errors.Is(err, auth.ErrTokenRevoked) {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			return nil, toStatus(err)
+		}
+
+		return handler(context.WithValue(ctx, movie.AuthUserIDContextKey, claims.UserID), req)
+	}
+}
+
+// RateLimitInterceptor returns a unary interceptor that limits the calls of the limited methods per
+// peer IP and per auth user, it must run after the AuthInterceptor. It returns the ratelimit-* headers
+// and rejects the calls over the limit with ResourceExhausted and a retry-after header.
+func RateLimitInterceptor(l *ratelimit.Limiter, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		group, ok := limitGroups[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		userID, _ := ctx.Value(movie.AuthUserIDContextKey).(int)
+		res, err := l.Allow(ctx, group, peerIP(ctx), userID)
+		if err != nil {
+			logging.For(ctx, logger).Error("rate limit", zap.String("group", group), zap.Error(err))
+		}
+		if h := res.Headers(); len(h) > 0 {
+			_ = grpc.SetHeader(ctx, metadata.New(h))
+		}
+		if !res.Allowed {
+			return nil, status.Error(codes.ResourceExhausted, ratelimit.ErrLimited.Error())
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// peerIP returns the IP of the peer, or its address when it has no IP.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
+
+// bearerToken returns the bearer token of the authorization metadata.
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+			return v[7:]
+		}
+	}
+
+	return ""
+}
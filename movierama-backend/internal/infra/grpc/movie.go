@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"movierama/internal/app/movie"
+	"movierama/internal/infra/grpc/pb"
+	sqlmovie "movierama/internal/infra/repository/sql/movie"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type movieServer struct {
+	pb.UnimplementedMovieServiceServer
+	msSvc movie.Service
+}
+
+// ListMovies returns the movies, optionally limited to the ones submitted by a user.
+func (s *movieServer) ListMovies(ctx context.Context, req *pb.ListMoviesRequest) (*pb.ListMoviesResponse, error) {
+	sortType := toSortType(req.GetSort())
+	userID := int(req.GetUserId())
+	_, authenticated := ctx.Value(movie.AuthUserIDContextKey).(int)
+
+	var (
+		res *movie.GetmoviesRes
+		err error
+	)
+	switch {
+	case userID != 0 && authenticated:
+		res, err = s.msSvc.GetUserMovies(ctx, userID, sortType)
+	case userID != 0:
+		res, err = s.msSvc.GetUserMoviesPublic(ctx, userID, sortType)
+	case authenticated:
+		res, err = s.msSvc.GetMovies(ctx, sortType)
+	default:
+		res, err = s.msSvc.GetMoviesPublic(ctx, sortType)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	movies := make([]*pb.Movie, 0, len(res.Movies))
+	for i := range res.Movies {
+		movies = append(movies, toPBMovie(&res.Movies[i]))
+	}
+
+	return &pb.ListMoviesResponse{Movies: movies}, nil
+}
+
+// GetMovie returns a movie.
+func (s *movieServer) GetMovie(ctx context.Context, req *pb.GetMovieRequest) (*pb.Movie, error) {
+	m, err := s.msSvc.GetMovie(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toPBMovie(m), nil
+}
+
+// CreateMovie submits a movie.
+func (s *movieServer) CreateMovie(ctx context.Context, req *pb.CreateMovieRequest) (*pb.CreateMovieResponse, error) {
+	if req.GetTitle() == "" {
+		return nil, status.Error(codes.InvalidArgument, "title is required")
+	}
+
+	err := s.msSvc.CreateMovie(ctx, movie.NewMovie{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Confirm:     req.GetConfirm(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.CreateMovieResponse{}, nil
+}
+
+// Vote sets the auth user vote for a movie.
+func (s *movieServer) Vote(ctx context.Context, req *pb.VoteRequest) (*pb.Movie, error) {
+	var action string
+	switch req.GetVote() {
+	case pb.Vote_VOTE_LIKE:
+		action = movie.ActionLike
+	case pb.Vote_VOTE_HATE:
+		action = movie.ActionHate
+	case pb.Vote_VOTE_NONE:
+		action = ""
+	default:
+		return nil, status.Error(codes.InvalidArgument, "vote is required")
+	}
+
+	movieID := int(req.GetMovieId())
+	if err := s.msSvc.Vote(ctx, movieID, action); err != nil {
+		return nil, toStatus(err)
+	}
+
+	m, err := s.msSvc.GetMovie(ctx, movieID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toPBMovie(m), nil
+}
+
+func toSortType(sort pb.SortType) string {
+	switch sort {
+	case pb.SortType_SORT_TYPE_LIKES:
+		return sqlmovie.SortCaseLikes
+	case pb.SortType_SORT_TYPE_HATES:
+		return sqlmovie.SortCaseHates
+	default:
+		return sqlmovie.SortCaseDate
+	}
+}
+
+func toPBMovie(m *movie.Movie) *pb.Movie {
+	vote := pb.Vote_VOTE_NONE
+	switch {
+	case m.UserLiked:
+		vote = pb.Vote_VOTE_LIKE
+	case m.UserHated:
+		vote = pb.Vote_VOTE_HATE
+	}
+
+	return &pb.Movie{
+		Id:                int64(m.ID),
+		Title:             m.Title,
+		Description:       m.Description,
+		UserId:            int64(m.UserID),
+		PostedBy:          m.PostedBy,
+		Likes:             int64(m.Likes),
+		Hates:             int64(m.Hates),
+		ViewerVote:        vote,
+		SubmittedByViewer: m.IsSameUser,
+		TimeAgo:           m.TimeAgo,
+		CreatedAt:         m.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,4 @@
+// Package pb contains the generated protobuf and gRPC code of the MovieRama API.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative movierama.proto
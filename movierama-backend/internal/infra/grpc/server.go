@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"context"
+	"movierama/internal/app/auth"
+	"movierama/internal/app/movie"
+	"movierama/internal/infra/grpc/pb"
+	"movierama/internal/jwtkeys"
+	"movierama/internal/ratelimit"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// NewServer returns a gRPC server exposing the movie and auth services, the calls are rate limited
+// with l when it is not nil.
+func NewServer(msSvc movie.Service, asSvc auth.Service, keys *jwtkeys.Set, l *ratelimit.Limiter, logger *zap.Logger) *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		LoggingInterceptor(logger),
+		LocaleInterceptor(),
+		AuthInterceptor(keys, asSvc),
+		RateLimitInterceptor(l, logger),
+	))
+	pb.RegisterMovieServiceServer(s, &movieServer{msSvc: msSvc})
+	pb.RegisterAuthServiceServer(s, &authServer{asSvc: asSvc})
+
+	return s
+}
+
+// Shutdown stops s gracefully, waiting for the pending RPCs and streams to finish
+// until ctx is done, then closes the remaining connections.
+func Shutdown(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,504 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	authservice "movierama/internal/app/auth"
+	movieservice "movierama/internal/app/movie"
+	"movierama/internal/contentfilter"
+	grpcapi "movierama/internal/infra/grpc"
+	"movierama/internal/infra/grpc/pb"
+	"movierama/internal/jwtkeys"
+	"movierama/internal/locale"
+	"movierama/internal/ratelimit"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const jwtSecret = "secret"
+
+func dial(t *testing.T, msSvc movieservice.Service, asSvc authservice.Service) *grpc.ClientConn {
+	return dialWithLimiter(t, msSvc, asSvc, nil)
+}
+
+func dialWithLimiter(t *testing.T, msSvc movieservice.Service, asSvc authservice.Service, l *ratelimit.Limiter) *grpc.ClientConn {
+	// The tokens of the tests are valid unless the test expects otherwise.
+	if m, ok := asSvc.(*authservice.SvcMock); ok {
+		m.On("Authenticate", mock.Anything, mock.Anything).Return(nil).Maybe()
+	}
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpcapi.NewServer(msSvc, asSvc, jwtkeys.NewHMAC(jwtSecret), l, zap.NewNop())
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func withToken(t *testing.T, userID int) context.Context {
+	tk := jwt.NewWithClaims(jwt.SigningMethodHS256, &authservice.JwtCustomClaims{UserID: userID})
+	s, err := tk.SignedString([]byte(jwtSecret))
+	require.NoError(t, err)
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+s)
+}
+
+func authCtx(userID int) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(movieservice.AuthUserIDContextKey) == userID
+	})
+}
+
+func TestMovieService_ListMovies(t *testing.T) {
+	createdAt := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+	movies := &movieservice.GetmoviesRes{
+		Movies: []movieservice.Movie{
+			{ID: 1, Title: "movie title", UserID: 2, PostedBy: "Full Name", Likes: 3, UserHated: true, CreatedAt: createdAt},
+		},
+	}
+
+	tests := map[string]struct {
+		mockSvc *movieservice.SvcMock
+		ctx     context.Context
+		req     *pb.ListMoviesRequest
+		expRes  *pb.ListMoviesResponse
+		expCode codes.Code
+	}{
+		"Should list public movies without auth": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("GetMoviesPublic", mock.Anything, "likes").Return(movies, nil)
+
+				return m
+			}(),
+			ctx: context.Background(),
+			req: &pb.ListMoviesRequest{Sort: pb.SortType_SORT_TYPE_LIKES},
+			expRes: &pb.ListMoviesResponse{Movies: []*pb.Movie{
+				{Id: 1, Title: "movie title", UserId: 2, PostedBy: "Full Name", Likes: 3, ViewerVote: pb.Vote_VOTE_HATE,
+					CreatedAt: "2026-10-01T12:00:00Z"},
+			}},
+		},
+		"Should list user movies with auth": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("GetUserMovies", authCtx(7), 2, "date").Return(movies, nil)
+
+				return m
+			}(),
+			ctx: withToken(t, 7),
+			req: &pb.ListMoviesRequest{UserId: 2},
+			expRes: &pb.ListMoviesResponse{Movies: []*pb.Movie{
+				{Id: 1, Title: "movie title", UserId: 2, PostedBy: "Full Name", Likes: 3, ViewerVote: pb.Vote_VOTE_HATE,
+					CreatedAt: "2026-10-01T12:00:00Z"},
+			}},
+		},
+		"Should list the movies in the locale of the call": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("GetMoviesPublic", mock.MatchedBy(func(ctx context.Context) bool {
+					return locale.FromContext(ctx) == locale.French
+				}), "date").Return(movies, nil)
+
+				return m
+			}(),
+			ctx: metadata.AppendToOutgoingContext(context.Background(), "accept-language", "fr-FR, en;q=0.5"),
+			req: &pb.ListMoviesRequest{},
+			expRes: &pb.ListMoviesResponse{Movies: []*pb.Movie{
+				{Id: 1, Title: "movie title", UserId: 2, PostedBy: "Full Name", Likes: 3, ViewerVote: pb.Vote_VOTE_HATE,
+					CreatedAt: "2026-10-01T12:00:00Z"},
+			}},
+		},
+		"Should reject an invalid token": {
+			mockSvc: &movieservice.SvcMock{},
+			ctx:     metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid"),
+			req:     &pb.ListMoviesRequest{},
+			expCode: codes.Unauthenticated,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := pb.NewMovieServiceClient(dial(t, tt.mockSvc, &authservice.SvcMock{}))
+
+			res, err := client.ListMovies(tt.ctx, tt.req)
+			assert.Equal(t, tt.expCode, status.Code(err))
+			if tt.expRes != nil {
+				require.Len(t, res.GetMovies(), len(tt.expRes.GetMovies()))
+				for i := range tt.expRes.GetMovies() {
+					assert.Equal(t, tt.expRes.GetMovies()[i].String(), res.GetMovies()[i].String())
+				}
+			}
+			tt.mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthInterceptor_Authenticate(t *testing.T) {
+	tests := map[string]struct {
+		err     error
+		expCode codes.Code
+	}{
+		"Should reject the token of a banned user": {
+			err:     authservice.ErrAccountBanned,
+			expCode: codes.Unauthenticated,
+		},
+		"Should reject a revoked token": {
+			err:     authservice.ErrTokenRevoked,
+			expCode: codes.Unauthenticated,
+		},
+		"Should fail when the user cannot be checked": {
+			err:     errors.New("db down"),
+			expCode: codes.Internal,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			asSvc := &authservice.SvcMock{}
+			asSvc.On("Authenticate", mock.Anything, mock.MatchedBy(func(c *authservice.JwtCustomClaims) bool {
+				return c.UserID == 7
+			})).Return(tt.err).Once()
+			client := pb.NewMovieServiceClient(dial(t, &movieservice.SvcMock{}, asSvc))
+
+			_, err := client.ListMovies(withToken(t, 7), &pb.ListMoviesRequest{})
+			assert.Equal(t, tt.expCode, status.Code(err))
+			asSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMovieService_Errors(t *testing.T) {
+	tests := map[string]struct {
+		mockSvc *movieservice.SvcMock
+		call    func(client pb.MovieServiceClient) error
+		expCode codes.Code
+	}{
+		"Should require auth to create a movie": {
+			mockSvc: &movieservice.SvcMock{},
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.CreateMovie(context.Background(), &pb.CreateMovieRequest{Title: "title"})
+
+				return err
+			},
+			expCode: codes.Unauthenticated,
+		},
+		"Should create a movie with auth": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("CreateMovie", authCtx(7), movieservice.NewMovie{Title: "title", Description: "desc"}).Return(nil)
+
+				return m
+			}(),
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.CreateMovie(withToken(t, 7), &pb.CreateMovieRequest{Title: "title", Description: "desc"})
+
+				return err
+			},
+			expCode: codes.OK,
+		},
+		"Should map a rejected movie to invalid argument": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("CreateMovie", authCtx(7), movieservice.NewMovie{Title: "title"}).
+					Return(&contentfilter.RejectedError{Rule: contentfilter.RuleBannedWords, Reason: "banned"})
+
+				return m
+			}(),
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.CreateMovie(withToken(t, 7), &pb.CreateMovieRequest{Title: "title"})
+
+				return err
+			},
+			expCode: codes.InvalidArgument,
+		},
+		"Should return the likely duplicates of a new movie": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("CreateMovie", authCtx(7), movieservice.NewMovie{Title: "Matrix, The"}).
+					Return(&movieservice.DuplicateError{Movies: []movieservice.Duplicate{{ID: 1, Title: "The Matrix"}}})
+
+				return m
+			}(),
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.CreateMovie(withToken(t, 7), &pb.CreateMovieRequest{Title: "Matrix, The"})
+				details := status.Convert(err).Details()
+				if assert.Len(t, details, 1) {
+					dups := details[0].(*pb.DuplicateMovies).GetMovies()
+					assert.Equal(t, int64(1), dups[0].GetId())
+					assert.Equal(t, "The Matrix", dups[0].GetTitle())
+				}
+
+				return err
+			},
+			expCode: codes.AlreadyExists,
+		},
+		"Should create a confirmed movie": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("CreateMovie", authCtx(7), movieservice.NewMovie{Title: "Matrix, The", Confirm: true}).Return(nil)
+
+				return m
+			}(),
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.CreateMovie(withToken(t, 7), &pb.CreateMovieRequest{Title: "Matrix, The", Confirm: true})
+
+				return err
+			},
+			expCode: codes.OK,
+		},
+		"Should map a missing movie to not found": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("GetMovie", mock.Anything, 5).Return((*movieservice.Movie)(nil), movieservice.ErrMovieNotFound)
+
+				return m
+			}(),
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.GetMovie(context.Background(), &pb.GetMovieRequest{Id: 5})
+
+				return err
+			},
+			expCode: codes.NotFound,
+		},
+		"Should translate the message of a status": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("GetMovie", mock.Anything, 5).Return((*movieservice.Movie)(nil), movieservice.ErrMovieNotFound)
+
+				return m
+			}(),
+			call: func(client pb.MovieServiceClient) error {
+				ctx := metadata.AppendToOutgoingContext(context.Background(), "accept-language", "el")
+				_, err := client.GetMovie(ctx, &pb.GetMovieRequest{Id: 5})
+				assert.Equal(t, "η ταινία δεν βρέθηκε", status.Convert(err).Message())
+
+				return err
+			},
+			expCode: codes.NotFound,
+		},
+		"Should map a vote on an own movie to permission denied": {
+			mockSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("Vote", authCtx(7), 5, "like").Return(movieservice.ErrOwnMovie)
+
+				return m
+			}(),
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.Vote(withToken(t, 7), &pb.VoteRequest{MovieId: 5, Vote: pb.Vote_VOTE_LIKE})
+
+				return err
+			},
+			expCode: codes.PermissionDenied,
+		},
+		"Should reject an unspecified vote": {
+			mockSvc: &movieservice.SvcMock{},
+			call: func(client pb.MovieServiceClient) error {
+				_, err := client.Vote(withToken(t, 7), &pb.VoteRequest{MovieId: 5})
+
+				return err
+			},
+			expCode: codes.InvalidArgument,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := pb.NewMovieServiceClient(dial(t, tt.mockSvc, &authservice.SvcMock{}))
+
+			assert.Equal(t, tt.expCode, status.Code(tt.call(client)))
+			tt.mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService(t *testing.T) {
+	tests := map[string]struct {
+		mockSvc *authservice.SvcMock
+		call    func(client pb.AuthServiceClient) error
+		expCode codes.Code
+	}{
+		"Should login": {
+			mockSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("Login", mock.Anything, &authservice.UserLogin{Username: "user", Password: "pass"}).
+					Return(&authservice.LoginRes{Username: "user", Token: "token"}, nil)
+
+				return m
+			}(),
+			call: func(client pb.AuthServiceClient) error {
+				res, err := client.Login(context.Background(), &pb.LoginRequest{Username: "user", Password: "pass"})
+				if err == nil {
+					assert.Equal(t, "token", res.GetToken())
+				}
+
+				return err
+			},
+			expCode: codes.OK,
+		},
+		"Should map invalid credentials to unauthenticated": {
+			mockSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("Login", mock.Anything, &authservice.UserLogin{Username: "user", Password: "wrong"}).
+					Return((*authservice.LoginRes)(nil), authservice.ErrInvalidCredentials)
+
+				return m
+			}(),
+			call: func(client pb.AuthServiceClient) error {
+				_, err := client.Login(context.Background(), &pb.LoginRequest{Username: "user", Password: "wrong"})
+
+				return err
+			},
+			expCode: codes.Unauthenticated,
+		},
+		"Should map a banned account to permission denied": {
+			mockSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("Login", mock.Anything, &authservice.UserLogin{Username: "user", Password: "pass"}).
+					Return((*authservice.LoginRes)(nil), authservice.ErrAccountBanned)
+
+				return m
+			}(),
+			call: func(client pb.AuthServiceClient) error {
+				_, err := client.Login(context.Background(), &pb.LoginRequest{Username: "user", Password: "pass"})
+
+				return err
+			},
+			expCode: codes.PermissionDenied,
+		},
+		"Should map a taken username to already exists": {
+			mockSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("Register", mock.Anything, mock.Anything).Return(authservice.ErrUsernameTaken)
+
+				return m
+			}(),
+			call: func(client pb.AuthServiceClient) error {
+				_, err := client.Register(context.Background(), &pb.RegisterRequest{Username: "user", Password: "pass"})
+
+				return err
+			},
+			expCode: codes.AlreadyExists,
+		},
+		"Should register with the email and map an invalid one to invalid argument": {
+			mockSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("Register", mock.Anything, &authservice.UserRegister{Username: "user", Password: "pass", Email: "user"}).
+					Return(authservice.ErrInvalidEmail)
+
+				return m
+			}(),
+			call: func(client pb.AuthServiceClient) error {
+				_, err := client.Register(context.Background(), &pb.RegisterRequest{Username: "user", Password: "pass", Email: "user"})
+
+				return err
+			},
+			expCode: codes.InvalidArgument,
+		},
+		"Should map too many attempts to resource exhausted with retry-after": {
+			mockSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("Login", mock.MatchedBy(func(ctx context.Context) bool {
+					return authservice.ClientFrom(ctx).IP != ""
+				}), mock.Anything).Return((*authservice.LoginRes)(nil), &authservice.AttemptsError{RetryAfter: 2 * time.Second})
+
+				return m
+			}(),
+			call: func(client pb.AuthServiceClient) error {
+				var header metadata.MD
+				_, err := client.Login(context.Background(), &pb.LoginRequest{Username: "user", Password: "pass"}, grpc.Header(&header))
+				assert.Equal(t, []string{"2"}, header.Get("retry-after"))
+
+				return err
+			},
+			expCode: codes.ResourceExhausted,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := pb.NewAuthServiceClient(dial(t, &movieservice.SvcMock{}, tt.mockSvc))
+
+			assert.Equal(t, tt.expCode, status.Code(tt.call(client)))
+			tt.mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLoggingInterceptor_RequestID(t *testing.T) {
+	tests := map[string]struct {
+		reqID string
+	}{
+		"Should reuse the request id of the call": {
+			reqID: "client-id",
+		},
+		"Should generate a request id": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &movieservice.SvcMock{}
+			mockSvc.On("GetMoviesPublic", mock.Anything, "date").Return(&movieservice.GetmoviesRes{}, nil)
+			client := pb.NewMovieServiceClient(dial(t, mockSvc, &authservice.SvcMock{}))
+
+			ctx := context.Background()
+			if tt.reqID != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", tt.reqID)
+			}
+			var header metadata.MD
+			_, err := client.ListMovies(ctx, &pb.ListMoviesRequest{}, grpc.Header(&header))
+			require.NoError(t, err)
+
+			ids := header.Get("x-request-id")
+			require.Len(t, ids, 1)
+			if tt.reqID != "" {
+				assert.Equal(t, tt.reqID, ids[0])
+			} else {
+				assert.NotEmpty(t, ids[0])
+			}
+		})
+	}
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+		ratelimit.GroupVotes: {PerUser: ratelimit.Limit{Requests: 1, Period: time.Minute}},
+	}, nil)
+	require.NoError(t, err)
+	mockSvc := &movieservice.SvcMock{}
+	mockSvc.On("Vote", authCtx(2), 1, "like").Return(nil).Once()
+	mockSvc.On("GetMovie", authCtx(2), 1).Return(&movieservice.Movie{ID: 1, Likes: 1}, nil).Once()
+	client := pb.NewMovieServiceClient(dialWithLimiter(t, mockSvc, &authservice.SvcMock{}, l))
+
+	var header metadata.MD
+	_, err = client.Vote(withToken(t, 2), &pb.VoteRequest{MovieId: 1, Vote: pb.Vote_VOTE_LIKE}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0"}, header.Get("ratelimit-remaining"))
+
+	_, err = client.Vote(withToken(t, 2), &pb.VoteRequest{MovieId: 1, Vote: pb.Vote_VOTE_LIKE}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"60"}, header.Get("retry-after"))
+	mockSvc.AssertExpectations(t)
+}
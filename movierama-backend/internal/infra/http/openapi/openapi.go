@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+//go:embed docs.html
+var docsHTML []byte
+
+// Spec loads and validates the embedded OpenAPI document.
+func Spec() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(specJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// EchoPath converts an echo route path (/movies/:movie_id) to an OpenAPI path template (/movies/{movie_id}).
+func EchoPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// Router infrastructure definition.
+type Router struct {
+	doc *openapi3.T
+}
+
+// NewRouter returns an HTTP component to serve the OpenAPI document and its docs UI.
+func NewRouter(doc *openapi3.T) *Router {
+	return &Router{
+		doc: doc,
+	}
+}
+
+// AppendRoutes adds the OpenAPI routes to router.
+func (r *Router) AppendRoutes(e *echo.Echo) {
+	e.GET("/api/openapi.json", r.GetSpec)
+	e.GET("/api/docs", r.GetDocs)
+}
+
+// GetSpec serves the OpenAPI document.
+func (r *Router) GetSpec(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, specJSON)
+}
+
+// GetDocs serves the bundled docs UI.
+func (r *Router) GetDocs(c echo.Context) error {
+	return c.HTMLBlob(http.StatusOK, docsHTML)
+}
+
+// ValidatorConfig defines the config for the request validator middleware.
+type ValidatorConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Doc is the OpenAPI document requests are validated against.
+	Doc *openapi3.T
+}
+
+// Validator returns a middleware that validates the matched route's request against the OpenAPI document.
+// Routes that are not described in the document are passed through.
+func Validator(doc *openapi3.T) echo.MiddlewareFunc {
+	return ValidatorWithConfig(ValidatorConfig{Doc: doc})
+}
+
+// ValidatorWithConfig returns a Validator middleware with config.
+func ValidatorWithConfig(cfg ValidatorConfig) echo.MiddlewareFunc {
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+	if cfg.Doc == nil {
+		panic("openapi: validator middleware requires a document")
+	}
+
+	// Authentication is enforced by the JWT middleware of each route group.
+	opts := &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper(c) {
+				return next(c)
+			}
+
+			path := EchoPath(c.Path())
+			pathItem := cfg.Doc.Paths.Find(path)
+			if pathItem == nil {
+				return next(c)
+			}
+			req := c.Request()
+			op := pathItem.GetOperation(req.Method)
+			if op == nil {
+				return next(c)
+			}
+
+			pathParams := make(map[string]string, len(c.ParamNames()))
+			for i, name := range c.ParamNames() {
+				pathParams[name] = c.ParamValues()[i]
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route: &routers.Route{
+					Spec:      cfg.Doc,
+					Path:      path,
+					PathItem:  pathItem,
+					Method:    req.Method,
+					Operation: op,
+				},
+				Options: opts,
+			}
+			if err := openapi3filter.ValidateRequest(req.Context(), input); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, validationMessage(err)).SetInternal(err)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// validationMessage returns a client friendly message for a validation error.
+func validationMessage(err error) string {
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) {
+		if reqErr.Parameter != nil {
+			msg := "invalid " + reqErr.Parameter.In + " parameter " + reqErr.Parameter.Name
+			if reqErr.Reason != "" {
+				msg += ": " + reqErr.Reason
+			}
+
+			return msg + errReason(reqErr.Err)
+		}
+		if reqErr.RequestBody != nil {
+			return "invalid request body" + errReason(reqErr.Err)
+		}
+
+		return reqErr.Error()
+	}
+
+	return err.Error()
+}
+
+func errReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		field := strings.Join(schemaErr.JSONPointer(), ".")
+		if field != "" {
+			return ": " + field + " " + schemaErr.Reason
+		}
+
+		return ": " + schemaErr.Reason
+	}
+
+	return ": " + err.Error()
+}
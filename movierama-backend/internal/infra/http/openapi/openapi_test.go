@@ -0,0 +1,171 @@
+package openapi_test
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	adminservice "movierama/internal/app/admin"
+	authservice "movierama/internal/app/auth"
+	movieservice "movierama/internal/app/movie"
+	"movierama/internal/health"
+	"movierama/internal/infra/http/openapi"
+	"movierama/internal/infra/http/router/admin"
+	"movierama/internal/infra/http/router/auth"
+	"movierama/internal/infra/http/router/graphql"
+	healthroute "movierama/internal/infra/http/router/health"
+	jwksroute "movierama/internal/infra/http/router/jwks"
+	"movierama/internal/infra/http/router/metrics"
+	"movierama/internal/infra/http/router/movie"
+	"movierama/internal/jwtkeys"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func TestSpec_CoversRegisteredRoutes(t *testing.T) {
+	doc, err := openapi.Spec()
+	require.NoError(t, err)
+
+	e := echo.New()
+	auth.NewRouter(&authservice.SvcMock{}, middleware.JWTConfig{SigningKey: []byte("secret")}, zap.NewNop()).AppendRoutes(e)
+	movie.NewRouter(&movieservice.SvcMock{}, middleware.JWTConfig{SigningKey: []byte("secret")}, zap.NewNop()).AppendRoutes(e)
+	graphql.NewRouter(&movieservice.SvcMock{}, &authservice.SvcMock{}, middleware.JWTConfig{SigningKey: []byte("secret")}, zap.NewNop()).AppendRoutes(e)
+	admin.NewRouter(&adminservice.SvcMock{}, &authservice.SvcMock{}, middleware.JWTConfig{SigningKey: []byte("secret")}, zap.NewNop()).AppendRoutes(e)
+	openapi.NewRouter(doc).AppendRoutes(e)
+	metrics.NewRouter(prometheus.NewRegistry()).AppendRoutes(e)
+	healthroute.NewRouter(health.NewChecker(time.Second)).AppendRoutes(e)
+	jwksroute.NewRouter(jwtkeys.NewHMAC("secret")).AppendRoutes(e)
+
+	for _, route := range e.Routes() {
+		// Skip the not found handlers echo registers for route groups with middleware.
+		if strings.HasPrefix(route.Name, "github.com/labstack/echo/") {
+			continue
+		}
+		path := openapi.EchoPath(route.Path)
+		pathItem := doc.Paths.Find(path)
+		if !assert.NotNilf(t, pathItem, "route %s %s is missing from the OpenAPI spec", route.Method, path) {
+			continue
+		}
+		assert.NotNilf(t, pathItem.GetOperation(route.Method), "route %s %s is missing from the OpenAPI spec", route.Method, path)
+	}
+}
+
+func TestEchoPath(t *testing.T) {
+	tests := map[string]struct {
+		path   string
+		expRes string
+	}{
+		"static path": {
+			path:   "/movies",
+			expRes: "/movies",
+		},
+		"path params": {
+			path:   "/api/v1/movies/:movie_id/action/:action",
+			expRes: "/api/v1/movies/{movie_id}/action/{action}",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expRes, openapi.EchoPath(tt.path))
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	doc, err := openapi.Spec()
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		method  string
+		path    string
+		target  string
+		body    string
+		expCode int
+	}{
+		"should pass a valid query": {
+			method:  http.MethodGet,
+			path:    "/movies",
+			target:  "/movies?sort=likes",
+			expCode: http.StatusOK,
+		},
+		"should reject an unknown sort type": {
+			method:  http.MethodGet,
+			path:    "/movies",
+			target:  "/movies?sort=random",
+			expCode: http.StatusBadRequest,
+		},
+		"should reject a non numeric path param": {
+			method:  http.MethodGet,
+			path:    "/users/:user_id/movies",
+			target:  "/users/abc/movies",
+			expCode: http.StatusBadRequest,
+		},
+		"should reject an unknown action": {
+			method:  http.MethodPost,
+			path:    "/api/v1/movies/:movie_id/action/:action",
+			target:  "/api/v1/movies/1/action/love",
+			expCode: http.StatusBadRequest,
+		},
+		"should pass a valid body": {
+			method:  http.MethodPost,
+			path:    "/api/v1/auth/login",
+			target:  "/api/v1/auth/login",
+			body:    `{"username":"test_username","password":"secret_password"}`,
+			expCode: http.StatusOK,
+		},
+		"should reject a body with missing fields": {
+			method:  http.MethodPost,
+			path:    "/api/v1/auth/login",
+			target:  "/api/v1/auth/login",
+			body:    `{"username":"test_username"}`,
+			expCode: http.StatusBadRequest,
+		},
+		"should pass routes missing from the spec": {
+			method:  http.MethodGet,
+			path:    "/unknown",
+			target:  "/unknown?sort=random",
+			expCode: http.StatusOK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(openapi.Validator(doc))
+			e.Add(tt.method, tt.path, func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestRouter_GetSpec(t *testing.T) {
+	doc, err := openapi.Spec()
+	require.NoError(t, err)
+
+	e := echo.New()
+	openapi.NewRouter(doc).AppendRoutes(e)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"openapi": "3.0.3"`)
+}
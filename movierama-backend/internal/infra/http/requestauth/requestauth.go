@@ -0,0 +1,155 @@
+// Package requestauth contains the HTTP middleware that authenticates and authorizes the requests.
+package requestauth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/app/auth"
+	"movierama/internal/jwtkeys"
+	"net/http"
+)
+
+// scopeKey is the key of the scope of the route in the echo context.
+const scopeKey = "requestauth.scope"
+
+// JWTConfig returns the config of the JWT middleware. Besides the signature, verified against keys, and
+// expiry, the token is checked with asSvc on every request, so that it stops working once its user is
+// banned, suspended, given another role or changes their password. The personal access tokens are only
+// accepted by the routes of a Scoped config.
+func JWTConfig(keys *jwtkeys.Set, asSvc auth.Service) middleware.JWTConfig {
+	return middleware.JWTConfig{
+		Claims: &auth.JwtCustomClaims{},
+		ParseTokenFunc: func(token string, c echo.Context) (interface{}, error) {
+			if auth.IsAccessToken(token) {
+				return parseAccessToken(token, c, asSvc)
+			}
+			claims := &auth.JwtCustomClaims{}
+			t, err := jwt.ParseWithClaims(token, claims, keys.Keyfunc)
+			if err != nil {
+				return nil, err
+			}
+			if err = asSvc.Authenticate(c.Request().Context(), claims); err != nil {
+				if isAccountError(err) {
+					return nil, err
+				}
+				// A storage failure does not tell anything about the token.
+				return nil, echo.NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+			}
+
+			return t, nil
+		},
+		ErrorHandlerWithContext: ErrorHandler,
+	}
+}
+
+// Scoped returns a copy of cfg that also accepts the personal access tokens granted scope, the other
+// tokens are rejected with 403.
+func Scoped(cfg middleware.JWTConfig, scope auth.Scope) middleware.JWTConfig {
+	before := cfg.BeforeFunc
+	cfg.BeforeFunc = func(c echo.Context) {
+		if before != nil {
+			before(c)
+		}
+		c.Set(scopeKey, scope)
+	}
+
+	return cfg
+}
+
+// Optional returns a copy of cfg that lets the requests without token through anonymously, the invalid
+// tokens are still rejected.
+func Optional(cfg middleware.JWTConfig) middleware.JWTConfig {
+	handleErr := cfg.ErrorHandlerWithContext
+	cfg.ContinueOnIgnoredError = true
+	cfg.ErrorHandlerWithContext = func(err error, c echo.Context) error {
+		if err == middleware.ErrJWTMissing {
+			return nil
+		}
+		if handleErr != nil {
+			return handleErr(err, c)
+		}
+
+		return &echo.HTTPError{
+			Code:     middleware.ErrJWTInvalid.Code,
+			Message:  middleware.ErrJWTInvalid.Message,
+			Internal: err,
+		}
+	}
+
+	return cfg
+}
+
+// parseAccessToken returns the token of the claims of a personal access token granted the scope of the route.
+func parseAccessToken(token string, c echo.Context, asSvc auth.Service) (*jwt.Token, error) {
+	claims, err := asSvc.AuthenticateAccessToken(c.Request().Context(), token)
+	if err != nil {
+		if isAccountError(err) {
+			return nil, err
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+	}
+	scope, ok := c.Get(scopeKey).(auth.Scope)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "personal access tokens are not accepted by this route")
+	}
+	if !claims.Allows(scope) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("the token lacks the %s scope", scope))
+	}
+
+	return &jwt.Token{Raw: token, Claims: claims, Valid: true}, nil
+}
+
+// ErrorHandler maps the errors of the JWT middleware to their status. The account errors
+// are told to the client, the other token errors are invalid or expired tokens.
+func ErrorHandler(err error, _ echo.Context) error {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if isAccountError(err) {
+		return &echo.HTTPError{Code: http.StatusUnauthorized, Message: err.Error(), Internal: err}
+	}
+
+	return &echo.HTTPError{
+		Code:     middleware.ErrJWTInvalid.Code,
+		Message:  middleware.ErrJWTInvalid.Message,
+		Internal: err,
+	}
+}
+
+func isAccountError(err error) bool {
+	return errors.Is(err, auth.ErrAccountBanned) || errors.Is(err, auth.ErrAccountSuspended) ||
+		errors.Is(err, auth.ErrTokenRevoked) || errors.Is(err, auth.ErrInvalidAccessToken)
+}
+
+// RequireRole returns a middleware that rejects the requests of the users below role with 403,
+// it must run after the JWT middleware.
+func RequireRole(role auth.Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := Claims(c)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, middleware.ErrJWTMissing.Message)
+			}
+			if !claims.UserRole().AtLeast(role) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("%s role required", role))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Claims returns the claims of the JWT stored by the JWT middleware.
+func Claims(c echo.Context) (*auth.JwtCustomClaims, bool) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := token.Claims.(*auth.JwtCustomClaims)
+
+	return claims, ok
+}
@@ -0,0 +1,290 @@
+package requestauth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/app/auth"
+	"movierama/internal/infra/http/requestauth"
+	"movierama/internal/jwtkeys"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const jwtSecret = "secret"
+
+func token(t *testing.T, method jwt.SigningMethod, key interface{}, claims *auth.JwtCustomClaims) string {
+	t.Helper()
+	claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+	s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+
+	return s
+}
+
+// keySet returns a set of a new Ed25519 key.
+func keySet(t *testing.T) *jwtkeys.Set {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0o600))
+	s, err := jwtkeys.Load(path)
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestJWTConfig(t *testing.T) {
+	keys := keySet(t)
+
+	tests := map[string]struct {
+		keys      *jwtkeys.Set
+		token     func(t *testing.T) string
+		authErr   error
+		expStatus int
+		expBody   string
+	}{
+		"should accept a valid token": {
+			token: func(t *testing.T) string {
+				return token(t, jwt.SigningMethodHS256, []byte(jwtSecret), &auth.JwtCustomClaims{UserID: 1, Role: auth.RoleModerator})
+			},
+			expStatus: http.StatusOK,
+			expBody:   "1 moderator",
+		},
+		"should reject a token of another key": {
+			token: func(t *testing.T) string {
+				return token(t, jwt.SigningMethodHS256, []byte("other"), &auth.JwtCustomClaims{UserID: 1})
+			},
+			expStatus: http.StatusUnauthorized,
+			expBody:   `{"message":"invalid or expired jwt"}`,
+		},
+		"should accept a token of the key set": {
+			keys: keys,
+			token: func(t *testing.T) string {
+				s, err := keys.Sign(&auth.JwtCustomClaims{UserID: 2, Role: auth.RoleUser,
+					StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()}})
+				require.NoError(t, err)
+				return s
+			},
+			expStatus: http.StatusOK,
+			expBody:   "2 user",
+		},
+		"should reject a token of the secret with a key set": {
+			keys: keys,
+			token: func(t *testing.T) string {
+				return token(t, jwt.SigningMethodHS256, []byte(jwtSecret), &auth.JwtCustomClaims{UserID: 1})
+			},
+			expStatus: http.StatusUnauthorized,
+			expBody:   `{"message":"invalid or expired jwt"}`,
+		},
+		"should reject a token of another method": {
+			token: func(t *testing.T) string {
+				return token(t, jwt.SigningMethodHS512, []byte(jwtSecret), &auth.JwtCustomClaims{UserID: 1})
+			},
+			expStatus: http.StatusUnauthorized,
+			expBody:   `{"message":"invalid or expired jwt"}`,
+		},
+		"should reject the token of a banned user": {
+			token: func(t *testing.T) string {
+				return token(t, jwt.SigningMethodHS256, []byte(jwtSecret), &auth.JwtCustomClaims{UserID: 1})
+			},
+			authErr:   auth.ErrAccountBanned,
+			expStatus: http.StatusUnauthorized,
+			expBody:   `{"message":"account is banned"}`,
+		},
+		"should reject a revoked token": {
+			token: func(t *testing.T) string {
+				return token(t, jwt.SigningMethodHS256, []byte(jwtSecret), &auth.JwtCustomClaims{UserID: 1})
+			},
+			authErr:   auth.ErrTokenRevoked,
+			expStatus: http.StatusUnauthorized,
+			expBody:   `{"message":"token is no longer valid"}`,
+		},
+		"should fail when the user cannot be checked": {
+			token: func(t *testing.T) string {
+				return token(t, jwt.SigningMethodHS256, []byte(jwtSecret), &auth.JwtCustomClaims{UserID: 1})
+			},
+			authErr:   errors.New("db down"),
+			expStatus: http.StatusInternalServerError,
+			expBody:   `{"message":"Internal Server Error"}`,
+		},
+		"should reject a missing token": {
+			token:     func(t *testing.T) string { return "" },
+			expStatus: http.StatusBadRequest,
+			expBody:   `{"message":"missing or malformed jwt"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			asSvc := &auth.SvcMock{}
+			asSvc.On("Authenticate", mock.Anything, mock.AnythingOfType("*auth.JwtCustomClaims")).Return(tt.authErr).Maybe()
+
+			keys := tt.keys
+			if keys == nil {
+				keys = jwtkeys.NewHMAC(jwtSecret)
+			}
+			e := echo.New()
+			e.GET("/", func(c echo.Context) error {
+				claims, _ := requestauth.Claims(c)
+				return c.String(http.StatusOK, fmt.Sprintf("%d %s", claims.UserID, claims.Role))
+			}, middleware.JWTWithConfig(requestauth.JWTConfig(keys, asSvc)))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tok := tt.token(t); tok != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tok)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			assert.Equal(t, tt.expBody, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := map[string]struct {
+		role      auth.Role
+		setClaims bool
+		expStatus int
+	}{
+		"should allow a higher role": {
+			role:      auth.RoleAdmin,
+			setClaims: true,
+			expStatus: http.StatusOK,
+		},
+		"should allow the same role": {
+			role:      auth.RoleModerator,
+			setClaims: true,
+			expStatus: http.StatusOK,
+		},
+		"should forbid a lower role": {
+			role:      auth.RoleUser,
+			setClaims: true,
+			expStatus: http.StatusForbidden,
+		},
+		"should forbid a token without role": {
+			setClaims: true,
+			expStatus: http.StatusForbidden,
+		},
+		"should reject a request without token": {
+			expStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			setClaims := func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if tt.setClaims {
+						c.Set("user", &jwt.Token{Claims: &auth.JwtCustomClaims{UserID: 1, Role: tt.role}})
+					}
+					return next(c)
+				}
+			}
+			e.GET("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, setClaims, requestauth.RequireRole(auth.RoleModerator))
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tt.expStatus, rec.Code)
+		})
+	}
+}
+
+func TestScoped(t *testing.T) {
+	const pat = auth.AccessTokenPrefix + "token"
+	tokenClaims := &auth.JwtCustomClaims{UserID: 3, Role: auth.RoleUser, AccessTokenID: 1, Scopes: []auth.Scope{auth.ScopeMoviesRead}}
+
+	tests := map[string]struct {
+		scope     auth.Scope
+		authRes   *auth.JwtCustomClaims
+		authErr   error
+		expStatus int
+		expBody   string
+	}{
+		"should accept a token of the scope": {
+			scope:     auth.ScopeMoviesRead,
+			authRes:   tokenClaims,
+			expStatus: http.StatusOK,
+			expBody:   "3 user",
+		},
+		"should reject a token without the scope": {
+			scope:     auth.ScopeMoviesWrite,
+			authRes:   tokenClaims,
+			expStatus: http.StatusForbidden,
+			expBody:   `{"message":"the token lacks the movies:write scope"}`,
+		},
+		"should reject a token on a route without scope": {
+			authRes:   tokenClaims,
+			expStatus: http.StatusForbidden,
+			expBody:   `{"message":"personal access tokens are not accepted by this route"}`,
+		},
+		"should reject an invalid token": {
+			scope:     auth.ScopeMoviesRead,
+			authRes:   (*auth.JwtCustomClaims)(nil),
+			authErr:   auth.ErrInvalidAccessToken,
+			expStatus: http.StatusUnauthorized,
+			expBody:   `{"message":"invalid, expired or revoked access token"}`,
+		},
+		"should reject the token of a banned user": {
+			scope:     auth.ScopeMoviesRead,
+			authRes:   (*auth.JwtCustomClaims)(nil),
+			authErr:   auth.ErrAccountBanned,
+			expStatus: http.StatusUnauthorized,
+			expBody:   `{"message":"account is banned"}`,
+		},
+		"should fail when the token cannot be checked": {
+			scope:     auth.ScopeMoviesRead,
+			authRes:   (*auth.JwtCustomClaims)(nil),
+			authErr:   errors.New("db down"),
+			expStatus: http.StatusInternalServerError,
+			expBody:   `{"message":"Internal Server Error"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			asSvc := &auth.SvcMock{}
+			asSvc.On("AuthenticateAccessToken", mock.Anything, pat).Return(tt.authRes, tt.authErr)
+
+			cfg := requestauth.JWTConfig(jwtkeys.NewHMAC(jwtSecret), asSvc)
+			if tt.scope != "" {
+				cfg = requestauth.Scoped(cfg, tt.scope)
+			}
+			e := echo.New()
+			e.GET("/", func(c echo.Context) error {
+				claims, _ := requestauth.Claims(c)
+				return c.String(http.StatusOK, fmt.Sprintf("%d %s", claims.UserID, claims.Role))
+			}, middleware.JWTWithConfig(cfg))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+pat)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			assert.Equal(t, tt.expBody, strings.TrimSpace(rec.Body.String()))
+			asSvc.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package requestcache contains the HTTP middleware that answers the conditional requests and sets the cache
+// headers of the responses.
+package requestcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/logging"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HTTP headers of the conditional requests.
+const (
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// Private is the Cache-Control of the responses of the auth users, which only the client caches and
+// revalidates before each use.
+const Private = "private, no-cache"
+
+// Public returns the Cache-Control of the responses that every cache may keep for maxAge, revalidated after.
+func Public(maxAge time.Duration) string {
+	return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+}
+
+// Validators identify the version of a response.
+type Validators struct {
+	// ETag is the entity tag, weak tags start with W/.
+	ETag         string
+	LastModified time.Time
+}
+
+// ValidatorsFunc returns the validators of the response of a request before its handler runs, they must be
+// cheaper than the response.
+type ValidatorsFunc func(c echo.Context) (Validators, error)
+
+// WeakETag returns a weak entity tag of the parts, which identify a version of a response.
+func WeakETag(parts ...interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintln(parts...)))
+
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// Conditional returns a middleware that answers the conditional GET requests whose response has not changed with
+// 304 Not Modified, and sets the validators and the Cache-Control of the successful responses.
+func Conditional(validators ValidatorsFunc, cacheControl string, logger *zap.Logger) echo.MiddlewareFunc {
+	return ConditionalWithConfig(ConditionalConfig{Validators: validators, CacheControl: cacheControl, Logger: logger})
+}
+
+// ConditionalConfig defines the config for the Conditional middleware.
+type ConditionalConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper      middleware.Skipper
+	Validators   ValidatorsFunc
+	CacheControl string
+	// Vary lists the request headers the responses depend on, on top of the URL.
+	Vary   []string
+	Logger *zap.Logger
+}
+
+// ConditionalWithConfig returns a Conditional middleware with config.
+func ConditionalWithConfig(cfg ConditionalConfig) echo.MiddlewareFunc {
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+	if cfg.Validators == nil {
+		panic("requestcache: conditional middleware requires a validators function")
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if cfg.Skipper(c) || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+				return next(c)
+			}
+
+			res := c.Response()
+			for _, h := range cfg.Vary {
+				res.Header().Add(echo.HeaderVary, h)
+			}
+			v, err := cfg.Validators(c)
+			if err != nil {
+				// The response is sent without validators, the handler reports its own errors.
+				logging.For(req.Context(), cfg.Logger).Error("cache validators", zap.Error(err))
+				return next(c)
+			}
+			if notModified(req, v) {
+				setHeaders(res.Header(), v, cfg.CacheControl)
+				return c.NoContent(http.StatusNotModified)
+			}
+			// The errors are neither validated nor cached.
+			res.Before(func() {
+				if res.Status == http.StatusOK {
+					setHeaders(res.Header(), v, cfg.CacheControl)
+				}
+			})
+
+			return next(c)
+		}
+	}
+}
+
+// setHeaders sets the validators and the Cache-Control of a response.
+func setHeaders(h http.Header, v Validators, cacheControl string) {
+	if v.ETag != "" {
+		h.Set(headerETag, v.ETag)
+	}
+	if !v.LastModified.IsZero() {
+		h.Set(echo.HeaderLastModified, v.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if cacheControl != "" {
+		h.Set(echo.HeaderCacheControl, cacheControl)
+	}
+}
+
+// notModified reports whether the client has the current version of the response. If-None-Match takes
+// precedence over If-Modified-Since, whose dates have a resolution of a second.
+func notModified(req *http.Request, v Validators) bool {
+	if inm := req.Header.Get(headerIfNoneMatch); inm != "" {
+		return v.ETag != "" && matchETag(inm, v.ETag)
+	}
+	ims, err := http.ParseTime(req.Header.Get(echo.HeaderIfModifiedSince))
+	if err != nil || v.LastModified.IsZero() {
+		return false
+	}
+
+	return !v.LastModified.Truncate(time.Second).After(ims)
+}
+
+// matchETag reports whether the If-None-Match header lists etag, by the weak comparison.
+func matchETag(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
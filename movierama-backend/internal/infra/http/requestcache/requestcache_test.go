@@ -0,0 +1,163 @@
+package requestcache_test
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"movierama/internal/infra/http/requestcache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestConditional(t *testing.T) {
+	modified := time.Date(2026, time.October, 25, 9, 30, 15, 500, time.UTC)
+	etag := requestcache.WeakETag(42, "en")
+	validators := func(c echo.Context) (requestcache.Validators, error) {
+		return requestcache.Validators{ETag: etag, LastModified: modified}, nil
+	}
+	ok := func(c echo.Context) error {
+		return c.String(http.StatusOK, "movies")
+	}
+
+	tests := map[string]struct {
+		method     string
+		headers    map[string]string
+		validators requestcache.ValidatorsFunc
+		handler    echo.HandlerFunc
+		expStatus  int
+		expBody    string
+		expHeaders bool
+	}{
+		"should send the response with its validators": {
+			handler:    ok,
+			expStatus:  http.StatusOK,
+			expBody:    "movies",
+			expHeaders: true,
+		},
+		"should not send a response whose etag matches": {
+			headers:    map[string]string{"If-None-Match": etag},
+			expStatus:  http.StatusNotModified,
+			expHeaders: true,
+		},
+		"should match the etags weakly": {
+			headers:    map[string]string{"If-None-Match": `W/"other", ` + etag[len("W/"):]},
+			expStatus:  http.StatusNotModified,
+			expHeaders: true,
+		},
+		"should match any etag": {
+			headers:    map[string]string{"If-None-Match": "*"},
+			expStatus:  http.StatusNotModified,
+			expHeaders: true,
+		},
+		"should send a response whose etag changed": {
+			headers:    map[string]string{"If-None-Match": `W/"other"`},
+			handler:    ok,
+			expStatus:  http.StatusOK,
+			expBody:    "movies",
+			expHeaders: true,
+		},
+		"should not send a response unmodified since": {
+			headers:    map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			expStatus:  http.StatusNotModified,
+			expHeaders: true,
+		},
+		"should send a response modified since": {
+			headers:    map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)},
+			handler:    ok,
+			expStatus:  http.StatusOK,
+			expBody:    "movies",
+			expHeaders: true,
+		},
+		"should prefer the etag to the date": {
+			headers: map[string]string{
+				"If-None-Match":     `W/"other"`,
+				"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat),
+			},
+			handler:    ok,
+			expStatus:  http.StatusOK,
+			expBody:    "movies",
+			expHeaders: true,
+		},
+		"should not validate nor cache the errors": {
+			handler: func(c echo.Context) error {
+				return c.String(http.StatusNotFound, "not found")
+			},
+			expStatus: http.StatusNotFound,
+			expBody:   "not found",
+		},
+		"should send the response without validators on error": {
+			headers: map[string]string{"If-None-Match": etag},
+			validators: func(c echo.Context) (requestcache.Validators, error) {
+				return requestcache.Validators{}, errors.New("sql error")
+			},
+			handler:   ok,
+			expStatus: http.StatusOK,
+			expBody:   "movies",
+		},
+		"should skip the other methods": {
+			method:    http.MethodPost,
+			headers:   map[string]string{"If-None-Match": etag},
+			handler:   ok,
+			expStatus: http.StatusOK,
+			expBody:   "movies",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.validators == nil {
+				tt.validators = validators
+			}
+			if tt.method == "" {
+				tt.method = http.MethodGet
+			}
+			e := echo.New()
+			e.Add(tt.method, "/", tt.handler, requestcache.ConditionalWithConfig(requestcache.ConditionalConfig{
+				Validators:   tt.validators,
+				CacheControl: requestcache.Private,
+				Vary:         []string{echo.HeaderAuthorization},
+				Logger:       zap.NewNop(),
+			}))
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			assert.Equal(t, tt.expBody, rec.Body.String())
+			if tt.expHeaders {
+				assert.Equal(t, etag, rec.Header().Get("ETag"))
+				assert.Equal(t, "Sun, 25 Oct 2026 09:30:15 GMT", rec.Header().Get("Last-Modified"))
+				assert.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"))
+			} else {
+				assert.Empty(t, rec.Header().Get("ETag"))
+				assert.Empty(t, rec.Header().Get("Cache-Control"))
+			}
+			if tt.method == http.MethodGet {
+				assert.Equal(t, "Authorization", rec.Header().Get(echo.HeaderVary))
+			}
+		})
+	}
+}
+
+func TestPublic(t *testing.T) {
+	assert.Equal(t, "public, max-age=5", requestcache.Public(5*time.Second))
+	assert.Equal(t, "public, max-age=0", requestcache.Public(0))
+}
+
+func TestWeakETag(t *testing.T) {
+	etag := requestcache.WeakETag(42, "en", 7)
+
+	assert.Regexp(t, `^W/"[0-9a-f]{16}"$`, etag)
+	assert.Equal(t, etag, requestcache.WeakETag(42, "en", 7))
+	assert.NotEqual(t, etag, requestcache.WeakETag(43, "en", 7))
+	assert.NotEqual(t, etag, requestcache.WeakETag(42, "el", 7))
+	assert.NotEqual(t, etag, requestcache.WeakETag(42, "en", 8))
+}
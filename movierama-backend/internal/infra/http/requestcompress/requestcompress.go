@@ -0,0 +1,194 @@
+// Package requestcompress contains the HTTP middleware that compresses the responses.
+package requestcompress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Supported content encodings.
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// encoder is the part of the gzip and brotli writers the middleware uses.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// pools keep the encoders by encoding, which are costly to allocate.
+var pools = map[string]*sync.Pool{
+	Brotli: {New: func() interface{} { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }},
+	Gzip:   {New: func() interface{} { return gzip.NewWriter(io.Discard) }},
+}
+
+// Middleware returns a middleware that compresses the responses with brotli or gzip, as the Accept-Encoding
+// header of the request prefers. The responses without body and the responses already encoded are sent as is.
+func Middleware() echo.MiddlewareFunc {
+	return MiddlewareWithConfig(Config{})
+}
+
+// Config defines the config for the compression middleware.
+type Config struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+}
+
+// MiddlewareWithConfig returns a compression middleware with config.
+func MiddlewareWithConfig(cfg Config) echo.MiddlewareFunc {
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper(c) {
+				return next(c)
+			}
+
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			encoding := Negotiate(c.Request().Header.Get(echo.HeaderAcceptEncoding))
+			if encoding == "" {
+				return next(c)
+			}
+
+			w := &compressWriter{ResponseWriter: res.Writer, encoding: encoding}
+			res.Writer = w
+			defer func() {
+				w.close()
+				res.Writer = w.ResponseWriter
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+// Negotiate returns the supported encoding the Accept-Encoding header prefers, by quality then brotli first,
+// and "" when the header accepts none of them.
+func Negotiate(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			var err error
+			if q, err = strconv.ParseFloat(params[len("q="):], 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "*" {
+			name = Brotli
+		}
+		if name != Brotli && name != Gzip {
+			continue
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == Brotli) {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// compressWriter compresses the body of a response, it holds the status until the first write to tell the
+// responses without body.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	enc         encoder
+	code        int
+	wroteHeader bool
+}
+
+// WriteHeader holds the status of a response that may have a body.
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.code = code
+	if !bodyAllowed(code) {
+		w.commit(false)
+	}
+}
+
+// Write compresses b, unless the handler encoded the body itself.
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.commit(w.Header().Get(echo.HeaderContentEncoding) == "")
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.enc.Write(b)
+}
+
+// Flush sends the compressed body written so far.
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.commit(w.Header().Get(echo.HeaderContentEncoding) == "")
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// commit sends the header of the response, along with the encoding when the body is compressed.
+func (w *compressWriter) commit(compress bool) {
+	w.wroteHeader = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if compress {
+		h := w.Header()
+		h.Set(echo.HeaderContentEncoding, w.encoding)
+		h.Del(echo.HeaderContentLength)
+		w.enc = pools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+// close ends the compressed body and releases the encoder, or sends the held status of a response without body.
+func (w *compressWriter) close() {
+	if !w.wroteHeader {
+		if w.code != 0 {
+			w.commit(false)
+		}
+		return
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(io.Discard)
+		pools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// bodyAllowed reports whether a response of the status may have a body.
+func bodyAllowed(code int) bool {
+	return code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified
+}
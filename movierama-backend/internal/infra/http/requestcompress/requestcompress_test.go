@@ -0,0 +1,156 @@
+package requestcompress_test
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"movierama/internal/infra/http/requestcompress"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		exp    string
+	}{
+		"should not encode without header": {},
+		"should pick gzip": {
+			header: "gzip, deflate",
+			exp:    requestcompress.Gzip,
+		},
+		"should prefer brotli on equal qualities": {
+			header: "gzip, deflate, br",
+			exp:    requestcompress.Brotli,
+		},
+		"should pick the highest quality": {
+			header: "br;q=0.5, gzip;q=0.8",
+			exp:    requestcompress.Gzip,
+		},
+		"should skip the refused encodings": {
+			header: "br;q=0, gzip;q=0.1",
+			exp:    requestcompress.Gzip,
+		},
+		"should skip an invalid quality": {
+			header: "br;q=2, gzip;q=0.1",
+			exp:    requestcompress.Gzip,
+		},
+		"should pick brotli on a wildcard": {
+			header: "*",
+			exp:    requestcompress.Brotli,
+		},
+		"should not encode on unsupported encodings": {
+			header: "deflate, identity",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, requestcompress.Negotiate(tt.header))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"title":"Casablanca"}`, 100)
+	ok := func(c echo.Context) error {
+		return c.String(http.StatusOK, body)
+	}
+
+	tests := map[string]struct {
+		encoding    string
+		handler     echo.HandlerFunc
+		expStatus   int
+		expEncoding string
+		expBody     string
+	}{
+		"should compress with brotli": {
+			encoding:    "gzip, br",
+			handler:     ok,
+			expStatus:   http.StatusOK,
+			expEncoding: requestcompress.Brotli,
+			expBody:     body,
+		},
+		"should compress with gzip": {
+			encoding:    "gzip",
+			handler:     ok,
+			expStatus:   http.StatusOK,
+			expEncoding: requestcompress.Gzip,
+			expBody:     body,
+		},
+		"should not compress without accepted encoding": {
+			handler:   ok,
+			expStatus: http.StatusOK,
+			expBody:   body,
+		},
+		"should not compress the errors the error handler sends": {
+			encoding: "gzip",
+			handler: func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusNotFound, "movie not found")
+			},
+			expStatus: http.StatusNotFound,
+			expBody:   `{"message":"movie not found"}` + "\n",
+		},
+		"should not encode a not modified response": {
+			encoding: "br",
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusNotModified)
+			},
+			expStatus: http.StatusNotModified,
+		},
+		"should not encode a response without content": {
+			encoding: "br",
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusNoContent)
+			},
+			expStatus: http.StatusNoContent,
+		},
+		"should send an encoded response as is": {
+			encoding: "gzip",
+			handler: func(c echo.Context) error {
+				c.Response().Header().Set(echo.HeaderContentEncoding, "identity")
+				return c.String(http.StatusOK, body)
+			},
+			expStatus:   http.StatusOK,
+			expEncoding: "identity",
+			expBody:     body,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(requestcompress.Middleware())
+			e.GET("/", tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.encoding != "" {
+				req.Header.Set(echo.HeaderAcceptEncoding, tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			assert.Equal(t, tt.expEncoding, rec.Header().Get(echo.HeaderContentEncoding))
+			assert.Equal(t, echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary))
+
+			var r io.Reader = rec.Body
+			switch tt.expEncoding {
+			case requestcompress.Brotli:
+				r = brotli.NewReader(rec.Body)
+			case requestcompress.Gzip:
+				gr, err := gzip.NewReader(rec.Body)
+				require.NoError(t, err)
+				r = gr
+			}
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expBody, string(b))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package requestlimit contains the HTTP middleware that rate limits the requests.
+package requestlimit
+
+import (
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/app/auth"
+	"movierama/internal/logging"
+	"movierama/internal/ratelimit"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Limit returns a middleware that limits the requests of the route group per client IP, and per
+// auth user when it runs after the JWT middleware. It sets the RateLimit-* headers and rejects the
+// requests over the limit with 429 and Retry-After.
+func Limit(l *ratelimit.Limiter, group string, logger *zap.Logger) echo.MiddlewareFunc {
+	return LimitWithConfig(LimitConfig{Limiter: l, Group: group, Logger: logger})
+}
+
+// LimitConfig defines the config for the Limit middleware.
+type LimitConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	Limiter *ratelimit.Limiter
+	Group   string
+	Logger  *zap.Logger
+}
+
+// LimitWithConfig returns a Limit middleware with config.
+func LimitWithConfig(cfg LimitConfig) echo.MiddlewareFunc {
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper(c) {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			res, err := cfg.Limiter.Allow(ctx, cfg.Group, c.RealIP(), authUserID(c))
+			if err != nil {
+				logging.For(ctx, cfg.Logger).Error("rate limit", zap.String("group", cfg.Group), zap.Error(err))
+			}
+			for k, v := range res.Headers() {
+				c.Response().Header().Set(k, v)
+			}
+			if !res.Allowed {
+				return echo.NewHTTPError(http.StatusTooManyRequests, ratelimit.ErrLimited.Error())
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// authUserID returns the user id of the JWT stored by the JWT middleware, zero when missing.
+func authUserID(c echo.Context) int {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return 0
+	}
+	claims, ok := token.Claims.(*auth.JwtCustomClaims)
+	if !ok {
+		return 0
+	}
+
+	return claims.UserID
+}
@@ -0,0 +1,107 @@
+package requestlimit_test
+
+import (
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/app/auth"
+	"movierama/internal/infra/http/requestlimit"
+	"movierama/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestLimit(t *testing.T) {
+	groups := map[string]ratelimit.Group{
+		ratelimit.GroupVotes: {
+			PerIP:   ratelimit.Limit{Requests: 3, Period: time.Minute},
+			PerUser: ratelimit.Limit{Requests: 1, Period: time.Minute},
+		},
+	}
+
+	tests := map[string]struct {
+		ip          string
+		userID      int
+		requests    int
+		expStatus   int
+		expHeaders  map[string]string
+		expNoHeader bool
+	}{
+		"should allow the requests under the limit": {
+			ip:        "10.1.0.1",
+			requests:  3,
+			expStatus: http.StatusOK,
+			expHeaders: map[string]string{
+				"RateLimit-Limit":     "3",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Policy":    "3;w=60",
+			},
+		},
+		"should reject the requests over the ip limit": {
+			ip:        "10.1.0.1",
+			requests:  4,
+			expStatus: http.StatusTooManyRequests,
+			expHeaders: map[string]string{
+				"RateLimit-Remaining": "0",
+				"Retry-After":         "20",
+			},
+		},
+		"should reject the requests over the user limit": {
+			ip:        "10.1.0.1",
+			userID:    1,
+			requests:  2,
+			expStatus: http.StatusTooManyRequests,
+			expHeaders: map[string]string{
+				"RateLimit-Limit": "1",
+				"Retry-After":     "60",
+			},
+		},
+		"should not limit the allow list": {
+			ip:          "127.0.0.1",
+			requests:    10,
+			expStatus:   http.StatusOK,
+			expNoHeader: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), groups, []string{"127.0.0.0/8"})
+			require.NoError(t, err)
+
+			e := echo.New()
+			setUser := func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if tt.userID != 0 {
+						c.Set("user", &jwt.Token{Claims: &auth.JwtCustomClaims{UserID: tt.userID}})
+					}
+					return next(c)
+				}
+			}
+			e.POST("/votes", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, setUser, requestlimit.Limit(l, ratelimit.GroupVotes, zap.NewNop()))
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/votes", nil)
+				req.Header.Set(echo.HeaderXRealIP, tt.ip)
+				rec = httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+			}
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			for k, v := range tt.expHeaders {
+				assert.Equal(t, v, rec.Header().Get(k), k)
+			}
+			if tt.expNoHeader {
+				assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Package requestlocale contains the HTTP middleware that negotiates the locale of a request.
+package requestlocale
+
+import (
+	"github.com/labstack/echo/v4"
+	"movierama/internal/locale"
+	"net/http"
+)
+
+// HTTP headers of the negotiation.
+const (
+	headerAcceptLanguage  = "Accept-Language"
+	headerContentLanguage = "Content-Language"
+)
+
+// Middleware returns a middleware that negotiates the locale of the request from its Accept-Language header,
+// stores it in the request context and translates the messages of the errors returned by the handlers.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			l := locale.Negotiate(req.Header.Get(headerAcceptLanguage))
+			c.Response().Header().Set(headerContentLanguage, string(l))
+			c.Response().Header().Add(echo.HeaderVary, headerAcceptLanguage)
+			c.SetRequest(req.WithContext(locale.WithLocale(req.Context(), l)))
+
+			err := next(c)
+			if err == nil || l == locale.English {
+				return err
+			}
+
+			return translate(err, l)
+		}
+	}
+}
+
+// translate returns the error with its message in the locale, as the error handler writes it.
+func translate(err error, l locale.Locale) error {
+	he, ok := err.(*echo.HTTPError)
+	if !ok {
+		return &echo.HTTPError{
+			Code:     http.StatusInternalServerError,
+			Message:  l.Translate(http.StatusText(http.StatusInternalServerError)),
+			Internal: err,
+		}
+	}
+	// The error handler writes an internal HTTP error in place of its wrapper.
+	if internal, ok := he.Internal.(*echo.HTTPError); ok {
+		he = internal
+	}
+	msg, ok := he.Message.(string)
+	if !ok {
+		return err
+	}
+
+	return &echo.HTTPError{Code: he.Code, Message: l.Translate(msg), Internal: he.Internal}
+}
@@ -0,0 +1,99 @@
+package requestlocale_test
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"movierama/internal/infra/http/requestlocale"
+	"movierama/internal/locale"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		lang        string
+		handler     echo.HandlerFunc
+		expStatus   int
+		expLanguage string
+		expBody     string
+	}{
+		"should store the locale of the request": {
+			lang: "el-GR, en;q=0.5",
+			handler: func(c echo.Context) error {
+				return c.String(http.StatusOK, string(locale.FromContext(c.Request().Context())))
+			},
+			expStatus:   http.StatusOK,
+			expLanguage: "el",
+			expBody:     "el",
+		},
+		"should default to English": {
+			handler: func(c echo.Context) error {
+				return c.String(http.StatusOK, string(locale.FromContext(c.Request().Context())))
+			},
+			expStatus:   http.StatusOK,
+			expLanguage: "en",
+			expBody:     "en",
+		},
+		"should translate the message of an error": {
+			lang: "fr",
+			handler: func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusNotFound, "movie not found")
+			},
+			expStatus:   http.StatusNotFound,
+			expLanguage: "fr",
+			expBody:     `{"message":"film introuvable"}` + "\n",
+		},
+		"should translate the message of an internal error": {
+			lang: "fr",
+			handler: func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusUnauthorized).SetInternal(echo.NewHTTPError(http.StatusForbidden, "Forbidden"))
+			},
+			expStatus:   http.StatusForbidden,
+			expLanguage: "fr",
+			expBody:     `{"message":"Interdit"}` + "\n",
+		},
+		"should translate the message of an unexpected error": {
+			lang: "el",
+			handler: func(c echo.Context) error {
+				return errors.New("random error")
+			},
+			expStatus:   http.StatusInternalServerError,
+			expLanguage: "el",
+			expBody:     `{"message":"Εσωτερικό σφάλμα διακομιστή"}` + "\n",
+		},
+		"should translate the message of a shared error": {
+			lang: "el",
+			handler: func(c echo.Context) error {
+				return echo.ErrNotFound
+			},
+			expStatus:   http.StatusNotFound,
+			expLanguage: "el",
+			expBody:     `{"message":"Δεν βρέθηκε"}` + "\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(requestlocale.Middleware())
+			e.GET("/", tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.lang != "" {
+				req.Header.Set("Accept-Language", tt.lang)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			assert.Equal(t, tt.expLanguage, rec.Header().Get("Content-Language"))
+			assert.Equal(t, "Accept-Language", rec.Header().Get(echo.HeaderVary))
+			assert.Equal(t, tt.expBody, rec.Body.String())
+		})
+	}
+
+	// The shared errors of echo are not changed.
+	assert.Equal(t, "Not Found", echo.ErrNotFound.Message)
+}
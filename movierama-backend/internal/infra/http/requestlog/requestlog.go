@@ -0,0 +1,93 @@
+// Package requestlog contains the HTTP middleware that correlates and logs requests.
+package requestlog
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/logging"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxRequestIDLength limits the length of the request ids accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestID returns a middleware that reuses the X-Request-ID header of the request
+// or generates a new id, echoes it in the response and stores it in the request context.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			rid := req.Header.Get(echo.HeaderXRequestID)
+			if rid == "" || len(rid) > maxRequestIDLength {
+				rid = logging.NewRequestID()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, rid)
+			c.SetRequest(req.WithContext(logging.WithRequestID(req.Context(), rid)))
+
+			return next(c)
+		}
+	}
+}
+
+// Logger returns a middleware that writes an access log line per request.
+func Logger(l *zap.Logger) echo.MiddlewareFunc {
+	return LoggerWithConfig(LoggerConfig{Logger: l})
+}
+
+// LoggerConfig defines the config for the Logger middleware.
+type LoggerConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	Logger  *zap.Logger
+}
+
+// LoggerWithConfig returns a Logger middleware with config.
+func LoggerWithConfig(cfg LoggerConfig) echo.MiddlewareFunc {
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper(c) {
+				return next(c)
+			}
+
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// Let the error handler write the response so that the status is known.
+				c.Error(err)
+			}
+
+			req := c.Request()
+			res := c.Response()
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("uri", req.RequestURI),
+				zap.String("route", c.Path()),
+				zap.Int("status", res.Status),
+				zap.Duration("latency", time.Since(start)),
+				zap.String("remote_ip", c.RealIP()),
+				zap.Int64("bytes_out", res.Size),
+			}
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+
+			l := logging.For(req.Context(), cfg.Logger)
+			switch {
+			case res.Status >= 500:
+				l.Error("request", fields...)
+			case res.Status >= 400:
+				l.Warn("request", fields...)
+			default:
+				l.Info("request", fields...)
+			}
+
+			return nil
+		}
+	}
+}
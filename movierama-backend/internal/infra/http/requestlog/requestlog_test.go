@@ -0,0 +1,109 @@
+package requestlog_test
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"movierama/internal/infra/http/requestlog"
+	"movierama/internal/logging"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		reqID     string
+		handler   echo.HandlerFunc
+		expStatus int
+		expLevel  zapcore.Level
+	}{
+		"should reuse the request id of the request": {
+			reqID: "client-id",
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+			expStatus: http.StatusOK,
+			expLevel:  zapcore.InfoLevel,
+		},
+		"should generate a request id": {
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+			expStatus: http.StatusOK,
+			expLevel:  zapcore.InfoLevel,
+		},
+		"should replace an oversized request id": {
+			reqID: strings.Repeat("a", 200),
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+			expStatus: http.StatusOK,
+			expLevel:  zapcore.InfoLevel,
+		},
+		"should log the status of handler errors": {
+			handler: func(c echo.Context) error {
+				return errors.New("random error")
+			},
+			expStatus: http.StatusInternalServerError,
+			expLevel:  zapcore.ErrorLevel,
+		},
+		"should warn on client errors": {
+			handler: func(c echo.Context) error {
+				return echo.ErrNotFound
+			},
+			expStatus: http.StatusNotFound,
+			expLevel:  zapcore.WarnLevel,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			l := zap.New(core)
+
+			e := echo.New()
+			e.Use(requestlog.RequestID())
+			e.Use(requestlog.Logger(l))
+			e.GET("/movies", func(c echo.Context) error {
+				logging.For(c.Request().Context(), l).Info("handler")
+
+				return tt.handler(c)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+			if tt.reqID != "" {
+				req.Header.Set(echo.HeaderXRequestID, tt.reqID)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			rid := rec.Header().Get(echo.HeaderXRequestID)
+			assert.NotEmpty(t, rid)
+			if tt.reqID != "" && len(tt.reqID) <= 128 {
+				assert.Equal(t, tt.reqID, rid)
+			} else {
+				assert.NotEqual(t, tt.reqID, rid)
+			}
+
+			entries := logs.All()
+			if assert.Len(t, entries, 2) {
+				assert.Equal(t, "handler", entries[0].Message)
+				assert.Equal(t, rid, entries[0].ContextMap()["request_id"])
+
+				access := entries[1]
+				assert.Equal(t, "request", access.Message)
+				assert.Equal(t, tt.expLevel, access.Level)
+				assert.Equal(t, rid, access.ContextMap()["request_id"])
+				assert.Equal(t, "/movies", access.ContextMap()["route"])
+				assert.EqualValues(t, tt.expStatus, access.ContextMap()["status"])
+			}
+		})
+	}
+}
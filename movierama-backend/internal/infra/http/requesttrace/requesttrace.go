@@ -0,0 +1,80 @@
+// Package requesttrace contains the HTTP middleware that starts a server span per request.
+package requesttrace
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "movierama/internal/infra/http/requesttrace"
+
+// Middleware returns a middleware that starts a server span per request, continuing
+// the trace of the W3C traceparent header when present.
+func Middleware(tp trace.TracerProvider) echo.MiddlewareFunc {
+	return MiddlewareWithConfig(Config{TracerProvider: tp})
+}
+
+// Config defines the config for the tracing middleware.
+type Config struct {
+	// Skipper defines a function to skip middleware.
+	Skipper        middleware.Skipper
+	TracerProvider trace.TracerProvider
+}
+
+// MiddlewareWithConfig returns a tracing middleware with config.
+func MiddlewareWithConfig(cfg Config) echo.MiddlewareFunc {
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+	tracer := cfg.TracerProvider.Tracer(tracerName)
+	propagator := propagation.TraceContext{}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			route := c.Path()
+			ctx, span := tracer.Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(req.Method),
+					semconv.HTTPRouteKey.String(route),
+					semconv.HTTPTargetKey.String(req.RequestURI),
+					semconv.NetPeerIPKey.String(c.RealIP()),
+				),
+			)
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			// The error handler writes the response of failed requests later on.
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				}
+				span.RecordError(err)
+			}
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return err
+		}
+	}
+}
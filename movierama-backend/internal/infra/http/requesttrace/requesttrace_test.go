@@ -0,0 +1,98 @@
+package requesttrace_test
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/infra/http/requesttrace"
+	"movierama/internal/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	const (
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID = "00f067aa0ba902b7"
+	)
+
+	tests := map[string]struct {
+		traceparent string
+		handler     echo.HandlerFunc
+		expStatus   int
+		expCode     codes.Code
+	}{
+		"should continue the trace of the traceparent header": {
+			traceparent: "00-" + traceID + "-" + parentSpanID + "-01",
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+			expStatus: http.StatusOK,
+		},
+		"should start a new trace": {
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+			expStatus: http.StatusOK,
+		},
+		"should mark server errors": {
+			handler: func(c echo.Context) error {
+				return errors.New("random error")
+			},
+			expStatus: http.StatusInternalServerError,
+			expCode:   codes.Error,
+		},
+		"should not mark client errors": {
+			handler: func(c echo.Context) error {
+				return echo.ErrUnauthorized
+			},
+			expStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tp, exporter := tracing.NewTestProvider()
+			e := echo.New()
+			e.Use(requesttrace.Middleware(tp))
+
+			var handlerSpan trace.SpanContext
+			e.GET("/users/:user_id/movies", func(c echo.Context) error {
+				handlerSpan = trace.SpanContextFromContext(c.Request().Context())
+
+				return tt.handler(c)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1/movies", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expStatus, rec.Code)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, "GET /users/:user_id/movies", span.Name)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+			assert.Equal(t, span.SpanContext.SpanID(), handlerSpan.SpanID(), "the handler context carries the span")
+			assert.Equal(t, tt.expCode, span.Status.Code)
+			assert.Contains(t, span.Attributes, attribute.Int("http.status_code", tt.expStatus))
+			assert.Contains(t, span.Attributes, attribute.String("http.route", "/users/:user_id/movies"))
+			if tt.traceparent != "" {
+				assert.Equal(t, traceID, span.SpanContext.TraceID().String())
+				assert.Equal(t, parentSpanID, span.Parent.SpanID().String())
+				assert.True(t, span.Parent.IsRemote())
+			} else {
+				assert.False(t, span.Parent.IsValid())
+			}
+		})
+	}
+}
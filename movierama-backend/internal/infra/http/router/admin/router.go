@@ -0,0 +1,300 @@
+package admin
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/app/admin"
+	"movierama/internal/app/auth"
+	"movierama/internal/infra/http/requestauth"
+	"movierama/internal/logging"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// Router infrastructure definition.
+type Router struct {
+	adSvc  admin.Service
+	asSvc  auth.Service
+	jwtCfg middleware.JWTConfig
+	log    *zap.Logger
+}
+
+// NewRouter returns an HTTP component to serve all the routes of the moderators and admins,
+// along with the reports of the movies by the users.
+func NewRouter(adSvc admin.Service, asSvc auth.Service, jwtCfg middleware.JWTConfig, logger *zap.Logger) *Router {
+	return &Router{
+		adSvc:  adSvc,
+		asSvc:  asSvc,
+		jwtCfg: jwtCfg,
+		log:    logger,
+	}
+}
+
+// AppendRoutes adds admin routes to router.
+func (r *Router) AppendRoutes(e *echo.Echo) {
+	e.POST("/api/v1/movies/:movie_id/report", r.ReportMovie,
+		middleware.JWTWithConfig(requestauth.Scoped(r.jwtCfg, auth.ScopeReportsWrite)))
+
+	rg := e.Group("/api/v1/admin")
+	rg.Use(middleware.JWTWithConfig(r.jwtCfg))
+	rg.Use(requestauth.RequireRole(auth.RoleModerator))
+	rg.GET("/users", r.ListUsers)
+	rg.PUT("/users/:user_id/status", r.SetStatus)
+	rg.PUT("/users/:user_id/role", r.SetRole, requestauth.RequireRole(auth.RoleAdmin))
+	rg.POST("/movies/:movie_id/remove", r.RemoveMovie)
+	rg.POST("/movies/:movie_id/merge", r.MergeMovie, requestauth.RequireRole(auth.RoleAdmin))
+	rg.GET("/queue", r.GetQueue)
+	rg.POST("/queue/:movie_id", r.ResolveReports)
+	rg.GET("/actions", r.GetActions)
+	rg.GET("/auth-events", r.GetAuthEvents, requestauth.RequireRole(auth.RoleAdmin))
+}
+
+// ListUsers searches the accounts, ordered by id.
+func (r *Router) ListUsers(c echo.Context) error {
+	filter := admin.AccountFilter{
+		Query:  c.QueryParam("q"),
+		Role:   auth.Role(c.QueryParam("role")),
+		Status: c.QueryParam("status"),
+	}
+	var err error
+	if filter.AfterID, err = intParam(c.QueryParam("after"), "after must be a user id"); err != nil {
+		return err
+	}
+	if filter.Limit, err = intParam(c.QueryParam("limit"), "limit must be a number"); err != nil {
+		return err
+	}
+
+	accounts, err := r.adSvc.ListUsers(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, accounts)
+}
+
+// SetStatus suspends, bans or reinstates a user.
+func (r *Router) SetStatus(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id must be a number")
+	}
+	change := new(admin.StatusChange)
+	if err = c.Bind(change); err != nil {
+		return err
+	}
+	logging.For(c.Request().Context(), r.log).Debug("status change request", logging.Payload("payload", change))
+
+	if err = r.adSvc.SetStatus(c.Request().Context(), actor(c), userID, change); err != nil {
+		return toHTTPError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetRole changes the role of a user.
+func (r *Router) SetRole(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_id must be a number")
+	}
+	change := new(admin.RoleChange)
+	if err = c.Bind(change); err != nil {
+		return err
+	}
+
+	if err = r.adSvc.SetRole(c.Request().Context(), actor(c), userID, change); err != nil {
+		return toHTTPError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveMovie deletes a movie with its votes.
+func (r *Router) RemoveMovie(c echo.Context) error {
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "movie_id must be a number")
+	}
+	removal := new(admin.MovieRemoval)
+	if err = c.Bind(removal); err != nil {
+		return err
+	}
+
+	if err = r.adSvc.RemoveMovie(c.Request().Context(), actor(c), movieID, removal); err != nil {
+		return toHTTPError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// MergeMovie folds a duplicate movie into another movie.
+func (r *Router) MergeMovie(c echo.Context) error {
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "movie_id must be a number")
+	}
+	merge := new(admin.MovieMerge)
+	if err = c.Bind(merge); err != nil {
+		return err
+	}
+
+	if err = r.adSvc.MergeMovie(c.Request().Context(), actor(c), movieID, merge); err != nil {
+		return toHTTPError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ReportMovie reports a movie to the moderators.
+func (r *Router) ReportMovie(c echo.Context) error {
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "movie_id must be a number")
+	}
+	report := new(admin.Report)
+	if err = c.Bind(report); err != nil {
+		return err
+	}
+	claims, _ := requestauth.Claims(c)
+
+	if err = r.adSvc.ReportMovie(c.Request().Context(), claims.UserID, movieID, report); err != nil {
+		return toHTTPError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetQueue gets the movies with open reports, the most reported first.
+func (r *Router) GetQueue(c echo.Context) error {
+	var filter admin.QueueFilter
+	var err error
+	if filter.Offset, err = intParam(c.QueryParam("offset"), "offset must be a number"); err != nil {
+		return err
+	}
+	if filter.Limit, err = intParam(c.QueryParam("limit"), "limit must be a number"); err != nil {
+		return err
+	}
+
+	queue, err := r.adSvc.GetQueue(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, queue)
+}
+
+// ResolveReports dismisses the reports of a movie, hides it, deletes it or warns its submitter.
+func (r *Router) ResolveReports(c echo.Context) error {
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "movie_id must be a number")
+	}
+	resolution := new(admin.Resolution)
+	if err = c.Bind(resolution); err != nil {
+		return err
+	}
+
+	if err = r.adSvc.ResolveReports(c.Request().Context(), actor(c), movieID, resolution); err != nil {
+		return toHTTPError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetActions gets the moderation actions, newest first.
+func (r *Router) GetActions(c echo.Context) error {
+	filter := admin.ActionFilter{Action: c.QueryParam("action")}
+	var err error
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"moderator_id", &filter.ModeratorID},
+		{"user_id", &filter.UserID},
+		{"movie_id", &filter.MovieID},
+		{"before", &filter.BeforeID},
+		{"limit", &filter.Limit},
+	} {
+		if *p.dst, err = intParam(c.QueryParam(p.name), p.name+" must be a number"); err != nil {
+			return err
+		}
+	}
+
+	actions, err := r.adSvc.GetActions(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, actions)
+}
+
+// GetAuthEvents gets the auth events of every user, newest first.
+func (r *Router) GetAuthEvents(c echo.Context) error {
+	filter := auth.EventFilter{
+		Username: c.QueryParam("username"),
+		IP:       c.QueryParam("ip"),
+		Event:    c.QueryParam("event"),
+	}
+	var err error
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"user_id", &filter.UserID},
+		{"before", &filter.BeforeID},
+		{"limit", &filter.Limit},
+	} {
+		if *p.dst, err = intParam(c.QueryParam(p.name), p.name+" must be a number"); err != nil {
+			return err
+		}
+	}
+
+	events, err := r.asSvc.GetEvents(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+// intParam parses an optional number parameter, zero when empty.
+func intParam(v, msg string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+
+	return i, nil
+}
+
+// actor returns the moderator of the request, the JWT middleware and RequireRole ran before.
+func actor(c echo.Context) admin.Actor {
+	claims, _ := requestauth.Claims(c)
+
+	return admin.Actor{ID: claims.UserID, Role: claims.UserRole()}
+}
+
+// toHTTPError maps the admin errors to their status, the other errors are returned as is.
+func toHTTPError(err error) error {
+	switch {
+	case errors.Is(err, admin.ErrForbidden), errors.Is(err, admin.ErrOwnAccount), errors.Is(err, admin.ErrOwnMovie):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, admin.ErrUserNotFound), errors.Is(err, admin.ErrMovieNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, admin.ErrAlreadyReported):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, admin.ErrInvalidStatus), errors.Is(err, admin.ErrInvalidUntil),
+		errors.Is(err, admin.ErrInvalidRole), errors.Is(err, admin.ErrInvalidReason),
+		errors.Is(err, admin.ErrInvalidCategory), errors.Is(err, admin.ErrInvalidResolution),
+		errors.Is(err, admin.ErrSameMovie):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return err
+}
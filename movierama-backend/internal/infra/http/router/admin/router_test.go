@@ -0,0 +1,367 @@
+package admin_test
+
+import (
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	adminservice "movierama/internal/app/admin"
+	authservice "movierama/internal/app/auth"
+	"movierama/internal/infra/http/router/admin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const jwtSecret = "secret"
+
+func token(t *testing.T, userID int, role authservice.Role) string {
+	tk := jwt.NewWithClaims(jwt.SigningMethodHS256, &authservice.JwtCustomClaims{UserID: userID, Role: role})
+	s, err := tk.SignedString([]byte(jwtSecret))
+	require.NoError(t, err)
+
+	return s
+}
+
+func newEcho(adSvc *adminservice.SvcMock, asSvc *authservice.SvcMock) *echo.Echo {
+	e := echo.New()
+	jwtCfg := middleware.JWTConfig{Claims: &authservice.JwtCustomClaims{}, SigningKey: []byte(jwtSecret)}
+	admin.NewRouter(adSvc, asSvc, jwtCfg, zap.NewNop()).AppendRoutes(e)
+
+	return e
+}
+
+func serve(e *echo.Echo, method, target, body, tok string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if tok != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+tok)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRouter_Access(t *testing.T) {
+	tests := map[string]struct {
+		method  string
+		target  string
+		role    authservice.Role
+		noToken bool
+		expCode int
+	}{
+		"should require a token": {
+			method:  http.MethodGet,
+			target:  "/api/v1/admin/users",
+			noToken: true,
+			expCode: http.StatusBadRequest,
+		},
+		"should forbid the users": {
+			method:  http.MethodGet,
+			target:  "/api/v1/admin/users",
+			role:    authservice.RoleUser,
+			expCode: http.StatusForbidden,
+		},
+		"should forbid the tokens without role": {
+			method:  http.MethodGet,
+			target:  "/api/v1/admin/actions",
+			expCode: http.StatusForbidden,
+		},
+		"should forbid the role changes of the moderators": {
+			method:  http.MethodPut,
+			target:  "/api/v1/admin/users/2/role",
+			role:    authservice.RoleModerator,
+			expCode: http.StatusForbidden,
+		},
+		"should forbid the queue to the users": {
+			method:  http.MethodGet,
+			target:  "/api/v1/admin/queue",
+			role:    authservice.RoleUser,
+			expCode: http.StatusForbidden,
+		},
+		"should require a token to report": {
+			method:  http.MethodPost,
+			target:  "/api/v1/movies/4/report",
+			noToken: true,
+			expCode: http.StatusBadRequest,
+		},
+		"should forbid the merges to the moderators": {
+			method:  http.MethodPost,
+			target:  "/api/v1/admin/movies/4/merge",
+			role:    authservice.RoleModerator,
+			expCode: http.StatusForbidden,
+		},
+		"should forbid the auth events to the moderators": {
+			method:  http.MethodGet,
+			target:  "/api/v1/admin/auth-events",
+			role:    authservice.RoleModerator,
+			expCode: http.StatusForbidden,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			adSvc := &adminservice.SvcMock{}
+			tok := ""
+			if !tt.noToken {
+				tok = token(t, 1, tt.role)
+			}
+
+			rec := serve(newEcho(adSvc, &authservice.SvcMock{}), tt.method, tt.target, "{}", tok)
+			assert.Equal(t, tt.expCode, rec.Code)
+			adSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_ListUsers(t *testing.T) {
+	adSvc := &adminservice.SvcMock{}
+	adSvc.On("ListUsers", mock.Anything, adminservice.AccountFilter{Query: "ali", Role: "user", Status: "banned", AfterID: 3, Limit: 10}).
+		Return([]adminservice.Account{{ID: 4, Username: "alice", Role: authservice.RoleUser, Status: "banned"}}, nil)
+
+	e := newEcho(adSvc, &authservice.SvcMock{})
+	rec := serve(e, http.MethodGet, "/api/v1/admin/users?q=ali&role=user&status=banned&after=3&limit=10", "", token(t, 1, authservice.RoleModerator))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"id":4,"username":"alice","first_name":"","last_name":"","role":"user","status":"banned"}]`, rec.Body.String())
+
+	rec = serve(e, http.MethodGet, "/api/v1/admin/users?after=x", "", token(t, 1, authservice.RoleModerator))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	adSvc.AssertExpectations(t)
+}
+
+func TestRouter_SetStatus(t *testing.T) {
+	until := time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		err     error
+		expCode int
+	}{
+		"should change the status": {
+			expCode: http.StatusNoContent,
+		},
+		"should return 403 when not allowed": {
+			err:     adminservice.ErrForbidden,
+			expCode: http.StatusForbidden,
+		},
+		"should return 404 on a missing user": {
+			err:     adminservice.ErrUserNotFound,
+			expCode: http.StatusNotFound,
+		},
+		"should return 422 on an invalid change": {
+			err:     adminservice.ErrInvalidUntil,
+			expCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			adSvc := &adminservice.SvcMock{}
+			adSvc.On("SetStatus", mock.Anything, adminservice.Actor{ID: 1, Role: authservice.RoleModerator}, 2,
+				&adminservice.StatusChange{Status: "suspended", Until: &until, Reason: "rude"}).Return(tt.err)
+
+			rec := serve(newEcho(adSvc, &authservice.SvcMock{}), http.MethodPut, "/api/v1/admin/users/2/status",
+				`{"status":"suspended","until":"2026-10-21T00:00:00Z","reason":"rude"}`, token(t, 1, authservice.RoleModerator))
+			assert.Equal(t, tt.expCode, rec.Code)
+			adSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_SetRole(t *testing.T) {
+	adSvc := &adminservice.SvcMock{}
+	adSvc.On("SetRole", mock.Anything, adminservice.Actor{ID: 1, Role: authservice.RoleAdmin}, 2,
+		&adminservice.RoleChange{Role: authservice.RoleModerator, Reason: "trusted"}).Return(nil)
+
+	rec := serve(newEcho(adSvc, &authservice.SvcMock{}), http.MethodPut, "/api/v1/admin/users/2/role",
+		`{"role":"moderator","reason":"trusted"}`, token(t, 1, authservice.RoleAdmin))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	adSvc.AssertExpectations(t)
+}
+
+func TestRouter_RemoveMovie(t *testing.T) {
+	tests := map[string]struct {
+		target  string
+		err     error
+		expCode int
+	}{
+		"should remove the movie": {
+			target:  "/api/v1/admin/movies/4/remove",
+			expCode: http.StatusNoContent,
+		},
+		"should return 404 on a missing movie": {
+			target:  "/api/v1/admin/movies/4/remove",
+			err:     adminservice.ErrMovieNotFound,
+			expCode: http.StatusNotFound,
+		},
+		"should return 400 on an invalid id": {
+			target:  "/api/v1/admin/movies/x/remove",
+			expCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			adSvc := &adminservice.SvcMock{}
+			adSvc.On("RemoveMovie", mock.Anything, adminservice.Actor{ID: 1, Role: authservice.RoleModerator}, 4,
+				&adminservice.MovieRemoval{Reason: "spam"}).Return(tt.err).Maybe()
+
+			rec := serve(newEcho(adSvc, &authservice.SvcMock{}), http.MethodPost, tt.target, `{"reason":"spam"}`,
+				token(t, 1, authservice.RoleModerator))
+			assert.Equal(t, tt.expCode, rec.Code)
+			adSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_MergeMovie(t *testing.T) {
+	tests := map[string]struct {
+		err     error
+		expCode int
+	}{
+		"should merge the movie": {
+			expCode: http.StatusNoContent,
+		},
+		"should return 422 on a merge into itself": {
+			err:     adminservice.ErrSameMovie,
+			expCode: http.StatusUnprocessableEntity,
+		},
+		"should return 404 on a missing movie": {
+			err:     adminservice.ErrMovieNotFound,
+			expCode: http.StatusNotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			adSvc := &adminservice.SvcMock{}
+			adSvc.On("MergeMovie", mock.Anything, adminservice.Actor{ID: 1, Role: authservice.RoleAdmin}, 4,
+				&adminservice.MovieMerge{Into: 2, Reason: "duplicate"}).Return(tt.err)
+
+			rec := serve(newEcho(adSvc, &authservice.SvcMock{}), http.MethodPost, "/api/v1/admin/movies/4/merge",
+				`{"into":2,"reason":"duplicate"}`, token(t, 1, authservice.RoleAdmin))
+			assert.Equal(t, tt.expCode, rec.Code)
+			adSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_GetActions(t *testing.T) {
+	adSvc := &adminservice.SvcMock{}
+	adSvc.On("GetActions", mock.Anything, adminservice.ActionFilter{ModeratorID: 1, MovieID: 4, Action: "remove_movie", BeforeID: 9, Limit: 5}).
+		Return([]adminservice.Action{}, nil)
+
+	rec := serve(newEcho(adSvc, &authservice.SvcMock{}), http.MethodGet,
+		"/api/v1/admin/actions?moderator_id=1&movie_id=4&action=remove_movie&before=9&limit=5", "", token(t, 1, authservice.RoleModerator))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+	adSvc.AssertExpectations(t)
+}
+
+func TestRouter_GetAuthEvents(t *testing.T) {
+	asSvc := &authservice.SvcMock{}
+	asSvc.On("GetEvents", mock.Anything, authservice.EventFilter{UserID: 2, Username: "bob", IP: "192.0.2.1", Event: "login_failed", Limit: 20}).
+		Return([]authservice.Event{}, nil)
+
+	rec := serve(newEcho(&adminservice.SvcMock{}, asSvc), http.MethodGet,
+		"/api/v1/admin/auth-events?user_id=2&username=bob&ip=192.0.2.1&event=login_failed&limit=20", "", token(t, 1, authservice.RoleAdmin))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	asSvc.AssertExpectations(t)
+}
+
+func TestRouter_ReportMovie(t *testing.T) {
+	tests := map[string]struct {
+		target  string
+		err     error
+		expCode int
+	}{
+		"should report the movie": {
+			target:  "/api/v1/movies/4/report",
+			expCode: http.StatusNoContent,
+		},
+		"should return 409 on a second report": {
+			target:  "/api/v1/movies/4/report",
+			err:     adminservice.ErrAlreadyReported,
+			expCode: http.StatusConflict,
+		},
+		"should return 403 on an own movie": {
+			target:  "/api/v1/movies/4/report",
+			err:     adminservice.ErrOwnMovie,
+			expCode: http.StatusForbidden,
+		},
+		"should return 422 on an invalid category": {
+			target:  "/api/v1/movies/4/report",
+			err:     adminservice.ErrInvalidCategory,
+			expCode: http.StatusUnprocessableEntity,
+		},
+		"should return 400 on an invalid id": {
+			target:  "/api/v1/movies/x/report",
+			expCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			adSvc := &adminservice.SvcMock{}
+			adSvc.On("ReportMovie", mock.Anything, 3, 4, &adminservice.Report{Category: "spam"}).Return(tt.err).Maybe()
+
+			rec := serve(newEcho(adSvc, &authservice.SvcMock{}), http.MethodPost, tt.target, `{"category":"spam"}`,
+				token(t, 3, authservice.RoleUser))
+			assert.Equal(t, tt.expCode, rec.Code)
+			adSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_GetQueue(t *testing.T) {
+	adSvc := &adminservice.SvcMock{}
+	adSvc.On("GetQueue", mock.Anything, adminservice.QueueFilter{Offset: 20, Limit: 10}).Return([]adminservice.QueueEntry{
+		{MovieID: 4, Title: "Spam", UserID: 2, Reports: 3, Categories: map[string]int{"spam": 2, "other": 1}},
+	}, nil)
+
+	e := newEcho(adSvc, &authservice.SvcMock{})
+	rec := serve(e, http.MethodGet, "/api/v1/admin/queue?offset=20&limit=10", "", token(t, 1, authservice.RoleModerator))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"movie_id":4,"title":"Spam","user_id":2,"reports":3,"categories":{"spam":2,"other":1}}]`, rec.Body.String())
+
+	rec = serve(e, http.MethodGet, "/api/v1/admin/queue?offset=x", "", token(t, 1, authservice.RoleModerator))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	adSvc.AssertExpectations(t)
+}
+
+func TestRouter_ResolveReports(t *testing.T) {
+	tests := map[string]struct {
+		err     error
+		expCode int
+	}{
+		"should resolve the reports": {
+			expCode: http.StatusNoContent,
+		},
+		"should return 404 on a missing movie": {
+			err:     adminservice.ErrMovieNotFound,
+			expCode: http.StatusNotFound,
+		},
+		"should return 422 on an invalid resolution": {
+			err:     adminservice.ErrInvalidResolution,
+			expCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			adSvc := &adminservice.SvcMock{}
+			adSvc.On("ResolveReports", mock.Anything, adminservice.Actor{ID: 1, Role: authservice.RoleModerator}, 4,
+				&adminservice.Resolution{Action: "hide", Reason: "offensive title"}).Return(tt.err)
+
+			rec := serve(newEcho(adSvc, &authservice.SvcMock{}), http.MethodPost, "/api/v1/admin/queue/4",
+				`{"action":"hide","reason":"offensive title"}`, token(t, 1, authservice.RoleModerator))
+			assert.Equal(t, tt.expCode, rec.Code)
+			adSvc.AssertExpectations(t)
+		})
+	}
+}
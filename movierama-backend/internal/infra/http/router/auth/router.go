@@ -1,27 +1,76 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"math"
 	"movierama/internal/app/auth"
+	"movierama/internal/infra/http/requestauth"
+	"movierama/internal/infra/http/requestlimit"
+	"movierama/internal/logging"
+	"movierama/internal/ratelimit"
 	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// The cookie binding the external logins to the browser that started them.
+const (
+	bindingCookie     = "movierama_oidc"
+	bindingCookiePath = "/api/v1/auth/oidc"
 )
 
 // Router infrastructure definition.
 type Router struct {
-	asSvc auth.Service
+	asSvc   auth.Service
+	jwtCfg  middleware.JWTConfig
+	limiter *ratelimit.Limiter
+	log     *zap.Logger
 }
 
 // NewRouter returns an HTTP component to serve all the routes for the user auth.
-func NewRouter(asSvc auth.Service) *Router {
+func NewRouter(asSvc auth.Service, jwtCfg middleware.JWTConfig, logger *zap.Logger) *Router {
 	return &Router{
-		asSvc: asSvc,
+		asSvc:  asSvc,
+		jwtCfg: jwtCfg,
+		log:    logger,
 	}
 }
 
+// WithLimiter rate limits the login, the registration and the emails with l.
+func (r *Router) WithLimiter(l *ratelimit.Limiter) *Router {
+	r.limiter = l
+
+	return r
+}
+
 // AppendRoutes adds auth routes to router.
 func (r *Router) AppendRoutes(e *echo.Echo) {
-	e.POST("/api/v1/auth/login", r.Login)
-	e.POST("/api/v1/auth/register", r.Register)
+	e.POST("/api/v1/auth/login", r.Login, requestlimit.Limit(r.limiter, ratelimit.GroupLogin, r.log))
+	e.POST("/api/v1/auth/register", r.Register, requestlimit.Limit(r.limiter, ratelimit.GroupRegister, r.log))
+	e.GET("/api/v1/auth/oidc/providers", r.GetProviders)
+	e.GET("/api/v1/auth/oidc/:provider/authorize", r.AuthorizeOIDC, requestlimit.Limit(r.limiter, ratelimit.GroupLogin, r.log))
+	// The JWT is optional, a link is completed by the user who started it.
+	e.POST("/api/v1/auth/oidc/callback", r.LoginOIDC, requestlimit.Limit(r.limiter, ratelimit.GroupLogin, r.log),
+		middleware.JWTWithConfig(requestauth.Optional(r.jwtCfg)))
+	e.POST("/api/v1/auth/verify-email", r.VerifyEmail, requestlimit.Limit(r.limiter, ratelimit.GroupLogin, r.log))
+	e.POST("/api/v1/auth/verify-email/resend", r.ResendVerification, requestlimit.Limit(r.limiter, ratelimit.GroupEmail, r.log))
+	e.POST("/api/v1/auth/forgot-password", r.ForgotPassword, requestlimit.Limit(r.limiter, ratelimit.GroupEmail, r.log))
+	e.POST("/api/v1/auth/reset-password", r.ResetPassword, requestlimit.Limit(r.limiter, ratelimit.GroupLogin, r.log))
+
+	rg := e.Group("/api/v1/auth")
+	rg.Use(middleware.JWTWithConfig(r.jwtCfg))
+	rg.PUT("/password", r.ChangePassword)
+	rg.PUT("/email", r.ChangeEmail, requestlimit.Limit(r.limiter, ratelimit.GroupEmail, r.log))
+	rg.GET("/events", r.GetEvents)
+	rg.POST("/oidc/:provider/link", r.LinkOIDC)
+	rg.POST("/tokens", r.CreateAccessToken)
+	rg.GET("/tokens", r.GetAccessTokens)
+	rg.DELETE("/tokens/:token_id", r.RevokeAccessToken)
 }
 
 // Login log's in the user.
@@ -31,10 +80,11 @@ func (r *Router) Login(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	logging.For(c.Request().Context(), r.log).Debug("login request", logging.Payload("payload", ul))
 
-	res, err := r.asSvc.Login(c, ul)
+	res, err := r.asSvc.Login(withClient(c), ul)
 	if err != nil {
-		return err
+		return toHTTPError(c, err)
 	}
 	return c.JSON(http.StatusOK, res)
 }
@@ -46,10 +96,288 @@ func (r *Router) Register(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	err = r.asSvc.Register(c.Request().Context(), userInput)
+	logging.For(c.Request().Context(), r.log).Debug("register request", logging.Payload("payload", userInput))
+	err = r.asSvc.Register(withClient(c), userInput)
 	if err != nil {
-		return err
+		return toHTTPError(c, err)
 	}
 
 	return c.JSON(http.StatusCreated, nil)
 }
+
+// GetProviders gets the names of the external identity providers.
+func (r *Router) GetProviders(c echo.Context) error {
+	providers := r.asSvc.Providers(c.Request().Context())
+	if providers == nil {
+		providers = []string{}
+	}
+
+	return c.JSON(http.StatusOK, providers)
+}
+
+// AuthorizeOIDC starts a login with an external identity provider.
+func (r *Router) AuthorizeOIDC(c echo.Context) error {
+	res, err := r.asSvc.AuthorizeOIDC(withClient(c), c.Param("provider"), 0)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+	setBinding(c, res.Binding)
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// LinkOIDC starts linking an identity of an external identity provider to the account of the user.
+func (r *Router) LinkOIDC(c echo.Context) error {
+	res, err := r.asSvc.AuthorizeOIDC(withClient(c), c.Param("provider"), getAuthUserID(c))
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+	setBinding(c, res.Binding)
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// LoginOIDC completes a login with an external identity provider with the code and the state of its callback,
+// in the browser that started it.
+func (r *Router) LoginOIDC(c echo.Context) error {
+	callback := new(auth.OIDCCallback)
+	err := c.Bind(callback)
+	if err != nil {
+		return err
+	}
+	if cookie, err := c.Cookie(bindingCookie); err == nil {
+		callback.Binding = cookie.Value
+	}
+	setBinding(c, "")
+	if claims, ok := requestauth.Claims(c); ok {
+		callback.UserID = claims.UserID
+	}
+
+	res, err := r.asSvc.LoginOIDC(withClient(c), callback)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ChangePassword changes the password of the user.
+func (r *Router) ChangePassword(c echo.Context) error {
+	change := new(auth.PasswordChange)
+	err := c.Bind(change)
+	if err != nil {
+		return err
+	}
+
+	err = r.asSvc.ChangePassword(withClient(c), getAuthUserID(c), change)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ChangeEmail changes the email of the user and sends its verification.
+func (r *Router) ChangeEmail(c echo.Context) error {
+	req := new(auth.EmailRequest)
+	err := c.Bind(req)
+	if err != nil {
+		return err
+	}
+
+	err = r.asSvc.ChangeEmail(withClient(c), getAuthUserID(c), req)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// VerifyEmail verifies the email of the token sent to it.
+func (r *Router) VerifyEmail(c echo.Context) error {
+	verification := new(auth.EmailVerification)
+	err := c.Bind(verification)
+	if err != nil {
+		return err
+	}
+
+	err = r.asSvc.VerifyEmail(withClient(c), verification)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResendVerification sends the verification of the email again, it is accepted whether the email is known or not.
+func (r *Router) ResendVerification(c echo.Context) error {
+	req := new(auth.EmailRequest)
+	err := c.Bind(req)
+	if err != nil {
+		return err
+	}
+
+	err = r.asSvc.ResendVerification(withClient(c), req)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ForgotPassword sends a password reset to the email, it is accepted whether the email is known or not.
+func (r *Router) ForgotPassword(c echo.Context) error {
+	req := new(auth.EmailRequest)
+	err := c.Bind(req)
+	if err != nil {
+		return err
+	}
+
+	err = r.asSvc.ForgotPassword(withClient(c), req)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ResetPassword replaces the password with the token of a password reset.
+func (r *Router) ResetPassword(c echo.Context) error {
+	reset := new(auth.PasswordReset)
+	err := c.Bind(reset)
+	if err != nil {
+		return err
+	}
+
+	err = r.asSvc.ResetPassword(withClient(c), reset)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetEvents gets the auth events of the user, newest first.
+func (r *Router) GetEvents(c echo.Context) error {
+	filter := auth.EventFilter{
+		UserID: getAuthUserID(c),
+		Event:  c.QueryParam("event"),
+	}
+	var err error
+	if before := c.QueryParam("before"); before != "" {
+		if filter.BeforeID, err = strconv.Atoi(before); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "before must be an event id")
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be a number")
+		}
+	}
+
+	events, err := r.asSvc.GetEvents(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+// CreateAccessToken creates a personal access token of the user, its secret is only returned here.
+func (r *Router) CreateAccessToken(c echo.Context) error {
+	req := new(auth.AccessTokenRequest)
+	err := c.Bind(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.asSvc.CreateAccessToken(withClient(c), getAuthUserID(c), req)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, res)
+}
+
+// GetAccessTokens gets the personal access tokens of the user.
+func (r *Router) GetAccessTokens(c echo.Context) error {
+	tokens, err := r.asSvc.GetAccessTokens(c.Request().Context(), getAuthUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeAccessToken revokes a personal access token of the user.
+func (r *Router) RevokeAccessToken(c echo.Context) error {
+	tokenID, err := strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "token_id must be a number")
+	}
+
+	err = r.asSvc.RevokeAccessToken(withClient(c), getAuthUserID(c), tokenID)
+	if err != nil {
+		return toHTTPError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// withClient returns the context of the request carrying its client, for the audit log.
+func withClient(c echo.Context) context.Context {
+	return auth.WithClient(c.Request().Context(), auth.Client{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	})
+}
+
+// toHTTPError maps the auth errors to their status, the other errors are returned as is.
+func toHTTPError(c echo.Context, err error) error {
+	var attemptsErr *auth.AttemptsError
+	switch {
+	case errors.As(err, &attemptsErr):
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(attemptsErr.RetryAfter.Seconds()))))
+		return echo.NewHTTPError(http.StatusTooManyRequests, auth.ErrTooManyAttempts.Error())
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrIdentityRejected):
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	case errors.Is(err, auth.ErrUsernameTaken), errors.Is(err, auth.ErrEmailTaken), errors.Is(err, auth.ErrIdentityLinked),
+		errors.Is(err, auth.ErrProviderLinked), errors.Is(err, auth.ErrTooManyTokens):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, auth.ErrAccountBanned), errors.Is(err, auth.ErrAccountSuspended), errors.Is(err, auth.ErrIdentityNotLinked),
+		errors.Is(err, auth.ErrEmailNotVerified):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, auth.ErrUnknownProvider), errors.Is(err, auth.ErrTokenNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, auth.ErrLoginExpired), errors.Is(err, auth.ErrInvalidEmailToken), errors.Is(err, auth.ErrInvalidEmail),
+		errors.Is(err, auth.ErrEmailRequired), errors.Is(err, auth.ErrInvalidTokenName), errors.Is(err, auth.ErrInvalidScope),
+		errors.Is(err, auth.ErrInvalidExpiry):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return err
+}
+
+// setBinding stores the login binding in an HttpOnly cookie, removing it when binding is empty.
+func setBinding(c echo.Context, binding string) {
+	cookie := &http.Cookie{
+		Name:     bindingCookie,
+		Value:    binding,
+		Path:     bindingCookiePath,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if binding == "" {
+		cookie.MaxAge = -1
+	}
+	c.SetCookie(cookie)
+}
+
+// gets user id from JWT token.
+func getAuthUserID(c echo.Context) int {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(*auth.JwtCustomClaims)
+
+	return claims.UserID
+}
@@ -1,20 +1,58 @@
 package auth_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	authservice "movierama/internal/app/auth"
+	movieservice "movierama/internal/app/movie"
+	"movierama/internal/config"
+	"movierama/internal/infra/http/requestauth"
 	"movierama/internal/infra/http/router/auth"
+	"movierama/internal/infra/http/router/movie"
+	"movierama/internal/infra/repository/memory"
+	"movierama/internal/jwtkeys"
+	"movierama/internal/mail"
+	"movierama/internal/metrics"
+	"movierama/internal/oidc"
+	"movierama/internal/oidc/oidctest"
+	"movierama/internal/ratelimit"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
 )
 
+const jwtSecret = "secret"
+
+func token(t *testing.T, userID int) string {
+	tk := jwt.NewWithClaims(jwt.SigningMethodHS256, &authservice.JwtCustomClaims{UserID: userID})
+	s, err := tk.SignedString([]byte(jwtSecret))
+	require.NoError(t, err)
+
+	return s
+}
+
+func newEcho(mockSvc *authservice.SvcMock) *echo.Echo {
+	e := echo.New()
+	jwtCfg := middleware.JWTConfig{Claims: &authservice.JwtCustomClaims{}, SigningKey: []byte(jwtSecret)}
+	auth.NewRouter(mockSvc, jwtCfg, zap.NewNop()).AppendRoutes(e)
+
+	return e
+}
+
 func TestRouter_Login(t *testing.T) {
 	rec := httptest.NewRecorder()
 
@@ -94,7 +132,7 @@ func TestRouter_Login(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 
-			r := auth.NewRouter(tt.mockSvc)
+			r := auth.NewRouter(tt.mockSvc, middleware.JWTConfig{}, zap.NewNop())
 			err := r.Login(tt.echoCtx)
 
 			if tt.expErr == nil {
@@ -183,7 +221,7 @@ func TestRouter_Register(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 
-			r := auth.NewRouter(tt.mockSvc)
+			r := auth.NewRouter(tt.mockSvc, middleware.JWTConfig{}, zap.NewNop())
 			err := r.Register(tt.echoCtx)
 
 			if tt.expErr == nil {
@@ -195,3 +233,719 @@ func TestRouter_Register(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_RateLimit(t *testing.T) {
+	mockSvc := &authservice.SvcMock{}
+	mockSvc.On("Login", mock.Anything, mock.Anything).Return(&authservice.LoginRes{Username: "u"}, nil).Twice()
+	l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+		ratelimit.GroupLogin: {PerIP: ratelimit.Limit{Requests: 2, Period: time.Minute}},
+	}, nil)
+	require.NoError(t, err)
+
+	e := echo.New()
+	auth.NewRouter(mockSvc, middleware.JWTConfig{SigningKey: []byte(jwtSecret)}, zap.NewNop()).WithLimiter(l).AppendRoutes(e)
+
+	var codes []int
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"username":"u","password":"p"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests {
+			assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+		}
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestRouter_Errors(t *testing.T) {
+	tests := map[string]struct {
+		err           error
+		expCode       int
+		expRetryAfter string
+	}{
+		"should return 401 on invalid credentials": {
+			err:     authservice.ErrInvalidCredentials,
+			expCode: http.StatusUnauthorized,
+		},
+		"should return 429 with Retry-After on too many attempts": {
+			err:           &authservice.AttemptsError{RetryAfter: 1500 * time.Millisecond},
+			expCode:       http.StatusTooManyRequests,
+			expRetryAfter: "2",
+		},
+		"should return 403 on a banned account": {
+			err:     authservice.ErrAccountBanned,
+			expCode: http.StatusForbidden,
+		},
+		"should return 403 on a suspended account": {
+			err:     &authservice.SuspendedError{},
+			expCode: http.StatusForbidden,
+		},
+		"should return 403 on an unverified email": {
+			err:     authservice.ErrEmailNotVerified,
+			expCode: http.StatusForbidden,
+		},
+		"should return other errors as is": {
+			err:     errors.New("random error"),
+			expCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			mockSvc.On("Login", mock.Anything, mock.Anything).Return(&authservice.LoginRes{}, tt.err)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"username":"u","password":"p"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code)
+			assert.Equal(t, tt.expRetryAfter, rec.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestRouter_ChangePassword(t *testing.T) {
+	change := &authservice.PasswordChange{CurrentPassword: "old", NewPassword: "new"}
+	tests := map[string]struct {
+		token   bool
+		err     error
+		expCode int
+	}{
+		"should change the password": {
+			token:   true,
+			expCode: http.StatusNoContent,
+		},
+		"should return 401 on a wrong current password": {
+			token:   true,
+			err:     authservice.ErrInvalidCredentials,
+			expCode: http.StatusUnauthorized,
+		},
+		"should require a token": {
+			expCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			mockSvc.On("ChangePassword", mock.MatchedBy(func(ctx context.Context) bool {
+				return authservice.ClientFrom(ctx) == authservice.Client{IP: "192.0.2.1", UserAgent: "test-agent"}
+			}), 7, change).Return(tt.err)
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/auth/password", strings.NewReader(`{"current_password":"old","new_password":"new"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("User-Agent", "test-agent")
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.token {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+token(t, 7))
+			}
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code)
+			if tt.token {
+				mockSvc.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestRouter_Email(t *testing.T) {
+	tests := map[string]struct {
+		method  string
+		path    string
+		body    string
+		token   bool
+		call    string
+		args    []interface{}
+		err     error
+		expCode int
+	}{
+		"should verify the email": {
+			path:    "/api/v1/auth/verify-email",
+			body:    `{"token":"tk"}`,
+			call:    "VerifyEmail",
+			args:    []interface{}{&authservice.EmailVerification{Token: "tk"}},
+			expCode: http.StatusNoContent,
+		},
+		"should return 400 on an invalid token": {
+			path:    "/api/v1/auth/verify-email",
+			body:    `{"token":"tk"}`,
+			call:    "VerifyEmail",
+			args:    []interface{}{&authservice.EmailVerification{Token: "tk"}},
+			err:     authservice.ErrInvalidEmailToken,
+			expCode: http.StatusBadRequest,
+		},
+		"should accept a verification to send again": {
+			path:    "/api/v1/auth/verify-email/resend",
+			body:    `{"email":"alice@example.com"}`,
+			call:    "ResendVerification",
+			args:    []interface{}{&authservice.EmailRequest{Email: "alice@example.com"}},
+			expCode: http.StatusAccepted,
+		},
+		"should accept a forgotten password": {
+			path:    "/api/v1/auth/forgot-password",
+			body:    `{"email":"alice@example.com"}`,
+			call:    "ForgotPassword",
+			args:    []interface{}{&authservice.EmailRequest{Email: "alice@example.com"}},
+			expCode: http.StatusAccepted,
+		},
+		"should reset the password": {
+			path:    "/api/v1/auth/reset-password",
+			body:    `{"token":"tk","new_password":"new"}`,
+			call:    "ResetPassword",
+			args:    []interface{}{&authservice.PasswordReset{Token: "tk", NewPassword: "new"}},
+			expCode: http.StatusNoContent,
+		},
+		"should change the email": {
+			method:  http.MethodPut,
+			path:    "/api/v1/auth/email",
+			body:    `{"email":"alice@example.com"}`,
+			token:   true,
+			call:    "ChangeEmail",
+			args:    []interface{}{7, &authservice.EmailRequest{Email: "alice@example.com"}},
+			expCode: http.StatusNoContent,
+		},
+		"should return 409 on a taken email": {
+			method:  http.MethodPut,
+			path:    "/api/v1/auth/email",
+			body:    `{"email":"alice@example.com"}`,
+			token:   true,
+			call:    "ChangeEmail",
+			args:    []interface{}{7, &authservice.EmailRequest{Email: "alice@example.com"}},
+			err:     authservice.ErrEmailTaken,
+			expCode: http.StatusConflict,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			mockSvc.On(tt.call, append([]interface{}{mock.Anything}, tt.args...)...).Return(tt.err)
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			req := httptest.NewRequest(method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.token {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+token(t, 7))
+			}
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code)
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_PasswordResetEndToEnd(t *testing.T) {
+	capture := mail.NewCapture()
+	cfg := &config.Config{
+		App: config.App{JWTSecret: jwtSecret},
+		Email: config.Email{
+			VerifyURL:       "http://localhost:4200/verify-email",
+			ResetURL:        "http://localhost:4200/reset-password",
+			VerifyTTL:       time.Hour,
+			ResetTTL:        time.Hour,
+			RequireVerified: true,
+		},
+	}
+	svc := authservice.NewService(memory.NewRepository(), nil, nil, capture, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+	e := echo.New()
+	auth.NewRouter(svc, requestauth.JWTConfig(jwtkeys.NewHMAC(jwtSecret), svc), zap.NewNop()).AppendRoutes(e)
+
+	serve := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+	// link returns the token of the link of the last email sent to alice.
+	link := func() string {
+		msg, ok := capture.Last("alice@example.com")
+		require.True(t, ok)
+		i := strings.Index(msg.Body, "http://localhost:4200/")
+		require.GreaterOrEqual(t, i, 0)
+		u, err := url.Parse(strings.Fields(msg.Body[i:])[0])
+		require.NoError(t, err)
+
+		return u.Query().Get("token")
+	}
+
+	rec := serve("/api/v1/auth/register", `{"username":"alice","password":"old_pass","first_name":"Alice","last_name":"Doe","email":"alice@example.com"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = serve("/api/v1/auth/login", `{"username":"alice","password":"old_pass"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serve("/api/v1/auth/forgot-password", `{"email":"alice@example.com"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	rec = serve("/api/v1/auth/forgot-password", `{"email":"unknown@example.com"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	body, err := json.Marshal(authservice.PasswordReset{Token: link(), NewPassword: "new_pass"})
+	require.NoError(t, err)
+	rec = serve("/api/v1/auth/reset-password", string(body))
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	rec = serve("/api/v1/auth/reset-password", string(body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "a token is used once")
+
+	rec = serve("/api/v1/auth/login", `{"username":"alice","password":"new_pass"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestRouter_GetEvents(t *testing.T) {
+	tests := map[string]struct {
+		query     string
+		expFilter authservice.EventFilter
+		expCode   int
+	}{
+		"should return the events of the user": {
+			expFilter: authservice.EventFilter{UserID: 7},
+			expCode:   http.StatusOK,
+		},
+		"should page through the events of the user": {
+			query:     "?event=login_failed&before=10&limit=5",
+			expFilter: authservice.EventFilter{UserID: 7, Event: "login_failed", BeforeID: 10, Limit: 5},
+			expCode:   http.StatusOK,
+		},
+		"should reject an invalid limit": {
+			query:   "?limit=many",
+			expCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			events := []authservice.Event{{ID: 3, UserID: 7, Username: "u", Event: authservice.EventLoginFailed}}
+			mockSvc.On("GetEvents", mock.Anything, tt.expFilter).Return(events, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/events"+tt.query, nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token(t, 7))
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code)
+			if tt.expCode == http.StatusOK {
+				var got []authservice.Event
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+				assert.Equal(t, events, got)
+			}
+		})
+	}
+}
+
+func TestRouter_GetProviders(t *testing.T) {
+	tests := map[string]struct {
+		providers []string
+		expBody   string
+	}{
+		"should return the providers": {
+			providers: []string{"github", "google"},
+			expBody:   `["github","google"]`,
+		},
+		"should return an empty list without providers": {
+			providers: nil,
+			expBody:   `[]`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			mockSvc.On("Providers", mock.Anything).Return(tt.providers)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/providers", nil)
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, tt.expBody, rec.Body.String())
+		})
+	}
+}
+
+func TestRouter_AuthorizeOIDC(t *testing.T) {
+	tests := map[string]struct {
+		method    string
+		path      string
+		token     bool
+		expUserID int
+		err       error
+		expCode   int
+	}{
+		"should start a login": {
+			method:  http.MethodGet,
+			path:    "/api/v1/auth/oidc/github/authorize",
+			expCode: http.StatusOK,
+		},
+		"should start linking an identity to the account of the user": {
+			method:    http.MethodPost,
+			path:      "/api/v1/auth/oidc/github/link",
+			token:     true,
+			expUserID: 7,
+			expCode:   http.StatusOK,
+		},
+		"should require a token to link an identity": {
+			method:  http.MethodPost,
+			path:    "/api/v1/auth/oidc/github/link",
+			expCode: http.StatusBadRequest,
+		},
+		"should return 404 on an unknown provider": {
+			method:  http.MethodGet,
+			path:    "/api/v1/auth/oidc/github/authorize",
+			err:     authservice.ErrUnknownProvider,
+			expCode: http.StatusNotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			mockSvc.On("AuthorizeOIDC", mock.Anything, "github", tt.expUserID).
+				Return(&authservice.AuthorizationRes{URL: "https://github.com/login/oauth/authorize?state=s", Binding: "secret"}, tt.err)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+token(t, 7))
+			}
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code)
+			if tt.expCode == http.StatusOK {
+				assert.JSONEq(t, `{"authorization_url":"https://github.com/login/oauth/authorize?state=s"}`, rec.Body.String())
+				mockSvc.AssertExpectations(t)
+				// The binding is kept out of reach of the scripts.
+				cookies := rec.Result().Cookies()
+				if assert.Len(t, cookies, 1) {
+					assert.Equal(t, "movierama_oidc", cookies[0].Name)
+					assert.Equal(t, "secret", cookies[0].Value)
+					assert.Equal(t, "/api/v1/auth/oidc", cookies[0].Path)
+					assert.True(t, cookies[0].HttpOnly)
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_LoginOIDC(t *testing.T) {
+	tests := map[string]struct {
+		token     bool
+		expUserID int
+		err       error
+		expCode   int
+	}{
+		"should log the user in": {
+			expCode: http.StatusOK,
+		},
+		"should complete the login with the user of the token": {
+			token:     true,
+			expUserID: 7,
+			expCode:   http.StatusOK,
+		},
+		"should return 400 on an expired login": {
+			err:     authservice.ErrLoginExpired,
+			expCode: http.StatusBadRequest,
+		},
+		"should return 401 on a rejected identity": {
+			err:     authservice.ErrIdentityRejected,
+			expCode: http.StatusUnauthorized,
+		},
+		"should return 403 on an identity not linked": {
+			err:     authservice.ErrIdentityNotLinked,
+			expCode: http.StatusForbidden,
+		},
+		"should return 409 on an identity linked to another account": {
+			err:     authservice.ErrIdentityLinked,
+			expCode: http.StatusConflict,
+		},
+		"should return 409 on a provider already linked": {
+			err:     authservice.ErrProviderLinked,
+			expCode: http.StatusConflict,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			callback := &authservice.OIDCCallback{Code: "code", State: "state", Binding: "secret", UserID: tt.expUserID}
+			mockSvc.On("LoginOIDC", mock.Anything, callback).Return(&authservice.LoginRes{Username: "u", Token: "t"}, tt.err)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/oidc/callback", strings.NewReader(`{"code":"code","state":"state"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.AddCookie(&http.Cookie{Name: "movierama_oidc", Value: "secret"})
+			if tt.token {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+token(t, 7))
+			}
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code)
+			mockSvc.AssertExpectations(t)
+			// The binding is used once.
+			cookies := rec.Result().Cookies()
+			if assert.Len(t, cookies, 1) {
+				assert.Equal(t, "movierama_oidc", cookies[0].Name)
+				assert.Negative(t, cookies[0].MaxAge)
+			}
+			if tt.expCode == http.StatusOK {
+				assert.JSONEq(t, `{"username":"u","token":"t"}`, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouter_OIDCEndToEnd(t *testing.T) {
+	srv := oidctest.NewServer(t)
+	redirect := "http://localhost:3000/auth/callback"
+	flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(srv.OIDC("test", redirect)), oidc.New(srv.OAuth2("github", redirect)))
+	cfg := &config.Config{App: config.App{JWTSecret: jwtSecret}, OIDC: config.OIDC{AutoCreate: true}}
+	svc := authservice.NewService(memory.NewRepository(), nil, flow, nil, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+	e := echo.New()
+	auth.NewRouter(svc, requestauth.JWTConfig(jwtkeys.NewHMAC(jwtSecret), svc), zap.NewNop()).AppendRoutes(e)
+
+	serve := func(method, path, body, tk string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if tk != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tk)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+	// begin starts the flow at path and returns the code and the state of the callback of u, along with the
+	// cookie of the browser that started it.
+	begin := func(path, tk string, u oidctest.User) (code, state string, cookie *http.Cookie) {
+		method := http.MethodGet
+		if tk != "" {
+			method = http.MethodPost
+		}
+		rec := serve(method, path, "", tk)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		authz := authservice.AuthorizationRes{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &authz))
+		require.Len(t, rec.Result().Cookies(), 1)
+		code, state = srv.Authorize(t, authz.URL, u)
+
+		return code, state, rec.Result().Cookies()[0]
+	}
+	complete := func(code, state, tk string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		body, err := json.Marshal(authservice.OIDCCallback{Code: code, State: state})
+		require.NoError(t, err)
+
+		return serve(http.MethodPost, "/api/v1/auth/oidc/callback", string(body), tk, cookies...)
+	}
+	// login runs the flow started at path in the browser of u and returns the login of the callback.
+	login := func(path, tk string, u oidctest.User) authservice.LoginRes {
+		code, state, cookie := begin(path, tk, u)
+		rec := complete(code, state, tk, cookie)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		res := authservice.LoginRes{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+		return res
+	}
+
+	rec := serve(http.MethodGet, "/api/v1/auth/oidc/providers", "", "")
+	assert.JSONEq(t, `["github","test"]`, rec.Body.String())
+
+	res := login("/api/v1/auth/oidc/test/authorize", "", oidctest.User{Subject: "s-1", Username: "alice", Email: "alice@example.com"})
+	assert.Equal(t, "alice", res.Username)
+
+	rec = serve(http.MethodGet, "/api/v1/auth/events", "", res.Token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"event":"registered"`)
+
+	linked := login("/api/v1/auth/oidc/github/link", res.Token, oidctest.User{Subject: "42", Username: "alice-gh"})
+	assert.Equal(t, "alice", linked.Username)
+
+	again := login("/api/v1/auth/oidc/github/authorize", "", oidctest.User{Subject: "42", Username: "alice-gh"})
+	assert.Equal(t, "alice", again.Username)
+
+	rec = serve(http.MethodGet, "/api/v1/auth/events?event=identity_linked", "", again.Token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"event":"identity_linked"`)
+
+	// A flow started by an attacker and completed in the browser of another user is refused.
+	bob := login("/api/v1/auth/oidc/test/authorize", "", oidctest.User{Subject: "s-2", Username: "bob"})
+	code, state, _ := begin("/api/v1/auth/oidc/test/authorize", "", oidctest.User{Subject: "s-2", Username: "bob"})
+	rec = complete(code, state, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	// A link is completed by the user who started it.
+	code, state, cookie := begin("/api/v1/auth/oidc/github/link", bob.Token, oidctest.User{Subject: "43", Username: "bob-gh"})
+	rec = complete(code, state, "", cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	code, state, cookie = begin("/api/v1/auth/oidc/github/link", bob.Token, oidctest.User{Subject: "43", Username: "bob-gh"})
+	rec = complete(code, state, res.Token, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+func TestRouter_AccessTokens(t *testing.T) {
+	created := &authservice.NewAccessToken{
+		AccessToken: authservice.AccessToken{ID: 3, Name: "bot", Scopes: []authservice.Scope{authservice.ScopeMoviesWrite}},
+		Token:       "mrpat_secret",
+	}
+
+	tests := map[string]struct {
+		method  string
+		path    string
+		body    string
+		call    string
+		args    []interface{}
+		res     interface{}
+		err     error
+		expCode int
+	}{
+		"should create a token": {
+			method:  http.MethodPost,
+			path:    "/api/v1/auth/tokens",
+			body:    `{"name":"bot","scopes":["movies:write"]}`,
+			call:    "CreateAccessToken",
+			args:    []interface{}{7, &authservice.AccessTokenRequest{Name: "bot", Scopes: []authservice.Scope{authservice.ScopeMoviesWrite}}},
+			res:     created,
+			expCode: http.StatusCreated,
+		},
+		"should return 400 on an invalid scope": {
+			method:  http.MethodPost,
+			path:    "/api/v1/auth/tokens",
+			body:    `{"name":"bot","scopes":["admin"]}`,
+			call:    "CreateAccessToken",
+			args:    []interface{}{7, &authservice.AccessTokenRequest{Name: "bot", Scopes: []authservice.Scope{"admin"}}},
+			res:     (*authservice.NewAccessToken)(nil),
+			err:     authservice.ErrInvalidScope,
+			expCode: http.StatusBadRequest,
+		},
+		"should return 409 on too many tokens": {
+			method:  http.MethodPost,
+			path:    "/api/v1/auth/tokens",
+			body:    `{"name":"bot","scopes":["movies:write"]}`,
+			call:    "CreateAccessToken",
+			args:    []interface{}{7, &authservice.AccessTokenRequest{Name: "bot", Scopes: []authservice.Scope{authservice.ScopeMoviesWrite}}},
+			res:     (*authservice.NewAccessToken)(nil),
+			err:     authservice.ErrTooManyTokens,
+			expCode: http.StatusConflict,
+		},
+		"should list the tokens": {
+			method:  http.MethodGet,
+			path:    "/api/v1/auth/tokens",
+			call:    "GetAccessTokens",
+			args:    []interface{}{7},
+			res:     []authservice.AccessToken{created.AccessToken},
+			expCode: http.StatusOK,
+		},
+		"should revoke a token": {
+			method:  http.MethodDelete,
+			path:    "/api/v1/auth/tokens/3",
+			call:    "RevokeAccessToken",
+			args:    []interface{}{7, 3},
+			expCode: http.StatusNoContent,
+		},
+		"should return 404 on an unknown token": {
+			method:  http.MethodDelete,
+			path:    "/api/v1/auth/tokens/4",
+			call:    "RevokeAccessToken",
+			args:    []interface{}{7, 4},
+			err:     authservice.ErrTokenNotFound,
+			expCode: http.StatusNotFound,
+		},
+		"should return 400 on an invalid token id": {
+			method:  http.MethodDelete,
+			path:    "/api/v1/auth/tokens/bot",
+			expCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := &authservice.SvcMock{}
+			if tt.call != "" {
+				ret := []interface{}{tt.err}
+				if tt.res != nil {
+					ret = []interface{}{tt.res, tt.err}
+				}
+				mockSvc.On(tt.call, append([]interface{}{mock.Anything}, tt.args...)...).Return(ret...)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token(t, 7))
+			rec := httptest.NewRecorder()
+			newEcho(mockSvc).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code, rec.Body.String())
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_AccessTokenEndToEnd(t *testing.T) {
+	repo := memory.NewRepository()
+	cfg := &config.Config{App: config.App{JWTSecret: jwtSecret}}
+	svc := authservice.NewService(repo, nil, nil, nil, nil, cfg, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+	movies := movieservice.NewService(repo, repo, nil, zap.NewNop(), metrics.New(prometheus.NewRegistry()))
+	jwtCfg := requestauth.JWTConfig(jwtkeys.NewHMAC(jwtSecret), svc)
+	e := echo.New()
+	auth.NewRouter(svc, jwtCfg, zap.NewNop()).AppendRoutes(e)
+	movie.NewRouter(movies, jwtCfg, zap.NewNop()).AppendRoutes(e)
+
+	serve := func(method, path, body, tk string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if tk != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tk)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/api/v1/auth/register", `{"username":"alice","password":"secret_pass","first_name":"Alice","last_name":"Doe"}`, "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = serve(http.MethodPost, "/api/v1/auth/login", `{"username":"alice","password":"secret_pass"}`, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	login := authservice.LoginRes{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+
+	rec = serve(http.MethodPost, "/api/v1/auth/tokens", `{"name":"reader","scopes":["movies:read"]}`, login.Token)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	pat := authservice.NewAccessToken{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pat))
+	require.True(t, authservice.IsAccessToken(pat.Token))
+
+	rec = serve(http.MethodGet, "/api/v1/movies", "", pat.Token)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = serve(http.MethodPost, "/api/v1/movies", `{"title":"Heat","description":"A heist."}`, pat.Token)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the token lacks movies:write")
+	assert.Contains(t, rec.Body.String(), "movies:write")
+	rec = serve(http.MethodGet, "/api/v1/auth/tokens", "", pat.Token)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "a token does not manage the tokens")
+
+	rec = serve(http.MethodGet, "/api/v1/auth/tokens", "", login.Token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), pat.Token)
+	assert.Contains(t, rec.Body.String(), `"last_used_at":"`)
+
+	rec = serve(http.MethodDelete, fmt.Sprintf("/api/v1/auth/tokens/%d", pat.ID), "", login.Token)
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	rec = serve(http.MethodGet, "/api/v1/movies", "", pat.Token)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "a revoked token stops working")
+}
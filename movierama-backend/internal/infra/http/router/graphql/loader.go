@@ -0,0 +1,54 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"movierama/internal/app/auth"
+
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// errUserNotFound is returned by the user loader for missing users.
+var errUserNotFound = errors.New("user not found")
+
+type loaderContextKey struct{}
+
+// withUserLoader returns a context carrying a request scoped user loader,
+// the loader batches the user lookups of a query into a single GetUsers call.
+func withUserLoader(ctx context.Context, asSvc auth.Service) context.Context {
+	loader := dataloader.NewBatchedLoader(func(ctx context.Context, userIDs []int) []*dataloader.Result[*auth.User] {
+		results := make([]*dataloader.Result[*auth.User], len(userIDs))
+
+		users, err := asSvc.GetUsers(ctx, userIDs)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*auth.User]{Error: err}
+			}
+
+			return results
+		}
+
+		byID := make(map[int]*auth.User, len(users))
+		for i := range users {
+			byID[users[i].ID] = &users[i]
+		}
+		for i, id := range userIDs {
+			if u, ok := byID[id]; ok {
+				results[i] = &dataloader.Result[*auth.User]{Data: u}
+			} else {
+				results[i] = &dataloader.Result[*auth.User]{Error: errUserNotFound}
+			}
+		}
+
+		return results
+	}, dataloader.WithClearCacheOnBatch[int, *auth.User]())
+
+	return context.WithValue(ctx, loaderContextKey{}, loader)
+}
+
+// loadUser loads a user through the request scoped loader.
+func loadUser(ctx context.Context, userID int) (*auth.User, error) {
+	loader := ctx.Value(loaderContextKey{}).(*dataloader.Loader[int, *auth.User])
+
+	return loader.Load(ctx, userID)()
+}
@@ -0,0 +1,291 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"movierama/internal/app/auth"
+	"movierama/internal/app/movie"
+	"movierama/internal/logging"
+	"movierama/internal/ratelimit"
+	"strconv"
+	"strings"
+	"time"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+// errUnauthenticated is returned by the fields that require an auth user.
+var errUnauthenticated = errors.New("authentication required")
+
+// duplicateError returns the likely duplicates of a new movie in the extensions of the error.
+type duplicateError struct {
+	*movie.DuplicateError
+}
+
+// Extensions implements the extensions of the graphql errors.
+func (e duplicateError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"duplicates": e.Movies}
+}
+
+// clientIPContextKey is the context key of the client IP.
+type clientIPContextKey struct{}
+
+type resolver struct {
+	msSvc   movie.Service
+	asSvc   auth.Service
+	limiter *ratelimit.Limiter
+	log     *zap.Logger
+}
+
+// limit takes a token of the route group for the client IP and the auth user.
+func (r *resolver) limit(ctx context.Context, group string) error {
+	ip, _ := ctx.Value(clientIPContextKey{}).(string)
+	res, err := r.limiter.Allow(ctx, group, ip, authUserID(ctx))
+	if err != nil {
+		logging.For(ctx, r.log).Error("rate limit", zap.String("group", group), zap.Error(err))
+	}
+	if !res.Allowed {
+		return ratelimit.ErrLimited
+	}
+
+	return nil
+}
+
+// authUserID returns the auth user id from context, zero for anonymous requests.
+func authUserID(ctx context.Context) int {
+	id, _ := ctx.Value(movie.AuthUserIDContextKey).(int)
+
+	return id
+}
+
+func parseID(id graphqlgo.ID) (int, error) {
+	i, err := strconv.Atoi(string(id))
+	if err != nil {
+		return 0, errors.New("invalid id " + string(id))
+	}
+
+	return i, nil
+}
+
+func sortType(sort string) string {
+	return strings.ToLower(sort)
+}
+
+func (r *resolver) movieList(ctx context.Context, userID int, sort string) ([]*movieResolver, error) {
+	var (
+		res *movie.GetmoviesRes
+		err error
+	)
+	switch {
+	case userID != 0 && authUserID(ctx) != 0:
+		res, err = r.msSvc.GetUserMovies(ctx, userID, sortType(sort))
+	case userID != 0:
+		res, err = r.msSvc.GetUserMoviesPublic(ctx, userID, sortType(sort))
+	case authUserID(ctx) != 0:
+		res, err = r.msSvc.GetMovies(ctx, sortType(sort))
+	default:
+		res, err = r.msSvc.GetMoviesPublic(ctx, sortType(sort))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	movies := make([]*movieResolver, 0, len(res.Movies))
+	for _, m := range res.Movies {
+		movies = append(movies, &movieResolver{r: r, m: m})
+	}
+
+	return movies, nil
+}
+
+func (r *resolver) Movies(ctx context.Context, args struct {
+	Sort   string
+	UserID *graphqlgo.ID
+}) ([]*movieResolver, error) {
+	var userID int
+	if args.UserID != nil {
+		id, err := parseID(*args.UserID)
+		if err != nil {
+			return nil, err
+		}
+		userID = id
+	}
+
+	return r.movieList(ctx, userID, args.Sort)
+}
+
+func (r *resolver) Movie(ctx context.Context, args struct{ ID graphqlgo.ID }) (*movieResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := r.msSvc.GetMovie(ctx, id)
+	if errors.Is(err, movie.ErrMovieNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &movieResolver{r: r, m: *m}, nil
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID graphqlgo.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := loadUser(ctx, id)
+	if errors.Is(err, errUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &userResolver{r: r, u: *u}, nil
+}
+
+func (r *resolver) Viewer(ctx context.Context) (*userResolver, error) {
+	id := authUserID(ctx)
+	if id == 0 {
+		return nil, nil
+	}
+
+	return r.User(ctx, struct{ ID graphqlgo.ID }{ID: graphqlgo.ID(strconv.Itoa(id))})
+}
+
+func (r *resolver) CreateMovie(ctx context.Context, args struct{ Input movie.NewMovie }) (bool, error) {
+	if authUserID(ctx) == 0 {
+		return false, errUnauthenticated
+	}
+	if err := r.limit(ctx, ratelimit.GroupMovies); err != nil {
+		return false, err
+	}
+
+	if err := r.msSvc.CreateMovie(ctx, args.Input); err != nil {
+		var dupErr *movie.DuplicateError
+		if errors.As(err, &dupErr) {
+			return false, duplicateError{dupErr}
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *resolver) Vote(ctx context.Context, args struct {
+	MovieID graphqlgo.ID
+	Vote    string
+}) (*movieResolver, error) {
+	if authUserID(ctx) == 0 {
+		return nil, errUnauthenticated
+	}
+	if err := r.limit(ctx, ratelimit.GroupVotes); err != nil {
+		return nil, err
+	}
+	id, err := parseID(args.MovieID)
+	if err != nil {
+		return nil, err
+	}
+
+	var action string
+	if args.Vote != "NONE" {
+		action = strings.ToLower(args.Vote)
+	}
+	if err = r.msSvc.Vote(ctx, id, action); err != nil {
+		return nil, err
+	}
+
+	m, err := r.msSvc.GetMovie(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &movieResolver{r: r, m: *m}, nil
+}
+
+type movieResolver struct {
+	r *resolver
+	m movie.Movie
+}
+
+func (mr *movieResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(strconv.Itoa(mr.m.ID))
+}
+
+func (mr *movieResolver) Title() string {
+	return mr.m.Title
+}
+
+func (mr *movieResolver) Description() string {
+	return mr.m.Description
+}
+
+func (mr *movieResolver) Likes() int32 {
+	return int32(mr.m.Likes)
+}
+
+func (mr *movieResolver) Hates() int32 {
+	return int32(mr.m.Hates)
+}
+
+func (mr *movieResolver) TimeAgo() string {
+	return mr.m.TimeAgo
+}
+
+func (mr *movieResolver) CreatedAt() string {
+	return mr.m.CreatedAt.Format(time.RFC3339)
+}
+
+func (mr *movieResolver) Submitter(ctx context.Context) (*userResolver, error) {
+	u, err := loadUser(ctx, mr.m.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userResolver{r: mr.r, u: *u}, nil
+}
+
+func (mr *movieResolver) ViewerVote() string {
+	switch {
+	case mr.m.UserLiked:
+		return "LIKE"
+	case mr.m.UserHated:
+		return "HATE"
+	default:
+		return "NONE"
+	}
+}
+
+func (mr *movieResolver) SubmittedByViewer() bool {
+	return mr.m.IsSameUser
+}
+
+type userResolver struct {
+	r *resolver
+	u auth.User
+}
+
+func (ur *userResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(strconv.Itoa(ur.u.ID))
+}
+
+func (ur *userResolver) FirstName() string {
+	return ur.u.FirstName
+}
+
+func (ur *userResolver) LastName() string {
+	return ur.u.LastName
+}
+
+func (ur *userResolver) Name() string {
+	return strings.TrimSpace(ur.u.FirstName + " " + ur.u.LastName)
+}
+
+func (ur *userResolver) Movies(ctx context.Context, args struct{ Sort string }) ([]*movieResolver, error) {
+	return ur.r.movieList(ctx, ur.u.ID, args.Sort)
+}
@@ -0,0 +1,106 @@
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/app/auth"
+	"movierama/internal/app/movie"
+	"movierama/internal/infra/http/requestauth"
+	"movierama/internal/locale"
+	"movierama/internal/logging"
+	"movierama/internal/ratelimit"
+	"net/http"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// Router infrastructure definition.
+type Router struct {
+	asSvc    auth.Service
+	resolver *resolver
+	schema   *graphqlgo.Schema
+	jwtCfg   middleware.JWTConfig
+	log      *zap.Logger
+}
+
+// NewRouter returns an HTTP component to serve the GraphQL endpoint.
+func NewRouter(msSvc movie.Service, asSvc auth.Service, jwtCfg middleware.JWTConfig, logger *zap.Logger) *Router {
+	res := &resolver{msSvc: msSvc, asSvc: asSvc, log: logger}
+
+	return &Router{
+		asSvc:    asSvc,
+		resolver: res,
+		schema:   graphqlgo.MustParseSchema(schema, res),
+		jwtCfg:   jwtCfg,
+		log:      logger,
+	}
+}
+
+// WithLimiter rate limits the createMovie and vote mutations with l, like their REST routes.
+func (r *Router) WithLimiter(l *ratelimit.Limiter) *Router {
+	r.resolver.limiter = l
+
+	return r
+}
+
+// AppendRoutes adds the GraphQL route to router.
+func (r *Router) AppendRoutes(e *echo.Echo) {
+	// The JWT is optional, anonymous requests get the public view of the data.
+	e.POST("/graphql", r.Query, middleware.JWTWithConfig(requestauth.Optional(r.jwtCfg)))
+}
+
+// Request contains the GraphQL request payload.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Query executes a GraphQL request.
+func (r *Router) Query(c echo.Context) error {
+	req := new(Request)
+	if err := json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid GraphQL request").SetInternal(err)
+	}
+
+	ctx := c.Request().Context()
+	if userID, ok := getAuthUserID(c); ok {
+		ctx = context.WithValue(ctx, movie.AuthUserIDContextKey, userID)
+	}
+	ctx = withUserLoader(ctx, r.asSvc)
+	ctx = context.WithValue(ctx, clientIPContextKey{}, c.RealIP())
+	log := logging.For(ctx, r.log)
+	log.Debug("graphql request", zap.String("operation_name", req.OperationName),
+		logging.Payload("variables", req.Variables))
+
+	res := r.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	l := locale.FromContext(ctx)
+	for _, err := range res.Errors {
+		log.Info("graphql error", zap.String("operation_name", req.OperationName), zap.Error(err))
+		err.Message = l.Translate(err.Message)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// gets user id from JWT token if present.
+func getAuthUserID(c echo.Context) (int, bool) {
+	user, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return 0, false
+	}
+	claims, ok := user.Claims.(*auth.JwtCustomClaims)
+	if !ok {
+		return 0, false
+	}
+
+	return claims.UserID, true
+}
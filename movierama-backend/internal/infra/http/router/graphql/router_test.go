@@ -0,0 +1,238 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	authservice "movierama/internal/app/auth"
+	movieservice "movierama/internal/app/movie"
+	"movierama/internal/infra/http/requestlocale"
+	"movierama/internal/infra/http/router/graphql"
+	"movierama/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const jwtSecret = "secret"
+
+func token(t *testing.T, userID int) string {
+	tk := jwt.NewWithClaims(jwt.SigningMethodHS256, &authservice.JwtCustomClaims{UserID: userID})
+	s, err := tk.SignedString([]byte(jwtSecret))
+	require.NoError(t, err)
+
+	return s
+}
+
+func userIDs(ids ...int) interface{} {
+	return mock.MatchedBy(func(got []int) bool {
+		sorted := append([]int(nil), got...)
+		sort.Ints(sorted)
+
+		return assert.ObjectsAreEqual(ids, sorted)
+	})
+}
+
+func TestRouter_Query(t *testing.T) {
+	movies := &movieservice.GetmoviesRes{
+		Movies: []movieservice.Movie{
+			{ID: 1, Title: "movie 1", UserID: 2, Likes: 3, UserLiked: true},
+			{ID: 2, Title: "movie 2", UserID: 5, Hates: 1},
+			{ID: 3, Title: "movie 3", UserID: 2, IsSameUser: true},
+		},
+	}
+	users := []authservice.User{
+		{ID: 2, FirstName: "first", LastName: "last"},
+		{ID: 5, FirstName: "other", LastName: "user"},
+	}
+
+	tests := map[string]struct {
+		movieSvc *movieservice.SvcMock
+		authSvc  *authservice.SvcMock
+		token    string
+		limiter  *ratelimit.Limiter
+		lang     string
+		query    string
+		expCode  int
+		expRes   string
+	}{
+		"Should batch the submitters of public movies": {
+			movieSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("GetMoviesPublic", mock.Anything, "likes").Return(movies, nil)
+
+				return m
+			}(),
+			authSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("GetUsers", mock.Anything, userIDs(2, 5)).Return(users, nil).Once()
+
+				return m
+			}(),
+			query:   `{ movies(sort: LIKES) { id title submitter { name } viewerVote } }`,
+			expCode: http.StatusOK,
+			expRes: `{"data":{"movies":[` +
+				`{"id":"1","title":"movie 1","submitter":{"name":"first last"},"viewerVote":"LIKE"},` +
+				`{"id":"2","title":"movie 2","submitter":{"name":"other user"},"viewerVote":"NONE"},` +
+				`{"id":"3","title":"movie 3","submitter":{"name":"first last"},"viewerVote":"NONE"}]}}`,
+		},
+		"Should resolve the viewer and their movies with auth": {
+			movieSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				ctx := mock.MatchedBy(func(ctx context.Context) bool {
+					return ctx.Value(movieservice.AuthUserIDContextKey) == 2
+				})
+				m.On("GetUserMovies", ctx, 2, "date").Return(&movieservice.GetmoviesRes{
+					Movies: movies.Movies[2:],
+				}, nil)
+
+				return m
+			}(),
+			authSvc: func() *authservice.SvcMock {
+				m := &authservice.SvcMock{}
+				m.On("GetUsers", mock.Anything, []int{2}).Return(users[:1], nil).Once()
+
+				return m
+			}(),
+			token:   "Bearer " + token(t, 2),
+			query:   `{ viewer { id name movies { id submittedByViewer } } }`,
+			expCode: http.StatusOK,
+			expRes:  `{"data":{"viewer":{"id":"2","name":"first last","movies":[{"id":"3","submittedByViewer":true}]}}}`,
+		},
+		"Should return null viewer without auth": {
+			movieSvc: &movieservice.SvcMock{},
+			authSvc:  &authservice.SvcMock{},
+			query:    `{ viewer { id } }`,
+			expCode:  http.StatusOK,
+			expRes:   `{"data":{"viewer":null}}`,
+		},
+		"Should vote with auth": {
+			movieSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("Vote", mock.Anything, 1, "hate").Return(nil)
+				m.On("GetMovie", mock.Anything, 1).Return(&movieservice.Movie{ID: 1, Hates: 1, UserHated: true,
+					CreatedAt: time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)}, nil)
+
+				return m
+			}(),
+			authSvc: &authservice.SvcMock{},
+			token:   "Bearer " + token(t, 2),
+			query:   `mutation { vote(movieId: "1", vote: HATE) { id hates viewerVote createdAt } }`,
+			expCode: http.StatusOK,
+			expRes:  `{"data":{"vote":{"id":"1","hates":1,"viewerVote":"HATE","createdAt":"2026-10-01T12:00:00Z"}}}`,
+		},
+		"Should return the likely duplicates of a new movie": {
+			movieSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("CreateMovie", mock.Anything, movieservice.NewMovie{Title: "Matrix, The", Description: "desc"}).
+					Return(&movieservice.DuplicateError{Movies: []movieservice.Duplicate{{ID: 1, Title: "The Matrix"}}})
+
+				return m
+			}(),
+			authSvc: &authservice.SvcMock{},
+			token:   "Bearer " + token(t, 2),
+			query:   `mutation { createMovie(input: {title: "Matrix, The", description: "desc"}) }`,
+			expCode: http.StatusOK,
+			expRes: `{"errors":[{"message":"the movie may already exist","path":["createMovie"],` +
+				`"extensions":{"duplicates":[{"id":1,"title":"The Matrix"}]}}],"data":null}`,
+		},
+		"Should create a confirmed movie": {
+			movieSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("CreateMovie", mock.Anything, movieservice.NewMovie{Title: "Matrix, The", Description: "desc", Confirm: true}).
+					Return(nil)
+
+				return m
+			}(),
+			authSvc: &authservice.SvcMock{},
+			token:   "Bearer " + token(t, 2),
+			query:   `mutation { createMovie(input: {title: "Matrix, The", description: "desc", confirm: true}) }`,
+			expCode: http.StatusOK,
+			expRes:  `{"data":{"createMovie":true}}`,
+		},
+		"Should not vote without auth": {
+			movieSvc: &movieservice.SvcMock{},
+			authSvc:  &authservice.SvcMock{},
+			query:    `mutation { vote(movieId: "1", vote: LIKE) { id } }`,
+			expCode:  http.StatusOK,
+			expRes:   `{"errors":[{"message":"authentication required","path":["vote"]}],"data":null}`,
+		},
+		"Should translate the errors in the locale of the request": {
+			movieSvc: &movieservice.SvcMock{},
+			authSvc:  &authservice.SvcMock{},
+			lang:     "fr-CA,fr;q=0.9",
+			query:    `mutation { vote(movieId: "1", vote: LIKE) { id } }`,
+			expCode:  http.StatusOK,
+			expRes:   `{"errors":[{"message":"authentification requise","path":["vote"]}],"data":null}`,
+		},
+		"Should rate limit the votes": {
+			movieSvc: func() *movieservice.SvcMock {
+				m := &movieservice.SvcMock{}
+				m.On("Vote", mock.Anything, 1, "like").Return(nil).Once()
+				m.On("GetMovie", mock.Anything, 1).Return(&movieservice.Movie{ID: 1, Likes: 1}, nil).Once()
+
+				return m
+			}(),
+			authSvc: &authservice.SvcMock{},
+			token:   "Bearer " + token(t, 2),
+			limiter: func() *ratelimit.Limiter {
+				l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+					ratelimit.GroupVotes: {PerUser: ratelimit.Limit{Requests: 1, Period: time.Minute}},
+				}, nil)
+				require.NoError(t, err)
+
+				return l
+			}(),
+			query:   `mutation { a: vote(movieId: "1", vote: LIKE) { id } b: vote(movieId: "1", vote: NONE) { id } }`,
+			expCode: http.StatusOK,
+			expRes:  `{"errors":[{"message":"rate limit exceeded","path":["b"]}],"data":null}`,
+		},
+		"Should reject an invalid token": {
+			movieSvc: &movieservice.SvcMock{},
+			authSvc:  &authservice.SvcMock{},
+			token:    "Bearer invalid",
+			query:    `{ viewer { id } }`,
+			expCode:  http.StatusUnauthorized,
+			expRes:   `{"message":"invalid or expired jwt"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(requestlocale.Middleware())
+			jwtCfg := middleware.JWTConfig{
+				Claims:     &authservice.JwtCustomClaims{},
+				SigningKey: []byte(jwtSecret),
+			}
+			graphql.NewRouter(tt.movieSvc, tt.authSvc, jwtCfg, zap.NewNop()).WithLimiter(tt.limiter).AppendRoutes(e)
+
+			body, _ := json.Marshal(graphql.Request{Query: tt.query})
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.token)
+			}
+			if tt.lang != "" {
+				req.Header.Set("Accept-Language", tt.lang)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expCode, rec.Code)
+			assert.JSONEq(t, tt.expRes, rec.Body.String())
+			tt.movieSvc.AssertExpectations(t)
+			tt.authSvc.AssertExpectations(t)
+		})
+	}
+}
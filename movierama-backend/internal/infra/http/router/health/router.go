@@ -0,0 +1,40 @@
+package health
+
+import (
+	"github.com/labstack/echo/v4"
+	"movierama/internal/health"
+	"net/http"
+)
+
+// Router infrastructure definition.
+type Router struct {
+	checker *health.Checker
+}
+
+// NewRouter returns an HTTP component to serve the liveness and readiness probes.
+func NewRouter(checker *health.Checker) *Router {
+	return &Router{
+		checker: checker,
+	}
+}
+
+// AppendRoutes adds health routes to router.
+func (r *Router) AppendRoutes(e *echo.Echo) {
+	e.GET("/healthz", r.Live)
+	e.GET("/readyz", r.Ready)
+}
+
+// Live reports that the process is able to serve requests.
+func (r *Router) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// Ready reports the status of the dependencies, with 503 when any of them is unavailable.
+func (r *Router) Ready(c echo.Context) error {
+	report := r.checker.Check(c.Request().Context())
+	if !report.OK() {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,81 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/health"
+	healthroute "movierama/internal/infra/http/router/health"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouter(t *testing.T) {
+	tests := map[string]struct {
+		path      string
+		check     health.CheckFunc
+		expStatus int
+		expBody   map[string]interface{}
+	}{
+		"should report the process alive": {
+			path: "/healthz",
+			check: func(ctx context.Context) error {
+				return errors.New("should not be called")
+			},
+			expStatus: http.StatusOK,
+			expBody:   map[string]interface{}{"status": "ok"},
+		},
+		"should report ready": {
+			path: "/readyz",
+			check: func(ctx context.Context) error {
+				return nil
+			},
+			expStatus: http.StatusOK,
+			expBody: map[string]interface{}{
+				"status": "ok",
+				"checks": map[string]interface{}{"writer": map[string]interface{}{"status": "ok"}},
+			},
+		},
+		"should report not ready": {
+			path: "/readyz",
+			check: func(ctx context.Context) error {
+				return errors.New("connection refused")
+			},
+			expStatus: http.StatusServiceUnavailable,
+			expBody: map[string]interface{}{
+				"status": "fail",
+				"checks": map[string]interface{}{
+					"writer": map[string]interface{}{"status": "fail", "error": "connection refused"},
+				},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := health.NewChecker(time.Second)
+			c.Add("writer", tt.check)
+			e := echo.New()
+			healthroute.NewRouter(c).AppendRoutes(e)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.expStatus, rec.Code)
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			if checks, ok := body["checks"].(map[string]interface{}); ok {
+				for _, res := range checks {
+					assert.Contains(t, res, "latency_ms")
+					delete(res.(map[string]interface{}), "latency_ms")
+				}
+			}
+			assert.Equal(t, tt.expBody, body)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package jwks
+
+import (
+	"github.com/labstack/echo/v4"
+	"movierama/internal/jwtkeys"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxAge is the time the clients may cache the key set, the keys are published ahead of signing.
+const maxAge = 5 * time.Minute
+
+// Router infrastructure definition.
+type Router struct {
+	keys *jwtkeys.Set
+}
+
+// NewRouter returns an HTTP component to serve the public keys verifying the access tokens.
+func NewRouter(keys *jwtkeys.Set) *Router {
+	return &Router{
+		keys: keys,
+	}
+}
+
+// AppendRoutes adds the JWKS route to router.
+func (r *Router) AppendRoutes(e *echo.Echo) {
+	e.GET("/.well-known/jwks.json", r.JWKS)
+}
+
+// JWKS serves the JSON Web Key Set of the public keys, empty when the tokens are signed with a secret.
+func (r *Router) JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+
+	return c.JSON(http.StatusOK, r.keys.JWKS(time.Now()))
+}
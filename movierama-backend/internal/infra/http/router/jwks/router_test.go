@@ -0,0 +1,77 @@
+package jwks_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jwksroute "movierama/internal/infra/http/router/jwks"
+	"movierama/internal/jwtkeys"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRouter(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{"Not-Before": time.Now().Add(time.Hour).UTC().Format(time.RFC3339)},
+		Bytes:   b,
+	}), 0o600))
+	keys, err := jwtkeys.Load(path)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		keys    *jwtkeys.Set
+		expKeys []jwtkeys.JWK
+	}{
+		"should publish the keys ahead of signing": {
+			keys: keys,
+			expKeys: []jwtkeys.JWK{{
+				Kty: "OKP",
+				Kid: keys.Keys()[0].ID,
+				Use: "sig",
+				Alg: "EdDSA",
+				Crv: "Ed25519",
+				X:   encode(pub),
+			}},
+		},
+		"should publish no key for a secret": {
+			keys:    jwtkeys.NewHMAC("secret"),
+			expKeys: []jwtkeys.JWK{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			jwksroute.NewRouter(tt.keys).AppendRoutes(e)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+
+			var body jwtkeys.JWKS
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.expKeys, body.Keys)
+		})
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
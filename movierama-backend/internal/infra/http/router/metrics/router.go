@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"movierama/internal/metrics"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels the requests that do not match a registered route,
+// so that unknown paths do not create new series.
+const unmatchedRoute = "unmatched"
+
+// Router infrastructure definition.
+type Router struct {
+	handler http.Handler
+}
+
+// NewRouter returns an HTTP component to serve the metrics of gatherer in the Prometheus text format.
+func NewRouter(gatherer prometheus.Gatherer) *Router {
+	return &Router{
+		handler: promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+	}
+}
+
+// AppendRoutes adds the metrics route to router.
+func (r *Router) AppendRoutes(e *echo.Echo) {
+	e.GET("/metrics", echo.WrapHandler(r.handler))
+}
+
+// Middleware returns a middleware that records the count and latency of the requests per route template.
+func Middleware(m *metrics.Metrics) echo.MiddlewareFunc {
+	return MiddlewareWithConfig(MiddlewareConfig{Metrics: m})
+}
+
+// MiddlewareConfig defines the config for the metrics middleware.
+type MiddlewareConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	Metrics *metrics.Metrics
+}
+
+// MiddlewareWithConfig returns a metrics middleware with config.
+func MiddlewareWithConfig(cfg MiddlewareConfig) echo.MiddlewareFunc {
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+
+	// The routes are registered after the middleware, collect them on the first request.
+	var (
+		once   sync.Once
+		routes map[string]bool
+	)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper(c) {
+				return next(c)
+			}
+			once.Do(func() {
+				routes = make(map[string]bool)
+				for _, r := range c.Echo().Routes() {
+					routes[r.Path] = true
+				}
+			})
+
+			start := time.Now()
+			err := next(c)
+
+			// The error handler writes the response of failed requests later on.
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				}
+			}
+
+			// Echo reports the request path as the route of unmatched requests.
+			route := c.Path()
+			if !routes[route] {
+				route = unmatchedRoute
+			}
+			cfg.Metrics.ObserveRequest(c.Request().Method, route, status, time.Since(start))
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metricsroute "movierama/internal/infra/http/router/metrics"
+	"movierama/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	e := echo.New()
+	e.Use(metricsroute.Middleware(metrics.New(reg)))
+	e.GET("/users/:user_id/movies", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.POST("/api/v1/movies", func(c echo.Context) error {
+		return echo.ErrUnauthorized
+	})
+	e.GET("/error", func(c echo.Context) error {
+		return errors.New("random error")
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users/1/movies", nil),
+		httptest.NewRequest(http.MethodGet, "/users/2/movies", nil),
+		httptest.NewRequest(http.MethodPost, "/api/v1/movies", nil),
+		httptest.NewRequest(http.MethodGet, "/error", nil),
+		httptest.NewRequest(http.MethodGet, "/unknown/1", nil),
+		httptest.NewRequest(http.MethodGet, "/unknown/2", nil),
+	} {
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	exp := `
+# HELP movierama_http_requests_total Number of HTTP requests by route template, method and status code.
+# TYPE movierama_http_requests_total counter
+movierama_http_requests_total{method="GET",route="/error",status="500"} 1
+movierama_http_requests_total{method="GET",route="/users/:user_id/movies",status="200"} 2
+movierama_http_requests_total{method="GET",route="unmatched",status="404"} 2
+movierama_http_requests_total{method="POST",route="/api/v1/movies",status="401"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(exp), "movierama_http_requests_total"))
+}
+
+func TestRouter_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics.New(reg).MovieCreated()
+
+	e := echo.New()
+	metricsroute.NewRouter(reg).AppendRoutes(e)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/plain")
+	assert.Contains(t, rec.Body.String(), "movierama_movies_created_total 1")
+}
@@ -1,7 +1,16 @@
 package movie
 
+import "movierama/internal/app/movie"
+
 // NewMovie contains the newly created movie payload struct.
 type NewMovie struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	Confirm     bool   `json:"confirm"`
+}
+
+// DuplicatesRes contains the likely duplicates of a new movie, it is created once confirmed.
+type DuplicatesRes struct {
+	Message    string            `json:"message"`
+	Duplicates []movie.Duplicate `json:"duplicates"`
 }
@@ -2,42 +2,129 @@ package movie
 
 import (
 	"context"
+	"errors"
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4/middleware"
 	"movierama/internal/app/auth"
 	"movierama/internal/app/movie"
+	"movierama/internal/contentfilter"
+	"movierama/internal/infra/http/requestauth"
+	"movierama/internal/infra/http/requestcache"
+	"movierama/internal/infra/http/requestlimit"
+	"movierama/internal/locale"
+	"movierama/internal/logging"
+	"movierama/internal/ratelimit"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 )
 
+// timeAgoPrecision is the smallest change of the time_ago of the movies, which versions their responses along
+// with their writes.
+const timeAgoPrecision = time.Minute
+
 // Router infrastructure definition.
 type Router struct {
-	asSvc  movie.Service
-	jwtCfg middleware.JWTConfig
+	asSvc   movie.Service
+	jwtCfg  middleware.JWTConfig
+	limiter *ratelimit.Limiter
+	// maxAge is how long the caches keep the public movies.
+	maxAge time.Duration
+	log    *zap.Logger
 }
 
 // NewRouter returns an HTTP component to serve all the routes for the movies.
-func NewRouter(asSvc movie.Service, jwtCfg middleware.JWTConfig) *Router {
+func NewRouter(asSvc movie.Service, jwtCfg middleware.JWTConfig, logger *zap.Logger) *Router {
 	return &Router{
 		asSvc:  asSvc,
 		jwtCfg: jwtCfg,
+		log:    logger,
+	}
+}
+
+// WithLimiter rate limits the movie submissions and the votes with l.
+func (r *Router) WithLimiter(l *ratelimit.Limiter) *Router {
+	r.limiter = l
+
+	return r
+}
+
+// WithCacheMaxAge lets the caches keep the public movies for maxAge, under timeAgoPrecision so that their time_ago
+// is not kept much past its change, the movies of the auth users are revalidated on each use.
+func (r *Router) WithCacheMaxAge(maxAge time.Duration) *Router {
+	if maxAge >= timeAgoPrecision {
+		maxAge = timeAgoPrecision - time.Second
 	}
+	r.maxAge = maxAge
+
+	return r
 }
 
 // AppendRoutes adds movies routes to router.
 func (r *Router) AppendRoutes(e *echo.Echo) {
-	e.GET("/movies", r.GetMoviesPublic)
-	e.GET("/users/:user_id/movies", r.GetUserMoviesPublic)
+	// The movies are answered with 304 Not Modified while they have not changed.
+	public := requestcache.Conditional(r.validators, requestcache.Public(r.maxAge), r.log)
+	e.GET("/movies", r.GetMoviesPublic, public)
+	e.GET("/movies/:movie_id", r.GetMoviePublic, public)
+	e.GET("/users/:user_id/movies", r.GetUserMoviesPublic, public)
 
+	// Each route takes the personal access tokens of its scope along with the JWTs.
 	rg := e.Group("/api/v1")
-	rg.Use(middleware.JWTWithConfig(r.jwtCfg))
-	rg.GET("/movies", r.GetMovies)
-	rg.GET("/users/:user_id/movies", r.GetUserMovies)
-	rg.POST("/movies", r.CreateMovie)
-	rg.POST("/movies/:movie_id/action/:action", r.MakeAction)
-	rg.POST("/movies/:movie_id/remove_action/:action", r.RemoveAction)
+	read := r.auth(auth.ScopeMoviesRead)
+	private := requestcache.ConditionalWithConfig(requestcache.ConditionalConfig{
+		Validators:   r.validators,
+		CacheControl: requestcache.Private,
+		Vary:         []string{echo.HeaderAuthorization},
+		Logger:       r.log,
+	})
+	rg.GET("/movies", r.GetMovies, read, private)
+	rg.GET("/movies/:movie_id", r.GetMovie, read, private)
+	rg.GET("/users/:user_id/movies", r.GetUserMovies, read, private)
+	limitMovies := requestlimit.Limit(r.limiter, ratelimit.GroupMovies, r.log)
+	limitVotes := requestlimit.Limit(r.limiter, ratelimit.GroupVotes, r.log)
+	vote := r.auth(auth.ScopeVotesWrite)
+	rg.POST("/movies", r.CreateMovie, r.auth(auth.ScopeMoviesWrite), limitMovies)
+	rg.POST("/movies/:movie_id/action/:action", r.MakeAction, vote, limitVotes)
+	rg.POST("/movies/:movie_id/remove_action/:action", r.RemoveAction, vote, limitVotes)
+}
+
+// auth returns the JWT middleware of a route of scope.
+func (r *Router) auth(scope auth.Scope) echo.MiddlewareFunc {
+	return middleware.JWTWithConfig(requestauth.Scoped(r.jwtCfg, scope))
+}
+
+// validators returns the validators of the movies of the route from their last write, the votes included: a
+// movie, the movies of a user or every movie. The movies also differ by locale, by auth user and by their
+// time_ago, which changes every timeAgoPrecision without any write.
+func (r *Router) validators(c echo.Context) (requestcache.Validators, error) {
+	ctx := c.Request().Context()
+	userID := authUserID(c)
+	if userID != 0 {
+		ctx = context.WithValue(ctx, movie.AuthUserIDContextKey, userID)
+	}
+	// The invalid ids are rejected by the handlers, whose errors are not validated.
+	var scope movie.ChangeScope
+	scope.MovieID, _ = strconv.Atoi(c.Param("movie_id"))
+	scope.UserID, _ = strconv.Atoi(c.Param("user_id"))
+
+	change, err := r.asSvc.GetChange(ctx, scope)
+	if err != nil {
+		return requestcache.Validators{}, err
+	}
+
+	lastModified := change.ChangedAt
+	if since := time.Now().Truncate(timeAgoPrecision); since.After(lastModified) {
+		lastModified = since
+	}
+
+	return requestcache.Validators{
+		ETag: requestcache.WeakETag(change.Movies, change.ChangedAt.UnixNano(), locale.FromContext(ctx), userID,
+			lastModified.UnixNano()),
+		LastModified: lastModified,
+	}, nil
 }
 
 // GetMoviesPublic gets the list of movies without auth.
@@ -82,6 +169,36 @@ func (r *Router) GetUserMovies(c echo.Context) error {
 	return c.JSON(http.StatusOK, movies)
 }
 
+// GetMoviePublic gets a movie without auth.
+func (r *Router) GetMoviePublic(c echo.Context) error {
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "movie_id must be a number")
+	}
+	m, err := r.asSvc.GetMovie(c.Request().Context(), movieID)
+	if err != nil {
+		return toHTTPError(c.Request().Context(), err)
+	}
+
+	return c.JSON(http.StatusOK, m)
+}
+
+// GetMovie gets a movie.
+func (r *Router) GetMovie(c echo.Context) error {
+	ctx := context.WithValue(c.Request().Context(), movie.AuthUserIDContextKey, getAuthUserID(c))
+
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "movie_id must be a number")
+	}
+	m, err := r.asSvc.GetMovie(ctx, movieID)
+	if err != nil {
+		return toHTTPError(ctx, err)
+	}
+
+	return c.JSON(http.StatusOK, m)
+}
+
 // GetUserMoviesPublic gets the public user movies.
 func (r *Router) GetUserMoviesPublic(c echo.Context) error {
 	sortType := c.QueryParam("sort")
@@ -107,9 +224,10 @@ func (r *Router) CreateMovie(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	logging.For(ctx, r.log).Debug("create movie request", logging.Payload("payload", m))
 	err = r.asSvc.CreateMovie(ctx, movie.NewMovie(*m))
 	if err != nil {
-		return err
+		return toHTTPError(ctx, err)
 	}
 
 	return c.JSON(http.StatusCreated, nil)
@@ -151,6 +269,25 @@ func (r *Router) RemoveAction(c echo.Context) error {
 	return c.JSON(http.StatusOK, nil)
 }
 
+// toHTTPError maps the service errors to HTTP errors, the missing movies are not found, the rejections of the
+// content filter give their reason and the likely duplicates of a new movie are listed along with the message,
+// in the locale of the request.
+func toHTTPError(ctx context.Context, err error) error {
+	var rejected *contentfilter.RejectedError
+	var dupErr *movie.DuplicateError
+	switch {
+	case errors.Is(err, movie.ErrMovieNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.As(err, &rejected):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, rejected.Reason)
+	case errors.As(err, &dupErr):
+		msg := locale.FromContext(ctx).Translate(dupErr.Error())
+		return echo.NewHTTPError(http.StatusConflict, DuplicatesRes{Message: msg, Duplicates: dupErr.Movies})
+	}
+
+	return err
+}
+
 // gets user id from JWT token.
 func getAuthUserID(c echo.Context) int {
 	// Get user_id from token.
@@ -159,3 +296,12 @@ func getAuthUserID(c echo.Context) int {
 
 	return claims.UserID
 }
+
+// authUserID returns the user id of the JWT token, zero on the routes without auth.
+func authUserID(c echo.Context) int {
+	if _, ok := c.Get("user").(*jwt.Token); !ok {
+		return 0
+	}
+
+	return getAuthUserID(c)
+}
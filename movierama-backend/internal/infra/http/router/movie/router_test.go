@@ -8,13 +8,18 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"movierama/internal/app/auth"
 	movieservice "movierama/internal/app/movie"
+	"movierama/internal/contentfilter"
 	"movierama/internal/infra/http/router/movie"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 func TestRouter_GetMoviesPublic(t *testing.T) {
@@ -30,7 +35,7 @@ func TestRouter_GetMoviesPublic(t *testing.T) {
 		"Should succeed on GetMoviesPublic call": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("GetMoviesPublic", context.TODO(), "date").
+				mockSvc.On("GetMoviesPublic", context.Background(), "date").
 					Return(&movieservice.GetmoviesRes{
 						Movies: []movieservice.Movie{
 							{
@@ -86,7 +91,7 @@ func TestRouter_GetMoviesPublic(t *testing.T) {
 		"Should return error on GetMoviesPublic error ": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("GetMoviesPublic", context.TODO(), "likes").
+				mockSvc.On("GetMoviesPublic", context.Background(), "likes").
 					Return(&movieservice.GetmoviesRes{}, errors.New("random error"))
 
 				return mockSvc
@@ -105,7 +110,7 @@ func TestRouter_GetMoviesPublic(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 
-			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig)
+			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig, zap.NewNop())
 			err := r.GetMoviesPublic(tt.echoCtx)
 
 			if tt.expErr == nil {
@@ -132,7 +137,7 @@ func TestRouter_GetUserMoviesPublic(t *testing.T) {
 		"Should succeed on GetUserMoviesPublic call": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("GetUserMoviesPublic", context.TODO(), 3, "date").
+				mockSvc.On("GetUserMoviesPublic", context.Background(), 3, "date").
 					Return(&movieservice.GetmoviesRes{
 						Movies: []movieservice.Movie{
 							{
@@ -190,7 +195,7 @@ func TestRouter_GetUserMoviesPublic(t *testing.T) {
 		"Should return error on GetUserMoviesPublic error ": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("GetUserMoviesPublic", context.TODO(), 3, "likes").
+				mockSvc.On("GetUserMoviesPublic", context.Background(), 3, "likes").
 					Return(&movieservice.GetmoviesRes{}, errors.New("random error"))
 
 				return mockSvc
@@ -211,7 +216,7 @@ func TestRouter_GetUserMoviesPublic(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 
-			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig)
+			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig, zap.NewNop())
 			err := r.GetUserMoviesPublic(tt.echoCtx)
 
 			if tt.expErr == nil {
@@ -237,7 +242,7 @@ func TestRouter_GetMovies(t *testing.T) {
 		"Should succeed on GetMovies call": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("GetMovies", context.WithValue(context.TODO(), movieservice.AuthUserIDContextKey, 1), "date").
+				mockSvc.On("GetMovies", context.WithValue(context.Background(), movieservice.AuthUserIDContextKey, 1), "date").
 					Return(&movieservice.GetmoviesRes{
 						Movies: []movieservice.Movie{
 							{
@@ -299,7 +304,7 @@ func TestRouter_GetMovies(t *testing.T) {
 				Claims: tt.jwtConfig.Claims,
 			})
 
-			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig)
+			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig, zap.NewNop())
 			r.AppendRoutes(e)
 			err := r.GetMovies(c)
 			if tt.expErr == nil {
@@ -325,7 +330,7 @@ func TestRouter_GetUserMovies(t *testing.T) {
 		"Should succeed on GetUserMovies call": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("GetUserMovies", context.WithValue(context.TODO(), movieservice.AuthUserIDContextKey, 1), 2, "date").
+				mockSvc.On("GetUserMovies", context.WithValue(context.Background(), movieservice.AuthUserIDContextKey, 1), 2, "date").
 					Return(&movieservice.GetmoviesRes{
 						Movies: []movieservice.Movie{
 							{
@@ -389,7 +394,7 @@ func TestRouter_GetUserMovies(t *testing.T) {
 				Claims: tt.jwtConfig.Claims,
 			})
 
-			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig)
+			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig, zap.NewNop())
 			r.AppendRoutes(e)
 			err := r.GetUserMovies(c)
 			if tt.expErr == nil {
@@ -418,7 +423,7 @@ func TestRouter_CreateMovie(t *testing.T) {
 					Title:       "Titanic",
 					Description: "Titanic Description",
 				}
-				mockSvc.On("CreateMovie", context.WithValue(context.TODO(),
+				mockSvc.On("CreateMovie", context.WithValue(context.Background(),
 					movieservice.AuthUserIDContextKey, 1), movieservice.NewMovie(m)).
 					Return(nil)
 
@@ -432,6 +437,43 @@ func TestRouter_CreateMovie(t *testing.T) {
 				SigningKey: []byte("secret"),
 			},
 		},
+		"Should return 422 with the reason of a rejection": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("CreateMovie", mock.Anything, mock.Anything).
+					Return(&contentfilter.RejectedError{Rule: contentfilter.RuleLinks, Reason: "links are not allowed in the title"})
+
+				return mockSvc
+			}(),
+			jwtConfig: middleware.JWTConfig{
+				Claims: &auth.JwtCustomClaims{
+					UserID:         1,
+					StandardClaims: jwt.StandardClaims{},
+				},
+				SigningKey: []byte("secret"),
+			},
+			expErr: echo.NewHTTPError(http.StatusUnprocessableEntity, "links are not allowed in the title"),
+		},
+		"Should return 409 with the likely duplicates": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("CreateMovie", mock.Anything, mock.Anything).
+					Return(&movieservice.DuplicateError{Movies: []movieservice.Duplicate{{ID: 3, Title: "Titanic!"}}})
+
+				return mockSvc
+			}(),
+			jwtConfig: middleware.JWTConfig{
+				Claims: &auth.JwtCustomClaims{
+					UserID:         1,
+					StandardClaims: jwt.StandardClaims{},
+				},
+				SigningKey: []byte("secret"),
+			},
+			expErr: echo.NewHTTPError(http.StatusConflict, movie.DuplicatesRes{
+				Message:    "the movie may already exist",
+				Duplicates: []movieservice.Duplicate{{ID: 3, Title: "Titanic!"}},
+			}),
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -450,7 +492,7 @@ func TestRouter_CreateMovie(t *testing.T) {
 				Claims: tt.jwtConfig.Claims,
 			})
 
-			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig)
+			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig, zap.NewNop())
 			r.AppendRoutes(e)
 			err = r.CreateMovie(c)
 			if tt.expErr == nil {
@@ -474,7 +516,7 @@ func TestRouter_MakeAction(t *testing.T) {
 		"Should succeed on MakeAction call": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("Action", context.WithValue(context.TODO(),
+				mockSvc.On("Action", context.WithValue(context.Background(),
 					movieservice.AuthUserIDContextKey, 1), 2, "like").
 					Return(nil)
 
@@ -506,7 +548,7 @@ func TestRouter_MakeAction(t *testing.T) {
 				Claims: tt.jwtConfig.Claims,
 			})
 
-			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig)
+			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig, zap.NewNop())
 			r.AppendRoutes(e)
 			err = r.MakeAction(c)
 			if tt.expErr == nil {
@@ -530,7 +572,7 @@ func TestRouter_RemoveAction(t *testing.T) {
 		"Should succeed on RemoveAction call": {
 			mockSvc: func() *movieservice.SvcMock {
 				mockSvc := &movieservice.SvcMock{}
-				mockSvc.On("RemoveAction", context.WithValue(context.TODO(),
+				mockSvc.On("RemoveAction", context.WithValue(context.Background(),
 					movieservice.AuthUserIDContextKey, 1), 2, "like").
 					Return(nil)
 
@@ -562,7 +604,7 @@ func TestRouter_RemoveAction(t *testing.T) {
 				Claims: tt.jwtConfig.Claims,
 			})
 
-			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig)
+			r := movie.NewRouter(tt.mockSvc, tt.jwtConfig, zap.NewNop())
 			r.AppendRoutes(e)
 			err = r.RemoveAction(c)
 			if tt.expErr == nil {
@@ -574,3 +616,260 @@ func TestRouter_RemoveAction(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_GetMovie(t *testing.T) {
+	tests := map[string]struct {
+		mockSvc   *movieservice.SvcMock
+		movieID   string
+		expStatus int
+		expRes    string
+		expErr    error
+	}{
+		"Should succeed on GetMovie call": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetMovie", context.WithValue(context.Background(), movieservice.AuthUserIDContextKey, 1), 3).
+					Return(&movieservice.Movie{ID: 3, Title: "movie title", Likes: 2, UserLiked: true}, nil)
+
+				return mockSvc
+			}(),
+			movieID:   "3",
+			expStatus: http.StatusOK,
+			expRes: func() string {
+				b, err := json.Marshal(&movieservice.Movie{ID: 3, Title: "movie title", Likes: 2, UserLiked: true})
+				assert.Nil(t, err)
+
+				return string(b) + "\n"
+			}(),
+		},
+		"Should return not found on a missing movie": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetMovie", context.WithValue(context.Background(), movieservice.AuthUserIDContextKey, 1), 3).
+					Return((*movieservice.Movie)(nil), movieservice.ErrMovieNotFound)
+
+				return mockSvc
+			}(),
+			movieID: "3",
+			expErr:  echo.NewHTTPError(http.StatusNotFound, movieservice.ErrMovieNotFound.Error()),
+		},
+		"Should return bad request on an invalid movie id": {
+			mockSvc: &movieservice.SvcMock{},
+			movieID: "abc",
+			expErr:  echo.NewHTTPError(http.StatusBadRequest, "movie_id must be a number"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("movie_id")
+			c.SetParamValues(tt.movieID)
+			c.Set("user", &jwt.Token{
+				Claims: &auth.JwtCustomClaims{UserID: 1},
+			})
+
+			r := movie.NewRouter(tt.mockSvc, middleware.JWTConfig{}, zap.NewNop())
+			err := r.GetMovie(c)
+			if tt.expErr == nil {
+				assert.Equal(t, tt.expStatus, rec.Code)
+				assert.Equal(t, tt.expRes, rec.Body.String())
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, tt.expErr, err)
+			}
+			tt.mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_Conditional(t *testing.T) {
+	// A write past the current minute keeps the changes of the time_ago out of the validators.
+	changedAt := time.Now().UTC().Add(time.Minute).Truncate(time.Second)
+	jwtCfg := middleware.JWTConfig{Claims: &auth.JwtCustomClaims{}, SigningKey: []byte("secret")}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.JwtCustomClaims{UserID: 1}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	// etag returns the etag of the movies of a request.
+	etag := func(t *testing.T, path, token string) string {
+		mockSvc := &movieservice.SvcMock{}
+		mockSvc.On("GetChange", mock.Anything, movieservice.ChangeScope{}).
+			Return(&movieservice.Change{Movies: 7, ChangedAt: changedAt}, nil)
+		mockSvc.On("GetMoviesPublic", mock.Anything, "").Return(&movieservice.GetmoviesRes{}, nil)
+		mockSvc.On("GetMovies", mock.Anything, "").Return(&movieservice.GetmoviesRes{}, nil)
+		e := echo.New()
+		movie.NewRouter(mockSvc, jwtCfg, zap.NewNop()).AppendRoutes(e)
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		return rec.Header().Get("ETag")
+	}
+
+	tests := map[string]struct {
+		mockSvc         *movieservice.SvcMock
+		path            string
+		token           string
+		ifNoneMatch     string
+		expStatus       int
+		expCacheControl string
+		expVary         string
+	}{
+		"Should send the public movies with a public cache control": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetChange", context.Background(), movieservice.ChangeScope{}).
+					Return(&movieservice.Change{Movies: 7, ChangedAt: changedAt}, nil)
+				mockSvc.On("GetMoviesPublic", context.Background(), "").
+					Return(&movieservice.GetmoviesRes{}, nil)
+
+				return mockSvc
+			}(),
+			path:            "/movies",
+			expStatus:       http.StatusOK,
+			expCacheControl: "public, max-age=5",
+		},
+		"Should not send the unchanged public movies": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetChange", context.Background(), movieservice.ChangeScope{}).
+					Return(&movieservice.Change{Movies: 7, ChangedAt: changedAt}, nil)
+
+				return mockSvc
+			}(),
+			path:            "/movies",
+			ifNoneMatch:     etag(t, "/movies", ""),
+			expStatus:       http.StatusNotModified,
+			expCacheControl: "public, max-age=5",
+		},
+		"Should send the changed public movie": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetChange", context.Background(), movieservice.ChangeScope{MovieID: 3}).
+					Return(&movieservice.Change{Movies: 1, ChangedAt: changedAt}, nil)
+				mockSvc.On("GetMovie", context.Background(), 3).
+					Return(&movieservice.Movie{ID: 3}, nil)
+
+				return mockSvc
+			}(),
+			path:            "/movies/3",
+			ifNoneMatch:     etag(t, "/movies", ""),
+			expStatus:       http.StatusOK,
+			expCacheControl: "public, max-age=5",
+		},
+		"Should version the movies of a user by their own change": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetChange", context.Background(), movieservice.ChangeScope{UserID: 2}).
+					Return(&movieservice.Change{Movies: 2, ChangedAt: changedAt}, nil)
+				mockSvc.On("GetUserMoviesPublic", context.Background(), 2, "").
+					Return(&movieservice.GetmoviesRes{}, nil)
+
+				return mockSvc
+			}(),
+			path:            "/users/2/movies",
+			ifNoneMatch:     etag(t, "/movies", ""),
+			expStatus:       http.StatusOK,
+			expCacheControl: "public, max-age=5",
+		},
+		"Should not cache a missing movie": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetChange", context.Background(), movieservice.ChangeScope{MovieID: 3}).
+					Return(&movieservice.Change{Movies: 0}, nil)
+				mockSvc.On("GetMovie", context.Background(), 3).
+					Return((*movieservice.Movie)(nil), movieservice.ErrMovieNotFound)
+
+				return mockSvc
+			}(),
+			path:      "/movies/3",
+			expStatus: http.StatusNotFound,
+		},
+		"Should send the movies of the auth user with a private cache control": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetChange", context.WithValue(context.Background(), movieservice.AuthUserIDContextKey, 1),
+					movieservice.ChangeScope{}).Return(&movieservice.Change{Movies: 7, ChangedAt: changedAt}, nil)
+				mockSvc.On("GetMovies", context.WithValue(context.Background(), movieservice.AuthUserIDContextKey, 1), "").
+					Return(&movieservice.GetmoviesRes{}, nil)
+
+				return mockSvc
+			}(),
+			path:            "/api/v1/movies",
+			token:           token,
+			ifNoneMatch:     etag(t, "/movies", ""),
+			expStatus:       http.StatusOK,
+			expCacheControl: "private, no-cache",
+			expVary:         echo.HeaderAuthorization,
+		},
+		"Should not send the unchanged movies of the auth user": {
+			mockSvc: func() *movieservice.SvcMock {
+				mockSvc := &movieservice.SvcMock{}
+				mockSvc.On("GetChange", context.WithValue(context.Background(), movieservice.AuthUserIDContextKey, 1),
+					movieservice.ChangeScope{}).Return(&movieservice.Change{Movies: 7, ChangedAt: changedAt}, nil)
+
+				return mockSvc
+			}(),
+			path:            "/api/v1/movies",
+			token:           token,
+			ifNoneMatch:     etag(t, "/api/v1/movies", token),
+			expStatus:       http.StatusNotModified,
+			expCacheControl: "private, no-cache",
+			expVary:         echo.HeaderAuthorization,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			movie.NewRouter(tt.mockSvc, jwtCfg, zap.NewNop()).WithCacheMaxAge(5 * time.Second).AppendRoutes(e)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expStatus, rec.Code)
+			assert.Equal(t, tt.expCacheControl, rec.Header().Get(echo.HeaderCacheControl))
+			assert.Equal(t, tt.expVary, rec.Header().Get(echo.HeaderVary))
+			if tt.expCacheControl != "" {
+				assert.NotEmpty(t, rec.Header().Get("ETag"))
+				assert.Equal(t, changedAt.Format(http.TimeFormat), rec.Header().Get(echo.HeaderLastModified))
+			}
+			tt.mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_TimeAgo(t *testing.T) {
+	changedAt := time.Now().UTC().Add(-time.Hour)
+	mockSvc := &movieservice.SvcMock{}
+	mockSvc.On("GetChange", context.Background(), movieservice.ChangeScope{}).
+		Return(&movieservice.Change{Movies: 7, ChangedAt: changedAt}, nil)
+	mockSvc.On("GetMoviesPublic", context.Background(), "").Return(&movieservice.GetmoviesRes{}, nil)
+	e := echo.New()
+	jwtCfg := middleware.JWTConfig{Claims: &auth.JwtCustomClaims{}, SigningKey: []byte("secret")}
+	movie.NewRouter(mockSvc, jwtCfg, zap.NewNop()).WithCacheMaxAge(time.Hour).AppendRoutes(e)
+
+	req := httptest.NewRequest(http.MethodGet, "/movies", nil)
+	req.Header.Set(echo.HeaderIfModifiedSince, changedAt.Format(http.TimeFormat))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code, "the time_ago changed since the last write")
+	assert.Equal(t, "public, max-age=59", rec.Header().Get(echo.HeaderCacheControl), "the caches keep it under a minute")
+	lastModified, err := http.ParseTime(rec.Header().Get(echo.HeaderLastModified))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Truncate(time.Minute), lastModified, time.Minute)
+}
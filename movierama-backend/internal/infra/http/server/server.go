@@ -0,0 +1,68 @@
+// Package server runs the HTTP server until the app is asked to stop.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Config defines the config of the server.
+type Config struct {
+	// Address is the TCP address to listen on, e.g. ":1323".
+	Address string
+	// ShutdownTimeout bounds the wait for the in-flight requests and the OnShutdown hooks.
+	ShutdownTimeout time.Duration
+	// OnShutdown are run concurrently with the draining of the requests, to stop the
+	// long-lived streams and the other servers. They must return when ctx is done.
+	OnShutdown []func(ctx context.Context) error
+}
+
+// Serve starts e and shuts it down gracefully once ctx is done: it stops accepting
+// connections and waits for the in-flight requests up to the shutdown timeout,
+// then closes the connections that are still open.
+func Serve(ctx context.Context, e *echo.Echo, cfg Config) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- e.Start(cfg.Address)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	errs := make([]error, len(cfg.OnShutdown)+1)
+	var wg sync.WaitGroup
+	for i, hook := range cfg.OnShutdown {
+		wg.Add(1)
+		go func(i int, hook func(ctx context.Context) error) {
+			defer wg.Done()
+			errs[i] = hook(sctx)
+		}(i, hook)
+	}
+	if errs[len(cfg.OnShutdown)] = e.Shutdown(sctx); errs[len(cfg.OnShutdown)] != nil {
+		// The deadline passed, drop the requests that are still running.
+		e.Close()
+	}
+	wg.Wait()
+
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("shutdown: %w", err)
+		}
+	}
+
+	return nil
+}
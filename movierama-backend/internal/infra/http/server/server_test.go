@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"movierama/internal/infra/http/server"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	tests := map[string]struct {
+		handlerDelay time.Duration
+		timeout      time.Duration
+		expBody      string
+		expErr       bool
+	}{
+		"should finish the in-flight request": {
+			handlerDelay: 100 * time.Millisecond,
+			timeout:      time.Second,
+			expBody:      "voted",
+		},
+		"should drop the requests still running at the deadline": {
+			handlerDelay: time.Second,
+			timeout:      50 * time.Millisecond,
+			expErr:       true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			e.HideBanner = true
+			e.HidePort = true
+			started := make(chan struct{})
+			e.POST("/api/v1/movies/1/vote", func(c echo.Context) error {
+				close(started)
+				time.Sleep(tt.handlerDelay)
+
+				return c.String(http.StatusOK, "voted")
+			})
+
+			var hookCalled bool
+			ctx, stop := context.WithCancel(context.Background())
+			served := make(chan error, 1)
+			go func() {
+				served <- server.Serve(ctx, e, server.Config{
+					Address:         "127.0.0.1:0",
+					ShutdownTimeout: tt.timeout,
+					OnShutdown: []func(ctx context.Context) error{
+						func(ctx context.Context) error {
+							hookCalled = true
+							return nil
+						},
+					},
+				})
+			}()
+			addr := waitForListener(t, e)
+
+			type response struct {
+				body string
+				err  error
+			}
+			responses := make(chan response, 1)
+			go func() {
+				res, err := http.Post("http://"+addr+"/api/v1/movies/1/vote", "", nil)
+				if err != nil {
+					responses <- response{err: err}
+					return
+				}
+				defer res.Body.Close()
+				b, err := io.ReadAll(res.Body)
+				responses <- response{body: string(b), err: err}
+			}()
+
+			// Ask for the shutdown while the request is in flight.
+			<-started
+			stop()
+
+			res := <-responses
+			err := <-served
+			assert.True(t, hookCalled)
+			if tt.expErr {
+				assert.Error(t, res.err)
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+				return
+			}
+			require.NoError(t, res.err)
+			assert.Equal(t, tt.expBody, res.body)
+			assert.NoError(t, err)
+
+			_, err = http.Get("http://" + addr + "/")
+			assert.Error(t, err, "the server no longer accepts connections")
+		})
+	}
+}
+
+func waitForListener(t *testing.T, e *echo.Echo) string {
+	for i := 0; i < 100; i++ {
+		if addr := e.ListenerAddr(); addr != nil {
+			return addr.String()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("the server did not start")
+
+	return ""
+}
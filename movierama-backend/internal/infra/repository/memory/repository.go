@@ -0,0 +1,901 @@
+// Package memory implements the repositories in memory, for local development and tests.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"movierama/internal/infra/repository/sql/moderation"
+	"movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Movie actions.
+const (
+	actionLike = "like"
+	actionHate = "hate"
+)
+
+// Defaults of the users columns.
+const (
+	defaultRole   = "user"
+	defaultStatus = "active"
+)
+
+// ErrDuplicateAction is returned when the user already has an action on the movie.
+var ErrDuplicateAction = errors.New("duplicate movie action")
+
+// Repository stores the users, the movies and their actions in memory, it is safe for concurrent use.
+// It implements the movie, the auth and the admin repositories, which share the users.
+type Repository struct {
+	mu     sync.RWMutex
+	users  []storedUser
+	movies []movie.SQLMovie
+	// deleted holds the ids of the deleted movies, the ids of the movies are their index in movies.
+	deleted map[int]bool
+	// hidden holds the hiding time of the hidden movies by id.
+	hidden map[int]time.Time
+	// actions holds the action of each user by movie id.
+	actions    map[int]map[int]string
+	events     []user.AuthEvent
+	identities []user.Identity
+	moderation []moderation.Action
+	reports    []moderation.Report
+	// nextReportID keeps the ids of the reports unique once the reports of deleted movies are dropped.
+	nextReportID int
+	tokens       []user.AccessToken
+	// nextTokenID keeps the ids of the access tokens unique once tokens are deleted.
+	nextTokenID int
+}
+
+// storedUser is a user with the columns set by the admins.
+type storedUser struct {
+	user.SQLUser
+	role           string
+	roleChanged    bool
+	status         string
+	suspendedUntil *time.Time
+	tokenVersion   int
+}
+
+// NewRepository constructor.
+func NewRepository() *Repository {
+	return &Repository{
+		actions: make(map[int]map[int]string), deleted: make(map[int]bool), hidden: make(map[int]time.Time),
+	}
+}
+
+// GetUserAuthDetails returns a user.
+func (r *Repository) GetUserAuthDetails(_ context.Context, username string) (*user.AuthUserDetails, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Username == username {
+			return u.authDetails(), nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// GetUserAuthDetailsByID returns a user.
+func (r *Repository) GetUserAuthDetailsByID(_ context.Context, userID int) (*user.AuthUserDetails, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if userID < 1 || userID > len(r.users) {
+		return nil, sql.ErrNoRows
+	}
+
+	return r.users[userID-1].authDetails(), nil
+}
+
+// GetUserAuthDetailsByEmail returns the user of an email.
+func (r *Repository) GetUserAuthDetailsByEmail(_ context.Context, email string) (*user.AuthUserDetails, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Email != nil && *u.Email == email {
+			return u.authDetails(), nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// authDetails returns the auth details of the user.
+func (u storedUser) authDetails() *user.AuthUserDetails {
+	return &user.AuthUserDetails{
+		ID: *u.ID, Username: u.Username, Password: u.Password,
+		Role: u.role, Status: u.status, SuspendedUntil: u.suspendedUntil,
+		Email: copyOf(u.Email), EmailVerifiedAt: copyOf(u.EmailVerifiedAt), TokenVersion: u.tokenVersion,
+	}
+}
+
+// copyOf returns a copy of *p, nil when p is nil, so that the stored users are not shared.
+func copyOf[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+
+	return &v
+}
+
+// UpdateEmail replaces the email of a user, which is no longer verified.
+func (r *Repository) UpdateEmail(_ context.Context, userID int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.emailTaken(email, userID) {
+		return user.ErrDuplicateEmail
+	}
+	if userID >= 1 && userID <= len(r.users) {
+		r.users[userID-1].Email = &email
+		r.users[userID-1].EmailVerifiedAt = nil
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the email of a user verified at the given time, unless the user has another email.
+func (r *Repository) VerifyEmail(_ context.Context, userID int, email string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if userID >= 1 && userID <= len(r.users) {
+		if u := &r.users[userID-1]; u.Email != nil && *u.Email == email {
+			u.EmailVerifiedAt = &at
+		}
+	}
+
+	return nil
+}
+
+// emailTaken reports whether a user other than userID has the email.
+func (r *Repository) emailTaken(email string, userID int) bool {
+	for _, u := range r.users {
+		if u.Email != nil && *u.Email == email && *u.ID != userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdatePassword replaces the password hash of a user and bumps their token version.
+func (r *Repository) UpdatePassword(_ context.Context, userID int, password string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if userID >= 1 && userID <= len(r.users) {
+		r.users[userID-1].Password = password
+		r.users[userID-1].tokenVersion++
+	}
+
+	return nil
+}
+
+// UpdateRole replaces the role of a user.
+func (r *Repository) UpdateRole(_ context.Context, userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if userID >= 1 && userID <= len(r.users) {
+		r.users[userID-1].role, r.users[userID-1].roleChanged = role, true
+	}
+
+	return nil
+}
+
+// BootstrapRole sets the role of a user whose role was never changed from the default one, it reports
+// whether it did.
+func (r *Repository) BootstrapRole(_ context.Context, userID int, role string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if userID < 1 || userID > len(r.users) {
+		return false, nil
+	}
+	u := &r.users[userID-1]
+	if u.role != defaultRole || u.roleChanged {
+		return false, nil
+	}
+	u.role, u.roleChanged = role, true
+
+	return true, nil
+}
+
+// UpdateStatus replaces the status of a user, until is only set for a suspension.
+func (r *Repository) UpdateStatus(_ context.Context, userID int, status string, until *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if userID >= 1 && userID <= len(r.users) {
+		r.users[userID-1].status = status
+		r.users[userID-1].suspendedUntil = until
+	}
+
+	return nil
+}
+
+// SearchAccounts returns the accounts selected by filter, ordered by id.
+func (r *Repository) SearchAccounts(_ context.Context, filter user.AccountFilter) ([]user.Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := strings.ToLower(filter.Query)
+	var accounts []user.Account
+	for _, u := range r.users {
+		if len(accounts) >= filter.Limit {
+			break
+		}
+		switch {
+		case query != "" && !strings.Contains(strings.ToLower(u.Username), query) &&
+			!strings.Contains(strings.ToLower(u.FirstName), query) && !strings.Contains(strings.ToLower(u.LastName), query),
+			filter.Role != "" && u.role != filter.Role,
+			filter.Status != "" && u.status != filter.Status,
+			*u.ID <= filter.AfterID:
+			continue
+		}
+		accounts = append(accounts, user.Account{
+			ID: *u.ID, Username: u.Username, FirstName: u.FirstName, LastName: u.LastName,
+			Role: u.role, Status: u.status, SuspendedUntil: u.suspendedUntil,
+		})
+	}
+
+	return accounts, nil
+}
+
+// CreateUser creates a new user and sets its id.
+func (r *Repository) CreateUser(_ context.Context, u *user.SQLUser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Username == u.Username {
+			return user.ErrDuplicateUsername
+		}
+	}
+	if u.Email != nil && r.emailTaken(*u.Email, 0) {
+		return user.ErrDuplicateEmail
+	}
+	id := len(r.users) + 1
+	stored := storedUser{SQLUser: *u, role: defaultRole, status: defaultStatus}
+	stored.ID = &id
+	stored.Email, stored.EmailVerifiedAt = copyOf(u.Email), copyOf(u.EmailVerifiedAt)
+	r.users = append(r.users, stored)
+	u.ID = &id
+
+	return nil
+}
+
+// GetUsers returns the users of the given ids.
+func (r *Repository) GetUsers(_ context.Context, userIDs []int) ([]user.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []user.User
+	for _, u := range r.users {
+		for _, id := range userIDs {
+			if *u.ID == id {
+				users = append(users, user.User{ID: id, Username: u.Username, FirstName: u.FirstName, LastName: u.LastName})
+				break
+			}
+		}
+	}
+
+	return users, nil
+}
+
+// GetChange returns the number of the movies of scope and the time of the last write of one of them or of their
+// votes.
+func (r *Repository) GetChange(_ context.Context, _ int, scope movie.ChangeScope) (*movie.Change, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var change movie.Change
+	for _, m := range r.movies {
+		if r.deleted[*m.ID] || (scope.MovieID != 0 && *m.ID != scope.MovieID) ||
+			(scope.UserID != 0 && m.UserID != scope.UserID) {
+			continue
+		}
+		change.Movies++
+		if m.UpdatedAt.After(change.ChangedAt) {
+			change.ChangedAt = m.UpdatedAt
+		}
+	}
+
+	return &change, nil
+}
+
+// touch sets the time of the last write of a movie or of its votes, r.mu must be held.
+func (r *Repository) touch(movieID int) {
+	if movieID >= 1 && movieID <= len(r.movies) {
+		r.movies[movieID-1].UpdatedAt = time.Now().UTC()
+	}
+}
+
+// GetMoviesPublic returns a list of movies without auth.
+func (r *Repository) GetMoviesPublic(_ context.Context, sortType string) ([]movie.Movie, error) {
+	return r.list(0, 0, sortType), nil
+}
+
+// GetMovies returns a list of movies.
+func (r *Repository) GetMovies(_ context.Context, authUsrID int, sortType string) ([]movie.Movie, error) {
+	return r.list(0, authUsrID, sortType), nil
+}
+
+// GetUserMovies returns a list of movies for a particular user.
+func (r *Repository) GetUserMovies(_ context.Context, userID, authUsrID int, sortType string) ([]movie.Movie, error) {
+	return r.list(userID, authUsrID, sortType), nil
+}
+
+// GetUserMoviesPublic returns a list of movies for a particular user.
+func (r *Repository) GetUserMoviesPublic(_ context.Context, userID int, sortType string) ([]movie.Movie, error) {
+	return r.list(userID, 0, sortType), nil
+}
+
+// GetMovie returns a visible movie, authUsrID may be zero for anonymous users.
+func (r *Repository) GetMovie(_ context.Context, movieID, authUsrID int) (*movie.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if movieID < 1 || movieID > len(r.movies) || r.deleted[movieID] || r.isHidden(movieID) {
+		return nil, sql.ErrNoRows
+	}
+	m := r.movie(r.movies[movieID-1], authUsrID)
+
+	return &m, nil
+}
+
+// GetAnyMovie returns a movie even when it is hidden, for the moderation.
+func (r *Repository) GetAnyMovie(_ context.Context, movieID int) (*movie.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if movieID < 1 || movieID > len(r.movies) || r.deleted[movieID] {
+		return nil, sql.ErrNoRows
+	}
+	m := r.movie(r.movies[movieID-1], 0)
+	if hiddenAt, ok := r.hidden[movieID]; ok {
+		m.HiddenAt = &hiddenAt
+	}
+
+	return &m, nil
+}
+
+// GetSimilarTitles returns the visible movies whose title keys share the prefix or the suffix of key, ordered by
+// id, along with the movies without key.
+func (r *Repository) GetSimilarTitles(_ context.Context, key string) ([]movie.Title, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prefix, suffix := movie.TitleEnds(key)
+	var titles []movie.Title
+	for _, m := range r.movies {
+		if r.deleted[*m.ID] || r.isHidden(*m.ID) {
+			continue
+		}
+		p, s := movie.TitleEnds(m.TitleKey)
+		if m.TitleKey == "" || p == prefix || s == suffix {
+			titles = append(titles, movie.Title{ID: *m.ID, Title: m.Title, Key: m.TitleKey})
+		}
+	}
+
+	return titles, nil
+}
+
+// SetTitleKeys sets the title keys of the movies created before the keys, by movie id.
+func (r *Repository) SetTitleKeys(_ context.Context, keys map[int]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, key := range keys {
+		if id >= 1 && id <= len(r.movies) {
+			r.movies[id-1].TitleKey = key
+		}
+	}
+
+	return nil
+}
+
+// CountUserMoviesSince counts the movies created by a user since a time.
+func (r *Repository) CountUserMoviesSince(_ context.Context, userID int, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	n := 0
+	for _, m := range r.movies {
+		if m.UserID == userID && !r.deleted[*m.ID] && !m.CreatedAt.Before(since) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// SetHidden hides a movie from the listings or shows it again.
+func (r *Repository) SetHidden(_ context.Context, movieID int, hidden bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !hidden {
+		delete(r.hidden, movieID)
+	} else if movieID >= 1 && movieID <= len(r.movies) {
+		r.hidden[movieID] = time.Now().UTC()
+	}
+	r.touch(movieID)
+
+	return nil
+}
+
+// CreateMovie creates a new movie and sets its id.
+func (r *Repository) CreateMovie(_ context.Context, m *movie.SQLMovie) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := len(r.movies) + 1
+	stored := *m
+	stored.ID = &id
+	stored.CreatedAt = time.Now()
+	stored.UpdatedAt = time.Now().UTC()
+	r.movies = append(r.movies, stored)
+	m.ID = &id
+
+	return nil
+}
+
+// AddMovieAction adds an action for a movie, a user has at most one action per movie.
+func (r *Repository) AddMovieAction(_ context.Context, movieID, userID int, action string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.actions[movieID][userID]; ok {
+		return ErrDuplicateAction
+	}
+	if r.actions[movieID] == nil {
+		r.actions[movieID] = make(map[int]string)
+	}
+	r.actions[movieID][userID] = action
+	r.touch(movieID)
+
+	return nil
+}
+
+// RemoveMovieAction removes an action for a movie.
+func (r *Repository) RemoveMovieAction(_ context.Context, movieID, userID int, action string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.actions[movieID][userID]; ok && current == action {
+		delete(r.actions[movieID], userID)
+	}
+	r.touch(movieID)
+
+	return nil
+}
+
+// SetMovieAction sets the action of a user for a visible movie, replacing any previous one, an empty action
+// retracts it. It returns the previous action.
+func (r *Repository) SetMovieAction(_ context.Context, movieID, userID int, action string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if movieID < 1 || movieID > len(r.movies) || r.deleted[movieID] || r.isHidden(movieID) {
+		return "", sql.ErrNoRows
+	}
+	if r.movies[movieID-1].UserID == userID {
+		return "", movie.ErrOwnMovie
+	}
+	previous := r.actions[movieID][userID]
+	if previous == action {
+		return previous, nil
+	}
+	if action == "" {
+		delete(r.actions[movieID], userID)
+	} else {
+		if r.actions[movieID] == nil {
+			r.actions[movieID] = make(map[int]string)
+		}
+		r.actions[movieID][userID] = action
+	}
+	r.touch(movieID)
+
+	return previous, nil
+}
+
+// DeleteMovie deletes a movie along with its actions and reports.
+func (r *Repository) DeleteMovie(_ context.Context, movieID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteMovie(movieID)
+
+	return nil
+}
+
+// MergeMovies folds the votes of a movie into another movie and deletes it along with its reports. The votes
+// of the users who voted for both movies, or who submitted the other movie, are dropped. It returns the number
+// of moved votes.
+func (r *Repository) MergeMovies(_ context.Context, movieID, intoID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moved := 0
+	if intoID >= 1 && intoID <= len(r.movies) && !r.deleted[intoID] {
+		into := r.movies[intoID-1]
+		for userID, action := range r.actions[movieID] {
+			if _, ok := r.actions[intoID][userID]; ok || userID == into.UserID {
+				continue
+			}
+			if r.actions[intoID] == nil {
+				r.actions[intoID] = make(map[int]string)
+			}
+			r.actions[intoID][userID] = action
+			moved++
+		}
+		r.touch(intoID)
+	}
+	r.deleteMovie(movieID)
+
+	return moved, nil
+}
+
+// deleteMovie deletes a movie along with its actions and reports, r.mu must be held.
+func (r *Repository) deleteMovie(movieID int) {
+	if movieID < 1 || movieID > len(r.movies) {
+		return
+	}
+	r.deleted[movieID] = true
+	delete(r.actions, movieID)
+	delete(r.hidden, movieID)
+	reports := r.reports[:0]
+	for _, rep := range r.reports {
+		if rep.MovieID != movieID {
+			reports = append(reports, rep)
+		}
+	}
+	r.reports = reports
+}
+
+// AddAuthEvent records an auth event and sets its id.
+func (r *Repository) AddAuthEvent(_ context.Context, event *user.AuthEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = len(r.events) + 1
+	stored := *event
+	stored.CreatedAt = time.Now()
+	r.events = append(r.events, stored)
+
+	return nil
+}
+
+// GetIdentityUserID returns the id of the user linked to the identity of the provider, sql.ErrNoRows when
+// there is none.
+func (r *Repository) GetIdentityUserID(_ context.Context, provider, subject string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, id := range r.identities {
+		if id.Provider == provider && id.Subject == subject {
+			return id.UserID, nil
+		}
+	}
+
+	return 0, sql.ErrNoRows
+}
+
+// AddIdentity links an identity to its user and sets its id.
+func (r *Repository) AddIdentity(_ context.Context, identity *user.Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range r.identities {
+		if id.Provider == identity.Provider && (id.Subject == identity.Subject || id.UserID == identity.UserID) {
+			return user.ErrDuplicateIdentity
+		}
+	}
+	identity.ID = len(r.identities) + 1
+	r.identities = append(r.identities, *identity)
+
+	return nil
+}
+
+// AddAccessToken stores a personal access token and sets its id.
+func (r *Repository) AddAccessToken(_ context.Context, token *user.AccessToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextTokenID++
+	token.ID = r.nextTokenID
+	r.tokens = append(r.tokens, *token)
+
+	return nil
+}
+
+// GetAccessTokenByHash returns the personal access token of the hash, sql.ErrNoRows when there is none.
+func (r *Repository) GetAccessTokenByHash(_ context.Context, hash string) (*user.AccessToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.tokens {
+		if t.TokenHash == hash {
+			return &t, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// GetAccessTokens returns the personal access tokens of a user, oldest first.
+func (r *Repository) GetAccessTokens(_ context.Context, userID int) ([]user.AccessToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tokens []user.AccessToken
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens, nil
+}
+
+// DeleteAccessToken deletes a personal access token of a user, sql.ErrNoRows when the user has no token of the id.
+func (r *Repository) DeleteAccessToken(_ context.Context, userID, tokenID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.tokens {
+		if t.ID == tokenID && t.UserID == userID {
+			r.tokens = append(r.tokens[:i], r.tokens[i+1:]...)
+			return nil
+		}
+	}
+
+	return sql.ErrNoRows
+}
+
+// TouchAccessToken records the last use of a personal access token.
+func (r *Repository) TouchAccessToken(_ context.Context, tokenID int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tokens {
+		if r.tokens[i].ID == tokenID {
+			r.tokens[i].LastUsedAt = &at
+		}
+	}
+
+	return nil
+}
+
+// GetAuthEvents returns the auth events selected by filter, newest first.
+func (r *Repository) GetAuthEvents(_ context.Context, filter user.AuthEventFilter) ([]user.AuthEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []user.AuthEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		e := r.events[i]
+		switch {
+		case filter.UserID != 0 && (e.UserID == nil || *e.UserID != filter.UserID),
+			filter.Username != "" && e.Username != filter.Username,
+			filter.IP != "" && e.IP != filter.IP,
+			filter.Event != "" && e.Event != filter.Event,
+			filter.BeforeID != 0 && e.ID >= filter.BeforeID:
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// AddAction records a moderation action and sets its id.
+func (r *Repository) AddAction(_ context.Context, action *moderation.Action) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	action.ID = len(r.moderation) + 1
+	stored := *action
+	stored.CreatedAt = time.Now()
+	r.moderation = append(r.moderation, stored)
+
+	return nil
+}
+
+// GetActions returns the moderation actions selected by filter, newest first.
+func (r *Repository) GetActions(_ context.Context, filter moderation.ActionFilter) ([]moderation.Action, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var actions []moderation.Action
+	for i := len(r.moderation) - 1; i >= 0 && len(actions) < filter.Limit; i-- {
+		a := r.moderation[i]
+		switch {
+		case filter.ModeratorID != 0 && a.ModeratorID != filter.ModeratorID,
+			filter.UserID != 0 && (a.UserID == nil || *a.UserID != filter.UserID),
+			filter.MovieID != 0 && (a.MovieID == nil || *a.MovieID != filter.MovieID),
+			filter.Action != "" && a.Action != filter.Action,
+			filter.BeforeID != 0 && a.ID >= filter.BeforeID:
+			continue
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, nil
+}
+
+// AddReport records the report of a movie and sets its id, a user reports a movie once.
+func (r *Repository) AddReport(_ context.Context, report *moderation.Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if report.Source == "" {
+		report.Source = moderation.SourceUser
+	}
+	for _, rep := range r.reports {
+		if report.ReporterID != 0 && rep.MovieID == report.MovieID && rep.ReporterID == report.ReporterID {
+			return moderation.ErrDuplicateReport
+		}
+	}
+	r.nextReportID++
+	report.ID = r.nextReportID
+	stored := *report
+	stored.Status = moderation.ReportOpen
+	stored.CreatedAt = time.Now()
+	r.reports = append(r.reports, stored)
+
+	return nil
+}
+
+// CountReports returns the number of open reports of a movie from source, from every source when empty.
+func (r *Repository) CountReports(_ context.Context, movieID int, source string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, rep := range r.reports {
+		if rep.MovieID == movieID && rep.Status == moderation.ReportOpen && (source == "" || rep.Source == source) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// GetQueue returns the movies with open reports, the most reported first.
+func (r *Repository) GetQueue(_ context.Context, filter moderation.QueueFilter) ([]moderation.QueueEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byMovie := map[int]*moderation.QueueEntry{}
+	for _, rep := range r.reports {
+		if rep.Status != moderation.ReportOpen || r.deleted[rep.MovieID] {
+			continue
+		}
+		e, ok := byMovie[rep.MovieID]
+		if !ok {
+			m := r.movies[rep.MovieID-1]
+			e = &moderation.QueueEntry{MovieID: rep.MovieID, Title: m.Title, UserID: m.UserID, Categories: map[string]int{}}
+			if hiddenAt, ok := r.hidden[rep.MovieID]; ok {
+				e.HiddenAt = &hiddenAt
+			}
+			byMovie[rep.MovieID] = e
+		}
+		e.Reports++
+		e.Categories[rep.Category]++
+	}
+
+	entries := make([]moderation.QueueEntry, 0, len(byMovie))
+	for _, e := range byMovie {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Reports != entries[j].Reports {
+			return entries[i].Reports > entries[j].Reports
+		}
+		return entries[i].MovieID < entries[j].MovieID
+	})
+	if filter.Offset >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[filter.Offset:]
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	return entries, nil
+}
+
+// ResolveReports resolves the open reports of a movie and returns their number.
+func (r *Repository) ResolveReports(_ context.Context, movieID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for i := range r.reports {
+		if r.reports[i].MovieID == movieID && r.reports[i].Status == moderation.ReportOpen {
+			r.reports[i].Status = moderation.ReportResolved
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// isHidden reports whether the movie is hidden, r.mu must be held.
+func (r *Repository) isHidden(movieID int) bool {
+	_, ok := r.hidden[movieID]
+	return ok
+}
+
+// list returns the movies of userID, or of every user when zero, sorted like the SQL repository.
+func (r *Repository) list(userID, authUsrID int, sortType string) []movie.Movie {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var movies []movie.Movie
+	for _, m := range r.movies {
+		if r.deleted[*m.ID] || r.isHidden(*m.ID) {
+			continue
+		}
+		if userID == 0 || m.UserID == userID {
+			movies = append(movies, r.movie(m, authUsrID))
+		}
+	}
+
+	column := movie.ConvertSortTypeToOrderByColumn(sortType)
+	sort.Slice(movies, func(i, j int) bool {
+		a, b := movies[i], movies[j]
+		switch {
+		case column == movie.TypeOrderByLikes && a.Likes != b.Likes:
+			return a.Likes > b.Likes
+		case column == movie.TypeOrderByHates && a.Hates != b.Hates:
+			return a.Hates > b.Hates
+		case column == movie.TypeOrderByCreatedDate && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+
+		return a.ID > b.ID
+	})
+
+	return movies
+}
+
+// movie returns the stored movie with its votes and author, r.mu must be held.
+func (r *Repository) movie(m movie.SQLMovie, authUsrID int) movie.Movie {
+	res := movie.Movie{
+		ID:          *m.ID,
+		UserID:      m.UserID,
+		Title:       m.Title,
+		Description: m.Description,
+		CreatedAt:   m.CreatedAt,
+	}
+	for userID, action := range r.actions[res.ID] {
+		switch action {
+		case actionLike:
+			res.Likes++
+			res.UserLiked = res.UserLiked || userID == authUsrID
+		case actionHate:
+			res.Hates++
+			res.UserHated = res.UserHated || userID == authUsrID
+		}
+	}
+	if m.UserID >= 1 && m.UserID <= len(r.users) {
+		u := r.users[m.UserID-1]
+		res.PostedBy = u.FirstName + " " + u.LastName
+	}
+
+	return res
+}
@@ -0,0 +1,46 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/infra/repository/memory"
+	"movierama/internal/infra/repository/repotest"
+	"movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"sync"
+	"testing"
+)
+
+func TestRepository_Contract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		r := memory.NewRepository()
+
+		return repotest.Repositories{Movies: r, Users: r, AdminUsers: r, AdminMovies: r, Moderation: r, Reports: r}
+	})
+}
+
+func TestRepository_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	r := memory.NewRepository()
+	require.NoError(t, r.CreateUser(ctx, &user.SQLUser{Username: "alice"}))
+	require.NoError(t, r.CreateMovie(ctx, &movie.SQLMovie{UserID: 1, Title: "Alien"}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, r.CreateUser(ctx, &user.SQLUser{Username: fmt.Sprintf("user%d", i)}))
+			assert.NoError(t, r.AddMovieAction(ctx, 1, i+2, "like"))
+			_, err := r.GetMovies(ctx, i+2, movie.SortCaseLikes)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	m, err := r.GetMovie(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 50, m.Likes)
+}
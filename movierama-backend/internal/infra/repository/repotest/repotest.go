@@ -0,0 +1,924 @@
+// Package repotest provides the contract that every implementation of the repositories must pass.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/app/admin"
+	"movierama/internal/app/auth"
+	movieapp "movierama/internal/app/movie"
+	"movierama/internal/infra/repository/sql/moderation"
+	"movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Repositories are the implementations under test, they must share the same store.
+type Repositories struct {
+	Movies      movieapp.Repository
+	Users       auth.Repository
+	AdminUsers  admin.UserRepository
+	AdminMovies admin.MovieRepository
+	Moderation  admin.ActionRepository
+	Reports     admin.ReportRepository
+}
+
+// Run runs the contract against the repositories returned by newRepos, which is called
+// for every test and must return repositories over an empty store.
+func Run(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	tests := map[string]func(t *testing.T, r Repositories){
+		"should create and find users":                     testUsers,
+		"should reject duplicate usernames":                testDuplicateUsername,
+		"should update passwords":                          testUpdatePassword,
+		"should link the identities":                       testIdentities,
+		"should verify the emails":                         testEmails,
+		"should store the access tokens":                   testAccessTokens,
+		"should record and filter auth events":             testAuthEvents,
+		"should search the accounts":                       testSearchAccounts,
+		"should update roles and statuses":                 testRoleAndStatus,
+		"should bootstrap a role once":                     testBootstrapRole,
+		"should delete movies with their votes":            testDeleteMovie,
+		"should record moderation actions":                 testModeration,
+		"should hide movies from the listings":             testHiddenMovies,
+		"should queue the reported movies":                 testReports,
+		"should create and list movies":                    testMovies,
+		"should sort the movies":                           testSort,
+		"should count the recent movies of users":          testCountUserMovies,
+		"should filter the movies of a user":               testUserMovies,
+		"should count the votes of the users":              testVotes,
+		"should allow one action per user":                 testDuplicateAction,
+		"should set the vote of a user":                    testSetAction,
+		"should not find an unknown movie":                 testMovieNotFound,
+		"should return no movies of empty store":           testEmpty,
+		"should version the writes of the movies by scope": testChanges,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepos(t))
+		})
+	}
+}
+
+// seed creates the users and the movies, the movies are created in order by user.
+func seed(t *testing.T, r Repositories, users []string, movies map[string][]string) map[string]int {
+	ctx := context.Background()
+	ids := make(map[string]int)
+	for _, username := range users {
+		u := &user.SQLUser{Username: username, Password: "hash", FirstName: username, LastName: "Doe"}
+		require.NoError(t, r.Users.CreateUser(ctx, u))
+		require.NotNil(t, u.ID, "the id of the created user is set")
+		ids[username] = *u.ID
+	}
+	for _, username := range users {
+		for _, title := range movies[username] {
+			require.NoError(t, r.Movies.CreateMovie(ctx, &movie.SQLMovie{
+				UserID: ids[username], Title: title, Description: title + " description",
+			}))
+		}
+	}
+
+	return ids
+}
+
+// titles returns the titles of the movies, with the ids of the movies by title.
+func titles(t *testing.T, movies []movie.Movie, ids map[string]int) []string {
+	var res []string
+	for _, m := range movies {
+		res = append(res, m.Title)
+		if ids != nil {
+			ids[m.Title] = m.ID
+		}
+	}
+
+	return res
+}
+
+func testUsers(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, nil)
+
+	u, err := r.Users.GetUserAuthDetails(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, &user.AuthUserDetails{ID: ids["alice"], Username: "alice", Password: "hash", Role: "user", Status: "active"}, u)
+
+	_, err = r.Users.GetUserAuthDetails(ctx, "carol")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	users, err := r.Users.GetUsers(ctx, []int{ids["bob"], ids["alice"], 999})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []user.User{
+		{ID: ids["alice"], Username: "alice", FirstName: "alice", LastName: "Doe"},
+		{ID: ids["bob"], Username: "bob", FirstName: "bob", LastName: "Doe"},
+	}, users)
+
+	users, err = r.Users.GetUsers(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func testDuplicateUsername(t *testing.T, r Repositories) {
+	seed(t, r, []string{"alice"}, nil)
+
+	err := r.Users.CreateUser(context.Background(), &user.SQLUser{Username: "alice", Password: "hash"})
+	assert.ErrorIs(t, err, user.ErrDuplicateUsername)
+}
+
+func testIdentities(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, nil)
+
+	_, err := r.Users.GetIdentityUserID(ctx, "github", "1001")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	id := &user.Identity{UserID: ids["alice"], Provider: "github", Subject: "1001", Email: "alice@example.com"}
+	require.NoError(t, r.Users.AddIdentity(ctx, id))
+	assert.NotZero(t, id.ID)
+	require.NoError(t, r.Users.AddIdentity(ctx, &user.Identity{UserID: ids["alice"], Provider: "google", Subject: "1001"}))
+
+	userID, err := r.Users.GetIdentityUserID(ctx, "github", "1001")
+	require.NoError(t, err)
+	assert.Equal(t, ids["alice"], userID)
+
+	// An identity is linked once, and a user has one identity per provider.
+	err = r.Users.AddIdentity(ctx, &user.Identity{UserID: ids["bob"], Provider: "github", Subject: "1001"})
+	assert.ErrorIs(t, err, user.ErrDuplicateIdentity)
+	err = r.Users.AddIdentity(ctx, &user.Identity{UserID: ids["alice"], Provider: "github", Subject: "1002"})
+	assert.ErrorIs(t, err, user.ErrDuplicateIdentity)
+}
+
+func testEmails(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice"}, nil)
+	email := "bob@example.com"
+	verifiedAt := time.Now().UTC().Truncate(time.Second)
+	bob := &user.SQLUser{Username: "bob", Password: "hash", FirstName: "bob", LastName: "smith", Email: &email, EmailVerifiedAt: &verifiedAt}
+	require.NoError(t, r.Users.CreateUser(ctx, bob))
+
+	u, err := r.Users.GetUserAuthDetailsByEmail(ctx, email)
+	require.NoError(t, err)
+	assert.Equal(t, *bob.ID, u.ID)
+	if assert.NotNil(t, u.EmailVerifiedAt) {
+		assert.WithinDuration(t, verifiedAt, *u.EmailVerifiedAt, time.Second)
+	}
+	_, err = r.Users.GetUserAuthDetailsByEmail(ctx, "carol@example.com")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// An email belongs to one user.
+	err = r.Users.CreateUser(ctx, &user.SQLUser{Username: "carol", Password: "hash", FirstName: "c", LastName: "c", Email: &email})
+	assert.ErrorIs(t, err, user.ErrDuplicateEmail)
+	assert.ErrorIs(t, r.Users.UpdateEmail(ctx, ids["alice"], email), user.ErrDuplicateEmail)
+
+	require.NoError(t, r.Users.UpdateEmail(ctx, ids["alice"], "alice@example.com"))
+	u, err = r.Users.GetUserAuthDetailsByID(ctx, ids["alice"])
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", *u.Email)
+	assert.Nil(t, u.EmailVerifiedAt)
+
+	// The verification of a former email is ignored.
+	require.NoError(t, r.Users.VerifyEmail(ctx, ids["alice"], "former@example.com", verifiedAt))
+	u, err = r.Users.GetUserAuthDetailsByID(ctx, ids["alice"])
+	require.NoError(t, err)
+	assert.Nil(t, u.EmailVerifiedAt)
+
+	require.NoError(t, r.Users.VerifyEmail(ctx, ids["alice"], "alice@example.com", verifiedAt))
+	u, err = r.Users.GetUserAuthDetailsByEmail(ctx, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, ids["alice"], u.ID)
+	if assert.NotNil(t, u.EmailVerifiedAt) {
+		assert.WithinDuration(t, verifiedAt, *u.EmailVerifiedAt, time.Second)
+	}
+
+	// Changing the email drops its verification.
+	require.NoError(t, r.Users.UpdateEmail(ctx, *bob.ID, "robert@example.com"))
+	u, err = r.Users.GetUserAuthDetailsByID(ctx, *bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "robert@example.com", *u.Email)
+	assert.Nil(t, u.EmailVerifiedAt)
+}
+
+func testAccessTokens(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, nil)
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	expiresAt := createdAt.Add(24 * time.Hour)
+
+	token := &user.AccessToken{UserID: ids["alice"], Name: "bot", TokenHash: strings.Repeat("a", 64),
+		Scopes: "movies:write,votes:write", ExpiresAt: &expiresAt, CreatedAt: createdAt}
+	require.NoError(t, r.Users.AddAccessToken(ctx, token))
+	assert.NotZero(t, token.ID)
+	require.NoError(t, r.Users.AddAccessToken(ctx, &user.AccessToken{UserID: ids["alice"], Name: "script",
+		TokenHash: strings.Repeat("b", 64), Scopes: "movies:read", CreatedAt: createdAt}))
+
+	got, err := r.Users.GetAccessTokenByHash(ctx, strings.Repeat("a", 64))
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, got.ID)
+	assert.Equal(t, "bot", got.Name)
+	assert.Equal(t, "movies:write,votes:write", got.Scopes)
+	assert.Nil(t, got.LastUsedAt)
+	if assert.NotNil(t, got.ExpiresAt) {
+		assert.WithinDuration(t, expiresAt, *got.ExpiresAt, time.Second)
+	}
+	_, err = r.Users.GetAccessTokenByHash(ctx, strings.Repeat("c", 64))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	usedAt := createdAt.Add(time.Minute)
+	require.NoError(t, r.Users.TouchAccessToken(ctx, token.ID, usedAt))
+	tokens, err := r.Users.GetAccessTokens(ctx, ids["alice"])
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, []string{"bot", "script"}, []string{tokens[0].Name, tokens[1].Name})
+	if assert.NotNil(t, tokens[0].LastUsedAt) {
+		assert.WithinDuration(t, usedAt, *tokens[0].LastUsedAt, time.Second)
+	}
+	assert.Nil(t, tokens[1].ExpiresAt)
+
+	// A token is deleted by its user only.
+	assert.ErrorIs(t, r.Users.DeleteAccessToken(ctx, ids["bob"], token.ID), sql.ErrNoRows)
+	require.NoError(t, r.Users.DeleteAccessToken(ctx, ids["alice"], token.ID))
+	assert.ErrorIs(t, r.Users.DeleteAccessToken(ctx, ids["alice"], token.ID), sql.ErrNoRows)
+	_, err = r.Users.GetAccessTokenByHash(ctx, strings.Repeat("a", 64))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	tokens, err = r.Users.GetAccessTokens(ctx, ids["bob"])
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+}
+
+func testUpdatePassword(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, nil)
+
+	require.NoError(t, r.Users.UpdatePassword(ctx, ids["alice"], "new hash"))
+
+	u, err := r.Users.GetUserAuthDetailsByID(ctx, ids["alice"])
+	require.NoError(t, err)
+	assert.Equal(t, &user.AuthUserDetails{ID: ids["alice"], Username: "alice", Password: "new hash", Role: "user", Status: "active",
+		TokenVersion: 1}, u)
+	u, err = r.Users.GetUserAuthDetailsByID(ctx, ids["bob"])
+	require.NoError(t, err)
+	assert.Equal(t, "hash", u.Password)
+	assert.Zero(t, u.TokenVersion)
+
+	_, err = r.Users.GetUserAuthDetailsByID(ctx, 999)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testAuthEvents(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice"}, nil)
+	alice := ids["alice"]
+
+	var eventIDs []int
+	for _, e := range []user.AuthEvent{
+		{UserID: &alice, Username: "alice", Event: "registered", IP: "10.1.0.1", UserAgent: "firefox"},
+		{Username: "mallory", Event: "login_failed", IP: "10.6.6.6", UserAgent: "curl"},
+		{UserID: &alice, Username: "alice", Event: "login_failed", IP: "10.6.6.6", UserAgent: "curl"},
+		{UserID: &alice, Username: "alice", Event: "login_succeeded", IP: "10.1.0.1", UserAgent: "firefox"},
+	} {
+		e := e
+		require.NoError(t, r.Users.AddAuthEvent(ctx, &e))
+		require.NotZero(t, e.ID, "the id of the event is set")
+		eventIDs = append(eventIDs, e.ID)
+	}
+
+	events, err := r.Users.GetAuthEvents(ctx, user.AuthEventFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	assert.Equal(t, eventIDs[3], events[0].ID, "the newest event comes first")
+	assert.Equal(t, &alice, events[0].UserID)
+	assert.Equal(t, "login_succeeded", events[0].Event)
+	assert.Equal(t, "firefox", events[0].UserAgent)
+	assert.False(t, events[0].CreatedAt.IsZero())
+	assert.Nil(t, events[2].UserID)
+
+	for name, tt := range map[string]struct {
+		filter user.AuthEventFilter
+		expIDs []int
+	}{
+		"user":     {user.AuthEventFilter{UserID: alice, Limit: 10}, []int{eventIDs[3], eventIDs[2], eventIDs[0]}},
+		"username": {user.AuthEventFilter{Username: "mallory", Limit: 10}, []int{eventIDs[1]}},
+		"ip":       {user.AuthEventFilter{IP: "10.6.6.6", Limit: 10}, []int{eventIDs[2], eventIDs[1]}},
+		"event":    {user.AuthEventFilter{UserID: alice, Event: "login_failed", Limit: 10}, []int{eventIDs[2]}},
+		"page":     {user.AuthEventFilter{BeforeID: eventIDs[2], Limit: 1}, []int{eventIDs[1]}},
+	} {
+		events, err := r.Users.GetAuthEvents(ctx, tt.filter)
+		require.NoError(t, err, name)
+		var got []int
+		for _, e := range events {
+			got = append(got, e.ID)
+		}
+		assert.Equal(t, tt.expIDs, got, name)
+	}
+}
+
+func testSearchAccounts(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob", "malice", "under_score"}, nil)
+	require.NoError(t, r.AdminUsers.UpdateRole(ctx, ids["bob"], "moderator"))
+	require.NoError(t, r.AdminUsers.UpdateStatus(ctx, ids["malice"], "banned", nil))
+
+	accounts, err := r.AdminUsers.SearchAccounts(ctx, user.AccountFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, accounts, 4)
+	assert.Equal(t, user.Account{
+		ID: ids["alice"], Username: "alice", FirstName: "alice", LastName: "Doe", Role: "user", Status: "active",
+	}, accounts[0])
+
+	for name, tt := range map[string]struct {
+		filter       user.AccountFilter
+		expUsernames []string
+	}{
+		"query":           {user.AccountFilter{Query: "LIC", Limit: 10}, []string{"alice", "malice"}},
+		"last name":       {user.AccountFilter{Query: "doe", Limit: 1}, []string{"alice"}},
+		"escaped query":   {user.AccountFilter{Query: "_", Limit: 10}, []string{"under_score"}},
+		"role":            {user.AccountFilter{Role: "moderator", Limit: 10}, []string{"bob"}},
+		"status":          {user.AccountFilter{Status: "banned", Limit: 10}, []string{"malice"}},
+		"page":            {user.AccountFilter{AfterID: ids["bob"], Limit: 1}, []string{"malice"}},
+		"no match":        {user.AccountFilter{Query: "carol", Limit: 10}, nil},
+		"query and role":  {user.AccountFilter{Query: "alice", Role: "moderator", Limit: 10}, nil},
+		"query and state": {user.AccountFilter{Query: "alice", Status: "active", Limit: 10}, []string{"alice"}},
+	} {
+		accounts, err := r.AdminUsers.SearchAccounts(ctx, tt.filter)
+		require.NoError(t, err, name)
+		var got []string
+		for _, a := range accounts {
+			got = append(got, a.Username)
+		}
+		assert.Equal(t, tt.expUsernames, got, name)
+	}
+}
+
+func testBootstrapRole(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, nil)
+
+	promoted, err := r.Users.BootstrapRole(ctx, ids["alice"], "admin")
+	require.NoError(t, err)
+	assert.True(t, promoted)
+	promoted, err = r.Users.BootstrapRole(ctx, ids["alice"], "admin")
+	require.NoError(t, err)
+	assert.False(t, promoted)
+
+	// A demoted user keeps the role the admin gave.
+	require.NoError(t, r.AdminUsers.UpdateRole(ctx, ids["alice"], "user"))
+	promoted, err = r.Users.BootstrapRole(ctx, ids["alice"], "admin")
+	require.NoError(t, err)
+	assert.False(t, promoted)
+	u, err := r.Users.GetUserAuthDetailsByID(ctx, ids["alice"])
+	require.NoError(t, err)
+	assert.Equal(t, "user", u.Role)
+
+	require.NoError(t, r.AdminUsers.UpdateRole(ctx, ids["bob"], "moderator"))
+	promoted, err = r.Users.BootstrapRole(ctx, ids["bob"], "admin")
+	require.NoError(t, err)
+	assert.False(t, promoted)
+}
+
+func testRoleAndStatus(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, nil)
+	until := time.Date(2026, 10, 21, 9, 30, 0, 0, time.UTC)
+
+	require.NoError(t, r.AdminUsers.UpdateRole(ctx, ids["alice"], "admin"))
+	require.NoError(t, r.AdminUsers.UpdateStatus(ctx, ids["bob"], "suspended", &until))
+
+	u, err := r.Users.GetUserAuthDetails(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", u.Role)
+	assert.Equal(t, "active", u.Status)
+	assert.Nil(t, u.SuspendedUntil)
+
+	u, err = r.AdminUsers.GetUserAuthDetailsByID(ctx, ids["bob"])
+	require.NoError(t, err)
+	assert.Equal(t, "user", u.Role)
+	assert.Equal(t, "suspended", u.Status)
+	if assert.NotNil(t, u.SuspendedUntil) {
+		assert.True(t, until.Equal(*u.SuspendedUntil), "suspended until %s", u.SuspendedUntil)
+	}
+
+	// Reinstating clears the end of the suspension.
+	require.NoError(t, r.AdminUsers.UpdateStatus(ctx, ids["bob"], "active", nil))
+	u, err = r.Users.GetUserAuthDetails(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "active", u.Status)
+	assert.Nil(t, u.SuspendedUntil)
+}
+
+func testDeleteMovie(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, map[string][]string{"alice": {"Alien", "Brazil"}})
+	byTitle := make(map[string]int)
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	titles(t, movies, byTitle)
+	require.NoError(t, r.Movies.AddMovieAction(ctx, byTitle["Alien"], ids["bob"], "like"))
+	require.NoError(t, r.Movies.AddMovieAction(ctx, byTitle["Brazil"], ids["bob"], "hate"))
+
+	require.NoError(t, r.AdminMovies.DeleteMovie(ctx, byTitle["Alien"]))
+
+	_, err = r.AdminMovies.GetAnyMovie(ctx, byTitle["Alien"])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	movies, err = r.Movies.GetMovies(ctx, ids["bob"], movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Brazil"}, titles(t, movies, nil))
+	assert.Equal(t, 1, movies[0].Hates, "the votes of the other movies are kept")
+}
+
+func testModeration(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, nil)
+	alice, bob, movieID := ids["alice"], ids["bob"], 42
+
+	var actionIDs []int
+	for _, a := range []moderation.Action{
+		{ModeratorID: alice, Action: "suspend", UserID: &bob, Detail: "2026-10-21T00:00:00Z", Reason: "rude"},
+		{ModeratorID: alice, Action: "remove_movie", UserID: &bob, MovieID: &movieID, MovieTitle: "Spam", Reason: "spam"},
+		{ModeratorID: bob, Action: "set_role", UserID: &alice, Detail: "moderator", Reason: "trusted"},
+	} {
+		a := a
+		require.NoError(t, r.Moderation.AddAction(ctx, &a))
+		require.NotZero(t, a.ID, "the id of the action is set")
+		actionIDs = append(actionIDs, a.ID)
+	}
+
+	actions, err := r.Moderation.GetActions(ctx, moderation.ActionFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, actions, 3)
+	assert.Equal(t, actionIDs[2], actions[0].ID, "the newest action comes first")
+	assert.Equal(t, "moderator", actions[0].Detail)
+	assert.Nil(t, actions[0].MovieID)
+	assert.Equal(t, &movieID, actions[1].MovieID)
+	assert.Equal(t, "Spam", actions[1].MovieTitle)
+	assert.False(t, actions[1].CreatedAt.IsZero())
+
+	for name, tt := range map[string]struct {
+		filter moderation.ActionFilter
+		expIDs []int
+	}{
+		"moderator": {moderation.ActionFilter{ModeratorID: alice, Limit: 10}, []int{actionIDs[1], actionIDs[0]}},
+		"user":      {moderation.ActionFilter{UserID: bob, Limit: 10}, []int{actionIDs[1], actionIDs[0]}},
+		"movie":     {moderation.ActionFilter{MovieID: movieID, Limit: 10}, []int{actionIDs[1]}},
+		"action":    {moderation.ActionFilter{Action: "set_role", Limit: 10}, []int{actionIDs[2]}},
+		"page":      {moderation.ActionFilter{BeforeID: actionIDs[2], Limit: 1}, []int{actionIDs[1]}},
+	} {
+		actions, err := r.Moderation.GetActions(ctx, tt.filter)
+		require.NoError(t, err, name)
+		var got []int
+		for _, a := range actions {
+			got = append(got, a.ID)
+		}
+		assert.Equal(t, tt.expIDs, got, name)
+	}
+}
+
+func testMergeMovies(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob", "carol", "dave", "erin"},
+		map[string][]string{"alice": {"The Matrix"}, "bob": {"Matrix, The"}})
+	byTitle := make(map[string]int)
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	titles(t, movies, byTitle)
+	into, merged := byTitle["The Matrix"], byTitle["Matrix, The"]
+	for username, votes := range map[string][2]string{
+		// The votes for both movies keep the vote for the movie merged into.
+		"carol": {"like", "hate"},
+		"dave":  {"", "like"},
+		"erin":  {"", "hate"},
+		// The author of the movie merged into cannot vote for it.
+		"alice": {"", "like"},
+	} {
+		if votes[0] != "" {
+			require.NoError(t, r.Movies.AddMovieAction(ctx, into, ids[username], votes[0]))
+		}
+		require.NoError(t, r.Movies.AddMovieAction(ctx, merged, ids[username], votes[1]))
+	}
+	require.NoError(t, r.Reports.AddReport(ctx, &moderation.Report{MovieID: merged, ReporterID: ids["carol"], Category: "spam"}))
+
+	moved, err := r.AdminMovies.MergeMovies(ctx, merged, into)
+	require.NoError(t, err)
+	assert.Equal(t, 2, moved)
+
+	_, err = r.AdminMovies.GetAnyMovie(ctx, merged)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	m, err := r.Movies.GetMovie(ctx, into, ids["carol"])
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.Likes)
+	assert.Equal(t, 1, m.Hates)
+	assert.True(t, m.UserLiked)
+	n, err := r.Reports.CountReports(ctx, merged, "")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func testSimilarTitles(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice"}, nil)
+	var created []int
+	for _, m := range []struct{ title, key string }{
+		{"Alien", "alien"}, {"Aliens", "aliens"}, {"Brazil", "brazil"}, {"Casablanca", "casablanca"}, {"Legacy", ""},
+		{"Alien Nation", "alien nation"}, {"Hidden Alien", "hidden alien"},
+	} {
+		sm := &movie.SQLMovie{UserID: ids["alice"], Title: m.title, TitleKey: m.key}
+		require.NoError(t, r.Movies.CreateMovie(ctx, sm))
+		created = append(created, *sm.ID)
+	}
+	require.NoError(t, r.AdminMovies.SetHidden(ctx, created[6], true))
+
+	// The titles sharing the prefix or the suffix of the key, and the ones without key.
+	got, err := r.Movies.GetSimilarTitles(ctx, "alian")
+	require.NoError(t, err)
+	exp := []movie.Title{
+		{ID: created[0], Title: "Alien", Key: "alien"},
+		{ID: created[1], Title: "Aliens", Key: "aliens"},
+		{ID: created[4], Title: "Legacy"},
+		{ID: created[5], Title: "Alien Nation", Key: "alien nation"},
+	}
+	assert.Equal(t, exp, got)
+
+	require.NoError(t, r.Movies.SetTitleKeys(ctx, map[int]string{created[4]: "legacy"}))
+	got, err = r.Movies.GetSimilarTitles(ctx, "casa blanca")
+	require.NoError(t, err)
+	assert.Equal(t, []movie.Title{{ID: created[3], Title: "Casablanca", Key: "casablanca"}}, got)
+	got, err = r.Movies.GetSimilarTitles(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, []movie.Title{{ID: created[4], Title: "Legacy", Key: "legacy"}}, got)
+}
+
+func testHiddenMovies(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, map[string][]string{"alice": {"Alien", "Brazil"}})
+	byTitle := make(map[string]int)
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	titles(t, movies, byTitle)
+
+	require.NoError(t, r.AdminMovies.SetHidden(ctx, byTitle["Alien"], true))
+
+	for name, list := range map[string]func() ([]movie.Movie, error){
+		"public": func() ([]movie.Movie, error) { return r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate) },
+		"auth":   func() ([]movie.Movie, error) { return r.Movies.GetMovies(ctx, ids["bob"], movie.SortCaseDate) },
+		"user": func() ([]movie.Movie, error) {
+			return r.Movies.GetUserMovies(ctx, ids["alice"], ids["bob"], movie.SortCaseLikes)
+		},
+		"user public": func() ([]movie.Movie, error) {
+			return r.Movies.GetUserMoviesPublic(ctx, ids["alice"], movie.SortCaseHates)
+		},
+	} {
+		movies, err := list()
+		require.NoError(t, err, name)
+		assert.Equal(t, []string{"Brazil"}, titles(t, movies, nil), name)
+	}
+	_, err = r.Movies.GetMovie(ctx, byTitle["Alien"], ids["bob"])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	m, err := r.AdminMovies.GetAnyMovie(ctx, byTitle["Alien"])
+	require.NoError(t, err)
+	assert.Equal(t, "Alien", m.Title)
+	assert.NotNil(t, m.HiddenAt)
+
+	require.NoError(t, r.AdminMovies.SetHidden(ctx, byTitle["Alien"], false))
+	movies, err = r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Brazil", "Alien"}, titles(t, movies, nil))
+	m, err = r.AdminMovies.GetAnyMovie(ctx, byTitle["Alien"])
+	require.NoError(t, err)
+	assert.Nil(t, m.HiddenAt)
+}
+
+func testReports(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob", "carol"}, map[string][]string{"alice": {"Alien", "Brazil"}})
+	byTitle := make(map[string]int)
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	titles(t, movies, byTitle)
+	alien, brazil := byTitle["Alien"], byTitle["Brazil"]
+
+	for _, rep := range []moderation.Report{
+		{MovieID: alien, ReporterID: ids["bob"], Category: "spam"},
+		{MovieID: alien, ReporterID: ids["carol"], Category: "offensive"},
+		{MovieID: brazil, ReporterID: ids["bob"], Category: "other"},
+	} {
+		rep := rep
+		require.NoError(t, r.Reports.AddReport(ctx, &rep))
+		require.NotZero(t, rep.ID, "the id of the report is set")
+	}
+	err = r.Reports.AddReport(ctx, &moderation.Report{MovieID: alien, ReporterID: ids["bob"], Category: "other"})
+	assert.ErrorIs(t, err, moderation.ErrDuplicateReport)
+	for i := 0; i < 2; i++ {
+		flag := &moderation.Report{MovieID: alien, Source: moderation.SourceFilter, Category: "spam"}
+		require.NoError(t, r.Reports.AddReport(ctx, flag), "the flags of a movie do not collide")
+	}
+
+	count, err := r.Reports.CountReports(ctx, alien, moderation.SourceUser)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "the flags are not reports of the users")
+	count, err = r.Reports.CountReports(ctx, alien, "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	require.NoError(t, r.AdminMovies.SetHidden(ctx, alien, true))
+	queue, err := r.Reports.GetQueue(ctx, moderation.QueueFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+	assert.NotNil(t, queue[0].HiddenAt, "the hidden movies stay in the queue")
+	queue[0].HiddenAt = nil
+	assert.Equal(t, []moderation.QueueEntry{
+		{MovieID: alien, Title: "Alien", UserID: ids["alice"], Reports: 4, Categories: map[string]int{"spam": 3, "offensive": 1}},
+		{MovieID: brazil, Title: "Brazil", UserID: ids["alice"], Reports: 1, Categories: map[string]int{"other": 1}},
+	}, queue, "the most reported movie comes first")
+
+	queue, err = r.Reports.GetQueue(ctx, moderation.QueueFilter{Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.Equal(t, brazil, queue[0].MovieID)
+
+	n, err := r.Reports.ResolveReports(ctx, alien)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	n, err = r.Reports.ResolveReports(ctx, alien)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	count, err = r.Reports.CountReports(ctx, alien, "")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	err = r.Reports.AddReport(ctx, &moderation.Report{MovieID: alien, ReporterID: ids["carol"], Category: "spam"})
+	assert.ErrorIs(t, err, moderation.ErrDuplicateReport, "a resolved report is not reopened")
+
+	require.NoError(t, r.AdminMovies.DeleteMovie(ctx, brazil))
+	queue, err = r.Reports.GetQueue(ctx, moderation.QueueFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, queue)
+}
+
+func testMovies(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice"}, nil)
+	created := &movie.SQLMovie{UserID: ids["alice"], Title: "Alien", Description: "Alien description"}
+	require.NoError(t, r.Movies.CreateMovie(ctx, created))
+	require.NotNil(t, created.ID, "the id of the created movie is set")
+
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	require.Len(t, movies, 1)
+	m := movies[0]
+	assert.Equal(t, *created.ID, m.ID)
+	assert.Equal(t, ids["alice"], m.UserID)
+	assert.Equal(t, "Alien", m.Title)
+	assert.Equal(t, "Alien description", m.Description)
+	assert.Equal(t, "alice Doe", m.PostedBy)
+	assert.False(t, m.CreatedAt.IsZero())
+
+	got, err := r.Movies.GetMovie(ctx, m.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, m, *got)
+}
+
+func testCountUserMovies(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, map[string][]string{"alice": {"Alien", "Brazil"}, "bob": {"Casablanca"}})
+
+	n, err := r.Movies.CountUserMoviesSince(ctx, ids["alice"], time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	n, err = r.Movies.CountUserMoviesSince(ctx, ids["bob"], time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = r.Movies.CountUserMoviesSince(ctx, ids["alice"], time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func testSort(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob", "carol", "dave"},
+		map[string][]string{"alice": {"Alien", "Brazil", "Casablanca"}})
+	byTitle := make(map[string]int)
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	// The movies created within the same second are sorted by id.
+	assert.Equal(t, []string{"Casablanca", "Brazil", "Alien"}, titles(t, movies, byTitle))
+
+	for username, votes := range map[string]map[string]string{
+		"bob":   {"Alien": "like", "Brazil": "like", "Casablanca": "hate"},
+		"carol": {"Brazil": "like", "Casablanca": "hate"},
+		"dave":  {"Alien": "hate"},
+	} {
+		for title, action := range votes {
+			require.NoError(t, r.Movies.AddMovieAction(ctx, byTitle[title], ids[username], action))
+		}
+	}
+
+	tests := map[string][]string{
+		movie.SortCaseLikes: {"Brazil", "Alien", "Casablanca"},
+		movie.SortCaseHates: {"Casablanca", "Alien", "Brazil"},
+		movie.SortCaseDate:  {"Casablanca", "Brazil", "Alien"},
+		"unknown":           {"Casablanca", "Brazil", "Alien"},
+	}
+	for sortType, exp := range tests {
+		movies, err := r.Movies.GetMoviesPublic(ctx, sortType)
+		require.NoError(t, err)
+		assert.Equal(t, exp, titles(t, movies, nil), "public sorted by %s", sortType)
+
+		movies, err = r.Movies.GetMovies(ctx, ids["bob"], sortType)
+		require.NoError(t, err)
+		assert.Equal(t, exp, titles(t, movies, nil), "sorted by %s", sortType)
+	}
+}
+
+func testUserMovies(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"},
+		map[string][]string{"alice": {"Alien", "Brazil"}, "bob": {"Casablanca"}})
+
+	movies, err := r.Movies.GetUserMoviesPublic(ctx, ids["alice"], movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Brazil", "Alien"}, titles(t, movies, nil))
+
+	movies, err = r.Movies.GetUserMovies(ctx, ids["bob"], ids["alice"], movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Casablanca"}, titles(t, movies, nil))
+
+	movies, err = r.Movies.GetUserMoviesPublic(ctx, 999, movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.Empty(t, movies)
+}
+
+func testVotes(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob", "carol"}, map[string][]string{"alice": {"Alien"}})
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	movieID := movies[0].ID
+
+	require.NoError(t, r.Movies.AddMovieAction(ctx, movieID, ids["bob"], "like"))
+	require.NoError(t, r.Movies.AddMovieAction(ctx, movieID, ids["carol"], "hate"))
+
+	m, err := r.Movies.GetMovie(ctx, movieID, ids["bob"])
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.Likes)
+	assert.Equal(t, 1, m.Hates)
+	assert.True(t, m.UserLiked)
+	assert.False(t, m.UserHated)
+
+	movies, err = r.Movies.GetMovies(ctx, ids["carol"], movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.False(t, movies[0].UserLiked)
+	assert.True(t, movies[0].UserHated)
+
+	movies, err = r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.False(t, movies[0].UserLiked || movies[0].UserHated, "anonymous users have no votes")
+
+	// Removing another action than the current one leaves it.
+	require.NoError(t, r.Movies.RemoveMovieAction(ctx, movieID, ids["bob"], "hate"))
+	require.NoError(t, r.Movies.RemoveMovieAction(ctx, movieID, ids["carol"], "hate"))
+	m, err = r.Movies.GetMovie(ctx, movieID, ids["carol"])
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.Likes)
+	assert.Equal(t, 0, m.Hates)
+	assert.False(t, m.UserHated)
+}
+
+func testDuplicateAction(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, map[string][]string{"alice": {"Alien"}})
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+
+	require.NoError(t, r.Movies.AddMovieAction(ctx, movies[0].ID, ids["bob"], "like"))
+	assert.Error(t, r.Movies.AddMovieAction(ctx, movies[0].ID, ids["bob"], "hate"))
+}
+
+func testSetAction(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob"}, map[string][]string{"alice": {"Alien"}})
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	movieID := movies[0].ID
+
+	steps := []struct {
+		action, expPrevious string
+		expLikes, expHates  int
+	}{
+		{action: "like", expLikes: 1},
+		{action: "like", expPrevious: "like", expLikes: 1},
+		{action: "hate", expPrevious: "like", expHates: 1},
+		{action: "", expPrevious: "hate"},
+		{action: "", expPrevious: ""},
+	}
+	for _, step := range steps {
+		previous, err := r.Movies.SetMovieAction(ctx, movieID, ids["bob"], step.action)
+		require.NoError(t, err)
+		assert.Equal(t, step.expPrevious, previous, "vote %q", step.action)
+		m, err := r.Movies.GetMovie(ctx, movieID, ids["bob"])
+		require.NoError(t, err)
+		assert.Equal(t, step.expLikes, m.Likes, "vote %q", step.action)
+		assert.Equal(t, step.expHates, m.Hates, "vote %q", step.action)
+	}
+
+	_, err = r.Movies.SetMovieAction(ctx, movieID, ids["alice"], "like")
+	assert.ErrorIs(t, err, movie.ErrOwnMovie)
+	_, err = r.Movies.SetMovieAction(ctx, 999, ids["bob"], "like")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, r.AdminMovies.SetHidden(ctx, movieID, true))
+	_, err = r.Movies.SetMovieAction(ctx, movieID, ids["bob"], "like")
+	assert.ErrorIs(t, err, sql.ErrNoRows, "the hidden movies take no votes")
+}
+
+func testMovieNotFound(t *testing.T, r Repositories) {
+	_, err := r.Movies.GetMovie(context.Background(), 999, 0)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testEmpty(t *testing.T, r Repositories) {
+	movies, err := r.Movies.GetMoviesPublic(context.Background(), movie.SortCaseDate)
+	require.NoError(t, err)
+	assert.Empty(t, movies)
+}
+
+func testChanges(t *testing.T, r Repositories) {
+	ctx := context.Background()
+	ids := seed(t, r, []string{"alice", "bob", "carol"},
+		map[string][]string{"alice": {"Alien", "Brazil"}, "carol": {"Casablanca"}})
+	movies, err := r.Movies.GetMoviesPublic(ctx, movie.SortCaseDate)
+	require.NoError(t, err)
+	byTitle := make(map[string]int)
+	titles(t, movies, byTitle)
+
+	scopes := map[string]movie.ChangeScope{
+		"every movie":      {},
+		"the alice movies": {UserID: ids["alice"]},
+		"the carol movies": {UserID: ids["carol"]},
+		"Alien":            {MovieID: byTitle["Alien"]},
+		"Casablanca":       {MovieID: byTitle["Casablanca"]},
+	}
+	// changes returns the change of each scope, by number of movies and time in nanoseconds.
+	changes := func() map[string][2]int64 {
+		res := make(map[string][2]int64)
+		for name, scope := range scopes {
+			change, err := r.Movies.GetChange(ctx, ids["bob"], scope)
+			require.NoError(t, err)
+			res[name] = [2]int64{int64(change.Movies), change.ChangedAt.UnixNano()}
+		}
+
+		return res
+	}
+	last := changes()
+	assert.Equal(t, int64(3), last["every movie"][0])
+
+	alice := []string{"every movie", "the alice movies", "Alien"}
+	writes := map[string]struct {
+		write   func() error
+		changed []string
+	}{
+		"create": {
+			write: func() error {
+				return r.Movies.CreateMovie(ctx, &movie.SQLMovie{UserID: ids["alice"], Title: "Dune", Description: "Dune description"})
+			},
+			changed: []string{"every movie", "the alice movies"},
+		},
+		"vote":    {write: func() error { return r.Movies.AddMovieAction(ctx, byTitle["Alien"], ids["bob"], "like") }, changed: alice},
+		"retract": {write: func() error { return r.Movies.RemoveMovieAction(ctx, byTitle["Alien"], ids["bob"], "like") }, changed: alice},
+		"hide":    {write: func() error { return r.AdminMovies.SetHidden(ctx, byTitle["Alien"], true) }, changed: alice},
+		"merge": {
+			write: func() error {
+				_, err := r.AdminMovies.MergeMovies(ctx, byTitle["Alien"], byTitle["Brazil"])
+				return err
+			},
+			changed: alice,
+		},
+		"delete": {
+			write:   func() error { return r.AdminMovies.DeleteMovie(ctx, byTitle["Brazil"]) },
+			changed: []string{"every movie", "the alice movies"},
+		},
+	}
+	for _, name := range []string{"create", "vote", "retract", "hide", "merge", "delete"} {
+		require.NoError(t, writes[name].write())
+		current := changes()
+		for scope := range scopes {
+			changed := false
+			for _, c := range writes[name].changed {
+				changed = changed || c == scope
+			}
+			if changed {
+				assert.NotEqual(t, last[scope], current[scope], "the %s changes %s", name, scope)
+			} else {
+				assert.Equal(t, last[scope], current[scope], "the %s keeps %s", name, scope)
+			}
+		}
+		last = current
+	}
+
+	// A failed write keeps the change.
+	require.NoError(t, r.Movies.AddMovieAction(ctx, byTitle["Casablanca"], ids["bob"], "like"))
+	last = changes()
+	assert.Error(t, r.Movies.AddMovieAction(ctx, byTitle["Casablanca"], ids["bob"], "hate"))
+	assert.Equal(t, last, changes())
+}
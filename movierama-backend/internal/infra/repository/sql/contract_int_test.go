@@ -0,0 +1,84 @@
+//go:build integration
+// +build integration
+
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/infra/repository/repotest"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/infra/repository/sql/moderation"
+	"movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/migration"
+	"os"
+	"strconv"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRepository_Contract_MySQL(t *testing.T) {
+	godotenv.Load("../../../../.env.dist")
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=True&multiStatements=true", os.Getenv("MYSQL_USERNAME"),
+		os.Getenv("MYSQL_PASSWORD"), os.Getenv("MYSQL_WRITE"), os.Getenv("MYSQL_PORT"), os.Getenv("MYSQL_DB"))
+	db, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = migration.NewMigrator(db, migration.MySQL, zap.NewNop()).Up(context.Background())
+	require.NoError(t, err)
+
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		_, err := db.Exec(`SET FOREIGN_KEY_CHECKS = 0;
+			TRUNCATE auth_events; TRUNCATE moderation_actions; TRUNCATE movie_reports; TRUNCATE user_identities; TRUNCATE personal_access_tokens; TRUNCATE movies_users_actions; TRUNCATE movies; TRUNCATE users;
+			SET FOREIGN_KEY_CHECKS = 1;`)
+		require.NoError(t, err)
+
+		mr, err := movie.NewRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+		ur, err := user.NewRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+		mdr, err := moderation.NewRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+
+		return repotest.Repositories{Movies: mr, Users: ur, AdminUsers: ur, AdminMovies: mr, Moderation: mdr, Reports: mdr}
+	})
+}
+
+func TestRepository_Contract_Postgres(t *testing.T) {
+	godotenv.Load("../../../../.env.dist")
+	port, err := strconv.Atoi(os.Getenv("POSTGRES_PORT"))
+	require.NoError(t, err)
+	c := sqlrepo.DBConfig{
+		Driver:   sqlrepo.Postgres,
+		Username: os.Getenv("POSTGRES_USERNAME"),
+		Pass:     os.Getenv("POSTGRES_PASSWORD"),
+		Port:     port,
+		DB:       os.Getenv("POSTGRES_DB"),
+		SSLMode:  os.Getenv("POSTGRES_SSL_MODE"),
+	}
+	db, err := sql.Open(c.DriverName(), c.DSN(os.Getenv("POSTGRES_WRITE")))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = migration.NewMigrator(db, migration.Postgres, zap.NewNop()).Up(context.Background())
+	require.NoError(t, err)
+
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		_, err := db.Exec(`TRUNCATE auth_events, moderation_actions, movie_reports, user_identities, personal_access_tokens, movies_users_actions, movies, users RESTART IDENTITY`)
+		require.NoError(t, err)
+
+		mr, err := movie.NewPostgresRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+		ur, err := user.NewPostgresRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+		mdr, err := moderation.NewPostgresRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+
+		return repotest.Repositories{Movies: mr, Users: ur, AdminUsers: ur, AdminMovies: mr, Moderation: mdr, Reports: mdr}
+	})
+}
@@ -0,0 +1,35 @@
+package sql_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/infra/repository/repotest"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/infra/repository/sql/moderation"
+	"movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/infra/repository/sql/user"
+	"movierama/migration"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRepository_Contract_SQLite(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		db, err := sqlrepo.NewSQLite(filepath.Join(t.TempDir(), "movierama.db"), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		_, err = migration.NewMigrator(db, migration.SQLite, zap.NewNop()).Up(context.Background())
+		require.NoError(t, err)
+
+		mr, err := movie.NewRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+		ur, err := user.NewRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+		mdr, err := moderation.NewRepository(db, db, zap.NewNop())
+		require.NoError(t, err)
+
+		return repotest.Repositories{Movies: mr, Users: ur, AdminUsers: ur, AdminMovies: mr, Moderation: mdr, Reports: mdr}
+	})
+}
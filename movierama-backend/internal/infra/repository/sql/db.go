@@ -3,39 +3,87 @@ package sql
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DBConfig Database Configuration.
 type DBConfig struct {
+	// Driver is MySQL or Postgres, MySQL when empty.
+	Driver   string
 	Username string
 	Pass     string
 	Reader   string
 	Writer   string
-	Port     string
+	Port     int
 	DB       string
+	// SSLMode is the sslmode of the PostgreSQL connections, disable when empty.
+	SSLMode string
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure both pools.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// TracerProvider records the query spans, no spans are recorded if nil.
+	TracerProvider trace.TracerProvider
+}
+
+// DriverName returns the database/sql name of the driver.
+func (c DBConfig) DriverName() string {
+	if c.Driver == "" {
+		return MySQL
+	}
+
+	return c.Driver
 }
 
-// NewDB returns a handle to a Database.
-func NewDB(c DBConfig) (read *sql.DB, write *sql.DB) {
-	read, err := sql.Open("mysql",
-		fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=True",
-			c.Username, c.Pass, c.Reader, c.Port, c.DB))
+// DSN returns the data source name of the database on host.
+func (c DBConfig) DSN(host string) string {
+	if c.DriverName() == Postgres {
+		sslMode := c.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.Username, c.Pass),
+			Host:     net.JoinHostPort(host, strconv.Itoa(c.Port)),
+			Path:     "/" + c.DB,
+			RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+		}
 
-	if err != nil || read.Ping() != nil {
-		log.Fatalf("failed to connect to master database %v", err)
-		os.Exit(1)
+		return u.String()
 	}
 
-	write, err = sql.Open("mysql",
-		fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=True",
-			c.Username, c.Pass, c.Writer, c.Port, c.DB))
+	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=True", c.Username, c.Pass, host, c.Port, c.DB)
+}
+
+// NewDB returns the handles of the reader and of the writer. The databases are not reached until the first
+// query, so that the app starts while they are down and its readiness checks report them.
+func NewDB(c DBConfig) (read *sql.DB, write *sql.DB, err error) {
+	tp := c.TracerProvider
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+
+	read, err = OpenDB(c.DriverName(), c.DSN(c.Reader), "reader", tp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open reader database: %w", err)
+	}
+	write, err = OpenDB(c.DriverName(), c.DSN(c.Writer), "writer", tp)
+	if err != nil {
+		read.Close()
+		return nil, nil, fmt.Errorf("open writer database: %w", err)
+	}
 
-	if err != nil || write.Ping() != nil {
-		log.Fatalf("failed to connect to replica database %v", err)
-		os.Exit(1)
+	for _, db := range []*sql.DB{read, write} {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+		db.SetMaxIdleConns(c.MaxIdleConns)
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
 	}
 
-	return read, write
+	return read, write, nil
 }
@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"strconv"
 	"testing"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -22,11 +23,12 @@ func TestDB_CreateConnection(t *testing.T) {
 		Pass:     getEnv("MYSQL_PASSWORD"),
 		Reader:   getEnv("MYSQL_READ"),
 		Writer:   getEnv("MYSQL_WRITE"),
-		Port:     getEnv("MYSQL_PORT"),
+		Port:     getIntEnv("MYSQL_PORT"),
 		DB:       getEnv("MYSQL_DB"),
 	}
 
-	r, w := NewDB(c)
+	r, w, err := NewDB(c)
+	assert.NoError(t, err)
 	assert.IsType(t, &sql.DB{}, r)
 	assert.IsType(t, &sql.DB{}, w)
 	assert.NoError(t, r.Ping())
@@ -40,3 +42,11 @@ func getEnv(key string) string {
 	}
 	return v
 }
+
+func getIntEnv(key string) int {
+	v, err := strconv.Atoi(getEnv(key))
+	if err != nil {
+		log.Fatalf("Invalid configuration %s: %v", key, err)
+	}
+	return v
+}
@@ -0,0 +1,112 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"strconv"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Driver names of the supported databases, as registered with database/sql.
+const (
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+const (
+	// mysqlErrDupEntry is the MySQL error number of unique key violations.
+	mysqlErrDupEntry = 1062
+	// pqUniqueViolation is the PostgreSQL error code of unique key violations.
+	pqUniqueViolation = "23505"
+)
+
+// Rebind returns the query written with ? placeholders in the placeholders of the driver,
+// PostgreSQL numbers them $1, $2... The queries must not contain a literal ?.
+func Rebind(driver, query string) string {
+	if driver != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// Execer runs the queries of a database or of a transaction.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// InsertID runs the INSERT query on db and returns the id of the new row. PostgreSQL has no
+// LastInsertId, the id is returned by a RETURNING clause instead.
+func InsertID(ctx context.Context, db Execer, driver, query string, args ...interface{}) (int, error) {
+	if driver == Postgres {
+		query = strings.TrimSuffix(strings.TrimSpace(query), ";") + " RETURNING id;"
+		var id int
+		err := db.QueryRowContext(ctx, Rebind(driver, query), args...).Scan(&id)
+
+		return id, err
+	}
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
+
+// IsDuplicate reports whether err is a unique key violation of MySQL, SQLite or PostgreSQL.
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDupEntry
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqUniqueViolation
+	}
+
+	return false
+}
+
+// IsDuplicateKey reports whether err is a unique key violation of the unique index of column in table. The
+// index is named after the column in MySQL and <table>_<column> in PostgreSQL, SQLite names the column.
+func IsDuplicateKey(err error, table, column string) bool {
+	if !IsDuplicate(err) {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// MySQL 8 prefixes the key with its table.
+		return strings.HasSuffix(mysqlErr.Message, "'"+column+"'") || strings.HasSuffix(mysqlErr.Message, "'"+table+"."+column+"'")
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint == table+"_"+column
+	}
+
+	return strings.Contains(err.Error(), table+"."+column)
+}
@@ -0,0 +1,188 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := map[string]struct {
+		driver   string
+		query    string
+		expQuery string
+	}{
+		"should number the postgres placeholders": {
+			driver:   sqlrepo.Postgres,
+			query:    `SELECT id FROM users WHERE id IN (?,?) AND username=?`,
+			expQuery: `SELECT id FROM users WHERE id IN ($1,$2) AND username=$3`,
+		},
+		"should leave the mysql placeholders": {
+			driver:   sqlrepo.MySQL,
+			query:    `SELECT id FROM users WHERE username=?`,
+			expQuery: `SELECT id FROM users WHERE username=?`,
+		},
+		"should leave the sqlite placeholders": {
+			driver:   sqlrepo.SQLite,
+			query:    `SELECT id FROM users WHERE username=?`,
+			expQuery: `SELECT id FROM users WHERE username=?`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expQuery, sqlrepo.Rebind(tt.driver, tt.query))
+		})
+	}
+}
+
+func TestInsertID(t *testing.T) {
+	tests := map[string]struct {
+		driver string
+		expect func(mock sqlmock.Sqlmock)
+	}{
+		"should return the last insert id": {
+			driver: sqlrepo.MySQL,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`INSERT INTO users (username) VALUES(?);`).WithArgs("username").
+					WillReturnResult(sqlmock.NewResult(7, 1))
+			},
+		},
+		"should return the id of the returning clause": {
+			driver: sqlrepo.Postgres,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`INSERT INTO users (username) VALUES($1) RETURNING id;`).WithArgs("username").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+			tt.expect(mock)
+
+			id, err := sqlrepo.InsertID(context.Background(), db, tt.driver, `INSERT INTO users (username) VALUES(?);`, "username")
+			require.NoError(t, err)
+			assert.Equal(t, 7, id)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestIsDuplicate(t *testing.T) {
+	// SQLite errors have no exported constructor, make a real one.
+	db, err := sqlrepo.NewSQLite(filepath.Join(t.TempDir(), "movierama.db"), nil)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE users (username TEXT NOT NULL UNIQUE); INSERT INTO users VALUES ('alice');`)
+	require.NoError(t, err)
+	_, sqliteErr := db.Exec(`INSERT INTO users VALUES ('alice')`)
+
+	tests := map[string]struct {
+		err error
+		exp bool
+	}{
+		"should match mysql duplicate entries": {
+			err: fmt.Errorf("create user: %w", &mysql.MySQLError{Number: 1062}),
+			exp: true,
+		},
+		"should match sqlite unique constraints": {
+			err: sqliteErr,
+			exp: true,
+		},
+		"should match postgres unique violations": {
+			err: &pq.Error{Code: "23505"},
+			exp: true,
+		},
+		"should not match other mysql errors": {
+			err: &mysql.MySQLError{Number: 1146},
+		},
+		"should not match other postgres errors": {
+			err: &pq.Error{Code: "23503"},
+		},
+		"should not match other errors": {
+			err: errors.New("sql error"),
+		},
+		"should not match nil": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, sqlrepo.IsDuplicate(tt.err))
+		})
+	}
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	db, err := sqlrepo.NewSQLite(filepath.Join(t.TempDir(), "movierama.db"), nil)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE users (username TEXT NOT NULL UNIQUE, email TEXT);
+		CREATE UNIQUE INDEX users_email ON users (email);
+		INSERT INTO users VALUES ('alice', 'alice@example.com');`)
+	require.NoError(t, err)
+	_, sqliteUsernameErr := db.Exec(`INSERT INTO users VALUES ('alice', 'bob@example.com')`)
+	_, sqliteEmailErr := db.Exec(`INSERT INTO users VALUES ('bob', 'alice@example.com')`)
+
+	tests := map[string]struct {
+		err error
+		exp bool
+	}{
+		"should match the mysql 8 key": {
+			err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice@example.com' for key 'users.email'"},
+			exp: true,
+		},
+		"should match the mysql 5 key": {
+			err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice@example.com' for key 'email'"},
+			exp: true,
+		},
+		"should not match another mysql key": {
+			err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice' for key 'users.username'"},
+		},
+		"should match the postgres index": {
+			err: &pq.Error{Code: "23505", Constraint: "users_email"},
+			exp: true,
+		},
+		"should not match another postgres index": {
+			err: &pq.Error{Code: "23505", Constraint: "users_username_key"},
+		},
+		"should match the sqlite column": {
+			err: sqliteEmailErr,
+			exp: true,
+		},
+		"should not match another sqlite column": {
+			err: sqliteUsernameErr,
+		},
+		"should not match other errors": {
+			err: errors.New("users.email"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, sqlrepo.IsDuplicateKey(tt.err, "users", "email"))
+		})
+	}
+}
+
+func TestDBConfig_DSN(t *testing.T) {
+	c := sqlrepo.DBConfig{Username: "user", Pass: "p@ss", Port: 5432, DB: "movierama"}
+	assert.Equal(t, "user:p@ss@tcp(db:5432)/movierama?parseTime=True", c.DSN("db"))
+
+	c.Driver = sqlrepo.Postgres
+	assert.Equal(t, "postgres://user:p%40ss@db:5432/movierama?sslmode=disable", c.DSN("db"))
+
+	c.SSLMode = "verify-full"
+	assert.Equal(t, "postgres://user:p%40ss@db:5432/movierama?sslmode=verify-full", c.DSN("db"))
+}
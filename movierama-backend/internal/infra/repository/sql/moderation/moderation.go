@@ -0,0 +1,73 @@
+// Package moderation stores the log of the moderation actions and the reports of the movies.
+package moderation
+
+import "time"
+
+// Action struct.
+type Action struct {
+	ID          int    `db:"id"`
+	ModeratorID int    `db:"moderator_id"`
+	Action      string `db:"action"`
+	UserID      *int   `db:"user_id"`
+	MovieID     *int   `db:"movie_id"`
+	MovieTitle  string `db:"movie_title"`
+	// Detail is the new role of a role change, the end of a suspension, the number of resolved reports, the
+	// content filter rule which flagged a movie or the movie a duplicate was merged into.
+	Detail    string    `db:"detail"`
+	Reason    string    `db:"reason"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ActionFilter selects moderation actions, the zero fields match every action.
+type ActionFilter struct {
+	ModeratorID int
+	UserID      int
+	MovieID     int
+	Action      string
+	// BeforeID selects the actions older than the action of this id, to page through them.
+	BeforeID int
+	Limit    int
+}
+
+// Report statuses, the open reports are in the moderation queue.
+const (
+	ReportOpen     = "open"
+	ReportResolved = "resolved"
+)
+
+// Report sources, the reports of the users hide the movies once they are enough, the flags of the content
+// filter only queue them for moderation.
+const (
+	SourceUser   = "user"
+	SourceFilter = "filter"
+)
+
+// Report struct.
+type Report struct {
+	ID      int `db:"id"`
+	MovieID int `db:"movie_id"`
+	// ReporterID is zero for the flags of the content filter, which are not deduplicated.
+	ReporterID int `db:"reporter_id"`
+	// Source is SourceUser when empty.
+	Source    string    `db:"source"`
+	Category  string    `db:"category"`
+	Status    string    `db:"status"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// QueueEntry is a movie with open reports.
+type QueueEntry struct {
+	MovieID  int        `db:"movie_id"`
+	Title    string     `db:"title"`
+	UserID   int        `db:"user_id"`
+	HiddenAt *time.Time `db:"hidden_at"`
+	Reports  int        `db:"reports"`
+	// Categories counts the open reports by category.
+	Categories map[string]int
+}
+
+// QueueFilter pages through the moderation queue.
+type QueueFilter struct {
+	Offset int
+	Limit  int
+}
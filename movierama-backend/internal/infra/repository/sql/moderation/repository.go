@@ -0,0 +1,251 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/logging"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrDuplicateReport is returned when the user already reported the movie.
+var ErrDuplicateReport = errors.New("duplicate movie report")
+
+// Repository definition.
+type Repository struct {
+	db  *sqlrepo.Router
+	log *zap.Logger
+	// driver adapts the queries to PostgreSQL, they are shared by MySQL and SQLite.
+	driver string
+}
+
+// NewRepository constructor of a repository of a MySQL or SQLite database.
+func NewRepository(reader *sql.DB, writer *sql.DB, logger *zap.Logger) (*Repository, error) {
+	if reader == nil {
+		return nil, errors.New("db reader is nil")
+	}
+	if writer == nil {
+		return nil, errors.New("db writer is nil")
+	}
+	if logger == nil {
+		return nil, errors.New("logger is nil")
+	}
+	return &Repository{db: sqlrepo.NewRouter(reader, writer, 0), log: logger, driver: sqlrepo.MySQL}, nil
+}
+
+// NewPostgresRepository constructor of a repository of a PostgreSQL database.
+func NewPostgresRepository(reader *sql.DB, writer *sql.DB, logger *zap.Logger) (*Repository, error) {
+	r, err := NewRepository(reader, writer, logger)
+	if err != nil {
+		return nil, err
+	}
+	r.driver = sqlrepo.Postgres
+
+	return r, nil
+}
+
+// WithRouter routes the queries with router, which is shared by the repositories of the same databases.
+func (sr *Repository) WithRouter(router *sqlrepo.Router) *Repository {
+	sr.db = router
+
+	return sr
+}
+
+// logErr logs the unexpected query errors with the request context and returns err.
+func (sr *Repository) logErr(ctx context.Context, query string, err error) error {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logging.For(ctx, sr.log).Error("sql query failed", zap.String("query", query), zap.Error(err))
+	}
+
+	return err
+}
+
+// AddAction records a moderation action and sets its id.
+func (sr *Repository) AddAction(ctx context.Context, action *Action) error {
+	sqlQuery := `INSERT INTO moderation_actions (moderator_id, action, user_id, movie_id, movie_title, detail, reason) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	id, err := sqlrepo.InsertID(ctx, sr.db.Writer(), sr.driver, sqlQuery,
+		action.ModeratorID, action.Action, action.UserID, action.MovieID, action.MovieTitle, action.Detail, action.Reason)
+	if err != nil {
+		return sr.logErr(ctx, "AddAction", err)
+	}
+	action.ID = id
+	sr.db.Wrote(ctx, sqlrepo.UserSession(action.ModeratorID))
+
+	return nil
+}
+
+// GetActions returns the moderation actions selected by filter, newest first.
+func (sr *Repository) GetActions(ctx context.Context, filter ActionFilter) ([]Action, error) {
+	var where []string
+	var args []interface{}
+	for _, cond := range []struct {
+		column string
+		value  interface{}
+		set    bool
+	}{
+		{"moderator_id = ?", filter.ModeratorID, filter.ModeratorID != 0},
+		{"user_id = ?", filter.UserID, filter.UserID != 0},
+		{"movie_id = ?", filter.MovieID, filter.MovieID != 0},
+		{"action = ?", filter.Action, filter.Action != ""},
+		{"id < ?", filter.BeforeID, filter.BeforeID != 0},
+	} {
+		if cond.set {
+			where = append(where, cond.column)
+			args = append(args, cond.value)
+		}
+	}
+	sqlQuery := `SELECT id, moderator_id, action, user_id, movie_id, movie_title, detail, reason, created_at FROM moderation_actions`
+	if len(where) > 0 {
+		sqlQuery += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	sqlQuery += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := sr.db.Reader(ctx, sqlrepo.UserSession(filter.ModeratorID)).QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), args...)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetActions", err)
+	}
+	defer rows.Close()
+
+	var actions []Action
+	for rows.Next() {
+		var a Action
+		if err := rows.Scan(&a.ID, &a.ModeratorID, &a.Action, &a.UserID, &a.MovieID, &a.MovieTitle, &a.Detail, &a.Reason, &a.CreatedAt); err != nil {
+			return actions, sr.logErr(ctx, "GetActions", err)
+		}
+		actions = append(actions, a)
+	}
+	if err = rows.Err(); err != nil {
+		return actions, sr.logErr(ctx, "GetActions", err)
+	}
+
+	return actions, nil
+}
+
+// AddReport records the report of a movie and sets its id, a user reports a movie once.
+func (sr *Repository) AddReport(ctx context.Context, report *Report) error {
+	sqlQuery := `INSERT INTO movie_reports (movie_id, reporter_id, source, category) VALUES (?, ?, ?, ?)`
+
+	if report.Source == "" {
+		report.Source = SourceUser
+	}
+	// The reporter of a flag is NULL, so that the flags of a movie do not collide.
+	var reporterID interface{}
+	if report.ReporterID != 0 {
+		reporterID = report.ReporterID
+	}
+	id, err := sqlrepo.InsertID(ctx, sr.db.Writer(), sr.driver, sqlQuery, report.MovieID, reporterID, report.Source,
+		report.Category)
+	if sqlrepo.IsDuplicate(err) {
+		return ErrDuplicateReport
+	}
+	if err != nil {
+		return sr.logErr(ctx, "AddReport", err)
+	}
+	report.ID = id
+	sr.db.Wrote(ctx, sqlrepo.UserSession(report.ReporterID))
+
+	return nil
+}
+
+// CountReports returns the number of open reports of a movie from source, from every source when empty.
+func (sr *Repository) CountReports(ctx context.Context, movieID int, source string) (int, error) {
+	sqlQuery := `SELECT COUNT(*) FROM movie_reports WHERE movie_id = ? AND status = ?`
+	args := []interface{}{movieID, ReportOpen}
+	if source != "" {
+		sqlQuery += ` AND source = ?`
+		args = append(args, source)
+	}
+
+	var count int
+	// The count decides the automatic hiding, it reads the writer to include the latest report.
+	err := sr.db.Writer().QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), args...).Scan(&count)
+	if err != nil {
+		return 0, sr.logErr(ctx, "CountReports", err)
+	}
+
+	return count, nil
+}
+
+// GetQueue returns the movies with open reports, the most reported first.
+func (sr *Repository) GetQueue(ctx context.Context, filter QueueFilter) ([]QueueEntry, error) {
+	sqlQuery := `SELECT r.movie_id, m.title, m.user_id, m.hidden_at, COUNT(*) AS reports
+		FROM movie_reports AS r JOIN movies AS m ON m.id = r.movie_id
+		WHERE r.status = ?
+		GROUP BY r.movie_id, m.title, m.user_id, m.hidden_at
+		ORDER BY reports DESC, r.movie_id LIMIT ? OFFSET ?`
+
+	// The moderators read the writer, their actions follow each other closely.
+	db := sr.db.Writer()
+	rows, err := db.QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), ReportOpen, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetQueue", err)
+	}
+	defer rows.Close()
+
+	var entries []QueueEntry
+	byMovie := map[int]*QueueEntry{}
+	for rows.Next() {
+		e := QueueEntry{Categories: map[string]int{}}
+		if err := rows.Scan(&e.MovieID, &e.Title, &e.UserID, &e.HiddenAt, &e.Reports); err != nil {
+			return nil, sr.logErr(ctx, "GetQueue", err)
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, sr.logErr(ctx, "GetQueue", err)
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	args := []interface{}{ReportOpen}
+	for i := range entries {
+		byMovie[entries[i].MovieID] = &entries[i]
+		args = append(args, entries[i].MovieID)
+	}
+	sqlQuery = `SELECT movie_id, category, COUNT(*) FROM movie_reports WHERE status = ? AND movie_id IN (?` +
+		strings.Repeat(`, ?`, len(entries)-1) + `) GROUP BY movie_id, category`
+
+	rows, err = db.QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), args...)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetQueue", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movieID, count int
+		var category string
+		if err := rows.Scan(&movieID, &category, &count); err != nil {
+			return nil, sr.logErr(ctx, "GetQueue", err)
+		}
+		if e, ok := byMovie[movieID]; ok {
+			e.Categories[category] = count
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, sr.logErr(ctx, "GetQueue", err)
+	}
+
+	return entries, nil
+}
+
+// ResolveReports resolves the open reports of a movie and returns their number.
+func (sr *Repository) ResolveReports(ctx context.Context, movieID int) (int, error) {
+	sqlQuery := `UPDATE movie_reports SET status = ? WHERE movie_id = ? AND status = ?`
+
+	res, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), ReportResolved, movieID, ReportOpen)
+	if err != nil {
+		return 0, sr.logErr(ctx, "ResolveReports", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, sr.logErr(ctx, "ResolveReports", err)
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,53 @@
+package moderation
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock describes a mock struct.
+type Mock struct {
+	mock.Mock
+}
+
+// AddAction mock.
+func (m *Mock) AddAction(ctx context.Context, action *Action) error {
+	args := m.MethodCalled("AddAction", ctx, action)
+
+	return args.Error(0)
+}
+
+// GetActions mock.
+func (m *Mock) GetActions(ctx context.Context, filter ActionFilter) ([]Action, error) {
+	args := m.MethodCalled("GetActions", ctx, filter)
+
+	return args.Get(0).([]Action), args.Error(1)
+}
+
+// AddReport mock.
+func (m *Mock) AddReport(ctx context.Context, report *Report) error {
+	args := m.MethodCalled("AddReport", ctx, report)
+
+	return args.Error(0)
+}
+
+// CountReports mock.
+func (m *Mock) CountReports(ctx context.Context, movieID int, source string) (int, error) {
+	args := m.MethodCalled("CountReports", ctx, movieID, source)
+
+	return args.Int(0), args.Error(1)
+}
+
+// GetQueue mock.
+func (m *Mock) GetQueue(ctx context.Context, filter QueueFilter) ([]QueueEntry, error) {
+	args := m.MethodCalled("GetQueue", ctx, filter)
+
+	return args.Get(0).([]QueueEntry), args.Error(1)
+}
+
+// ResolveReports mock.
+func (m *Mock) ResolveReports(ctx context.Context, movieID int) (int, error) {
+	args := m.MethodCalled("ResolveReports", ctx, movieID)
+
+	return args.Int(0), args.Error(1)
+}
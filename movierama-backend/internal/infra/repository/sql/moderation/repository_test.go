@@ -0,0 +1,191 @@
+package moderation_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"movierama/internal/infra/repository/sql/moderation"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func Test_NewRepository(t *testing.T) {
+	tests := map[string]struct {
+		reader *sql.DB
+		writer *sql.DB
+		logger *zap.Logger
+		expErr error
+	}{
+		"success": {
+			reader: &sql.DB{},
+			writer: &sql.DB{},
+			logger: zap.NewNop(),
+		},
+		"missing reader": {
+			writer: &sql.DB{},
+			logger: zap.NewNop(),
+			expErr: errors.New("db reader is nil"),
+		},
+		"missing writer": {
+			reader: &sql.DB{},
+			logger: zap.NewNop(),
+			expErr: errors.New("db writer is nil"),
+		},
+		"missing logger": {
+			reader: &sql.DB{},
+			writer: &sql.DB{},
+			expErr: errors.New("logger is nil"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := moderation.NewRepository(tt.reader, tt.writer, tt.logger)
+			if tt.expErr != nil {
+				assert.Equal(t, tt.expErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, got)
+			}
+		})
+	}
+}
+
+func Test_AddAction(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, _ := moderation.NewRepository(db, db, zap.NewNop())
+
+	movieID, userID := 4, 2
+	insert := `INSERT INTO moderation_actions (moderator_id, action, user_id, movie_id, movie_title, detail, reason) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	mock.ExpectExec(insert).WithArgs(1, "remove_movie", 2, 4, "Spam", "", "spam").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	a := &moderation.Action{ModeratorID: 1, Action: "remove_movie", UserID: &userID, MovieID: &movieID, MovieTitle: "Spam", Reason: "spam"}
+	assert.NoError(t, repo.AddAction(context.TODO(), a))
+	assert.Equal(t, 7, a.ID)
+
+	mock.ExpectExec(insert).WithArgs(1, "ban", 2, nil, "", "", "abuse").
+		WillReturnError(errors.New("sql error"))
+	a = &moderation.Action{ModeratorID: 1, Action: "ban", UserID: &userID, Reason: "abuse"}
+	assert.EqualError(t, repo.AddAction(context.TODO(), a), "sql error")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_GetActions(t *testing.T) {
+	columns := []string{"id", "moderator_id", "action", "user_id", "movie_id", "movie_title", "detail", "reason", "created_at"}
+	createdAt := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	movieID, userID := 4, 2
+
+	cases := map[string]struct {
+		filter   moderation.ActionFilter
+		expQuery string
+		expArgs  []driver.Value
+		rows     *sqlmock.Rows
+		expRes   []moderation.Action
+	}{
+		"should return every action without filter": {
+			filter:   moderation.ActionFilter{Limit: 50},
+			expQuery: `SELECT id, moderator_id, action, user_id, movie_id, movie_title, detail, reason, created_at FROM moderation_actions ORDER BY id DESC LIMIT ?`,
+			expArgs:  []driver.Value{50},
+			rows: sqlmock.NewRows(columns).
+				AddRow(2, 1, "remove_movie", 2, 4, "Spam", "", "spam", createdAt).
+				AddRow(1, 1, "suspend", 2, nil, "", "2026-10-21T00:00:00Z", "rude", createdAt),
+			expRes: []moderation.Action{
+				{ID: 2, ModeratorID: 1, Action: "remove_movie", UserID: &userID, MovieID: &movieID, MovieTitle: "Spam", Reason: "spam", CreatedAt: createdAt},
+				{ID: 1, ModeratorID: 1, Action: "suspend", UserID: &userID, Detail: "2026-10-21T00:00:00Z", Reason: "rude", CreatedAt: createdAt},
+			},
+		},
+		"should filter the actions": {
+			filter: moderation.ActionFilter{ModeratorID: 1, UserID: 2, MovieID: 4, Action: "remove_movie", BeforeID: 10, Limit: 5},
+			expQuery: `SELECT id, moderator_id, action, user_id, movie_id, movie_title, detail, reason, created_at FROM moderation_actions ` +
+				`WHERE moderator_id = ? AND user_id = ? AND movie_id = ? AND action = ? AND id < ? ORDER BY id DESC LIMIT ?`,
+			expArgs: []driver.Value{1, 2, 4, "remove_movie", 10, 5},
+			rows:    sqlmock.NewRows(columns),
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			assert.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(tt.expQuery).WithArgs(tt.expArgs...).WillReturnRows(tt.rows)
+
+			repo, _ := moderation.NewRepository(db, db, zap.NewNop())
+			actions, err := repo.GetActions(context.TODO(), tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expRes, actions)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_GetQueue(t *testing.T) {
+	hiddenAt := time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT r.movie_id, m.title, m.user_id, m.hidden_at, COUNT(*) AS reports
+		FROM movie_reports AS r JOIN movies AS m ON m.id = r.movie_id
+		WHERE r.status = ?
+		GROUP BY r.movie_id, m.title, m.user_id, m.hidden_at
+		ORDER BY reports DESC, r.movie_id LIMIT ? OFFSET ?`).
+		WithArgs("open", 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"movie_id", "title", "user_id", "hidden_at", "reports"}).
+			AddRow(4, "Spam", 2, hiddenAt, 3).
+			AddRow(6, "Rude", 3, nil, 1))
+	mock.ExpectQuery(`SELECT movie_id, category, COUNT(*) FROM movie_reports WHERE status = ? AND movie_id IN (?, ?) GROUP BY movie_id, category`).
+		WithArgs("open", 4, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"movie_id", "category", "count"}).
+			AddRow(4, "spam", 2).
+			AddRow(4, "other", 1).
+			AddRow(6, "offensive", 1))
+
+	repo, _ := moderation.NewRepository(db, db, zap.NewNop())
+	entries, err := repo.GetQueue(context.TODO(), moderation.QueueFilter{Offset: 20, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []moderation.QueueEntry{
+		{MovieID: 4, Title: "Spam", UserID: 2, HiddenAt: &hiddenAt, Reports: 3, Categories: map[string]int{"spam": 2, "other": 1}},
+		{MovieID: 6, Title: "Rude", UserID: 3, Reports: 1, Categories: map[string]int{"offensive": 1}},
+	}, entries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_ResolveReports(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	mock.ExpectExec(`UPDATE movie_reports SET status = ? WHERE movie_id = ? AND status = ?`).
+		WithArgs("resolved", 4, "open").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	repo, _ := moderation.NewRepository(db, db, zap.NewNop())
+	n, err := repo.ResolveReports(context.TODO(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_Postgres(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, err := moderation.NewPostgresRepository(db, db, zap.NewNop())
+	assert.NoError(t, err)
+
+	userID := 2
+	mock.ExpectQuery(`INSERT INTO moderation_actions (moderator_id, action, user_id, movie_id, movie_title, detail, reason) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`).
+		WithArgs(1, "ban", 2, nil, "", "", "abuse").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	a := &moderation.Action{ModeratorID: 1, Action: "ban", UserID: &userID, Reason: "abuse"}
+	assert.NoError(t, repo.AddAction(context.TODO(), a))
+	assert.Equal(t, 3, a.ID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -14,6 +14,8 @@ type Movie struct {
 	UserLiked   bool      `db:"usr_liked"`
 	UserHated   bool      `db:"usr_hated"`
 	CreatedAt   time.Time `db:"created_at"`
+	// HiddenAt is set on the hidden movies, only GetAnyMovie returns them.
+	HiddenAt *time.Time `db:"hidden_at"`
 }
 
 // SQLMovie struct.
@@ -23,4 +25,43 @@ type SQLMovie struct {
 	Title       string    `db:"title"`
 	Description string    `db:"description"`
 	CreatedAt   time.Time `db:"created_at"`
+	// TitleKey is the normalized title, which the similar titles are looked up by.
+	TitleKey string `db:"title_key"`
+	// UpdatedAt is the time of the last write of the movie or of its votes.
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// Title struct.
+type Title struct {
+	ID    int    `db:"id"`
+	Title string `db:"title"`
+	// Key is the normalized title, empty for the movies created before the keys, see SetTitleKeys.
+	Key string `db:"title_key"`
+}
+
+// titleEndLength is the number of runes of the ends of the title keys, which the similar titles share one of.
+const titleEndLength = 3
+
+// TitleEnds returns the prefix and the suffix of a title key, the similar titles share at least one of them.
+func TitleEnds(key string) (prefix, suffix string) {
+	r := []rune(key)
+	if len(r) <= titleEndLength {
+		return key, key
+	}
+
+	return string(r[:titleEndLength]), string(r[len(r)-titleEndLength:])
+}
+
+// Change struct, the number of the movies of a scope and the time of the last write of one of them or of their
+// votes. A write moves the time, a deletion changes the number.
+type Change struct {
+	Movies    int       `db:"movies"`
+	ChangedAt time.Time `db:"updated_at"`
+}
+
+// ChangeScope selects the movies of a change, the zero scope selects every movie.
+type ChangeScope struct {
+	// UserID selects the movies submitted by the user.
+	UserID  int
+	MovieID int
 }
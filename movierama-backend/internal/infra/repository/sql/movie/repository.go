@@ -5,6 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/logging"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 // Ordering types.
@@ -17,21 +22,113 @@ const (
 	TypeOrderByHates       = "hates"
 )
 
+// ErrOwnMovie is returned when a user votes for the movie they submitted.
+var ErrOwnMovie = errors.New("own movie")
+
 // Repository definition.
 type Repository struct {
-	read  *sql.DB
-	write *sql.DB
+	db  *sqlrepo.Router
+	log *zap.Logger
+	// driver adapts the queries to PostgreSQL, they are shared by MySQL and SQLite.
+	driver string
 }
 
-// NewRepository constructor.
-func NewRepository(reader *sql.DB, writer *sql.DB) (*Repository, error) {
+// NewRepository constructor of a repository of a MySQL or SQLite database.
+func NewRepository(reader *sql.DB, writer *sql.DB, logger *zap.Logger) (*Repository, error) {
 	if reader == nil {
 		return nil, errors.New("db reader is nil")
 	}
 	if writer == nil {
 		return nil, errors.New("db writer is nil")
 	}
-	return &Repository{read: reader, write: writer}, nil
+	if logger == nil {
+		return nil, errors.New("logger is nil")
+	}
+	return &Repository{db: sqlrepo.NewRouter(reader, writer, 0), log: logger, driver: sqlrepo.MySQL}, nil
+}
+
+// NewPostgresRepository constructor of a repository of a PostgreSQL database.
+func NewPostgresRepository(reader *sql.DB, writer *sql.DB, logger *zap.Logger) (*Repository, error) {
+	r, err := NewRepository(reader, writer, logger)
+	if err != nil {
+		return nil, err
+	}
+	r.driver = sqlrepo.Postgres
+
+	return r, nil
+}
+
+// WithRouter routes the queries with router, which is shared by the repositories of the same databases.
+func (sr *Repository) WithRouter(router *sqlrepo.Router) *Repository {
+	sr.db = router
+
+	return sr
+}
+
+// logErr logs the unexpected query errors with the request context and returns err.
+func (sr *Repository) logErr(ctx context.Context, query string, err error) error {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, ErrOwnMovie) {
+		logging.For(ctx, sr.log).Error("sql query failed", zap.String("query", query), zap.Error(err))
+	}
+
+	return err
+}
+
+// touchQuery sets the time of the last write of a movie or of its votes.
+const touchQuery = `UPDATE movies SET updated_at=? WHERE id=?`
+
+// write runs the writes of fn in a transaction that touches the movie of movieID, unless zero, so that the
+// responses of the movie and of its listings cached by the clients are sent again. Only the writes of the same
+// movie wait for each other.
+func (sr *Repository) write(ctx context.Context, query string, movieID int, fn func(tx *sql.Tx) error) error {
+	tx, err := sr.db.Writer().BeginTx(ctx, nil)
+	if err != nil {
+		return sr.logErr(ctx, query, err)
+	}
+	defer tx.Rollback()
+
+	if err = fn(tx); err != nil {
+		return sr.logErr(ctx, query, err)
+	}
+	if movieID != 0 {
+		if _, err = tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver, touchQuery), time.Now().UTC(), movieID); err != nil {
+			return sr.logErr(ctx, query, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return sr.logErr(ctx, query, err)
+	}
+
+	return nil
+}
+
+// GetChange returns the number of the movies of scope and the time of the last write of one of them or of their
+// votes, authUsrID may be zero for anonymous users. The hidden movies are counted, hiding a movie writes it.
+func (sr *Repository) GetChange(ctx context.Context, authUsrID int, scope ChangeScope) (*Change, error) {
+	where, args := "1=1", []interface{}{}
+	switch {
+	case scope.MovieID != 0:
+		where, args = "id=?", []interface{}{scope.MovieID}
+	case scope.UserID != 0:
+		where, args = "user_id=?", []interface{}{scope.UserID}
+	}
+	// The time is selected from its column rather than by MAX, whose type SQLite does not keep.
+	sqlQuery := `SELECT (SELECT COUNT(*) FROM movies WHERE ` + where + `), updated_at FROM movies WHERE ` + where + `
+		ORDER BY updated_at DESC LIMIT 1`
+
+	// The listings of the user are read from the same database, the change must not be newer than them.
+	var change Change
+	err := sr.db.Reader(ctx, sqlrepo.UserSession(authUsrID)).
+		QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), append(args, args...)...).
+		Scan(&change.Movies, &change.ChangedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &change, nil
+	}
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetChange", err)
+	}
+
+	return &change, nil
 }
 
 // GetMoviesPublic returns a list of movies without auth.
@@ -44,13 +141,15 @@ func (sr *Repository) GetMoviesPublic(ctx context.Context, sortType string) ([]M
     movie.created_at,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
-    (SELECT CONCAT_WS(' ', first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    		ORDER BY %s DESC;`, ConvertSortTypeToOrderByColumn(sortType))
+    	WHERE movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, ConvertSortTypeToOrderByColumn(sortType))
 
-	rows, err := sr.read.QueryContext(ctx, sqlQuery)
+	rows, err := sr.db.Reader(ctx, "").QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery))
 	if err != nil {
-		return nil, err
+		return nil, sr.logErr(ctx, "GetMoviesPublic", err)
 	}
 
 	defer rows.Close()
@@ -59,6 +158,7 @@ func (sr *Repository) GetMoviesPublic(ctx context.Context, sortType string) ([]M
 	// Loop through rows, using Scan to assign column data to struct fields.
 	for rows.Next() {
 		var movie Movie
+		var firstName, lastName string
 		if err := rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -67,14 +167,16 @@ func (sr *Repository) GetMoviesPublic(ctx context.Context, sortType string) ([]M
 			&movie.CreatedAt,
 			&movie.Likes,
 			&movie.Hates,
-			&movie.PostedBy,
+			&firstName,
+			&lastName,
 		); err != nil {
-			return movies, err
+			return movies, sr.logErr(ctx, "GetMoviesPublic", err)
 		}
+		movie.PostedBy = postedBy(firstName, lastName)
 		movies = append(movies, movie)
 	}
 	if err = rows.Err(); err != nil {
-		return movies, err
+		return movies, sr.logErr(ctx, "GetMoviesPublic", err)
 	}
 
 	return movies, nil
@@ -92,13 +194,15 @@ func (sr *Repository) GetMovies(ctx context.Context, authUsrID int, sortType str
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
-    (SELECT CONCAT_WS(' ', first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    		ORDER BY %s DESC;`, ConvertSortTypeToOrderByColumn(sortType))
+    	WHERE movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, ConvertSortTypeToOrderByColumn(sortType))
 
-	rows, err := sr.read.QueryContext(ctx, sqlQuery, authUsrID, authUsrID)
+	rows, err := sr.db.Reader(ctx, sqlrepo.UserSession(authUsrID)).QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), authUsrID, authUsrID)
 	if err != nil {
-		return nil, err
+		return nil, sr.logErr(ctx, "GetMovies", err)
 	}
 
 	defer rows.Close()
@@ -107,6 +211,7 @@ func (sr *Repository) GetMovies(ctx context.Context, authUsrID int, sortType str
 	// Loop through rows, using Scan to assign column data to struct fields.
 	for rows.Next() {
 		var movie Movie
+		var firstName, lastName string
 		if err := rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -117,14 +222,16 @@ func (sr *Repository) GetMovies(ctx context.Context, authUsrID int, sortType str
 			&movie.Hates,
 			&movie.UserLiked,
 			&movie.UserHated,
-			&movie.PostedBy,
+			&firstName,
+			&lastName,
 		); err != nil {
-			return movies, err
+			return movies, sr.logErr(ctx, "GetMovies", err)
 		}
+		movie.PostedBy = postedBy(firstName, lastName)
 		movies = append(movies, movie)
 	}
 	if err = rows.Err(); err != nil {
-		return movies, err
+		return movies, sr.logErr(ctx, "GetMovies", err)
 	}
 
 	return movies, nil
@@ -142,14 +249,16 @@ func (sr *Repository) GetUserMovies(ctx context.Context, userID, authUsrID int,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
-    (SELECT CONCAT_WS(" ", first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    	WHERE user_id=?
-    		ORDER BY %s DESC;`, ConvertSortTypeToOrderByColumn(sortType))
+    	WHERE user_id=? AND movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, ConvertSortTypeToOrderByColumn(sortType))
 
-	rows, err := sr.read.QueryContext(ctx, sqlQuery, authUsrID, authUsrID, userID)
+	rows, err := sr.db.Reader(ctx, sqlrepo.UserSession(authUsrID)).QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery),
+		authUsrID, authUsrID, userID)
 	if err != nil {
-		return nil, err
+		return nil, sr.logErr(ctx, "GetUserMovies", err)
 	}
 
 	defer rows.Close()
@@ -158,6 +267,7 @@ func (sr *Repository) GetUserMovies(ctx context.Context, userID, authUsrID int,
 	// Loop through rows, using Scan to assign column data to struct fields.
 	for rows.Next() {
 		var movie Movie
+		var firstName, lastName string
 		if err := rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -168,14 +278,16 @@ func (sr *Repository) GetUserMovies(ctx context.Context, userID, authUsrID int,
 			&movie.Hates,
 			&movie.UserLiked,
 			&movie.UserHated,
-			&movie.PostedBy,
+			&firstName,
+			&lastName,
 		); err != nil {
-			return movies, err
+			return movies, sr.logErr(ctx, "GetUserMovies", err)
 		}
+		movie.PostedBy = postedBy(firstName, lastName)
 		movies = append(movies, movie)
 	}
 	if err = rows.Err(); err != nil {
-		return movies, err
+		return movies, sr.logErr(ctx, "GetUserMovies", err)
 	}
 
 	return movies, nil
@@ -191,14 +303,15 @@ func (sr *Repository) GetUserMoviesPublic(ctx context.Context, userID int, sortT
     movie.created_at,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
-    (SELECT CONCAT_WS(" ", first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    	WHERE user_id=?
-    		ORDER BY %s DESC;`, ConvertSortTypeToOrderByColumn(sortType))
+    	WHERE user_id=? AND movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, ConvertSortTypeToOrderByColumn(sortType))
 
-	rows, err := sr.read.QueryContext(ctx, sqlQuery, userID)
+	rows, err := sr.db.Reader(ctx, "").QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), userID)
 	if err != nil {
-		return nil, err
+		return nil, sr.logErr(ctx, "GetUserMoviesPublic", err)
 	}
 
 	defer rows.Close()
@@ -207,6 +320,7 @@ func (sr *Repository) GetUserMoviesPublic(ctx context.Context, userID int, sortT
 	// Loop through rows, using Scan to assign column data to struct fields.
 	for rows.Next() {
 		var movie Movie
+		var firstName, lastName string
 		if err := rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -215,40 +329,150 @@ func (sr *Repository) GetUserMoviesPublic(ctx context.Context, userID int, sortT
 			&movie.CreatedAt,
 			&movie.Likes,
 			&movie.Hates,
-			&movie.PostedBy,
+			&firstName,
+			&lastName,
 		); err != nil {
-			return movies, err
+			return movies, sr.logErr(ctx, "GetUserMoviesPublic", err)
 		}
+		movie.PostedBy = postedBy(firstName, lastName)
 		movies = append(movies, movie)
 	}
 	if err = rows.Err(); err != nil {
-		return movies, err
+		return movies, sr.logErr(ctx, "GetUserMoviesPublic", err)
 	}
 
 	return movies, nil
 }
 
-// CreateMovie creates a new movie.
+// GetMovie returns a visible movie, authUsrID may be zero for anonymous users.
+func (sr *Repository) GetMovie(ctx context.Context, movieID, authUsrID int) (*Movie, error) {
+	sqlQuery := `SELECT 
+    movie.id,
+    movie.title,
+    movie.description,
+    movie.user_id,
+    movie.created_at,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
+    (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
+    (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
+    	FROM movies AS movie 
+    	WHERE movie.id=? AND movie.hidden_at IS NULL;`
+
+	row := sr.db.Reader(ctx, sqlrepo.UserSession(authUsrID)).QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery),
+		authUsrID, authUsrID, movieID)
+
+	var movie Movie
+	var firstName, lastName string
+	if err := row.Scan(
+		&movie.ID,
+		&movie.Title,
+		&movie.Description,
+		&movie.UserID,
+		&movie.CreatedAt,
+		&movie.Likes,
+		&movie.Hates,
+		&movie.UserLiked,
+		&movie.UserHated,
+		&firstName,
+		&lastName,
+	); err != nil {
+		return nil, sr.logErr(ctx, "GetMovie", err)
+	}
+	movie.PostedBy = postedBy(firstName, lastName)
+
+	return &movie, nil
+}
+
+// GetAnyMovie returns a movie even when it is hidden, for the moderation.
+func (sr *Repository) GetAnyMovie(ctx context.Context, movieID int) (*Movie, error) {
+	sqlQuery := `SELECT 
+    movie.id,
+    movie.title,
+    movie.description,
+    movie.user_id,
+    movie.created_at,
+    movie.hidden_at,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
+    	FROM movies AS movie 
+    	WHERE movie.id=?;`
+
+	// The moderators read the writer, their actions follow each other closely.
+	row := sr.db.Writer().QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), movieID)
+
+	var movie Movie
+	var firstName, lastName string
+	if err := row.Scan(
+		&movie.ID,
+		&movie.Title,
+		&movie.Description,
+		&movie.UserID,
+		&movie.CreatedAt,
+		&movie.HiddenAt,
+		&movie.Likes,
+		&movie.Hates,
+		&firstName,
+		&lastName,
+	); err != nil {
+		return nil, sr.logErr(ctx, "GetAnyMovie", err)
+	}
+	movie.PostedBy = postedBy(firstName, lastName)
+
+	return &movie, nil
+}
+
+// CreateMovie creates a new movie and sets its id.
 func (sr *Repository) CreateMovie(ctx context.Context, movie *SQLMovie) error {
 	sqlQuery := `INSERT INTO movies (
 		title,
 		user_id,
-		description
+		description,
+		title_key,
+		title_prefix,
+		title_suffix,
+		updated_at
 	) VALUES(
+		?,
+		?,
+		?,
+		?,
 		?,
 		?,
 		?
 	);`
 
-	_, err := sr.write.ExecContext(ctx,
-		sqlQuery,
-		movie.Title,
-		movie.UserID,
-		movie.Description,
-	)
+	// The movies without key are keyed by SetTitleKeys.
+	var key, prefix, suffix *string
+	if movie.TitleKey != "" {
+		p, s := TitleEnds(movie.TitleKey)
+		key, prefix, suffix = &movie.TitleKey, &p, &s
+	}
+	var id int
+	err := sr.write(ctx, "CreateMovie", 0, func(tx *sql.Tx) error {
+		var err error
+		id, err = sqlrepo.InsertID(ctx, tx, sr.driver,
+			sqlQuery,
+			movie.Title,
+			movie.UserID,
+			movie.Description,
+			key,
+			prefix,
+			suffix,
+			time.Now().UTC(),
+		)
+
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	movie.ID = &id
+	sr.db.Wrote(ctx, sqlrepo.UserSession(movie.UserID))
 
 	return nil
 }
@@ -265,15 +489,20 @@ func (sr *Repository) AddMovieAction(ctx context.Context, movieID, userID int, a
 		?
 	);`
 
-	_, err := sr.write.ExecContext(ctx,
-		sqlQuery,
-		movieID,
-		userID,
-		action,
-	)
+	err := sr.write(ctx, "AddMovieAction", movieID, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			sqlrepo.Rebind(sr.driver, sqlQuery),
+			movieID,
+			userID,
+			action,
+		)
+
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
 
 	return nil
 }
@@ -282,19 +511,224 @@ func (sr *Repository) AddMovieAction(ctx context.Context, movieID, userID int, a
 func (sr *Repository) RemoveMovieAction(ctx context.Context, movieID, userID int, action string) error {
 	sqlQuery := `DELETE FROM movies_users_actions WHERE movie_id=? AND user_id=? AND action=?;`
 
-	_, err := sr.write.ExecContext(ctx,
-		sqlQuery,
-		movieID,
-		userID,
-		action,
-	)
+	err := sr.write(ctx, "RemoveMovieAction", movieID, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			sqlrepo.Rebind(sr.driver, sqlQuery),
+			movieID,
+			userID,
+			action,
+		)
+
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
+
+	return nil
+}
+
+// SetMovieAction sets the action of a user for a visible movie in one transaction, replacing any previous one,
+// an empty action retracts it. It returns the previous action, sql.ErrNoRows when the movie is not found and
+// ErrOwnMovie when the user submitted it.
+func (sr *Repository) SetMovieAction(ctx context.Context, movieID, userID int, action string) (string, error) {
+	sqlQuery := `SELECT 
+    movie.user_id,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like' AND user_id=?) AS usr_liked,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate' AND user_id=?) AS usr_hated
+    	FROM movies AS movie 
+    	WHERE movie.id=? AND movie.hidden_at IS NULL;`
+
+	var previous string
+	set := func(tx *sql.Tx) error {
+		// The vote is read from the writer, a lagging reader would replace a vote it has not seen yet.
+		var ownerID int
+		var liked, hated bool
+		err := tx.QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), userID, userID, movieID).
+			Scan(&ownerID, &liked, &hated)
+		if err != nil {
+			return err
+		}
+		if ownerID == userID {
+			return ErrOwnMovie
+		}
+		switch previous = ""; {
+		case liked:
+			previous = "like"
+		case hated:
+			previous = "hate"
+		}
+
+		// Each change is a single statement, a switched vote is never retracted on its own.
+		switch {
+		case previous == action:
+			return nil
+		case action == "":
+			_, err = tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver,
+				`DELETE FROM movies_users_actions WHERE movie_id=? AND user_id=?;`), movieID, userID)
+		case previous == "":
+			_, err = tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver,
+				`INSERT INTO movies_users_actions (movie_id, user_id, action) VALUES(?, ?, ?);`), movieID, userID, action)
+		default:
+			_, err = tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver,
+				`UPDATE movies_users_actions SET action=? WHERE movie_id=? AND user_id=?;`), action, movieID, userID)
+		}
+
+		return err
+	}
+
+	err := sr.write(ctx, "SetMovieAction", movieID, set)
+	if sqlrepo.IsDuplicate(err) {
+		// A concurrent request of the user voted first, the vote is set over it.
+		err = sr.write(ctx, "SetMovieAction", movieID, set)
+	}
+	if err != nil {
+		return "", err
+	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
+
+	return previous, nil
+}
+
+// CountUserMoviesSince counts the movies created by a user since a time, it reads the writer so that the
+// limits of the submissions hold while the reader lags.
+func (sr *Repository) CountUserMoviesSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	sqlQuery := `SELECT COUNT(*) FROM movies WHERE user_id=? AND created_at >= ?`
+
+	var n int
+	err := sr.db.Writer().QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), userID, since.UTC()).Scan(&n)
+	if err != nil {
+		return 0, sr.logErr(ctx, "CountUserMoviesSince", err)
+	}
+
+	return n, nil
+}
+
+// GetSimilarTitles returns the visible movies whose title keys share the prefix or the suffix of key, ordered by
+// id, along with the movies without key.
+func (sr *Repository) GetSimilarTitles(ctx context.Context, key string) ([]Title, error) {
+	sqlQuery := `SELECT id, title, title_key FROM movies
+		WHERE hidden_at IS NULL AND (title_prefix=? OR title_suffix=? OR title_prefix IS NULL)
+		ORDER BY id`
+
+	prefix, suffix := TitleEnds(key)
+	rows, err := sr.db.Reader(ctx, "").QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), prefix, suffix)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetSimilarTitles", err)
+	}
+	defer rows.Close()
+
+	var titles []Title
+	for rows.Next() {
+		var (
+			t   Title
+			key sql.NullString
+		)
+		if err = rows.Scan(&t.ID, &t.Title, &key); err != nil {
+			return nil, sr.logErr(ctx, "GetSimilarTitles", err)
+		}
+		t.Key = key.String
+		titles = append(titles, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, sr.logErr(ctx, "GetSimilarTitles", err)
+	}
+
+	return titles, nil
+}
+
+// SetTitleKeys sets the title keys of the movies created before the keys, by movie id.
+func (sr *Repository) SetTitleKeys(ctx context.Context, keys map[int]string) error {
+	sqlQuery := `UPDATE movies SET title_key=?, title_prefix=?, title_suffix=? WHERE id=?`
+
+	for id, key := range keys {
+		prefix, suffix := TitleEnds(key)
+		_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), key, prefix, suffix, id)
+		if err != nil {
+			return sr.logErr(ctx, "SetTitleKeys", err)
+		}
+	}
 
 	return nil
 }
 
+// SetHidden hides a movie from the listings or shows it again.
+func (sr *Repository) SetHidden(ctx context.Context, movieID int, hidden bool) error {
+	sqlQuery := `UPDATE movies SET hidden_at=?, updated_at=? WHERE id=?`
+
+	now := time.Now().UTC()
+	var hiddenAt *time.Time
+	if hidden {
+		hiddenAt = &now
+	}
+	_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), hiddenAt, now, movieID)
+
+	return sr.logErr(ctx, "SetHidden", err)
+}
+
+// DeleteMovie deletes a movie along with its actions and reports.
+func (sr *Repository) DeleteMovie(ctx context.Context, movieID int) error {
+	return sr.write(ctx, "DeleteMovie", 0, func(tx *sql.Tx) error {
+		for _, sqlQuery := range []string{
+			`DELETE FROM movies_users_actions WHERE movie_id=?`,
+			`DELETE FROM movie_reports WHERE movie_id=?`,
+			`DELETE FROM movies WHERE id=?`,
+		} {
+			if _, err := tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), movieID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// MergeMovies folds the votes of a movie into another movie and deletes it along with its reports. The votes
+// of the users who voted for both movies, or who submitted the other movie, are dropped. It returns the number
+// of moved votes.
+func (sr *Repository) MergeMovies(ctx context.Context, movieID, intoID int) (int, error) {
+	var moved int64
+	err := sr.write(ctx, "MergeMovies", intoID, func(tx *sql.Tx) error {
+		// MySQL cannot select from the table it deletes from but from a derived table of it.
+		sqlQuery := `DELETE FROM movies_users_actions WHERE movie_id=? AND (
+			user_id IN (SELECT user_id FROM (SELECT user_id FROM movies_users_actions WHERE movie_id=?) AS voters)
+			OR user_id IN (SELECT user_id FROM movies WHERE id=?)
+		)`
+		if _, err := tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), movieID, intoID, intoID); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver, `UPDATE movies_users_actions SET movie_id=? WHERE movie_id=?`),
+			intoID, movieID)
+		if err != nil {
+			return err
+		}
+		if moved, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		for _, sqlQuery := range []string{
+			`DELETE FROM movie_reports WHERE movie_id=?`,
+			`DELETE FROM movies WHERE id=?`,
+		} {
+			if _, err = tx.ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), movieID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(moved), nil
+}
+
+// postedBy returns the full name of the movie author.
+func postedBy(firstName, lastName string) string {
+	return firstName + " " + lastName
+}
+
 // ConvertSortTypeToOrderByColumn converts sort type to db column name type.
 func ConvertSortTypeToOrderByColumn(sortType string) string {
 	var orderSQL string
@@ -3,6 +3,7 @@ package movie
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 // Mock describes a mock struct.
@@ -38,6 +39,13 @@ func (m *Mock) GetUserMoviesPublic(ctx context.Context, userID int, sortType str
 	return args.Get(0).([]Movie), args.Error(1)
 }
 
+// GetMovie mock.
+func (m *Mock) GetMovie(ctx context.Context, movieID, authUsrID int) (*Movie, error) {
+	args := m.MethodCalled("GetMovie", ctx, movieID, authUsrID)
+
+	return args.Get(0).(*Movie), args.Error(1)
+}
+
 // CreateMovie mock.
 func (m *Mock) CreateMovie(ctx context.Context, movie *SQLMovie) error {
 	args := m.MethodCalled("CreateMovie", ctx, movie)
@@ -58,3 +66,66 @@ func (m *Mock) RemoveMovieAction(ctx context.Context, movieID, userID int, actio
 
 	return args.Error(0)
 }
+
+// SetMovieAction mock.
+func (m *Mock) SetMovieAction(ctx context.Context, movieID, userID int, action string) (string, error) {
+	args := m.MethodCalled("SetMovieAction", ctx, movieID, userID, action)
+
+	return args.String(0), args.Error(1)
+}
+
+// DeleteMovie mock.
+func (m *Mock) DeleteMovie(ctx context.Context, movieID int) error {
+	args := m.MethodCalled("DeleteMovie", ctx, movieID)
+
+	return args.Error(0)
+}
+
+// GetAnyMovie mock.
+func (m *Mock) GetAnyMovie(ctx context.Context, movieID int) (*Movie, error) {
+	args := m.MethodCalled("GetAnyMovie", ctx, movieID)
+
+	return args.Get(0).(*Movie), args.Error(1)
+}
+
+// SetHidden mock.
+func (m *Mock) SetHidden(ctx context.Context, movieID int, hidden bool) error {
+	args := m.MethodCalled("SetHidden", ctx, movieID, hidden)
+
+	return args.Error(0)
+}
+
+// CountUserMoviesSince mock.
+func (m *Mock) CountUserMoviesSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	args := m.MethodCalled("CountUserMoviesSince", ctx, userID, since)
+
+	return args.Int(0), args.Error(1)
+}
+
+// GetSimilarTitles mock.
+func (m *Mock) GetSimilarTitles(ctx context.Context, key string) ([]Title, error) {
+	args := m.MethodCalled("GetSimilarTitles", ctx, key)
+
+	return args.Get(0).([]Title), args.Error(1)
+}
+
+// SetTitleKeys mock.
+func (m *Mock) SetTitleKeys(ctx context.Context, keys map[int]string) error {
+	args := m.MethodCalled("SetTitleKeys", ctx, keys)
+
+	return args.Error(0)
+}
+
+// MergeMovies mock.
+func (m *Mock) MergeMovies(ctx context.Context, movieID, intoID int) (int, error) {
+	args := m.MethodCalled("MergeMovies", ctx, movieID, intoID)
+
+	return args.Int(0), args.Error(1)
+}
+
+// GetChange mock.
+func (m *Mock) GetChange(ctx context.Context, authUsrID int, scope ChangeScope) (*Change, error) {
+	args := m.MethodCalled("GetChange", ctx, authUsrID, scope)
+
+	return args.Get(0).(*Change), args.Error(1)
+}
@@ -3,13 +3,21 @@ package movie_test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
+	sqlrepo "movierama/internal/infra/repository/sql"
 	"movierama/internal/infra/repository/sql/movie"
+	"movierama/internal/logging"
 	"testing"
 	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 type dbMock struct {
@@ -21,24 +29,33 @@ func Test_NewRepository(t *testing.T) {
 	tests := map[string]struct {
 		reader *sql.DB
 		writer *sql.DB
+		logger *zap.Logger
 		expErr error
 	}{
 		"success": {
 			reader: &sql.DB{},
 			writer: &sql.DB{},
+			logger: zap.NewNop(),
 		},
 		"missing reader": {
 			writer: &sql.DB{},
+			logger: zap.NewNop(),
 			expErr: errors.New("db reader is nil"),
 		},
 		"missing writer": {
 			reader: &sql.DB{},
+			logger: zap.NewNop(),
 			expErr: errors.New("db writer is nil"),
 		},
+		"missing logger": {
+			reader: &sql.DB{},
+			writer: &sql.DB{},
+			expErr: errors.New("logger is nil"),
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := movie.NewRepository(tt.reader, tt.writer)
+			got, err := movie.NewRepository(tt.reader, tt.writer, tt.logger)
 			if tt.expErr != nil {
 				assert.Equal(t, tt.expErr, err)
 			} else {
@@ -62,8 +79,8 @@ func Test_GetMoviesPublic(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
-				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "posted_by"}).
-					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, "user 1")
+				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "first_name", "last_name"}).
+					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, "user", "1")
 
 				mock.ExpectQuery(fmt.Sprintf(`SELECT 
     movie.id,
@@ -73,9 +90,11 @@ func Test_GetMoviesPublic(t *testing.T) {
     movie.created_at,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
-    (SELECT CONCAT_WS(' ', first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("date"))).
+    	WHERE movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("date"))).
 					WillReturnRows(rows)
 
 				return dbMock{
@@ -109,10 +128,13 @@ func Test_GetMoviesPublic(t *testing.T) {
     movie.created_at,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
-    (SELECT CONCAT_WS(' ', first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("date"))).
+    	WHERE movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("date"))).
 					WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
 
 				return dbMock{
 					db:   db,
@@ -125,7 +147,7 @@ func Test_GetMoviesPublic(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			resp, err := repo.GetMoviesPublic(context.TODO(), tt.sortType)
 			assert.Equal(t, tt.expRes, resp)
 			if tt.expErr != nil {
@@ -150,8 +172,8 @@ func Test_GetMovies(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
-				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "usr_liked", "usr_hated", "posted_by"}).
-					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, 1, 0, "user 1")
+				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "usr_liked", "usr_hated", "first_name", "last_name"}).
+					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, 1, 0, "user", "1")
 
 				mock.ExpectQuery(fmt.Sprintf(`SELECT 
     movie.id,
@@ -163,9 +185,11 @@ func Test_GetMovies(t *testing.T) {
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
-    (SELECT CONCAT_WS(' ', first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("date")),
+    	WHERE movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("date")),
 				).
 					WithArgs(6, 6).
 					WillReturnRows(rows)
@@ -205,12 +229,15 @@ func Test_GetMovies(t *testing.T) {
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
-    (SELECT CONCAT_WS(' ', first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("date")),
+    	WHERE movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("date")),
 				).
 					WithArgs(6, 6).
 					WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
 
 				return dbMock{
 					db:   db,
@@ -223,7 +250,7 @@ func Test_GetMovies(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			resp, err := repo.GetMovies(context.TODO(), tt.authUserID, tt.sortType)
 			assert.Equal(t, tt.expRes, resp)
 			if tt.expErr != nil {
@@ -248,8 +275,8 @@ func Test_GetUserMoviesPublic(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
-				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "posted_by"}).
-					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, "user 1")
+				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "first_name", "last_name"}).
+					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, "user", "1")
 
 				mock.ExpectQuery(fmt.Sprintf(`SELECT 
     movie.id,
@@ -259,10 +286,11 @@ func Test_GetUserMoviesPublic(t *testing.T) {
     movie.created_at,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
-    (SELECT CONCAT_WS(" ", first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    	WHERE user_id=?
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("date"))).
+    	WHERE user_id=? AND movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("date"))).
 					WithArgs(8).
 					WillReturnRows(rows)
 
@@ -298,12 +326,14 @@ func Test_GetUserMoviesPublic(t *testing.T) {
     movie.created_at,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
-    (SELECT CONCAT_WS(" ", first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    	WHERE user_id=?
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("likes"))).
+    	WHERE user_id=? AND movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("likes"))).
 					WithArgs(8).
 					WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
 
 				return dbMock{
 					db:   db,
@@ -316,7 +346,7 @@ func Test_GetUserMoviesPublic(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			resp, err := repo.GetUserMoviesPublic(context.TODO(), tt.userID, tt.sortType)
 			assert.Equal(t, tt.expRes, resp)
 			if tt.expErr != nil {
@@ -343,8 +373,8 @@ func Test_GetUserMovies(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
-				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "usr_liked", "usr_hated", "posted_by"}).
-					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, 1, 0, "user 1")
+				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "usr_liked", "usr_hated", "first_name", "last_name"}).
+					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, 1, 0, "user", "1")
 
 				mock.ExpectQuery(fmt.Sprintf(`SELECT 
     movie.id,
@@ -356,10 +386,11 @@ func Test_GetUserMovies(t *testing.T) {
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
-    (SELECT CONCAT_WS(" ", first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    	WHERE user_id=?
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("hates")),
+    	WHERE user_id=? AND movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("hates")),
 				).
 					WithArgs(6, 6, 9).
 					WillReturnRows(rows)
@@ -400,13 +431,15 @@ func Test_GetUserMovies(t *testing.T) {
     (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
     (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
-    (SELECT CONCAT_WS(" ", first_name, last_name) FROM users WHERE id=movie.user_id) AS posted_by
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
     	FROM movies AS movie 
-    	WHERE user_id=?
-    		ORDER BY %s DESC;`, movie.ConvertSortTypeToOrderByColumn("date")),
+    	WHERE user_id=? AND movie.hidden_at IS NULL
+    		ORDER BY %s DESC, movie.id DESC;`, movie.ConvertSortTypeToOrderByColumn("date")),
 				).
 					WithArgs(6, 6, 9).
 					WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
 
 				return dbMock{
 					db:   db,
@@ -419,7 +452,7 @@ func Test_GetUserMovies(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			resp, err := repo.GetUserMovies(context.TODO(), tt.userID, tt.authUserID, tt.sortType)
 			assert.Equal(t, tt.expRes, resp)
 			if tt.expErr != nil {
@@ -440,21 +473,32 @@ func Test_CreateMovie(t *testing.T) {
 				UserID:      6,
 				Title:       "movie title",
 				Description: "movie description",
+				TitleKey:    "movie title",
 			},
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				mock.ExpectBegin()
 				mock.ExpectExec(`INSERT INTO movies (
 		title,
 		user_id,
-		description
+		description,
+		title_key,
+		title_prefix,
+		title_suffix,
+		updated_at
 	) VALUES(
+		?,
+		?,
+		?,
+		?,
 		?,
 		?,
 		?
 	);`,
 				).
-					WithArgs("movie title", 6, "movie description").
+					WithArgs("movie title", 6, "movie description", "movie title", "mov", "tle", anyTime{}).
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectTouch(mock, 0)
 
 				return dbMock{
 					db:   db,
@@ -467,22 +511,33 @@ func Test_CreateMovie(t *testing.T) {
 				UserID:      6,
 				Title:       "movie title",
 				Description: "movie description",
+				TitleKey:    "movie title",
 			},
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
+				mock.ExpectBegin()
 				mock.ExpectExec(`INSERT INTO movies (
 		title,
 		user_id,
-		description
+		description,
+		title_key,
+		title_prefix,
+		title_suffix,
+		updated_at
 	) VALUES(
+		?,
+		?,
+		?,
+		?,
 		?,
 		?,
 		?
 	);`,
 				).
-					WithArgs("movie title", 6, "movie description").
+					WithArgs("movie title", 6, "movie description", "movie title", "mov", "tle", anyTime{}).
 					WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
 
 				return dbMock{
 					db:   db,
@@ -495,7 +550,7 @@ func Test_CreateMovie(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			err := repo.CreateMovie(context.TODO(), tt.movie)
 			assert.Equal(t, tt.expErr, err)
 		})
@@ -515,6 +570,7 @@ func Test_AddMovieAction(t *testing.T) {
 			action:  "like",
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				mock.ExpectBegin()
 				mock.ExpectExec(`INSERT INTO movies_users_actions (
 		movie_id,
 		user_id,
@@ -527,6 +583,7 @@ func Test_AddMovieAction(t *testing.T) {
 				).
 					WithArgs(1, 2, "like").
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectTouch(mock, 1)
 
 				return dbMock{
 					db:   db,
@@ -541,6 +598,7 @@ func Test_AddMovieAction(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
+				mock.ExpectBegin()
 				mock.ExpectExec(`INSERT INTO movies_users_actions (
 		movie_id,
 		user_id,
@@ -553,6 +611,7 @@ func Test_AddMovieAction(t *testing.T) {
 				).
 					WithArgs(1, 2, "like").
 					WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
 
 				return dbMock{
 					db:   db,
@@ -565,7 +624,7 @@ func Test_AddMovieAction(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			err := repo.AddMovieAction(context.TODO(), tt.movieID, tt.userID, tt.action)
 			assert.Equal(t, tt.expErr, err)
 		})
@@ -585,9 +644,11 @@ func Test_RemoveMovieAction(t *testing.T) {
 			action:  "like",
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				mock.ExpectBegin()
 				mock.ExpectExec(`DELETE FROM movies_users_actions WHERE movie_id=? AND user_id=? AND action=?;`).
 					WithArgs(1, 2, "like").
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectTouch(mock, 1)
 
 				return dbMock{
 					db:   db,
@@ -602,9 +663,11 @@ func Test_RemoveMovieAction(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
+				mock.ExpectBegin()
 				mock.ExpectExec(`DELETE FROM movies_users_actions WHERE movie_id=? AND user_id=? AND action=?;`).
 					WithArgs(1, 2, "like").
 					WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
 
 				return dbMock{
 					db:   db,
@@ -617,9 +680,577 @@ func Test_RemoveMovieAction(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			err := repo.RemoveMovieAction(context.TODO(), tt.movieID, tt.userID, tt.action)
 			assert.Equal(t, tt.expErr, err)
 		})
 	}
 }
+
+func Test_SetMovieAction(t *testing.T) {
+	const selectVote = `SELECT\s+movie\.user_id,.+WHERE movie\.id=\? AND movie\.hidden_at IS NULL;`
+	cols := []string{"user_id", "usr_liked", "usr_hated"}
+	cases := map[string]struct {
+		action      string
+		expect      func(mock sqlmock.Sqlmock)
+		expPrevious string
+		expErr      error
+	}{
+		"should add a vote": {
+			action: "like",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectVote).WithArgs(2, 2, 1).WillReturnRows(sqlmock.NewRows(cols).AddRow(3, 0, 0))
+				mock.ExpectExec(`INSERT INTO movies_users_actions \(movie_id, user_id, action\) VALUES\(\?, \?, \?\);`).
+					WithArgs(1, 2, "like").WillReturnResult(sqlmock.NewResult(1, 1))
+				expectTouch(mock, 1)
+			},
+		},
+		"should switch a vote in one statement": {
+			action: "hate",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectVote).WithArgs(2, 2, 1).WillReturnRows(sqlmock.NewRows(cols).AddRow(3, 1, 0))
+				mock.ExpectExec(`UPDATE movies_users_actions SET action=\? WHERE movie_id=\? AND user_id=\?;`).
+					WithArgs("hate", 1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectTouch(mock, 1)
+			},
+			expPrevious: "like",
+		},
+		"should retract a vote": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectVote).WithArgs(2, 2, 1).WillReturnRows(sqlmock.NewRows(cols).AddRow(3, 0, 1))
+				mock.ExpectExec(`DELETE FROM movies_users_actions WHERE movie_id=\? AND user_id=\?;`).
+					WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectTouch(mock, 1)
+			},
+			expPrevious: "hate",
+		},
+		"should set the vote over a concurrent first vote": {
+			action: "like",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectVote).WithArgs(2, 2, 1).WillReturnRows(sqlmock.NewRows(cols).AddRow(3, 0, 0))
+				mock.ExpectExec(`INSERT INTO movies_users_actions`).WithArgs(1, 2, "like").
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '2-1' for key 'user_id'"})
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectVote).WithArgs(2, 2, 1).WillReturnRows(sqlmock.NewRows(cols).AddRow(3, 0, 1))
+				mock.ExpectExec(`UPDATE movies_users_actions SET action=\? WHERE movie_id=\? AND user_id=\?;`).
+					WithArgs("like", 1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectTouch(mock, 1)
+			},
+			expPrevious: "hate",
+		},
+		"should not vote for own movie": {
+			action: "like",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectVote).WithArgs(2, 2, 1).WillReturnRows(sqlmock.NewRows(cols).AddRow(2, 0, 0))
+				mock.ExpectRollback()
+			},
+			expErr: movie.ErrOwnMovie,
+		},
+		"should not vote for a missing movie": {
+			action: "like",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectVote).WithArgs(2, 2, 1).WillReturnRows(sqlmock.NewRows(cols))
+				mock.ExpectRollback()
+			},
+			expErr: sql.ErrNoRows,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			// The exact queries of expectChange are matched along with the regexps.
+			matcher := sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+				if sqlmock.QueryMatcherEqual.Match(expected, actual) == nil {
+					return nil
+				}
+				return sqlmock.QueryMatcherRegexp.Match(expected, actual)
+			})
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+			assert.NoError(t, err)
+			defer db.Close()
+			tt.expect(mock)
+
+			repo, _ := movie.NewRepository(db, db, zap.NewNop())
+			previous, err := repo.SetMovieAction(context.TODO(), 1, 2, tt.action)
+			assert.Equal(t, tt.expErr, err)
+			assert.Equal(t, tt.expPrevious, previous)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_DeleteMovie(t *testing.T) {
+	cases := map[string]struct {
+		expect func(mock sqlmock.Sqlmock)
+		expErr error
+	}{
+		"should delete the movie with its actions and reports": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM movies_users_actions WHERE movie_id=?`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(`DELETE FROM movie_reports WHERE movie_id=?`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`DELETE FROM movies WHERE id=?`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectTouch(mock, 0)
+			},
+		},
+		"should roll back on sql error": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM movies_users_actions WHERE movie_id=?`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(`DELETE FROM movie_reports WHERE movie_id=?`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`DELETE FROM movies WHERE id=?`).WithArgs(1).WillReturnError(errors.New("sql error"))
+				mock.ExpectRollback()
+			},
+			expErr: errors.New("sql error"),
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			assert.NoError(t, err)
+			defer db.Close()
+			tt.expect(mock)
+
+			repo, _ := movie.NewRepository(db, db, zap.NewNop())
+			assert.Equal(t, tt.expErr, repo.DeleteMovie(context.TODO(), 1))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_SetHidden(t *testing.T) {
+	cases := map[string]struct {
+		hidden bool
+		expArg driver.Value
+	}{
+		"should hide the movie": {
+			hidden: true,
+			expArg: anyTime{},
+		},
+		"should show the movie": {
+			expArg: nil,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			assert.NoError(t, err)
+			defer db.Close()
+			mock.ExpectExec(`UPDATE movies SET hidden_at=?, updated_at=? WHERE id=?`).WithArgs(tt.expArg, anyTime{}, 1).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			repo, _ := movie.NewRepository(db, db, zap.NewNop())
+			assert.NoError(t, repo.SetHidden(context.TODO(), 1, tt.hidden))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_GetAnyMovie(t *testing.T) {
+	nowTime := time.Now()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(`SELECT 
+    movie.id,
+    movie.title,
+    movie.description,
+    movie.user_id,
+    movie.created_at,
+    movie.hidden_at,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
+    	FROM movies AS movie 
+    	WHERE movie.id=?;`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "hidden_at", "likes", "hates", "first_name", "last_name"}).
+			AddRow(1, "movie title", "movie description", 4, nowTime, nowTime, 2, 3, "user", "1"))
+
+	repo, _ := movie.NewRepository(db, db, zap.NewNop())
+	resp, err := repo.GetAnyMovie(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &movie.Movie{
+		ID:          1,
+		UserID:      4,
+		Title:       "movie title",
+		Description: "movie description",
+		PostedBy:    "user 1",
+		Likes:       2,
+		Hates:       3,
+		CreatedAt:   nowTime,
+		HiddenAt:    &nowTime,
+	}, resp)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_GetMovie(t *testing.T) {
+	nowTime := time.Now()
+	query := `SELECT 
+    movie.id,
+    movie.title,
+    movie.description,
+    movie.user_id,
+    movie.created_at,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='like') AS likes,
+    (SELECT COUNT(*) FROM movies_users_actions WHERE movie_id=movie.id AND action='hate') AS hates,
+    (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='like' AND a.user_id=?) AS usr_liked,
+    (SELECT COUNT(*) FROM movies_users_actions a WHERE movie_id=movie.id AND action='hate' AND a.user_id=?) AS usr_hated,
+    (SELECT first_name FROM users WHERE id=movie.user_id) AS first_name,
+    (SELECT last_name FROM users WHERE id=movie.user_id) AS last_name
+    	FROM movies AS movie 
+    	WHERE movie.id=? AND movie.hidden_at IS NULL;`
+	cases := map[string]struct {
+		dbMock dbMock
+		expRes *movie.Movie
+		expErr error
+	}{
+		"should return movie": {
+			dbMock: func() dbMock {
+				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+
+				rows := sqlmock.NewRows([]string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "usr_liked", "usr_hated", "first_name", "last_name"}).
+					AddRow(1, "movie title", "movie description", 4, nowTime, 2, 3, 0, 1, "user", "1")
+
+				mock.ExpectQuery(query).
+					WithArgs(6, 6, 1).
+					WillReturnRows(rows)
+
+				return dbMock{
+					db:   db,
+					mock: mock,
+				}
+			}(),
+			expRes: &movie.Movie{
+				ID:          1,
+				UserID:      4,
+				Title:       "movie title",
+				Description: "movie description",
+				PostedBy:    "user 1",
+				Likes:       2,
+				Hates:       3,
+				UserLiked:   false,
+				UserHated:   true,
+				CreatedAt:   nowTime,
+			},
+		},
+		"should return error on missing movie": {
+			dbMock: func() dbMock {
+				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+
+				mock.ExpectQuery(query).
+					WithArgs(6, 6, 1).
+					WillReturnError(sql.ErrNoRows)
+
+				return dbMock{
+					db:   db,
+					mock: mock,
+				}
+			}(),
+			expErr: sql.ErrNoRows,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			repo, _ := movie.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
+			resp, err := repo.GetMovie(context.TODO(), 1, 6)
+			assert.Equal(t, tt.expRes, resp)
+			assert.Equal(t, tt.expErr, err)
+		})
+	}
+}
+
+func Test_LogsQueryErrors(t *testing.T) {
+	cases := map[string]struct {
+		err     error
+		expLogs int
+	}{
+		"should log sql errors with the request id": {
+			err:     errors.New("sql error"),
+			expLogs: 1,
+		},
+		"should not log missing rows": {
+			err: sql.ErrNoRows,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New()
+			mock.ExpectQuery(`SELECT`).WillReturnError(tt.err)
+			core, logs := observer.New(zapcore.DebugLevel)
+
+			repo, _ := movie.NewRepository(db, db, zap.New(core))
+			_, err := repo.GetMovie(logging.WithRequestID(context.TODO(), "rid"), 1, 0)
+			assert.Equal(t, tt.err, err)
+
+			assert.Equal(t, tt.expLogs, logs.Len())
+			for _, entry := range logs.All() {
+				assert.Equal(t, zapcore.ErrorLevel, entry.Level)
+				assert.Equal(t, "rid", entry.ContextMap()["request_id"])
+				assert.Equal(t, "GetMovie", entry.ContextMap()["query"])
+			}
+		})
+	}
+}
+
+func Test_Postgres(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, err := movie.NewPostgresRepository(db, db, zap.NewNop())
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO movies (
+		title,
+		user_id,
+		description,
+		title_key,
+		title_prefix,
+		title_suffix,
+		updated_at
+	) VALUES(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6,
+		$7
+	) RETURNING id;`).
+		WithArgs("movie title", 6, "movie description", nil, nil, nil, anyTime{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+	m := &movie.SQLMovie{UserID: 6, Title: "movie title", Description: "movie description"}
+	assert.NoError(t, repo.CreateMovie(context.TODO(), m))
+	if assert.NotNil(t, m.ID) {
+		assert.Equal(t, 3, *m.ID)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM movies_users_actions WHERE movie_id=$1 AND user_id=$2 AND action=$3;`).
+		WithArgs(3, 2, "like").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE movies SET updated_at=$1 WHERE id=$2`).WithArgs(anyTime{}, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.RemoveMovieAction(context.TODO(), 3, 2, "like"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_ReadYourWrites(t *testing.T) {
+	reader, readerMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer reader.Close()
+	writer, writerMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer writer.Close()
+	repo, err := movie.NewRepository(reader, writer, zap.NewNop())
+	assert.NoError(t, err)
+	repo.WithRouter(sqlrepo.NewRouter(reader, writer, time.Minute))
+
+	columns := []string{"id", "title", "description", "user_id", "created_at", "likes", "hates", "usr_liked",
+		"usr_hated", "first_name", "last_name"}
+	writerMock.ExpectBegin()
+	writerMock.ExpectExec("INSERT INTO movies_users_actions").WithArgs(1, 2, "like").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	writerMock.ExpectExec("UPDATE movies SET updated_at").WithArgs(anyTime{}, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	writerMock.ExpectCommit()
+	// The voter reads its vote from the writer, the other users from the lagging reader.
+	writerMock.ExpectQuery("SELECT").WithArgs(2, 2, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "title", "desc", 3, time.Time{}, 1, 0, 1, 0, "user", "1"))
+	readerMock.ExpectQuery("SELECT").WithArgs(4, 4, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "title", "desc", 3, time.Time{}, 0, 0, 0, 0, "user", "1"))
+
+	assert.NoError(t, repo.AddMovieAction(context.TODO(), 1, 2, "like"))
+	m, err := repo.GetMovie(context.TODO(), 1, 2)
+	assert.NoError(t, err)
+	assert.True(t, m.UserLiked)
+	_, err = repo.GetMovie(context.TODO(), 1, 4)
+	assert.NoError(t, err)
+
+	assert.NoError(t, writerMock.ExpectationsWereMet())
+	assert.NoError(t, readerMock.ExpectationsWereMet())
+}
+
+// anyTime matches any time argument.
+type anyTime struct{}
+
+// Match implements sqlmock.Argument.
+func (anyTime) Match(v driver.Value) bool {
+	_, ok := v.(time.Time)
+	return ok
+}
+
+// expectTouch expects the movie of movieID, unless zero, to be touched along with the writes, then the commit.
+func expectTouch(mock sqlmock.Sqlmock, movieID int) {
+	if movieID != 0 {
+		mock.ExpectExec(`UPDATE movies SET updated_at=? WHERE id=?`).WithArgs(anyTime{}, movieID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func Test_CountUserMoviesSince(t *testing.T) {
+	since := time.Date(2026, 10, 21, 9, 0, 0, 0, time.FixedZone("EEST", 3*60*60))
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(`SELECT COUNT(*) FROM movies WHERE user_id=? AND created_at >= ?`).WithArgs(1, since.UTC()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	repo, _ := movie.NewRepository(db, db, zap.NewNop())
+	n, err := repo.CountUserMoviesSince(context.TODO(), 1, since)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_GetSimilarTitles(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery(`SELECT id, title, title_key FROM movies
+		WHERE hidden_at IS NULL AND (title_prefix=? OR title_suffix=? OR title_prefix IS NULL)
+		ORDER BY id`).
+		WithArgs("mat", "rix").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "title_key"}).
+			AddRow(1, "The Matrix", "matrix").AddRow(3, "Alien", nil))
+
+	repo, _ := movie.NewRepository(db, db, zap.NewNop())
+	titles, err := repo.GetSimilarTitles(context.TODO(), "matrix")
+	assert.NoError(t, err)
+	assert.Equal(t, []movie.Title{{ID: 1, Title: "The Matrix", Key: "matrix"}, {ID: 3, Title: "Alien"}}, titles)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_SetTitleKeys(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	mock.ExpectExec(`UPDATE movies SET title_key=?, title_prefix=?, title_suffix=? WHERE id=?`).
+		WithArgs("alien", "ali", "ien", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	repo, _ := movie.NewRepository(db, db, zap.NewNop())
+	assert.NoError(t, repo.SetTitleKeys(context.TODO(), map[int]string{3: "alien"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTitleEnds(t *testing.T) {
+	tests := map[string]struct {
+		key       string
+		expPrefix string
+		expSuffix string
+	}{
+		"should return the first and last runes":   {key: "amélie", expPrefix: "amé", expSuffix: "lie"},
+		"should return a short key as both ends":   {key: "up", expPrefix: "up", expSuffix: "up"},
+		"should return empty ends of an empty key": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			prefix, suffix := movie.TitleEnds(tt.key)
+			assert.Equal(t, tt.expPrefix, prefix)
+			assert.Equal(t, tt.expSuffix, suffix)
+		})
+	}
+}
+
+func Test_MergeMovies(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM movies_users_actions WHERE movie_id=? AND (
+			user_id IN (SELECT user_id FROM (SELECT user_id FROM movies_users_actions WHERE movie_id=?) AS voters)
+			OR user_id IN (SELECT user_id FROM movies WHERE id=?)
+		)`).WithArgs(2, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE movies_users_actions SET movie_id=? WHERE movie_id=?`).WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM movie_reports WHERE movie_id=?`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM movies WHERE id=?`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTouch(mock, 1)
+
+	repo, _ := movie.NewRepository(db, db, zap.NewNop())
+	moved, err := repo.MergeMovies(context.TODO(), 2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, moved)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_GetChange(t *testing.T) {
+	changedAt := time.Date(2026, time.October, 25, 9, 0, 0, 0, time.UTC)
+	const query = `SELECT (SELECT COUNT(*) FROM movies WHERE %[1]s), updated_at FROM movies WHERE %[1]s
+		ORDER BY updated_at DESC LIMIT 1`
+	cols := []string{"movies", "updated_at"}
+	cases := map[string]struct {
+		scope     movie.ChangeScope
+		expect    func(mock sqlmock.Sqlmock)
+		expChange *movie.Change
+		expErr    error
+	}{
+		"should return the change of every movie": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fmt.Sprintf(query, "1=1")).WillReturnRows(sqlmock.NewRows(cols).AddRow(42, changedAt))
+			},
+			expChange: &movie.Change{Movies: 42, ChangedAt: changedAt},
+		},
+		"should return the change of the movies of a user": {
+			scope: movie.ChangeScope{UserID: 3},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fmt.Sprintf(query, "user_id=?")).WithArgs(3, 3).
+					WillReturnRows(sqlmock.NewRows(cols).AddRow(2, changedAt))
+			},
+			expChange: &movie.Change{Movies: 2, ChangedAt: changedAt},
+		},
+		"should return the change of a movie": {
+			scope: movie.ChangeScope{UserID: 3, MovieID: 5},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fmt.Sprintf(query, "id=?")).WithArgs(5, 5).
+					WillReturnRows(sqlmock.NewRows(cols).AddRow(1, changedAt))
+			},
+			expChange: &movie.Change{Movies: 1, ChangedAt: changedAt},
+		},
+		"should return the zero change without movies": {
+			scope: movie.ChangeScope{MovieID: 5},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fmt.Sprintf(query, "id=?")).WithArgs(5, 5).WillReturnRows(sqlmock.NewRows(cols))
+			},
+			expChange: &movie.Change{},
+		},
+		"should return error on sql error": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fmt.Sprintf(query, "1=1")).WillReturnError(errors.New("sql error"))
+			},
+			expErr: errors.New("sql error"),
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			assert.NoError(t, err)
+			defer db.Close()
+			tt.expect(mock)
+
+			repo, _ := movie.NewRepository(db, db, zap.NewNop())
+			change, err := repo.GetChange(context.TODO(), 7, tt.scope)
+			assert.Equal(t, tt.expErr, err)
+			assert.Equal(t, tt.expChange, change)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
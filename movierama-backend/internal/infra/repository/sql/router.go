@@ -0,0 +1,193 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Session identifies the author of writes, whose next reads must see them.
+type Session string
+
+// UserSession returns the session of a user, anonymous users have none.
+func UserSession(userID int) Session {
+	if userID == 0 {
+		return ""
+	}
+
+	return Session("user:" + strconv.Itoa(userID))
+}
+
+// UsernameSession returns the session of a username, whose user may not have an id yet.
+func UsernameSession(username string) Session {
+	return Session("username:" + username)
+}
+
+// EmailSession returns the session of an email, whose user may not be known.
+func EmailSession(email string) Session {
+	return Session("email:" + email)
+}
+
+// sessionsRetryInterval is the wait before the session store is tried again after a failure, so that a down
+// store does not slow every read down.
+const sessionsRetryInterval = 5 * time.Second
+
+// Router routes the queries between the reader and the writer databases. The reads of a session go to
+// the writer for a window after its last write so that a lagging replica does not hide them, and every
+// read goes to the writer while the reader is unhealthy.
+type Router struct {
+	reader *sql.DB
+	writer *sql.DB
+	window time.Duration
+	// sessions holds the sessions that wrote within the window.
+	sessions SessionStore
+	log      *zap.Logger
+
+	mu         sync.Mutex
+	readerDown bool
+	// sessionsDown is set while the session store fails, until retryAt.
+	sessionsDown bool
+	retryAt      time.Time
+}
+
+// NewRouter constructor, the reads of a session are not routed to the writer after a write when window is
+// zero. The sessions are kept in memory.
+func NewRouter(reader, writer *sql.DB, window time.Duration) *Router {
+	return &Router{reader: reader, writer: writer, window: window, sessions: NewMemorySessions(), log: zap.NewNop()}
+}
+
+// WithSessions keeps the sessions in store, which the replicas share. The reads of the sessions go to the
+// writer while the store fails.
+func (r *Router) WithSessions(store SessionStore, logger *zap.Logger) *Router {
+	r.sessions, r.log = store, logger
+
+	return r
+}
+
+// Writer returns the handle of the writes.
+func (r *Router) Writer() *sql.DB {
+	return r.writer
+}
+
+// Wrote records that the sessions have just written.
+func (r *Router) Wrote(ctx context.Context, sessions ...Session) {
+	if r.window <= 0 || r.reader == r.writer {
+		return
+	}
+
+	recorded := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		if s != "" {
+			recorded = append(recorded, s)
+		}
+	}
+	if len(recorded) == 0 || r.skipSessions() {
+		return
+	}
+	r.sessionsDone(r.sessions.Wrote(ctx, r.window, recorded...))
+}
+
+// Reader returns the handle of the reads of the session, which may be empty for anonymous reads.
+func (r *Router) Reader(ctx context.Context, s Session) *sql.DB {
+	if r.reader == r.writer {
+		return r.writer
+	}
+
+	r.mu.Lock()
+	down := r.readerDown
+	r.mu.Unlock()
+	switch {
+	case down:
+		return r.writer
+	case s == "" || r.window <= 0:
+		return r.reader
+	case r.skipSessions():
+		// The session may have written, it is only known to the down store.
+		return r.writer
+	}
+	recent, err := r.sessions.Recent(ctx, s)
+	r.sessionsDone(err)
+	if err != nil || recent {
+		return r.writer
+	}
+
+	return r.reader
+}
+
+// skipSessions reports whether the session store failed and is not retried yet.
+func (r *Router) skipSessions() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sessionsDown && time.Now().Before(r.retryAt)
+}
+
+// sessionsDone records the outcome of a call to the session store, logging when it fails or recovers.
+func (r *Router) sessionsDone(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case err != nil && !r.sessionsDown:
+		r.log.Warn("session store failed, reading the sessions from the writer", zap.Error(err))
+	case err == nil && r.sessionsDown:
+		r.log.Info("session store recovered")
+	}
+	r.sessionsDown = err != nil
+	r.retryAt = time.Now().Add(sessionsRetryInterval)
+}
+
+// ReaderHealthy reports whether the reads go to the reader.
+func (r *Router) ReaderHealthy() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return !r.readerDown
+}
+
+// CheckReader pings the reader and routes the reads to the writer while it fails.
+func (r *Router) CheckReader(ctx context.Context) error {
+	if r.reader == r.writer {
+		return nil
+	}
+	err := r.reader.PingContext(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readerDown = err != nil
+
+	return err
+}
+
+// Monitor checks the reader at once, so that a reader down at boot is not read from, then every interval
+// until ctx is done, logging when it goes down or up.
+func (r *Router) Monitor(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	if r.reader == r.writer {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		healthy := r.ReaderHealthy()
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		err := r.CheckReader(checkCtx)
+		cancel()
+		switch {
+		case err != nil && healthy:
+			logger.Warn("reader is unhealthy, reading from the writer", zap.Error(err))
+		case err == nil && !healthy:
+			logger.Info("reader is healthy again")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
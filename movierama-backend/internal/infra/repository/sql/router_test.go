@@ -0,0 +1,168 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRouter_Reader(t *testing.T) {
+	reader, writer := &sql.DB{}, &sql.DB{}
+	alice, bob := sqlrepo.UserSession(1), sqlrepo.UserSession(2)
+
+	tests := map[string]struct {
+		window time.Duration
+		wrote  []sqlrepo.Session
+		wait   time.Duration
+		read   sqlrepo.Session
+		exp    *sql.DB
+	}{
+		"should read from the reader": {
+			window: time.Minute,
+			read:   alice,
+			exp:    reader,
+		},
+		"should read from the writer after a write of the session": {
+			window: time.Minute,
+			wrote:  []sqlrepo.Session{alice},
+			read:   alice,
+			exp:    writer,
+		},
+		"should read from the reader after a write of another session": {
+			window: time.Minute,
+			wrote:  []sqlrepo.Session{bob},
+			read:   alice,
+			exp:    reader,
+		},
+		"should read from the reader after the window": {
+			window: 10 * time.Millisecond,
+			wrote:  []sqlrepo.Session{alice},
+			wait:   20 * time.Millisecond,
+			read:   alice,
+			exp:    reader,
+		},
+		"should read anonymously from the reader": {
+			window: time.Minute,
+			wrote:  []sqlrepo.Session{sqlrepo.UserSession(0)},
+			read:   sqlrepo.UserSession(0),
+			exp:    reader,
+		},
+		"should not route the session without window": {
+			wrote: []sqlrepo.Session{alice},
+			read:  alice,
+			exp:   reader,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := sqlrepo.NewRouter(reader, writer, tt.window)
+			r.Wrote(context.Background(), tt.wrote...)
+			time.Sleep(tt.wait)
+
+			assert.Same(t, tt.exp, r.Reader(context.Background(), tt.read))
+			assert.Same(t, writer, r.Writer())
+		})
+	}
+}
+
+func TestRouter_SharedSessions(t *testing.T) {
+	reader, writer := &sql.DB{}, &sql.DB{}
+	alice := sqlrepo.UserSession(1)
+	mr := miniredis.RunT(t)
+	newRouter := func() *sqlrepo.Router {
+		sessions := sqlrepo.NewRedisSessions(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "movierama:session:")
+		return sqlrepo.NewRouter(reader, writer, time.Minute).WithSessions(sessions, zap.NewNop())
+	}
+	ctx := context.Background()
+
+	// The replicas share the session of the user.
+	newRouter().Wrote(ctx, alice)
+	assert.Same(t, writer, newRouter().Reader(ctx, alice))
+	assert.Same(t, reader, newRouter().Reader(ctx, sqlrepo.UserSession(2)))
+
+	mr.FastForward(time.Minute)
+	assert.Same(t, reader, newRouter().Reader(ctx, alice), "the session expires with the window")
+
+	// The sessions are read from the writer while the store is down.
+	r := newRouter()
+	mr.Close()
+	assert.Same(t, writer, r.Reader(ctx, alice))
+	assert.Same(t, writer, r.Reader(ctx, sqlrepo.UserSession(2)))
+	assert.Same(t, reader, r.Reader(ctx, ""), "the anonymous reads do not need the store")
+}
+
+func TestRouter_CheckReader(t *testing.T) {
+	reader, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer reader.Close()
+	writer := &sql.DB{}
+	r := sqlrepo.NewRouter(reader, writer, 0)
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	assert.Error(t, r.CheckReader(context.Background()))
+	assert.False(t, r.ReaderHealthy())
+	assert.Same(t, writer, r.Reader(context.Background(), ""), "the reads fall back to the writer")
+
+	mock.ExpectPing()
+	assert.NoError(t, r.CheckReader(context.Background()))
+	assert.True(t, r.ReaderHealthy())
+	assert.Same(t, reader, r.Reader(context.Background(), ""))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRouter_Monitor(t *testing.T) {
+	reader, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer reader.Close()
+	r := sqlrepo.NewRouter(reader, &sql.DB{}, 0)
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Monitor(ctx, 5*time.Millisecond, zap.NewNop())
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return !r.ReaderHealthy() }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestRouter_MonitorAtBoot(t *testing.T) {
+	reader, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer reader.Close()
+	r := sqlrepo.NewRouter(reader, &sql.DB{}, 0)
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Monitor(ctx, time.Hour, zap.NewNop())
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return !r.ReaderHealthy() }, time.Second, time.Millisecond,
+		"a reader down at boot is not read from until the first interval")
+	cancel()
+	<-done
+}
+
+func TestRouter_SameDB(t *testing.T) {
+	db := &sql.DB{}
+	r := sqlrepo.NewRouter(db, db, time.Minute)
+	r.Wrote(context.Background(), sqlrepo.UserSession(1))
+
+	assert.Same(t, db, r.Reader(context.Background(), sqlrepo.UserSession(1)))
+	assert.NoError(t, r.CheckReader(context.Background()))
+}
@@ -0,0 +1,100 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// sweepInterval is the interval between two removals of the expired sessions of the memory store.
+const sweepInterval = time.Minute
+
+// SessionStore records the sessions that wrote recently, whose reads go to the writer until their window ends.
+type SessionStore interface {
+	// Wrote records that the sessions have just written, for window.
+	Wrote(ctx context.Context, window time.Duration, sessions ...Session) error
+	// Recent reports whether the session wrote within its window.
+	Recent(ctx context.Context, s Session) (bool, error)
+}
+
+// MemorySessions keeps the sessions in memory, they are not shared by replicas: the reads of a session that
+// land on another replica than its write may miss it, unless the load balancer keeps the sessions sticky.
+type MemorySessions struct {
+	mu sync.Mutex
+	// writes holds the end of the window of the sessions.
+	writes    map[Session]time.Time
+	lastSweep time.Time
+}
+
+// NewMemorySessions constructor.
+func NewMemorySessions() *MemorySessions {
+	return &MemorySessions{writes: make(map[Session]time.Time), lastSweep: time.Now()}
+}
+
+// Wrote implements SessionStore.
+func (m *MemorySessions) Wrote(_ context.Context, window time.Duration, sessions ...Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for s, until := range m.writes {
+			if now.After(until) {
+				delete(m.writes, s)
+			}
+		}
+		m.lastSweep = now
+	}
+	for _, s := range sessions {
+		m.writes[s] = now.Add(window)
+	}
+
+	return nil
+}
+
+// Recent implements SessionStore.
+func (m *MemorySessions) Recent(_ context.Context, s Session) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.writes[s]
+	if ok && time.Now().After(until) {
+		delete(m.writes, s)
+		ok = false
+	}
+
+	return ok, nil
+}
+
+// RedisSessions keeps the sessions in redis, they are shared by replicas.
+type RedisSessions struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisSessions constructor, the keys of the sessions start with prefix.
+func NewRedisSessions(client redis.Cmdable, prefix string) *RedisSessions {
+	return &RedisSessions{client: client, prefix: prefix}
+}
+
+// Wrote implements SessionStore, the keys expire with the windows.
+func (r *RedisSessions) Wrote(ctx context.Context, window time.Duration, sessions ...Session) error {
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, s := range sessions {
+			p.Set(ctx, r.prefix+string(s), 1, window)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Recent implements SessionStore.
+func (r *RedisSessions) Recent(ctx context.Context, s Session) (bool, error) {
+	n, err := r.client.Exists(ctx, r.prefix+string(s)).Result()
+
+	return n > 0, err
+}
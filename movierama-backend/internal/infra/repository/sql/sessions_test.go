@@ -0,0 +1,53 @@
+package sql_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"testing"
+	"time"
+)
+
+func TestSessionStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) sqlrepo.SessionStore{
+		"memory": func(t *testing.T) sqlrepo.SessionStore {
+			return sqlrepo.NewMemorySessions()
+		},
+		"redis": func(t *testing.T) sqlrepo.SessionStore {
+			mr := miniredis.RunT(t)
+			return sqlrepo.NewRedisSessions(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "movierama:session:")
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			alice, bob := sqlrepo.UserSession(1), sqlrepo.EmailSession("bob@movierama.dev")
+
+			require.NoError(t, s.Wrote(ctx, time.Minute, alice, bob))
+			for _, session := range []sqlrepo.Session{alice, bob} {
+				recent, err := s.Recent(ctx, session)
+				require.NoError(t, err)
+				assert.True(t, recent, session)
+			}
+			recent, err := s.Recent(ctx, sqlrepo.UserSession(2))
+			require.NoError(t, err)
+			assert.False(t, recent)
+		})
+	}
+}
+
+func TestMemorySessions_Expiry(t *testing.T) {
+	s := sqlrepo.NewMemorySessions()
+
+	require.NoError(t, s.Wrote(context.Background(), 20*time.Millisecond, sqlrepo.UserSession(1)))
+	time.Sleep(30 * time.Millisecond)
+
+	recent, err := s.Recent(context.Background(), sqlrepo.UserSession(1))
+	require.NoError(t, err)
+	assert.False(t, recent)
+}
@@ -0,0 +1,30 @@
+package sql
+
+import (
+	"database/sql"
+	"net/url"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewSQLite returns a handle to the SQLite database file, it is used as both reader and writer.
+func NewSQLite(path string, tp trace.TracerProvider) (*sql.DB, error) {
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+
+	// WAL lets the readers run along with the writer, which waits for the lock of another one.
+	q := url.Values{}
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	db, err := OpenDB(SQLite, "file:"+path+"?"+q.Encode(), "sqlite", tp)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
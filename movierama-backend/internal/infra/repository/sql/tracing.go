@@ -0,0 +1,242 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "movierama/internal/infra/repository/sql"
+
+// OpenDB opens a database handle that records a span per query with tp,
+// name tells the handles of the same database apart.
+func OpenDB(driverName, dsn, name string, tp trace.TracerProvider) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+
+	var connector driver.Connector = &dsnConnector{dsn: dsn, driver: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(&tracedConnector{
+		Connector: connector,
+		tracer: &queryTracer{
+			tracer: tp.Tracer(tracerName),
+			attrs: []attribute.KeyValue{
+				semconv.DBSystemKey.String(driverName),
+				attribute.String("db.handle", name),
+			},
+		},
+	}), nil
+}
+
+// dsnConnector is the connector of the drivers that do not implement driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type queryTracer struct {
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+// record records a span for a statement that started at start and has just finished.
+// The span is created afterwards since the driver calls do not have child spans, and
+// skipped when the driver asks database/sql to fall back to a prepared statement.
+func (t *queryTracer) record(ctx context.Context, op, query string, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+
+	_, span := t.tracer.Start(ctx, "sql."+op,
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.attrs...),
+		trace.WithAttributes(semconv.DBStatementKey.String(query)),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type tracedConnector struct {
+	driver.Connector
+	tracer *queryTracer
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn, tracer: c.tracer}, nil
+}
+
+type tracedConn struct {
+	driver.Conn
+	tracer *queryTracer
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := qc.QueryContext(ctx, query, args)
+	c.tracer.record(ctx, "query", query, start, err)
+
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := ec.ExecContext(ctx, query, args)
+	c.tracer.record(ctx, "exec", query, start, err)
+
+	return res, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedStmt{Stmt: stmt, query: query, tracer: c.tracer}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.Conn.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	query  string
+	tracer *queryTracer
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args))
+	}
+	s.tracer.record(ctx, "query", s.query, start, err)
+
+	return rows, err
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args))
+	}
+	s.tracer.record(ctx, "exec", s.query, start, err)
+
+	return res, err
+}
+
+func (s *tracedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+
+	return vals
+}
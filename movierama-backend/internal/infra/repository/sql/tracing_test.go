@@ -0,0 +1,91 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/tracing"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestOpenDB(t *testing.T) {
+	tests := map[string]struct {
+		run       func(t *testing.T, mock sqlmock.Sqlmock, ctx context.Context, db *sql.DB)
+		expName   string
+		expQuery  string
+		expStatus codes.Code
+	}{
+		"should trace queries": {
+			run: func(t *testing.T, mock sqlmock.Sqlmock, ctx context.Context, db *sql.DB) {
+				mock.ExpectQuery(`SELECT id FROM users WHERE id IN (?,?)`).
+					WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+				rows, err := db.QueryContext(ctx, `SELECT id FROM users WHERE id IN (?,?)`, 1, 2)
+				require.NoError(t, err)
+				rows.Close()
+			},
+			expName:  "sql.query",
+			expQuery: `SELECT id FROM users WHERE id IN (?,?)`,
+		},
+		"should trace statements": {
+			run: func(t *testing.T, mock sqlmock.Sqlmock, ctx context.Context, db *sql.DB) {
+				mock.ExpectExec(`DELETE FROM movies WHERE id=?`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				_, err := db.ExecContext(ctx, `DELETE FROM movies WHERE id=?`, 1)
+				require.NoError(t, err)
+			},
+			expName:  "sql.exec",
+			expQuery: `DELETE FROM movies WHERE id=?`,
+		},
+		"should record errors": {
+			run: func(t *testing.T, mock sqlmock.Sqlmock, ctx context.Context, db *sql.DB) {
+				mock.ExpectExec(`DELETE FROM movies WHERE id=?`).
+					WithArgs(1).
+					WillReturnError(errors.New("sql error"))
+
+				_, err := db.ExecContext(ctx, `DELETE FROM movies WHERE id=?`, 1)
+				require.Error(t, err)
+			},
+			expName:   "sql.exec",
+			expQuery:  `DELETE FROM movies WHERE id=?`,
+			expStatus: codes.Error,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dsn := "tracing_" + name
+			_, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			tp, exporter := tracing.NewTestProvider()
+
+			db, err := sqlrepo.OpenDB("sqlmock", dsn, "reader", tp)
+			require.NoError(t, err)
+			defer db.Close()
+
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+			tt.run(t, mock, ctx, db)
+			parent.End()
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 2)
+			span := spans[0]
+			assert.Equal(t, tt.expName, span.Name)
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+			assert.Equal(t, tt.expStatus, span.Status.Code)
+			assert.Contains(t, span.Attributes, attribute.String("db.statement", tt.expQuery))
+			assert.Contains(t, span.Attributes, attribute.String("db.handle", "reader"))
+		})
+	}
+}
@@ -4,33 +4,118 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	sqlrepo "movierama/internal/infra/repository/sql"
+	"movierama/internal/logging"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
 )
 
+// ErrDuplicateUsername is returned when the username already exists.
+var ErrDuplicateUsername = errors.New("duplicate username")
+
+// ErrDuplicateEmail is returned when the email belongs to another user.
+var ErrDuplicateEmail = errors.New("duplicate email")
+
+// ErrDuplicateIdentity is returned when the identity, or an identity of the same provider of the user, is
+// already linked.
+var ErrDuplicateIdentity = errors.New("duplicate identity")
+
 // Repository definition.
 type Repository struct {
-	read  *sql.DB
-	write *sql.DB
+	db  *sqlrepo.Router
+	log *zap.Logger
+	// driver adapts the queries to PostgreSQL, they are shared by MySQL and SQLite.
+	driver string
 }
 
-// NewRepository constructor.
-func NewRepository(reader *sql.DB, writer *sql.DB) (*Repository, error) {
+// NewRepository constructor of a repository of a MySQL or SQLite database.
+func NewRepository(reader *sql.DB, writer *sql.DB, logger *zap.Logger) (*Repository, error) {
 	if reader == nil {
 		return nil, errors.New("db reader is nil")
 	}
 	if writer == nil {
 		return nil, errors.New("db writer is nil")
 	}
-	return &Repository{read: reader, write: writer}, nil
+	if logger == nil {
+		return nil, errors.New("logger is nil")
+	}
+	return &Repository{db: sqlrepo.NewRouter(reader, writer, 0), log: logger, driver: sqlrepo.MySQL}, nil
+}
+
+// NewPostgresRepository constructor of a repository of a PostgreSQL database.
+func NewPostgresRepository(reader *sql.DB, writer *sql.DB, logger *zap.Logger) (*Repository, error) {
+	r, err := NewRepository(reader, writer, logger)
+	if err != nil {
+		return nil, err
+	}
+	r.driver = sqlrepo.Postgres
+
+	return r, nil
+}
+
+// WithRouter routes the queries with router, which is shared by the repositories of the same databases.
+func (sr *Repository) WithRouter(router *sqlrepo.Router) *Repository {
+	sr.db = router
+
+	return sr
+}
+
+// logErr logs the unexpected query errors with the request context and returns err.
+func (sr *Repository) logErr(ctx context.Context, query string, err error) error {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logging.For(ctx, sr.log).Error("sql query failed", zap.String("query", query), zap.Error(err))
+	}
+
+	return err
 }
 
 // GetUserAuthDetails returns a user.
 func (sr *Repository) GetUserAuthDetails(ctx context.Context, username string) (*AuthUserDetails, error) {
-	sqlQuery := `SELECT id, username, password FROM users WHERE username=?`
+	sqlQuery := `SELECT ` + authDetailsColumns + ` FROM users WHERE username=?`
 
-	row := sr.read.QueryRowContext(ctx, sqlQuery, username)
+	row := sr.db.Reader(ctx, sqlrepo.UsernameSession(username)).QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), username)
+	user, err := scanAuthDetails(row)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetUserAuthDetails", err)
+	}
+
+	return user, nil
+}
+
+// GetUserAuthDetailsByID returns a user.
+func (sr *Repository) GetUserAuthDetailsByID(ctx context.Context, userID int) (*AuthUserDetails, error) {
+	sqlQuery := `SELECT ` + authDetailsColumns + ` FROM users WHERE id=?`
 
+	row := sr.db.Reader(ctx, sqlrepo.UserSession(userID)).QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), userID)
+	user, err := scanAuthDetails(row)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetUserAuthDetailsByID", err)
+	}
+
+	return user, nil
+}
+
+// GetUserAuthDetailsByEmail returns the user of an email.
+func (sr *Repository) GetUserAuthDetailsByEmail(ctx context.Context, email string) (*AuthUserDetails, error) {
+	sqlQuery := `SELECT ` + authDetailsColumns + ` FROM users WHERE email=?`
+
+	row := sr.db.Reader(ctx, sqlrepo.EmailSession(email)).QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), email)
+	user, err := scanAuthDetails(row)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetUserAuthDetailsByEmail", err)
+	}
+
+	return user, nil
+}
+
+const authDetailsColumns = `id, username, password, role, status, suspended_until, email, email_verified_at, token_version`
+
+func scanAuthDetails(row *sql.Row) (*AuthUserDetails, error) {
 	var user AuthUserDetails
-	err := row.Scan(&user.ID, &user.Username, &user.Password)
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.Status, &user.SuspendedUntil,
+		&user.Email, &user.EmailVerifiedAt, &user.TokenVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -38,30 +123,421 @@ func (sr *Repository) GetUserAuthDetails(ctx context.Context, username string) (
 	return &user, nil
 }
 
-// CreateUser creates a new user.
+// UpdatePassword replaces the password hash of a user and bumps their token version.
+func (sr *Repository) UpdatePassword(ctx context.Context, userID int, password string) error {
+	sqlQuery := `UPDATE users SET password=?, token_version=token_version+1 WHERE id=?`
+
+	_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), password, userID)
+	if err != nil {
+		return sr.logErr(ctx, "UpdatePassword", err)
+	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
+
+	return nil
+}
+
+// UpdateEmail replaces the email of a user, which is no longer verified.
+func (sr *Repository) UpdateEmail(ctx context.Context, userID int, email string) error {
+	sqlQuery := `UPDATE users SET email=?, email_verified_at=NULL WHERE id=?`
+
+	_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), email, userID)
+	if sqlrepo.IsDuplicateKey(err, "users", "email") {
+		return ErrDuplicateEmail
+	}
+	if err != nil {
+		return sr.logErr(ctx, "UpdateEmail", err)
+	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID), sqlrepo.EmailSession(email))
+
+	return nil
+}
+
+// VerifyEmail marks the email of a user verified at the given time, unless the user has another email.
+func (sr *Repository) VerifyEmail(ctx context.Context, userID int, email string, at time.Time) error {
+	sqlQuery := `UPDATE users SET email_verified_at=? WHERE id=? AND email=?`
+
+	_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), at, userID, email)
+	if err != nil {
+		return sr.logErr(ctx, "VerifyEmail", err)
+	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID), sqlrepo.EmailSession(email))
+
+	return nil
+}
+
+// UpdateRole replaces the role of a user.
+func (sr *Repository) UpdateRole(ctx context.Context, userID int, role string) error {
+	sqlQuery := `UPDATE users SET role=?, role_changed_at=? WHERE id=?`
+
+	_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), role, time.Now().UTC(), userID)
+	if err != nil {
+		return sr.logErr(ctx, "UpdateRole", err)
+	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
+
+	return nil
+}
+
+// BootstrapRole sets the role of a user whose role was never changed from the default one, it reports
+// whether it did.
+func (sr *Repository) BootstrapRole(ctx context.Context, userID int, role string) (bool, error) {
+	sqlQuery := `UPDATE users SET role=?, role_changed_at=? WHERE id=? AND role=? AND role_changed_at IS NULL`
+
+	res, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), role, time.Now().UTC(), userID,
+		DefaultRole)
+	if err != nil {
+		return false, sr.logErr(ctx, "BootstrapRole", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, sr.logErr(ctx, "BootstrapRole", err)
+	}
+	if n > 0 {
+		sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
+	}
+
+	return n > 0, nil
+}
+
+// UpdateStatus replaces the status of a user, until is only set for a suspension.
+func (sr *Repository) UpdateStatus(ctx context.Context, userID int, status string, until *time.Time) error {
+	sqlQuery := `UPDATE users SET status=?, suspended_until=? WHERE id=?`
+
+	_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), status, until, userID)
+	if err != nil {
+		return sr.logErr(ctx, "UpdateStatus", err)
+	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
+
+	return nil
+}
+
+// CreateUser creates a new user and sets its id.
 func (sr *Repository) CreateUser(ctx context.Context, user *SQLUser) error {
 	sqlQuery := `INSERT INTO users (
 		username,
 		password,
 		first_name,
-		last_name
+		last_name,
+		email,
+		email_verified_at
 	) VALUES(
+		?,
+		?,
 		?,
 		?,
 		?,
 		?
 	);`
 
-	var err error
-	_, err = sr.write.ExecContext(ctx, sqlQuery,
+	id, err := sqlrepo.InsertID(ctx, sr.db.Writer(), sr.driver, sqlQuery,
 		&user.Username,
 		&user.Password,
 		&user.FirstName,
 		&user.LastName,
+		user.Email,
+		user.EmailVerifiedAt,
 	)
+	if sqlrepo.IsDuplicateKey(err, "users", "email") {
+		return ErrDuplicateEmail
+	}
+	if sqlrepo.IsDuplicate(err) {
+		return ErrDuplicateUsername
+	}
+	if err != nil {
+		return sr.logErr(ctx, "CreateUser", err)
+	}
+	user.ID = &id
+	sessions := []sqlrepo.Session{sqlrepo.UserSession(id), sqlrepo.UsernameSession(user.Username)}
+	if user.Email != nil {
+		sessions = append(sessions, sqlrepo.EmailSession(*user.Email))
+	}
+	sr.db.Wrote(ctx, sessions...)
+
+	return nil
+}
+
+// GetIdentityUserID returns the id of the user linked to the identity of the provider, sql.ErrNoRows when
+// there is none. It reads the writer, so that an identity linked a moment ago is not linked twice.
+func (sr *Repository) GetIdentityUserID(ctx context.Context, provider, subject string) (int, error) {
+	sqlQuery := `SELECT user_id FROM user_identities WHERE provider=? AND subject=?`
+
+	var userID int
+	err := sr.db.Writer().QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), provider, subject).Scan(&userID)
+	if err != nil {
+		return 0, sr.logErr(ctx, "GetIdentityUserID", err)
+	}
+
+	return userID, nil
+}
+
+// AddIdentity links an identity to its user and sets its id.
+func (sr *Repository) AddIdentity(ctx context.Context, identity *Identity) error {
+	sqlQuery := `INSERT INTO user_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?)`
+
+	id, err := sqlrepo.InsertID(ctx, sr.db.Writer(), sr.driver, sqlQuery,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if sqlrepo.IsDuplicate(err) {
+		return ErrDuplicateIdentity
+	}
+	if err != nil {
+		return sr.logErr(ctx, "AddIdentity", err)
+	}
+	identity.ID = id
+	sr.db.Wrote(ctx, sqlrepo.UserSession(identity.UserID))
+
+	return nil
+}
+
+// accessTokenColumns are the columns of the personal access tokens, in the order of scanAccessToken.
+const accessTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at`
+
+// scanAccessToken scans the accessTokenColumns of a row.
+func scanAccessToken(scan func(dest ...interface{}) error) (*AccessToken, error) {
+	var t AccessToken
+	if err := scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// AddAccessToken stores a personal access token and sets its id.
+func (sr *Repository) AddAccessToken(ctx context.Context, token *AccessToken) error {
+	sqlQuery := `INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	id, err := sqlrepo.InsertID(ctx, sr.db.Writer(), sr.driver, sqlQuery,
+		token.UserID, token.Name, token.TokenHash, token.Scopes, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return sr.logErr(ctx, "AddAccessToken", err)
+	}
+	token.ID = id
+	sr.db.Wrote(ctx, sqlrepo.UserSession(token.UserID))
+
+	return nil
+}
+
+// GetAccessTokenByHash returns the personal access token of the hash, sql.ErrNoRows when there is none. It
+// reads the writer, so that a revoked token stops working at once.
+func (sr *Repository) GetAccessTokenByHash(ctx context.Context, hash string) (*AccessToken, error) {
+	sqlQuery := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens WHERE token_hash=?`
+
+	token, err := scanAccessToken(sr.db.Writer().QueryRowContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), hash).Scan)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetAccessTokenByHash", err)
+	}
+
+	return token, nil
+}
+
+// GetAccessTokens returns the personal access tokens of a user, oldest first.
+func (sr *Repository) GetAccessTokens(ctx context.Context, userID int) ([]AccessToken, error) {
+	sqlQuery := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens WHERE user_id=? ORDER BY id`
+
+	rows, err := sr.db.Reader(ctx, sqlrepo.UserSession(userID)).QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), userID)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetAccessTokens", err)
+	}
+	defer rows.Close()
+
+	var tokens []AccessToken
+	for rows.Next() {
+		token, err := scanAccessToken(rows.Scan)
+		if err != nil {
+			return tokens, sr.logErr(ctx, "GetAccessTokens", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	if err = rows.Err(); err != nil {
+		return tokens, sr.logErr(ctx, "GetAccessTokens", err)
+	}
+
+	return tokens, nil
+}
+
+// DeleteAccessToken deletes a personal access token of a user, sql.ErrNoRows when the user has no token of the id.
+func (sr *Repository) DeleteAccessToken(ctx context.Context, userID, tokenID int) error {
+	sqlQuery := `DELETE FROM personal_access_tokens WHERE id=? AND user_id=?`
+
+	res, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), tokenID, userID)
+	if err != nil {
+		return sr.logErr(ctx, "DeleteAccessToken", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return sr.logErr(ctx, "DeleteAccessToken", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	sr.db.Wrote(ctx, sqlrepo.UserSession(userID))
+
+	return nil
+}
+
+// TouchAccessToken records the last use of a personal access token.
+func (sr *Repository) TouchAccessToken(ctx context.Context, tokenID int, at time.Time) error {
+	sqlQuery := `UPDATE personal_access_tokens SET last_used_at=? WHERE id=?`
+
+	_, err := sr.db.Writer().ExecContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), at, tokenID)
 	if err != nil {
-		return err
+		return sr.logErr(ctx, "TouchAccessToken", err)
 	}
 
 	return nil
 }
+
+// GetUsers returns the users of the given ids.
+func (sr *Repository) GetUsers(ctx context.Context, userIDs []int) ([]User, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		args[i] = id
+	}
+	sqlQuery := `SELECT id, username, first_name, last_name FROM users WHERE id IN (?` +
+		strings.Repeat(",?", len(userIDs)-1) + `)`
+
+	rows, err := sr.db.Reader(ctx, "").QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), args...)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetUsers", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName); err != nil {
+			return users, sr.logErr(ctx, "GetUsers", err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return users, sr.logErr(ctx, "GetUsers", err)
+	}
+
+	return users, nil
+}
+
+// SearchAccounts returns the accounts selected by filter, ordered by id.
+func (sr *Repository) SearchAccounts(ctx context.Context, filter AccountFilter) ([]Account, error) {
+	var where []string
+	var args []interface{}
+	if filter.Query != "" {
+		// The wildcards of the query are escaped with !, the only escape character shared by the dialects.
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		where = append(where, `(LOWER(username) LIKE ? ESCAPE '!' OR LOWER(first_name) LIKE ? ESCAPE '!' OR LOWER(last_name) LIKE ? ESCAPE '!')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	for _, cond := range []struct {
+		column string
+		value  interface{}
+		set    bool
+	}{
+		{"role = ?", filter.Role, filter.Role != ""},
+		{"status = ?", filter.Status, filter.Status != ""},
+		{"id > ?", filter.AfterID, filter.AfterID != 0},
+	} {
+		if cond.set {
+			where = append(where, cond.column)
+			args = append(args, cond.value)
+		}
+	}
+	sqlQuery := `SELECT id, username, first_name, last_name, role, status, suspended_until FROM users`
+	if len(where) > 0 {
+		sqlQuery += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	sqlQuery += ` ORDER BY id LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := sr.db.Reader(ctx, "").QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), args...)
+	if err != nil {
+		return nil, sr.logErr(ctx, "SearchAccounts", err)
+	}
+	defer rows.Close()
+
+	var accounts []Account
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.ID, &a.Username, &a.FirstName, &a.LastName, &a.Role, &a.Status, &a.SuspendedUntil); err != nil {
+			return accounts, sr.logErr(ctx, "SearchAccounts", err)
+		}
+		accounts = append(accounts, a)
+	}
+	if err = rows.Err(); err != nil {
+		return accounts, sr.logErr(ctx, "SearchAccounts", err)
+	}
+
+	return accounts, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern with !.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// AddAuthEvent records an auth event and sets its id.
+func (sr *Repository) AddAuthEvent(ctx context.Context, event *AuthEvent) error {
+	sqlQuery := `INSERT INTO auth_events (user_id, username, event, ip, user_agent) VALUES (?, ?, ?, ?, ?)`
+
+	id, err := sqlrepo.InsertID(ctx, sr.db.Writer(), sr.driver, sqlQuery,
+		event.UserID, event.Username, event.Event, event.IP, event.UserAgent)
+	if err != nil {
+		return sr.logErr(ctx, "AddAuthEvent", err)
+	}
+	event.ID = id
+	if event.UserID != nil {
+		sr.db.Wrote(ctx, sqlrepo.UserSession(*event.UserID))
+	}
+
+	return nil
+}
+
+// GetAuthEvents returns the auth events selected by filter, newest first.
+func (sr *Repository) GetAuthEvents(ctx context.Context, filter AuthEventFilter) ([]AuthEvent, error) {
+	var where []string
+	var args []interface{}
+	for _, cond := range []struct {
+		column string
+		value  interface{}
+		set    bool
+	}{
+		{"user_id = ?", filter.UserID, filter.UserID != 0},
+		{"username = ?", filter.Username, filter.Username != ""},
+		{"ip = ?", filter.IP, filter.IP != ""},
+		{"event = ?", filter.Event, filter.Event != ""},
+		{"id < ?", filter.BeforeID, filter.BeforeID != 0},
+	} {
+		if cond.set {
+			where = append(where, cond.column)
+			args = append(args, cond.value)
+		}
+	}
+	sqlQuery := `SELECT id, user_id, username, event, ip, user_agent, created_at FROM auth_events`
+	if len(where) > 0 {
+		sqlQuery += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	sqlQuery += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := sr.db.Reader(ctx, sqlrepo.UserSession(filter.UserID)).QueryContext(ctx, sqlrepo.Rebind(sr.driver, sqlQuery), args...)
+	if err != nil {
+		return nil, sr.logErr(ctx, "GetAuthEvents", err)
+	}
+	defer rows.Close()
+
+	var events []AuthEvent
+	for rows.Next() {
+		var e AuthEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Username, &e.Event, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return events, sr.logErr(ctx, "GetAuthEvents", err)
+		}
+		events = append(events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return events, sr.logErr(ctx, "GetAuthEvents", err)
+	}
+
+	return events, nil
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 // Mock describes a mock struct.
@@ -23,3 +24,136 @@ func (m *Mock) CreateUser(ctx context.Context, user *SQLUser) error {
 
 	return args.Error(0)
 }
+
+// GetUsers mock.
+func (m *Mock) GetUsers(ctx context.Context, userIDs []int) ([]User, error) {
+	args := m.MethodCalled("GetUsers", ctx, userIDs)
+
+	return args.Get(0).([]User), args.Error(1)
+}
+
+// GetUserAuthDetailsByID mock.
+func (m *Mock) GetUserAuthDetailsByID(ctx context.Context, userID int) (*AuthUserDetails, error) {
+	args := m.MethodCalled("GetUserAuthDetailsByID", ctx, userID)
+
+	return args.Get(0).(*AuthUserDetails), args.Error(1)
+}
+
+// GetUserAuthDetailsByEmail mock.
+func (m *Mock) GetUserAuthDetailsByEmail(ctx context.Context, email string) (*AuthUserDetails, error) {
+	args := m.MethodCalled("GetUserAuthDetailsByEmail", ctx, email)
+
+	return args.Get(0).(*AuthUserDetails), args.Error(1)
+}
+
+// UpdateEmail mock.
+func (m *Mock) UpdateEmail(ctx context.Context, userID int, email string) error {
+	args := m.MethodCalled("UpdateEmail", ctx, userID, email)
+
+	return args.Error(0)
+}
+
+// VerifyEmail mock.
+func (m *Mock) VerifyEmail(ctx context.Context, userID int, email string, at time.Time) error {
+	args := m.MethodCalled("VerifyEmail", ctx, userID, email, at)
+
+	return args.Error(0)
+}
+
+// UpdatePassword mock.
+func (m *Mock) UpdatePassword(ctx context.Context, userID int, password string) error {
+	args := m.MethodCalled("UpdatePassword", ctx, userID, password)
+
+	return args.Error(0)
+}
+
+// AddAuthEvent mock.
+func (m *Mock) AddAuthEvent(ctx context.Context, event *AuthEvent) error {
+	args := m.MethodCalled("AddAuthEvent", ctx, event)
+
+	return args.Error(0)
+}
+
+// GetAuthEvents mock.
+func (m *Mock) GetAuthEvents(ctx context.Context, filter AuthEventFilter) ([]AuthEvent, error) {
+	args := m.MethodCalled("GetAuthEvents", ctx, filter)
+
+	return args.Get(0).([]AuthEvent), args.Error(1)
+}
+
+// UpdateRole mock.
+func (m *Mock) UpdateRole(ctx context.Context, userID int, role string) error {
+	args := m.MethodCalled("UpdateRole", ctx, userID, role)
+
+	return args.Error(0)
+}
+
+// BootstrapRole mock.
+func (m *Mock) BootstrapRole(ctx context.Context, userID int, role string) (bool, error) {
+	args := m.MethodCalled("BootstrapRole", ctx, userID, role)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// UpdateStatus mock.
+func (m *Mock) UpdateStatus(ctx context.Context, userID int, status string, until *time.Time) error {
+	args := m.MethodCalled("UpdateStatus", ctx, userID, status, until)
+
+	return args.Error(0)
+}
+
+// SearchAccounts mock.
+func (m *Mock) SearchAccounts(ctx context.Context, filter AccountFilter) ([]Account, error) {
+	args := m.MethodCalled("SearchAccounts", ctx, filter)
+
+	return args.Get(0).([]Account), args.Error(1)
+}
+
+// GetIdentityUserID mock.
+func (m *Mock) GetIdentityUserID(ctx context.Context, provider, subject string) (int, error) {
+	args := m.MethodCalled("GetIdentityUserID", ctx, provider, subject)
+
+	return args.Int(0), args.Error(1)
+}
+
+// AddIdentity mock.
+func (m *Mock) AddIdentity(ctx context.Context, identity *Identity) error {
+	args := m.MethodCalled("AddIdentity", ctx, identity)
+
+	return args.Error(0)
+}
+
+// AddAccessToken mock.
+func (m *Mock) AddAccessToken(ctx context.Context, token *AccessToken) error {
+	args := m.MethodCalled("AddAccessToken", ctx, token)
+
+	return args.Error(0)
+}
+
+// GetAccessTokenByHash mock.
+func (m *Mock) GetAccessTokenByHash(ctx context.Context, hash string) (*AccessToken, error) {
+	args := m.MethodCalled("GetAccessTokenByHash", ctx, hash)
+
+	return args.Get(0).(*AccessToken), args.Error(1)
+}
+
+// GetAccessTokens mock.
+func (m *Mock) GetAccessTokens(ctx context.Context, userID int) ([]AccessToken, error) {
+	args := m.MethodCalled("GetAccessTokens", ctx, userID)
+
+	return args.Get(0).([]AccessToken), args.Error(1)
+}
+
+// DeleteAccessToken mock.
+func (m *Mock) DeleteAccessToken(ctx context.Context, userID, tokenID int) error {
+	args := m.MethodCalled("DeleteAccessToken", ctx, userID, tokenID)
+
+	return args.Error(0)
+}
+
+// TouchAccessToken mock.
+func (m *Mock) TouchAccessToken(ctx context.Context, tokenID int, at time.Time) error {
+	args := m.MethodCalled("TouchAccessToken", ctx, tokenID, at)
+
+	return args.Error(0)
+}
@@ -6,9 +6,14 @@ import (
 	"database/sql/driver"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"movierama/internal/infra/repository/sql/user"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type dbMock struct {
@@ -20,24 +25,33 @@ func Test_NewRepository(t *testing.T) {
 	tests := map[string]struct {
 		reader *sql.DB
 		writer *sql.DB
+		logger *zap.Logger
 		expErr error
 	}{
 		"success": {
 			reader: &sql.DB{},
 			writer: &sql.DB{},
+			logger: zap.NewNop(),
 		},
 		"missing reader": {
 			writer: &sql.DB{},
+			logger: zap.NewNop(),
 			expErr: errors.New("db reader is nil"),
 		},
 		"missing writer": {
 			reader: &sql.DB{},
+			logger: zap.NewNop(),
 			expErr: errors.New("db writer is nil"),
 		},
+		"missing logger": {
+			reader: &sql.DB{},
+			writer: &sql.DB{},
+			expErr: errors.New("logger is nil"),
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := user.NewRepository(tt.reader, tt.writer)
+			got, err := user.NewRepository(tt.reader, tt.writer, tt.logger)
 			if tt.expErr != nil {
 				assert.Equal(t, tt.expErr, err)
 			} else {
@@ -49,6 +63,7 @@ func Test_NewRepository(t *testing.T) {
 }
 
 func Test_GetUserAuthDetails(t *testing.T) {
+	email := "test@example.com"
 	cases := map[string]struct {
 		dbMock   dbMock
 		username string
@@ -60,10 +75,10 @@ func Test_GetUserAuthDetails(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
-				rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-					AddRow(1, "test_username", "pass")
+				rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "status", "suspended_until", "email", "email_verified_at", "token_version"}).
+					AddRow(1, "test_username", "pass", "user", "active", nil, "test@example.com", nil, 3)
 
-				mock.ExpectQuery(`SELECT id, username, password FROM users WHERE username=?`).
+				mock.ExpectQuery(`SELECT id, username, password, role, status, suspended_until, email, email_verified_at, token_version FROM users WHERE username=?`).
 					WithArgs("test_username").
 					WillReturnRows(rows)
 
@@ -73,9 +88,13 @@ func Test_GetUserAuthDetails(t *testing.T) {
 				}
 			}(),
 			expRes: &user.AuthUserDetails{
-				ID:       1,
-				Username: "test_username",
-				Password: "pass",
+				ID:           1,
+				Username:     "test_username",
+				Password:     "pass",
+				Role:         "user",
+				Status:       "active",
+				Email:        &email,
+				TokenVersion: 3,
 			},
 		},
 		"should return error on sql error": {
@@ -83,7 +102,7 @@ func Test_GetUserAuthDetails(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
-				mock.ExpectQuery(`SELECT id, username, password FROM users WHERE username=?`).
+				mock.ExpectQuery(`SELECT id, username, password, role, status, suspended_until, email, email_verified_at, token_version FROM users WHERE username=?`).
 					WithArgs("test_username").
 					WillReturnError(errors.New("sql error"))
 
@@ -99,10 +118,10 @@ func Test_GetUserAuthDetails(t *testing.T) {
 			dbMock: func() dbMock {
 				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 
-				rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-					AddRow(nil, "test_username", "pass")
+				rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "status", "suspended_until", "email", "email_verified_at", "token_version"}).
+					AddRow(nil, "test_username", "pass", "user", "active", nil, nil, nil, 0)
 
-				mock.ExpectQuery(`SELECT id, username, password FROM users WHERE username=?`).
+				mock.ExpectQuery(`SELECT id, username, password, role, status, suspended_until, email, email_verified_at, token_version FROM users WHERE username=?`).
 					WithArgs("test_username").
 					WillReturnRows(rows)
 
@@ -117,7 +136,7 @@ func Test_GetUserAuthDetails(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := user.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := user.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			resp, err := repo.GetUserAuthDetails(context.TODO(), tt.username)
 			assert.Equal(t, tt.expRes, resp)
 			if tt.expErr != nil {
@@ -146,14 +165,18 @@ func Test_CreateUser(t *testing.T) {
 		username,
 		password,
 		first_name,
-		last_name
+		last_name,
+		email,
+		email_verified_at
 	) VALUES(
+		?,
+		?,
 		?,
 		?,
 		?,
 		?
 	);`).
-					WithArgs([]driver.Value{"username", "pass", "first name", "last name"}...).
+					WithArgs([]driver.Value{"username", "pass", "first name", "last name", nil, nil}...).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 				return dbMock{
 					db:   db,
@@ -161,6 +184,70 @@ func Test_CreateUser(t *testing.T) {
 				}
 			}(),
 		},
+		"should return duplicate username error": {
+			user: &user.SQLUser{
+				Username:  "username",
+				Password:  "pass",
+				FirstName: "first name",
+				LastName:  "last name",
+			}, dbMock: func() dbMock {
+				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				mock.ExpectExec(`INSERT INTO users (
+		username,
+		password,
+		first_name,
+		last_name,
+		email,
+		email_verified_at
+	) VALUES(
+		?,
+		?,
+		?,
+		?,
+		?,
+		?
+	);`).
+					WithArgs([]driver.Value{"username", "pass", "first name", "last name", nil, nil}...).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+				return dbMock{
+					db:   db,
+					mock: mock,
+				}
+			}(),
+			expErr: user.ErrDuplicateUsername,
+		},
+		"should return duplicate email error": {
+			user: &user.SQLUser{
+				Username:  "username",
+				Password:  "pass",
+				FirstName: "first name",
+				LastName:  "last name",
+			}, dbMock: func() dbMock {
+				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				mock.ExpectExec(`INSERT INTO users (
+		username,
+		password,
+		first_name,
+		last_name,
+		email,
+		email_verified_at
+	) VALUES(
+		?,
+		?,
+		?,
+		?,
+		?,
+		?
+	);`).
+					WithArgs([]driver.Value{"username", "pass", "first name", "last name", nil, nil}...).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'users.email'"})
+				return dbMock{
+					db:   db,
+					mock: mock,
+				}
+			}(),
+			expErr: user.ErrDuplicateEmail,
+		},
 		"should return error on exec error": {
 			user: &user.SQLUser{
 				Username:  "username",
@@ -173,14 +260,18 @@ func Test_CreateUser(t *testing.T) {
 		username,
 		password,
 		first_name,
-		last_name
+		last_name,
+		email,
+		email_verified_at
 	) VALUES(
+		?,
+		?,
 		?,
 		?,
 		?,
 		?
 	);`).
-					WithArgs([]driver.Value{"username", "pass", "first name", "last name"}...).
+					WithArgs([]driver.Value{"username", "pass", "first name", "last name", nil, nil}...).
 					WillReturnError(errors.New("exec error"))
 				return dbMock{
 					db:   db,
@@ -193,9 +284,373 @@ func Test_CreateUser(t *testing.T) {
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			repo, _ := user.NewRepository(tt.dbMock.db, tt.dbMock.db)
+			repo, _ := user.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
 			err := repo.CreateUser(context.TODO(), tt.user)
 			assert.Equal(t, tt.expErr, err)
 		})
 	}
 }
+
+func Test_GetUsers(t *testing.T) {
+	cases := map[string]struct {
+		dbMock  dbMock
+		userIDs []int
+		expRes  []user.User
+		expErr  error
+	}{
+		"should return users": {
+			userIDs: []int{1, 2},
+			dbMock: func() dbMock {
+				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				rows := sqlmock.NewRows([]string{"id", "username", "first_name", "last_name"}).
+					AddRow(1, "username1", "first 1", "last 1").
+					AddRow(2, "username2", "first 2", "last 2")
+				mock.ExpectQuery(`SELECT id, username, first_name, last_name FROM users WHERE id IN (?,?)`).
+					WithArgs(1, 2).
+					WillReturnRows(rows)
+
+				return dbMock{
+					db:   db,
+					mock: mock,
+				}
+			}(),
+			expRes: []user.User{
+				{ID: 1, Username: "username1", FirstName: "first 1", LastName: "last 1"},
+				{ID: 2, Username: "username2", FirstName: "first 2", LastName: "last 2"},
+			},
+		},
+		"should not query without ids": {
+			dbMock: func() dbMock {
+				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+
+				return dbMock{
+					db:   db,
+					mock: mock,
+				}
+			}(),
+		},
+		"should return error on sql error": {
+			userIDs: []int{1},
+			dbMock: func() dbMock {
+				db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				mock.ExpectQuery(`SELECT id, username, first_name, last_name FROM users WHERE id IN (?)`).
+					WithArgs(1).
+					WillReturnError(errors.New("sql error"))
+
+				return dbMock{
+					db:   db,
+					mock: mock,
+				}
+			}(),
+			expErr: errors.New("sql error"),
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			repo, _ := user.NewRepository(tt.dbMock.db, tt.dbMock.db, zap.NewNop())
+			resp, err := repo.GetUsers(context.TODO(), tt.userIDs)
+			assert.Equal(t, tt.expRes, resp)
+			assert.Equal(t, tt.expErr, err)
+			assert.NoError(t, tt.dbMock.mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_GetAuthEvents(t *testing.T) {
+	columns := []string{"id", "user_id", "username", "event", "ip", "user_agent", "created_at"}
+	createdAt := time.Date(2022, 12, 2, 13, 58, 0, 0, time.UTC)
+	userID := 3
+
+	cases := map[string]struct {
+		filter   user.AuthEventFilter
+		expQuery string
+		expArgs  []driver.Value
+		rows     *sqlmock.Rows
+		expRes   []user.AuthEvent
+	}{
+		"should return every event without filter": {
+			filter:   user.AuthEventFilter{Limit: 50},
+			expQuery: `SELECT id, user_id, username, event, ip, user_agent, created_at FROM auth_events ORDER BY id DESC LIMIT ?`,
+			expArgs:  []driver.Value{50},
+			rows: sqlmock.NewRows(columns).
+				AddRow(2, nil, "unknown", "login_failed", "10.1.0.1", "curl", createdAt).
+				AddRow(1, 3, "username", "registered", "10.1.0.2", "firefox", createdAt),
+			expRes: []user.AuthEvent{
+				{ID: 2, Username: "unknown", Event: "login_failed", IP: "10.1.0.1", UserAgent: "curl", CreatedAt: createdAt},
+				{ID: 1, UserID: &userID, Username: "username", Event: "registered", IP: "10.1.0.2", UserAgent: "firefox", CreatedAt: createdAt},
+			},
+		},
+		"should filter the events": {
+			filter: user.AuthEventFilter{UserID: 3, Username: "username", IP: "10.1.0.2", Event: "registered", BeforeID: 10, Limit: 5},
+			expQuery: `SELECT id, user_id, username, event, ip, user_agent, created_at FROM auth_events ` +
+				`WHERE user_id = ? AND username = ? AND ip = ? AND event = ? AND id < ? ORDER BY id DESC LIMIT ?`,
+			expArgs: []driver.Value{3, "username", "10.1.0.2", "registered", 10, 5},
+			rows:    sqlmock.NewRows(columns),
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			assert.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(tt.expQuery).WithArgs(tt.expArgs...).WillReturnRows(tt.rows)
+
+			repo, _ := user.NewRepository(db, db, zap.NewNop())
+			events, err := repo.GetAuthEvents(context.TODO(), tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expRes, events)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_AddAuthEvent(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, _ := user.NewRepository(db, db, zap.NewNop())
+
+	insert := `INSERT INTO auth_events (user_id, username, event, ip, user_agent) VALUES (?, ?, ?, ?, ?)`
+	mock.ExpectExec(insert).WithArgs(nil, "unknown", "login_failed", "10.1.0.1", "curl").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	e := &user.AuthEvent{Username: "unknown", Event: "login_failed", IP: "10.1.0.1", UserAgent: "curl"}
+	assert.NoError(t, repo.AddAuthEvent(context.TODO(), e))
+	assert.Equal(t, 7, e.ID)
+
+	mock.ExpectExec(insert).WithArgs(nil, "unknown", "login_failed", "10.1.0.1", "curl").
+		WillReturnError(errors.New("sql error"))
+	assert.EqualError(t, repo.AddAuthEvent(context.TODO(), e), "sql error")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_GetIdentityUserID(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, _ := user.NewRepository(db, db, zap.NewNop())
+
+	query := `SELECT user_id FROM user_identities WHERE provider=? AND subject=?`
+	mock.ExpectQuery(query).WithArgs("github", "1001").WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+	userID, err := repo.GetIdentityUserID(context.TODO(), "github", "1001")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, userID)
+
+	mock.ExpectQuery(query).WithArgs("github", "1002").WillReturnError(sql.ErrNoRows)
+	_, err = repo.GetIdentityUserID(context.TODO(), "github", "1002")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_AddIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, _ := user.NewRepository(db, db, zap.NewNop())
+
+	insert := `INSERT INTO user_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?)`
+	mock.ExpectExec(insert).WithArgs(2, "github", "1001", "alice@example.com").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	id := &user.Identity{UserID: 2, Provider: "github", Subject: "1001", Email: "alice@example.com"}
+	assert.NoError(t, repo.AddIdentity(context.TODO(), id))
+	assert.Equal(t, 3, id.ID)
+
+	mock.ExpectExec(insert).WithArgs(2, "github", "1001", "alice@example.com").
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	assert.ErrorIs(t, repo.AddIdentity(context.TODO(), id), user.ErrDuplicateIdentity)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_AccessTokens(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, _ := user.NewRepository(db, db, zap.NewNop())
+	createdAt := time.Date(2026, 10, 24, 9, 0, 0, 0, time.UTC)
+	hash := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+	mock.ExpectExec(`INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`).WithArgs(2, "bot", hash, "movies:write", nil, createdAt).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	token := &user.AccessToken{UserID: 2, Name: "bot", TokenHash: hash, Scopes: "movies:write", CreatedAt: createdAt}
+	assert.NoError(t, repo.AddAccessToken(context.TODO(), token))
+	assert.Equal(t, 5, token.ID)
+
+	columns := []string{"id", "user_id", "name", "token_hash", "scopes", "expires_at", "last_used_at", "created_at"}
+	mock.ExpectQuery(`SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at FROM personal_access_tokens WHERE token_hash=?`).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, 2, "bot", hash, "movies:write", nil, nil, createdAt))
+	got, err := repo.GetAccessTokenByHash(context.TODO(), hash)
+	assert.NoError(t, err)
+	assert.Equal(t, token, got)
+
+	mock.ExpectExec(`UPDATE personal_access_tokens SET last_used_at=? WHERE id=?`).WithArgs(createdAt, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.TouchAccessToken(context.TODO(), 5, createdAt))
+
+	deleteQuery := `DELETE FROM personal_access_tokens WHERE id=? AND user_id=?`
+	mock.ExpectExec(deleteQuery).WithArgs(5, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.DeleteAccessToken(context.TODO(), 3, 5), sql.ErrNoRows)
+	mock.ExpectExec(deleteQuery).WithArgs(5, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.DeleteAccessToken(context.TODO(), 2, 5))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_SearchAccounts(t *testing.T) {
+	columns := []string{"id", "username", "first_name", "last_name", "role", "status", "suspended_until"}
+	until := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		filter   user.AccountFilter
+		expQuery string
+		expArgs  []driver.Value
+		rows     *sqlmock.Rows
+		expRes   []user.Account
+	}{
+		"should return every account without filter": {
+			filter:   user.AccountFilter{Limit: 50},
+			expQuery: `SELECT id, username, first_name, last_name, role, status, suspended_until FROM users ORDER BY id LIMIT ?`,
+			expArgs:  []driver.Value{50},
+			rows: sqlmock.NewRows(columns).
+				AddRow(1, "admin", "Ada", "Admin", "admin", "active", nil).
+				AddRow(2, "troll", "Tom", "Troll", "user", "suspended", until),
+			expRes: []user.Account{
+				{ID: 1, Username: "admin", FirstName: "Ada", LastName: "Admin", Role: "admin", Status: "active"},
+				{ID: 2, Username: "troll", FirstName: "Tom", LastName: "Troll", Role: "user", Status: "suspended", SuspendedUntil: &until},
+			},
+		},
+		"should search the accounts with escaped wildcards": {
+			filter: user.AccountFilter{Query: "T_m%", Role: "user", Status: "suspended", AfterID: 1, Limit: 5},
+			expQuery: `SELECT id, username, first_name, last_name, role, status, suspended_until FROM users ` +
+				`WHERE (LOWER(username) LIKE ? ESCAPE '!' OR LOWER(first_name) LIKE ? ESCAPE '!' OR LOWER(last_name) LIKE ? ESCAPE '!') ` +
+				`AND role = ? AND status = ? AND id > ? ORDER BY id LIMIT ?`,
+			expArgs: []driver.Value{"%t!_m!%%", "%t!_m!%%", "%t!_m!%%", "user", "suspended", 1, 5},
+			rows:    sqlmock.NewRows(columns),
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			assert.NoError(t, err)
+			defer db.Close()
+			mock.ExpectQuery(tt.expQuery).WithArgs(tt.expArgs...).WillReturnRows(tt.rows)
+
+			repo, _ := user.NewRepository(db, db, zap.NewNop())
+			accounts, err := repo.SearchAccounts(context.TODO(), tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expRes, accounts)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_UpdateStatus(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, _ := user.NewRepository(db, db, zap.NewNop())
+
+	until := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	update := `UPDATE users SET status=?, suspended_until=? WHERE id=?`
+	mock.ExpectExec(update).WithArgs("suspended", until, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.UpdateStatus(context.TODO(), 2, "suspended", &until))
+
+	mock.ExpectExec(update).WithArgs("banned", nil, 2).WillReturnError(errors.New("sql error"))
+	assert.EqualError(t, repo.UpdateStatus(context.TODO(), 2, "banned", nil), "sql error")
+
+	mock.ExpectExec(`UPDATE users SET role=?, role_changed_at=? WHERE id=?`).WithArgs("moderator", sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.UpdateRole(context.TODO(), 2, "moderator"))
+
+	bootstrap := `UPDATE users SET role=?, role_changed_at=? WHERE id=? AND role=? AND role_changed_at IS NULL`
+	mock.ExpectExec(bootstrap).WithArgs("admin", sqlmock.AnyArg(), 2, "user").WillReturnResult(sqlmock.NewResult(0, 1))
+	promoted, err := repo.BootstrapRole(context.TODO(), 2, "admin")
+	assert.NoError(t, err)
+	assert.True(t, promoted)
+
+	mock.ExpectExec(bootstrap).WithArgs("admin", sqlmock.AnyArg(), 2, "user").WillReturnResult(sqlmock.NewResult(0, 0))
+	promoted, err = repo.BootstrapRole(context.TODO(), 2, "admin")
+	assert.NoError(t, err)
+	assert.False(t, promoted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_Email(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, _ := user.NewRepository(db, db, zap.NewNop())
+
+	verifiedAt := time.Date(2026, 10, 23, 9, 0, 0, 0, time.UTC)
+	email := "alice@example.com"
+	mock.ExpectQuery(`SELECT id, username, password, role, status, suspended_until, email, email_verified_at, token_version FROM users WHERE email=?`).
+		WithArgs(email).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "role", "status", "suspended_until", "email", "email_verified_at", "token_version"}).
+			AddRow(2, "alice", "pass", "user", "active", nil, email, verifiedAt, 0))
+	details, err := repo.GetUserAuthDetailsByEmail(context.TODO(), email)
+	assert.NoError(t, err)
+	assert.Equal(t, &user.AuthUserDetails{ID: 2, Username: "alice", Password: "pass", Role: "user", Status: "active",
+		Email: &email, EmailVerifiedAt: &verifiedAt}, details)
+
+	update := `UPDATE users SET email=?, email_verified_at=NULL WHERE id=?`
+	mock.ExpectExec(update).WithArgs(email, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.UpdateEmail(context.TODO(), 2, email))
+
+	mock.ExpectExec(update).WithArgs(email, 3).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice@example.com' for key 'users.email'"})
+	assert.Equal(t, user.ErrDuplicateEmail, repo.UpdateEmail(context.TODO(), 3, email))
+
+	mock.ExpectExec(`UPDATE users SET email_verified_at=? WHERE id=? AND email=?`).WithArgs(verifiedAt, 2, email).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.VerifyEmail(context.TODO(), 2, email, verifiedAt))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_Postgres(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, err := user.NewPostgresRepository(db, db, zap.NewNop())
+	assert.NoError(t, err)
+
+	insert := `INSERT INTO users (
+		username,
+		password,
+		first_name,
+		last_name,
+		email,
+		email_verified_at
+	) VALUES(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6
+	) RETURNING id;`
+	args := []driver.Value{"username", "pass", "first name", "last name", nil, nil}
+	mock.ExpectQuery(insert).WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	u := &user.SQLUser{Username: "username", Password: "pass", FirstName: "first name", LastName: "last name"}
+	assert.NoError(t, repo.CreateUser(context.TODO(), u))
+	if assert.NotNil(t, u.ID) {
+		assert.Equal(t, 4, *u.ID)
+	}
+
+	mock.ExpectQuery(insert).WithArgs(args...).WillReturnError(&pq.Error{Code: "23505"})
+	assert.Equal(t, user.ErrDuplicateUsername, repo.CreateUser(context.TODO(), u))
+
+	mock.ExpectQuery(`SELECT id, username, first_name, last_name FROM users WHERE id IN ($1,$2)`).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name"}).
+			AddRow(1, "username", "first name", "last name"))
+	users, err := repo.GetUsers(context.TODO(), []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []user.User{{ID: 1, Username: "username", FirstName: "first name", LastName: "last name"}}, users)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
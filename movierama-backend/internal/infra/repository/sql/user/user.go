@@ -1,17 +1,107 @@
 package user
 
+import "time"
+
+// DefaultRole is the role of the new users.
+const DefaultRole = "user"
+
 // AuthUserDetails struct.
 type AuthUserDetails struct {
-	ID       int    `db:"id"`
-	Username string `db:"username"`
-	Password string `db:"password"`
+	ID             int        `db:"id"`
+	Username       string     `db:"username"`
+	Password       string     `db:"password"`
+	Role           string     `db:"role"`
+	Status         string     `db:"status"`
+	SuspendedUntil *time.Time `db:"suspended_until"`
+	// Email is nil for the accounts without email.
+	Email           *string    `db:"email"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
+	// TokenVersion is bumped on each password change, so that the tokens issued before stop working.
+	TokenVersion int `db:"token_version"`
 }
 
 // SQLUser struct.
 type SQLUser struct {
-	ID        *int   `db:"id"`
+	ID              *int       `db:"id"`
+	Username        string     `db:"username"`
+	Password        string     `db:"password"`
+	FirstName       string     `db:"first_name"`
+	LastName        string     `db:"last_name"`
+	Email           *string    `db:"email"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
+}
+
+// User struct.
+type User struct {
+	ID        int    `db:"id"`
 	Username  string `db:"username"`
-	Password  string `db:"password"`
 	FirstName string `db:"first_name"`
 	LastName  string `db:"last_name"`
 }
+
+// Account struct.
+type Account struct {
+	ID             int        `db:"id"`
+	Username       string     `db:"username"`
+	FirstName      string     `db:"first_name"`
+	LastName       string     `db:"last_name"`
+	Role           string     `db:"role"`
+	Status         string     `db:"status"`
+	SuspendedUntil *time.Time `db:"suspended_until"`
+}
+
+// AccountFilter selects accounts, the zero fields match every account.
+type AccountFilter struct {
+	// Query matches the accounts whose username, first or last name contains it, ignoring case.
+	Query  string
+	Role   string
+	Status string
+	// AfterID selects the accounts following the account of this id, to page through them.
+	AfterID int
+	Limit   int
+}
+
+// AuthEvent struct.
+type AuthEvent struct {
+	ID        int       `db:"id"`
+	UserID    *int      `db:"user_id"`
+	Username  string    `db:"username"`
+	Event     string    `db:"event"`
+	IP        string    `db:"ip"`
+	UserAgent string    `db:"user_agent"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// AuthEventFilter selects auth events, the zero fields match every event.
+type AuthEventFilter struct {
+	UserID   int
+	Username string
+	IP       string
+	Event    string
+	// BeforeID selects the events older than the event of this id, to page through them.
+	BeforeID int
+	Limit    int
+}
+
+// Identity struct, an account of an external identity provider linked to a user.
+type Identity struct {
+	ID       int    `db:"id"`
+	UserID   int    `db:"user_id"`
+	Provider string `db:"provider"`
+	Subject  string `db:"subject"`
+	Email    string `db:"email"`
+}
+
+// AccessToken struct, a personal access token of a user, only the hash of the token is stored.
+type AccessToken struct {
+	ID        int    `db:"id"`
+	UserID    int    `db:"user_id"`
+	Name      string `db:"name"`
+	TokenHash string `db:"token_hash"`
+	// Scopes is the comma separated list of the scopes of the token.
+	Scopes string `db:"scopes"`
+	// ExpiresAt is nil for a token without expiry.
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
@@ -0,0 +1,296 @@
+// Package jwtkeys signs the access tokens and verifies them against a set of keys.
+//
+// The keys are RSA (RS256) or Ed25519 (EdDSA) keys read from PEM files: a private key signs and verifies,
+// a public key only verifies. Each key is identified by the kid header of its tokens, its RFC 7638
+// thumbprint. The optional Not-Before and Not-After PEM headers, in RFC 3339, schedule the rotation: the
+// key that signs is the private key activated last, and a key stops verifying after its Not-After.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// minRSABits is the minimum size of the RSA keys.
+const minRSABits = 2048
+
+// Key set errors.
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrNoSigningKey is returned when no private key of the set is active.
+	ErrNoSigningKey = errors.New("no active signing key")
+)
+
+// Key is a key of the set.
+type Key struct {
+	// ID is the kid of the tokens of the key.
+	ID     string
+	Method jwt.SigningMethod
+	// NotBefore and NotAfter bound the use of the key, zero when unbounded. The key verifies before
+	// NotBefore, so that it is published ahead of signing.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// private is nil for a key that only verifies.
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// signs reports whether the key signs at t.
+func (k *Key) signs(t time.Time) bool {
+	return k.private != nil && !t.Before(k.NotBefore) && k.verifies(t)
+}
+
+// verifies reports whether the key verifies at t.
+func (k *Key) verifies(t time.Time) bool {
+	return k.NotAfter.IsZero() || t.Before(k.NotAfter)
+}
+
+// Set signs and verifies the tokens.
+type Set struct {
+	keys []*Key
+	// secret signs and verifies HS256 tokens without kid, when set.
+	secret []byte
+	// signSecret signs with secret, for a set without keys.
+	signSecret bool
+}
+
+// NewHMAC returns a set signing and verifying HS256 tokens with secret.
+func NewHMAC(secret string) *Set {
+	return &Set{secret: []byte(secret), signSecret: true}
+}
+
+// Load returns the set of the keys of the PEM files, one key per file.
+func Load(files ...string) (*Set, error) {
+	s := &Set{}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt key: %w", err)
+		}
+		k, err := ParseKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", file, err)
+		}
+		for _, other := range s.keys {
+			if other.ID == k.ID {
+				return nil, fmt.Errorf("jwt key %s: duplicate key %s", file, k.ID)
+			}
+		}
+		s.keys = append(s.keys, k)
+	}
+	if len(s.keys) == 0 {
+		return nil, errors.New("no jwt key")
+	}
+
+	return s, nil
+}
+
+// AcceptHMAC returns a copy of s also verifying the HS256 tokens of secret, which it does not sign, so
+// that the tokens issued before the switch to the keys keep working until they expire.
+func (s *Set) AcceptHMAC(secret string) *Set {
+	return &Set{keys: s.keys, secret: []byte(secret), signSecret: s.signSecret}
+}
+
+// ParseKey parses a PEM private or public key, PKCS #1, PKCS #8 or PKIX, with its schedule headers.
+func ParseKey(b []byte) (*Key, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	k := &Key{}
+	var err error
+	for _, h := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"Not-Before", &k.NotBefore},
+		{"Not-After", &k.NotAfter},
+	} {
+		if v, ok := block.Headers[h.name]; ok {
+			if *h.dst, err = time.Parse(time.RFC3339, v); err != nil {
+				return nil, fmt.Errorf("%s: %q is not an RFC 3339 time", h.name, v)
+			}
+		}
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.private, k.public, k.Method = key, &key.PublicKey, jwt.SigningMethodRS256
+	case *rsa.PublicKey:
+		k.public, k.Method = key, jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		k.private, k.public, k.Method = key, key.Public(), jwt.SigningMethodEdDSA
+	case ed25519.PublicKey:
+		k.public, k.Method = key, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and Ed25519 keys are", key)
+	}
+	if pub, ok := k.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key of %d bits, at least %d are required", pub.N.BitLen(), minRSABits)
+	}
+	if !k.NotAfter.IsZero() && !k.NotAfter.After(k.NotBefore) {
+		return nil, errors.New("Not-After must be after Not-Before")
+	}
+	k.ID = thumbprint(k.public)
+
+	return k, nil
+}
+
+// Keys returns the keys of the set.
+func (s *Set) Keys() []*Key {
+	return s.keys
+}
+
+// SigningKey returns the key signing at t, the private key activated last, nil when there is none.
+func (s *Set) SigningKey(t time.Time) *Key {
+	var current *Key
+	for _, k := range s.keys {
+		if k.signs(t) && (current == nil || k.NotBefore.After(current.NotBefore)) {
+			current = k
+		}
+	}
+
+	return current
+}
+
+// Sign returns the token of claims signed with the current key, with its kid header.
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	if s.signSecret {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	}
+	k := s.SigningKey(time.Now())
+	if k == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(k.Method, claims)
+	token.Header["kid"] = k.ID
+
+	return token.SignedString(k.private)
+}
+
+// Keyfunc returns the key verifying the token, that of its kid, which must be of its signing method. It
+// is the jwt.Keyfunc of the set.
+func (s *Set) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if s.secret != nil && t.Method == jwt.SigningMethodHS256 {
+			return s.secret, nil
+		}
+		return nil, fmt.Errorf("%w: no kid", ErrUnknownKey)
+	}
+
+	now := time.Now()
+	for _, k := range s.keys {
+		if k.ID != kid || !k.verifies(now) {
+			continue
+		}
+		if t.Method != k.Method {
+			return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
+		}
+		return k.public, nil
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+}
+
+// JWK is a public key of the set, as a JSON Web Key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// N and E are the modulus and the exponent of an RSA key.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv and X are the curve and the public key of an Ed25519 key.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys verifying at t, including the keys not yet signing.
+func (s *Set) JWKS(t time.Time) JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range s.keys {
+		if !k.verifies(t) {
+			continue
+		}
+		jwk := toJWK(k.public)
+		jwk.Kid, jwk.Use, jwk.Alg = k.ID, "sig", k.Method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// toJWK returns the public members of the JWK of key.
+func toJWK(key crypto.PublicKey) JWK {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: encode(key)}
+	}
+
+	return JWK{}
+}
+
+// thumbprint returns the RFC 7638 thumbprint of key, the hash of its required members in lexicographic order.
+func thumbprint(key crypto.PublicKey) string {
+	jwk := toJWK(key)
+	var members interface{}
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+
+	return encode(sum[:])
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,291 @@
+package jwtkeys_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"movierama/internal/jwtkeys"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// rsaKey is generated once, the RSA keys are slow to generate.
+var rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+// writeKey writes the PEM of key with the schedule headers and returns its path.
+func writeKey(t *testing.T, key interface{}, headers map[string]string) string {
+	var block *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	default:
+		b, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			b, err = x509.MarshalPKCS8PrivateKey(k)
+			block = &pem.Block{Type: "PRIVATE KEY", Bytes: b}
+		} else {
+			block = &pem.Block{Type: "PUBLIC KEY", Bytes: b}
+		}
+		require.NoError(t, err)
+	}
+	block.Headers = headers
+	f, err := os.CreateTemp(t.TempDir(), "*.pem")
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, pem.Encode(f, block))
+
+	return f.Name()
+}
+
+func newEd25519(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return pub, priv
+}
+
+func at(d time.Duration) string {
+	return time.Now().Add(d).UTC().Format(time.RFC3339)
+}
+
+func claims() jwt.Claims {
+	return &jwt.StandardClaims{Subject: "1", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+}
+
+func verify(s *jwtkeys.Set, token string) error {
+	_, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, s.Keyfunc)
+
+	return err
+}
+
+func TestSet_Sign(t *testing.T) {
+	_, edKey := newEd25519(t)
+
+	tests := map[string]struct {
+		key    interface{}
+		expAlg string
+	}{
+		"should sign with an RSA key": {
+			key:    rsaKey,
+			expAlg: "RS256",
+		},
+		"should sign with an Ed25519 key": {
+			key:    edKey,
+			expAlg: "EdDSA",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := jwtkeys.Load(writeKey(t, tt.key, nil))
+			require.NoError(t, err)
+
+			token, err := s.Sign(claims())
+			require.NoError(t, err)
+			parsed, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, s.Keyfunc)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expAlg, parsed.Header["alg"])
+			assert.Equal(t, s.Keys()[0].ID, parsed.Header["kid"])
+		})
+	}
+}
+
+func TestSet_Rotation(t *testing.T) {
+	oldPub, oldKey := newEd25519(t)
+	_, currentKey := newEd25519(t)
+	_, nextKey := newEd25519(t)
+	retiredPub, _ := newEd25519(t)
+
+	s, err := jwtkeys.Load(
+		writeKey(t, oldKey, map[string]string{"Not-Before": at(-48 * time.Hour)}),
+		writeKey(t, currentKey, map[string]string{"Not-Before": at(-time.Hour)}),
+		writeKey(t, nextKey, map[string]string{"Not-Before": at(time.Hour)}),
+		writeKey(t, retiredPub, map[string]string{"Not-After": at(-time.Minute)}),
+	)
+	require.NoError(t, err)
+	old, current, next := s.Keys()[0], s.Keys()[1], s.Keys()[2]
+
+	assert.Equal(t, current, s.SigningKey(time.Now()), "the key activated last signs")
+	assert.Equal(t, next, s.SigningKey(time.Now().Add(2*time.Hour)), "the next key signs once active")
+
+	jwks := s.JWKS(time.Now())
+	var kids []string
+	for _, k := range jwks.Keys {
+		kids = append(kids, k.Kid)
+		assert.Equal(t, "OKP", k.Kty)
+		assert.Equal(t, "EdDSA", k.Alg)
+		assert.Equal(t, "sig", k.Use)
+	}
+	assert.Equal(t, []string{old.ID, current.ID, next.ID}, kids, "the next key is published, the retired one is not")
+
+	// The tokens of the former key keep verifying.
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims())
+	token.Header["kid"] = old.ID
+	signed, err := token.SignedString(oldKey)
+	require.NoError(t, err)
+	assert.NoError(t, verify(s, signed))
+
+	verifyOnly, err := jwtkeys.Load(writeKey(t, oldPub, nil))
+	require.NoError(t, err)
+	assert.NoError(t, verify(verifyOnly, signed), "a public key verifies")
+	_, err = verifyOnly.Sign(claims())
+	assert.ErrorIs(t, err, jwtkeys.ErrNoSigningKey, "a public key does not sign")
+}
+
+func TestSet_Keyfunc(t *testing.T) {
+	edPub, edKey := newEd25519(t)
+	s, err := jwtkeys.Load(writeKey(t, edKey, nil), writeKey(t, rsaKey, nil))
+	require.NoError(t, err)
+	edID, rsaID := s.Keys()[0].ID, s.Keys()[1].ID
+	_, otherKey := newEd25519(t)
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	hs256 := sign(jwt.SigningMethodHS256, "", []byte("secret"))
+
+	tests := map[string]struct {
+		set    *jwtkeys.Set
+		token  string
+		expErr bool
+	}{
+		"should verify the token of an Ed25519 key": {
+			set:   s,
+			token: sign(jwt.SigningMethodEdDSA, edID, edKey),
+		},
+		"should verify the token of an RSA key": {
+			set:   s,
+			token: sign(jwt.SigningMethodRS256, rsaID, rsaKey),
+		},
+		"should reject a token of an unknown kid": {
+			set:    s,
+			token:  sign(jwt.SigningMethodEdDSA, "unknown", edKey),
+			expErr: true,
+		},
+		"should reject a token signed by another key": {
+			set:    s,
+			token:  sign(jwt.SigningMethodEdDSA, edID, otherKey),
+			expErr: true,
+		},
+		"should reject a token of another method than its key": {
+			set:    s,
+			token:  sign(jwt.SigningMethodHS256, edID, []byte(edPub)),
+			expErr: true,
+		},
+		"should reject a token without kid": {
+			set:    s,
+			token:  hs256,
+			expErr: true,
+		},
+		"should verify an HS256 token when accepted": {
+			set:   s.AcceptHMAC("secret"),
+			token: hs256,
+		},
+		"should verify the token of an HMAC set": {
+			set:   jwtkeys.NewHMAC("secret"),
+			token: hs256,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := verify(tt.set, tt.token)
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	signed, err := s.AcceptHMAC("secret").Sign(claims())
+	require.NoError(t, err)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(signed, &jwt.StandardClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Header["alg"], "the accepted secret does not sign")
+}
+
+func TestParseKey(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, edKey := newEd25519(t)
+	read := func(path string) []byte {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return b
+	}
+
+	tests := map[string]struct {
+		pem    []byte
+		expErr string
+	}{
+		"should reject a small RSA key": {
+			pem:    read(writeKey(t, small, nil)),
+			expErr: "at least 2048",
+		},
+		"should reject an invalid schedule": {
+			pem:    read(writeKey(t, edKey, map[string]string{"Not-Before": "tomorrow"})),
+			expErr: "Not-Before",
+		},
+		"should reject a retirement before the activation": {
+			pem:    read(writeKey(t, edKey, map[string]string{"Not-Before": at(time.Hour), "Not-After": at(0)})),
+			expErr: "Not-After must be after Not-Before",
+		},
+		"should reject a certificate": {
+			pem:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("cert")}),
+			expErr: "unsupported PEM block",
+		},
+		"should reject a file without PEM": {
+			pem:    []byte("key"),
+			expErr: "no PEM block",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := jwtkeys.ParseKey(tt.pem)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expErr)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	_, edKey := newEd25519(t)
+	path := writeKey(t, edKey, nil)
+
+	_, err := jwtkeys.Load(path, path)
+	assert.ErrorContains(t, err, "duplicate key")
+	_, err = jwtkeys.Load(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+	_, err = jwtkeys.Load()
+	assert.Error(t, err)
+}
+
+func TestThumbprint(t *testing.T) {
+	// The RSA key of RFC 7638 section 3.1 and its thumbprint.
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFF" +
+		"xuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQ" +
+		"MicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBn" +
+		"iIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	require.NoError(t, err)
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	k, err := jwtkeys.ParseKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(key)}))
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", k.ID)
+}
@@ -0,0 +1,128 @@
+// Package locale negotiates the language of a request and localizes the relative times and the messages
+// returned to the users.
+package locale
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xeonx/timeago"
+)
+
+// Locale is a supported language, by its ISO 639-1 code.
+type Locale string
+
+// Supported locales.
+const (
+	English Locale = "en"
+	Greek   Locale = "el"
+	French  Locale = "fr"
+)
+
+// Default is the locale of the requests that accept none of the supported locales.
+const Default = English
+
+// Supported contains the supported locales, the default first.
+var Supported = []Locale{English, Greek, French}
+
+// maxHeaderLength limits the length of the Accept-Language headers that are parsed.
+const maxHeaderLength = 512
+
+// greek formats the relative times in Greek, timeago has no Greek config.
+var greek = timeago.Config{
+	PastPrefix:   "πριν από ",
+	FuturePrefix: "σε ",
+
+	Periods: []timeago.FormatPeriod{
+		{D: time.Second, One: "περίπου ένα δευτερόλεπτο", Many: "%d δευτερόλεπτα"},
+		{D: time.Minute, One: "περίπου ένα λεπτό", Many: "%d λεπτά"},
+		{D: time.Hour, One: "περίπου μία ώρα", Many: "%d ώρες"},
+		{D: timeago.Day, One: "μία ημέρα", Many: "%d ημέρες"},
+		{D: timeago.Month, One: "έναν μήνα", Many: "%d μήνες"},
+		{D: timeago.Year, One: "ένα χρόνο", Many: "%d χρόνια"},
+	},
+
+	Zero: "περίπου ένα δευτερόλεπτο",
+
+	Max:           73 * time.Hour,
+	DefaultLayout: "02/01/2006",
+}
+
+// timeagoConfigs contains the relative time format of each locale.
+var timeagoConfigs = map[Locale]timeago.Config{
+	English: timeago.English,
+	Greek:   greek,
+	French:  timeago.French,
+}
+
+type localeContextKey struct{}
+
+// WithLocale returns a copy of ctx carrying the locale.
+func WithLocale(ctx context.Context, l Locale) context.Context {
+	return context.WithValue(ctx, localeContextKey{}, l)
+}
+
+// FromContext returns the locale of ctx, the default locale if there is none.
+func FromContext(ctx context.Context) Locale {
+	if l, ok := ctx.Value(localeContextKey{}).(Locale); ok {
+		return l
+	}
+
+	return Default
+}
+
+// Parse returns the supported locale of a language tag, e.g. fr-CA, and whether there is one.
+func Parse(tag string) (Locale, bool) {
+	primary := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(primary, "-_"); i >= 0 {
+		primary = primary[:i]
+	}
+	for _, l := range Supported {
+		if primary == string(l) {
+			return l, true
+		}
+	}
+
+	return "", false
+}
+
+// Negotiate returns the supported locale the Accept-Language header prefers, by quality then by order, and
+// the default locale when the header accepts none of them.
+func Negotiate(acceptLanguage string) Locale {
+	if len(acceptLanguage) > maxHeaderLength {
+		return Default
+	}
+
+	best, bestQ := Default, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			var err error
+			if q, err = strconv.ParseFloat(params[len("q="):], 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		l, ok := Parse(tag)
+		if strings.TrimSpace(tag) == "*" {
+			l, ok = Default, true
+		}
+		if ok && q > bestQ {
+			best, bestQ = l, q
+		}
+	}
+
+	return best
+}
+
+// TimeAgo formats t relative to now, e.g. "about an hour ago", and as a date past three days.
+func (l Locale) TimeAgo(t time.Time) string {
+	cfg, ok := timeagoConfigs[l]
+	if !ok {
+		cfg = timeagoConfigs[Default]
+	}
+
+	return cfg.Format(t)
+}
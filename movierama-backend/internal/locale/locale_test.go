@@ -0,0 +1,181 @@
+package locale_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"movierama/internal/app/admin"
+	"movierama/internal/app/auth"
+	"movierama/internal/app/movie"
+	"movierama/internal/locale"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		exp    locale.Locale
+	}{
+		"should default to English without header": {
+			exp: locale.English,
+		},
+		"should pick a supported locale": {
+			header: "el",
+			exp:    locale.Greek,
+		},
+		"should match the primary language of a region": {
+			header: "fr-CA",
+			exp:    locale.French,
+		},
+		"should pick the highest quality": {
+			header: "en;q=0.5, el-GR;q=0.8, fr;q=0.7",
+			exp:    locale.Greek,
+		},
+		"should pick the first of equal qualities": {
+			header: "fr, el",
+			exp:    locale.French,
+		},
+		"should skip the unsupported locales": {
+			header: "de-DE, fr;q=0.3",
+			exp:    locale.French,
+		},
+		"should default to English on unsupported locales": {
+			header: "de-DE, it",
+			exp:    locale.English,
+		},
+		"should skip the refused locales": {
+			header: "el;q=0, fr;q=0.1",
+			exp:    locale.French,
+		},
+		"should skip an invalid quality": {
+			header: "el;q=2, fr;q=0.1",
+			exp:    locale.French,
+		},
+		"should default to English on a wildcard": {
+			header: "*",
+			exp:    locale.English,
+		},
+		"should ignore an oversized header": {
+			header: "el," + strings.Repeat("a", 600),
+			exp:    locale.English,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, locale.Negotiate(tt.header))
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, locale.English, locale.FromContext(context.Background()))
+	assert.Equal(t, locale.Greek, locale.FromContext(locale.WithLocale(context.Background(), locale.Greek)))
+}
+
+func TestLocale_TimeAgo(t *testing.T) {
+	hourAgo := time.Now().Add(-time.Hour)
+	lastYear := time.Date(2025, time.March, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		locale locale.Locale
+		t      time.Time
+		exp    string
+	}{
+		"should format in English": {
+			locale: locale.English,
+			t:      hourAgo,
+			exp:    "about an hour ago",
+		},
+		"should format in Greek": {
+			locale: locale.Greek,
+			t:      time.Now().Add(-2 * time.Hour),
+			exp:    "πριν από 2 ώρες",
+		},
+		"should format in French": {
+			locale: locale.French,
+			t:      hourAgo,
+			exp:    "il y a environ une heure",
+		},
+		"should format an old time as a date of the locale": {
+			locale: locale.Greek,
+			t:      lastYear,
+			exp:    "04/03/2025",
+		},
+		"should format in English for an unsupported locale": {
+			locale: "de",
+			t:      hourAgo,
+			exp:    "about an hour ago",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, tt.locale.TimeAgo(tt.t))
+		})
+	}
+}
+
+func TestLocale_Translate(t *testing.T) {
+	tests := map[string]struct {
+		locale locale.Locale
+		msg    string
+		exp    string
+	}{
+		"should keep the English message": {
+			locale: locale.English,
+			msg:    "movie not found",
+			exp:    "movie not found",
+		},
+		"should translate a message": {
+			locale: locale.French,
+			msg:    "movie not found",
+			exp:    "film introuvable",
+		},
+		"should translate a message with a parameter": {
+			locale: locale.Greek,
+			msg:    "movie_id must be a number",
+			exp:    "το movie_id πρέπει να είναι αριθμός",
+		},
+		"should translate a message with a number": {
+			locale: locale.French,
+			msg:    "at most 5 movies can be submitted per hour",
+			exp:    "au plus 5 films peuvent être proposés par heure",
+		},
+		"should keep a message without translation": {
+			locale: locale.Greek,
+			msg:    "request body has an error",
+			exp:    "request body has an error",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, tt.locale.Translate(tt.msg))
+		})
+	}
+}
+
+func TestLocale_TranslateErrors(t *testing.T) {
+	// The errors whose messages are returned to the users.
+	errs := []error{
+		auth.ErrInvalidCredentials, auth.ErrUsernameTaken, auth.ErrTooManyAttempts, auth.ErrAccountBanned,
+		auth.ErrAccountSuspended, auth.ErrTokenRevoked, auth.ErrUnknownProvider, auth.ErrLoginExpired,
+		auth.ErrIdentityRejected, auth.ErrIdentityNotLinked, auth.ErrIdentityLinked, auth.ErrProviderLinked,
+		auth.ErrInvalidEmail, auth.ErrEmailTaken, auth.ErrEmailRequired, auth.ErrEmailNotVerified,
+		auth.ErrInvalidEmailToken, auth.ErrInvalidTokenName, auth.ErrInvalidScope, auth.ErrInvalidExpiry,
+		auth.ErrTooManyTokens, auth.ErrTokenNotFound, auth.ErrInvalidAccessToken,
+		movie.ErrMovieNotFound, movie.ErrInvalidAction, movie.ErrOwnMovie, &movie.DuplicateError{},
+		admin.ErrForbidden, admin.ErrUserNotFound, admin.ErrMovieNotFound, admin.ErrOwnAccount,
+		admin.ErrInvalidStatus, admin.ErrInvalidUntil, admin.ErrInvalidRole, admin.ErrInvalidReason,
+		admin.ErrInvalidCategory, admin.ErrOwnMovie, admin.ErrAlreadyReported, admin.ErrInvalidResolution,
+		admin.ErrSameMovie,
+	}
+
+	for _, l := range locale.Supported[1:] {
+		for _, err := range errs {
+			assert.NotEqual(t, err.Error(), l.Translate(err.Error()), "%s has no %s translation", err, l)
+		}
+	}
+}
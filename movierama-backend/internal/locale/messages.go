@@ -0,0 +1,303 @@
+package locale
+
+import "strings"
+
+// messages contains the translations of the messages returned to the users, by their English text. A %s or %d
+// verb stands for a part of the message kept as is, e.g. a parameter name or a limit.
+var messages = map[string]map[Locale]string{
+	// Auth.
+	"invalid username or password": {
+		Greek:  "λάθος όνομα χρήστη ή κωδικός πρόσβασης",
+		French: "nom d'utilisateur ou mot de passe invalide",
+	},
+	"username is already taken": {
+		Greek:  "το όνομα χρήστη χρησιμοποιείται ήδη",
+		French: "ce nom d'utilisateur est déjà pris",
+	},
+	"too many failed login attempts": {
+		Greek:  "πάρα πολλές αποτυχημένες προσπάθειες σύνδεσης",
+		French: "trop de tentatives de connexion échouées",
+	},
+	"account is banned": {
+		Greek:  "ο λογαριασμός έχει αποκλειστεί",
+		French: "le compte est banni",
+	},
+	"account is suspended": {
+		Greek:  "ο λογαριασμός έχει ανασταλεί",
+		French: "le compte est suspendu",
+	},
+	"token is no longer valid": {
+		Greek:  "το διακριτικό δεν ισχύει πλέον",
+		French: "le jeton n'est plus valide",
+	},
+	"unknown identity provider": {
+		Greek:  "άγνωστος πάροχος ταυτότητας",
+		French: "fournisseur d'identité inconnu",
+	},
+	"the login is invalid or has expired, start it again": {
+		Greek:  "η σύνδεση δεν είναι έγκυρη ή έχει λήξει, ξεκινήστε την ξανά",
+		French: "la connexion est invalide ou a expiré, recommencez-la",
+	},
+	"the identity provider rejected the login": {
+		Greek:  "ο πάροχος ταυτότητας απέρριψε τη σύνδεση",
+		French: "le fournisseur d'identité a refusé la connexion",
+	},
+	"the identity is not linked to an account": {
+		Greek:  "η ταυτότητα δεν είναι συνδεδεμένη με λογαριασμό",
+		French: "l'identité n'est liée à aucun compte",
+	},
+	"the identity is linked to another account": {
+		Greek:  "η ταυτότητα είναι συνδεδεμένη με άλλο λογαριασμό",
+		French: "l'identité est liée à un autre compte",
+	},
+	"another identity of the provider is linked to the account": {
+		Greek:  "άλλη ταυτότητα του παρόχου είναι συνδεδεμένη με τον λογαριασμό",
+		French: "une autre identité du fournisseur est liée au compte",
+	},
+	"invalid email address": {
+		Greek:  "μη έγκυρη διεύθυνση email",
+		French: "adresse e-mail invalide",
+	},
+	"email is already taken": {
+		Greek:  "το email χρησιμοποιείται ήδη",
+		French: "cette adresse e-mail est déjà utilisée",
+	},
+	"an email is required": {
+		Greek:  "απαιτείται email",
+		French: "une adresse e-mail est requise",
+	},
+	"email is not verified": {
+		Greek:  "το email δεν έχει επαληθευτεί",
+		French: "l'adresse e-mail n'est pas vérifiée",
+	},
+	"the link is invalid or has expired": {
+		Greek:  "ο σύνδεσμος δεν είναι έγκυρος ή έχει λήξει",
+		French: "le lien est invalide ou a expiré",
+	},
+	"the token name must have 1 to 64 characters": {
+		Greek:  "το όνομα του διακριτικού πρέπει να έχει από 1 έως 64 χαρακτήρες",
+		French: "le nom du jeton doit comporter de 1 à 64 caractères",
+	},
+	"the token needs at least one scope, of %s": {
+		Greek:  "το διακριτικό χρειάζεται τουλάχιστον ένα δικαίωμα, από %s",
+		French: "le jeton nécessite au moins une portée, parmi %s",
+	},
+	"the token expiry must be in the future": {
+		Greek:  "η λήξη του διακριτικού πρέπει να είναι στο μέλλον",
+		French: "l'expiration du jeton doit être dans le futur",
+	},
+	"too many access tokens, revoke one first": {
+		Greek:  "πάρα πολλά διακριτικά πρόσβασης, ανακαλέστε πρώτα ένα",
+		French: "trop de jetons d'accès, révoquez-en un d'abord",
+	},
+	"access token not found": {
+		Greek:  "το διακριτικό πρόσβασης δεν βρέθηκε",
+		French: "jeton d'accès introuvable",
+	},
+	"invalid, expired or revoked access token": {
+		Greek:  "μη έγκυρο, ληγμένο ή ανακληθέν διακριτικό πρόσβασης",
+		French: "jeton d'accès invalide, expiré ou révoqué",
+	},
+	"personal access tokens are not accepted by this route": {
+		Greek:  "τα προσωπικά διακριτικά πρόσβασης δεν γίνονται δεκτά σε αυτή τη διαδρομή",
+		French: "les jetons d'accès personnels ne sont pas acceptés par cette route",
+	},
+	"the token lacks the %s scope": {
+		Greek:  "στο διακριτικό λείπει το δικαίωμα %s",
+		French: "il manque la portée %s au jeton",
+	},
+	"%s role required": {
+		Greek:  "απαιτείται ο ρόλος %s",
+		French: "le rôle %s est requis",
+	},
+	"missing or malformed jwt": {
+		Greek:  "το jwt λείπει ή δεν είναι έγκυρο",
+		French: "jwt manquant ou mal formé",
+	},
+	"invalid or expired jwt": {
+		Greek:  "μη έγκυρο ή ληγμένο jwt",
+		French: "jwt invalide ou expiré",
+	},
+	"authentication required": {
+		Greek:  "απαιτείται σύνδεση",
+		French: "authentification requise",
+	},
+
+	// Movies.
+	"movie not found": {
+		Greek:  "η ταινία δεν βρέθηκε",
+		French: "film introuvable",
+	},
+	"invalid movie action": {
+		Greek:  "μη έγκυρη ενέργεια ταινίας",
+		French: "action sur le film invalide",
+	},
+	"users cannot vote for the movies they have submitted": {
+		Greek:  "οι χρήστες δεν μπορούν να ψηφίσουν τις ταινίες που έχουν υποβάλει",
+		French: "les utilisateurs ne peuvent pas voter pour les films qu'ils ont proposés",
+	},
+	"the movie may already exist": {
+		Greek:  "η ταινία ίσως υπάρχει ήδη",
+		French: "le film existe peut-être déjà",
+	},
+	"the title or the description contains a banned word": {
+		Greek:  "ο τίτλος ή η περιγραφή περιέχει απαγορευμένη λέξη",
+		French: "le titre ou la description contient un mot interdit",
+	},
+	"links are not allowed in the title": {
+		Greek:  "δεν επιτρέπονται σύνδεσμοι στον τίτλο",
+		French: "les liens ne sont pas autorisés dans le titre",
+	},
+	"links are not allowed in the description": {
+		Greek:  "δεν επιτρέπονται σύνδεσμοι στην περιγραφή",
+		French: "les liens ne sont pas autorisés dans la description",
+	},
+	"at most %d links are allowed in the description": {
+		Greek:  "επιτρέπονται έως %d σύνδεσμοι στην περιγραφή",
+		French: "au plus %d liens sont autorisés dans la description",
+	},
+	"at most %d movies can be submitted per hour": {
+		Greek:  "μπορούν να υποβληθούν έως %d ταινίες την ώρα",
+		French: "au plus %d films peuvent être proposés par heure",
+	},
+	"invalid GraphQL request": {
+		Greek:  "μη έγκυρο αίτημα GraphQL",
+		French: "requête GraphQL invalide",
+	},
+
+	// Moderation.
+	"action not allowed for the role": {
+		Greek:  "η ενέργεια δεν επιτρέπεται για τον ρόλο",
+		French: "action non autorisée pour ce rôle",
+	},
+	"user not found": {
+		Greek:  "ο χρήστης δεν βρέθηκε",
+		French: "utilisateur introuvable",
+	},
+	"moderators cannot act on their own account": {
+		Greek:  "οι συντονιστές δεν μπορούν να ενεργήσουν στον δικό τους λογαριασμό",
+		French: "les modérateurs ne peuvent pas agir sur leur propre compte",
+	},
+	"invalid account status": {
+		Greek:  "μη έγκυρη κατάσταση λογαριασμού",
+		French: "statut de compte invalide",
+	},
+	"a suspension must end in the future": {
+		Greek:  "μια αναστολή πρέπει να λήγει στο μέλλον",
+		French: "une suspension doit se terminer dans le futur",
+	},
+	"invalid role": {
+		Greek:  "μη έγκυρος ρόλος",
+		French: "rôle invalide",
+	},
+	"a reason of at most 500 characters is required": {
+		Greek:  "απαιτείται αιτιολογία έως 500 χαρακτήρων",
+		French: "un motif d'au plus 500 caractères est requis",
+	},
+	"invalid report category": {
+		Greek:  "μη έγκυρη κατηγορία αναφοράς",
+		French: "catégorie de signalement invalide",
+	},
+	"users cannot report their own movies": {
+		Greek:  "οι χρήστες δεν μπορούν να αναφέρουν τις δικές τους ταινίες",
+		French: "les utilisateurs ne peuvent pas signaler leurs propres films",
+	},
+	"movie already reported": {
+		Greek:  "η ταινία έχει ήδη αναφερθεί",
+		French: "film déjà signalé",
+	},
+	"invalid resolution": {
+		Greek:  "μη έγκυρη απόφαση",
+		French: "résolution invalide",
+	},
+	"a movie cannot be merged into itself": {
+		Greek:  "μια ταινία δεν μπορεί να συγχωνευθεί με τον εαυτό της",
+		French: "un film ne peut pas être fusionné avec lui-même",
+	},
+
+	// Requests.
+	"%s must be a number": {
+		Greek:  "το %s πρέπει να είναι αριθμός",
+		French: "%s doit être un nombre",
+	},
+	"%s must be a user id": {
+		Greek:  "το %s πρέπει να είναι αναγνωριστικό χρήστη",
+		French: "%s doit être un identifiant d'utilisateur",
+	},
+	"%s must be an event id": {
+		Greek:  "το %s πρέπει να είναι αναγνωριστικό συμβάντος",
+		French: "%s doit être un identifiant d'événement",
+	},
+	"rate limit exceeded": {
+		Greek:  "υπέρβαση του ορίου αιτημάτων",
+		French: "limite de requêtes dépassée",
+	},
+	"Bad Request": {
+		Greek:  "Μη έγκυρο αίτημα",
+		French: "Requête invalide",
+	},
+	"Unauthorized": {
+		Greek:  "Απαιτείται εξουσιοδότηση",
+		French: "Non autorisé",
+	},
+	"Forbidden": {
+		Greek:  "Απαγορεύεται",
+		French: "Interdit",
+	},
+	"Not Found": {
+		Greek:  "Δεν βρέθηκε",
+		French: "Introuvable",
+	},
+	"Method Not Allowed": {
+		Greek:  "Η μέθοδος δεν επιτρέπεται",
+		French: "Méthode non autorisée",
+	},
+	"Request Entity Too Large": {
+		Greek:  "Το αίτημα είναι πολύ μεγάλο",
+		French: "Requête trop volumineuse",
+	},
+	"Internal Server Error": {
+		Greek:  "Εσωτερικό σφάλμα διακομιστή",
+		French: "Erreur interne du serveur",
+	},
+	"Service Unavailable": {
+		Greek:  "Η υπηρεσία δεν είναι διαθέσιμη",
+		French: "Service indisponible",
+	},
+}
+
+// verbs contains the verbs that stand for a kept part of a message.
+var verbs = []string{"%s", "%d"}
+
+// Translate returns the message in the locale, the message as is when it has no translation.
+func (l Locale) Translate(msg string) string {
+	if l == English {
+		return msg
+	}
+	if t, ok := messages[msg][l]; ok {
+		return t
+	}
+	for key, translations := range messages {
+		t, ok := translations[l]
+		if !ok {
+			continue
+		}
+		for _, verb := range verbs {
+			if arg, ok := match(key, verb, msg); ok {
+				return strings.Replace(t, verb, arg, 1)
+			}
+		}
+	}
+
+	return msg
+}
+
+// match returns the part of msg standing for the verb of key, when the rest of msg is the rest of key.
+func match(key, verb, msg string) (string, bool) {
+	prefix, suffix, ok := strings.Cut(key, verb)
+	if !ok || len(msg) <= len(prefix)+len(suffix) || !strings.HasPrefix(msg, prefix) || !strings.HasSuffix(msg, suffix) {
+		return "", false
+	}
+
+	return msg[len(prefix) : len(msg)-len(suffix)], true
+}
@@ -0,0 +1,125 @@
+// Package logging provides the structured JSON logger of the app and the
+// request scoped helpers used to correlate log lines.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the values of sensitive payload fields.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys contains the substrings of the payload field names that are redacted.
+var sensitiveKeys = []string{"password", "token", "secret", "authorization"}
+
+type requestIDContextKey struct{}
+
+// New returns a JSON logger writing to stdout at the given level, which defaults to info.
+func New(level string) (*zap.Logger, error) {
+	lvl := zapcore.InfoLevel
+	if level != "" {
+		var err error
+		if lvl, err = zapcore.ParseLevel(level); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
+	cfg.Sampling = nil
+	cfg.OutputPaths = []string{"stdout"}
+	cfg.EncoderConfig.TimeKey = "time"
+	cfg.EncoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return cfg.Build()
+}
+
+// NewRequestID returns a random request id.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx carrying the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestID returns the request id of ctx, empty if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+
+	return id
+}
+
+// For returns the logger annotated with the request id and the trace id of ctx.
+func For(ctx context.Context, l *zap.Logger) *zap.Logger {
+	var fields []zap.Field
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+	}
+	if len(fields) == 0 {
+		return l
+	}
+
+	return l.With(fields...)
+}
+
+// Payload returns a field logging v as JSON with the passwords and tokens redacted.
+func Payload(key string, v interface{}) zap.Field {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return zap.String(key, "unserializable payload")
+	}
+	var payload interface{}
+	if err = json.Unmarshal(b, &payload); err != nil {
+		return zap.String(key, "unserializable payload")
+	}
+
+	return zap.Any(key, redact(payload))
+}
+
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if isSensitive(k) {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = redact(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = redact(val)
+		}
+	}
+
+	return v
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package logging_test
+
+import (
+	"context"
+	"movierama/internal/logging"
+	"movierama/internal/tracing"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		level    string
+		expLevel zapcore.Level
+		expErr   bool
+	}{
+		"should default to info": {
+			expLevel: zapcore.InfoLevel,
+		},
+		"should parse the level": {
+			level:    "debug",
+			expLevel: zapcore.DebugLevel,
+		},
+		"should reject an unknown level": {
+			level:  "verbose",
+			expErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, err := logging.New(tt.level)
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, l.Core().Enabled(tt.expLevel))
+			assert.False(t, l.Core().Enabled(tt.expLevel-1))
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(core)
+
+	tp, _ := tracing.NewTestProvider()
+	ctx, span := tp.Tracer("test").Start(logging.WithRequestID(context.Background(), "rid"), "span")
+	defer span.End()
+
+	logging.For(context.Background(), l).Info("anonymous")
+	logging.For(ctx, l).Info("scoped")
+
+	entries := logs.All()
+	assert.Len(t, entries, 2)
+	assert.NotContains(t, entries[0].ContextMap(), "request_id")
+	assert.NotContains(t, entries[0].ContextMap(), "trace_id")
+	assert.Equal(t, "rid", entries[1].ContextMap()["request_id"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), entries[1].ContextMap()["trace_id"])
+}
+
+func TestNewRequestID(t *testing.T) {
+	id := logging.NewRequestID()
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, id, logging.NewRequestID())
+}
+
+func TestPayload(t *testing.T) {
+	type login struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	tests := map[string]struct {
+		payload interface{}
+		exp     interface{}
+	}{
+		"should redact the password of a struct": {
+			payload: &login{Username: "user", Password: "pass"},
+			exp:     map[string]interface{}{"username": "user", "password": logging.Redacted},
+		},
+		"should redact nested tokens and secrets": {
+			payload: map[string]interface{}{
+				"input": map[string]interface{}{"accessToken": "t", "title": "movie"},
+				"list":  []interface{}{map[string]interface{}{"client_secret": "s"}},
+			},
+			exp: map[string]interface{}{
+				"input": map[string]interface{}{"accessToken": logging.Redacted, "title": "movie"},
+				"list":  []interface{}{map[string]interface{}{"client_secret": logging.Redacted}},
+			},
+		},
+		"should keep scalars": {
+			payload: "value",
+			exp:     "value",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			zap.New(core).Info("payload", logging.Payload("payload", tt.payload))
+
+			assert.Equal(t, tt.exp, logs.All()[0].ContextMap()["payload"])
+		})
+	}
+}
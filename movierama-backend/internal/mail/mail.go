@@ -0,0 +1,100 @@
+// Package mail sends the emails of the app, with SMTP or to a capture for the tests and local development.
+package mail
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidHeader is returned for a recipient or a subject spanning several lines, which would inject headers.
+var ErrInvalidHeader = errors.New("invalid email header")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+
+	return nil
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Capture keeps the sent emails in memory.
+type Capture struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewCapture constructor.
+func NewCapture() *Capture {
+	return &Capture{}
+}
+
+// Send implements Mailer.
+func (c *Capture) Send(_ context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg)
+
+	return nil
+}
+
+// Messages returns the sent emails, oldest first.
+func (c *Capture) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Message(nil), c.messages...)
+}
+
+// Last returns the last email sent to the recipient.
+func (c *Capture) Last(to string) (Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if c.messages[i].To == to {
+			return c.messages[i], true
+		}
+	}
+
+	return Message{}, false
+}
+
+// Log writes the emails to the log instead of sending them, when no SMTP server is configured. Their
+// bodies carry tokens, they are only logged at the debug level.
+type Log struct {
+	log *zap.Logger
+}
+
+// NewLog constructor.
+func NewLog(logger *zap.Logger) *Log {
+	return &Log{log: logger}
+}
+
+// Send implements Mailer.
+func (l *Log) Send(_ context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	l.log.Info("email not sent, no SMTP server", zap.String("to", msg.To), zap.String("subject", msg.Subject))
+	l.log.Debug("email body", zap.String("to", msg.To), zap.String("body", msg.Body))
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package mail_test
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime/quotedprintable"
+	"movierama/internal/mail"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal SMTP server accepting one email per connection.
+type smtpServer struct {
+	ln     net.Listener
+	from   chan string
+	rcpt   chan string
+	data   chan string
+	reject string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpServer{ln: ln, from: make(chan string, 1), rcpt: make(chan string, 1), data: make(chan string, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case cmd == "EHLO" || cmd == "HELO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250 8BITMIME")
+		case cmd == "MAIL":
+			s.from <- line
+			_ = tp.PrintfLine("250 OK")
+		case cmd == "RCPT" && s.reject != "":
+			_ = tp.PrintfLine("550 %s", s.reject)
+		case cmd == "RCPT":
+			s.rcpt <- line
+			_ = tp.PrintfLine("250 OK")
+		case cmd == "DATA":
+			_ = tp.PrintfLine("354 Go ahead")
+			b, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.data <- string(b)
+			_ = tp.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestSMTP_Send(t *testing.T) {
+	srv := newSMTPServer(t)
+	m, err := mail.NewSMTP("127.0.0.1", srv.port(), "", "", "MovieRama <no-reply@movierama.dev>")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = m.Send(ctx, mail.Message{To: "alice@example.com", Subject: "Vérifiez", Body: "Hello,\nopen https://movierama.dev/verify?token=abc"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "MAIL FROM:<no-reply@movierama.dev> BODY=8BITMIME", <-srv.from)
+	assert.Equal(t, "RCPT TO:<alice@example.com>", <-srv.rcpt)
+	data := <-srv.data
+	// the dot reader turns the CRLF line endings into LF
+	header, body, _ := strings.Cut(data, "\n\n")
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(header + "\n\n")))
+	h, err := r.ReadMIMEHeader()
+	require.NoError(t, err)
+	assert.Equal(t, `"MovieRama" <no-reply@movierama.dev>`, h.Get("From"))
+	assert.Equal(t, "<alice@example.com>", h.Get("To"))
+	assert.Equal(t, "=?utf-8?q?V=C3=A9rifiez?=", h.Get("Subject"))
+	assert.Equal(t, "text/plain; charset=UTF-8", h.Get("Content-Type"))
+	assert.True(t, strings.HasSuffix(h.Get("Message-ID"), "@movierama.dev>"))
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello,\nopen https://movierama.dev/verify?token=abc", strings.TrimRight(string(decoded), "\n"))
+}
+
+func TestSMTP_Errors(t *testing.T) {
+	srv := newSMTPServer(t)
+	srv.reject = "no such user"
+	m, err := mail.NewSMTP("127.0.0.1", srv.port(), "", "", "no-reply@movierama.dev")
+	require.NoError(t, err)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	down, err := mail.NewSMTP("127.0.0.1", closedPort, "", "", "no-reply@movierama.dev")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		mailer *mail.SMTP
+		msg    mail.Message
+		expErr string
+	}{
+		"should reject a recipient injecting headers": {
+			mailer: m,
+			msg:    mail.Message{To: "alice@example.com\r\nBcc: mallory@example.com", Subject: "s"},
+			expErr: mail.ErrInvalidHeader.Error(),
+		},
+		"should reject a subject injecting headers": {
+			mailer: m,
+			msg:    mail.Message{To: "alice@example.com", Subject: "s\nBcc: mallory@example.com"},
+			expErr: mail.ErrInvalidHeader.Error(),
+		},
+		"should reject an invalid recipient": {
+			mailer: m,
+			msg:    mail.Message{To: "alice", Subject: "s"},
+			expErr: `recipient "alice"`,
+		},
+		"should return the refusal of the server": {
+			mailer: m,
+			msg:    mail.Message{To: "alice@example.com", Subject: "s"},
+			expErr: "no such user",
+		},
+		"should return the connection errors": {
+			mailer: down,
+			msg:    mail.Message{To: "alice@example.com", Subject: "s"},
+			expErr: "connection refused",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.mailer.Send(context.Background(), tt.msg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expErr)
+		})
+	}
+}
+
+func TestNewSMTP(t *testing.T) {
+	_, err := mail.NewSMTP("localhost", 25, "", "", "not an address")
+	assert.Error(t, err)
+}
+
+func TestCapture(t *testing.T) {
+	c := mail.NewCapture()
+	for i := 0; i < 3; i++ {
+		to := "alice@example.com"
+		if i == 1 {
+			to = "bob@example.com"
+		}
+		require.NoError(t, c.Send(context.Background(), mail.Message{To: to, Subject: strconv.Itoa(i)}))
+	}
+	assert.ErrorIs(t, c.Send(context.Background(), mail.Message{To: "alice@example.com", Subject: "a\r\nb"}), mail.ErrInvalidHeader)
+
+	assert.Len(t, c.Messages(), 3)
+	last, ok := c.Last("alice@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "2", last.Subject)
+	_, ok = c.Last("carol@example.com")
+	assert.False(t, ok)
+}
@@ -0,0 +1,129 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP sends the emails to an SMTP server. The connection is upgraded with STARTTLS when the server offers
+// it, and the credentials are only sent over TLS, or to localhost.
+type SMTP struct {
+	host     string
+	addr     string
+	username string
+	password string
+	from     *mail.Address
+}
+
+// NewSMTP constructor, from is the address of the sender, e.g. "MovieRama <no-reply@movierama.dev>".
+func NewSMTP(host string, port int, username, password, from string) (*SMTP, error) {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("sender %q: %w", from, err)
+	}
+
+	return &SMTP{
+		host:     host,
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		username: username,
+		password: password,
+		from:     addr,
+	}, nil
+}
+
+// Send implements Mailer, the deadline of ctx bounds the whole exchange.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("recipient %q: %w", msg.To, err)
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err = c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(s.format(to, msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// format returns the message with its headers, the body is quoted-printable.
+func (s *SMTP) format(to *mail.Address, msg Message) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", s.from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", s.messageID())
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	_, _ = qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+	_ = qp.Close()
+
+	return b.Bytes()
+}
+
+func (s *SMTP) messageID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	domain := s.from.Address[strings.LastIndex(s.from.Address, "@")+1:]
+
+	return "<" + hex.EncodeToString(id) + "@" + domain + ">"
+}
@@ -0,0 +1,146 @@
+// Package metrics contains the Prometheus collectors of the app.
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "movierama"
+
+// Login results.
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+	// LoginBlocked is a login rejected without checking the password after repeated failures.
+	LoginBlocked = "blocked"
+	// LoginDenied is a login with valid credentials of a suspended or banned account.
+	LoginDenied = "denied"
+)
+
+// Metrics contains the HTTP and business collectors.
+type Metrics struct {
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	registrations prometheus.Counter
+	logins        *prometheus.CounterVec
+	moviesCreated prometheus.Counter
+	votes         *prometheus.CounterVec
+	moderation    *prometheus.CounterVec
+	reports       *prometheus.CounterVec
+	filtered      *prometheus.CounterVec
+}
+
+// New returns the app metrics registered to reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by route template, method and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route template and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		registrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_total",
+			Help:      "Number of registered users.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Number of login attempts by result.",
+		}, []string{"result"}),
+		moviesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "movies_created_total",
+			Help:      "Number of submitted movies.",
+		}),
+		votes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "votes_total",
+			Help:      "Number of votes cast by type.",
+		}, []string{"type"}),
+		moderation: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "moderation_actions_total",
+			Help:      "Number of moderation actions by type.",
+		}, []string{"action"}),
+		reports: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "movie_reports_total",
+			Help:      "Number of movie reports by category.",
+		}, []string{"category"}),
+		filtered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "movies_filtered_total",
+			Help:      "Number of submitted movies rejected or flagged by the content filter, by rule and verdict.",
+		}, []string{"rule", "verdict"}),
+	}
+	reg.MustRegister(m.httpRequests, m.httpDuration, m.registrations, m.logins, m.moviesCreated, m.votes, m.moderation, m.reports,
+		m.filtered)
+
+	// Expose zero valued series before the first event.
+	m.logins.WithLabelValues(LoginSucceeded)
+	m.logins.WithLabelValues(LoginFailed)
+	m.logins.WithLabelValues(LoginBlocked)
+	m.logins.WithLabelValues(LoginDenied)
+
+	return m
+}
+
+// ObserveRequest records an HTTP request.
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+// UserRegistered records a registration.
+func (m *Metrics) UserRegistered() {
+	m.registrations.Inc()
+}
+
+// Login records a login attempt with its result.
+func (m *Metrics) Login(result string) {
+	m.logins.WithLabelValues(result).Inc()
+}
+
+// MovieCreated records a movie submission.
+func (m *Metrics) MovieCreated() {
+	m.moviesCreated.Inc()
+}
+
+// VoteCast records a vote of the given type.
+func (m *Metrics) VoteCast(voteType string) {
+	m.votes.WithLabelValues(voteType).Inc()
+}
+
+// ModerationAction records a moderation action of the given type.
+func (m *Metrics) ModerationAction(action string) {
+	m.moderation.WithLabelValues(action).Inc()
+}
+
+// MovieReported records the report of a movie in the given category.
+func (m *Metrics) MovieReported(category string) {
+	m.reports.WithLabelValues(category).Inc()
+}
+
+// MovieFiltered records a submitted movie rejected or flagged by a rule of the content filter.
+func (m *Metrics) MovieFiltered(rule, verdict string) {
+	m.filtered.WithLabelValues(rule, verdict).Inc()
+}
+
+// RegisterDB registers the connection pool stats of db, name tells the handles apart.
+func RegisterDB(reg prometheus.Registerer, name string, db *sql.DB) error {
+	return reg.Register(collectors.NewDBStatsCollector(db, name))
+}
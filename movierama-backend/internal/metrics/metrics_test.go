@@ -0,0 +1,101 @@
+package metrics_test
+
+import (
+	"database/sql"
+	"movierama/internal/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_BusinessCounters(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	m.UserRegistered()
+	m.Login(metrics.LoginSucceeded)
+	m.Login(metrics.LoginFailed)
+	m.Login(metrics.LoginFailed)
+	m.MovieCreated()
+	m.VoteCast("like")
+	m.VoteCast("like")
+	m.VoteCast("hate")
+	m.ModerationAction("ban")
+	m.MovieReported("spam")
+	m.MovieFiltered("links", "reject")
+
+	exp := `
+# HELP movierama_logins_total Number of login attempts by result.
+# TYPE movierama_logins_total counter
+movierama_logins_total{result="blocked"} 0
+movierama_logins_total{result="denied"} 0
+movierama_logins_total{result="failed"} 2
+movierama_logins_total{result="succeeded"} 1
+# HELP movierama_moderation_actions_total Number of moderation actions by type.
+# TYPE movierama_moderation_actions_total counter
+movierama_moderation_actions_total{action="ban"} 1
+# HELP movierama_movie_reports_total Number of movie reports by category.
+# TYPE movierama_movie_reports_total counter
+movierama_movie_reports_total{category="spam"} 1
+# HELP movierama_movies_created_total Number of submitted movies.
+# TYPE movierama_movies_created_total counter
+movierama_movies_created_total 1
+# HELP movierama_movies_filtered_total Number of submitted movies rejected or flagged by the content filter, by rule and verdict.
+# TYPE movierama_movies_filtered_total counter
+movierama_movies_filtered_total{rule="links",verdict="reject"} 1
+# HELP movierama_registrations_total Number of registered users.
+# TYPE movierama_registrations_total counter
+movierama_registrations_total 1
+# HELP movierama_votes_total Number of votes cast by type.
+# TYPE movierama_votes_total counter
+movierama_votes_total{type="hate"} 1
+movierama_votes_total{type="like"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(exp),
+		"movierama_logins_total", "movierama_moderation_actions_total", "movierama_movie_reports_total", "movierama_movies_created_total", "movierama_movies_filtered_total",
+		"movierama_registrations_total", "movierama_votes_total"))
+}
+
+func TestMetrics_ObserveRequest(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	m.ObserveRequest("GET", "/users/:user_id/movies", 200, 30*time.Millisecond)
+	m.ObserveRequest("GET", "/users/:user_id/movies", 200, 70*time.Millisecond)
+	m.ObserveRequest("POST", "/api/v1/movies", 401, time.Millisecond)
+
+	exp := `
+# HELP movierama_http_requests_total Number of HTTP requests by route template, method and status code.
+# TYPE movierama_http_requests_total counter
+movierama_http_requests_total{method="GET",route="/users/:user_id/movies",status="200"} 2
+movierama_http_requests_total{method="POST",route="/api/v1/movies",status="401"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(exp), "movierama_http_requests_total"))
+
+	count, err := testutil.GatherAndCount(reg, "movierama_http_request_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestRegisterDB(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reader, _, err := sqlmock.New()
+	require.NoError(t, err)
+	writer, _, err := sqlmock.New()
+	require.NoError(t, err)
+
+	for name, db := range map[string]*sql.DB{"reader": reader, "writer": writer} {
+		require.NoError(t, metrics.RegisterDB(reg, name, db))
+	}
+	assert.Error(t, metrics.RegisterDB(reg, "reader", reader), "the names must be unique")
+
+	count, err := testutil.GatherAndCount(reg, "go_sql_open_connections")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
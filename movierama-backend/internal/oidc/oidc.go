@@ -0,0 +1,173 @@
+// Package oidc logs the users in with external identity providers, with the OAuth2 authorization code flow
+// and PKCE, and returns the identities they vouch for.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Flow errors.
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	// ErrInvalidState is returned for a callback whose state was not issued, has expired, was already used or
+	// was started by another client.
+	ErrInvalidState = errors.New("invalid or expired login state")
+	// ErrInvalidToken is returned when the provider refuses the code or vouches for it with an invalid token.
+	ErrInvalidToken = errors.New("invalid identity token")
+)
+
+// Identity is a user as told by an identity provider.
+type Identity struct {
+	Provider string
+	// Subject identifies the user at the provider, it never changes.
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+// Provider is an identity provider.
+type Provider interface {
+	// Name identifies the provider in the routes and the linked identities.
+	Name() string
+	// AuthCodeURL returns the page of the provider asking the user to log in.
+	AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error)
+	// Exchange trades the code of the callback for the identity of the user.
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// Pending is a started login, kept until its callback.
+type Pending struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// UserID is the user linking the identity to their account, zero for a login.
+	UserID int `json:"user_id,omitempty"`
+	// Binding is the hash of the secret of the client that started the login, which completes it.
+	Binding string `json:"binding"`
+}
+
+// StateStore keeps the pending logins by state.
+type StateStore interface {
+	Save(ctx context.Context, state string, p Pending, ttl time.Duration) error
+	// Take returns and removes the pending login of state, nil when there is none.
+	Take(ctx context.Context, state string) (*Pending, error)
+}
+
+// Flow runs the authorization code flows of the providers.
+type Flow struct {
+	providers map[string]Provider
+	states    StateStore
+	ttl       time.Duration
+}
+
+// NewFlow constructor, the logins must complete within ttl.
+func NewFlow(states StateStore, ttl time.Duration, providers ...Provider) *Flow {
+	f := &Flow{providers: make(map[string]Provider), states: states, ttl: ttl}
+	for _, p := range providers {
+		f.providers[p.Name()] = p
+	}
+
+	return f
+}
+
+// Providers returns the names of the providers, sorted.
+func (f *Flow) Providers() []string {
+	if f == nil {
+		return nil
+	}
+	names := make([]string, 0, len(f.providers))
+	for name := range f.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Begin starts a login with the provider and returns the page it redirects to along with the secret binding
+// the login to the client, which keeps it out of reach of the page, e.g. in an HttpOnly cookie. userID is the
+// user linking the identity to their account, zero for a login.
+func (f *Flow) Begin(ctx context.Context, provider string, userID int) (authURL, secret string, err error) {
+	if f == nil || f.providers[provider] == nil {
+		return "", "", ErrUnknownProvider
+	}
+	state, err := random()
+	if err != nil {
+		return "", "", err
+	}
+	p := Pending{Provider: provider, UserID: userID}
+	if p.Nonce, err = random(); err != nil {
+		return "", "", err
+	}
+	if p.Verifier, err = random(); err != nil {
+		return "", "", err
+	}
+	if secret, err = random(); err != nil {
+		return "", "", err
+	}
+	p.Binding = Challenge(secret)
+	if err = f.states.Save(ctx, state, p, f.ttl); err != nil {
+		return "", "", fmt.Errorf("save login state: %w", err)
+	}
+	if authURL, err = f.providers[provider].AuthCodeURL(ctx, state, p.Nonce, Challenge(p.Verifier)); err != nil {
+		return "", "", err
+	}
+
+	return authURL, secret, nil
+}
+
+// Complete ends the login of state with the code of the callback and the secret of the client that started
+// it, and returns its pending login, whose state can no longer be used, and the identity of the user.
+func (f *Flow) Complete(ctx context.Context, code, state, secret string) (*Pending, *Identity, error) {
+	if f == nil {
+		return nil, nil, ErrInvalidState
+	}
+	p, err := f.states.Take(ctx, state)
+	if err != nil {
+		return nil, nil, fmt.Errorf("take login state: %w", err)
+	}
+	if p == nil || f.providers[p.Provider] == nil {
+		return nil, nil, ErrInvalidState
+	}
+	// A state started by another client, e.g. sent to the user in a link, is refused.
+	if subtle.ConstantTimeCompare([]byte(Challenge(secret)), []byte(p.Binding)) != 1 {
+		return nil, nil, ErrInvalidState
+	}
+
+	id, err := f.providers[p.Provider].Exchange(ctx, code, p.Verifier, p.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	id.Provider = p.Provider
+
+	return p, id, nil
+}
+
+// Challenge returns the S256 PKCE challenge of verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// random returns 256 random bits, URL encoded, for the states, nonces, verifiers and secrets.
+func random() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
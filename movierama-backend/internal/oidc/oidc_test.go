@@ -0,0 +1,159 @@
+package oidc_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/internal/oidc"
+	"movierama/internal/oidc/oidctest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const redirectURL = "http://localhost:4200/auth/callback"
+
+var alice = oidctest.User{
+	Subject:       "1001",
+	Username:      "alice",
+	Email:         "alice@example.com",
+	EmailVerified: true,
+	GivenName:     "Alice",
+	FamilyName:    "Liddell",
+}
+
+func TestFlow(t *testing.T) {
+	srv := oidctest.NewServer(t)
+
+	tests := map[string]struct {
+		cfg   oidc.Config
+		expID *oidc.Identity
+	}{
+		"should log in with an OpenID Connect provider": {
+			cfg: srv.OIDC("test", redirectURL),
+			expID: &oidc.Identity{Provider: "test", Subject: "1001", Username: "alice", Email: "alice@example.com",
+				EmailVerified: true, GivenName: "Alice", FamilyName: "Liddell"},
+		},
+		"should log in with a plain OAuth2 provider": {
+			cfg: srv.OAuth2("test", redirectURL),
+			expID: &oidc.Identity{Provider: "test", Subject: "1001", Username: "alice", Email: "alice@example.com",
+				Name: "Alice Liddell"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(tt.cfg))
+
+			authURL, secret, err := flow.Begin(ctx, "test", 7)
+			require.NoError(t, err)
+			u, err := url.Parse(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, redirectURL, u.Query().Get("redirect_uri"))
+			assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+
+			code, state := srv.Authorize(t, authURL, alice)
+			p, id, err := flow.Complete(ctx, code, state, secret)
+			require.NoError(t, err)
+			assert.Equal(t, 7, p.UserID)
+			assert.Equal(t, tt.expID, id)
+
+			// The state is used once.
+			_, _, err = flow.Complete(ctx, code, state, secret)
+			assert.ErrorIs(t, err, oidc.ErrInvalidState)
+		})
+	}
+}
+
+func TestFlow_Errors(t *testing.T) {
+	srv := oidctest.NewServer(t)
+	ctx := context.Background()
+
+	t.Run("should reject an unknown provider", func(t *testing.T) {
+		flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(srv.OIDC("test", redirectURL)))
+		_, _, err := flow.Begin(ctx, "other", 0)
+		assert.ErrorIs(t, err, oidc.ErrUnknownProvider)
+	})
+
+	t.Run("should reject an unknown state", func(t *testing.T) {
+		flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(srv.OIDC("test", redirectURL)))
+		_, _, err := flow.Complete(ctx, "code", "state", "secret")
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("should reject an invalid code", func(t *testing.T) {
+		flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(srv.OIDC("test", redirectURL)))
+		authURL, secret, err := flow.Begin(ctx, "test", 0)
+		require.NoError(t, err)
+		_, state := srv.Authorize(t, authURL, alice)
+
+		_, _, err = flow.Complete(ctx, "forged", state, secret)
+		assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+	})
+
+	t.Run("should reject a code redeemed without its verifier", func(t *testing.T) {
+		flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(srv.OIDC("test", redirectURL)))
+		authURL, _, err := flow.Begin(ctx, "test", 0)
+		require.NoError(t, err)
+		code, _ := srv.Authorize(t, authURL, alice)
+
+		// A second login of the attacker, whose state comes with another verifier.
+		otherURL, otherSecret, err := flow.Begin(ctx, "test", 0)
+		require.NoError(t, err)
+		_, otherState := srv.Authorize(t, otherURL, alice)
+
+		_, _, err = flow.Complete(ctx, code, otherState, otherSecret)
+		assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+	})
+
+	t.Run("should reject an ID token of another issuer", func(t *testing.T) {
+		other := oidctest.NewServer(t)
+		other.TokenIssuer = "https://evil.example.com"
+		flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(other.OIDC("test", redirectURL)))
+		authURL, secret, err := flow.Begin(ctx, "test", 0)
+		require.NoError(t, err)
+		code, state := other.Authorize(t, authURL, alice)
+
+		_, _, err = flow.Complete(ctx, code, state, secret)
+		assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+	})
+
+	t.Run("should reject an expired state", func(t *testing.T) {
+		flow := oidc.NewFlow(oidc.NewMemoryStore(), 10*time.Millisecond, oidc.New(srv.OIDC("test", redirectURL)))
+		authURL, secret, err := flow.Begin(ctx, "test", 0)
+		require.NoError(t, err)
+		code, state := srv.Authorize(t, authURL, alice)
+		time.Sleep(20 * time.Millisecond)
+
+		_, _, err = flow.Complete(ctx, code, state, secret)
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("should reject a state started by another client", func(t *testing.T) {
+		flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.New(srv.OIDC("test", redirectURL)))
+		// The attacker starts the login and sends its page to the user.
+		authURL, _, err := flow.Begin(ctx, "test", 0)
+		require.NoError(t, err)
+		_, userSecret, err := flow.Begin(ctx, "test", 0)
+		require.NoError(t, err)
+		code, state := srv.Authorize(t, authURL, alice)
+
+		_, _, err = flow.Complete(ctx, code, state, userSecret)
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+}
+
+func TestFlow_Providers(t *testing.T) {
+	flow := oidc.NewFlow(oidc.NewMemoryStore(), time.Minute, oidc.NewGoogle("id", "secret", redirectURL),
+		oidc.NewGitHub("id", "secret", redirectURL))
+	assert.Equal(t, []string{"github", "google"}, flow.Providers())
+
+	var none *oidc.Flow
+	assert.Empty(t, none.Providers())
+}
+
+func TestChallenge(t *testing.T) {
+	// The example of RFC 7636, appendix B.
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", oidc.Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
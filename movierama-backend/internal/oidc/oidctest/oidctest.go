@@ -0,0 +1,251 @@
+// Package oidctest provides a local identity provider to test the logins end-to-end, it serves the
+// discovery document, the authorization, token, user info and key set endpoints of OpenID Connect.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"movierama/internal/oidc"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Client credentials accepted by the server.
+const (
+	ClientID     = "movierama"
+	ClientSecret = "client-secret"
+	keyID        = "test-key"
+)
+
+// User is the user logging in at the provider.
+type User struct {
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+type grant struct {
+	user        User
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// Server is a local identity provider.
+type Server struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu sync.Mutex
+	// user logs in on the next authorization.
+	user   User
+	grants map[string]grant
+	tokens map[string]User
+	// TokenIssuer overrides the issuer of the ID tokens, to test their verification.
+	TokenIssuer string
+}
+
+// NewServer starts a provider closed with the test.
+func NewServer(t *testing.T) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	s := &Server{key: key, grants: make(map[string]grant), tokens: make(map[string]User)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userInfo)
+	mux.HandleFunc("/jwks", s.jwks)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// OIDC returns the config of the provider as an OpenID Connect provider named name.
+func (s *Server) OIDC(name, redirectURL string) oidc.Config {
+	return oidc.Config{
+		Name:         name,
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		Issuer:       s.URL,
+	}
+}
+
+// OAuth2 returns the config of the provider as a plain OAuth2 provider named name, like GitHub.
+func (s *Server) OAuth2(name, redirectURL string) oidc.Config {
+	return oidc.Config{
+		Name:         name,
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      s.URL + "/authorize",
+		TokenURL:     s.URL + "/token",
+		UserInfoURL:  s.URL + "/userinfo",
+	}
+}
+
+// Authorize logs u in at the page of authURL, as the browser would, and returns the code and the state of
+// the callback.
+func (s *Server) Authorize(t *testing.T, authURL string, u User) (code, state string) {
+	s.mu.Lock()
+	s.user = u
+	s.mu.Unlock()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusFound, res.StatusCode)
+
+	loc, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(t, err)
+
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" ||
+		q.Get("code_challenge") == "" || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := random()
+	s.mu.Lock()
+	s.grants[code] = grant{user: s.user, redirectURI: q.Get("redirect_uri"), challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	s.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	if r.PostForm.Get("client_id") != ClientID || r.PostForm.Get("client_secret") != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	g, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	if !ok || g.redirectURI != r.PostForm.Get("redirect_uri") || oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	accessToken := random()
+	s.mu.Lock()
+	s.tokens[accessToken] = g.user
+	issuer := s.TokenIssuer
+	s.mu.Unlock()
+	if issuer == "" {
+		issuer = s.URL
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                issuer,
+		"aud":                ClientID,
+		"sub":                g.user.Subject,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              g.nonce,
+		"preferred_username": g.user.Username,
+		"email":              g.user.Email,
+		"email_verified":     g.user.EmailVerified,
+		"given_name":         g.user.GivenName,
+		"family_name":        g.user.FamilyName,
+	})
+	tok.Header["kid"] = keyID
+	idToken, err := tok.SignedString(s.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": accessToken, "token_type": "Bearer", "id_token": idToken})
+}
+
+// userInfo describes the user like GitHub, with a numeric id and a login.
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	u, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":    json.Number(u.Subject),
+		"login": u.Username,
+		"name":  u.GivenName + " " + u.FamilyName,
+		"email": u.Email,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, _ *http.Request) {
+	enc := base64.RawURLEncoding
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   enc.EncodeToString(s.key.N.Bytes()),
+			"e":   enc.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func random() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,470 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// httpTimeout bounds the calls to the providers.
+	httpTimeout = 10 * time.Second
+	// keysRefreshInterval is the minimum interval between two fetches of the signing keys, so that the tokens
+	// of unknown keys do not hammer the provider.
+	keysRefreshInterval = time.Minute
+	// maxResponseSize bounds the responses of the providers.
+	maxResponseSize = 1 << 20
+)
+
+// Config describes a provider. The OpenID Connect providers are discovered from their Issuer and vouch for
+// the users with ID tokens, the plain OAuth2 providers set their endpoints and describe the users on their
+// UserInfoURL.
+type Config struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the page receiving the code and the state of the callback.
+	RedirectURL string
+	Scopes      []string
+	Issuer      string
+	// AuthURL, TokenURL and UserInfoURL override the discovered endpoints.
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// Provider presets.
+const (
+	GitHub = "github"
+	Google = "google"
+)
+
+// NewGitHub returns the GitHub provider, a plain OAuth2 provider.
+func NewGitHub(clientID, clientSecret, redirectURL string) Provider {
+	return New(Config{
+		Name:         GitHub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"read:user", "user:email"},
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		UserInfoURL:  "https://api.github.com/user",
+	})
+}
+
+// NewGoogle returns the Google provider, an OpenID Connect provider.
+func NewGoogle(clientID, clientSecret, redirectURL string) Provider {
+	return New(Config{
+		Name:         Google,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		Issuer:       "https://accounts.google.com",
+	})
+}
+
+// endpoints are the endpoints of a provider.
+type endpoints struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+}
+
+type provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu         sync.Mutex
+	endpoints  *endpoints
+	keys       map[string]crypto.PublicKey
+	keysLoaded time.Time
+}
+
+// New returns the provider of cfg, an OpenID Connect provider is discovered on its first login.
+func New(cfg Config) Provider {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+
+	return &provider{cfg: cfg, client: client}
+}
+
+func (p *provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	ep, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	if p.cfg.Issuer != "" {
+		q.Set("nonce", nonce)
+	}
+	sep := "?"
+	if strings.Contains(ep.AuthURL, "?") {
+		sep = "&"
+	}
+
+	return ep.AuthURL + sep + q.Encode(), nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	ep, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var tok struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &tok)
+	if err != nil {
+		return nil, err
+	}
+	// GitHub reports the errors with a 200.
+	if status == http.StatusBadRequest || status == http.StatusUnauthorized || tok.Error != "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrInvalidToken, tok.Error, tok.ErrorDescription)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint: status %d", status)
+	}
+
+	var c claims
+	if p.cfg.Issuer != "" {
+		if c, err = p.verify(ctx, ep, tok.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	} else if c, err = p.userInfo(ctx, ep, tok.AccessToken); err != nil {
+		return nil, err
+	}
+
+	id := c.identity()
+	if id.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	return id, nil
+}
+
+// verify checks the signature, the issuer, the audience, the expiry and the nonce of an ID token and
+// returns its claims.
+func (p *provider) verify(ctx context.Context, ep *endpoints, raw, nonce string) (claims, error) {
+	if raw == "" {
+		return claims{}, fmt.Errorf("%w: no ID token", ErrInvalidToken)
+	}
+	mc := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, mc, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+
+		return p.key(ctx, ep, kid)
+	})
+	if err != nil {
+		return claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if !mc.VerifyIssuer(ep.Issuer, true) {
+		return claims{}, fmt.Errorf("%w: unexpected issuer %v", ErrInvalidToken, mc["iss"])
+	}
+	if !mc.VerifyAudience(p.cfg.ClientID, true) {
+		return claims{}, fmt.Errorf("%w: unexpected audience %v", ErrInvalidToken, mc["aud"])
+	}
+	if !mc.VerifyExpiresAt(time.Now().Unix(), true) {
+		return claims{}, fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	}
+	if n, _ := mc["nonce"].(string); n != nonce {
+		return claims{}, fmt.Errorf("%w: unexpected nonce", ErrInvalidToken)
+	}
+
+	// The claims are decoded again to read them as the user info.
+	b, err := json.Marshal(mc)
+	if err != nil {
+		return claims{}, err
+	}
+	var c claims
+	if err = json.Unmarshal(b, &c); err != nil {
+		return claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return c, nil
+}
+
+// userInfo returns the user of the access token.
+func (p *provider) userInfo(ctx context.Context, ep *endpoints, accessToken string) (claims, error) {
+	if ep.UserInfoURL == "" {
+		return claims{}, fmt.Errorf("provider %s has no user info endpoint", p.cfg.Name)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.UserInfoURL, nil)
+	if err != nil {
+		return claims{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	var c claims
+	status, err := p.do(req, &c)
+	if err != nil {
+		return claims{}, err
+	}
+	if status == http.StatusUnauthorized {
+		return claims{}, fmt.Errorf("%w: user info refused the access token", ErrInvalidToken)
+	}
+	if status != http.StatusOK {
+		return claims{}, fmt.Errorf("user info endpoint: status %d", status)
+	}
+
+	return c, nil
+}
+
+// discover returns the endpoints of the provider, fetched from the discovery document of the OpenID
+// Connect providers on first use.
+func (p *provider) discover(ctx context.Context) (*endpoints, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.endpoints != nil {
+		return p.endpoints, nil
+	}
+	ep := &endpoints{}
+	if p.cfg.Issuer != "" {
+		u := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		status, err := p.do(req, ep)
+		if err != nil {
+			return nil, fmt.Errorf("discover %s: %w", p.cfg.Name, err)
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("discover %s: status %d", p.cfg.Name, status)
+		}
+		if strings.TrimSuffix(ep.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+			return nil, fmt.Errorf("discover %s: unexpected issuer %q", p.cfg.Name, ep.Issuer)
+		}
+	}
+	for _, o := range []struct {
+		dst *string
+		src string
+	}{
+		{&ep.AuthURL, p.cfg.AuthURL},
+		{&ep.TokenURL, p.cfg.TokenURL},
+		{&ep.UserInfoURL, p.cfg.UserInfoURL},
+	} {
+		if o.src != "" {
+			*o.dst = o.src
+		}
+	}
+	if ep.AuthURL == "" || ep.TokenURL == "" {
+		return nil, fmt.Errorf("provider %s has no authorization or token endpoint", p.cfg.Name)
+	}
+	p.endpoints = ep
+
+	return ep, nil
+}
+
+// key returns the signing key of kid, the only key when kid is empty. The keys are fetched again for an
+// unknown kid, as the providers rotate them.
+func (p *provider) key(ctx context.Context, ep *endpoints, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k := p.lookup(kid); k != nil {
+		return k, nil
+	}
+	if time.Since(p.keysLoaded) < keysRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("signing keys: status %d", status)
+	}
+	p.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		// The keys of other types and uses are skipped.
+		if pub := k.publicKey(); pub != nil && (k.Use == "" || k.Use == "sig") {
+			p.keys[k.Kid] = pub
+		}
+	}
+	p.keysLoaded = time.Now()
+
+	if k := p.lookup(kid); k != nil {
+		return k, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *provider) lookup(kid string) crypto.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+
+	return p.keys[kid]
+}
+
+// do sends req and decodes its JSON response into v, it returns the status of the response.
+func (p *provider) do(req *http.Request, v interface{}) (int, error) {
+	req.Header.Set("Accept", "application/json")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return 0, err
+	}
+	// The errors may not be JSON, only their status matters then.
+	if err = json.Unmarshal(b, v); err != nil && res.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("decode %s: %w", req.URL.Path, err)
+	}
+
+	return res.StatusCode, nil
+}
+
+// jwk is a JSON web key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the RSA or EC key of k, nil when it is invalid or of another type.
+func (k jwk) publicKey() crypto.PublicKey {
+	switch k.Kty {
+	case "RSA":
+		n, e := decodeInt(k.N), decodeInt(k.E)
+		if n == nil || e == nil || !e.IsInt64() {
+			return nil
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, x, y := curves[k.Crv], decodeInt(k.X), decodeInt(k.Y)
+		if curve == nil || x == nil || y == nil || !curve.IsOnCurve(x, y) {
+			return nil
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	}
+
+	return nil
+}
+
+func decodeInt(s string) *big.Int {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+
+	return new(big.Int).SetBytes(b)
+}
+
+// claims describe a user in an ID token or a user info response, the GitHub users have a numeric id and a
+// login rather than a subject and a preferred username.
+type claims struct {
+	Subject           string      `json:"sub"`
+	ID                json.Number `json:"id"`
+	PreferredUsername string      `json:"preferred_username"`
+	Login             string      `json:"login"`
+	Email             string      `json:"email"`
+	// EmailVerified is a string for some providers.
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+}
+
+func (c claims) identity() *Identity {
+	id := &Identity{
+		Subject:    c.Subject,
+		Username:   c.PreferredUsername,
+		Email:      c.Email,
+		Name:       c.Name,
+		GivenName:  c.GivenName,
+		FamilyName: c.FamilyName,
+	}
+	if id.Subject == "" {
+		id.Subject = c.ID.String()
+	}
+	if id.Username == "" {
+		id.Username = c.Login
+	}
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		id.EmailVerified = v
+	case string:
+		id.EmailVerified = v == "true"
+	}
+
+	return id
+}
@@ -0,0 +1,95 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type pendingEntry struct {
+	Pending
+	expires time.Time
+}
+
+// MemoryStore keeps the pending logins in memory, they are not shared by replicas.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]pendingEntry
+}
+
+// NewMemoryStore constructor.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]pendingEntry)}
+}
+
+// Save implements StateStore, the expired logins are removed along the way.
+func (s *MemoryStore) Save(_ context.Context, state string, p Pending, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[state] = pendingEntry{Pending: p, expires: now.Add(ttl)}
+
+	return nil
+}
+
+// Take implements StateStore.
+func (s *MemoryStore) Take(_ context.Context, state string) (*Pending, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[state]
+	delete(s.entries, state)
+	if !ok || time.Now().After(e.expires) {
+		return nil, nil
+	}
+
+	return &e.Pending, nil
+}
+
+// RedisStore keeps the pending logins in redis, they are shared by replicas.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore constructor, the keys start with prefix.
+func NewRedisStore(client redis.Cmdable, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Save implements StateStore.
+func (s *RedisStore) Save(ctx context.Context, state string, p Pending, ttl time.Duration) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(ctx, s.prefix+state, b, ttl).Err()
+}
+
+// Take implements StateStore, the state is read and removed at once so that it is used once.
+func (s *RedisStore) Take(ctx context.Context, state string) (*Pending, error) {
+	b, err := s.client.GetDel(ctx, s.prefix+state).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pending{}
+	if err = json.Unmarshal(b, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
@@ -0,0 +1,53 @@
+package oidc_test
+
+import (
+	"context"
+	"movierama/internal/oidc"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) oidc.StateStore{
+		"memory": func(t *testing.T) oidc.StateStore {
+			return oidc.NewMemoryStore()
+		},
+		"redis": func(t *testing.T) oidc.StateStore {
+			mr := miniredis.RunT(t)
+			return oidc.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "movierama:oidc:")
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			p := oidc.Pending{Provider: "github", Nonce: "n", Verifier: "v", UserID: 2}
+
+			require.NoError(t, s.Save(ctx, "a", p, time.Hour))
+			got, err := s.Take(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, &p, got)
+
+			got, err = s.Take(ctx, "a")
+			require.NoError(t, err)
+			assert.Nil(t, got)
+		})
+	}
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	s := oidc.NewMemoryStore()
+
+	require.NoError(t, s.Save(context.Background(), "a", oidc.Pending{Provider: "github"}, 20*time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+
+	got, err := s.Take(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
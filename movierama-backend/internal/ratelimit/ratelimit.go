@@ -0,0 +1,260 @@
+// Package ratelimit limits the requests of the clients with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route groups, each one has its own limits.
+const (
+	GroupLogin    = "login"
+	GroupRegister = "register"
+	GroupMovies   = "movies"
+	GroupVotes    = "votes"
+	// GroupEmail contains the routes sending an email.
+	GroupEmail = "email"
+)
+
+// ErrLimited is returned when a request exceeds its limit.
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Limit allows Requests per Period, in bursts of up to Requests. The zero Limit does not limit.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses a limit written <requests>/<period>, e.g. 10/1m, an empty string or 0 does not limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("%q is not <requests>/<period>", s)
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%q: the requests must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(parts[1])
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%q: the period must be a positive duration", s)
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *Limit) UnmarshalText(text []byte) error {
+	parsed, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+
+	return nil
+}
+
+// String returns the limit in the format of ParseLimit.
+func (l Limit) String() string {
+	if l.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// IsZero reports whether the limit does not limit.
+func (l Limit) IsZero() bool {
+	return l.Requests == 0
+}
+
+// rate returns the tokens added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is the state of a bucket after taking a token.
+type Result struct {
+	Limit   Limit
+	Allowed bool
+	// Remaining is the number of requests allowed right away.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when it is.
+	RetryAfter time.Duration
+}
+
+// Headers returns the RateLimit-* headers of the result, and Retry-After when the request is not
+// allowed. The durations are rounded up to seconds.
+func (r Result) Headers() map[string]string {
+	if r.Limit.IsZero() {
+		return nil
+	}
+	h := map[string]string{
+		"RateLimit-Limit":     strconv.Itoa(r.Limit.Requests),
+		"RateLimit-Remaining": strconv.Itoa(r.Remaining),
+		"RateLimit-Reset":     strconv.Itoa(seconds(r.Reset)),
+		"RateLimit-Policy":    fmt.Sprintf("%d;w=%d", r.Limit.Requests, seconds(r.Limit.Period)),
+	}
+	if !r.Allowed {
+		h["Retry-After"] = strconv.Itoa(seconds(r.RetryAfter))
+	}
+
+	return h
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// result returns the result of a bucket of l left with tokens.
+func result(l Limit, tokens float64, allowed bool) Result {
+	res := Result{
+		Limit:     l,
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(l.Requests) - tokens) / l.rate() * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / l.rate() * float64(time.Second))
+	}
+
+	return res
+}
+
+// Store keeps the buckets.
+type Store interface {
+	// Take takes a token from the bucket of key, which allows l.
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+	// Refund gives back a token taken from the bucket of key, for a request another bucket rejected.
+	Refund(ctx context.Context, key string, l Limit) error
+}
+
+// Group contains the limits of a route group.
+type Group struct {
+	PerIP   Limit
+	PerUser Limit
+}
+
+// Limiter limits the requests of the route groups per client IP and per auth user.
+type Limiter struct {
+	store  Store
+	groups map[string]Group
+	allow  []*net.IPNet
+}
+
+// NewLimiter constructor, the allow list contains the IPs and CIDRs that are never limited.
+func NewLimiter(store Store, groups map[string]Group, allowList []string) (*Limiter, error) {
+	allow, err := ParseAllowList(allowList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Limiter{store: store, groups: groups, allow: allow}, nil
+}
+
+// ParseAllowList parses the IPs and CIDRs of an allow list.
+func ParseAllowList(allowList []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(allowList))
+	for _, s := range allowList {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP or a CIDR", s)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP or a CIDR", s)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// Allow takes a token from the buckets of the client IP and of the user, userID is zero for anonymous
+// requests. It returns the result of the most restrictive bucket, the zero Result when the request
+// is not limited. A request rejected by one bucket does not use the tokens of the other one. A store
+// error is returned along with an allowing result, so that the requests are not rejected while the
+// store is down.
+func (l *Limiter) Allow(ctx context.Context, group, ip string, userID int) (Result, error) {
+	if l == nil || l.allowed(ip) {
+		return Result{Allowed: true}, nil
+	}
+	g := l.groups[group]
+
+	ipKey := group + ":ip:" + ip
+	res := Result{Allowed: true}
+	if !g.PerIP.IsZero() {
+		r, err := l.store.Take(ctx, ipKey, g.PerIP)
+		if err != nil {
+			return Result{Allowed: true}, err
+		}
+		if !r.Allowed {
+			return r, nil
+		}
+		res = r
+	}
+	if userID == 0 || g.PerUser.IsZero() {
+		return res, nil
+	}
+	r, err := l.store.Take(ctx, group+":user:"+strconv.Itoa(userID), g.PerUser)
+	if err != nil {
+		return Result{Allowed: true}, err
+	}
+	if !r.Allowed {
+		// The request is rejected even when the refund fails.
+		if !g.PerIP.IsZero() {
+			err = l.store.Refund(ctx, ipKey, g.PerIP)
+		}
+		return r, err
+	}
+	if res.Limit.IsZero() || moreRestrictive(r, res) {
+		res = r
+	}
+
+	return res, nil
+}
+
+// moreRestrictive reports whether a leaves the client fewer requests than b.
+func moreRestrictive(a, b Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+
+	return a.Remaining < b.Remaining
+}
+
+func (l *Limiter) allowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range l.allow {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,219 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"movierama/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := map[string]struct {
+		s        string
+		expLimit ratelimit.Limit
+		expErr   bool
+	}{
+		"should parse a limit": {
+			s:        "10/1m",
+			expLimit: ratelimit.Limit{Requests: 10, Period: time.Minute},
+		},
+		"should not limit on empty": {
+			s: "",
+		},
+		"should not limit on zero": {
+			s: "0",
+		},
+		"should reject a missing period": {
+			s:      "10",
+			expErr: true,
+		},
+		"should reject non positive requests": {
+			s:      "0/1m",
+			expErr: true,
+		},
+		"should reject an invalid period": {
+			s:      "10/minute",
+			expErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, err := ratelimit.ParseLimit(tt.s)
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expLimit, l)
+			parsed, err := ratelimit.ParseLimit(l.String())
+			require.NoError(t, err)
+			assert.Equal(t, l, parsed)
+		})
+	}
+}
+
+// errStore fails every take and refund.
+type errStore struct{}
+
+func (errStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store down")
+}
+
+func (errStore) Refund(context.Context, string, ratelimit.Limit) error {
+	return errors.New("store down")
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	groups := map[string]ratelimit.Group{
+		ratelimit.GroupVotes: {
+			PerIP:   ratelimit.Limit{Requests: 3, Period: time.Minute},
+			PerUser: ratelimit.Limit{Requests: 2, Period: time.Minute},
+		},
+		ratelimit.GroupLogin: {
+			PerIP: ratelimit.Limit{Requests: 1, Period: time.Minute},
+		},
+	}
+
+	t.Run("should limit per user and per ip", func(t *testing.T) {
+		l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), groups, nil)
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		res, err := l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.1", 1)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		// The user bucket is the most restrictive one.
+		assert.Equal(t, 1, res.Remaining)
+		assert.Equal(t, groups[ratelimit.GroupVotes].PerUser, res.Limit)
+
+		res, _ = l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.1", 1)
+		assert.True(t, res.Allowed)
+		res, _ = l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.1", 1)
+		assert.False(t, res.Allowed)
+		assert.Greater(t, res.RetryAfter, time.Duration(0))
+
+		// Another user from the same ip is only limited by the ip bucket, the rejected request did not use it.
+		res, _ = l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.1", 2)
+		assert.True(t, res.Allowed)
+		res, _ = l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.1", 2)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, groups[ratelimit.GroupVotes].PerIP, res.Limit)
+		res, _ = l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.2", 2)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("should not use the user bucket on requests the ip bucket rejects", func(t *testing.T) {
+		l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), groups, nil)
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			res, _ := l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.1", i+1)
+			assert.True(t, res.Allowed)
+		}
+		res, _ := l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.1", 4)
+		assert.False(t, res.Allowed)
+		res, _ = l.Allow(ctx, ratelimit.GroupVotes, "10.1.0.2", 4)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 1, res.Remaining)
+	})
+
+	t.Run("should not limit the groups without limits", func(t *testing.T) {
+		l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), groups, nil)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			res, err := l.Allow(context.Background(), ratelimit.GroupMovies, "10.1.0.1", 1)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+		}
+	})
+
+	t.Run("should not limit the allow list", func(t *testing.T) {
+		l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), groups, []string{"10.0.0.0/8", "::1"})
+		require.NoError(t, err)
+
+		for _, ip := range []string{"10.2.3.4", "::1"} {
+			for i := 0; i < 3; i++ {
+				res, _ := l.Allow(context.Background(), ratelimit.GroupLogin, ip, 0)
+				assert.True(t, res.Allowed)
+			}
+		}
+		res, _ := l.Allow(context.Background(), ratelimit.GroupLogin, "192.168.1.1", 0)
+		assert.True(t, res.Allowed)
+		res, _ = l.Allow(context.Background(), ratelimit.GroupLogin, "192.168.1.1", 0)
+		assert.False(t, res.Allowed)
+	})
+
+	t.Run("should allow on store errors", func(t *testing.T) {
+		l, err := ratelimit.NewLimiter(errStore{}, groups, nil)
+		require.NoError(t, err)
+
+		res, err := l.Allow(context.Background(), ratelimit.GroupLogin, "10.1.0.1", 0)
+		assert.Error(t, err)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("should allow with a nil limiter", func(t *testing.T) {
+		var l *ratelimit.Limiter
+
+		res, err := l.Allow(context.Background(), ratelimit.GroupLogin, "10.1.0.1", 0)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("should reject an invalid allow list", func(t *testing.T) {
+		_, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), groups, []string{"internal"})
+		assert.Error(t, err)
+	})
+}
+
+func TestResult_Headers(t *testing.T) {
+	tests := map[string]struct {
+		res        ratelimit.Result
+		expHeaders map[string]string
+	}{
+		"should round up the durations": {
+			res: ratelimit.Result{
+				Limit:     ratelimit.Limit{Requests: 10, Period: time.Minute},
+				Allowed:   true,
+				Remaining: 4,
+				Reset:     35500 * time.Millisecond,
+			},
+			expHeaders: map[string]string{
+				"RateLimit-Limit":     "10",
+				"RateLimit-Remaining": "4",
+				"RateLimit-Reset":     "36",
+				"RateLimit-Policy":    "10;w=60",
+			},
+		},
+		"should set retry after when limited": {
+			res: ratelimit.Result{
+				Limit:      ratelimit.Limit{Requests: 10, Period: time.Minute},
+				Reset:      time.Minute,
+				RetryAfter: 5200 * time.Millisecond,
+			},
+			expHeaders: map[string]string{
+				"RateLimit-Limit":     "10",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+				"RateLimit-Policy":    "10;w=60",
+				"Retry-After":         "6",
+			},
+		},
+		"should not set headers when not limited": {
+			res: ratelimit.Result{Allowed: true},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expHeaders, tt.res.Headers())
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// sweepInterval is the interval between two removals of the idle buckets of the memory store.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket is full again, it can be forgotten after.
+	full time.Time
+}
+
+// MemoryStore keeps the buckets in memory, they are not shared by replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore constructor.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(_ context.Context, key string, l Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, b := range s.buckets {
+			if now.After(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	capacity := float64(l.Requests)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*l.rate())
+	b.last = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	res := result(l, b.tokens, allowed)
+	b.full = now.Add(res.Reset)
+
+	return res, nil
+}
+
+// Refund implements Store.
+func (s *MemoryStore) Refund(_ context.Context, key string, l Limit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.buckets[key]; ok {
+		b.tokens = math.Min(float64(l.Requests), b.tokens+1)
+	}
+
+	return nil
+}
+
+// takeScript takes a token from the bucket of KEYS[1] of ARGV[1] tokens, refilled in ARGV[2] microseconds.
+// The time of the redis server is used so that the replicas share the same clock.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * capacity / period)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(period / 1000))
+return {allowed, tostring(tokens)}
+`)
+
+// refundScript gives back a token to the bucket of KEYS[1] of ARGV[1] tokens, if it still exists.
+var refundScript = redis.NewScript(`
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens ~= nil then
+	redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[1]), tokens + 1)))
+end
+return 0
+`)
+
+// RedisStore keeps the buckets in redis, they are shared by replicas.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore constructor, the keys of the buckets start with prefix.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take implements Store.
+func (s *RedisStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	res, err := takeScript.Run(ctx, s.client, []string{s.prefix + key}, l.Requests, l.Period.Microseconds()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := res[0].(int64)
+	s1, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(s1, 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result(l, tokens, allowed == 1), nil
+}
+
+// Refund implements Store.
+func (s *RedisStore) Refund(ctx context.Context, key string, l Limit) error {
+	return refundScript.Run(ctx, s.client, []string{s.prefix + key}, l.Requests).Err()
+}
+
+// FallbackStore takes the tokens from the primary store, and from the fallback store while the
+// primary one fails, e.g. the buckets are kept in memory while redis is down. The primary store is
+// retried after RetryInterval, so that a down store does not slow every request down.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+	log      *zap.Logger
+	// RetryInterval is the wait before the primary store is tried again after a failure.
+	RetryInterval time.Duration
+
+	mu      sync.Mutex
+	failing bool
+	retryAt time.Time
+}
+
+// NewFallbackStore constructor.
+func NewFallbackStore(primary, fallback Store, logger *zap.Logger) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback, log: logger, RetryInterval: 5 * time.Second}
+}
+
+// Take implements Store.
+func (s *FallbackStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	s.mu.Lock()
+	skip := s.failing && time.Now().Before(s.retryAt)
+	s.mu.Unlock()
+	if skip {
+		return s.fallback.Take(ctx, key, l)
+	}
+
+	res, err := s.primary.Take(ctx, key, l)
+
+	s.mu.Lock()
+	switch {
+	case err != nil && !s.failing:
+		s.log.Warn("rate limit store failed, falling back", zap.Error(err))
+	case err == nil && s.failing:
+		s.log.Info("rate limit store recovered")
+	}
+	s.failing = err != nil
+	s.retryAt = time.Now().Add(s.RetryInterval)
+	s.mu.Unlock()
+
+	if err != nil {
+		return s.fallback.Take(ctx, key, l)
+	}
+
+	return res, nil
+}
+
+// Refund implements Store, the token goes back to the store it was taken from.
+func (s *FallbackStore) Refund(ctx context.Context, key string, l Limit) error {
+	s.mu.Lock()
+	failing := s.failing
+	s.mu.Unlock()
+	if failing {
+		return s.fallback.Refund(ctx, key, l)
+	}
+
+	return s.primary.Refund(ctx, key, l)
+}
@@ -0,0 +1,113 @@
+package ratelimit_test
+
+import (
+	"context"
+	"movierama/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) ratelimit.Store{
+		"memory": func(t *testing.T) ratelimit.Store {
+			return ratelimit.NewMemoryStore()
+		},
+		"redis": func(t *testing.T) ratelimit.Store {
+			mr := miniredis.RunT(t)
+			return ratelimit.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "movierama:ratelimit:")
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+			l := ratelimit.Limit{Requests: 2, Period: time.Hour}
+
+			res, err := s.Take(ctx, "a", l)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 1, res.Remaining)
+			assert.InDelta(t, 30*time.Minute, res.Reset, float64(time.Second))
+			assert.Zero(t, res.RetryAfter)
+
+			res, err = s.Take(ctx, "a", l)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+
+			res, err = s.Take(ctx, "a", l)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+			assert.InDelta(t, 30*time.Minute, res.RetryAfter, float64(time.Second))
+
+			// A refunded token is taken again, the refunds do not overfill the bucket.
+			require.NoError(t, s.Refund(ctx, "a", l))
+			res, err = s.Take(ctx, "a", l)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			for i := 0; i < 3; i++ {
+				require.NoError(t, s.Refund(ctx, "a", l))
+			}
+			res, err = s.Take(ctx, "a", l)
+			require.NoError(t, err)
+			assert.Equal(t, 1, res.Remaining)
+
+			// The buckets are independent.
+			res, err = s.Take(ctx, "b", l)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+		})
+	}
+}
+
+func TestMemoryStore_Refill(t *testing.T) {
+	s := ratelimit.NewMemoryStore()
+	l := ratelimit.Limit{Requests: 1, Period: 50 * time.Millisecond}
+
+	res, _ := s.Take(context.Background(), "a", l)
+	assert.True(t, res.Allowed)
+	res, _ = s.Take(context.Background(), "a", l)
+	assert.False(t, res.Allowed)
+
+	time.Sleep(60 * time.Millisecond)
+	res, _ = s.Take(context.Background(), "a", l)
+	assert.True(t, res.Allowed)
+}
+
+func TestFallbackStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	primary := ratelimit.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}), "")
+	s := ratelimit.NewFallbackStore(primary, ratelimit.NewMemoryStore(), zap.NewNop())
+	s.RetryInterval = 50 * time.Millisecond
+	l := ratelimit.Limit{Requests: 1, Period: time.Hour}
+
+	res, err := s.Take(context.Background(), "a", l)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.True(t, mr.Exists("a"))
+
+	// The memory store takes over while redis is down.
+	mr.Close()
+	res, err = s.Take(context.Background(), "a", l)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	res, err = s.Take(context.Background(), "a", l)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+
+	// Redis is retried once it is back.
+	require.NoError(t, mr.Restart())
+	time.Sleep(60 * time.Millisecond)
+	res, err = s.Take(context.Background(), "b", l)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.True(t, mr.Exists("b"))
+}
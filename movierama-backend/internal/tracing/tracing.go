@@ -0,0 +1,58 @@
+// Package tracing sets up the OpenTelemetry tracer provider of the app.
+package tracing
+
+import (
+	"context"
+	"movierama/internal/config"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the service name reported with the spans.
+const ServiceName = "movierama"
+
+// NewProvider returns the tracer provider configured by cfg along with its shutdown function,
+// which flushes the pending spans. Without an OTLP endpoint the provider is a no-op.
+func NewProvider(ctx context.Context, cfg config.Tracing) (trace.TracerProvider, func(context.Context) error, error) {
+	if cfg.OTLPEndpoint == "" {
+		return trace.NewNoopTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OTLPEndpoint)}
+	if cfg.OTLPInsecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+	)
+
+	return tp, tp.Shutdown, nil
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// NewTestProvider returns a tracer provider recording the spans in memory.
+func NewTestProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
@@ -0,0 +1,96 @@
+// Package migration embeds the SQL migrations of the database schema and applies them.
+package migration
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+const (
+	// mysqlNoSuchTable is the MySQL error number of a query on a missing table.
+	mysqlNoSuchTable = 1146
+	// pqUndefinedTable is the PostgreSQL error code of a query on a missing table.
+	pqUndefinedTable = "42P01"
+)
+
+// Dialect is the SQL flavour of a database, each one has its own migrations.
+type Dialect string
+
+// Supported dialects.
+const (
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// FS contains the migration files of each dialect, named <dialect>/<version>_<title>.<up|down>.sql.
+//
+//go:embed mysql/*.sql sqlite/*.sql postgres/*.sql
+var FS embed.FS
+
+// Migration is a change of the schema.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations returns the embedded migrations of the dialect sorted by version.
+func Migrations(d Dialect) ([]Migration, error) {
+	files, err := fs.Glob(FS, string(d)+"/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migrations for dialect %q", d)
+	}
+
+	// fs.Glob returns the names in lexical order, which is the version order.
+	migrations := make([]Migration, 0, len(files))
+	for _, f := range files {
+		base := strings.TrimSuffix(f, ".up.sql")
+		parts := strings.SplitN(strings.TrimPrefix(base, string(d)+"/"), "_", 2)
+		v, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", f, err)
+		}
+		up, err := FS.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		down, err := FS.ReadFile(base + ".down.sql")
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", f, err)
+		}
+
+		m := Migration{Version: v, Up: string(up), Down: string(down)}
+		if len(parts) == 2 {
+			m.Name = parts[1]
+		}
+		migrations = append(migrations, m)
+	}
+
+	return migrations, nil
+}
+
+// isNoSuchTable reports whether err is caused by a missing table.
+func isNoSuchTable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlNoSuchTable
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqUndefinedTable
+	}
+
+	// The SQLite driver only exposes the generic error code, match the message instead.
+	return strings.Contains(err.Error(), "no such table")
+}
@@ -0,0 +1,58 @@
+package migration_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/migration"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	tests := map[string]struct {
+		dialect migration.Dialect
+		expErr  bool
+	}{
+		"should load the mysql migrations": {
+			dialect: migration.MySQL,
+		},
+		"should load the sqlite migrations": {
+			dialect: migration.SQLite,
+		},
+		"should load the postgres migrations": {
+			dialect: migration.Postgres,
+		},
+		"should reject an unknown dialect": {
+			dialect: "oracle",
+			expErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := migration.Migrations(tt.dialect)
+			if tt.expErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			// Every dialect has the same versions so that they can be swapped.
+			mysql, err := migration.Migrations(migration.MySQL)
+			require.NoError(t, err)
+			assert.Equal(t, versions(mysql), versions(m))
+			assert.Equal(t, uint64(20221202135649), m[0].Version)
+			assert.Equal(t, "create_users", m[0].Name)
+			assert.Equal(t, uint64(20221202135951), m[2].Version)
+			assert.Contains(t, m[0].Up, "CREATE TABLE")
+			assert.Contains(t, m[0].Down, "DROP TABLE")
+		})
+	}
+}
+
+func versions(migrations []migration.Migration) []uint64 {
+	v := make([]uint64, len(migrations))
+	for i, m := range migrations {
+		v[i] = m.Version
+	}
+
+	return v
+}
@@ -0,0 +1,357 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// lockName is the advisory lock held while migrating, so that concurrent replicas wait for each other.
+	lockName = "movierama_schema_migrations"
+	// lockTimeoutSeconds bounds the wait for the lock held by another replica.
+	lockTimeoutSeconds = 60
+	// maxBackoff caps the wait between two connection attempts.
+	maxBackoff = 10 * time.Second
+)
+
+// ErrDirty is returned when the last migration failed half-way and the schema must be fixed by hand.
+var ErrDirty = errors.New("schema is dirty, fix it and force the version")
+
+// errLockTimeout is returned when another replica holds the migration lock for too long.
+var errLockTimeout = errors.New("timed out, another replica is migrating")
+
+// Status is a migration with whether it is applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Migrator applies the embedded migrations of its dialect to a database, a MySQL DSN must allow
+// multiStatements. The schema version is kept in the schema_migrations table.
+type Migrator struct {
+	db      *sql.DB
+	dialect Dialect
+	log     *zap.Logger
+	// Attempts and Backoff control the connection retries while the database comes up,
+	// the wait doubles after every failed attempt.
+	Attempts int
+	Backoff  time.Duration
+}
+
+// NewMigrator constructor.
+func NewMigrator(db *sql.DB, d Dialect, logger *zap.Logger) *Migrator {
+	return &Migrator{
+		db:       db,
+		dialect:  d,
+		log:      logger,
+		Attempts: 10,
+		Backoff:  500 * time.Millisecond,
+	}
+}
+
+// Up applies the pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, err := Migrations(m.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = m.locked(ctx, func(conn *sql.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range migrations {
+			if mig.Version <= version {
+				continue
+			}
+			if err = m.apply(ctx, conn, mig.Version, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.log.Info("migration applied", zap.Uint64("version", mig.Version), zap.String("name", mig.Name))
+			applied = append(applied, mig)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last n applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	migrations, err := Migrations(m.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	err = m.locked(ctx, func(conn *sql.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < n; i-- {
+			mig := migrations[i]
+			if mig.Version > version {
+				continue
+			}
+			var prev uint64
+			if i > 0 {
+				prev = migrations[i-1].Version
+			}
+			if err = m.apply(ctx, conn, mig.Version, mig.Down, prev); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.log.Info("migration reverted", zap.Uint64("version", mig.Version), zap.String("name", mig.Name))
+			reverted = append(reverted, mig)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Force sets the schema version without running any migration, to recover from a dirty schema.
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		return m.setVersion(ctx, conn, version, false)
+	})
+}
+
+// Version returns the schema version and whether its last migration failed half-way.
+func (m *Migrator) Version(ctx context.Context) (version uint64, dirty bool, err error) {
+	conn, err := m.connect(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	return readVersion(ctx, conn)
+}
+
+// Pending returns the number of migrations that are not applied, it fails when the schema is dirty.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	migrations, err := Migrations(m.dialect)
+	if err != nil {
+		return 0, err
+	}
+	version, dirty, err := readVersion(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("version %d: %w", version, ErrDirty)
+	}
+
+	pending := 0
+	for _, mig := range migrations {
+		if mig.Version > version {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+// Status returns the embedded migrations with whether they are applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := Migrations(m.dialect)
+	if err != nil {
+		return nil, err
+	}
+	version, _, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, len(migrations))
+	for i, mig := range migrations {
+		status[i] = Status{Migration: mig, Applied: mig.Version <= version}
+	}
+
+	return status, nil
+}
+
+// apply runs the statements of the migration version, marking the schema dirty until they succeed.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version uint64, statements string, next uint64) error {
+	if err := m.setVersion(ctx, conn, version, true); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+
+	return m.setVersion(ctx, conn, next, false)
+}
+
+// cleanVersion creates the schema_migrations table when missing and returns the version of a clean schema.
+func (m *Migrator) cleanVersion(ctx context.Context, conn *sql.Conn) (uint64, error) {
+	_, err := conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`)
+	if err != nil {
+		return 0, err
+	}
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("version %d: %w", version, ErrDirty)
+	}
+
+	return version, nil
+}
+
+// setVersion replaces the schema version, a version 0 leaves the table empty.
+func (m *Migrator) setVersion(ctx context.Context, conn *sql.Conn, version uint64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version > 0 {
+		insert := `INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)`
+		if m.dialect == Postgres {
+			insert = `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`
+		}
+		if _, err = tx.ExecContext(ctx, insert, version, dirty); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// locked runs fn on a connection holding the migration lock. SQLite databases are local files
+// that are not shared by replicas, they are not locked.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var release func(ctx context.Context, conn *sql.Conn) error
+	switch m.dialect {
+	case MySQL:
+		release, err = lockMySQL(ctx, conn)
+	case Postgres:
+		release, err = lockPostgres(ctx, conn)
+	default:
+		return fn(conn)
+	}
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if err := release(context.Background(), conn); err != nil {
+			m.log.Error("release migration lock", zap.Error(err))
+		}
+	}()
+
+	return fn(conn)
+}
+
+// lockMySQL acquires the named lock of MySQL on conn.
+func lockMySQL(ctx context.Context, conn *sql.Conn) (func(ctx context.Context, conn *sql.Conn) error, error) {
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, lockTimeoutSeconds).Scan(&acquired); err != nil {
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		return nil, errLockTimeout
+	}
+
+	return func(ctx context.Context, conn *sql.Conn) error {
+		var released sql.NullInt64
+		return conn.QueryRowContext(ctx, `SELECT RELEASE_LOCK(?)`, lockName).Scan(&released)
+	}, nil
+}
+
+// lockPostgres acquires the advisory lock of PostgreSQL on conn, polling since pg_advisory_lock
+// would wait without a timeout.
+func lockPostgres(ctx context.Context, conn *sql.Conn) (func(ctx context.Context, conn *sql.Conn) error, error) {
+	deadline := time.Now().Add(lockTimeoutSeconds * time.Second)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, lockName).Scan(&acquired); err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, errLockTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	return func(ctx context.Context, conn *sql.Conn) error {
+		var released bool
+		return conn.QueryRowContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, lockName).Scan(&released)
+	}, nil
+}
+
+// connect returns a connection, retrying with an exponential backoff while the database is not reachable.
+func (m *Migrator) connect(ctx context.Context) (*sql.Conn, error) {
+	wait := m.Backoff
+	for attempt := 1; ; attempt++ {
+		conn, err := m.db.Conn(ctx)
+		if err == nil {
+			if err = conn.PingContext(ctx); err == nil {
+				return conn, nil
+			}
+			conn.Close()
+		}
+		if attempt >= m.Attempts {
+			return nil, fmt.Errorf("connect to database: %w", err)
+		}
+		m.log.Warn("database not ready, retrying", zap.Int("attempt", attempt), zap.Duration("wait", wait), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maxBackoff {
+			wait = maxBackoff
+		}
+	}
+}
+
+// querier is implemented by *sql.DB and *sql.Conn.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// readVersion returns the schema version and whether its last migration failed half-way,
+// a database without the schema_migrations table is at version 0.
+func readVersion(ctx context.Context, q querier) (version uint64, dirty bool, err error) {
+	err = q.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, false, nil
+	case err != nil && isNoSuchTable(err):
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	}
+
+	return version, dirty, nil
+}
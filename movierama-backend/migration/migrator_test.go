@@ -0,0 +1,314 @@
+package migration_test
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"movierama/migration"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`
+	getLock     = `SELECT GET_LOCK(?, ?)`
+	releaseLock = `SELECT RELEASE_LOCK(?)`
+	getVersion  = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+)
+
+func migrations(t *testing.T) []migration.Migration {
+	m, err := migration.Migrations(migration.MySQL)
+	require.NoError(t, err)
+
+	return m
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(getLock).WithArgs("movierama_schema_migrations", 60).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+}
+
+func expectRelease(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(releaseLock).WithArgs("movierama_schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+}
+
+func expectVersion(mock sqlmock.Sqlmock, version uint64, dirty bool) {
+	mock.ExpectQuery(getVersion).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(version, dirty))
+}
+
+func expectSetVersion(mock sqlmock.Sqlmock, version uint64, dirty bool) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if version > 0 {
+		mock.ExpectExec(`INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)`).
+			WithArgs(version, dirty).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestMigrator_Up(t *testing.T) {
+	all := migrations(t)
+
+	tests := map[string]struct {
+		expect     func(mock sqlmock.Sqlmock)
+		expApplied []migration.Migration
+		expErr     error
+	}{
+		"should apply the pending migrations": {
+			expect: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectVersion(mock, all[0].Version, false)
+				for _, m := range all[1:] {
+					expectSetVersion(mock, m.Version, true)
+					mock.ExpectExec(m.Up).WillReturnResult(sqlmock.NewResult(0, 0))
+					expectSetVersion(mock, m.Version, false)
+				}
+				expectRelease(mock)
+			},
+			expApplied: all[1:],
+		},
+		"should leave the schema dirty when a migration fails": {
+			expect: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectVersion(mock, all[1].Version, false)
+				expectSetVersion(mock, all[2].Version, true)
+				mock.ExpectExec(all[2].Up).WillReturnError(errors.New("sql error"))
+				expectRelease(mock)
+			},
+			expErr: errors.New("migration 20221202135951_create_movies_users_actions: sql error"),
+		},
+		"should refuse to migrate a dirty schema": {
+			expect: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectVersion(mock, all[2].Version, true)
+				expectRelease(mock)
+			},
+			expErr: migration.ErrDirty,
+		},
+		"should fail when another replica holds the lock": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getLock).WithArgs("movierama_schema_migrations", 60).
+					WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+			},
+			expErr: errors.New("acquire migration lock: timed out, another replica is migrating"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+			tt.expect(mock)
+
+			applied, err := migration.NewMigrator(db, migration.MySQL, zap.NewNop()).Up(context.Background())
+			switch {
+			case tt.expErr == migration.ErrDirty:
+				assert.ErrorIs(t, err, migration.ErrDirty)
+			case tt.expErr != nil:
+				assert.EqualError(t, err, tt.expErr.Error())
+			default:
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expApplied, applied)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Down(t *testing.T) {
+	all := migrations(t)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectLock(mock)
+	mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectVersion(mock, all[1].Version, false)
+	expectSetVersion(mock, all[1].Version, true)
+	mock.ExpectExec(all[1].Down).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSetVersion(mock, all[0].Version, false)
+	expectSetVersion(mock, all[0].Version, true)
+	mock.ExpectExec(all[0].Down).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSetVersion(mock, 0, false)
+	expectRelease(mock)
+
+	reverted, err := migration.NewMigrator(db, migration.MySQL, zap.NewNop()).Down(context.Background(), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []migration.Migration{all[1], all[0]}, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Status(t *testing.T) {
+	all, err := migration.Migrations(migration.MySQL)
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+	expectVersion(mock, 20221202135812, false)
+
+	status, err := migration.NewMigrator(db, migration.MySQL, zap.NewNop()).Status(context.Background())
+	require.NoError(t, err)
+	applied := make([]bool, len(status))
+	for i, s := range status {
+		applied[i] = s.Applied
+	}
+	expApplied := make([]bool, len(all))
+	expApplied[0], expApplied[1] = true, true
+	assert.Equal(t, expApplied, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_RetriesConnection(t *testing.T) {
+	tests := map[string]struct {
+		failures int
+		expErr   bool
+	}{
+		"should retry until the database is up": {
+			failures: 2,
+		},
+		"should give up after the attempts": {
+			failures: 3,
+			expErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual), sqlmock.MonitorPingsOption(true))
+			require.NoError(t, err)
+			defer db.Close()
+			for i := 0; i < tt.failures; i++ {
+				mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+			}
+			if !tt.expErr {
+				mock.ExpectPing()
+				expectVersion(mock, 20221202135951, false)
+			}
+
+			m := migration.NewMigrator(db, migration.MySQL, zap.NewNop())
+			m.Attempts = 3
+			m.Backoff = time.Millisecond
+			version, _, err := m.Version(context.Background())
+			if tt.expErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(20221202135951), version)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Pending(t *testing.T) {
+	const query = getVersion
+	all, err := migration.Migrations(migration.MySQL)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		expect     func(mock sqlmock.Sqlmock)
+		expPending int
+		expErr     bool
+	}{
+		"should count the migrations above the schema version": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).
+					AddRow(20221202135649, false))
+			},
+			expPending: len(all) - 1,
+		},
+		"should have none pending when up to date": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).
+					AddRow(all[len(all)-1].Version, false))
+			},
+		},
+		"should count all the migrations of an empty database": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnError(&mysql.MySQLError{Number: 1146})
+			},
+			expPending: len(all),
+		},
+		"should count all the migrations of an empty postgres database": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnError(&pq.Error{Code: "42P01"})
+			},
+			expPending: len(all),
+		},
+		"should fail on a dirty schema": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).
+					AddRow(20221202135812, true))
+			},
+			expErr: true,
+		},
+		"should fail on a query error": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnError(errors.New("sql error"))
+			},
+			expErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+			tt.expect(mock)
+
+			pending, err := migration.NewMigrator(db, migration.MySQL, zap.NewNop()).Pending(context.Background())
+			if tt.expErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expPending, pending)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Up_Postgres(t *testing.T) {
+	all, err := migration.Migrations(migration.Postgres)
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	lock := `SELECT pg_try_advisory_lock(hashtext($1))`
+	mock.ExpectQuery(lock).WithArgs("movierama_schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(true))
+	mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectVersion(mock, all[1].Version, false)
+	for _, m := range all[2:] {
+		for _, dirty := range []bool{true, false} {
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`).
+				WithArgs(m.Version, dirty).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			if dirty {
+				mock.ExpectExec(m.Up).WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+		}
+	}
+	mock.ExpectQuery(`SELECT pg_advisory_unlock(hashtext($1))`).WithArgs("movierama_schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"unlock"}).AddRow(true))
+
+	applied, err := migration.NewMigrator(db, migration.Postgres, zap.NewNop()).Up(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, all[2:], applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
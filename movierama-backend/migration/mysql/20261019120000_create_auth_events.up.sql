@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `auth_events`
+(
+    `id`         bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id`    int unsigned             DEFAULT NULL,
+    `username`   varchar(255)    NOT NULL,
+    `event`      varchar(32)     NOT NULL,
+    `ip`         varchar(45)     NOT NULL,
+    `user_agent` varchar(512)    NOT NULL,
+    `created_at` timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `user_id` (`user_id`),
+    KEY `username` (`username`),
+    KEY `ip` (`ip`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4 COMMENT ='Store the authentication events';
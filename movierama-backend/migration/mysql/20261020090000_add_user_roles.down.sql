@@ -0,0 +1,6 @@
+ALTER TABLE `users`
+    DROP KEY `status`,
+    DROP KEY `role`,
+    DROP COLUMN `suspended_until`,
+    DROP COLUMN `status`,
+    DROP COLUMN `role`;
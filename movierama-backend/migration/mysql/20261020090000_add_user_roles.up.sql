@@ -0,0 +1,6 @@
+ALTER TABLE `users`
+    ADD COLUMN `role`            varchar(16) NOT NULL DEFAULT 'user',
+    ADD COLUMN `status`          varchar(16) NOT NULL DEFAULT 'active',
+    ADD COLUMN `suspended_until` timestamp   NULL     DEFAULT NULL,
+    ADD KEY `role` (`role`),
+    ADD KEY `status` (`status`);
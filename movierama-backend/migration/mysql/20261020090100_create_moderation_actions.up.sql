@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `moderation_actions`
+(
+    `id`           bigint unsigned NOT NULL AUTO_INCREMENT,
+    `moderator_id` int unsigned    NOT NULL,
+    `action`       varchar(32)     NOT NULL,
+    `user_id`      int unsigned             DEFAULT NULL,
+    `movie_id`     int unsigned             DEFAULT NULL,
+    `movie_title`  varchar(255)    NOT NULL DEFAULT '',
+    `detail`       varchar(64)     NOT NULL DEFAULT '',
+    `reason`       varchar(500)    NOT NULL,
+    `created_at`   timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `moderator_id` (`moderator_id`),
+    KEY `user_id` (`user_id`),
+    KEY `movie_id` (`movie_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4 COMMENT ='Store the moderation actions of the moderators and admins';
@@ -0,0 +1,3 @@
+ALTER TABLE `movies`
+    DROP KEY `hidden_at`,
+    DROP COLUMN `hidden_at`;
@@ -0,0 +1,3 @@
+ALTER TABLE `movies`
+    ADD COLUMN `hidden_at` timestamp NULL DEFAULT NULL,
+    ADD KEY `hidden_at` (`hidden_at`);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `movie_reports`
+(
+    `id`          bigint unsigned NOT NULL AUTO_INCREMENT,
+    `movie_id`    int unsigned    NOT NULL,
+    `reporter_id` int unsigned    NULL,
+    `source`      varchar(16)     NOT NULL DEFAULT 'user',
+    `category`    varchar(32)     NOT NULL,
+    `status`      varchar(16)     NOT NULL DEFAULT 'open',
+    `created_at`  timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `movie_id` (`movie_id`, `reporter_id`),
+    KEY `status` (`status`, `movie_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4 COMMENT ='Store the reports of the movies by the users';
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `user_identities`
+(
+    `id`         bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id`    int unsigned    NOT NULL,
+    `provider`   varchar(32)     NOT NULL,
+    `subject`    varchar(255)    NOT NULL,
+    `email`      varchar(255)    NOT NULL DEFAULT '',
+    `created_at` timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `provider` (`provider`, `subject`),
+    UNIQUE KEY `user_id` (`user_id`, `provider`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4 COMMENT ='Store the identities of the external identity providers linked to the users';
@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    DROP KEY `email`,
+    DROP COLUMN `email_verified_at`,
+    DROP COLUMN `email`;
@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    ADD COLUMN `email`             varchar(255) NULL DEFAULT NULL,
+    ADD COLUMN `email_verified_at` timestamp    NULL DEFAULT NULL,
+    ADD UNIQUE KEY `email` (`email`);
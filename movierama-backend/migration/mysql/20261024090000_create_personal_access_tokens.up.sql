@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `personal_access_tokens`
+(
+    `id`           bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id`      int unsigned    NOT NULL,
+    `name`         varchar(64)     NOT NULL,
+    `token_hash`   char(64)        NOT NULL,
+    `scopes`       varchar(255)    NOT NULL,
+    `expires_at`   timestamp       NULL DEFAULT NULL,
+    `last_used_at` timestamp       NULL DEFAULT NULL,
+    `created_at`   timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `token_hash` (`token_hash`),
+    KEY `user_id` (`user_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4 COMMENT ='Store the hashes of the personal access tokens of the users';
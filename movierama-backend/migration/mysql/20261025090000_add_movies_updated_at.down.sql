@@ -0,0 +1,3 @@
+ALTER TABLE `movies`
+    DROP KEY `updated_at`,
+    DROP COLUMN `updated_at`;
@@ -0,0 +1,4 @@
+ALTER TABLE `movies`
+    ADD COLUMN `updated_at` timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    ADD KEY `updated_at` (`updated_at`);
+UPDATE `movies` SET `updated_at` = `created_at`;
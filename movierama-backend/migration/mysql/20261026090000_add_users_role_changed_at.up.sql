@@ -0,0 +1,2 @@
+ALTER TABLE `users`
+    ADD COLUMN `role_changed_at` timestamp NULL DEFAULT NULL;
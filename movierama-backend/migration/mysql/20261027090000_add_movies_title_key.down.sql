@@ -0,0 +1,6 @@
+ALTER TABLE `movies`
+    DROP KEY `title_suffix`,
+    DROP KEY `title_prefix`,
+    DROP COLUMN `title_suffix`,
+    DROP COLUMN `title_prefix`,
+    DROP COLUMN `title_key`;
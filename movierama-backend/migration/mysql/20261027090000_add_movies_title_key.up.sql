@@ -0,0 +1,6 @@
+ALTER TABLE `movies`
+    ADD COLUMN `title_key`    text        CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL DEFAULT NULL,
+    ADD COLUMN `title_prefix` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL DEFAULT NULL,
+    ADD COLUMN `title_suffix` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL DEFAULT NULL,
+    ADD KEY `title_prefix` (`title_prefix`),
+    ADD KEY `title_suffix` (`title_suffix`);
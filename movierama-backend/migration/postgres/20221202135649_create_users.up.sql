@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id         serial       NOT NULL PRIMARY KEY,
+    username   varchar(255) NOT NULL UNIQUE,
+    password   char(60)     NOT NULL,
+    first_name varchar(255) NOT NULL,
+    last_name  varchar(255) NOT NULL
+);
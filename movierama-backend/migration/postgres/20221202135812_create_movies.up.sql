@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS movies
+(
+    id          serial       NOT NULL PRIMARY KEY,
+    title       varchar(255) NOT NULL,
+    user_id     integer      NOT NULL,
+    description text         NOT NULL,
+    created_at  timestamptz  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS movies_user_id ON movies (user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movies_users_actions;
+DROP TYPE IF EXISTS movie_action;
@@ -0,0 +1,10 @@
+CREATE TYPE movie_action AS ENUM ('like', 'hate');
+CREATE TABLE IF NOT EXISTS movies_users_actions
+(
+    id       serial       NOT NULL PRIMARY KEY,
+    user_id  integer      NOT NULL,
+    movie_id integer      NOT NULL,
+    action   movie_action NOT NULL,
+    UNIQUE (user_id, movie_id)
+);
+CREATE INDEX IF NOT EXISTS movies_users_actions_movie_id ON movies_users_actions (movie_id);
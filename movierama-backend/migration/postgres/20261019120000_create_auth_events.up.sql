@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS auth_events
+(
+    id         bigserial    NOT NULL PRIMARY KEY,
+    user_id    integer,
+    username   varchar(255) NOT NULL,
+    event      varchar(32)  NOT NULL,
+    ip         varchar(45)  NOT NULL,
+    user_agent varchar(512) NOT NULL,
+    created_at timestamptz  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS auth_events_user_id ON auth_events (user_id);
+CREATE INDEX IF NOT EXISTS auth_events_username ON auth_events (username);
+CREATE INDEX IF NOT EXISTS auth_events_ip ON auth_events (ip);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_status;
+DROP INDEX IF EXISTS users_role;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS suspended_until,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS role;
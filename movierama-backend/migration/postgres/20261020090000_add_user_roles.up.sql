@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role            varchar(16) NOT NULL DEFAULT 'user',
+    ADD COLUMN IF NOT EXISTS status          varchar(16) NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS suspended_until timestamptz;
+CREATE INDEX IF NOT EXISTS users_role ON users (role);
+CREATE INDEX IF NOT EXISTS users_status ON users (status);
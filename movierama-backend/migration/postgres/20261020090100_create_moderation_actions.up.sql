@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS moderation_actions
+(
+    id           bigserial    NOT NULL PRIMARY KEY,
+    moderator_id integer      NOT NULL,
+    action       varchar(32)  NOT NULL,
+    user_id      integer,
+    movie_id     integer,
+    movie_title  varchar(255) NOT NULL DEFAULT '',
+    detail       varchar(64)  NOT NULL DEFAULT '',
+    reason       varchar(500) NOT NULL,
+    created_at   timestamptz  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS moderation_actions_moderator_id ON moderation_actions (moderator_id);
+CREATE INDEX IF NOT EXISTS moderation_actions_user_id ON moderation_actions (user_id);
+CREATE INDEX IF NOT EXISTS moderation_actions_movie_id ON moderation_actions (movie_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_hidden_at;
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS hidden_at;
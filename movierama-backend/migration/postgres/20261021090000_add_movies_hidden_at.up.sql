@@ -0,0 +1,3 @@
+ALTER TABLE movies
+    ADD COLUMN IF NOT EXISTS hidden_at timestamptz;
+CREATE INDEX IF NOT EXISTS movies_hidden_at ON movies (hidden_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS movie_reports
+(
+    id          bigserial   NOT NULL PRIMARY KEY,
+    movie_id    integer     NOT NULL,
+    reporter_id integer     NULL,
+    source      varchar(16) NOT NULL DEFAULT 'user',
+    category    varchar(32) NOT NULL,
+    status      varchar(16) NOT NULL DEFAULT 'open',
+    created_at  timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (movie_id, reporter_id)
+);
+CREATE INDEX IF NOT EXISTS movie_reports_status ON movie_reports (status, movie_id);
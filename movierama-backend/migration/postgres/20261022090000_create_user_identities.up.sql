@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS user_identities
+(
+    id         bigserial    NOT NULL PRIMARY KEY,
+    user_id    integer      NOT NULL,
+    provider   varchar(32)  NOT NULL,
+    subject    varchar(255) NOT NULL,
+    email      varchar(255) NOT NULL DEFAULT '',
+    created_at timestamptz  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
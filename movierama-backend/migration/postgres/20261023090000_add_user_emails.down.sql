@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_email;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_verified_at,
+    DROP COLUMN IF EXISTS email;
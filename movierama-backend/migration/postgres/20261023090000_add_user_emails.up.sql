@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email             varchar(255),
+    ADD COLUMN IF NOT EXISTS email_verified_at timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users (email);
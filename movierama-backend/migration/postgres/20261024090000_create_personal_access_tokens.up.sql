@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS personal_access_tokens
+(
+    id           bigserial    NOT NULL PRIMARY KEY,
+    user_id      integer      NOT NULL,
+    name         varchar(64)  NOT NULL,
+    token_hash   char(64)     NOT NULL,
+    scopes       varchar(255) NOT NULL,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    created_at   timestamptz  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (token_hash)
+);
+CREATE INDEX IF NOT EXISTS personal_access_tokens_user_id ON personal_access_tokens (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_updated_at;
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS updated_at;
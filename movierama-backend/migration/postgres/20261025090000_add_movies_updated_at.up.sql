@@ -0,0 +1,4 @@
+ALTER TABLE movies
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP;
+UPDATE movies SET updated_at = created_at;
+CREATE INDEX IF NOT EXISTS movies_updated_at ON movies (updated_at);
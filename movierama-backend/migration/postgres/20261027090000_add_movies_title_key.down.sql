@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS movies_title_suffix;
+DROP INDEX IF EXISTS movies_title_prefix;
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS title_suffix,
+    DROP COLUMN IF EXISTS title_prefix,
+    DROP COLUMN IF EXISTS title_key;
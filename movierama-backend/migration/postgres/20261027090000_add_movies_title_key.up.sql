@@ -0,0 +1,6 @@
+ALTER TABLE movies
+    ADD COLUMN IF NOT EXISTS title_key    text,
+    ADD COLUMN IF NOT EXISTS title_prefix varchar(16),
+    ADD COLUMN IF NOT EXISTS title_suffix varchar(16);
+CREATE INDEX IF NOT EXISTS movies_title_prefix ON movies (title_prefix);
+CREATE INDEX IF NOT EXISTS movies_title_suffix ON movies (title_suffix);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `users`
+(
+    `id`         INTEGER      NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `username`   VARCHAR(255) NOT NULL UNIQUE,
+    `password`   CHAR(60)     NOT NULL,
+    `first_name` VARCHAR(255) NOT NULL,
+    `last_name`  VARCHAR(255) NOT NULL
+);
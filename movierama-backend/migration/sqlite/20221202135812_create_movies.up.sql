@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `movies`
+(
+    `id`          INTEGER      NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `title`       VARCHAR(255) NOT NULL,
+    `user_id`     INTEGER      NOT NULL,
+    `description` TEXT         NOT NULL,
+    `created_at`  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `movies_user_id` ON `movies` (`user_id`);
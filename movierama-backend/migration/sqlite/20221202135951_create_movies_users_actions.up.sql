@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `movies_users_actions`
+(
+    `id`       INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `user_id`  INTEGER NOT NULL,
+    `movie_id` INTEGER NOT NULL,
+    `action`   TEXT    NOT NULL CHECK (`action` IN ('like', 'hate')),
+    UNIQUE (`user_id`, `movie_id`)
+);
+CREATE INDEX IF NOT EXISTS `movies_users_actions_movie_id` ON `movies_users_actions` (`movie_id`);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `auth_events`
+(
+    `id`         INTEGER      NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `user_id`    INTEGER,
+    `username`   VARCHAR(255) NOT NULL,
+    `event`      VARCHAR(32)  NOT NULL,
+    `ip`         VARCHAR(45)  NOT NULL,
+    `user_agent` VARCHAR(512) NOT NULL,
+    `created_at` TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `auth_events_user_id` ON `auth_events` (`user_id`);
+CREATE INDEX IF NOT EXISTS `auth_events_username` ON `auth_events` (`username`);
+CREATE INDEX IF NOT EXISTS `auth_events_ip` ON `auth_events` (`ip`);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS `users_status`;
+DROP INDEX IF EXISTS `users_role`;
+ALTER TABLE `users` DROP COLUMN `suspended_until`;
+ALTER TABLE `users` DROP COLUMN `status`;
+ALTER TABLE `users` DROP COLUMN `role`;
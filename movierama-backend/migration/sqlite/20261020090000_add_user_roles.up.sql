@@ -0,0 +1,5 @@
+ALTER TABLE `users` ADD COLUMN `role` VARCHAR(16) NOT NULL DEFAULT 'user';
+ALTER TABLE `users` ADD COLUMN `status` VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE `users` ADD COLUMN `suspended_until` TIMESTAMP;
+CREATE INDEX IF NOT EXISTS `users_role` ON `users` (`role`);
+CREATE INDEX IF NOT EXISTS `users_status` ON `users` (`status`);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `moderation_actions`
+(
+    `id`           INTEGER      NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `moderator_id` INTEGER      NOT NULL,
+    `action`       VARCHAR(32)  NOT NULL,
+    `user_id`      INTEGER,
+    `movie_id`     INTEGER,
+    `movie_title`  VARCHAR(255) NOT NULL DEFAULT '',
+    `detail`       VARCHAR(64)  NOT NULL DEFAULT '',
+    `reason`       VARCHAR(500) NOT NULL,
+    `created_at`   TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `moderation_actions_moderator_id` ON `moderation_actions` (`moderator_id`);
+CREATE INDEX IF NOT EXISTS `moderation_actions_user_id` ON `moderation_actions` (`user_id`);
+CREATE INDEX IF NOT EXISTS `moderation_actions_movie_id` ON `moderation_actions` (`movie_id`);
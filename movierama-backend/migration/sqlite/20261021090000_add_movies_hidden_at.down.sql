@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS `movies_hidden_at`;
+ALTER TABLE `movies` DROP COLUMN `hidden_at`;
@@ -0,0 +1,2 @@
+ALTER TABLE `movies` ADD COLUMN `hidden_at` TIMESTAMP;
+CREATE INDEX IF NOT EXISTS `movies_hidden_at` ON `movies` (`hidden_at`);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `movie_reports`
+(
+    `id`          INTEGER     NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `movie_id`    INTEGER     NOT NULL,
+    `reporter_id` INTEGER     NULL,
+    `source`      VARCHAR(16) NOT NULL DEFAULT 'user',
+    `category`    VARCHAR(32) NOT NULL,
+    `status`      VARCHAR(16) NOT NULL DEFAULT 'open',
+    `created_at`  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (`movie_id`, `reporter_id`)
+);
+CREATE INDEX IF NOT EXISTS `movie_reports_status` ON `movie_reports` (`status`, `movie_id`);
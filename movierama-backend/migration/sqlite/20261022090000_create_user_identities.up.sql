@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `user_identities`
+(
+    `id`         INTEGER      NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `user_id`    INTEGER      NOT NULL,
+    `provider`   VARCHAR(32)  NOT NULL,
+    `subject`    VARCHAR(255) NOT NULL,
+    `email`      VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (`provider`, `subject`),
+    UNIQUE (`user_id`, `provider`)
+);
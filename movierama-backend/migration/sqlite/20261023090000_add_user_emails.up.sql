@@ -0,0 +1,3 @@
+ALTER TABLE `users` ADD COLUMN `email` VARCHAR(255);
+ALTER TABLE `users` ADD COLUMN `email_verified_at` TIMESTAMP;
+CREATE UNIQUE INDEX IF NOT EXISTS `users_email` ON `users` (`email`);
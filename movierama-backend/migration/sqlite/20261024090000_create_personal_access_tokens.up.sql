@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `personal_access_tokens`
+(
+    `id`           INTEGER      NOT NULL PRIMARY KEY AUTOINCREMENT,
+    `user_id`      INTEGER      NOT NULL,
+    `name`         VARCHAR(64)  NOT NULL,
+    `token_hash`   CHAR(64)     NOT NULL,
+    `scopes`       VARCHAR(255) NOT NULL,
+    `expires_at`   TIMESTAMP,
+    `last_used_at` TIMESTAMP,
+    `created_at`   TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (`token_hash`)
+);
+CREATE INDEX IF NOT EXISTS `personal_access_tokens_user_id` ON `personal_access_tokens` (`user_id`);
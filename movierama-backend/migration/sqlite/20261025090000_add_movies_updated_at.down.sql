@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS `movies_updated_at`;
+ALTER TABLE `movies` DROP COLUMN `updated_at`;
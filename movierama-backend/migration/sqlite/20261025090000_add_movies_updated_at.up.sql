@@ -0,0 +1,3 @@
+ALTER TABLE `movies` ADD COLUMN `updated_at` TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE `movies` SET `updated_at` = `created_at`;
+CREATE INDEX IF NOT EXISTS `movies_updated_at` ON `movies` (`updated_at`);
@@ -0,0 +1 @@
+ALTER TABLE `users` ADD COLUMN `role_changed_at` TIMESTAMP;
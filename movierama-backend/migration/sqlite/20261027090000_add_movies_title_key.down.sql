@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS `movies_title_suffix`;
+DROP INDEX IF EXISTS `movies_title_prefix`;
+ALTER TABLE `movies` DROP COLUMN `title_suffix`;
+ALTER TABLE `movies` DROP COLUMN `title_prefix`;
+ALTER TABLE `movies` DROP COLUMN `title_key`;
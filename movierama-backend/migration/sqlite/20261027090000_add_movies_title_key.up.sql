@@ -0,0 +1,5 @@
+ALTER TABLE `movies` ADD COLUMN `title_key` TEXT;
+ALTER TABLE `movies` ADD COLUMN `title_prefix` VARCHAR(16);
+ALTER TABLE `movies` ADD COLUMN `title_suffix` VARCHAR(16);
+CREATE INDEX IF NOT EXISTS `movies_title_prefix` ON `movies` (`title_prefix`);
+CREATE INDEX IF NOT EXISTS `movies_title_suffix` ON `movies` (`title_suffix`);